@@ -440,7 +440,7 @@ func api_admin_list_cloud_regions(c *gin.Context) {
 	}
 
 	// Create provider and query regions
-	p, err := cloudprovider.NewProvider(accountToProviderConfig(account))
+	p, err := newCloudProvider(account)
 	if err != nil {
 		log.Errorf(c, "failed to create provider: %v", err)
 		Error(c, ErrorSystemError, "failed to create provider")
@@ -493,7 +493,7 @@ func api_admin_list_cloud_plans(c *gin.Context) {
 		return
 	}
 
-	p, err := cloudprovider.NewProvider(accountToProviderConfig(account))
+	p, err := newCloudProvider(account)
 	if err != nil {
 		log.Errorf(c, "failed to create provider: %v", err)
 		Error(c, ErrorSystemError, "failed to create provider")
@@ -532,7 +532,7 @@ func api_admin_list_cloud_images(c *gin.Context) {
 		return
 	}
 
-	p, err := cloudprovider.NewProvider(accountToProviderConfig(account))
+	p, err := newCloudProvider(account)
 	if err != nil {
 		log.Errorf(c, "failed to create provider: %v", err)
 		Error(c, ErrorSystemError, "failed to create provider")
@@ -623,3 +623,112 @@ func api_admin_update_traffic_config(c *gin.Context) {
 func enqueueCloudTask(taskType string, payload any) (string, error) {
 	return ScheduleCloudTaskImmediate(taskType, payload)
 }
+
+// api_admin_cloud_provider_metrics returns per-account provider middleware
+// counters (rate limiting, throttle retries, circuit breaker state).
+func api_admin_cloud_provider_metrics(c *gin.Context) {
+	log.Infof(c, "admin request to get cloud provider metrics")
+
+	items := cloudprovider.GuardMetricsSnapshot()
+	ListWithData(c, items, &Pagination{Total: int64(len(items))})
+}
+
+// api_admin_list_cloud_ip_changes lists change-IP workflows (old→new IP
+// history). Filters: instance_id, ip (matches old or new), status.
+func api_admin_list_cloud_ip_changes(c *gin.Context) {
+	log.Infof(c, "admin request to list cloud ip changes")
+	pagination := PaginationFromRequest(c)
+
+	query := db.Get().Model(&CloudIPChange{})
+	if instanceID := c.Query("instance_id"); instanceID != "" {
+		query = query.Where("cloud_instance_id = ?", instanceID)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("old_ip = ? OR new_ip = ?", ip, ip)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&pagination.Total).Error; err != nil {
+		log.Errorf(c, "failed to count cloud ip changes: %v", err)
+		Error(c, ErrorSystemError, "failed to count ip changes")
+		return
+	}
+
+	var items []CloudIPChange
+	if err := query.Order("id DESC").
+		Offset(pagination.Offset()).
+		Limit(pagination.PageSize).
+		Find(&items).Error; err != nil {
+		log.Errorf(c, "failed to list cloud ip changes: %v", err)
+		Error(c, ErrorSystemError, "failed to list ip changes")
+		return
+	}
+
+	ListWithData(c, items, pagination)
+}
+
+// api_admin_cloud_rotation_signals runs the blocked-IP detector as a dry run
+// and returns every evaluated node with its signals and policy decision.
+func api_admin_cloud_rotation_signals(c *gin.Context) {
+	log.Infof(c, "admin request to get cloud rotation signals")
+
+	items, err := detectBlockedIPs(c)
+	if err != nil {
+		log.Errorf(c, "failed to evaluate blocked ips: %v", err)
+		Error(c, ErrorSystemError, "failed to evaluate blocked ips")
+		return
+	}
+	ListWithData(c, items, &Pagination{Total: int64(len(items))})
+}
+
+// api_admin_cloud_fleet_plan diffs the declarative fleet spec
+// (cloud_instance.fleet) against current instances. Read-only.
+func api_admin_cloud_fleet_plan(c *gin.Context) {
+	log.Infof(c, "admin request to plan cloud fleet")
+
+	plan, err := planCloudFleet(c)
+	if err != nil {
+		log.Errorf(c, "failed to plan cloud fleet: %v", err)
+		Error(c, ErrorSystemError, "failed to plan cloud fleet")
+		return
+	}
+	Success(c, plan)
+}
+
+// api_admin_cloud_fleet_apply re-plans server-side and submits the result
+// for approval; the client never supplies the actions.
+func api_admin_cloud_fleet_apply(c *gin.Context) {
+	log.Infof(c, "admin request to apply cloud fleet")
+
+	plan, err := planCloudFleet(c)
+	if err != nil {
+		log.Errorf(c, "failed to plan cloud fleet: %v", err)
+		Error(c, ErrorSystemError, "failed to plan cloud fleet")
+		return
+	}
+	if len(plan.Actions) == 0 {
+		Success(c, plan)
+		return
+	}
+	if plan.Settling {
+		Error(c, ErrorConflict, "previous fleet apply is still settling, retry later")
+		return
+	}
+
+	creates, deletes := plan.Counts()
+	approvalID, executed, err := SubmitApproval(c, approvalActionCloudFleetApply, plan,
+		fmt.Sprintf("云节点舰队对齐 (%s): 新建 %d 台, 删除 %d 台", plan.PlanID, creates, deletes))
+	if err != nil {
+		log.Errorf(c, "提交舰队对齐审批失败: %v", err)
+		Error(c, ErrorSystemError, "submit approval failed")
+		return
+	}
+
+	if !executed {
+		PendingApproval(c, approvalID)
+		return
+	}
+	Success(c, plan)
+}
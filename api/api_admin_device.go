@@ -98,8 +98,16 @@ func api_admin_issue_test_token(c *gin.Context) {
 	now := time.Now()
 	tokenIssueAt := now.Unix()
 
+	// 新会话：设备上原有的会话随之作废
+	session, err := startAuthSession(c, db.Get(), user.ID, device.UDID, classStr(device.IsGateway))
+	if err != nil {
+		log.Errorf(c, "Failed to start session: %v", err)
+		Error(c, ErrorSystemError, "failed to generate device token")
+		return
+	}
+
 	// 生成 token（传入用户角色）
-	tokenResp, err := generateDeviceToken(c, user.ID, device.UDID, tokenIssueAt, user.Roles)
+	tokenResp, err := generateDeviceToken(c, user.ID, device.UDID, tokenIssueAt, user.Roles, session.FamilyID)
 	if err != nil {
 		log.Errorf(c, "Failed to generate device token: %v", err)
 		Error(c, ErrorSystemError, "failed to generate device token")
@@ -133,7 +141,7 @@ type deviceTokenResult struct {
 }
 
 // generateDeviceToken 生成设备 access + refresh token
-func generateDeviceToken(ctx *gin.Context, userID uint64, deviceID string, tokenIssueAt int64, roles uint64) (*deviceTokenResult, error) {
+func generateDeviceToken(ctx *gin.Context, userID uint64, deviceID string, tokenIssueAt int64, roles uint64, sessionID string) (*deviceTokenResult, error) {
 	jwtConfig := configJwt(ctx)
 	jwtSecret := []byte(jwtConfig.Secret)
 
@@ -150,6 +158,7 @@ func generateDeviceToken(ctx *gin.Context, userID uint64, deviceID string, token
 		Type:         TokenTypeAccess,
 		TokenIssueAt: tokenIssueAt,
 		Roles:        roles,
+		SessionID:    sessionID,
 	}
 	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims).SignedString(jwtSecret)
 	if err != nil {
@@ -164,6 +173,7 @@ func generateDeviceToken(ctx *gin.Context, userID uint64, deviceID string, token
 		Type:         TokenTypeRefresh,
 		TokenIssueAt: tokenIssueAt,
 		Roles:        roles,
+		SessionID:    sessionID,
 	}
 	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString(jwtSecret)
 	if err != nil {
@@ -289,6 +289,10 @@ func api_admin_send_templated_emails(c *gin.Context) {
 		Error(c, ErrorInvalidArgument, err.Error())
 		return
 	}
+	if req.Category != "" && !req.Category.Valid() {
+		Error(c, ErrorInvalidArgument, "invalid category")
+		return
+	}
 
 	// 收集 slug 列表用于审批摘要
 	slugSet := make(map[string]bool)
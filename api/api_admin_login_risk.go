@@ -0,0 +1,165 @@
+package center
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// ==================== 登录风控记录 ====================
+
+// AdminLoginRiskEventItem 管理员风控记录列表项
+type AdminLoginRiskEventItem struct {
+	ID        uint64        `json:"id"`
+	CreatedAt int64         `json:"createdAt"`
+	Action    string        `json:"action"` // send_code | code_login | password_login | device_code
+	Brand     string        `json:"brand"`
+	User      *ResourceUser `json:"user,omitempty"` // 邮箱未注册时为空
+	IP        string        `json:"ip"`
+	Subnet    string        `json:"subnet"`
+	ASN       uint          `json:"asn"`
+	Country   string        `json:"country"`
+	UDID      string        `json:"udid"`
+	Score     int           `json:"score"`
+	Signals   []string      `json:"signals"`
+	Decision  string        `json:"decision"` // allow | captcha | email_code | block
+	Outcome   string        `json:"outcome"`  // success | failed | rejected，空 = 请求未走完
+}
+
+// AdminLoginRiskUnblockRequest 解除 IP 临时封禁
+type AdminLoginRiskUnblockRequest struct {
+	IP string `json:"ip" binding:"required"`
+}
+
+// api_admin_list_login_risk_events 查询登录风控记录
+// 支持按 email（按品牌索引哈希匹配）、ip、userUuid、decision、action 筛选
+func api_admin_list_login_risk_events(c *gin.Context) {
+	pagination := PaginationFromRequest(c)
+
+	dbQuery := db.Get().Model(&LoginRiskEvent{})
+
+	if email := strings.TrimSpace(c.Query("email")); email != "" {
+		cleaned, err := sanitizeEmail(email)
+		if err != nil {
+			Error(c, ErrorInvalidArgument, "invalid email format")
+			return
+		}
+		dbQuery = dbQuery.Where(&LoginRiskEvent{EmailHash: secretHashIt(c, []byte(cleaned))})
+	}
+	if ip := strings.TrimSpace(c.Query("ip")); ip != "" {
+		dbQuery = dbQuery.Where(&LoginRiskEvent{IP: ip})
+	}
+	if userUUID := c.Query("userUuid"); userUUID != "" {
+		var user User
+		if err := db.Get().Where(&User{UUID: userUUID}).First(&user).Error; err != nil {
+			Error(c, ErrorNotFound, "user not found")
+			return
+		}
+		dbQuery = dbQuery.Where(&LoginRiskEvent{UserID: user.ID})
+	}
+	if decision := c.Query("decision"); decision != "" {
+		dbQuery = dbQuery.Where(&LoginRiskEvent{Decision: decision})
+	}
+	if action := c.Query("action"); action != "" {
+		dbQuery = dbQuery.Where(&LoginRiskEvent{Action: action})
+	}
+
+	var total int64
+	if err := dbQuery.Count(&total).Error; err != nil {
+		log.Errorf(c, "统计风控记录失败: %v", err)
+		Error(c, ErrorSystemError, "count login risk events failed")
+		return
+	}
+	pagination.Total = total
+
+	var events []LoginRiskEvent
+	if err := dbQuery.
+		Order("id DESC").
+		Offset(pagination.Offset()).
+		Limit(pagination.PageSize).
+		Find(&events).Error; err != nil {
+		log.Errorf(c, "查询风控记录失败: %v", err)
+		Error(c, ErrorSystemError, "list login risk events failed")
+		return
+	}
+
+	// 批量取本页涉及的用户
+	userIDs := make([]uint64, 0, len(events))
+	for _, ev := range events {
+		if ev.UserID != 0 {
+			userIDs = append(userIDs, ev.UserID)
+		}
+	}
+	users := map[uint64]*ResourceUser{}
+	if len(userIDs) > 0 {
+		var rows []User
+		if err := db.Get().Preload("LoginIdentifies").Where("id IN ?", userIDs).Find(&rows).Error; err != nil {
+			log.Errorf(c, "查询风控记录关联用户失败: %v", err)
+		}
+		for _, u := range rows {
+			ru := &ResourceUser{UUID: u.UUID}
+			for _, identify := range u.LoginIdentifies {
+				if identify.Type == "email" {
+					if email, err := secretDecryptString(c, identify.EncryptedValue); err == nil {
+						ru.Email = email
+					}
+					break
+				}
+			}
+			users[u.ID] = ru
+		}
+	}
+
+	items := make([]AdminLoginRiskEventItem, len(events))
+	for i, ev := range events {
+		signals := []string{}
+		if ev.Signals != "" {
+			signals = strings.Split(ev.Signals, ",")
+		}
+		items[i] = AdminLoginRiskEventItem{
+			ID:        ev.ID,
+			CreatedAt: ev.CreatedAt.Unix(),
+			Action:    ev.Action,
+			Brand:     ev.Brand,
+			User:      users[ev.UserID],
+			IP:        ev.IP,
+			Subnet:    ev.Subnet,
+			ASN:       ev.ASN,
+			Country:   ev.Country,
+			UDID:      ev.UDID,
+			Score:     ev.Score,
+			Signals:   signals,
+			Decision:  ev.Decision,
+			Outcome:   ev.Outcome,
+		}
+	}
+
+	List(c, items, pagination)
+}
+
+// api_admin_login_risk_unblock 解除 IP 的临时登录封禁（管理员直接执行，无需审批）
+func api_admin_login_risk_unblock(c *gin.Context) {
+	var req AdminLoginRiskUnblockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+	ip := strings.TrimSpace(req.IP)
+	if net.ParseIP(ip) == nil {
+		Error(c, ErrorInvalidArgument, "invalid ip")
+		return
+	}
+
+	if err := unblockLoginRiskIP(c, ip); err != nil {
+		log.Errorf(c, "failed to unblock login risk ip %s: %v", ip, err)
+		Error(c, ErrorSystemError, "failed to unblock")
+		return
+	}
+
+	log.Infof(c, "admin unblocked login risk ip %s", ip)
+	SuccessEmpty(c)
+	WriteAuditLog(c, "login_risk_unblock", "ip", ip, nil)
+}
@@ -0,0 +1,48 @@
+package center
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// RuleMissTopResponse 规则未命中域名排行（供策略规则作者补规则）
+type RuleMissTopResponse struct {
+	Range          string              `json:"range"`
+	Since          string              `json:"since"`          // 起始 salt_day（含）
+	RevealK        int                 `json:"revealK"`        // 明文揭示所需的最少上报方数
+	Domains        []RuleMissTopDomain `json:"domains"`        // 已揭示域名，按上报次数降序
+	UnrevealedHits int64               `json:"unrevealedHits"` // 未达 k 的哈希的上报次数合计
+}
+
+// api_admin_rule_miss_top 查询规则未命中的热门域名
+// range: 7d（默认）| 30d，不超过聚合表保留期；limit: 1-500，默认 100
+func api_admin_rule_miss_top(c *gin.Context) {
+	rangeParam := c.DefaultQuery("range", "7d")
+	days, err := parseRangeDays(rangeParam)
+	if err != nil || days > ruleMissAggregateRetentionDays {
+		Error(c, ErrorInvalidArgument, "bad range")
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days).Format("2006-01-02")
+	domains, hidden, err := queryRuleMissTopDomains(since, limit)
+	if err != nil {
+		log.Errorf(c, "rule-miss top domains: %v", err)
+		Error(c, ErrorSystemError, "query failed")
+		return
+	}
+	Success(c, &RuleMissTopResponse{
+		Range:          rangeParam,
+		Since:          since,
+		RevealK:        ruleMissRevealK,
+		Domains:        domains,
+		UnrevealedHits: hidden,
+	})
+}
@@ -51,6 +51,24 @@ var validRouteQualities = map[string]bool{
 	"unknown":   true,
 }
 
+// Valid network types for validation
+var validNetworkTypes = map[string]bool{
+	"wifi":     true,
+	"cellular": true,
+	"ethernet": true,
+	"unknown":  true,
+}
+
+// Valid congestion controls for validation ("" = protocol default)
+var validCongestions = map[string]bool{
+	"":       true,
+	"bbr":    true,
+	"brutal": true,
+}
+
+// maxRuleIDLen bounds rule IDs, which clients echo back in telemetry decisions
+const maxRuleIDLen = 64
+
 // versionPattern matches YYYY.MM.DD.N format
 var versionPattern = regexp.MustCompile(`^\d{4}\.\d{2}\.\d{2}\.\d+$`)
 
@@ -102,21 +120,37 @@ type AdminDefaultConfig struct {
 
 // AdminRulesListItem item in rules list
 type AdminRulesListItem struct {
-	ID        uint64 `json:"id"`
-	Version   string `json:"version"`
-	IsActive  bool   `json:"isActive"`
-	CreatedAt int64  `json:"createdAt"`
-	UpdatedAt int64  `json:"updatedAt"`
+	ID             uint64 `json:"id"`
+	Version        string `json:"version"`
+	IsActive       bool   `json:"isActive"`
+	RolloutPercent int    `json:"rolloutPercent"`
+	CreatedAt      int64  `json:"createdAt"`
+	UpdatedAt      int64  `json:"updatedAt"`
 }
 
 // AdminRulesDetailResponse detailed rules response
 type AdminRulesDetailResponse struct {
-	ID        uint64                   `json:"id"`
-	Version   string                   `json:"version"`
-	IsActive  bool                     `json:"isActive"`
-	Content   AdminStrategyRulesConfig `json:"content"`
-	CreatedAt int64                    `json:"createdAt"`
-	UpdatedAt int64                    `json:"updatedAt"`
+	ID             uint64                   `json:"id"`
+	Version        string                   `json:"version"`
+	IsActive       bool                     `json:"isActive"`
+	RolloutPercent int                      `json:"rolloutPercent"`
+	Content        AdminStrategyRulesConfig `json:"content"`
+	CreatedAt      int64                    `json:"createdAt"`
+	UpdatedAt      int64                    `json:"updatedAt"`
+}
+
+// AdminRolloutRequest sets the staged rollout percentage of a candidate version
+type AdminRolloutRequest struct {
+	Percent *int `json:"percent" binding:"required,min=0,max=100"` // 0 = stop rollout
+}
+
+// AdminRulesCompareResponse candidate vs active outcome comparison
+type AdminRulesCompareResponse struct {
+	Candidate      string                 `json:"candidate"`
+	Active         string                 `json:"active"` // empty if no active version
+	RolloutPercent int                    `json:"rolloutPercent"`
+	Range          string                 `json:"range"`
+	Groups         []StrategyOutcomeStats `json:"groups"` // per (version, cohort)
 }
 
 // ========================= Validation Functions =========================
@@ -131,10 +165,38 @@ func validateVersion(version string) error {
 
 // validateProtocolChain validates protocol names
 func validateProtocolChain(chain []string) error {
+	seen := make(map[string]bool, len(chain))
 	for _, p := range chain {
 		if !validProtocols[p] {
 			return fmt.Errorf("invalid protocol: %s (valid: k2:quic_bbr, k2:quic_brutal, k2:tcp_ws)", p)
 		}
+		if seen[p] {
+			return fmt.Errorf("duplicate protocol: %s", p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+// validateNetworkTypes validates network type values
+func validateNetworkTypes(types []string) error {
+	for _, t := range types {
+		if !validNetworkTypes[t] {
+			return fmt.Errorf("invalid network_type: %s (valid: wifi, cellular, ethernet, unknown)", t)
+		}
+	}
+	return nil
+}
+
+// validateProtocolParams validates the per-protocol parameter blocks
+func validateProtocolParams(protocols map[string]any) error {
+	for name, params := range protocols {
+		if !validProtocols[name] {
+			return fmt.Errorf("invalid protocol: %s (valid: k2:quic_bbr, k2:quic_brutal, k2:tcp_ws)", name)
+		}
+		if _, ok := params.(map[string]any); !ok {
+			return fmt.Errorf("%s: parameters must be an object", name)
+		}
 	}
 	return nil
 }
@@ -165,16 +227,29 @@ func validateRulesConfig(config *AdminStrategyRulesConfig) error {
 	if err := validateProtocolChain(config.Default.ProtocolChain); err != nil {
 		return fmt.Errorf("default.protocol_chain: %w", err)
 	}
+	if err := validateProtocolParams(config.Protocols); err != nil {
+		return fmt.Errorf("protocols: %w", err)
+	}
 
 	// Validate each rule
 	ruleIDs := make(map[string]bool)
+	priorities := make(map[int]string)
 	for i, rule := range config.Rules {
 		// Check unique rule ID
+		if len(rule.ID) > maxRuleIDLen {
+			return fmt.Errorf("rules[%d]: id longer than %d characters", i, maxRuleIDLen)
+		}
 		if ruleIDs[rule.ID] {
 			return fmt.Errorf("rules[%d]: duplicate rule id '%s'", i, rule.ID)
 		}
 		ruleIDs[rule.ID] = true
 
+		// Equal priorities make evaluation order depend on array order
+		if other, ok := priorities[rule.Priority]; ok {
+			return fmt.Errorf("rules[%d]: priority %d already used by rule '%s'", i, rule.Priority, other)
+		}
+		priorities[rule.Priority] = rule.ID
+
 		// Validate match conditions
 		if len(rule.Match.Carrier) > 0 {
 			if err := validateCarriers(rule.Match.Carrier); err != nil {
@@ -186,11 +261,22 @@ func validateRulesConfig(config *AdminStrategyRulesConfig) error {
 				return fmt.Errorf("rules[%d].match.route_quality: %w", i, err)
 			}
 		}
+		if len(rule.Match.NetworkType) > 0 {
+			if err := validateNetworkTypes(rule.Match.NetworkType); err != nil {
+				return fmt.Errorf("rules[%d].match.network_type: %w", i, err)
+			}
+		}
+		if r := rule.Match.HistoryFailureRateGt; r != nil && (*r < 0 || *r >= 1) {
+			return fmt.Errorf("rules[%d].match.history_failure_rate_gt must be in [0, 1)", i)
+		}
 
 		// Validate action
 		if err := validateProtocolChain(rule.Action.ProtocolChain); err != nil {
 			return fmt.Errorf("rules[%d].action.protocol_chain: %w", i, err)
 		}
+		if !validCongestions[rule.Action.Congestion] {
+			return fmt.Errorf("rules[%d].action.congestion: invalid value %s (valid: bbr, brutal)", i, rule.Action.Congestion)
+		}
 		if rule.Action.TimeoutMs != 0 && (rule.Action.TimeoutMs < 1000 || rule.Action.TimeoutMs > 30000) {
 			return fmt.Errorf("rules[%d].action.timeout_ms must be between 1000 and 30000", i)
 		}
@@ -218,11 +304,12 @@ func api_admin_strategy_list(c *gin.Context) {
 	items := make([]AdminRulesListItem, len(rules))
 	for i, r := range rules {
 		items[i] = AdminRulesListItem{
-			ID:        r.ID,
-			Version:   r.Version,
-			IsActive:  r.IsActive != nil && *r.IsActive,
-			CreatedAt: r.CreatedAt.Unix(),
-			UpdatedAt: r.UpdatedAt.Unix(),
+			ID:             r.ID,
+			Version:        r.Version,
+			IsActive:       r.IsActive != nil && *r.IsActive,
+			RolloutPercent: r.RolloutPercent,
+			CreatedAt:      r.CreatedAt.Unix(),
+			UpdatedAt:      r.UpdatedAt.Unix(),
 		}
 	}
 
@@ -259,12 +346,13 @@ func api_admin_strategy_get(c *gin.Context) {
 	}
 
 	response := AdminRulesDetailResponse{
-		ID:        rules.ID,
-		Version:   rules.Version,
-		IsActive:  rules.IsActive != nil && *rules.IsActive,
-		Content:   content,
-		CreatedAt: rules.CreatedAt.Unix(),
-		UpdatedAt: rules.UpdatedAt.Unix(),
+		ID:             rules.ID,
+		Version:        rules.Version,
+		IsActive:       rules.IsActive != nil && *rules.IsActive,
+		RolloutPercent: rules.RolloutPercent,
+		Content:        content,
+		CreatedAt:      rules.CreatedAt.Unix(),
+		UpdatedAt:      rules.UpdatedAt.Unix(),
 	}
 
 	log.Infof(c, "returning rules version %s", version)
@@ -374,9 +462,11 @@ func api_admin_strategy_activate(c *gin.Context) {
 			return err
 		}
 
-		// Activate target version
-		isActiveTrue := true
-		if err := tx.Model(&target).Update("is_active", &isActiveTrue).Error; err != nil {
+		// Activate target version; a promoted candidate now serves 100%
+		if err := tx.Model(&target).Updates(map[string]any{
+			"is_active":       true,
+			"rollout_percent": 0,
+		}).Error; err != nil {
 			return err
 		}
 
@@ -428,6 +518,24 @@ func api_admin_strategy_delete(c *gin.Context) {
 		return
 	}
 
+	// Cannot delete a version still serving devices
+	if target.RolloutPercent > 0 {
+		log.Warnf(c, "cannot delete rules version under rollout: %s", version)
+		Error(c, ErrorInvalidArgument, "stop the rollout before deleting this version")
+		return
+	}
+	var pinned int64
+	if err := db.Get().Model(&StrategyCohort{}).Where(&StrategyCohort{Version: version}).Count(&pinned).Error; err != nil {
+		log.Errorf(c, "failed to check cohorts: %v", err)
+		Error(c, ErrorSystemError, "failed to delete rules")
+		return
+	}
+	if pinned > 0 {
+		log.Warnf(c, "cannot delete rules version pinned by %d cohorts: %s", pinned, version)
+		Error(c, ErrorInvalidArgument, "rules version is pinned by a cohort")
+		return
+	}
+
 	// Soft delete
 	if err := db.Get().Delete(&target).Error; err != nil {
 		log.Errorf(c, "failed to delete rules: %v", err)
@@ -445,3 +553,125 @@ func api_admin_strategy_delete(c *gin.Context) {
 	}
 	Success(c, &result)
 }
+
+// api_admin_strategy_rollout sets the staged rollout percentage of a non-active
+// version. Only one candidate rolls out at a time: starting one stops any other.
+//
+// PUT /app/strategy/rules/:version/rollout
+func api_admin_strategy_rollout(c *gin.Context) {
+	version := c.Param("version")
+	var req AdminRolloutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warnf(c, "invalid request: %v", err)
+		Error(c, ErrorInvalidArgument, "percent must be between 0 and 100")
+		return
+	}
+	percent := *req.Percent
+
+	var target StrategyRules
+	err := db.Get().Where(&StrategyRules{Version: version}).First(&target).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			Error(c, ErrorNotFound, "rules version not found")
+			return
+		}
+		log.Errorf(c, "failed to find rules: %v", err)
+		Error(c, ErrorSystemError, "failed to find rules")
+		return
+	}
+	if target.IsActive != nil && *target.IsActive {
+		Error(c, ErrorInvalidArgument, "active version already serves all devices")
+		return
+	}
+
+	previous := target.RolloutPercent
+	err = db.Get().Transaction(func(tx *gorm.DB) error {
+		if percent > 0 {
+			if err := tx.Model(&StrategyRules{}).
+				Where("rollout_percent > 0 AND id <> ?", target.ID).
+				Update("rollout_percent", 0).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&target).Update("rollout_percent", percent).Error
+	})
+	if err != nil {
+		log.Errorf(c, "failed to set rollout: %v", err)
+		Error(c, ErrorSystemError, "failed to set rollout")
+		return
+	}
+
+	WriteAuditLog(c, "strategy_rollout", "strategy_rules", version, map[string]any{
+		"from": previous,
+		"to":   percent,
+	})
+	log.Infof(c, "rules version %s rollout %d%% -> %d%%", version, previous, percent)
+	result := struct {
+		Version        string `json:"version"`
+		RolloutPercent int    `json:"rolloutPercent"`
+	}{
+		Version:        version,
+		RolloutPercent: percent,
+	}
+	Success(c, &result)
+}
+
+// api_admin_strategy_compare compares telemetry outcomes of a version against
+// the active one, split by cohort (rollout / control / default / named cohorts)
+//
+// GET /app/strategy/rules/:version/compare?range=7d
+func api_admin_strategy_compare(c *gin.Context) {
+	version := c.Param("version")
+	rangeParam := c.DefaultQuery("range", "7d")
+	days, err := parseRangeDays(rangeParam)
+	if err != nil || days > telemetryEventRetentionDays {
+		// 对比读原始事件，不能超过明细保留期
+		Error(c, ErrorInvalidArgument, "bad range")
+		return
+	}
+
+	var target StrategyRules
+	if err := db.Get().Where(&StrategyRules{Version: version}).First(&target).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			Error(c, ErrorNotFound, "rules version not found")
+			return
+		}
+		log.Errorf(c, "failed to find rules: %v", err)
+		Error(c, ErrorSystemError, "failed to find rules")
+		return
+	}
+
+	versions := []string{target.Version}
+	activeVersion := ""
+	var active StrategyRules
+	err = db.Get().Where(&StrategyRules{IsActive: BoolPtr(true)}).First(&active).Error
+	switch {
+	case err == nil:
+		activeVersion = active.Version
+		if activeVersion != target.Version {
+			versions = append(versions, activeVersion)
+		}
+	case err != gorm.ErrRecordNotFound:
+		log.Errorf(c, "failed to find active rules: %v", err)
+		Error(c, ErrorSystemError, "failed to find rules")
+		return
+	default:
+		// No active version: devices outside the rollout get the built-in default
+		versions = append(versions, "default")
+	}
+
+	since := time.Now().AddDate(0, 0, -days).UnixMilli()
+	groups, err := queryStrategyOutcomes(versions, since)
+	if err != nil {
+		log.Errorf(c, "failed to compare rules outcomes: %v", err)
+		Error(c, ErrorSystemError, "query failed")
+		return
+	}
+	Success(c, &AdminRulesCompareResponse{
+		Candidate:      target.Version,
+		Active:         activeVersion,
+		RolloutPercent: target.RolloutPercent,
+		Range:          rangeParam,
+		Groups:         groups,
+	})
+}
@@ -0,0 +1,262 @@
+package center
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cohortNamePattern 分组名：小写字母数字开头，可含 - _，会作为遥测 cohort 标签
+var cohortNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// AdminCreateCohortRequest 创建 A/B 分组
+type AdminCreateCohortRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Version string `json:"version" binding:"required"` // 分组固定下发的规则版本
+	Note    string `json:"note" binding:"max=255"`
+}
+
+// AdminCohortItem 分组列表项
+type AdminCohortItem struct {
+	ID        uint64 `json:"id"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Note      string `json:"note"`
+	Devices   int64  `json:"devices"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// AdminCohortDevicesRequest 批量加入分组（设备已在其他分组时会被移过来）
+type AdminCohortDevicesRequest struct {
+	UDIDs []string `json:"udids" binding:"required,min=1,max=500"`
+}
+
+// AdminCohortDevicesResponse 批量加入结果
+type AdminCohortDevicesResponse struct {
+	Added    int      `json:"added"`
+	NotFound []string `json:"notFound"`
+}
+
+// api_admin_strategy_cohort_list 列出所有 A/B 分组及设备数
+//
+// GET /app/strategy/cohorts
+func api_admin_strategy_cohort_list(c *gin.Context) {
+	var cohorts []StrategyCohort
+	if err := db.Get().Order("created_at DESC").Find(&cohorts).Error; err != nil {
+		log.Errorf(c, "failed to list cohorts: %v", err)
+		Error(c, ErrorSystemError, "failed to list cohorts")
+		return
+	}
+
+	var counts []struct {
+		CohortID uint64
+		Devices  int64
+	}
+	if err := db.Get().Model(&StrategyCohortDevice{}).
+		Select("cohort_id, COUNT(*) AS devices").
+		Group("cohort_id").
+		Scan(&counts).Error; err != nil {
+		log.Errorf(c, "failed to count cohort devices: %v", err)
+		Error(c, ErrorSystemError, "failed to list cohorts")
+		return
+	}
+	devices := make(map[uint64]int64, len(counts))
+	for _, row := range counts {
+		devices[row.CohortID] = row.Devices
+	}
+
+	items := make([]AdminCohortItem, len(cohorts))
+	for i, co := range cohorts {
+		items[i] = AdminCohortItem{
+			ID:        co.ID,
+			Name:      co.Name,
+			Version:   co.Version,
+			Note:      co.Note,
+			Devices:   devices[co.ID],
+			CreatedAt: co.CreatedAt.Unix(),
+		}
+	}
+	ItemsAll(c, items)
+}
+
+// api_admin_strategy_cohort_create 创建固定到某规则版本的分组
+//
+// POST /app/strategy/cohorts
+func api_admin_strategy_cohort_create(c *gin.Context) {
+	var req AdminCreateCohortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warnf(c, "invalid request: %v", err)
+		Error(c, ErrorInvalidArgument, "invalid request format")
+		return
+	}
+	if !cohortNamePattern.MatchString(req.Name) || reservedStrategyCohorts[req.Name] {
+		Error(c, ErrorInvalidArgument, "invalid cohort name")
+		return
+	}
+
+	var rules StrategyRules
+	if err := db.Get().Where(&StrategyRules{Version: req.Version}).First(&rules).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			Error(c, ErrorNotFound, "rules version not found")
+			return
+		}
+		log.Errorf(c, "failed to find rules: %v", err)
+		Error(c, ErrorSystemError, "failed to find rules")
+		return
+	}
+
+	var existing int64
+	if err := db.Get().Model(&StrategyCohort{}).Where(&StrategyCohort{Name: req.Name}).Count(&existing).Error; err != nil {
+		log.Errorf(c, "failed to check cohort: %v", err)
+		Error(c, ErrorSystemError, "failed to create cohort")
+		return
+	}
+	if existing > 0 {
+		Error(c, ErrorConflict, "cohort already exists")
+		return
+	}
+
+	cohort := StrategyCohort{Name: req.Name, Version: req.Version, Note: req.Note}
+	if err := db.Get().Create(&cohort).Error; err != nil {
+		log.Errorf(c, "failed to create cohort: %v", err)
+		Error(c, ErrorSystemError, "failed to create cohort")
+		return
+	}
+
+	WriteAuditLog(c, "strategy_cohort_create", "strategy_cohort", cohort.Name, map[string]any{
+		"version": cohort.Version,
+	})
+	Success(c, &AdminCohortItem{
+		ID:        cohort.ID,
+		Name:      cohort.Name,
+		Version:   cohort.Version,
+		Note:      cohort.Note,
+		CreatedAt: cohort.CreatedAt.Unix(),
+	})
+}
+
+// api_admin_strategy_cohort_delete 删除分组，组内设备回到灰度/激活版本
+//
+// DELETE /app/strategy/cohorts/:id
+func api_admin_strategy_cohort_delete(c *gin.Context) {
+	cohort, ok := loadAdminCohort(c)
+	if !ok {
+		return
+	}
+
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&StrategyCohortDevice{CohortID: cohort.ID}).Delete(&StrategyCohortDevice{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(cohort).Error
+	})
+	if err != nil {
+		log.Errorf(c, "failed to delete cohort: %v", err)
+		Error(c, ErrorSystemError, "failed to delete cohort")
+		return
+	}
+
+	WriteAuditLog(c, "strategy_cohort_delete", "strategy_cohort", cohort.Name, nil)
+	SuccessEmpty(c)
+}
+
+// api_admin_strategy_cohort_add_devices 按 UDID 批量把设备加入分组
+//
+// POST /app/strategy/cohorts/:id/devices
+func api_admin_strategy_cohort_add_devices(c *gin.Context) {
+	cohort, ok := loadAdminCohort(c)
+	if !ok {
+		return
+	}
+	var req AdminCohortDevicesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warnf(c, "invalid request: %v", err)
+		Error(c, ErrorInvalidArgument, "udids must contain 1-500 entries")
+		return
+	}
+
+	var devices []Device
+	if err := db.Get().Where("udid IN ?", req.UDIDs).Find(&devices).Error; err != nil {
+		log.Errorf(c, "failed to find devices: %v", err)
+		Error(c, ErrorSystemError, "failed to find devices")
+		return
+	}
+	found := make(map[string]bool, len(devices))
+	members := make([]StrategyCohortDevice, 0, len(devices))
+	for _, d := range devices {
+		found[d.UDID] = true
+		members = append(members, StrategyCohortDevice{CohortID: cohort.ID, DeviceID: d.ID})
+	}
+	notFound := []string{}
+	for _, udid := range req.UDIDs {
+		if !found[udid] {
+			notFound = append(notFound, udid)
+		}
+	}
+
+	if len(members) > 0 {
+		// 一台设备只属于一个分组：已在其他分组的直接移过来
+		if err := db.Get().Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "device_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"cohort_id"}),
+		}).Create(&members).Error; err != nil {
+			log.Errorf(c, "failed to add cohort devices: %v", err)
+			Error(c, ErrorSystemError, "failed to add devices")
+			return
+		}
+	}
+
+	WriteAuditLog(c, "strategy_cohort_add_devices", "strategy_cohort", cohort.Name, map[string]any{
+		"added":    len(members),
+		"notFound": len(notFound),
+	})
+	Success(c, &AdminCohortDevicesResponse{Added: len(members), NotFound: notFound})
+}
+
+// api_admin_strategy_cohort_remove_device 把设备移出分组
+//
+// DELETE /app/strategy/cohorts/:id/devices/:udid
+func api_admin_strategy_cohort_remove_device(c *gin.Context) {
+	cohort, ok := loadAdminCohort(c)
+	if !ok {
+		return
+	}
+	var device Device
+	if err := db.Get().Where(&Device{UDID: c.Param("udid")}).First(&device).Error; err != nil {
+		Error(c, ErrorNotFound, "device not found")
+		return
+	}
+	if err := db.Get().
+		Where(&StrategyCohortDevice{CohortID: cohort.ID, DeviceID: device.ID}).
+		Delete(&StrategyCohortDevice{}).Error; err != nil {
+		log.Errorf(c, "failed to remove cohort device: %v", err)
+		Error(c, ErrorSystemError, "failed to remove device")
+		return
+	}
+	SuccessEmpty(c)
+}
+
+// loadAdminCohort 解析 :id 并加载分组，失败时已写响应
+func loadAdminCohort(c *gin.Context) (*StrategyCohort, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		Error(c, ErrorInvalidArgument, "invalid id")
+		return nil, false
+	}
+	var cohort StrategyCohort
+	if err := db.Get().First(&cohort, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			Error(c, ErrorNotFound, "cohort not found")
+			return nil, false
+		}
+		log.Errorf(c, "failed to find cohort: %v", err)
+		Error(c, ErrorSystemError, "failed to find cohort")
+		return nil, false
+	}
+	return &cohort, true
+}
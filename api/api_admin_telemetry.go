@@ -0,0 +1,180 @@
+package center
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// TelemetryDayStats 某 UTC 日的全量连接统计
+type TelemetryDayStats struct {
+	Day string `json:"day"`
+	TelemetryConnectStats
+}
+
+// TelemetryOverviewResponse 连接质量总览：日序列 + 区间合计 + 失败原因排行
+type TelemetryOverviewResponse struct {
+	Range       string                `json:"range"`
+	Since       string                `json:"since"` // 起始 UTC 日（含）
+	Totals      TelemetryConnectStats `json:"totals"`
+	Days        []TelemetryDayStats   `json:"days"`
+	TopFailures []TelemetryFailureRow `json:"topFailures"`
+}
+
+// TelemetryBreakdownRow 某维度取值的连接统计
+type TelemetryBreakdownRow struct {
+	Value string `json:"value"` // 空 = 客户端未上报该维度
+	TelemetryConnectStats
+}
+
+// TelemetryBreakdownResponse 按维度切片的连接统计
+type TelemetryBreakdownResponse struct {
+	Range     string                  `json:"range"`
+	Since     string                  `json:"since"`
+	Dimension string                  `json:"dimension"`
+	Rows      []TelemetryBreakdownRow `json:"rows"` // 按尝试次数降序
+}
+
+// TelemetryFailuresResponse 失败原因排行
+type TelemetryFailuresResponse struct {
+	Range     string                `json:"range"`
+	Since     string                `json:"since"`
+	Dimension string                `json:"dimension"`
+	Value     *string               `json:"value,omitempty"`
+	Reasons   []TelemetryFailureRow `json:"reasons"`
+}
+
+// parseTelemetryRange 解析 range（7d 默认 | 30d | 90d），返回起始 UTC 日
+func parseTelemetryRange(c *gin.Context) (string, string, bool) {
+	rangeParam := c.DefaultQuery("range", "7d")
+	days, err := parseRangeDays(rangeParam)
+	if err != nil {
+		Error(c, ErrorInvalidArgument, "bad range")
+		return "", "", false
+	}
+	return rangeParam, time.Now().UTC().AddDate(0, 0, -days).Format("2006-01-02"), true
+}
+
+// api_admin_telemetry_overview 连接成功率 / 建连耗时日序列与失败原因
+//
+// GET /app/telemetry/overview?range=7d
+func api_admin_telemetry_overview(c *gin.Context) {
+	rangeParam, since, ok := parseTelemetryRange(c)
+	if !ok {
+		return
+	}
+
+	rows, err := queryTelemetryStats(telemetryDimensionAll, since, "day", 0)
+	if err != nil {
+		log.Errorf(c, "telemetry overview: %v", err)
+		Error(c, ErrorSystemError, "query failed")
+		return
+	}
+	var total telemetryStatsRow
+	days := make([]TelemetryDayStats, len(rows))
+	for i := range rows {
+		days[i] = TelemetryDayStats{Day: rows[i].Bucket, TelemetryConnectStats: rows[i].stats()}
+		total.Attempts += rows[i].Attempts
+		total.Successes += rows[i].Successes
+		total.Failures += rows[i].Failures
+		total.Devices += rows[i].Devices
+		total.ConnectMsSum += rows[i].ConnectMsSum
+		total.ConnectMsCount += rows[i].ConnectMsCount
+		total.ConnectUnder1s += rows[i].ConnectUnder1s
+		total.ConnectUnder3s += rows[i].ConnectUnder3s
+		total.ConnectOver3s += rows[i].ConnectOver3s
+	}
+
+	failures, err := queryTelemetryFailures(telemetryDimensionAll, nil, since, 10)
+	if err != nil {
+		log.Errorf(c, "telemetry overview failures: %v", err)
+		Error(c, ErrorSystemError, "query failed")
+		return
+	}
+
+	Success(c, &TelemetryOverviewResponse{
+		Range:       rangeParam,
+		Since:       since,
+		Totals:      total.stats(),
+		Days:        days,
+		TopFailures: failures,
+	})
+}
+
+// api_admin_telemetry_breakdown 按维度切片的连接统计
+// dimension: protocol_chain | country | carrier | asn | app_version | node | rules_version
+// limit: 1-500，默认 50
+//
+// GET /app/telemetry/breakdown?dimension=protocol_chain&range=7d
+func api_admin_telemetry_breakdown(c *gin.Context) {
+	dimension := c.Query("dimension")
+	if _, ok := telemetryDimensionColumns[dimension]; !ok || dimension == telemetryDimensionAll {
+		Error(c, ErrorInvalidArgument, "bad dimension")
+		return
+	}
+	rangeParam, since, ok := parseTelemetryRange(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	rows, err := queryTelemetryStats(dimension, since, "value", limit)
+	if err != nil {
+		log.Errorf(c, "telemetry breakdown %s: %v", dimension, err)
+		Error(c, ErrorSystemError, "query failed")
+		return
+	}
+	out := make([]TelemetryBreakdownRow, len(rows))
+	for i := range rows {
+		out[i] = TelemetryBreakdownRow{Value: rows[i].Bucket, TelemetryConnectStats: rows[i].stats()}
+	}
+	Success(c, &TelemetryBreakdownResponse{
+		Range:     rangeParam,
+		Since:     since,
+		Dimension: dimension,
+		Rows:      out,
+	})
+}
+
+// api_admin_telemetry_failures 失败原因排行，可限定到某维度取值
+// dimension 缺省为 all；带 dimension 时 value 可选（不带 = 该维度全部取值合计）
+//
+// GET /app/telemetry/failures?dimension=node&value=jp1.example.com&range=7d
+func api_admin_telemetry_failures(c *gin.Context) {
+	dimension := c.DefaultQuery("dimension", telemetryDimensionAll)
+	if _, ok := telemetryDimensionColumns[dimension]; !ok {
+		Error(c, ErrorInvalidArgument, "bad dimension")
+		return
+	}
+	rangeParam, since, ok := parseTelemetryRange(c)
+	if !ok {
+		return
+	}
+	var value *string
+	if v, ok := c.GetQuery("value"); ok && dimension != telemetryDimensionAll {
+		value = &v
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 200 {
+		limit = 20
+	}
+
+	reasons, err := queryTelemetryFailures(dimension, value, since, limit)
+	if err != nil {
+		log.Errorf(c, "telemetry failures %s: %v", dimension, err)
+		Error(c, ErrorSystemError, "query failed")
+		return
+	}
+	Success(c, &TelemetryFailuresResponse{
+		Range:     rangeParam,
+		Since:     since,
+		Dimension: dimension,
+		Value:     value,
+		Reasons:   reasons,
+	})
+}
@@ -125,9 +125,9 @@ func handleAntiblockSeed(c *gin.Context) {
 		if tunnel.Node.Class != NodeClassShared {
 			continue
 		}
-		// Hide over-quota or offline nodes (same gate as /api/tunnels).
+		// Hide over-quota, offline or draining nodes (same gate as /api/tunnels).
 		u := usageMap[tunnel.Node.Ipv4]
-		if shouldHideTunnelForUser(u, false, now) {
+		if shouldHideNodeForUser(tunnel.Node, u, false, now) {
 			continue
 		}
 		// Parse the k2v5 descriptor; skip entries with missing ip/pin/ech.
@@ -42,6 +42,7 @@ type DataAppConfig struct {
 	MinClientVersion string             `json:"minClientVersion,omitempty"` // 最低客户端版本要求，低于此版本强制升级
 	Announcement     *DataAnnouncement  `json:"announcement,omitempty"`     // 向后兼容：最高优先级公告
 	Announcements    []DataAnnouncement `json:"announcements,omitempty"`    // 全部活跃公告（按 priority DESC 排序）
+	CaptchaSiteKey   string             `json:"captchaSiteKey,omitempty"`   // Turnstile site key；登录风控返回 ErrorCaptchaRequired 时用它渲染人机验证
 }
 
 // api_get_app_config 获取应用配置
@@ -120,6 +121,9 @@ func api_get_app_config(c *gin.Context) {
 		Announcement:     singleAnnouncement,
 		Announcements:    announcements,
 	}
+	if captcha := configCaptcha(b); captcha.Enabled() {
+		data.CaptchaSiteKey = captcha.SiteKey
+	}
 
 	log.Infof(c, "successfully retrieved app config: %+v", data)
 	Success(c, &data)
@@ -2,6 +2,7 @@
 package center
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/mail"
@@ -22,6 +23,8 @@ import (
 type SendAuthCodeRequest struct {
 	Email    string `json:"email" binding:"required,email" example:"user@example.com"` // 邮箱地址
 	Language string `json:"language" example:"en-US"`                                  // 用户语言偏好（注册时使用）
+	// 登录风控要求人机验证（ErrorCaptchaRequired）后带上的 Turnstile token
+	CaptchaToken string `json:"captchaToken"`
 }
 
 // api_send_auth_code 发送验证码（统一处理登录/注册）
@@ -98,6 +101,16 @@ func sendCodeWithMode(c *gin.Context, userExistRequired bool) {
 		user = identify.User
 	}
 
+	risk, ok := enforceLoginRisk(c, loginRiskAttempt{
+		Action:       loginRiskActionSendCode,
+		EmailHash:    indexID,
+		User:         user,
+		CaptchaToken: req.CaptchaToken,
+	})
+	if !ok {
+		return
+	}
+
 	if userExists && isUserBlocked(user) {
 		log.Warnf(c, "send-code rejected: user %d is blocked", user.ID)
 		Error(c, ErrorForbidden, "account blocked")
@@ -170,6 +183,7 @@ func sendCodeWithMode(c *gin.Context, userExistRequired bool) {
 	}
 
 	log.Infof(c, "successfully sent auth code to email: %s, userExists: %v", hideEmail(req.Email), userExists)
+	recordLoginSuccess(c, risk)
 
 	// 返回用户存在状态、激活状态和首单状态
 	response := &SendCodeResponse{
@@ -214,6 +228,16 @@ func api_login(c *gin.Context) {
 
 	indexID := secretHashIt(c, []byte(req.Email))
 
+	risk, ok := enforceLoginRisk(c, loginRiskAttempt{
+		Action:    loginRiskActionCodeLogin,
+		EmailHash: indexID,
+		User:      loginRiskUser(c, indexID),
+		UDID:      req.UDID,
+	})
+	if !ok {
+		return
+	}
+
 	switch verifyEmailCode(c, indexID, req.VerificationCode) {
 	case VerifyCodeOK:
 		// fall through to login flow
@@ -223,19 +247,13 @@ func api_login(c *gin.Context) {
 		return
 	case VerifyCodeWrong:
 		log.Warnf(c, "invalid verification code for email: %s", req.Email)
+		recordLoginFailure(c, risk)
 		Error(c, ErrorInvalidVerificationCode, "invalid verification code")
 		return
 	}
 
-	// 缩短 TTL 到宽限期（非立即删除），让双击 / 网络抖动重试在宽限期内幂等成功
-	if err := markVerificationCodeUsed(c, indexID); err != nil {
-		log.Errorf(c, "failed to mark verification code used for email %s: %v", req.Email, err)
-		Error(c, ErrorSystemError, "failed to mark verification code used")
-		return
-	}
-
 	var identify LoginIdentify
-	if err := db.Get().Where("type = ? AND index_id = ? AND brand = ?", "email", indexID, string(ReqBrand(c))).First(&identify).Error; err != nil {
+	if err := db.Get().Preload("User").Where("type = ? AND index_id = ? AND brand = ?", "email", indexID, string(ReqBrand(c))).First(&identify).Error; err != nil {
 		if util.DbIsNotFoundErr(err) {
 			log.Warnf(c, "user not found during login for email (hashed): %s", indexID)
 			Error(c, ErrorNotFound, "user not found")
@@ -247,6 +265,22 @@ func api_login(c *gin.Context) {
 		}
 	}
 
+	// 两步验证在标记验证码已用之前校验：App 登录签发的 Bearer token 同样能访问
+	// /app/*，不能只凭邮箱验证码绕过管理员的两步验证
+	if identify.User != nil {
+		if err := verifySecondFactor(c, identify.User, req.TOTPCode); err != nil {
+			ErrorE(c, err)
+			return
+		}
+	}
+
+	// 缩短 TTL 到宽限期（非立即删除），让双击 / 网络抖动重试在宽限期内幂等成功
+	if err := markVerificationCodeUsed(c, indexID); err != nil {
+		log.Errorf(c, "failed to mark verification code used for email %s: %v", req.Email, err)
+		Error(c, ErrorSystemError, "failed to mark verification code used")
+		return
+	}
+
 	var device Device
 	var authResult *DataAuthResult
 	var err error
@@ -368,7 +402,7 @@ func api_login(c *gin.Context) {
 		}
 
 		var tokenIssueTime time.Time
-		authResult, tokenIssueTime, err = generateTokens(c, identify.UserID, req.UDID, user.Roles)
+		authResult, tokenIssueTime, err = issueDeviceSession(c, tx, identify.UserID, req.UDID, user.Roles)
 		if err != nil {
 			log.Errorf(c, "failed to generate tokens during login for user %d: %v", identify.UserID, err)
 			return err
@@ -406,6 +440,7 @@ func api_login(c *gin.Context) {
 	}
 
 	log.Infof(c, "user %d logged in successfully with device %s", identify.UserID, device.UDID)
+	recordLoginSuccess(c, risk)
 	Success(c, authResult)
 }
 
@@ -439,6 +474,10 @@ func api_refresh_token(c *gin.Context) {
 	claims, device, err := validateToken(c, refreshToken, TokenTypeRefresh)
 	if err != nil {
 		log.Warnf(c, "failed to validate refresh token: %v", err)
+		if errors.Is(err, ErrRefreshTokenReused) {
+			handleRefreshReuse(c, claims)
+			err = ErrInvalidToken
+		}
 		ErrorE(c, err)
 		return
 	}
@@ -454,8 +493,28 @@ func api_refresh_token(c *gin.Context) {
 		Error(c, ErrorForbidden, "account blocked")
 		return
 	}
+	if _, ok := checkAuthSession(c, claims, &user); !ok {
+		ErrorE(c, ErrInvalidToken)
+		return
+	}
 
-	authResult, tokenIssueTime, err := generateTokens(c, claims.UserID, claims.DeviceID, claims.Roles)
+	// 轮换沿用原家族；存量 token（无 sid）在此迁入会话体系
+	sessionID := claims.SessionID
+	if sessionID == "" {
+		session, err := startAuthSession(c, db.Get(), claims.UserID, claims.DeviceID, classStr(device.IsGateway))
+		if err != nil {
+			log.Errorf(c, "failed to start session for user %d, device %s: %v", claims.UserID, claims.DeviceID, err)
+			Error(c, ErrorSystemError, "failed to refresh token")
+			return
+		}
+		sessionID = session.FamilyID
+	}
+
+	issueAt := time.Now()
+	if issueAt.Unix() <= device.TokenIssueAt {
+		issueAt = time.Unix(device.TokenIssueAt+1, 0)
+	}
+	authResult, tokenIssueTime, err := generateTokensAt(c, claims.UserID, claims.DeviceID, claims.Roles, sessionID, issueAt)
 	if err != nil {
 		log.Errorf(c, "failed to generate new access token for user %d: %v", claims.UserID, err)
 		Error(c, ErrorSystemError, "failed to generate new access token")
@@ -487,6 +546,13 @@ func api_logout(c *gin.Context) {
 	// 清除认证 Cookie（Web 端）
 	clearAuthCookies(c)
 
+	// 吊销当前会话：Web token 本身是无状态的，只清 Cookie 并不能让它失效
+	if session := ReqSession(c); session != nil {
+		if _, err := revokeAuthSessions(db.Get(), sessionRevokeLogout, "id = ?", session.ID); err != nil {
+			log.Errorf(c, "failed to revoke session %d on logout for user %d: %v", session.ID, userID, err)
+		}
+	}
+
 	// 如果是设备认证，删除设备记录
 	if udid != "" {
 		if err := db.Get().Where("udid = ? AND user_id = ?", udid, userID).Delete(&Device{}).Error; err != nil {
@@ -521,6 +587,15 @@ func api_web_auth(c *gin.Context) {
 
 	indexID := secretHashIt(c, []byte(req.Email))
 
+	risk, ok := enforceLoginRisk(c, loginRiskAttempt{
+		Action:    loginRiskActionCodeLogin,
+		EmailHash: indexID,
+		User:      loginRiskUser(c, indexID),
+	})
+	if !ok {
+		return
+	}
+
 	switch verifyEmailCode(c, indexID, req.VerificationCode) {
 	case VerifyCodeOK:
 		// fall through
@@ -530,18 +605,13 @@ func api_web_auth(c *gin.Context) {
 		return
 	case VerifyCodeWrong:
 		log.Warnf(c, "invalid verification code for web login email: %s", req.Email)
+		recordLoginFailure(c, risk)
 		Error(c, ErrorInvalidVerificationCode, "invalid verification code")
 		return
 	}
 
-	if err := markVerificationCodeUsed(c, indexID); err != nil {
-		log.Errorf(c, "failed to mark verification code used for email %s: %v", req.Email, err)
-		Error(c, ErrorSystemError, "failed to mark verification code used")
-		return
-	}
-
 	var identify LoginIdentify
-	if err := db.Get().Where(&LoginIdentify{Type: "email", IndexID: indexID, Brand: string(ReqBrand(c))}).First(&identify).Error; err != nil {
+	if err := db.Get().Preload("User").Where(&LoginIdentify{Type: "email", IndexID: indexID, Brand: string(ReqBrand(c))}).First(&identify).Error; err != nil {
 		if util.DbIsNotFoundErr(err) {
 			log.Warnf(c, "user not found during web login for email (hashed): %s", indexID)
 			Error(c, ErrorNotFound, "user not found")
@@ -553,6 +623,21 @@ func api_web_auth(c *gin.Context) {
 		}
 	}
 
+	// 两步验证在标记验证码已用之前校验：返回 ErrorTwoFactorRequired 后，
+	// 前端带上 totpCode 用同一个邮箱验证码重新提交
+	if identify.User != nil {
+		if err := verifySecondFactor(c, identify.User, req.TOTPCode); err != nil {
+			ErrorE(c, err)
+			return
+		}
+	}
+
+	if err := markVerificationCodeUsed(c, indexID); err != nil {
+		log.Errorf(c, "failed to mark verification code used for email %s: %v", req.Email, err)
+		Error(c, ErrorSystemError, "failed to mark verification code used")
+		return
+	}
+
 	var authResult *DataAuthResult
 	var err error
 	var userIsAdmin bool     // 用于响应中返回用户信息
@@ -579,71 +664,7 @@ func api_web_auth(c *gin.Context) {
 		userHasPassword = HasPasswordSet(&user)
 		userBrand = Brand(user.Brand)
 
-		// 追踪是否需要保存用户信息
-		needSave := false
-
-		// 如果提供了语言偏好，更新用户语言设置
-		if req.Language != "" {
-			acceptLanguage := c.GetHeader("Accept-Language")
-			detectedLanguage := detectUserLanguage(c, req.Language, req.Email, acceptLanguage)
-			if detectedLanguage != user.Language {
-				user.Language = detectedLanguage
-				needSave = true
-				log.Infof(c, "will update user %d language to: %s", identify.UserID, detectedLanguage)
-			}
-		}
-
-		// 处理邀请码逻辑（仅未激活用户可以设置邀请码）
-		if req.InviteCode != "" {
-			if user.IsActivated == nil || !*user.IsActivated {
-				log.Infof(c, "user %d is not activated, processing invite code: %s", identify.UserID, req.InviteCode)
-				inviteCodeID := InviteCodeID(req.InviteCode)
-				var inviteCode InviteCode
-				if err := tx.First(&inviteCode, inviteCodeID).Error; err != nil {
-					if util.DbIsNotFoundErr(err) {
-						log.Warnf(c, "invalid invite code %s for user %d", req.InviteCode, identify.UserID)
-						return e(ErrorInvalidInviteCode, "invalid invite code")
-					}
-					log.Errorf(c, "failed to check invite code %s: %v", req.InviteCode, err)
-					return err
-				}
-
-				// 检查自邀请
-				if inviteCode.UserID == identify.UserID {
-					log.Warnf(c, "self-invitation detected for user %d with code %s", identify.UserID, req.InviteCode)
-					return e(ErrorSelfInvitation, "cannot use your own invite code")
-				}
-
-				// 设置邀请码
-				user.InvitedByCodeID = inviteCodeID
-				needSave = true
-				log.Infof(c, "will set invite code %s for user %d", req.InviteCode, identify.UserID)
-
-				// 异步处理邀请奖励
-				go handleInviteDownloadReward(c, identify.UserID)
-			} else {
-				log.Infof(c, "user %d is already activated, ignoring invite code", identify.UserID)
-			}
-		}
-
-		// 如果未激活用户没有提供邀请码，也需要激活账号
-		if user.IsActivated == nil || !*user.IsActivated {
-			user.IsActivated = BoolPtr(true)
-			user.ActivatedAt = time.Now().Unix()
-			needSave = true
-			log.Infof(c, "will activate user %d (web login without invite code) at %d", identify.UserID, user.ActivatedAt)
-		}
-
-		// 保存用户信息
-		if needSave {
-			if err := tx.Save(&user).Error; err != nil {
-				log.Errorf(c, "failed to save user %d: %v", identify.UserID, err)
-				return err
-			}
-			log.Infof(c, "successfully saved user %d updates", identify.UserID)
-		}
-
-		return nil
+		return applyWebLoginProfile(c, tx, &user, req.Email, req.Language, req.InviteCode)
 	})
 
 	if err != nil {
@@ -653,7 +674,7 @@ func api_web_auth(c *gin.Context) {
 	}
 
 	// 生成 Web Cookie 专用 token（2个月有效期，无 refresh token）
-	authResult, _, err = generateWebCookieToken(c, identify.UserID, userRoles)
+	authResult, err = issueWebSession(c, identify.UserID, userRoles)
 	if err != nil {
 		log.Errorf(c, "failed to generate web cookie token for user %d: %v", identify.UserID, err)
 		Error(c, ErrorSystemError, "failed to generate tokens")
@@ -674,6 +695,7 @@ func api_web_auth(c *gin.Context) {
 	}
 
 	log.Infof(c, "user %d successfully logged in via web", identify.UserID)
+	recordLoginSuccess(c, risk)
 
 	// Tokens 通过 HttpOnly Cookie 设置，AccessToken 也在 body 里返回供
 	// fallback 使用（iOS 微信 WKWebView 等不持久化 Set-Cookie 的环境）。
@@ -689,6 +711,111 @@ func api_web_auth(c *gin.Context) {
 	})
 }
 
+// applyWebLoginProfile Web 登录时更新语言偏好、处理邀请码并激活账号（在登录事务内调用）
+func applyWebLoginProfile(c *gin.Context, tx *gorm.DB, user *User, email, language, invite string) error {
+	// 追踪是否需要保存用户信息
+	needSave := false
+
+	// 如果提供了语言偏好，更新用户语言设置
+	if language != "" {
+		acceptLanguage := c.GetHeader("Accept-Language")
+		detectedLanguage := detectUserLanguage(c, language, email, acceptLanguage)
+		if detectedLanguage != user.Language {
+			user.Language = detectedLanguage
+			needSave = true
+			log.Infof(c, "will update user %d language to: %s", user.ID, detectedLanguage)
+		}
+	}
+
+	// 处理邀请码逻辑（仅未激活用户可以设置邀请码）
+	if invite != "" {
+		if user.IsActivated == nil || !*user.IsActivated {
+			log.Infof(c, "user %d is not activated, processing invite code: %s", user.ID, invite)
+			inviteCodeID := InviteCodeID(invite)
+			var inviteCode InviteCode
+			if err := tx.First(&inviteCode, inviteCodeID).Error; err != nil {
+				if util.DbIsNotFoundErr(err) {
+					log.Warnf(c, "invalid invite code %s for user %d", invite, user.ID)
+					return e(ErrorInvalidInviteCode, "invalid invite code")
+				}
+				log.Errorf(c, "failed to check invite code %s: %v", invite, err)
+				return err
+			}
+
+			// 检查自邀请
+			if inviteCode.UserID == user.ID {
+				log.Warnf(c, "self-invitation detected for user %d with code %s", user.ID, invite)
+				return e(ErrorSelfInvitation, "cannot use your own invite code")
+			}
+
+			// 设置邀请码
+			user.InvitedByCodeID = inviteCodeID
+			needSave = true
+			log.Infof(c, "will set invite code %s for user %d", invite, user.ID)
+
+			// 异步处理邀请奖励
+			go handleInviteDownloadReward(c, user.ID)
+		} else {
+			log.Infof(c, "user %d is already activated, ignoring invite code", user.ID)
+		}
+	}
+
+	// 如果未激活用户没有提供邀请码，也需要激活账号
+	if user.IsActivated == nil || !*user.IsActivated {
+		user.IsActivated = BoolPtr(true)
+		user.ActivatedAt = time.Now().Unix()
+		needSave = true
+		log.Infof(c, "will activate user %d (web login without invite code) at %d", user.ID, user.ActivatedAt)
+	}
+
+	// 保存用户信息
+	if needSave {
+		if err := tx.Save(user).Error; err != nil {
+			log.Errorf(c, "failed to save user %d: %v", user.ID, err)
+			return err
+		}
+		log.Infof(c, "successfully saved user %d updates", user.ID)
+	}
+
+	return nil
+}
+
+// respondWebLogin 为已通过校验的用户开启 Web 会话、写 Cookie、发送登录提醒并返回登录结果。
+// method 仅用于日志，如 "passkey 12"、"google"。
+func respondWebLogin(c *gin.Context, user *User, method string) {
+	authResult, err := issueWebSession(c, user.ID, user.Roles)
+	if err != nil {
+		log.Errorf(c, "failed to generate web cookie token for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to generate tokens")
+		return
+	}
+	setAuthCookies(c, authResult)
+
+	meta := WebLoginMeta{
+		LoginTime: time.Now().Format("2006-01-02 15:04:05"),
+		ClientIP:  c.ClientIP(),
+	}
+	if err := emailToUser(c, int64(user.ID), brandedWebLoginTemplate.For(Brand(user.Brand)), meta); err != nil {
+		log.Errorf(c, "failed to send web login email to user %d: %v", user.ID, err)
+	}
+
+	email, err := getUserEmail(c, user.ID)
+	if err != nil {
+		log.Warnf(c, "%s login: no email for user %d: %v", method, user.ID, err)
+	}
+	log.Infof(c, "user %d successfully logged in via %s", user.ID, method)
+	Success(c, &DataWebLoginResponse{
+		User: DataWebLoginUser{
+			ID:          user.ID,
+			Email:       email,
+			IsAdmin:     user.IsAdmin != nil && *user.IsAdmin,
+			Roles:       user.Roles,
+			HasPassword: HasPasswordSet(user),
+		},
+		AccessToken: authResult.AccessToken,
+	})
+}
+
 type AuthWithDeviceRequest struct {
 	UDID string `json:"udid" binding:"required"`
 }
@@ -767,6 +894,10 @@ type PasswordLoginRequest struct {
 	Platform   string `json:"platform"`
 	// Language preference
 	Language string `json:"language"`
+	// Two-factor code or recovery code, required once the account enables TOTP
+	TOTPCode string `json:"totpCode"`
+	// Turnstile token, required after the risk engine answers ErrorCaptchaRequired
+	CaptchaToken string `json:"captchaToken"`
 }
 
 // api_password_login handles password-based authentication
@@ -797,18 +928,33 @@ func api_password_login(c *gin.Context) {
 
 	// Find user by email
 	var identify LoginIdentify
-	if err := db.Get().Preload("User").Where("type = ? AND index_id = ? AND brand = ?", "email", indexID, string(ReqBrand(c))).First(&identify).Error; err != nil {
-		if util.DbIsNotFoundErr(err) {
-			log.Warnf(c, "user not found for password login, email (hashed): %s", indexID)
-			// Use generic error to prevent email enumeration
-			Error(c, ErrorInvalidCredentials, "invalid email or password")
-			return
-		}
-		log.Errorf(c, "failed to find user for password login: %v", err)
+	lookupErr := db.Get().Preload("User").Where("type = ? AND index_id = ? AND brand = ?", "email", indexID, string(ReqBrand(c))).First(&identify).Error
+	if lookupErr != nil && !util.DbIsNotFoundErr(lookupErr) {
+		log.Errorf(c, "failed to find user for password login: %v", lookupErr)
 		Error(c, ErrorSystemError, "login failed")
 		return
 	}
 
+	// Risk check runs before the not-found branch so unknown emails are
+	// throttled exactly like known ones
+	risk, ok := enforceLoginRisk(c, loginRiskAttempt{
+		Action:       loginRiskActionPasswordLogin,
+		EmailHash:    indexID,
+		User:         identify.User,
+		UDID:         req.UDID,
+		CaptchaToken: req.CaptchaToken,
+	})
+	if !ok {
+		return
+	}
+	if lookupErr != nil {
+		log.Warnf(c, "user not found for password login, email (hashed): %s", indexID)
+		recordLoginFailure(c, risk)
+		// Use generic error to prevent email enumeration
+		Error(c, ErrorInvalidCredentials, "invalid email or password")
+		return
+	}
+
 	user := identify.User
 	if user == nil {
 		log.Errorf(c, "user object is nil for identify %d", identify.ID)
@@ -825,6 +971,7 @@ func api_password_login(c *gin.Context) {
 	// Check if password is set
 	if !HasPasswordSet(user) {
 		log.Warnf(c, "user %d has no password set", user.ID)
+		recordLoginFailure(c, risk)
 		Error(c, ErrorInvalidCredentials, "invalid email or password")
 		return
 	}
@@ -843,68 +990,25 @@ func api_password_login(c *gin.Context) {
 		if err := RecordFailedPasswordAttempt(c, user); err != nil {
 			log.Errorf(c, "failed to record failed attempt for user %d: %v", user.ID, err)
 		}
+		recordLoginFailure(c, risk)
 		Error(c, ErrorInvalidCredentials, "invalid email or password")
 		return
 	}
 
+	// Second factor, only after the password checks out so the code prompt
+	// never confirms an account exists
+	if err := verifySecondFactor(c, user, req.TOTPCode); err != nil {
+		ErrorE(c, err)
+		return
+	}
+
 	// Reset failed attempts on success
 	if err := ResetFailedPasswordAttempts(c, user); err != nil {
 		log.Errorf(c, "failed to reset failed attempts for user %d: %v", user.ID, err)
 	}
 
 	// Proceed with device binding (similar to api_login)
-	var device Device
-	var authResult *DataAuthResult
-	var err error
-
-	err = db.Get().Transaction(func(tx *gorm.DB) error {
-		// Check for device transfer
-		var oldDevice Device
-		oldDeviceErr := tx.Where("udid = ?", req.UDID).First(&oldDevice).Error
-		if oldDeviceErr == nil && oldDevice.UserID != identify.UserID {
-			log.Warnf(c, "device transfer detected: udid=%s, from user %d to user %d",
-				req.UDID, oldDevice.UserID, identify.UserID)
-			transferMeta := DeviceTransferMeta{
-				TransferTime: time.Now().Format("2006-01-02 15:04:05"),
-				DeviceRemark: oldDevice.Remark,
-			}
-			// 收件人是设备原所有者（oldDevice.UserID），与本次登录用户不同——
-			// 品牌来自收件人自己的 User 行，而非当前请求品牌。
-			oldOwnerBrand := brandOfUser(c, oldDevice.UserID)
-			if err := emailToUser(c, int64(oldDevice.UserID), brandedDeviceTransferTemplate.For(oldOwnerBrand), transferMeta); err != nil {
-				log.Errorf(c, "failed to send device transfer email: %v", err)
-			}
-		}
-
-		// Delete old device record
-		if err := tx.Where("udid = ?", req.UDID).Delete(&Device{}).Error; err != nil {
-			return err
-		}
-
-		// Check device limit by type (app vs router)
-		if err := checkDeviceLimitOrKick(c, tx, user, isGatewayRequest(c)); err != nil {
-			return err
-		}
-
-		// Generate tokens
-		var tokenIssueTime time.Time
-		authResult, tokenIssueTime, err = generateTokens(c, identify.UserID, req.UDID, user.Roles)
-		if err != nil {
-			return err
-		}
-
-		// Create device record
-		device = Device{
-			UDID:            req.UDID,
-			Remark:          req.Remark,
-			UserID:          identify.UserID,
-			TokenIssueAt:    tokenIssueTime.Unix(),
-			TokenLastUsedAt: time.Now().Unix(),
-		}
-		createDeviceWithAppInfo(c, &device)
-		return tx.Create(&device).Error
-	})
-
+	authResult, err := bindDeviceLogin(c, user, req.UDID, req.Remark)
 	if err != nil {
 		log.Errorf(c, "password login transaction failed for user %d: %v", user.ID, err)
 		ErrorE(c, err)
@@ -928,7 +1032,8 @@ func api_password_login(c *gin.Context) {
 		log.Errorf(c, "failed to send password login email to user %d: %v", identify.UserID, err)
 	}
 
-	log.Infof(c, "user %d logged in via password with device %s", identify.UserID, device.UDID)
+	log.Infof(c, "user %d logged in via password with device %s", identify.UserID, req.UDID)
+	recordLoginSuccess(c, risk)
 	Success(c, authResult)
 }
 
@@ -937,10 +1042,12 @@ func api_password_login(c *gin.Context) {
 // authentication that returns an HttpOnly cookie (plus accessToken in body
 // for WebView fallback).
 type WebPasswordLoginRequest struct {
-	Email      string `json:"email" binding:"required,email"`
-	Password   string `json:"password" binding:"required"`
-	Language   string `json:"language"`
-	InviteCode string `json:"inviteCode"`
+	Email        string `json:"email" binding:"required,email"`
+	Password     string `json:"password" binding:"required"`
+	Language     string `json:"language"`
+	InviteCode   string `json:"inviteCode"`
+	TOTPCode     string `json:"totpCode"`
+	CaptchaToken string `json:"captchaToken"`
 }
 
 // api_web_password_login handles cookie-based password authentication for
@@ -970,17 +1077,29 @@ func api_web_password_login(c *gin.Context) {
 	indexID := secretHashIt(c, []byte(req.Email))
 
 	var identify LoginIdentify
-	if err := db.Get().Preload("User").Where("type = ? AND index_id = ? AND brand = ?", "email", indexID, string(ReqBrand(c))).First(&identify).Error; err != nil {
-		if util.DbIsNotFoundErr(err) {
-			log.Warnf(c, "user not found for web password login, email (hashed): %s", indexID)
-			Error(c, ErrorInvalidCredentials, "invalid email or password")
-			return
-		}
-		log.Errorf(c, "failed to find user for web password login: %v", err)
+	lookupErr := db.Get().Preload("User").Where("type = ? AND index_id = ? AND brand = ?", "email", indexID, string(ReqBrand(c))).First(&identify).Error
+	if lookupErr != nil && !util.DbIsNotFoundErr(lookupErr) {
+		log.Errorf(c, "failed to find user for web password login: %v", lookupErr)
 		Error(c, ErrorSystemError, "login failed")
 		return
 	}
 
+	risk, ok := enforceLoginRisk(c, loginRiskAttempt{
+		Action:       loginRiskActionPasswordLogin,
+		EmailHash:    indexID,
+		User:         identify.User,
+		CaptchaToken: req.CaptchaToken,
+	})
+	if !ok {
+		return
+	}
+	if lookupErr != nil {
+		log.Warnf(c, "user not found for web password login, email (hashed): %s", indexID)
+		recordLoginFailure(c, risk)
+		Error(c, ErrorInvalidCredentials, "invalid email or password")
+		return
+	}
+
 	user := identify.User
 	if user == nil {
 		log.Errorf(c, "user object is nil for identify %d", identify.ID)
@@ -996,6 +1115,7 @@ func api_web_password_login(c *gin.Context) {
 
 	if !HasPasswordSet(user) {
 		log.Warnf(c, "user %d has no password set (web)", user.ID)
+		recordLoginFailure(c, risk)
 		Error(c, ErrorInvalidCredentials, "invalid email or password")
 		return
 	}
@@ -1012,10 +1132,16 @@ func api_web_password_login(c *gin.Context) {
 		if err := RecordFailedPasswordAttempt(c, user); err != nil {
 			log.Errorf(c, "failed to record failed attempt for user %d: %v", user.ID, err)
 		}
+		recordLoginFailure(c, risk)
 		Error(c, ErrorInvalidCredentials, "invalid email or password")
 		return
 	}
 
+	if err := verifySecondFactor(c, user, req.TOTPCode); err != nil {
+		ErrorE(c, err)
+		return
+	}
+
 	if err := ResetFailedPasswordAttempts(c, user); err != nil {
 		log.Errorf(c, "failed to reset failed attempts for user %d: %v", user.ID, err)
 	}
@@ -1081,7 +1207,7 @@ func api_web_password_login(c *gin.Context) {
 		return
 	}
 
-	authResult, _, err := generateWebCookieToken(c, identify.UserID, userRoles)
+	authResult, err := issueWebSession(c, identify.UserID, userRoles)
 	if err != nil {
 		log.Errorf(c, "failed to generate web cookie token for user %d: %v", identify.UserID, err)
 		Error(c, ErrorSystemError, "failed to generate tokens")
@@ -1101,6 +1227,7 @@ func api_web_password_login(c *gin.Context) {
 	}
 
 	log.Infof(c, "user %d successfully logged in via web password", identify.UserID)
+	recordLoginSuccess(c, risk)
 	// HasPassword is unconditionally true on this code path — we just verified
 	// the user's password to get here. Routed through HasPasswordSet for
 	// consistency with api_web_auth (cheap, no extra query).
@@ -280,7 +280,7 @@ func TestRefreshToken_BlockedUser_ReturnsForbidden(t *testing.T) {
 	require.NoError(t, db.Get().Create(&device).Error)
 	t.Cleanup(func() { db.Get().Unscoped().Delete(&device) })
 
-	authResult, issueTime, err := generateTokens(context.Background(), user.ID, udid, 0)
+	authResult, issueTime, err := generateTokens(context.Background(), user.ID, udid, 0, "")
 	require.NoError(t, err)
 	device.TokenIssueAt = issueTime.Unix()
 	require.NoError(t, db.Get().Save(&device).Error)
@@ -305,7 +305,7 @@ func TestRefreshToken_NotBlockedUser_Succeeds(t *testing.T) {
 	require.NoError(t, db.Get().Create(&device).Error)
 	t.Cleanup(func() { db.Get().Unscoped().Delete(&device) })
 
-	authResult, issueTime, err := generateTokens(context.Background(), user.ID, udid, 0)
+	authResult, issueTime, err := generateTokens(context.Background(), user.ID, udid, 0, "")
 	require.NoError(t, err)
 	device.TokenIssueAt = issueTime.Unix()
 	require.NoError(t, db.Get().Save(&device).Error)
@@ -0,0 +1,480 @@
+package center
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"github.com/wordgate/qtoolkit/redis"
+	"gorm.io/gorm"
+)
+
+// 无交互登录：CI runner、团队机器上的 k2-mcp 等无法收邮件验证码的客户端。
+//
+//  1. Access key：请求头 X-Access-Key 走 handleAccessKeyAuth，直接绑定设备签发 token。
+//  2. 设备授权（RFC 8628 风格）：客户端申请 deviceCode + userCode，展示 userCode；
+//     已登录用户在 App 设备页输入 userCode 批准，批准记录以 deviceCode 为键单独存放
+//     （不与 Web OTT 共用前缀，否则 deviceCode 本身就能拿去 /api/auth/ott/exchange）；
+//     客户端轮询时一次性取走该记录，绑定设备并拿到 token。
+
+const (
+	deviceAuthPrefix   = "devauth:"
+	deviceAuthTTL      = 600 // 10 minutes
+	deviceAuthInterval = 5   // seconds between polls
+	// deviceCodeIPLimit caps codes issued per source IP per deviceAuthTTL window;
+	// each code also holds two Redis keys for the whole window.
+	deviceCodeIPLimit = 10
+	// userCodeAlphabet drops vowels and look-alikes (RFC 8628 §6.1).
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// deviceAuthRequest is the pending request, stored under both codes.
+type deviceAuthRequest struct {
+	DeviceCode string `json:"device_code"`
+	UserCode   string `json:"user_code"`
+	UDID       string `json:"udid"`
+	Remark     string `json:"remark"`
+	ClientIP   string `json:"client_ip"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+// remainingTTL is how many seconds of the deviceAuthTTL window are left.
+func (p *deviceAuthRequest) remainingTTL(now time.Time) int {
+	return deviceAuthTTL - int(now.Unix()-p.CreatedAt)
+}
+
+// DataDeviceLoginRequest 无交互登录的设备信息
+type DataDeviceLoginRequest struct {
+	UDID   string `json:"udid" binding:"required"`
+	Remark string `json:"remark"`
+}
+
+// DataDeviceCodeResponse 设备授权码
+type DataDeviceCodeResponse struct {
+	DeviceCode string `json:"deviceCode"` // 客户端保密，用于轮询
+	UserCode   string `json:"userCode"`   // 展示给用户，形如 BCDF-GHJK
+	ExpiresIn  int    `json:"expiresIn"`  // 秒
+	Interval   int    `json:"interval"`   // 建议轮询间隔（秒）
+}
+
+// DataDeviceTokenRequest 设备授权轮询请求
+type DataDeviceTokenRequest struct {
+	DeviceCode string `json:"deviceCode" binding:"required"`
+}
+
+// DataDeviceAuthInfo 待批准的设备授权（展示给批准人核对）
+type DataDeviceAuthInfo struct {
+	UserCode  string `json:"userCode"`
+	Remark    string `json:"remark"`
+	ClientIP  string `json:"clientIp"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// DataDeviceAuthApproveRequest 批准设备授权
+type DataDeviceAuthApproveRequest struct {
+	UserCode string `json:"userCode" binding:"required"`
+}
+
+// bindDeviceLogin binds udid to user and issues device tokens, replacing any
+// previous binding of udid (notifying its old owner) and enforcing the
+// device limit. Shared by password, access key and device-code login.
+func bindDeviceLogin(c *gin.Context, user *User, udid, remark string) (*DataAuthResult, error) {
+	var authResult *DataAuthResult
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		// Check for device transfer
+		var oldDevice Device
+		oldDeviceErr := tx.Where("udid = ?", udid).First(&oldDevice).Error
+		if oldDeviceErr == nil && oldDevice.UserID != user.ID {
+			log.Warnf(c, "device transfer detected: udid=%s, from user %d to user %d",
+				udid, oldDevice.UserID, user.ID)
+			transferMeta := DeviceTransferMeta{
+				TransferTime: time.Now().Format("2006-01-02 15:04:05"),
+				DeviceRemark: oldDevice.Remark,
+			}
+			// 收件人是设备原所有者（oldDevice.UserID），与本次登录用户不同——
+			// 品牌来自收件人自己的 User 行，而非当前请求品牌。
+			oldOwnerBrand := brandOfUser(c, oldDevice.UserID)
+			if err := emailToUser(c, int64(oldDevice.UserID), brandedDeviceTransferTemplate.For(oldOwnerBrand), transferMeta); err != nil {
+				log.Errorf(c, "failed to send device transfer email: %v", err)
+			}
+		}
+
+		// Delete old device record
+		if err := tx.Where("udid = ?", udid).Delete(&Device{}).Error; err != nil {
+			return err
+		}
+
+		// Check device limit by type (app vs router)
+		if err := checkDeviceLimitOrKick(c, tx, user, isGatewayRequest(c)); err != nil {
+			return err
+		}
+
+		// Generate tokens
+		var tokenIssueTime time.Time
+		var err error
+		authResult, tokenIssueTime, err = issueDeviceSession(c, tx, user.ID, udid, user.Roles)
+		if err != nil {
+			return err
+		}
+
+		// Create device record
+		device := Device{
+			UDID:            udid,
+			Remark:          remark,
+			UserID:          user.ID,
+			TokenIssueAt:    tokenIssueTime.Unix(),
+			TokenLastUsedAt: time.Now().Unix(),
+		}
+		createDeviceWithAppInfo(c, &device)
+		return tx.Create(&device).Error
+	})
+	return authResult, err
+}
+
+// notifyHeadlessLogin mails the account owner about a login that did not
+// involve their inbox, so an unexpected one is noticed.
+func notifyHeadlessLogin(c *gin.Context, user *User, remark string) {
+	meta := NewDeviceLoginMeta{
+		LoginTime: time.Now().Format("2006-01-02 15:04:05"),
+		Remark:    remark,
+	}
+	if err := emailToUser(c, int64(user.ID), brandedNewDeviceLoginTemplate.For(Brand(user.Brand)), meta); err != nil {
+		log.Errorf(c, "failed to send new device login email to user %d: %v", user.ID, err)
+	}
+}
+
+// rejectInvalidClientClass mirrors api_login: an unknown X-K2-Client token
+// would create a Device row EnforceDeviceClass later refuses.
+func rejectInvalidClientClass(c *gin.Context) bool {
+	if rawHeader := c.GetHeader("X-K2-Client"); rawHeader != "" && parseClientHeader(rawHeader) == nil {
+		log.Warnf(c, "login rejected: invalid client class header=%q remote=%s", rawHeader, c.ClientIP())
+		Error(c, ErrorInvalidClientClass, "invalid client class token")
+		return true
+	}
+	return false
+}
+
+// api_access_key_login 使用 access key 登录并绑定设备
+//
+// POST /api/auth/access-key   Header: X-Access-Key
+func api_access_key_login(c *gin.Context) {
+	var req DataDeviceLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warnf(c, "invalid access key login request: %v", err)
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+	if rejectInvalidClientClass(c) {
+		return
+	}
+
+	accessKey := c.GetHeader("X-Access-Key")
+	if accessKey == "" {
+		Error(c, ErrorInvalidCredentials, "access key required")
+		return
+	}
+	auth := handleAccessKeyAuth(c, accessKey)
+	if auth == nil || auth.User == nil {
+		log.Warnf(c, "access key login rejected, udid: %s", req.UDID)
+		Error(c, ErrorInvalidCredentials, "invalid access key")
+		return
+	}
+	if isUserBlocked(auth.User) {
+		log.Warnf(c, "access key login rejected: user %d is blocked", auth.UserID)
+		Error(c, ErrorForbidden, "account blocked")
+		return
+	}
+
+	authResult, err := bindDeviceLogin(c, auth.User, req.UDID, req.Remark)
+	if err != nil {
+		log.Errorf(c, "access key login failed for user %d: %v", auth.UserID, err)
+		ErrorE(c, err)
+		return
+	}
+	notifyHeadlessLogin(c, auth.User, req.Remark)
+
+	log.Infof(c, "user %d logged in via access key with device %s", auth.UserID, req.UDID)
+	Success(c, authResult)
+}
+
+// newUserCode returns an 8-letter code from userCodeAlphabet.
+func newUserCode() (string, error) {
+	buf := make([]byte, userCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := make([]byte, userCodeLength)
+	for i, b := range buf {
+		// 256 % 20 != 0 leaves a slight bias; irrelevant at 20^8 codes with a 10 minute TTL.
+		code[i] = userCodeAlphabet[int(b)%len(userCodeAlphabet)]
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode accepts the code as typed: any case, with or without
+// the dash or spaces.
+func normalizeUserCode(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// formatUserCode renders BCDFGHJK as BCDF-GHJK.
+func formatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
+
+func deviceAuthApprovalKey(deviceCode string) string {
+	return deviceAuthPrefix + "approved:" + deviceCode
+}
+
+func deviceCodeRateKey(ip string) string {
+	return deviceAuthPrefix + "rate:" + ip
+}
+
+// allowDeviceCode counts an issuance against the source IP's fixed window.
+// Redis errors let the request through, same as assessLoginRisk.
+func allowDeviceCode(c *gin.Context) bool {
+	ctx := context.Background()
+	rdb := redis.Client()
+	key := deviceCodeRateKey(c.ClientIP())
+	n, err := rdb.Incr(ctx, key).Result()
+	if err != nil {
+		log.Warnf(c, "device code: failed to count issuance for %s: %v", c.ClientIP(), err)
+		return true
+	}
+	if n == 1 {
+		rdb.Expire(ctx, key, deviceAuthTTL*time.Second)
+	}
+	return n <= deviceCodeIPLimit
+}
+
+func loadDeviceAuth(key string) (*deviceAuthRequest, error) {
+	var raw string
+	exist, err := redis.CacheGet(key, &raw)
+	if err != nil || !exist {
+		return nil, err
+	}
+	var req deviceAuthRequest
+	if err := json.Unmarshal([]byte(raw), &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// api_device_code 申请设备授权码
+//
+// POST /api/auth/device-code
+func api_device_code(c *gin.Context) {
+	var req DataDeviceLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warnf(c, "invalid device code request: %v", err)
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+	if rejectInvalidClientClass(c) {
+		return
+	}
+
+	// 与发码接口一样先过登录风控（被封禁的 IP 直接拒绝），再按 IP 限制签发频率；
+	// 超限计为一次失败，持续刷码会累积到封禁
+	risk, ok := enforceLoginRisk(c, loginRiskAttempt{
+		Action: loginRiskActionDeviceCode,
+		UDID:   req.UDID,
+	})
+	if !ok {
+		return
+	}
+	if !allowDeviceCode(c) {
+		recordLoginFailure(c, risk)
+		log.Warnf(c, "device code rejected: %s exceeded %d codes per %ds", c.ClientIP(), deviceCodeIPLimit, deviceAuthTTL)
+		Error(c, ErrorTooManyRequests, "too many device code requests, try again later")
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Errorf(c, "failed to generate device code: %v", err)
+		Error(c, ErrorSystemError, "failed to generate device code")
+		return
+	}
+	userCode, err := newUserCode()
+	if err != nil {
+		log.Errorf(c, "failed to generate user code: %v", err)
+		Error(c, ErrorSystemError, "failed to generate device code")
+		return
+	}
+
+	pending := deviceAuthRequest{
+		DeviceCode: hex.EncodeToString(secret),
+		UserCode:   userCode,
+		UDID:       req.UDID,
+		Remark:     req.Remark,
+		ClientIP:   c.ClientIP(),
+		CreatedAt:  time.Now().Unix(),
+	}
+	raw, _ := json.Marshal(pending)
+	if err := redis.CacheSet(deviceAuthPrefix+"code:"+pending.DeviceCode, string(raw), deviceAuthTTL); err != nil {
+		log.Errorf(c, "failed to store device code: %v", err)
+		Error(c, ErrorSystemError, "failed to store device code")
+		return
+	}
+	if err := redis.CacheSet(deviceAuthPrefix+"user:"+userCode, string(raw), deviceAuthTTL); err != nil {
+		log.Errorf(c, "failed to store user code: %v", err)
+		Error(c, ErrorSystemError, "failed to store device code")
+		return
+	}
+
+	recordLoginSuccess(c, risk)
+	log.Infof(c, "device code issued for udid %s, user code %s", req.UDID, formatUserCode(userCode))
+	Success(c, &DataDeviceCodeResponse{
+		DeviceCode: pending.DeviceCode,
+		UserCode:   formatUserCode(userCode),
+		ExpiresIn:  deviceAuthTTL,
+		Interval:   deviceAuthInterval,
+	})
+}
+
+// api_device_token 轮询设备授权结果
+//
+// POST /api/auth/device-token
+//
+// Returns:
+// - code 0: 已批准，返回 token（一次性，再次轮询视为过期）
+// - code 202: 等待用户批准
+// - code 400013: 授权码不存在或已过期
+func api_device_token(c *gin.Context) {
+	var req DataDeviceTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+
+	codeKey := deviceAuthPrefix + "code:" + req.DeviceCode
+	pending, err := loadDeviceAuth(codeKey)
+	if err != nil {
+		log.Errorf(c, "failed to load device code: %v", err)
+		Error(c, ErrorSystemError, "failed to load device code")
+		return
+	}
+	if pending == nil {
+		Error(c, ErrorVerificationCodeExpired, "device code expired")
+		return
+	}
+
+	approval, err := takeOTT(deviceAuthApprovalKey(req.DeviceCode))
+	if err != nil {
+		log.Errorf(c, "device token: %v", err)
+		Error(c, ErrorSystemError, "failed to read approval")
+		return
+	}
+	if approval == nil {
+		Error(c, ErrorPendingApproval, "authorization pending")
+		return
+	}
+	_ = redis.CacheDel(codeKey)
+
+	var user User
+	if err := db.Get().First(&user, approval.UserID).Error; err != nil {
+		log.Errorf(c, "device token: approving user %d not found: %v", approval.UserID, err)
+		Error(c, ErrorVerificationCodeExpired, "device code expired")
+		return
+	}
+	if isUserBlocked(&user) {
+		log.Warnf(c, "device token rejected: user %d is blocked", user.ID)
+		Error(c, ErrorForbidden, "account blocked")
+		return
+	}
+
+	authResult, err := bindDeviceLogin(c, &user, pending.UDID, pending.Remark)
+	if err != nil {
+		log.Errorf(c, "device code login failed for user %d: %v", user.ID, err)
+		ErrorE(c, err)
+		return
+	}
+	notifyHeadlessLogin(c, &user, pending.Remark)
+
+	log.Infof(c, "user %d logged in via device code with device %s", user.ID, pending.UDID)
+	Success(c, authResult)
+}
+
+// api_get_device_auth 查询待批准的设备授权
+//
+// GET /api/user/device-auth/:code
+func api_get_device_auth(c *gin.Context) {
+	code := normalizeUserCode(c.Param("code"))
+	pending, err := loadDeviceAuth(deviceAuthPrefix + "user:" + code)
+	if err != nil {
+		log.Errorf(c, "failed to load user code: %v", err)
+		Error(c, ErrorSystemError, "failed to load device code")
+		return
+	}
+	if pending == nil {
+		Error(c, ErrorNotFound, "code not found or expired")
+		return
+	}
+	Success(c, &DataDeviceAuthInfo{
+		UserCode:  formatUserCode(pending.UserCode),
+		Remark:    pending.Remark,
+		ClientIP:  pending.ClientIP,
+		CreatedAt: pending.CreatedAt,
+	})
+}
+
+// api_approve_device_auth 批准设备授权
+//
+// POST /api/user/device-auth/approve
+func api_approve_device_auth(c *gin.Context) {
+	userID := ReqUserID(c)
+	var req DataDeviceAuthApproveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+
+	userKey := deviceAuthPrefix + "user:" + normalizeUserCode(req.UserCode)
+	pending, err := loadDeviceAuth(userKey)
+	if err != nil {
+		log.Errorf(c, "failed to load user code: %v", err)
+		Error(c, ErrorSystemError, "failed to load device code")
+		return
+	}
+	if pending == nil {
+		log.Warnf(c, "user %d tried to approve unknown or expired code", userID)
+		Error(c, ErrorNotFound, "code not found or expired")
+		return
+	}
+
+	// The approval lives as long as the code itself: a device polling late in
+	// the window must still find it after the approver walked away.
+	remaining := pending.remainingTTL(time.Now())
+	if remaining <= 0 {
+		Error(c, ErrorNotFound, "code not found or expired")
+		return
+	}
+
+	// One approval per code; the device takes it once on its next poll.
+	_ = redis.CacheDel(userKey)
+	if err := storeOTT(deviceAuthApprovalKey(pending.DeviceCode), ottData{UserID: userID}, remaining); err != nil {
+		log.Errorf(c, "failed to store device approval: %v", err)
+		Error(c, ErrorSystemError, "failed to approve")
+		return
+	}
+
+	log.Infof(c, "user %d approved device %s (%s)", userID, pending.UDID, pending.Remark)
+	SuccessEmpty(c)
+}
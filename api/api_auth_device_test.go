@@ -0,0 +1,200 @@
+package center
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/redis"
+)
+
+func TestUserCode_FormatAndNormalize(t *testing.T) {
+	code, err := newUserCode()
+	if err != nil {
+		t.Fatalf("newUserCode: %v", err)
+	}
+	if len(code) != userCodeLength {
+		t.Fatalf("expected %d letters, got %q", userCodeLength, code)
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(userCodeAlphabet, r) {
+			t.Errorf("letter %q outside alphabet in %q", r, code)
+		}
+	}
+
+	formatted := formatUserCode(code)
+	if formatted != code[:4]+"-"+code[4:] {
+		t.Errorf("formatUserCode(%q) = %q", code, formatted)
+	}
+	for _, typed := range []string{formatted, strings.ToLower(formatted), " " + code[:4] + " " + code[4:]} {
+		if got := normalizeUserCode(typed); got != code {
+			t.Errorf("normalizeUserCode(%q) = %q, want %q", typed, got, code)
+		}
+	}
+}
+
+func TestDeviceAuthRequest_RemainingTTL(t *testing.T) {
+	now := time.Now()
+	pending := &deviceAuthRequest{CreatedAt: now.Add(-60 * time.Second).Unix()}
+	if got := pending.remainingTTL(now); got != deviceAuthTTL-60 {
+		t.Errorf("remainingTTL one minute in = %d, want %d", got, deviceAuthTTL-60)
+	}
+	pending.CreatedAt = now.Add(-deviceAuthTTL * time.Second).Unix()
+	if got := pending.remainingTTL(now); got > 0 {
+		t.Errorf("remainingTTL at the end of the window = %d, want <= 0", got)
+	}
+}
+
+func setupDeviceAuthRouter() *gin.Engine {
+	r := SetupMinimalRouter()
+	r.POST("/api/auth/access-key", api_access_key_login)
+	r.POST("/api/auth/device-code", api_device_code)
+	r.POST("/api/auth/device-token", api_device_token)
+	r.GET("/api/user/device-auth/:code", AuthRequired(), api_get_device_auth)
+	r.POST("/api/user/device-auth/approve", AuthRequired(), api_approve_device_auth)
+	return r
+}
+
+func TestDeviceCodeLogin_Flow(t *testing.T) {
+	skipIfNoConfig(t)
+	r := setupDeviceAuthRouter()
+
+	approver := CreateTestUser(t)
+	CreateTestDevice(t, approver.ID, "udid-devauth-approver")
+	token := GenerateTestToken(approver.ID, "udid-devauth-approver", time.Hour)
+
+	udid := "udid-devauth-" + time.Now().Format("150405.000000")
+	t.Cleanup(func() { db.Get().Where("udid = ?", udid).Delete(&Device{}) })
+
+	w := NewTestRequest(http.MethodPost, "/api/auth/device-code").
+		WithBody(map[string]string{"udid": udid, "remark": "ci-runner"}).
+		Execute(r)
+	issued, err := ParseResponseData[DataDeviceCodeResponse](w)
+	if err != nil || issued.DeviceCode == "" || issued.UserCode == "" {
+		t.Fatalf("device-code failed: %v, body=%s", err, w.Body.String())
+	}
+
+	poll := func() int {
+		w := NewTestRequest(http.MethodPost, "/api/auth/device-token").
+			WithBody(map[string]string{"deviceCode": issued.DeviceCode}).
+			Execute(r)
+		resp, err := ParseResponse(w)
+		if err != nil {
+			t.Fatalf("parse poll response: %v", err)
+		}
+		return resp.Code
+	}
+	if code := poll(); code != int(ErrorPendingApproval) {
+		t.Fatalf("expected pending before approval, got %d", code)
+	}
+
+	// The approver sees what they are approving, typed in lower case.
+	w = NewTestRequest(http.MethodGet, "/api/user/device-auth/"+strings.ToLower(issued.UserCode)).
+		WithBearerToken(token).
+		Execute(r)
+	info, err := ParseResponseData[DataDeviceAuthInfo](w)
+	if err != nil || info.Remark != "ci-runner" || info.UserCode != issued.UserCode {
+		t.Fatalf("unexpected device-auth info: %+v, %v, body=%s", info, err, w.Body.String())
+	}
+
+	approve := func() int {
+		w := NewTestRequest(http.MethodPost, "/api/user/device-auth/approve").
+			WithBearerToken(token).
+			WithBody(map[string]string{"userCode": issued.UserCode}).
+			Execute(r)
+		resp, _ := ParseResponse(w)
+		return resp.Code
+	}
+	if code := approve(); code != 0 {
+		t.Fatalf("approve failed with code %d", code)
+	}
+	// The approval outlives the short OTT window: it expires with the code.
+	ttl, err := redis.Client().TTL(context.Background(), deviceAuthApprovalKey(issued.DeviceCode)).Result()
+	if err != nil || ttl <= ottTTL*time.Second {
+		t.Errorf("approval TTL = %v, %v; want the code's remaining %ds", ttl, err, deviceAuthTTL)
+	}
+	if code := approve(); code != int(ErrorNotFound) {
+		t.Errorf("second approval must fail, got %d", code)
+	}
+
+	w = NewTestRequest(http.MethodPost, "/api/auth/device-token").
+		WithBody(map[string]string{"deviceCode": issued.DeviceCode}).
+		Execute(r)
+	result, err := ParseResponseData[DataAuthResult](w)
+	if err != nil || result.AccessToken == "" {
+		t.Fatalf("expected tokens after approval: %v, body=%s", err, w.Body.String())
+	}
+	var device Device
+	if err := db.Get().Where("udid = ?", udid).First(&device).Error; err != nil || device.UserID != approver.ID {
+		t.Errorf("device not bound to approver: %+v, %v", device, err)
+	}
+
+	if code := poll(); code != int(ErrorVerificationCodeExpired) {
+		t.Errorf("device code must be single use, got %d", code)
+	}
+}
+
+// TestDeviceCode_RateLimitedPerIP: the endpoint is unauthenticated and every
+// code holds Redis keys for ten minutes, so issuance is capped per source IP.
+func TestDeviceCode_RateLimitedPerIP(t *testing.T) {
+	skipIfNoConfig(t)
+	r := setupDeviceAuthRouter()
+
+	// postJSON goes through httptest.NewRequest, which always comes from 192.0.2.1
+	const ip = "192.0.2.1"
+	reset := func() {
+		_ = redis.CacheDel(deviceCodeRateKey(ip))
+		_ = unblockLoginRiskIP(context.Background(), ip)
+	}
+	reset()
+	t.Cleanup(reset)
+
+	body := map[string]string{"udid": "udid-devauth-rate"}
+	for i := 0; i < deviceCodeIPLimit; i++ {
+		if resp := postJSON(t, r, "/api/auth/device-code", body); resp.Code != 0 {
+			t.Fatalf("request %d: expected success, got %d", i+1, resp.Code)
+		}
+	}
+	if resp := postJSON(t, r, "/api/auth/device-code", body); resp.Code != int(ErrorTooManyRequests) {
+		t.Errorf("expected ErrorTooManyRequests past the limit, got %d", resp.Code)
+	}
+}
+
+func TestAccessKeyLogin(t *testing.T) {
+	skipIfNoConfig(t)
+	r := setupDeviceAuthRouter()
+
+	user := CreateTestUser(t)
+	key, err := GenerateAccessKey(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("GenerateAccessKey: %v", err)
+	}
+
+	udid := "udid-accesskey-" + time.Now().Format("150405.000000")
+	t.Cleanup(func() { db.Get().Where("udid = ?", udid).Delete(&Device{}) })
+	body := map[string]string{"udid": udid, "remark": "k2-mcp"}
+
+	w := NewTestRequest(http.MethodPost, "/api/auth/access-key").
+		WithHeader("X-Access-Key", key).
+		WithBody(body).
+		Execute(r)
+	result, err := ParseResponseData[DataAuthResult](w)
+	if err != nil || result.AccessToken == "" {
+		t.Fatalf("expected tokens: %v, body=%s", err, w.Body.String())
+	}
+
+	for name, header := range map[string]string{"missing": "", "wrong": key + "x"} {
+		w := NewTestRequest(http.MethodPost, "/api/auth/access-key").
+			WithHeader("X-Access-Key", header).
+			WithBody(body).
+			Execute(r)
+		resp, _ := ParseResponse(w)
+		if resp.Code != int(ErrorInvalidCredentials) {
+			t.Errorf("%s key: expected %d, got %d", name, ErrorInvalidCredentials, resp.Code)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package center
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/url"
 	"strings"
 
@@ -32,6 +34,37 @@ type DataOTTResponse struct {
 	URL string `json:"url"`
 }
 
+// storeOTT saves data under key for ttl seconds.
+func storeOTT(key string, data ottData, ttl int) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal OTT data: %w", err)
+	}
+	return redis.CacheSet(key, string(dataJSON), ttl)
+}
+
+// takeOTT reads and deletes the OTT stored under key in one GETDEL, so two
+// concurrent exchanges cannot both redeem it. Returns nil data when the
+// token is unknown or expired.
+func takeOTT(key string) (*ottData, error) {
+	raw, err := redis.Client().GetDel(context.Background(), key).Result()
+	if err != nil {
+		// 不存在（redis.Nil）与读取失败一样按无效 token 处理
+		return nil, nil
+	}
+
+	// storeOTT 经 CacheSet 写入，值本身是 JSON 编码过的字符串
+	var dataJSON string
+	if err := json.Unmarshal([]byte(raw), &dataJSON); err != nil {
+		return nil, fmt.Errorf("corrupt OTT data: %w", err)
+	}
+	var data ottData
+	if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
+		return nil, fmt.Errorf("corrupt OTT data: %w", err)
+	}
+	return &data, nil
+}
+
 // isAllowedRedirect validates redirect URL: must be https, host must be the
 // user's brand root domain or a subdomain of it.
 func isAllowedRedirect(rawURL string, b Brand) bool {
@@ -81,14 +114,7 @@ func api_issue_ott(c *gin.Context) {
 	token := hex.EncodeToString(tokenBytes)
 
 	// Store in Redis
-	data := ottData{UserID: auth.UserID, Redirect: req.Redirect}
-	dataJSON, err := json.Marshal(data)
-	if err != nil {
-		log.Errorf(c, "failed to marshal OTT data: %v", err)
-		Error(c, ErrorSystemError, "failed to generate token")
-		return
-	}
-	if err := redis.CacheSet(ottPrefix+token, string(dataJSON), ottTTL); err != nil {
+	if err := storeOTT(ottPrefix+token, ottData{UserID: auth.UserID, Redirect: req.Redirect}, ottTTL); err != nil {
 		log.Errorf(c, "failed to store OTT in Redis: %v", err)
 		Error(c, ErrorSystemError, "failed to store token")
 		return
@@ -117,23 +143,16 @@ func api_exchange_ott(c *gin.Context) {
 		return
 	}
 
-	// Get from Redis
-	var dataJSON string
-	exist, err := redis.CacheGet(ottPrefix+token, &dataJSON)
-	if err != nil || !exist {
-		log.Warnf(c, "OTT exchange failed: token not found or expired")
-		c.Redirect(302, "/auth/login?reason=expired")
+	// Get from Redis and delete immediately (one-time use)
+	data, err := takeOTT(ottPrefix + token)
+	if err != nil {
+		log.Errorf(c, "OTT exchange failed: %v", err)
+		c.Redirect(302, "/auth/login?reason=invalid")
 		return
 	}
-
-	// Delete immediately (one-time use)
-	_ = redis.CacheDel(ottPrefix + token)
-
-	// Parse stored data
-	var data ottData
-	if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
-		log.Errorf(c, "OTT exchange failed: corrupt data: %v", err)
-		c.Redirect(302, "/auth/login?reason=invalid")
+	if data == nil {
+		log.Warnf(c, "OTT exchange failed: token not found or expired")
+		c.Redirect(302, "/auth/login?reason=expired")
 		return
 	}
 
@@ -167,7 +186,7 @@ func api_exchange_ott(c *gin.Context) {
 	}
 
 	// Generate web cookie token (same as web login)
-	authResult, _, err := generateWebCookieToken(c, user.ID, user.Roles)
+	authResult, err := issueWebSession(c, user.ID, user.Roles)
 	if err != nil {
 		log.Errorf(c, "OTT exchange failed: token generation error: %v", err)
 		c.Redirect(302, "/auth/login?reason=expired")
@@ -0,0 +1,156 @@
+package center
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// DataNotificationPreference 单个类别的偏好
+type DataNotificationPreference struct {
+	Category  NotifyCategory `json:"category"`
+	Push      bool           `json:"push"`
+	Email     bool           `json:"email"`
+	Mandatory bool           `json:"mandatory"` // 不可关闭（安全类）
+}
+
+// DataNotificationPreferences 全部类别的偏好
+type DataNotificationPreferences struct {
+	Items []DataNotificationPreference `json:"items"`
+}
+
+// UpdateNotificationPreferencesRequest 更新偏好请求，只改列出的类别
+type UpdateNotificationPreferencesRequest struct {
+	Items []struct {
+		Category NotifyCategory `json:"category" binding:"required"`
+		Push     bool           `json:"push"`
+		Email    bool           `json:"email"`
+	} `json:"items" binding:"required,min=1"`
+}
+
+// MarkInboxReadRequest 标记已读请求：ids 与 all 二选一
+type MarkInboxReadRequest struct {
+	IDs []uint64 `json:"ids"`
+	All bool     `json:"all"`
+}
+
+func notificationPreferencesResponse(c *gin.Context, userID uint64) {
+	prefs, err := getNotificationPreferences(userID)
+	if err != nil {
+		log.Errorf(c, "failed to load notification preferences for user %d: %v", userID, err)
+		Error(c, ErrorSystemError, "failed to load preferences")
+		return
+	}
+	out := DataNotificationPreferences{Items: make([]DataNotificationPreference, len(prefs))}
+	for i, p := range prefs {
+		out.Items[i] = DataNotificationPreference{
+			Category:  p.Category,
+			Push:      p.Push,
+			Email:     p.Email,
+			Mandatory: p.Category.Mandatory(),
+		}
+	}
+	Success(c, &out)
+}
+
+// api_get_notification_preferences 我的通知偏好
+//
+// GET /api/user/notification-preferences
+func api_get_notification_preferences(c *gin.Context) {
+	notificationPreferencesResponse(c, ReqUserID(c))
+}
+
+// api_update_notification_preferences 更新通知偏好
+//
+// PUT /api/user/notification-preferences
+func api_update_notification_preferences(c *gin.Context) {
+	userID := ReqUserID(c)
+	var req UpdateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, "invalid request body")
+		return
+	}
+	for _, item := range req.Items {
+		if !item.Category.Valid() {
+			Error(c, ErrorInvalidArgument, "unknown category: "+string(item.Category))
+			return
+		}
+		if item.Category.Mandatory() && (!item.Push || !item.Email) {
+			Error(c, ErrorInvalidArgument, string(item.Category)+" notifications cannot be turned off")
+			return
+		}
+	}
+	for _, item := range req.Items {
+		if item.Category.Mandatory() {
+			continue
+		}
+		if err := setNotificationPreference(userID, item.Category, item.Push, item.Email); err != nil {
+			log.Errorf(c, "failed to save notification preference for user %d: %v", userID, err)
+			Error(c, ErrorSystemError, "failed to save preferences")
+			return
+		}
+	}
+	log.Infof(c, "user %d updated notification preferences", userID)
+	notificationPreferencesResponse(c, userID)
+}
+
+// api_user_inbox 我的收件箱（最近 inboxKeepPerUser 条推送与邮件，新的在前）
+//
+// GET /api/user/inbox?page=1&pageSize=20
+func api_user_inbox(c *gin.Context) {
+	userID := ReqUserID(c)
+	pagination := PaginationFromRequest(c)
+
+	query := db.Get().Model(&InboxNotification{}).Where("user_id = ?", userID)
+	if err := query.Count(&pagination.Total).Error; err != nil {
+		log.Errorf(c, "api_user_inbox: failed to count: %v", err)
+		Error(c, ErrorSystemError, "failed to query inbox")
+		return
+	}
+	var items []InboxNotification
+	if err := query.Order("id DESC").Offset(pagination.Offset()).Limit(pagination.PageSize).
+		Find(&items).Error; err != nil {
+		log.Errorf(c, "api_user_inbox: failed to query: %v", err)
+		Error(c, ErrorSystemError, "failed to query inbox")
+		return
+	}
+	List(c, items, pagination)
+}
+
+// api_user_inbox_unread 收件箱未读数
+//
+// GET /api/user/inbox/unread
+func api_user_inbox_unread(c *gin.Context) {
+	var count int64
+	if err := db.Get().Model(&InboxNotification{}).
+		Where("user_id = ? AND read_at IS NULL", ReqUserID(c)).Count(&count).Error; err != nil {
+		log.Errorf(c, "api_user_inbox_unread: %v", err)
+		Error(c, ErrorSystemError, "failed to query inbox")
+		return
+	}
+	Success(c, &UnreadCountResponse{Unread: int(count)})
+}
+
+// api_user_inbox_read 标记收件箱已读
+//
+// POST /api/user/inbox/read   {"ids":[1,2]} 或 {"all":true}
+func api_user_inbox_read(c *gin.Context) {
+	userID := ReqUserID(c)
+	var req MarkInboxReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (!req.All && len(req.IDs) == 0) {
+		Error(c, ErrorInvalidArgument, "ids or all is required")
+		return
+	}
+	query := db.Get().Model(&InboxNotification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if !req.All {
+		query = query.Where("id IN ?", req.IDs)
+	}
+	if err := query.Update("read_at", time.Now()).Error; err != nil {
+		log.Errorf(c, "api_user_inbox_read: %v", err)
+		Error(c, ErrorSystemError, "failed to update inbox")
+		return
+	}
+	api_user_inbox_unread(c)
+}
@@ -0,0 +1,192 @@
+package center
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+func TestNotifyCategory(t *testing.T) {
+	assert.True(t, NotifyCategoryMarketing.Valid())
+	assert.False(t, NotifyCategory("spam").Valid())
+	assert.True(t, NotifyCategorySecurity.Mandatory())
+	assert.False(t, NotifyCategoryBilling.Mandatory())
+	assert.Equal(t, NotifyCategoryService, NotifyCategory("").orDefault())
+}
+
+func TestInboxTextFromHTML(t *testing.T) {
+	html := `<html><style>p{color:red}</style><body><p>您的订阅将于 <b>3 天</b>后到期&nbsp;&amp; 续费</p>
+	<a href="https://x">立即续费</a></body></html>`
+	assert.Equal(t, "您的订阅将于 3 天 后到期 & 续费 立即续费", inboxTextFromHTML(html))
+
+	long := inboxTextFromHTML(fmt.Sprintf("<p>%0600d</p>", 0))
+	assert.Len(t, []rune(long), inboxBodyMaxRunes)
+}
+
+func setupNotificationRouter() *gin.Engine {
+	r := SetupMinimalRouter()
+	r.GET("/api/user/notification-preferences", AuthRequired(), api_get_notification_preferences)
+	r.PUT("/api/user/notification-preferences", AuthRequired(), api_update_notification_preferences)
+	r.GET("/api/user/inbox", AuthRequired(), api_user_inbox)
+	r.GET("/api/user/inbox/unread", AuthRequired(), api_user_inbox_unread)
+	r.POST("/api/user/inbox/read", AuthRequired(), api_user_inbox_read)
+	return r
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	skipIfNoConfig(t)
+	r := setupNotificationRouter()
+	user := CreateTestUser(t)
+	token := GenerateTestToken(user.ID, "", time.Hour)
+	t.Cleanup(func() { db.Get().Where("user_id = ?", user.ID).Delete(&NotificationPreference{}) })
+	ctx := context.Background()
+
+	// Defaults: everything on.
+	w := NewTestRequest(http.MethodGet, "/api/user/notification-preferences").WithBearerToken(token).Execute(r)
+	prefs, err := ParseResponseData[DataNotificationPreferences](w)
+	require.NoError(t, err)
+	require.Len(t, prefs.Items, len(NotifyCategories))
+	for _, p := range prefs.Items {
+		assert.True(t, p.Push && p.Email, "%s should default on", p.Category)
+	}
+	assert.True(t, notificationAllowed(ctx, user.ID, NotifyCategoryMarketing, NotifyChannelEmail))
+
+	// Security cannot be muted.
+	w = NewTestRequest(http.MethodPut, "/api/user/notification-preferences").WithBearerToken(token).
+		WithBody(map[string]any{"items": []map[string]any{{"category": "security", "push": false, "email": true}}}).Execute(r)
+	resp, err := ParseResponse(w)
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorInvalidArgument), resp.Code)
+
+	// Mute marketing email, keep marketing push.
+	w = NewTestRequest(http.MethodPut, "/api/user/notification-preferences").WithBearerToken(token).
+		WithBody(map[string]any{"items": []map[string]any{{"category": "marketing", "push": true, "email": false}}}).Execute(r)
+	prefs, err = ParseResponseData[DataNotificationPreferences](w)
+	require.NoError(t, err)
+	assert.Equal(t, DataNotificationPreference{Category: NotifyCategoryMarketing, Push: true}, prefs.Items[3])
+	assert.False(t, notificationAllowed(ctx, user.ID, NotifyCategoryMarketing, NotifyChannelEmail))
+	assert.True(t, notificationAllowed(ctx, user.ID, NotifyCategoryMarketing, NotifyChannelPush))
+	assert.True(t, notificationAllowed(ctx, user.ID, NotifyCategorySecurity, NotifyChannelEmail))
+
+	// Update in place.
+	require.NoError(t, setNotificationPreference(user.ID, NotifyCategoryMarketing, false, true))
+	assert.True(t, notificationAllowed(ctx, user.ID, NotifyCategoryMarketing, NotifyChannelEmail))
+	assert.False(t, notificationAllowed(ctx, user.ID, NotifyCategoryMarketing, NotifyChannelPush))
+}
+
+func TestInbox(t *testing.T) {
+	skipIfNoConfig(t)
+	r := setupNotificationRouter()
+	user := CreateTestUser(t)
+	token := GenerateTestToken(user.ID, "", time.Hour)
+	t.Cleanup(func() { db.Get().Where("user_id = ?", user.ID).Delete(&InboxNotification{}) })
+	ctx := context.Background()
+
+	for i := 0; i < inboxKeepPerUser+5; i++ {
+		recordInbox(ctx, user.ID, NotifyCategoryBilling, NotifyChannelEmail, fmt.Sprintf("notice %d", i), "body", nil)
+	}
+	recordInbox(ctx, user.ID, "", NotifyChannelPush, "latest", "body", map[string]interface{}{"ticketId": 7})
+
+	var kept int64
+	db.Get().Model(&InboxNotification{}).Where("user_id = ?", user.ID).Count(&kept)
+	assert.Equal(t, int64(inboxKeepPerUser), kept)
+
+	w := NewTestRequest(http.MethodGet, "/api/user/inbox?pageSize=2").WithBearerToken(token).Execute(r)
+	list, err := ParseResponseData[ListResult[InboxNotification]](w)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	assert.Equal(t, "latest", list.Items[0].Title)
+	assert.Equal(t, NotifyCategoryService, list.Items[0].Category)
+	assert.JSONEq(t, `{"ticketId":7}`, list.Items[0].Data)
+	assert.Equal(t, int64(inboxKeepPerUser), list.Pagination.Total)
+
+	w = NewTestRequest(http.MethodPost, "/api/user/inbox/read").WithBearerToken(token).
+		WithBody(map[string]any{"ids": []uint64{list.Items[0].ID, list.Items[1].ID}}).Execute(r)
+	unread, err := ParseResponseData[UnreadCountResponse](w)
+	require.NoError(t, err)
+	assert.Equal(t, inboxKeepPerUser-2, unread.Unread)
+
+	// Another user's ids are not touched.
+	other := CreateTestUser(t)
+	otherToken := GenerateTestToken(other.ID, "", time.Hour)
+	w = NewTestRequest(http.MethodPost, "/api/user/inbox/read").WithBearerToken(otherToken).
+		WithBody(map[string]any{"all": true}).Execute(r)
+	unread, err = ParseResponseData[UnreadCountResponse](w)
+	require.NoError(t, err)
+	assert.Equal(t, 0, unread.Unread)
+
+	w = NewTestRequest(http.MethodGet, "/api/user/inbox/unread").WithBearerToken(token).Execute(r)
+	unread, err = ParseResponseData[UnreadCountResponse](w)
+	require.NoError(t, err)
+	assert.Equal(t, inboxKeepPerUser-2, unread.Unread)
+}
+
+func TestPushToUser_MutedCategoryStillReachesInbox(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	t.Cleanup(func() {
+		db.Get().Where("user_id = ?", user.ID).Delete(&InboxNotification{})
+		db.Get().Where("user_id = ?", user.ID).Delete(&NotificationPreference{})
+	})
+	require.NoError(t, setNotificationPreference(user.ID, NotifyCategoryMarketing, false, true))
+
+	// Muted: returns before looking up tokens or enqueueing.
+	err := PushToUser(context.Background(), user.ID, PushNotification{
+		Title: "50% off", Body: "This week only", Category: NotifyCategoryMarketing,
+	})
+	require.NoError(t, err)
+
+	var entries []InboxNotification
+	require.NoError(t, db.Get().Where("user_id = ?", user.ID).Find(&entries).Error)
+	require.Len(t, entries, 1)
+	assert.Equal(t, NotifyChannelPush, entries[0].Channel)
+	assert.Equal(t, "50% off", entries[0].Title)
+}
+
+// 关闭邮件通道只拦投递，收件箱照常记录；重跑批次不重复记录
+func TestSendTemplatedEmail_MutedStillRecordsInbox(t *testing.T) {
+	skipIfNoConfig(t)
+	ctx := context.Background()
+	user := CreateTestUser(t)
+	require.NoError(t, db.Get().First(user, user.ID).Error)
+	require.NoError(t, setNotificationPreference(user.ID, NotifyCategoryMarketing, true, false))
+
+	active := true
+	tmpl := EmailMarketingTemplate{
+		Name:     "muted-inbox",
+		Slug:     generateId("muted-inbox"),
+		Language: "zh-CN",
+		Subject:  "Spring sale",
+		Content:  "<p>20% off</p>",
+		IsActive: &active,
+		Brand:    user.Brand,
+	}
+	require.NoError(t, db.Get().Create(&tmpl).Error)
+	batchID := generateId("muted-batch")
+	t.Cleanup(func() {
+		db.Get().Unscoped().Delete(&tmpl)
+		db.Get().Where("batch_id = ?", batchID).Delete(&EmailSendLog{})
+		db.Get().Where("user_id = ?", user.ID).Delete(&InboxNotification{})
+		db.Get().Where("user_id = ?", user.ID).Delete(&NotificationPreference{})
+	})
+
+	item := &SendEmailItem{Email: "muted@example.com", UserID: user.ID, Slug: tmpl.Slug}
+	for i := 0; i < 2; i++ {
+		res := sendSingleTemplatedEmail(ctx, batchID, NotifyCategoryMarketing, item,
+			map[string]*EmailMarketingTemplate{}, map[string][]string{})
+		assert.Equal(t, "skipped", res.Status)
+	}
+
+	var inbox []InboxNotification
+	require.NoError(t, db.Get().Where("user_id = ?", user.ID).Find(&inbox).Error)
+	require.Len(t, inbox, 1)
+	assert.Equal(t, "Spring sale", inbox[0].Title)
+	assert.Equal(t, "20% off", inbox[0].Body)
+}
@@ -0,0 +1,368 @@
+package center
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"gorm.io/gorm"
+)
+
+// =====================================================================
+// 第三方登录 / 绑定接口，流程说明见 logic_oauth.go
+// =====================================================================
+
+// DataOAuthProviders 当前品牌可用的第三方登录
+type DataOAuthProviders struct {
+	Providers []string `json:"providers"` // google | apple | github，按展示顺序
+}
+
+// DataOAuthStart 发起授权的结果。前端保存 state，跳转 authorizeUrl。
+type DataOAuthStart struct {
+	AuthorizeURL string `json:"authorizeUrl"`
+	State        string `json:"state"`
+}
+
+// OAuthLoginRequest 用回调得到的 ticket 完成登录
+type OAuthLoginRequest struct {
+	Ticket     string `json:"ticket" binding:"required"`
+	State      string `json:"state" binding:"required"` // 发起授权时拿到的 state，证明是同一个浏览器
+	TOTPCode   string `json:"totpCode"`                 // 已启用两步验证时必填（验证码或恢复码）
+	Language   string `json:"language"`
+	InviteCode string `json:"inviteCode"`
+}
+
+// OAuthLinkRequest 用回调得到的 ticket 完成绑定
+type OAuthLinkRequest struct {
+	Ticket string `json:"ticket" binding:"required"`
+	State  string `json:"state" binding:"required"`
+}
+
+// DataOAuthIdentity 已绑定的第三方账号
+type DataOAuthIdentity struct {
+	Provider  string `json:"provider"`
+	Account   string `json:"account"` // provider 侧邮箱，没有时为 provider 用户 ID
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// requireOAuthProvider 取路径中的 provider，未知或当前品牌未配置时返回错误
+func requireOAuthProvider(c *gin.Context) (string, bool) {
+	provider := c.Param("provider")
+	if !isOAuthProvider(provider) || !configOAuthProvider(provider, ReqBrand(c)).Enabled(provider) {
+		Error(c, ErrorNotSupported, "login provider not available")
+		return "", false
+	}
+	return provider, true
+}
+
+// api_oauth_providers 当前品牌可用的第三方登录
+//
+// GET /api/auth/oauth/providers
+func api_oauth_providers(c *gin.Context) {
+	Success(c, &DataOAuthProviders{Providers: enabledOAuthProviders(ReqBrand(c))})
+}
+
+// api_oauth_start 发起第三方登录
+//
+// POST /api/auth/oauth/:provider/start
+func api_oauth_start(c *gin.Context) {
+	provider, ok := requireOAuthProvider(c)
+	if !ok {
+		return
+	}
+	authorizeURL, state, err := beginOAuth(c, provider, ReqBrand(c), oauthIntentLogin, 0)
+	if err != nil {
+		log.Errorf(c, "failed to start %s login: %v", provider, err)
+		Error(c, ErrorSystemError, "failed to start login")
+		return
+	}
+	Success(c, &DataOAuthStart{AuthorizeURL: authorizeURL, State: state})
+}
+
+// api_oauth_link_start 发起绑定第三方账号
+//
+// POST /api/user/oauth/:provider/link/start
+func api_oauth_link_start(c *gin.Context) {
+	provider, ok := requireOAuthProvider(c)
+	if !ok {
+		return
+	}
+	authorizeURL, state, err := beginOAuth(c, provider, ReqBrand(c), oauthIntentLink, ReqUserID(c))
+	if err != nil {
+		log.Errorf(c, "failed to start %s link for user %d: %v", provider, ReqUserID(c), err)
+		Error(c, ErrorSystemError, "failed to start linking")
+		return
+	}
+	Success(c, &DataOAuthStart{AuthorizeURL: authorizeURL, State: state})
+}
+
+// oauthCallbackParam 回调参数：GET 在 query，Apple form_post 在表单
+func oauthCallbackParam(c *gin.Context, key string) string {
+	if v := c.Query(key); v != "" {
+		return v
+	}
+	return c.PostForm(key)
+}
+
+// oauthCallbackRedirect 303 回品牌官网的完成页（locale 前缀由官网中间件补齐）
+func oauthCallbackRedirect(c *gin.Context, brand Brand, q url.Values) {
+	c.Redirect(http.StatusSeeOther, strings.TrimRight(brand.Config().BaseURL, "/")+"/login/oauth?"+q.Encode())
+}
+
+// api_oauth_callback provider 回跳：校验授权结果，换成一次性 ticket 交给官网完成页。
+// 这里不签发登录态——ticket 必须连同发起授权时的 state 一起提交才能使用。
+//
+// GET|POST /api/auth/oauth/:provider/callback
+func api_oauth_callback(c *gin.Context) {
+	provider := c.Param("provider")
+	state := oauthCallbackParam(c, "state")
+
+	// 用户取消授权（Google access_denied、Apple user_cancelled_authorize 等）
+	if reason := oauthCallbackParam(c, "error"); reason != "" {
+		brand := ReqBrand(c)
+		if st := takeOAuthState(state); st != nil {
+			brand = st.Brand
+		}
+		log.Infof(c, "%s authorization not completed: %s", provider, reason)
+		oauthCallbackRedirect(c, brand, url.Values{"error": {"cancelled"}})
+		return
+	}
+
+	st, id, err := finishOAuth(c, provider, state, oauthCallbackParam(c, "code"))
+	if st == nil {
+		log.Warnf(c, "%s callback with unknown or expired state: %v", provider, err)
+		oauthCallbackRedirect(c, ReqBrand(c), url.Values{"error": {"expired"}})
+		return
+	}
+	if err != nil {
+		if errors.Is(err, errOAuthInvalid) {
+			log.Warnf(c, "%s callback rejected: %v", provider, err)
+		} else {
+			log.Errorf(c, "%s callback failed: %v", provider, err)
+		}
+		oauthCallbackRedirect(c, st.Brand, url.Values{"error": {"failed"}})
+		return
+	}
+
+	ticket, err := issueOAuthTicket(oauthTicket{
+		State:    state,
+		Brand:    st.Brand,
+		Intent:   st.Intent,
+		UserID:   st.UserID,
+		Identity: *id,
+	})
+	if err != nil {
+		log.Errorf(c, "failed to issue %s ticket: %v", provider, err)
+		oauthCallbackRedirect(c, st.Brand, url.Values{"error": {"failed"}})
+		return
+	}
+	oauthCallbackRedirect(c, st.Brand, url.Values{"ticket": {ticket}})
+}
+
+// api_oauth_login 用 ticket 完成第三方登录，签发与其他 Web 登录方式相同的 Cookie。
+// 已启用两步验证的账号需带 totpCode；验证码错误时 ticket 保留，可在有效期内重试。
+//
+// POST /api/auth/web-login/oauth
+func api_oauth_login(c *gin.Context) {
+	var req OAuthLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+	ticket := peekOAuthTicket(req.Ticket, req.State)
+	if ticket == nil || ticket.Intent != oauthIntentLogin {
+		Error(c, ErrorInvalidCredentials, "login session expired, please sign in again")
+		return
+	}
+	brand := ReqBrand(c)
+	if ticket.Brand != brand {
+		log.Warnf(c, "oauth ticket of brand %s presented on brand %s", ticket.Brand, brand)
+		Error(c, ErrorBrandMismatch, "brand mismatch")
+		return
+	}
+	id := &ticket.Identity
+
+	user, linked, err := resolveOAuthUser(c, brand, id)
+	if err != nil {
+		log.Errorf(c, "failed to resolve %s identity: %v", id.Provider, err)
+		Error(c, ErrorSystemError, "login failed")
+		return
+	}
+	if user == nil && (!id.EmailVerified || id.Email == "") {
+		dropOAuthTicket(req.Ticket)
+		log.Infof(c, "%s login rejected: no verified email for new account", id.Provider)
+		Error(c, ErrorOAuthEmailUnverified, "no verified email on this account")
+		return
+	}
+	if user != nil {
+		if isUserBlocked(user) {
+			log.Warnf(c, "%s login rejected: user %d is blocked", id.Provider, user.ID)
+			Error(c, ErrorForbidden, "account blocked")
+			return
+		}
+		if err := verifySecondFactor(c, user, req.TOTPCode); err != nil {
+			ErrorE(c, err)
+			return
+		}
+	}
+	dropOAuthTicket(req.Ticket)
+
+	if user == nil {
+		user, err = FindOrCreateUserByEmail(c, id.Email, req.Language, c.GetHeader("Accept-Language"))
+		if err != nil {
+			log.Errorf(c, "failed to create user for %s login: %v", id.Provider, err)
+			Error(c, ErrorSystemError, "login failed")
+			return
+		}
+		log.Infof(c, "user %d signed up via %s", user.ID, id.Provider)
+	}
+
+	err = db.Get().Transaction(func(tx *gorm.DB) error {
+		if !linked {
+			if err := createOAuthIdentify(c, tx, user.ID, brand, id); err != nil {
+				return err
+			}
+		}
+		return applyWebLoginProfile(c, tx, user, id.Email, req.Language, req.InviteCode)
+	})
+	if err != nil {
+		log.Errorf(c, "failed to complete %s login for user %d: %v", id.Provider, user.ID, err)
+		ErrorE(c, err)
+		return
+	}
+
+	respondWebLogin(c, user, id.Provider)
+}
+
+// api_list_oauth_identities 当前用户已绑定的第三方账号
+//
+// GET /api/user/oauth
+func api_list_oauth_identities(c *gin.Context) {
+	userID := ReqUserID(c)
+	var identifies []LoginIdentify
+	if err := db.Get().Where("user_id = ? AND type IN ?", userID, oauthProviderNames).
+		Order("id").Find(&identifies).Error; err != nil {
+		log.Errorf(c, "failed to list oauth identities for user %d: %v", userID, err)
+		Error(c, ErrorSystemError, "failed to list linked accounts")
+		return
+	}
+	items := make([]DataOAuthIdentity, 0, len(identifies))
+	for _, identify := range identifies {
+		account, err := secretDecryptString(c, identify.EncryptedValue)
+		if err != nil {
+			log.Warnf(c, "failed to decrypt %s identity %d: %v", identify.Type, identify.ID, err)
+		}
+		items = append(items, DataOAuthIdentity{
+			Provider:  identify.Type,
+			Account:   account,
+			CreatedAt: identify.CreatedAt.Unix(),
+		})
+	}
+	ItemsAll(c, items)
+}
+
+// api_oauth_link 用 ticket 把第三方账号绑定到当前用户（每种 provider 一个）
+//
+// POST /api/user/oauth/link
+func api_oauth_link(c *gin.Context) {
+	var req OAuthLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+	userID := ReqUserID(c)
+	ticket := peekOAuthTicket(req.Ticket, req.State)
+	if ticket == nil || ticket.Intent != oauthIntentLink {
+		Error(c, ErrorInvalidCredentials, "link session expired, please try again")
+		return
+	}
+	if ticket.UserID != userID || ticket.Brand != ReqBrand(c) {
+		log.Warnf(c, "oauth link ticket of user %d presented by user %d", ticket.UserID, userID)
+		Error(c, ErrorForbidden, "link session does not belong to this account")
+		return
+	}
+	dropOAuthTicket(req.Ticket)
+	id := &ticket.Identity
+
+	existing, err := findOAuthIdentify(c, ticket.Brand, id)
+	if err != nil {
+		log.Errorf(c, "failed to look up %s identity: %v", id.Provider, err)
+		Error(c, ErrorSystemError, "failed to link account")
+		return
+	}
+	if existing != nil {
+		if existing.UserID == userID {
+			SuccessEmpty(c)
+			return
+		}
+		log.Warnf(c, "%s identity of user %d already linked to user %d", id.Provider, userID, existing.UserID)
+		Error(c, ErrorIdentityAlreadyLinked, "this account is already linked to another user")
+		return
+	}
+
+	var count int64
+	if err := db.Get().Model(&LoginIdentify{}).
+		Where("user_id = ? AND type = ?", userID, id.Provider).Count(&count).Error; err != nil {
+		log.Errorf(c, "failed to count %s identities of user %d: %v", id.Provider, userID, err)
+		Error(c, ErrorSystemError, "failed to link account")
+		return
+	}
+	if count > 0 {
+		Error(c, ErrorConflict, "another account of this provider is already linked")
+		return
+	}
+
+	if err := createOAuthIdentify(c, db.Get(), userID, ticket.Brand, id); err != nil {
+		log.Errorf(c, "failed to link %s identity to user %d: %v", id.Provider, userID, err)
+		Error(c, ErrorSystemError, "failed to link account")
+		return
+	}
+	SuccessEmpty(c)
+}
+
+// api_oauth_unlink 解绑第三方账号。不能解绑最后一种登录方式。
+//
+// DELETE /api/user/oauth/:provider
+func api_oauth_unlink(c *gin.Context) {
+	provider := c.Param("provider")
+	if !isOAuthProvider(provider) {
+		Error(c, ErrorInvalidArgument, "unknown provider")
+		return
+	}
+	userID := ReqUserID(c)
+
+	var identify LoginIdentify
+	if err := db.Get().Where("user_id = ? AND type = ?", userID, provider).First(&identify).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, ErrorNotFound, "account not linked")
+			return
+		}
+		log.Errorf(c, "failed to find %s identity of user %d: %v", provider, userID, err)
+		Error(c, ErrorSystemError, "failed to unlink account")
+		return
+	}
+
+	var others int64
+	if err := db.Get().Model(&LoginIdentify{}).
+		Where("user_id = ? AND id <> ?", userID, identify.ID).Count(&others).Error; err != nil {
+		log.Errorf(c, "failed to count identities of user %d: %v", userID, err)
+		Error(c, ErrorSystemError, "failed to unlink account")
+		return
+	}
+	if others == 0 {
+		Error(c, ErrorInvalidOperation, "cannot unlink the only sign-in method")
+		return
+	}
+
+	if err := db.Get().Delete(&identify).Error; err != nil {
+		log.Errorf(c, "failed to unlink %s identity of user %d: %v", provider, userID, err)
+		Error(c, ErrorSystemError, "failed to unlink account")
+		return
+	}
+	log.Infof(c, "user %d unlinked %s identity", userID, provider)
+	SuccessEmpty(c)
+}
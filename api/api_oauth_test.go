@@ -0,0 +1,460 @@
+package center
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+// mockOIDCIssuer 本地 OIDC 替身：discovery、JWKS、token 端点，id_token 用 RSA 签名。
+// 测试先登记授权码对应的 claims，token 端点校验 PKCE 后签发。
+type mockOIDCIssuer struct {
+	srv  *httptest.Server
+	key  *rsa.PrivateKey
+	mu   sync.Mutex
+	code map[string]mockOIDCGrant
+}
+
+type mockOIDCGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockOIDCIssuer(t *testing.T) *mockOIDCIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	m := &mockOIDCIssuer{key: key, code: map[string]mockOIDCGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                           m.srv.URL,
+			"authorization_endpoint":           m.srv.URL + "/authorize",
+			"token_endpoint":                   m.srv.URL + "/token",
+			"jwks_uri":                         m.srv.URL + "/jwks",
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "alg": "RS256", "use": "sig",
+			"n": webauthnB64.EncodeToString(key.N.Bytes()),
+			"e": webauthnB64.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		grant, ok := m.code[r.PostFormValue("code")]
+		delete(m.code, r.PostFormValue("code"))
+		m.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || webauthnB64.EncodeToString(sum[:]) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+		tok.Header["kid"] = "k1"
+		signed, err := tok.SignedString(key)
+		require.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "id_token": signed})
+	})
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+// authorize 模拟用户在 provider 页面同意授权：按授权地址中的参数登记授权码
+func (m *mockOIDCIssuer) authorize(t *testing.T, authorizeURL string, claims jwt.MapClaims) string {
+	t.Helper()
+	u, err := url.Parse(authorizeURL)
+	require.NoError(t, err)
+	q := u.Query()
+	require.Equal(t, m.srv.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	full := jwt.MapClaims{
+		"iss":   m.srv.URL,
+		"aud":   q.Get("client_id"),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for k, v := range claims {
+		full[k] = v
+	}
+	code := oauthRandom(12)
+	m.mu.Lock()
+	m.code[code] = mockOIDCGrant{challenge: q.Get("code_challenge"), claims: full}
+	m.mu.Unlock()
+	return code
+}
+
+// useMockGoogle 把 google 指向替身 issuer 并配置凭证
+func useMockGoogle(t *testing.T) *mockOIDCIssuer {
+	t.Helper()
+	testInitConfig()
+	m := newMockOIDCIssuer(t)
+	prev := oauthIssuers[oauthProviderGoogle]
+	oauthIssuers[oauthProviderGoogle] = m.srv.URL
+	viper.Set("oauth.google.client_id", "google-client")
+	viper.Set("oauth.google.client_secret", "google-secret")
+	t.Cleanup(func() {
+		oauthIssuers[oauthProviderGoogle] = prev
+		viper.Set("oauth.google.client_id", "")
+		viper.Set("oauth.google.client_secret", "")
+	})
+	return m
+}
+
+func setupOAuthRouter() *gin.Engine {
+	r := SetupMinimalRouter()
+	r.GET("/api/auth/oauth/providers", api_oauth_providers)
+	r.POST("/api/auth/oauth/:provider/start", api_oauth_start)
+	r.GET("/api/auth/oauth/:provider/callback", api_oauth_callback)
+	r.POST("/api/auth/oauth/:provider/callback", api_oauth_callback)
+	r.POST("/api/auth/web-login/oauth", api_oauth_login)
+	r.GET("/api/user/oauth", AuthRequired(), api_list_oauth_identities)
+	r.POST("/api/user/oauth/link", AuthRequired(), api_oauth_link)
+	r.POST("/api/user/oauth/:provider/link/start", AuthRequired(), api_oauth_link_start)
+	r.DELETE("/api/user/oauth/:provider", AuthRequired(), api_oauth_unlink)
+	return r
+}
+
+// oauthCallback 走完 start → provider 授权 → callback，返回 ticket 与 state
+func oauthCallback(t *testing.T, r *gin.Engine, m *mockOIDCIssuer, start *TestRequest, claims jwt.MapClaims) (ticket, state string) {
+	t.Helper()
+	data, err := ParseResponseData[DataOAuthStart](start.Execute(r))
+	require.NoError(t, err)
+	code := m.authorize(t, data.AuthorizeURL, claims)
+
+	w := NewTestRequest(http.MethodGet, "/api/auth/oauth/google/callback?"+
+		url.Values{"state": {data.State}, "code": {code}}.Encode()).Execute(r)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	loc, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/login/oauth", loc.Path)
+	require.Empty(t, loc.Query().Get("error"))
+	return loc.Query().Get("ticket"), data.State
+}
+
+func oauthLogin(t *testing.T, r *gin.Engine, body map[string]any) (*TestResponse, *httptest.ResponseRecorder) {
+	t.Helper()
+	w := NewTestRequest(http.MethodPost, "/api/auth/web-login/oauth").WithBody(body).Execute(r)
+	resp, err := ParseResponse(w)
+	require.NoError(t, err)
+	return resp, w
+}
+
+func uniqueOAuthEmail(prefix string) string {
+	return fmt.Sprintf("%s-%d@example.com", prefix, time.Now().UnixNano())
+}
+
+func TestOAuth_ProvidersRequireCredentials(t *testing.T) {
+	testInitConfig()
+	r := setupOAuthRouter()
+	data, err := ParseResponseData[DataOAuthProviders](NewTestRequest(http.MethodGet, "/api/auth/oauth/providers").Execute(r))
+	require.NoError(t, err)
+	assert.NotContains(t, data.Providers, oauthProviderGoogle)
+
+	resp, err := ParseResponse(NewTestRequest(http.MethodPost, "/api/auth/oauth/google/start").Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorNotSupported), resp.Code)
+
+	useMockGoogle(t)
+	data, err = ParseResponseData[DataOAuthProviders](NewTestRequest(http.MethodGet, "/api/auth/oauth/providers").Execute(r))
+	require.NoError(t, err)
+	assert.Contains(t, data.Providers, oauthProviderGoogle)
+
+	resp, err = ParseResponse(NewTestRequest(http.MethodPost, "/api/auth/oauth/weibo/start").Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorNotSupported), resp.Code)
+}
+
+func TestOAuth_CallbackRejectsBadState(t *testing.T) {
+	m := useMockGoogle(t)
+	r := setupOAuthRouter()
+	data, err := ParseResponseData[DataOAuthStart](NewTestRequest(http.MethodPost, "/api/auth/oauth/google/start").Execute(r))
+	require.NoError(t, err)
+	assert.Contains(t, data.AuthorizeURL, "code_challenge_method=S256")
+	code := m.authorize(t, data.AuthorizeURL, jwt.MapClaims{"sub": "g-1"})
+
+	errorOf := func(w *httptest.ResponseRecorder) string {
+		loc, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Empty(t, loc.Query().Get("ticket"))
+		return loc.Query().Get("error")
+	}
+
+	w := NewTestRequest(http.MethodGet, "/api/auth/oauth/google/callback?state=forged&code="+code).Execute(r)
+	assert.Equal(t, "expired", errorOf(w))
+
+	// provider 侧取消：state 一并作废
+	w = NewTestRequest(http.MethodGet, "/api/auth/oauth/google/callback?error=access_denied&state="+data.State).Execute(r)
+	assert.Equal(t, "cancelled", errorOf(w))
+	w = NewTestRequest(http.MethodGet, "/api/auth/oauth/google/callback?state="+data.State+"&code="+code).Execute(r)
+	assert.Equal(t, "expired", errorOf(w))
+}
+
+func TestOAuth_IDTokenValidation(t *testing.T) {
+	m := useMockGoogle(t)
+	ctx := context.Background()
+	meta, err := oidcMetadata(ctx, m.srv.URL)
+	require.NoError(t, err)
+
+	sign := func(claims jwt.MapClaims) string {
+		base := jwt.MapClaims{"iss": m.srv.URL, "aud": "google-client", "sub": "g-1", "nonce": "n1",
+			"exp": time.Now().Add(time.Minute).Unix()}
+		for k, v := range claims {
+			base[k] = v
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, base)
+		tok.Header["kid"] = "k1"
+		s, err := tok.SignedString(m.key)
+		require.NoError(t, err)
+		return s
+	}
+
+	claims, err := verifyIDToken(ctx, oauthProviderGoogle, meta, "google-client", "n1", sign(jwt.MapClaims{"email_verified": "true"}))
+	require.NoError(t, err)
+	assert.Equal(t, "g-1", claims.Subject)
+	assert.True(t, claims.emailVerified())
+
+	for name, bad := range map[string]jwt.MapClaims{
+		"nonce":   {"nonce": "other"},
+		"aud":     {"aud": "someone-else"},
+		"iss":     {"iss": "https://evil.example.com"},
+		"expired": {"exp": time.Now().Add(-time.Hour).Unix()},
+		"sub":     {"sub": ""},
+	} {
+		_, err := verifyIDToken(ctx, oauthProviderGoogle, meta, "google-client", "n1", sign(bad))
+		assert.ErrorIs(t, err, errOAuthInvalid, name)
+	}
+
+	// 其他密钥签名
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"iss": m.srv.URL, "aud": "google-client", "sub": "g-1",
+		"nonce": "n1", "exp": time.Now().Add(time.Minute).Unix()})
+	tok.Header["kid"] = "k1"
+	forged, err := tok.SignedString(other)
+	require.NoError(t, err)
+	_, err = verifyIDToken(ctx, oauthProviderGoogle, meta, "google-client", "n1", forged)
+	assert.ErrorIs(t, err, errOAuthInvalid)
+}
+
+func TestOAuth_LoginCreatesUserAndLinksIdentity(t *testing.T) {
+	skipIfNoConfig(t)
+	viper.Set("mail.dev_mode", true)
+	t.Cleanup(func() { viper.Set("mail.dev_mode", false) })
+	m := useMockGoogle(t)
+	r := setupOAuthRouter()
+	email := uniqueOAuthEmail("oauth-new")
+	sub := "g-new-" + oauthRandom(6)
+	start := NewTestRequest(http.MethodPost, "/api/auth/oauth/google/start")
+
+	ticket, state := oauthCallback(t, r, m, start, jwt.MapClaims{"sub": sub, "email": strings.ToUpper(email), "email_verified": true})
+
+	// ticket 必须配合发起授权的 state
+	resp, _ := oauthLogin(t, r, map[string]any{"ticket": ticket, "state": "someone-else"})
+	assert.Equal(t, int(ErrorInvalidCredentials), resp.Code)
+
+	resp, w := oauthLogin(t, r, map[string]any{"ticket": ticket, "state": state, "language": "en-US"})
+	require.Equal(t, 0, resp.Code, resp.Message)
+	AssertCookieExists(t, w, CookieAccessToken)
+	login, err := ParseResponseData[DataWebLoginResponse](w)
+	require.NoError(t, err)
+	assert.Equal(t, email, login.User.Email)
+	t.Cleanup(func() {
+		db.Get().Unscoped().Where("user_id = ?", login.User.ID).Delete(&LoginIdentify{})
+		db.Get().Unscoped().Delete(&User{}, login.User.ID)
+	})
+
+	var types []string
+	require.NoError(t, db.Get().Model(&LoginIdentify{}).Where("user_id = ?", login.User.ID).
+		Order("type").Pluck("type", &types).Error)
+	assert.Equal(t, []string{"email", "google"}, types)
+
+	// ticket 一次性
+	resp, _ = oauthLogin(t, r, map[string]any{"ticket": ticket, "state": state})
+	assert.Equal(t, int(ErrorInvalidCredentials), resp.Code)
+
+	// 再次登录走已绑定身份，即使 provider 侧邮箱已变更
+	ticket, state = oauthCallback(t, r, m, start, jwt.MapClaims{"sub": sub, "email": "changed@example.com", "email_verified": false})
+	resp, w = oauthLogin(t, r, map[string]any{"ticket": ticket, "state": state})
+	require.Equal(t, 0, resp.Code, resp.Message)
+	again, err := ParseResponseData[DataWebLoginResponse](w)
+	require.NoError(t, err)
+	assert.Equal(t, login.User.ID, again.User.ID)
+}
+
+func TestOAuth_LoginMatchesVerifiedEmailOnly(t *testing.T) {
+	skipIfNoConfig(t)
+	viper.Set("mail.dev_mode", true)
+	t.Cleanup(func() { viper.Set("mail.dev_mode", false) })
+	m := useMockGoogle(t)
+	r := setupOAuthRouter()
+	start := NewTestRequest(http.MethodPost, "/api/auth/oauth/google/start")
+
+	user, _ := seedWebPasswordLoginUser(t, "k7N#mq2P!xT9")
+	email := uniqueOAuthEmail("oauth-existing")
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	enc, err := secretEncryptString(c, email)
+	require.NoError(t, err)
+	identify := LoginIdentify{UserID: user.ID, Type: "email", IndexID: secretHashIt(c, []byte(email)), EncryptedValue: enc}
+	require.NoError(t, db.Get().Create(&identify).Error)
+	t.Cleanup(func() { db.Get().Unscoped().Where("user_id = ?", user.ID).Delete(&LoginIdentify{}) })
+
+	// 未验证邮箱既不能关联既有账号，也不能注册
+	ticket, state := oauthCallback(t, r, m, start, jwt.MapClaims{"sub": "g-unverified-" + oauthRandom(6), "email": email, "email_verified": false})
+	resp, _ := oauthLogin(t, r, map[string]any{"ticket": ticket, "state": state})
+	assert.Equal(t, int(ErrorOAuthEmailUnverified), resp.Code)
+
+	// 启用两步验证的账号需要验证码；输错后 ticket 仍可重试
+	recovery, hashes, err := generateRecoveryCodes(context.Background())
+	require.NoError(t, err)
+	user.TOTPEnabledAt, user.TOTPRecoveryCodes = 1, hashes
+	require.NoError(t, saveTwoFactorState(&user))
+
+	sub := "g-existing-" + oauthRandom(6)
+	ticket, state = oauthCallback(t, r, m, start, jwt.MapClaims{"sub": sub, "email": email, "email_verified": true})
+	resp, _ = oauthLogin(t, r, map[string]any{"ticket": ticket, "state": state})
+	assert.Equal(t, int(ErrorTwoFactorRequired), resp.Code)
+	resp, w := oauthLogin(t, r, map[string]any{"ticket": ticket, "state": state, "totpCode": recovery[0]})
+	require.Equal(t, 0, resp.Code, resp.Message)
+	login, err := ParseResponseData[DataWebLoginResponse](w)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, login.User.ID)
+
+	var linked LoginIdentify
+	require.NoError(t, db.Get().Where("user_id = ? AND type = ?", user.ID, oauthProviderGoogle).First(&linked).Error)
+	assert.Equal(t, secretHashIt(c, []byte(sub)), linked.IndexID)
+}
+
+func TestOAuth_LinkAndUnlink(t *testing.T) {
+	skipIfNoConfig(t)
+	m := useMockGoogle(t)
+	r := setupOAuthRouter()
+	user, _ := seedWebPasswordLoginUser(t, "k7N#mq2P!xT9")
+	token := GenerateTestToken(user.ID, "", time.Hour)
+	t.Cleanup(func() { db.Get().Unscoped().Where("user_id = ?", user.ID).Delete(&LoginIdentify{}) })
+	linkStart := func(tok string) *TestRequest {
+		return NewTestRequest(http.MethodPost, "/api/user/oauth/google/link/start").WithBearerToken(tok)
+	}
+	link := func(tok, ticket, state string) int {
+		resp, err := ParseResponse(NewTestRequest(http.MethodPost, "/api/user/oauth/link").WithBearerToken(tok).
+			WithBody(map[string]any{"ticket": ticket, "state": state}).Execute(r))
+		require.NoError(t, err)
+		return resp.Code
+	}
+
+	// 绑定 ticket 不能用于登录
+	sub := "g-link-" + oauthRandom(6)
+	ticket, state := oauthCallback(t, r, m, linkStart(token), jwt.MapClaims{"sub": sub})
+	resp, _ := oauthLogin(t, r, map[string]any{"ticket": ticket, "state": state})
+	assert.Equal(t, int(ErrorInvalidCredentials), resp.Code)
+
+	// 别人拿到 ticket 也绑不上
+	stranger := CreateTestUser(t)
+	strangerToken := GenerateTestToken(stranger.ID, "", time.Hour)
+	assert.Equal(t, int(ErrorForbidden), link(strangerToken, ticket, state))
+
+	assert.Equal(t, 0, link(token, ticket, state))
+	w := NewTestRequest(http.MethodGet, "/api/user/oauth").WithBearerToken(token).Execute(r)
+	list, err := ParseResponseData[ListResult[DataOAuthIdentity]](w)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, oauthProviderGoogle, list.Items[0].Provider)
+	assert.Equal(t, sub, list.Items[0].Account)
+
+	// 同一 provider 只能绑一个账号；已绑定给别人的身份不能再绑
+	ticket, state = oauthCallback(t, r, m, linkStart(token), jwt.MapClaims{"sub": "g-second-" + oauthRandom(6)})
+	assert.Equal(t, int(ErrorConflict), link(token, ticket, state))
+	ticket, state = oauthCallback(t, r, m, linkStart(strangerToken), jwt.MapClaims{"sub": sub})
+	assert.Equal(t, int(ErrorIdentityAlreadyLinked), link(strangerToken, ticket, state))
+
+	resp, err = ParseResponse(NewTestRequest(http.MethodDelete, "/api/user/oauth/google").WithBearerToken(token).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, 0, resp.Code)
+
+	// 唯一的登录方式不能解绑
+	ticket, state = oauthCallback(t, r, m, linkStart(strangerToken), jwt.MapClaims{"sub": sub})
+	assert.Equal(t, 0, link(strangerToken, ticket, state))
+	t.Cleanup(func() { db.Get().Unscoped().Where("user_id = ?", stranger.ID).Delete(&LoginIdentify{}) })
+	resp, err = ParseResponse(NewTestRequest(http.MethodDelete, "/api/user/oauth/google").WithBearerToken(strangerToken).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorInvalidOperation), resp.Code)
+}
+
+func TestOAuth_GitHubIdentity(t *testing.T) {
+	testInitConfig()
+	var gotVerifier string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		gotVerifier = r.PostFormValue("code_verifier")
+		if r.PostFormValue("code") != "gh-code" {
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "gh-token"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": 4242, "email": nil})
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]map[string]any{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "Octo@Example.com", "primary": true, "verified": true},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	prevOAuth, prevAPI := githubOAuthURL, githubAPIURL
+	githubOAuthURL, githubAPIURL = srv.URL, srv.URL
+	viper.Set("oauth.github.client_id", "gh-client")
+	viper.Set("oauth.github.client_secret", "gh-secret")
+	t.Cleanup(func() {
+		githubOAuthURL, githubAPIURL = prevOAuth, prevAPI
+		viper.Set("oauth.github.client_id", "")
+		viper.Set("oauth.github.client_secret", "")
+	})
+
+	ctx := context.Background()
+	authorizeURL, state, err := beginOAuth(ctx, oauthProviderGitHub, BrandKaitu, oauthIntentLogin, 0)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(authorizeURL, srv.URL+"/login/oauth/authorize?"))
+
+	st, id, err := finishOAuth(ctx, oauthProviderGitHub, state, "gh-code")
+	require.NoError(t, err)
+	assert.Equal(t, BrandKaitu, st.Brand)
+	assert.NotEmpty(t, gotVerifier)
+	assert.Equal(t, &oauthIdentity{Provider: oauthProviderGitHub, Subject: "4242", Email: "octo@example.com", EmailVerified: true}, id)
+
+	// state 一次性
+	st, _, err = finishOAuth(ctx, oauthProviderGitHub, state, "gh-code")
+	assert.Nil(t, st)
+	assert.ErrorIs(t, err, errOAuthInvalid)
+
+	_, state, err = beginOAuth(ctx, oauthProviderGitHub, BrandKaitu, oauthIntentLogin, 0)
+	require.NoError(t, err)
+	_, _, err = finishOAuth(ctx, oauthProviderGitHub, state, "wrong")
+	assert.ErrorIs(t, err, errOAuthInvalid)
+}
@@ -0,0 +1,416 @@
+package center
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"github.com/wordgate/qtoolkit/util"
+)
+
+// =====================================================================
+// Passkey 登记 / 管理（已登录）与 passkey 登录（公开）
+// =====================================================================
+//
+// options 接口返回的结构与 PublicKeyCredentialCreationOptionsJSON /
+// PublicKeyCredentialRequestOptionsJSON 一致（二进制字段均为 base64url），
+// 前端可直接交给 PublicKeyCredential.parse*OptionsFromJSON。
+
+// webauthnTimeoutMs 浏览器端 ceremony 超时，与 challenge TTL 一致
+const webauthnTimeoutMs = webauthnChallengeTTL * 1000
+
+type DataPasskeyCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type DataPasskeyRP struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type DataPasskeyUser struct {
+	ID          string `json:"id"` // user handle：base64url(user UUID)，不含个人信息
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type DataPasskeyPubKeyParam struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type DataPasskeyAuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// DataPasskeyCreationOptions navigator.credentials.create() 参数
+type DataPasskeyCreationOptions struct {
+	Challenge              string                            `json:"challenge"`
+	RP                     DataPasskeyRP                     `json:"rp"`
+	User                   DataPasskeyUser                   `json:"user"`
+	PubKeyCredParams       []DataPasskeyPubKeyParam          `json:"pubKeyCredParams"`
+	Timeout                int                               `json:"timeout"`
+	Attestation            string                            `json:"attestation"`
+	AuthenticatorSelection DataPasskeyAuthenticatorSelection `json:"authenticatorSelection"`
+	ExcludeCredentials     []DataPasskeyCredentialDescriptor `json:"excludeCredentials"`
+}
+
+// DataPasskeyRequestOptions navigator.credentials.get() 参数
+type DataPasskeyRequestOptions struct {
+	Challenge        string                            `json:"challenge"`
+	RPID             string                            `json:"rpId"`
+	Timeout          int                               `json:"timeout"`
+	UserVerification string                            `json:"userVerification"`
+	AllowCredentials []DataPasskeyCredentialDescriptor `json:"allowCredentials"`
+}
+
+// PasskeyRegisterRequest 登记结果（AuthenticatorAttestationResponse）
+type PasskeyRegisterRequest struct {
+	ID                string   `json:"id" binding:"required"`
+	ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+	AttestationObject string   `json:"attestationObject" binding:"required"`
+	Transports        []string `json:"transports"`
+	Remark            string   `json:"remark"` // 设备备注，如 "MacBook Touch ID"
+}
+
+// PasskeyLoginRequest 断言结果（AuthenticatorAssertionResponse）
+type PasskeyLoginRequest struct {
+	ID                string `json:"id" binding:"required"`
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+// UpdatePasskeyRequest 修改备注
+type UpdatePasskeyRequest struct {
+	Remark string `json:"remark" binding:"required"`
+}
+
+const passkeyRemarkMaxLen = 100
+
+// passkeyUserHandle user handle 使用 UUID，登录时据此交叉校验凭证归属
+func passkeyUserHandle(user *User) string {
+	return webauthnB64.EncodeToString([]byte(user.UUID))
+}
+
+// normalizePasskeyRemark 去空白并按字符截断；空备注回退为 fallback
+func normalizePasskeyRemark(remark, fallback string) string {
+	remark = strings.TrimSpace(remark)
+	if remark == "" {
+		remark = fallback
+	}
+	if utf8.RuneCountInString(remark) > passkeyRemarkMaxLen {
+		remark = string([]rune(remark)[:passkeyRemarkMaxLen])
+	}
+	return remark
+}
+
+// requireWebauthnRP 解析 RP；非品牌站点直接回错误
+func requireWebauthnRP(c *gin.Context) (*webauthnRP, bool) {
+	rp, ok := resolveWebauthnRP(c)
+	if !ok {
+		log.Warnf(c, "passkey request from non-brand host %q (origin %q)", c.Request.Host, c.GetHeader("Origin"))
+		Error(c, ErrorNotSupported, "passkeys are only available on the brand website")
+		return nil, false
+	}
+	return rp, true
+}
+
+// api_passkey_register_options 开始登记
+//
+// POST /api/user/passkeys/register/options
+func api_passkey_register_options(c *gin.Context) {
+	user := ReqUser(c)
+	rp, ok := requireWebauthnRP(c)
+	if !ok {
+		return
+	}
+	if Brand(user.Brand) != rp.Brand {
+		Error(c, ErrorBrandMismatch, "account belongs to a different brand")
+		return
+	}
+
+	var existing []PasskeyCredential
+	if err := db.Get().Where("user_id = ?", user.ID).Find(&existing).Error; err != nil {
+		log.Errorf(c, "failed to list passkeys for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to list passkeys")
+		return
+	}
+	if len(existing) >= webauthnMaxCredentials {
+		Error(c, ErrorInvalidOperation, "too many passkeys")
+		return
+	}
+
+	challenge, err := issueWebauthnChallenge(webauthnChallenge{Purpose: webauthnPurposeRegister, Brand: rp.Brand, UserID: user.ID})
+	if err != nil {
+		log.Errorf(c, "failed to issue passkey challenge for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to start registration")
+		return
+	}
+
+	name, err := getUserEmail(c, user.ID)
+	if err != nil {
+		name = user.UUID
+	}
+	exclude := make([]DataPasskeyCredentialDescriptor, 0, len(existing))
+	for i := range existing {
+		exclude = append(exclude, DataPasskeyCredentialDescriptor{
+			Type:       "public-key",
+			ID:         existing[i].CredentialID,
+			Transports: existing[i].TransportList(),
+		})
+	}
+
+	Success(c, &DataPasskeyCreationOptions{
+		Challenge: challenge,
+		RP:        DataPasskeyRP{ID: rp.ID, Name: rp.Name},
+		User:      DataPasskeyUser{ID: passkeyUserHandle(user), Name: name, DisplayName: name},
+		PubKeyCredParams: []DataPasskeyPubKeyParam{
+			{Type: "public-key", Alg: coseAlgES256},
+			{Type: "public-key", Alg: coseAlgEdDSA},
+			{Type: "public-key", Alg: coseAlgRS256},
+		},
+		Timeout:     webauthnTimeoutMs,
+		Attestation: "none",
+		AuthenticatorSelection: DataPasskeyAuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		ExcludeCredentials: exclude,
+	})
+}
+
+// api_passkey_register 完成登记
+//
+// POST /api/user/passkeys/register
+func api_passkey_register(c *gin.Context) {
+	user := ReqUser(c)
+	var req PasskeyRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+	rp, ok := requireWebauthnRP(c)
+	if !ok {
+		return
+	}
+
+	reg, err := verifyWebauthnRegistration(rp, req.ID, req.ClientDataJSON, req.AttestationObject)
+	if err != nil {
+		log.Warnf(c, "passkey registration rejected for user %d: %v", user.ID, err)
+		Error(c, ErrorInvalidArgument, "invalid passkey registration")
+		return
+	}
+	if reg.UserID != user.ID {
+		log.Warnf(c, "passkey challenge of user %d submitted by user %d", reg.UserID, user.ID)
+		Error(c, ErrorInvalidArgument, "invalid passkey registration")
+		return
+	}
+
+	var dup int64
+	if err := db.Get().Model(&PasskeyCredential{}).Where("credential_id = ?", reg.CredentialID).Count(&dup).Error; err != nil {
+		log.Errorf(c, "failed to check passkey uniqueness: %v", err)
+		Error(c, ErrorSystemError, "failed to save passkey")
+		return
+	}
+	if dup > 0 {
+		Error(c, ErrorConflict, "passkey already registered")
+		return
+	}
+
+	cred := &PasskeyCredential{
+		UserID:       user.ID,
+		Brand:        string(rp.Brand),
+		CredentialID: reg.CredentialID,
+		PublicKey:    reg.PublicKey,
+		Algorithm:    reg.Algorithm,
+		SignCount:    reg.SignCount,
+		AAGUID:       reg.AAGUID,
+		Transports:   strings.Join(req.Transports, ","),
+		Synced:       reg.Synced,
+		Remark:       normalizePasskeyRemark(req.Remark, "Passkey"),
+	}
+	if len(cred.Transports) > 100 {
+		cred.Transports = ""
+	}
+	if err := db.Get().Create(cred).Error; err != nil {
+		log.Errorf(c, "failed to save passkey for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to save passkey")
+		return
+	}
+	log.Infof(c, "user %d registered passkey %d (alg %d, synced %v)", user.ID, cred.ID, cred.Algorithm, cred.Synced)
+	Success(c, cred)
+}
+
+// api_list_passkeys 我的 passkey
+//
+// GET /api/user/passkeys
+func api_list_passkeys(c *gin.Context) {
+	userID := ReqUserID(c)
+	var creds []PasskeyCredential
+	if err := db.Get().Where("user_id = ?", userID).Order("id DESC").Find(&creds).Error; err != nil {
+		log.Errorf(c, "failed to list passkeys for user %d: %v", userID, err)
+		Error(c, ErrorSystemError, "failed to list passkeys")
+		return
+	}
+	ItemsAll(c, creds)
+}
+
+// api_update_passkey 修改备注
+//
+// PUT /api/user/passkeys/:id
+func api_update_passkey(c *gin.Context) {
+	userID := ReqUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		Error(c, ErrorInvalidArgument, "invalid id")
+		return
+	}
+	var req UpdatePasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+	remark := normalizePasskeyRemark(req.Remark, "")
+	if remark == "" {
+		Error(c, ErrorInvalidArgument, "remark is required")
+		return
+	}
+
+	result := db.Get().Model(&PasskeyCredential{}).Where("id = ? AND user_id = ?", id, userID).Update("remark", remark)
+	if result.Error != nil {
+		log.Errorf(c, "failed to update passkey %d for user %d: %v", id, userID, result.Error)
+		Error(c, ErrorSystemError, "failed to update passkey")
+		return
+	}
+	if result.RowsAffected == 0 {
+		Error(c, ErrorNotFound, "passkey not found")
+		return
+	}
+	SuccessEmpty(c)
+}
+
+// api_delete_passkey 删除 passkey
+//
+// DELETE /api/user/passkeys/:id
+func api_delete_passkey(c *gin.Context) {
+	userID := ReqUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		Error(c, ErrorInvalidArgument, "invalid id")
+		return
+	}
+	result := db.Get().Where("id = ? AND user_id = ?", id, userID).Delete(&PasskeyCredential{})
+	if result.Error != nil {
+		log.Errorf(c, "failed to delete passkey %d for user %d: %v", id, userID, result.Error)
+		Error(c, ErrorSystemError, "failed to delete passkey")
+		return
+	}
+	if result.RowsAffected == 0 {
+		Error(c, ErrorNotFound, "passkey not found")
+		return
+	}
+	log.Infof(c, "user %d deleted passkey %d", userID, id)
+	SuccessEmpty(c)
+}
+
+// api_passkey_login_options 开始 passkey 登录（discoverable credential，不需要邮箱）
+//
+// POST /api/auth/web-login/passkey/options
+func api_passkey_login_options(c *gin.Context) {
+	rp, ok := requireWebauthnRP(c)
+	if !ok {
+		return
+	}
+	challenge, err := issueWebauthnChallenge(webauthnChallenge{Purpose: webauthnPurposeLogin, Brand: rp.Brand})
+	if err != nil {
+		log.Errorf(c, "failed to issue passkey login challenge: %v", err)
+		Error(c, ErrorSystemError, "failed to start login")
+		return
+	}
+	Success(c, &DataPasskeyRequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          webauthnTimeoutMs,
+		UserVerification: "required",
+		AllowCredentials: []DataPasskeyCredentialDescriptor{},
+	})
+}
+
+// api_passkey_login 完成 passkey 登录，签发与其他 Web 登录方式相同的 Cookie
+//
+// POST /api/auth/web-login/passkey
+func api_passkey_login(c *gin.Context) {
+	var req PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+	rp, ok := requireWebauthnRP(c)
+	if !ok {
+		return
+	}
+
+	var cred PasskeyCredential
+	if err := db.Get().Where("credential_id = ? AND brand = ?", strings.TrimRight(req.ID, "="), string(rp.Brand)).First(&cred).Error; err != nil {
+		if util.DbIsNotFoundErr(err) {
+			log.Warnf(c, "passkey login with unknown credential")
+			Error(c, ErrorInvalidCredentials, "unknown passkey")
+			return
+		}
+		log.Errorf(c, "failed to find passkey: %v", err)
+		Error(c, ErrorSystemError, "login failed")
+		return
+	}
+
+	var user User
+	if err := db.Get().First(&user, cred.UserID).Error; err != nil {
+		log.Errorf(c, "failed to load user %d of passkey %d: %v", cred.UserID, cred.ID, err)
+		Error(c, ErrorInvalidCredentials, "unknown passkey")
+		return
+	}
+	if req.UserHandle != "" && strings.TrimRight(req.UserHandle, "=") != passkeyUserHandle(&user) {
+		log.Warnf(c, "passkey %d user handle does not match user %d", cred.ID, user.ID)
+		Error(c, ErrorInvalidCredentials, "invalid passkey assertion")
+		return
+	}
+
+	signCount, err := verifyWebauthnAssertion(rp, &cred, req.ClientDataJSON, req.AuthenticatorData, req.Signature)
+	if err != nil {
+		if !errors.Is(err, errWebauthnInvalid) {
+			log.Errorf(c, "passkey %d verification error: %v", cred.ID, err)
+			Error(c, ErrorSystemError, "login failed")
+			return
+		}
+		log.Warnf(c, "passkey login rejected for user %d (passkey %d): %v", user.ID, cred.ID, err)
+		Error(c, ErrorInvalidCredentials, "invalid passkey assertion")
+		return
+	}
+
+	if isUserBlocked(&user) {
+		log.Warnf(c, "passkey login rejected: user %d is blocked", user.ID)
+		Error(c, ErrorForbidden, "account blocked")
+		return
+	}
+
+	if err := db.Get().Model(&cred).Updates(map[string]any{
+		"sign_count":   signCount,
+		"last_used_at": time.Now().Unix(),
+	}).Error; err != nil {
+		log.Errorf(c, "failed to update passkey %d usage: %v", cred.ID, err)
+	}
+
+	respondWebLogin(c, &user, fmt.Sprintf("passkey %d", cred.ID))
+}
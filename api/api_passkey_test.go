@@ -0,0 +1,131 @@
+package center
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+func setupPasskeyRouter() *gin.Engine {
+	r := SetupMinimalRouter()
+	r.GET("/api/user/passkeys", AuthRequired(), api_list_passkeys)
+	r.POST("/api/user/passkeys/register/options", AuthRequired(), api_passkey_register_options)
+	r.POST("/api/user/passkeys/register", AuthRequired(), api_passkey_register)
+	r.PUT("/api/user/passkeys/:id", AuthRequired(), api_update_passkey)
+	r.DELETE("/api/user/passkeys/:id", AuthRequired(), api_delete_passkey)
+	r.POST("/api/auth/web-login/passkey/options", api_passkey_login_options)
+	r.POST("/api/auth/web-login/passkey", api_passkey_login)
+	return r
+}
+
+func TestPasskey_RegisterLoginDelete(t *testing.T) {
+	skipIfNoConfig(t)
+	viper.Set("mail.dev_mode", true)
+	t.Cleanup(func() { viper.Set("mail.dev_mode", false) })
+
+	user, _ := seedWebPasswordLoginUser(t, "k7N#mq2P!xT9")
+	token := GenerateTestToken(user.ID, "", time.Hour)
+	r := setupPasskeyRouter()
+	origin := BrandKaitu.Config().WebOrigins[0]
+	auth := newSoftAuthenticator(t, "kaitu.io")
+
+	// 非品牌站点不提供 passkey
+	resp, err := ParseResponse(NewTestRequest(http.MethodPost, "/api/user/passkeys/register/options").
+		WithBearerToken(token).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorNotSupported), resp.Code)
+
+	w := NewTestRequest(http.MethodPost, "/api/user/passkeys/register/options").
+		WithBearerToken(token).WithHeader("Origin", origin).Execute(r)
+	opts, err := ParseResponseData[DataPasskeyCreationOptions](w)
+	require.NoError(t, err)
+	assert.Equal(t, "kaitu.io", opts.RP.ID)
+	assert.Equal(t, passkeyUserHandle(&user), opts.User.ID)
+	assert.Empty(t, opts.ExcludeCredentials)
+
+	cd, att := auth.create(opts.Challenge, origin)
+	w = NewTestRequest(http.MethodPost, "/api/user/passkeys/register").WithBearerToken(token).
+		WithHeader("Origin", origin).
+		WithBody(map[string]any{
+			"id": auth.id(), "clientDataJSON": cd, "attestationObject": att,
+			"transports": []string{"internal", "hybrid"}, "remark": "  MacBook Touch ID ",
+		}).Execute(r)
+	cred, err := ParseResponseData[PasskeyCredential](w)
+	require.NoError(t, err)
+	assert.Equal(t, "MacBook Touch ID", cred.Remark)
+	assert.Equal(t, auth.id(), cred.CredentialID)
+
+	// 已登记的凭证出现在 excludeCredentials 中
+	w = NewTestRequest(http.MethodPost, "/api/user/passkeys/register/options").
+		WithBearerToken(token).WithHeader("Origin", origin).Execute(r)
+	opts, err = ParseResponseData[DataPasskeyCreationOptions](w)
+	require.NoError(t, err)
+	require.Len(t, opts.ExcludeCredentials, 1)
+	assert.Equal(t, []string{"internal", "hybrid"}, opts.ExcludeCredentials[0].Transports)
+
+	login := func() *TestResponse {
+		w := NewTestRequest(http.MethodPost, "/api/auth/web-login/passkey/options").WithHeader("Origin", origin).Execute(r)
+		req, err := ParseResponseData[DataPasskeyRequestOptions](w)
+		require.NoError(t, err)
+		assert.Equal(t, "kaitu.io", req.RPID)
+		cd, ad, sig := auth.get(t, req.Challenge, origin)
+		w = NewTestRequest(http.MethodPost, "/api/auth/web-login/passkey").WithHeader("Origin", origin).
+			WithBody(map[string]any{
+				"id": auth.id(), "clientDataJSON": cd, "authenticatorData": ad, "signature": sig,
+				"userHandle": passkeyUserHandle(&user),
+			}).Execute(r)
+		resp, err := ParseResponse(w)
+		require.NoError(t, err)
+		if resp.Code == 0 {
+			AssertCookieExists(t, w, CookieAccessToken)
+		}
+		return resp
+	}
+
+	assert.Equal(t, 0, login().Code)
+	var stored PasskeyCredential
+	require.NoError(t, db.Get().First(&stored, cred.ID).Error)
+	assert.Equal(t, uint32(1), stored.SignCount)
+	assert.NotZero(t, stored.LastUsedAt)
+
+	// 删除后不能再登录
+	w = NewTestRequest(http.MethodDelete, fmt.Sprintf("/api/user/passkeys/%d", cred.ID)).WithBearerToken(token).Execute(r)
+	resp, err = ParseResponse(w)
+	require.NoError(t, err)
+	assert.Equal(t, 0, resp.Code)
+	assert.Equal(t, int(ErrorInvalidCredentials), login().Code)
+}
+
+func TestPasskey_CannotTouchOthersCredentials(t *testing.T) {
+	skipIfNoConfig(t)
+	owner := CreateTestUser(t)
+	other := CreateTestUser(t)
+	cred := &PasskeyCredential{
+		UserID: owner.ID, Brand: string(BrandKaitu), CredentialID: "cred-" + owner.UUID,
+		PublicKey: "x", Algorithm: coseAlgES256, Remark: "YubiKey",
+	}
+	require.NoError(t, db.Get().Create(cred).Error)
+	r := setupPasskeyRouter()
+	token := GenerateTestToken(other.ID, "", time.Hour)
+
+	path := fmt.Sprintf("/api/user/passkeys/%d", cred.ID)
+	resp, err := ParseResponse(NewTestRequest(http.MethodPut, path).WithBearerToken(token).
+		WithBody(map[string]string{"remark": "mine now"}).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorNotFound), resp.Code)
+
+	resp, err = ParseResponse(NewTestRequest(http.MethodDelete, path).WithBearerToken(token).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorNotFound), resp.Code)
+
+	var stored PasskeyCredential
+	require.NoError(t, db.Get().First(&stored, cred.ID).Error)
+	assert.Equal(t, "YubiKey", stored.Remark)
+}
@@ -230,3 +230,10 @@ func isValidPlatformProviderCombination(platform, provider, flavor string) bool
 	}
 	return false
 }
+
+// api_admin_push_metrics 各推送通道的投递指标（进程内累计）
+// GET /app/push/metrics
+func api_admin_push_metrics(c *gin.Context) {
+	items := PushMetricsSnapshot()
+	ListWithData(c, items, &Pagination{Total: int64(len(items))})
+}
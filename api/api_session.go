@@ -0,0 +1,135 @@
+package center
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"gorm.io/gorm"
+)
+
+// DataAuthSession 会话列表项
+type DataAuthSession struct {
+	ID           uint64 `json:"id"`
+	ClientClass  string `json:"clientClass"`            // web | service | router
+	Platform     string `json:"platform,omitempty"`     // 设备会话：macos / ios / router 等
+	DeviceRemark string `json:"deviceRemark,omitempty"` // 设备会话：设备备注
+	IPCountry    string `json:"ipCountry"`              // 最近一次活跃时的 IP 国家（ISO 3166-1 alpha-2 小写）
+	CreatedAt    int64  `json:"createdAt"`              // 登录时间
+	LastSeenAt   int64  `json:"lastSeenAt"`             // 最近活跃（约 5 分钟精度）
+	Current      bool   `json:"current"`                // 是否为发起本次请求的会话
+}
+
+// api_list_sessions 当前用户的有效会话
+func api_list_sessions(c *gin.Context) {
+	userID := ReqUserID(c)
+
+	var sessions []AuthSession
+	if err := db.Get().Where("user_id = ? AND revoked_at = 0", userID).
+		Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		log.Errorf(c, "failed to list sessions for user %d: %v", userID, err)
+		Error(c, ErrorSystemError, "failed to list sessions")
+		return
+	}
+
+	// 设备会话随设备删除（登出、被踢、手动删除）而结束，不再展示
+	var devices []Device
+	if err := db.Get().Where("user_id = ?", userID).Find(&devices).Error; err != nil {
+		log.Errorf(c, "failed to load devices for user %d: %v", userID, err)
+		Error(c, ErrorSystemError, "failed to list sessions")
+		return
+	}
+	deviceByUDID := make(map[string]*Device, len(devices))
+	for i := range devices {
+		deviceByUDID[devices[i].UDID] = &devices[i]
+	}
+
+	var currentID uint64
+	if current := ReqSession(c); current != nil {
+		currentID = current.ID
+	}
+
+	items := make([]DataAuthSession, 0, len(sessions))
+	for _, s := range sessions {
+		item := DataAuthSession{
+			ID:          s.ID,
+			ClientClass: s.ClientClass,
+			IPCountry:   s.IPCountry,
+			CreatedAt:   s.CreatedAt.Unix(),
+			LastSeenAt:  s.LastSeenAt,
+			Current:     s.ID == currentID,
+		}
+		if s.UDID != "" {
+			device, ok := deviceByUDID[s.UDID]
+			if !ok {
+				continue
+			}
+			item.Platform = device.AppPlatform
+			item.DeviceRemark = device.Remark
+		}
+		items = append(items, item)
+	}
+	ItemsAll(c, items)
+}
+
+// api_revoke_session 吊销单个会话。当前会话请走登出。
+func api_revoke_session(c *gin.Context) {
+	userID := ReqUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		Error(c, ErrorInvalidArgument, "invalid session id")
+		return
+	}
+	if current := ReqSession(c); current != nil && current.ID == id {
+		Error(c, ErrorInvalidOperation, "cannot revoke current session")
+		return
+	}
+
+	n, err := revokeAuthSessions(db.Get(), sessionRevokeUser, "id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		log.Errorf(c, "failed to revoke session %d for user %d: %v", id, userID, err)
+		Error(c, ErrorSystemError, "failed to revoke session")
+		return
+	}
+	if n == 0 {
+		Error(c, ErrorNotFound, "session not found")
+		return
+	}
+	log.Infof(c, "user %d revoked session %d", userID, id)
+	SuccessEmpty(c)
+}
+
+// api_admin_revoke_user_sessions 全局登出（凭证泄漏应急，管理员直接执行，无需审批）
+// POST /app/users/:uuid/sessions/revoke
+func api_admin_revoke_user_sessions(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	var user User
+	if err := db.Get().Where(&User{UUID: uuid}).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, ErrorNotFound, "user not found")
+			return
+		}
+		log.Errorf(c, "failed to find user %s: %v", uuid, err)
+		Error(c, ErrorSystemError, "database error")
+		return
+	}
+
+	var revoked int64
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		n, err := revokeAllUserSessions(tx, user.ID)
+		revoked = n
+		return err
+	})
+	if err != nil {
+		log.Errorf(c, "failed to revoke sessions of user %s: %v", uuid, err)
+		Error(c, ErrorSystemError, "failed to revoke sessions")
+		return
+	}
+
+	log.Infof(c, "admin revoked %d sessions of user %s (id=%d)", revoked, uuid, user.ID)
+	SuccessEmpty(c)
+	WriteAuditLog(c, "user_revoke_sessions", "user", uuid, map[string]any{"revoked": revoked})
+}
@@ -0,0 +1,272 @@
+package center
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+func newSessionTestContext() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	return c
+}
+
+func setupSessionRouter() *gin.Engine {
+	r := SetupMinimalRouter()
+	r.POST("/api/auth/refresh", api_refresh_token)
+	r.POST("/api/auth/logout", AuthRequired(), api_logout)
+	r.GET("/api/user/sessions", AuthRequired(), api_list_sessions)
+	r.DELETE("/api/user/sessions/:id", AuthRequired(), api_revoke_session)
+	return r
+}
+
+// seedDeviceSession 模拟设备登录：建设备、开会话、签发 token
+func seedDeviceSession(t *testing.T, user *User, udid string) *DataAuthResult {
+	t.Helper()
+	device := CreateTestDevice(t, user.ID, udid)
+	authResult, issueAt, err := issueDeviceSession(newSessionTestContext(), db.Get(), user.ID, udid, 0)
+	require.NoError(t, err)
+	require.NoError(t, db.Get().Model(device).Update("token_issue_at", issueAt.Unix()).Error)
+	return authResult
+}
+
+func sessionOf(t *testing.T, token string) *AuthSession {
+	t.Helper()
+	claims := &TokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(configJwt(context.Background()).Secret), nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, claims.SessionID)
+	var s AuthSession
+	require.NoError(t, db.Get().Where(&AuthSession{FamilyID: claims.SessionID}).First(&s).Error)
+	return &s
+}
+
+func refresh(t *testing.T, r *gin.Engine, refreshToken string) (*TestResponse, *DataAuthResult) {
+	t.Helper()
+	w := NewTestRequest(http.MethodPost, "/api/auth/refresh").
+		WithBody(map[string]string{"refreshToken": refreshToken}).Execute(r)
+	resp, err := ParseResponse(w)
+	require.NoError(t, err)
+	if resp.Code != 0 {
+		return resp, nil
+	}
+	data, err := ParseResponseData[DataAuthResult](w)
+	require.NoError(t, err)
+	return resp, data
+}
+
+func TestRefreshToken_RotatesWithinFamily(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	r := setupSessionRouter()
+	first := seedDeviceSession(t, user, "udid-session-rotate-"+user.UUID)
+
+	resp, second := refresh(t, r, first.RefreshToken)
+	require.Equal(t, 0, resp.Code)
+	assert.Equal(t, sessionOf(t, first.RefreshToken).ID, sessionOf(t, second.RefreshToken).ID)
+
+	// 同一秒内再次轮换也必须产生新的一代
+	resp, third := refresh(t, r, second.RefreshToken)
+	require.Equal(t, 0, resp.Code)
+	assert.NotEqual(t, second.RefreshToken, third.RefreshToken)
+	assertAuthorized(t, r, third.AccessToken, true)
+}
+
+func TestRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	r := setupSessionRouter()
+	udid := "udid-session-reuse-" + user.UUID
+	stolen := seedDeviceSession(t, user, udid)
+
+	resp, current := refresh(t, r, stolen.RefreshToken)
+	require.Equal(t, 0, resp.Code)
+
+	// 旧 refresh token 再次出现：拒绝并吊销整个家族
+	resp, _ = refresh(t, r, stolen.RefreshToken)
+	assert.Equal(t, int(ErrorNotLogin), resp.Code)
+
+	session := sessionOf(t, current.RefreshToken)
+	assert.Equal(t, sessionRevokeReuse, session.RevokeReason)
+	resp, _ = refresh(t, r, current.RefreshToken)
+	assert.Equal(t, int(ErrorNotLogin), resp.Code)
+	assertAuthorized(t, r, current.AccessToken, false)
+
+	var device Device
+	require.NoError(t, db.Get().Where(&Device{UDID: udid}).First(&device).Error)
+	assert.Zero(t, device.TokenIssueAt)
+}
+
+func TestRefreshToken_ReuseFromSupersededLoginKeepsCurrent(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	r := setupSessionRouter()
+	udid := "udid-session-superseded-" + user.UUID
+	old := seedDeviceSession(t, user, udid)
+
+	// 同设备重新登录：旧家族作废
+	current, issueAt, err := issueDeviceSession(newSessionTestContext(), db.Get(), user.ID, udid, 0)
+	require.NoError(t, err)
+	require.NoError(t, db.Get().Model(&Device{}).Where("udid = ?", udid).
+		Update("token_issue_at", issueAt.Unix()).Error)
+	assert.Equal(t, sessionRevokeSuperseded, sessionOf(t, old.RefreshToken).RevokeReason)
+
+	resp, _ := refresh(t, r, old.RefreshToken)
+	assert.Equal(t, int(ErrorNotLogin), resp.Code)
+	assert.False(t, sessionOf(t, current.RefreshToken).IsRevoked())
+}
+
+func TestRefreshToken_LegacyTokenJoinsSession(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	r := setupSessionRouter()
+	udid := "udid-session-legacy-" + user.UUID
+	device := CreateTestDevice(t, user.ID, udid)
+	legacy, issueAt, err := generateTokens(newSessionTestContext(), user.ID, udid, 0, "")
+	require.NoError(t, err)
+	require.NoError(t, db.Get().Model(device).Update("token_issue_at", issueAt.Unix()).Error)
+
+	resp, rotated := refresh(t, r, legacy.RefreshToken)
+	require.Equal(t, 0, resp.Code)
+	session := sessionOf(t, rotated.RefreshToken)
+	assert.Equal(t, udid, session.UDID)
+	assert.Equal(t, "service", session.ClientClass)
+}
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	r := setupSessionRouter()
+	mine, err := issueWebSession(newSessionTestContext(), user.ID, 0)
+	require.NoError(t, err)
+	other, err := issueWebSession(newSessionTestContext(), user.ID, 0)
+	require.NoError(t, err)
+	seedDeviceSession(t, user, "udid-session-list-"+user.UUID)
+
+	w := NewTestRequest(http.MethodGet, "/api/user/sessions").WithBearerToken(mine.AccessToken).Execute(r)
+	list, err := ParseResponseData[ListResult[DataAuthSession]](w)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 3)
+	var current, web, device int
+	for _, s := range list.Items {
+		if s.Current {
+			current++
+			assert.Equal(t, sessionOf(t, mine.AccessToken).ID, s.ID)
+		}
+		switch s.ClientClass {
+		case sessionClientWeb:
+			web++
+		case "service":
+			device++
+		}
+	}
+	assert.Equal(t, []int{1, 2, 1}, []int{current, web, device})
+
+	// 当前会话不能在这里吊销
+	path := fmt.Sprintf("/api/user/sessions/%d", sessionOf(t, mine.AccessToken).ID)
+	resp, err := ParseResponse(NewTestRequest(http.MethodDelete, path).WithBearerToken(mine.AccessToken).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorInvalidOperation), resp.Code)
+
+	path = fmt.Sprintf("/api/user/sessions/%d", sessionOf(t, other.AccessToken).ID)
+	resp, err = ParseResponse(NewTestRequest(http.MethodDelete, path).WithBearerToken(mine.AccessToken).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, 0, resp.Code)
+	assertAuthorized(t, r, other.AccessToken, false)
+	assertAuthorized(t, r, mine.AccessToken, true)
+
+	// 别人的会话：不存在
+	stranger := CreateTestUser(t)
+	theirs, err := issueWebSession(newSessionTestContext(), stranger.ID, 0)
+	require.NoError(t, err)
+	path = fmt.Sprintf("/api/user/sessions/%d", sessionOf(t, theirs.AccessToken).ID)
+	resp, err = ParseResponse(NewTestRequest(http.MethodDelete, path).WithBearerToken(mine.AccessToken).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorNotFound), resp.Code)
+	assertAuthorized(t, r, theirs.AccessToken, true)
+}
+
+func TestSessions_LogoutRevokesWebSession(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	r := setupSessionRouter()
+	web, err := issueWebSession(newSessionTestContext(), user.ID, 0)
+	require.NoError(t, err)
+
+	resp, err := ParseResponse(NewTestRequest(http.MethodPost, "/api/auth/logout").WithBearerToken(web.AccessToken).Execute(r))
+	require.NoError(t, err)
+	require.Equal(t, 0, resp.Code)
+	assert.Equal(t, sessionRevokeLogout, sessionOf(t, web.AccessToken).RevokeReason)
+	assertAuthorized(t, r, web.AccessToken, false)
+}
+
+func TestSessions_RevokeAllCoversLegacyTokens(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	r := setupSessionRouter()
+	web, err := issueWebSession(newSessionTestContext(), user.ID, 0)
+	require.NoError(t, err)
+	legacyWeb, _, err := generateWebCookieToken(newSessionTestContext(), user.ID, 0, "")
+	require.NoError(t, err)
+	device := seedDeviceSession(t, user, "udid-session-revoke-all-"+user.UUID)
+	assertAuthorized(t, r, legacyWeb.AccessToken, true)
+
+	n, err := revokeAllUserSessions(db.Get(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	assertAuthorized(t, r, web.AccessToken, false)
+	assertAuthorized(t, r, legacyWeb.AccessToken, false)
+	assertAuthorized(t, r, device.AccessToken, false)
+	resp, _ := refresh(t, r, device.RefreshToken)
+	assert.Equal(t, int(ErrorNotLogin), resp.Code)
+
+	// 之后的新登录不受影响
+	fresh, err := issueWebSession(newSessionTestContext(), user.ID, 0)
+	require.NoError(t, err)
+	assertAuthorized(t, r, fresh.AccessToken, true)
+}
+
+// TestSlidingRenewal_LegacyCookieJoinsSession: 存量 Web Cookie（无 sid）续期时开启
+// 会话，续期后的 token 出现在会话列表里，也能被单独吊销
+func TestSlidingRenewal_LegacyCookieJoinsSession(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	r := setupSessionRouter()
+	legacy := GenerateExpiringToken(user.ID, 3*24*time.Hour)
+
+	w := NewTestRequest(http.MethodGet, "/api/user/sessions").WithCookie(CookieAccessToken, legacy).Execute(r)
+	renewed := GetAccessTokenCookie(w)
+	require.NotNil(t, renewed, "token below the renewal threshold gets a new cookie")
+	session := sessionOf(t, renewed.Value)
+	assert.Equal(t, user.ID, session.UserID)
+	assert.Equal(t, sessionClientWeb, session.ClientClass)
+	assert.Empty(t, session.UDID)
+
+	_, err := revokeAuthSessions(db.Get(), sessionRevokeUser, "id = ?", session.ID)
+	require.NoError(t, err)
+	assertAuthorized(t, r, renewed.Value, false)
+}
+
+func assertAuthorized(t *testing.T, r *gin.Engine, accessToken string, want bool) {
+	t.Helper()
+	resp, err := ParseResponse(NewTestRequest(http.MethodGet, "/api/user/sessions").WithBearerToken(accessToken).Execute(r))
+	require.NoError(t, err)
+	if want {
+		assert.Equal(t, 0, resp.Code)
+	} else {
+		assert.Equal(t, int(ErrorNotLogin), resp.Code)
+	}
+}
@@ -3,6 +3,7 @@ package center
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -27,16 +28,16 @@ func api_strategy_get_rules(c *gin.Context) {
 	// Check If-None-Match header for caching
 	clientETag := c.GetHeader("If-None-Match")
 
-	// Find active rules
-	var rules StrategyRules
-	err := db.Get().Where(&StrategyRules{IsActive: BoolPtr(true)}).First(&rules).Error
-	if err != nil {
+	// Resolve the version for this device: cohort pin > rollout bucket > active
+	assignment := resolveStrategyAssignment(c, ReqDevice(c))
+	if assignment.Rules == nil {
 		// No active rules - return default
 		log.Infof(c, "no active rules found, returning defaults")
 		defaultRules := StrategyRulesResponse{
 			Version:   "default",
 			UpdatedAt: time.Now().Format(time.RFC3339),
-			ETag:      "\"default\"",
+			ETag:      assignment.ETag(),
+			Cohort:    assignment.Cohort,
 			Rules:     []map[string]any{},
 			Protocols: map[string]any{},
 			Default: map[string]any{
@@ -47,9 +48,11 @@ func api_strategy_get_rules(c *gin.Context) {
 		Success(c, &defaultRules)
 		return
 	}
+	rules := assignment.Rules
 
-	// Generate ETag from version
-	etag := fmt.Sprintf("\"%s\"", rules.Version)
+	// ETag covers version and cohort: a device moved between cohorts on the
+	// same version must refetch to pick up its new cohort label
+	etag := assignment.ETag()
 
 	// Check if client has current version
 	if clientETag == etag {
@@ -76,12 +79,13 @@ func api_strategy_get_rules(c *gin.Context) {
 		Version:   rules.Version,
 		UpdatedAt: rules.UpdatedAt.Format(time.RFC3339),
 		ETag:      etag,
+		Cohort:    assignment.Cohort,
 		Rules:     content.Rules,
 		Protocols: content.Protocols,
 		Default:   content.Default,
 	}
 
-	log.Infof(c, "returning rules version %s", rules.Version)
+	log.Infof(c, "returning rules version %s (cohort %s)", rules.Version, assignment.Cohort)
 	Success(c, &response)
 }
 
@@ -139,14 +143,44 @@ func api_strategy_telemetry_batch(c *gin.Context) {
 		errors = append(errors, fmt.Sprintf("rate limit: only %d events accepted", remaining))
 	}
 
+	// Stamp events with the rules version and cohort the client reports it
+	// ran (event context, else batch header) so candidate and active versions
+	// can be compared on outcomes. The device's current assignment is only a
+	// fallback: a batch flushed after a rollout change would otherwise credit
+	// the new version with the old version's results.
+	var assignment *strategyAssignment
+	var ipCountry string
+	var ipASN uint
+	if len(eventsToProcess) > 0 {
+		ipCountry = CountryFromGinContext(c)
+		ipASN = ASNFromIP(c.ClientIP())
+	}
+	batchVersion := clipTelemetryString(strings.TrimSpace(req.RulesVersion), 50)
+	batchCohort := clipTelemetryString(strings.TrimSpace(req.Cohort), 64)
+
 	// Build batch of events
 	var events []TelemetryEvent
 	for _, evt := range eventsToProcess {
 		contextJSON, _ := json.Marshal(evt.Context)
 		decisionJSON, _ := json.Marshal(evt.Decision)
 		outcomeJSON, _ := json.Marshal(evt.Outcome)
+		var success *bool
+		if v, ok := evt.Outcome["success"].(bool); ok {
+			success = BoolPtr(v)
+		}
+		rulesVersion := telemetryString(evt.Context, "rules_version", 50)
+		cohort := telemetryString(evt.Context, "cohort", 64)
+		if rulesVersion == "" {
+			rulesVersion, cohort = batchVersion, batchCohort
+		}
+		if rulesVersion == "" {
+			if assignment == nil {
+				assignment = resolveStrategyAssignment(c, &device)
+			}
+			rulesVersion, cohort = assignment.Version(), assignment.Cohort
+		}
 
-		events = append(events, TelemetryEvent{
+		row := TelemetryEvent{
 			EventID:      evt.EventID,
 			Timestamp:    evt.Timestamp,
 			EventType:    evt.EventType,
@@ -156,7 +190,12 @@ func api_strategy_telemetry_batch(c *gin.Context) {
 			Outcome:      string(outcomeJSON),
 			AppVersion:   req.AppVersion,
 			Satisfaction: evt.Satisfaction,
-		})
+			RulesVersion: rulesVersion,
+			Cohort:       cohort,
+			Success:      success,
+		}
+		applyTelemetryDims(&row, &evt, ipCountry, ipASN)
+		events = append(events, row)
 	}
 
 	// Batch insert with INSERT IGNORE (duplicates silently ignored)
@@ -1,6 +1,7 @@
 package center
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
 )
 
 // =====================================================================
@@ -170,6 +173,63 @@ func TestGetStrategyRules_ETagCaching(t *testing.T) {
 	})
 }
 
+// TestGetStrategyRules_CohortChangeRefetches: moving a device to another
+// cohort pinned to the same version must not answer the old ETag with 304,
+// otherwise the device keeps reporting its previous cohort.
+func TestGetStrategyRules_CohortChangeRefetches(t *testing.T) {
+	skipIfNoConfig(t)
+	pinned := createTestStrategyRules(t, "1999.01.03.1", 0)
+	device := &Device{ID: 900001000, UDID: "etag-cohort-test"}
+
+	var cohorts []*StrategyCohort
+	for _, name := range []string{"etag-test-a", "etag-test-b"} {
+		cohort := &StrategyCohort{Name: name, Version: pinned.Version}
+		db.Get().Where(&StrategyCohort{Name: name}).Delete(&StrategyCohort{})
+		require.NoError(t, db.Get().Create(cohort).Error)
+		cohorts = append(cohorts, cohort)
+	}
+	member := &StrategyCohortDevice{CohortID: cohorts[0].ID, DeviceID: device.ID}
+	db.Get().Where(&StrategyCohortDevice{DeviceID: device.ID}).Delete(&StrategyCohortDevice{})
+	require.NoError(t, db.Get().Create(member).Error)
+	t.Cleanup(func() {
+		db.Get().Delete(member)
+		for _, cohort := range cohorts {
+			db.Get().Delete(cohort)
+		}
+	})
+
+	testInitConfig()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/strategy/rules", func(c *gin.Context) {
+		c.Set("authContext", &authContext{Device: device})
+	}, api_strategy_get_rules)
+	get := func(etag string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/strategy/rules", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("")
+	require.Equal(t, http.StatusOK, w.Code)
+	first := w.Header().Get("ETag")
+	assert.Equal(t, http.StatusNotModified, get(first).Code)
+
+	require.NoError(t, db.Get().Model(member).Update("cohort_id", cohorts[1].ID).Error)
+	w = get(first)
+	require.Equal(t, http.StatusOK, w.Code, "cohort changed, same version: full response")
+	var resp Response[StrategyRulesResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, pinned.Version, resp.Data.Version)
+	assert.Equal(t, "etag-test-b", resp.Data.Cohort)
+	assert.NotEqual(t, first, resp.Data.ETag)
+	assert.Equal(t, http.StatusNotModified, get(resp.Data.ETag).Code)
+}
+
 // =====================================================================
 // Telemetry Batch API Tests (TDD)
 // =====================================================================
@@ -307,3 +367,51 @@ func TestTelemetryBatch_HTTPMethod(t *testing.T) {
 		})
 	}
 }
+
+// 事件按客户端上报的规则版本打标：事件 context 优先，其次批次头，都没有才取设备当前分配
+func TestTelemetryBatch_StampsReportedRulesVersion(t *testing.T) {
+	skipIfNoConfig(t)
+	r := setupTelemetryTestRouter()
+	user := CreateTestUser(t)
+	udid := generateId("telemetry-stamp")
+	device := CreateTestDevice(t, user.ID, udid)
+	t.Cleanup(func() {
+		db.Get().Where("device_id = ?", device.ID).Delete(&TelemetryEvent{})
+		db.Get().Where("device_id = ?", device.ID).Delete(&TelemetryRateLimit{})
+	})
+
+	post := func(body map[string]any) {
+		w := NewTestRequest(http.MethodPost, "/api/telemetry/batch").WithBody(body).Execute(r)
+		resp, err := ParseResponse(w)
+		require.NoError(t, err)
+		require.Equal(t, 0, resp.Code, "body=%s", w.Body.String())
+	}
+	event := func(id string, ctx map[string]any) map[string]any {
+		return map[string]any{"eventId": udid + id, "timestamp": 1, "eventType": "connection", "context": ctx}
+	}
+
+	post(map[string]any{
+		"deviceId": udid, "appVersion": "1.0.0", "rulesVersion": "2026.01.01.1", "cohort": strategyCohortRollout,
+		"events": []any{
+			event("-ctx", map[string]any{"rules_version": "2026.01.01.0", "cohort": strategyCohortControl}),
+			event("-batch", nil),
+		},
+	})
+	post(map[string]any{"deviceId": udid, "appVersion": "1.0.0", "events": []any{event("-none", nil)}})
+
+	stamped := func(id string) TelemetryEvent {
+		var ev TelemetryEvent
+		require.NoError(t, db.Get().Where("event_id = ?", udid+id).First(&ev).Error)
+		return ev
+	}
+	ev := stamped("-ctx")
+	assert.Equal(t, "2026.01.01.0", ev.RulesVersion)
+	assert.Equal(t, strategyCohortControl, ev.Cohort)
+	ev = stamped("-batch")
+	assert.Equal(t, "2026.01.01.1", ev.RulesVersion)
+	assert.Equal(t, strategyCohortRollout, ev.Cohort)
+	assignment := resolveStrategyAssignment(context.Background(), device)
+	ev = stamped("-none")
+	assert.Equal(t, assignment.Version(), ev.RulesVersion)
+	assert.Equal(t, assignment.Cohort, ev.Cohort)
+}
@@ -371,8 +371,8 @@ func api_subs(c *gin.Context) {
 		// overage every byte costs real money, so even a low score is not
 		// safe enough. Admin bypass keeps the path open for triage.
 		u := usageMap[t.Node.Ipv4] // nil if no usage row yet
-		if shouldHideTunnelForUser(u, isAdmin, now) {
-			log.Warnf(c, "subs: tunnel %d (node=%s, ip=%s) hidden from non-admin (over-quota/offline)",
+		if shouldHideNodeForUser(t.Node, u, isAdmin, now) {
+			log.Warnf(c, "subs: tunnel %d (node=%s, ip=%s) hidden from non-admin (over-quota/offline/draining)",
 				t.ID, t.Node.Name, t.Node.Ipv4)
 			continue
 		}
@@ -150,8 +150,8 @@ func api_k2_tunnels(c *gin.Context) {
 		// additional byte costs real money. Admin path stays open so such
 		// nodes remain visible for triage.
 		u := usageMap[tunnel.Node.Ipv4] // nil if no usage row yet
-		if shouldHideTunnelForUser(u, isAdmin, now) {
-			log.Warnf(c, "tunnel %d (node=%s, ip=%s) hidden from non-admin (over-quota/offline)",
+		if shouldHideNodeForUser(tunnel.Node, u, isAdmin, now) {
+			log.Warnf(c, "tunnel %d (node=%s, ip=%s) hidden from non-admin (over-quota/offline/draining)",
 				tunnel.ID, tunnel.Node.Name, tunnel.Node.Ipv4)
 			continue
 		}
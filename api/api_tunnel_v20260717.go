@@ -80,7 +80,7 @@ func api_v20260717_tunnels(c *gin.Context) {
 		}
 
 		u := usageMap[tunnel.Node.Ipv4] // nil if no usage row yet
-		if shouldHideTunnelForUser(u, false, now) {
+		if shouldHideNodeForUser(tunnel.Node, u, false, now) {
 			continue
 		}
 
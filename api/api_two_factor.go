@@ -0,0 +1,282 @@
+package center
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"gorm.io/gorm"
+)
+
+// DataTwoFactorStatus 两步验证状态
+type DataTwoFactorStatus struct {
+	Enabled                bool  `json:"enabled"`
+	EnabledAt              int64 `json:"enabledAt"`
+	RecoveryCodesRemaining int   `json:"recoveryCodesRemaining"`
+	Required               bool  `json:"required"` // 管理角色账号必须启用，且不可关闭
+}
+
+// DataTwoFactorSetup 待确认的新密钥，用于认证器 App 扫码或手动输入
+type DataTwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OtpauthURL string `json:"otpauthUrl"`
+}
+
+// DataRecoveryCodes 新生成的恢复码（明文只返回这一次）
+type DataRecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// TwoFactorCodeRequest 携带验证码的请求；enable 只接受 TOTP，其余接受 TOTP 或恢复码
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+func twoFactorStatus(user *User) *DataTwoFactorStatus {
+	status := &DataTwoFactorStatus{
+		Enabled:   twoFactorEnabled(user),
+		EnabledAt: user.TOTPEnabledAt,
+		Required:  requiresTwoFactor(user),
+	}
+	if status.Enabled {
+		status.RecoveryCodesRemaining = len(recoveryCodeHashes(user))
+	}
+	return status
+}
+
+// api_get_two_factor 我的两步验证状态
+//
+// GET /api/user/2fa
+func api_get_two_factor(c *gin.Context) {
+	Success(c, twoFactorStatus(ReqUser(c)))
+}
+
+// api_setup_two_factor 生成新密钥（尚未生效，需 enable 确认）
+//
+// POST /api/user/2fa/setup
+func api_setup_two_factor(c *gin.Context) {
+	user := ReqUser(c)
+	if twoFactorEnabled(user) {
+		Error(c, ErrorConflict, "two-factor authentication already enabled")
+		return
+	}
+
+	secret, err := totpGenerateSecret()
+	if err != nil {
+		log.Errorf(c, "failed to generate totp secret for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to generate secret")
+		return
+	}
+	encrypted, err := secretEncryptString(c, secret)
+	if err != nil {
+		log.Errorf(c, "failed to encrypt totp secret for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to generate secret")
+		return
+	}
+	user.TOTPSecret = encrypted
+	user.TOTPLastStep = 0
+	if err := saveTwoFactorState(user); err != nil {
+		log.Errorf(c, "failed to save totp secret for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to save secret")
+		return
+	}
+
+	account, err := getUserEmail(c, user.ID)
+	if err != nil {
+		account = user.UUID
+	}
+	issuer := Brand(user.Brand).Config().DisplayName
+	log.Infof(c, "user %d started two-factor setup", user.ID)
+	Success(c, &DataTwoFactorSetup{
+		Secret:     secret,
+		OtpauthURL: totpProvisioningURI(issuer, account, secret),
+	})
+}
+
+// api_enable_two_factor 用认证器上的验证码确认密钥并启用，返回恢复码
+//
+// POST /api/user/2fa/enable   {"code":"123456"}
+func api_enable_two_factor(c *gin.Context) {
+	user := ReqUser(c)
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, "code is required")
+		return
+	}
+	if twoFactorEnabled(user) {
+		Error(c, ErrorConflict, "two-factor authentication already enabled")
+		return
+	}
+	if user.TOTPSecret == "" {
+		Error(c, ErrorInvalidOperation, "call setup first")
+		return
+	}
+	if !checkTOTPCode(c, user, req.Code) {
+		log.Warnf(c, "user %d entered an invalid code while enabling two-factor", user.ID)
+		Error(c, ErrorInvalidTwoFactorCode, "invalid two-factor code")
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes(c)
+	if err != nil {
+		log.Errorf(c, "failed to generate recovery codes for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to generate recovery codes")
+		return
+	}
+	user.TOTPRecoveryCodes = hashes
+	user.TOTPEnabledAt = time.Now().Unix()
+	if err := saveTwoFactorState(user); err != nil {
+		log.Errorf(c, "failed to enable two-factor for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to enable two-factor authentication")
+		return
+	}
+	log.Infof(c, "user %d enabled two-factor authentication", user.ID)
+	Success(c, &DataRecoveryCodes{RecoveryCodes: codes})
+}
+
+// api_disable_two_factor 关闭两步验证（管理角色账号不可关闭）
+//
+// POST /api/user/2fa/disable   {"code":"123456"}
+func api_disable_two_factor(c *gin.Context) {
+	user := ReqUser(c)
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, "code is required")
+		return
+	}
+	if !twoFactorEnabled(user) {
+		Error(c, ErrorInvalidOperation, "two-factor authentication is not enabled")
+		return
+	}
+	if requiresTwoFactor(user) {
+		Error(c, ErrorForbidden, "two-factor authentication is mandatory for admin accounts")
+		return
+	}
+	if err := verifySecondFactor(c, user, req.Code); err != nil {
+		ErrorE(c, err)
+		return
+	}
+
+	clearTwoFactor(user)
+	if err := saveTwoFactorState(user); err != nil {
+		log.Errorf(c, "failed to disable two-factor for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to disable two-factor authentication")
+		return
+	}
+	log.Infof(c, "user %d disabled two-factor authentication", user.ID)
+	Success(c, twoFactorStatus(user))
+}
+
+// api_regenerate_recovery_codes 重新生成恢复码，旧的全部作废
+//
+// POST /api/user/2fa/recovery-codes   {"code":"123456"}
+func api_regenerate_recovery_codes(c *gin.Context) {
+	user := ReqUser(c)
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, "code is required")
+		return
+	}
+	if !twoFactorEnabled(user) {
+		Error(c, ErrorInvalidOperation, "two-factor authentication is not enabled")
+		return
+	}
+	if err := verifySecondFactor(c, user, req.Code); err != nil {
+		ErrorE(c, err)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes(c)
+	if err != nil {
+		log.Errorf(c, "failed to generate recovery codes for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to generate recovery codes")
+		return
+	}
+	user.TOTPRecoveryCodes = hashes
+	if err := saveTwoFactorState(user); err != nil {
+		log.Errorf(c, "failed to save recovery codes for user %d: %v", user.ID, err)
+		Error(c, ErrorSystemError, "failed to save recovery codes")
+		return
+	}
+	log.Infof(c, "user %d regenerated recovery codes", user.ID)
+	Success(c, &DataRecoveryCodes{RecoveryCodes: codes})
+}
+
+// clearTwoFactor 清空两步验证相关字段（调用方负责保存）
+func clearTwoFactor(user *User) {
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = 0
+	user.TOTPLastStep = 0
+	user.TOTPRecoveryCodes = ""
+}
+
+// =====================================================================
+// 管理员重置用户两步验证（丢失认证器且恢复码用尽时）
+// =====================================================================
+
+// AdminResetTwoFactorRequest POST /app/users/:uuid/2fa/reset 请求体
+type AdminResetTwoFactorRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// adminResetTwoFactorParams 审批参数
+type adminResetTwoFactorParams struct {
+	UserUUID   string `json:"userUuid"`
+	Reason     string `json:"reason"`
+	AdminEmail string `json:"adminEmail"` // 提交人邮箱，用于通知邮件
+}
+
+// api_admin_reset_user_two_factor 重置用户的两步验证，走审批流程。
+// 重置后用户可仅凭密码/邮箱验证码登录；管理角色账号下次访问后台时会被要求重新启用。
+//
+// POST /app/users/:uuid/2fa/reset   {"reason":"..."}
+func api_admin_reset_user_two_factor(c *gin.Context) {
+	uuid := c.Param("uuid")
+	var req AdminResetTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Error(c, ErrorInvalidArgument, err.Error())
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len(reason) < 3 {
+		Error(c, ErrorInvalidArgument, "reason too short")
+		return
+	}
+
+	var user User
+	if err := db.Get().Where(&User{UUID: uuid}).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, ErrorNotFound, "user not found")
+			return
+		}
+		log.Errorf(c, "find user %s failed: %v", uuid, err)
+		Error(c, ErrorSystemError, "find user failed")
+		return
+	}
+	if !twoFactorEnabled(&user) && user.TOTPSecret == "" {
+		Error(c, ErrorInvalidOperation, "two-factor authentication is not enabled")
+		return
+	}
+
+	params := adminResetTwoFactorParams{
+		UserUUID:   uuid,
+		Reason:     reason,
+		AdminEmail: adminDisplayEmail(c),
+	}
+	summary := fmt.Sprintf("重置用户 %s 的两步验证，原因：%s", uuid, reason)
+	approvalID, executed, err := SubmitApproval(c, "user_reset_2fa", params, summary)
+	if err != nil {
+		log.Errorf(c, "failed to submit approval for 2fa reset of user %s: %v", uuid, err)
+		Error(c, ErrorSystemError, "failed to submit approval")
+		return
+	}
+	if !executed {
+		PendingApproval(c, approvalID)
+		return
+	}
+	SuccessEmpty(c)
+}
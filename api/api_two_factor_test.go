@@ -0,0 +1,213 @@
+package center
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+func setupTwoFactorRouter() *gin.Engine {
+	r := SetupMinimalRouter()
+	r.GET("/api/user/2fa", AuthRequired(), api_get_two_factor)
+	r.POST("/api/user/2fa/setup", AuthRequired(), api_setup_two_factor)
+	r.POST("/api/user/2fa/enable", AuthRequired(), api_enable_two_factor)
+	r.POST("/api/user/2fa/disable", AuthRequired(), api_disable_two_factor)
+	r.POST("/api/user/2fa/recovery-codes", AuthRequired(), api_regenerate_recovery_codes)
+	r.POST("/api/auth/web-login/password", api_web_password_login)
+	r.POST("/api/auth/web-login", api_web_auth)
+	return r
+}
+
+// enrollTwoFactor runs setup → enable over HTTP and returns the secret and recovery codes.
+func enrollTwoFactor(t *testing.T, r *gin.Engine, token string) (string, []string) {
+	t.Helper()
+	w := NewTestRequest(http.MethodPost, "/api/user/2fa/setup").WithBearerToken(token).Execute(r)
+	setup, err := ParseResponseData[DataTwoFactorSetup](w)
+	require.NoError(t, err)
+	require.NotEmpty(t, setup.Secret)
+	assert.Contains(t, setup.OtpauthURL, "secret="+setup.Secret)
+
+	w = NewTestRequest(http.MethodPost, "/api/user/2fa/enable").WithBearerToken(token).
+		WithBody(map[string]any{"code": "000000"}).Execute(r)
+	resp, err := ParseResponse(w)
+	require.NoError(t, err)
+	if resp.Code == 0 {
+		t.Skip("000000 happened to be the current code")
+	}
+	assert.Equal(t, int(ErrorInvalidTwoFactorCode), resp.Code)
+
+	code, err := totpCodeAt(setup.Secret, totpStep(time.Now()))
+	require.NoError(t, err)
+	w = NewTestRequest(http.MethodPost, "/api/user/2fa/enable").WithBearerToken(token).
+		WithBody(map[string]any{"code": code}).Execute(r)
+	codes, err := ParseResponseData[DataRecoveryCodes](w)
+	require.NoError(t, err)
+	require.Len(t, codes.RecoveryCodes, recoveryCodeCount)
+	return setup.Secret, codes.RecoveryCodes
+}
+
+func TestTwoFactor_EnrollAndPasswordLogin(t *testing.T) {
+	skipIfNoConfig(t)
+	viper.Set("mail.dev_mode", true)
+	t.Cleanup(func() { viper.Set("mail.dev_mode", false) })
+
+	const password = "k7N#mq2P!xT9"
+	user, email := seedWebPasswordLoginUser(t, password)
+	token := GenerateTestToken(user.ID, "", time.Hour)
+	r := setupTwoFactorRouter()
+
+	secret, recovery := enrollTwoFactor(t, r, token)
+
+	w := NewTestRequest(http.MethodGet, "/api/user/2fa").WithBearerToken(token).Execute(r)
+	status, err := ParseResponseData[DataTwoFactorStatus](w)
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.False(t, status.Required)
+	assert.Equal(t, recoveryCodeCount, status.RecoveryCodesRemaining)
+
+	login := func(totp string) int {
+		w := NewTestRequest(http.MethodPost, "/api/auth/web-login/password").
+			WithBody(map[string]string{"email": email, "password": password, "totpCode": totp}).Execute(r)
+		resp, err := ParseResponse(w)
+		require.NoError(t, err)
+		return resp.Code
+	}
+
+	// Password alone is no longer enough.
+	assert.Equal(t, int(ErrorTwoFactorRequired), login(""))
+	assert.Equal(t, int(ErrorInvalidTwoFactorCode), login("999999"))
+
+	// The code consumed by enable cannot be replayed; the next step's can.
+	next, _ := totpCodeAt(secret, totpStep(time.Now())+1)
+	assert.Equal(t, 0, login(next))
+	assert.Equal(t, int(ErrorInvalidTwoFactorCode), login(next))
+
+	// A recovery code works exactly once.
+	assert.Equal(t, 0, login(recovery[0]))
+	assert.Equal(t, int(ErrorInvalidTwoFactorCode), login(recovery[0]))
+
+	var stored User
+	require.NoError(t, db.Get().First(&stored, user.ID).Error)
+	assert.Len(t, recoveryCodeHashes(&stored), recoveryCodeCount-1)
+	assert.Equal(t, 1, stored.PasswordFailedAttempts, "bad second factors count toward the lock until the next success")
+
+	// Disable with a recovery code, then password alone works again.
+	w = NewTestRequest(http.MethodPost, "/api/user/2fa/disable").WithBearerToken(token).
+		WithBody(map[string]any{"code": recovery[1]}).Execute(r)
+	status, err = ParseResponseData[DataTwoFactorStatus](w)
+	require.NoError(t, err)
+	assert.False(t, status.Enabled)
+	assert.Equal(t, 0, login(""))
+}
+
+func TestTwoFactor_WebCodeLoginKeepsCodeForRetry(t *testing.T) {
+	skipIfNoConfig(t)
+	EnableMockVerificationCode = true
+	viper.Set("mail.dev_mode", true)
+	t.Cleanup(func() {
+		EnableMockVerificationCode = false
+		viper.Set("mail.dev_mode", false)
+	})
+
+	user, email := seedWebPasswordLoginUser(t, "k7N#mq2P!xT9")
+	r := setupTwoFactorRouter()
+	secret, _ := enrollTwoFactor(t, r, GenerateTestToken(user.ID, "", time.Hour))
+
+	body := map[string]string{"email": email, "verificationCode": MockVerificationCode}
+	resp, err := ParseResponse(NewTestRequest(http.MethodPost, "/api/auth/web-login").WithBody(body).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorTwoFactorRequired), resp.Code)
+
+	body["totpCode"], _ = totpCodeAt(secret, totpStep(time.Now())+1)
+	resp, err = ParseResponse(NewTestRequest(http.MethodPost, "/api/auth/web-login").WithBody(body).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, 0, resp.Code, "the same email code is accepted together with the TOTP code")
+}
+
+// TestTwoFactor_AppCodeLoginRequiresSecondFactor covers the app email-code
+// login: Bearer tokens reach /app/* too, so an admin with 2FA must not get one
+// from the email code alone.
+func TestTwoFactor_AppCodeLoginRequiresSecondFactor(t *testing.T) {
+	skipIfNoConfig(t)
+	EnableMockVerificationCode = true
+	t.Cleanup(func() { EnableMockVerificationCode = false })
+
+	user, email := seedWebPasswordLoginUser(t, "k7N#mq2P!xT9")
+	require.NoError(t, db.Get().Model(&user).Update("roles", RoleUser|RoleDevopsEditor).Error)
+	user.TOTPSecret, user.TOTPEnabledAt, user.TOTPLastStep = rfc6238Secret, 1, 7
+	require.NoError(t, saveTwoFactorState(&user))
+
+	r := SetupMinimalRouter()
+	r.POST("/api/auth/login", api_login)
+	body := map[string]string{
+		"email":            email,
+		"verificationCode": MockVerificationCode,
+		"udid":             "udid-2fa-test-" + user.UUID,
+	}
+	resp, err := ParseResponse(NewTestRequest(http.MethodPost, "/api/auth/login").WithBody(body).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorTwoFactorRequired), resp.Code)
+
+	var devices int64
+	db.Get().Model(&Device{}).Where("udid = ?", body["udid"]).Count(&devices)
+	assert.Zero(t, devices, "no device or token is issued without the second factor")
+
+	body["totpCode"], _ = totpCodeAt(rfc6238Secret, totpStep(time.Now()))
+	resp, err = ParseResponse(NewTestRequest(http.MethodPost, "/api/auth/login").WithBody(body).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, 0, resp.Code, "the same email code is accepted together with the TOTP code")
+	t.Cleanup(func() { db.Get().Unscoped().Where("udid = ?", body["udid"]).Delete(&Device{}) })
+}
+
+func TestTwoFactor_AdminCannotDisable(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	require.NoError(t, db.Get().Model(user).Update("roles", RoleUser|RoleDevopsEditor).Error)
+	token := GenerateTestToken(user.ID, "", time.Hour)
+	r := setupTwoFactorRouter()
+	r.GET("/app/nodes", AuthRequired(), RoleRequired(RoleDevopsEditor), func(c *gin.Context) { SuccessEmpty(c) })
+
+	resp, err := ParseResponse(NewTestRequest(http.MethodGet, "/app/nodes").WithBearerToken(token).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorTwoFactorSetupRequired), resp.Code)
+
+	_, recovery := enrollTwoFactor(t, r, token)
+	resp, err = ParseResponse(NewTestRequest(http.MethodGet, "/app/nodes").WithBearerToken(token).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, 0, resp.Code)
+
+	resp, err = ParseResponse(NewTestRequest(http.MethodPost, "/api/user/2fa/disable").WithBearerToken(token).
+		WithBody(map[string]any{"code": recovery[0]}).Execute(r))
+	require.NoError(t, err)
+	assert.Equal(t, int(ErrorForbidden), resp.Code)
+}
+
+func TestExecuteApprovalUserReset2FA(t *testing.T) {
+	skipIfNoConfig(t)
+	viper.Set("mail.dev_mode", true)
+	t.Cleanup(func() { viper.Set("mail.dev_mode", false) })
+
+	user := CreateTestUser(t)
+	_, hashes, err := generateRecoveryCodes(context.Background())
+	require.NoError(t, err)
+	user.TOTPSecret, user.TOTPEnabledAt, user.TOTPLastStep, user.TOTPRecoveryCodes = rfc6238Secret, 1, 7, hashes
+	require.NoError(t, saveTwoFactorState(user))
+
+	params, _ := json.Marshal(adminResetTwoFactorParams{UserUUID: user.UUID, Reason: "lost phone"})
+	require.NoError(t, executeApprovalUserReset2FA(context.Background(), params))
+
+	var stored User
+	require.NoError(t, db.Get().First(&stored, user.ID).Error)
+	assert.False(t, twoFactorEnabled(&stored))
+	assert.Empty(t, stored.TOTPSecret)
+	assert.Empty(t, stored.TOTPRecoveryCodes)
+	assert.Zero(t, stored.TOTPLastStep)
+}
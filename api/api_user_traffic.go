@@ -0,0 +1,146 @@
+package center
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// defaultUserTrafficDays is the window of GET /api/user/traffic when ?days is
+// omitted. The maximum is trafficRetentionDays: older rows no longer exist.
+const defaultUserTrafficDays = 30
+
+// DataUserTrafficDay 单日单设备流量（各节点合计）
+type DataUserTrafficDay struct {
+	Date    string `json:"date"`
+	UDID    string `json:"udid" gorm:"column:udid"`
+	RxBytes int64  `json:"rxBytes"`
+	TxBytes int64  `json:"txBytes"`
+}
+
+// DataUserTrafficDevice 单设备窗口内合计
+type DataUserTrafficDevice struct {
+	UDID       string `json:"udid"`
+	Remark     string `json:"remark"` // 设备已删除时为空
+	RxBytes    int64  `json:"rxBytes"`
+	TxBytes    int64  `json:"txBytes"`
+	TotalBytes int64  `json:"totalBytes"`
+}
+
+// DataUserTraffic 我的流量：最近 N 天按设备、按日，以及本月累计与滥用阈值
+type DataUserTraffic struct {
+	Since      string `json:"since"` // 含，CST 日期
+	Until      string `json:"until"` // 含，CST 日期
+	RxBytes    int64  `json:"rxBytes"`
+	TxBytes    int64  `json:"txBytes"`
+	TotalBytes int64  `json:"totalBytes"`
+
+	Month                 string `json:"month"`
+	MonthBytes            int64  `json:"monthBytes"`
+	MonthlyThresholdBytes int64  `json:"monthlyThresholdBytes"` // traffic.abuse_monthly_gb
+
+	Devices []DataUserTrafficDevice `json:"devices"` // 按总量降序
+	Daily   []DataUserTrafficDay    `json:"daily"`   // 按日期、设备升序
+}
+
+// queryUserTraffic aggregates userID's DeviceTrafficDaily rows over the last
+// days accounting days (today included).
+func queryUserTraffic(userID uint, days int, now time.Time) (*DataUserTraffic, error) {
+	out := &DataUserTraffic{
+		Since:                 trafficDate(now.AddDate(0, 0, -(days - 1))),
+		Until:                 trafficDate(now),
+		Month:                 now.In(cnZone).Format("2006-01"),
+		MonthlyThresholdBytes: trafficAbuseThresholdBytes(),
+		Devices:               []DataUserTrafficDevice{},
+		Daily:                 []DataUserTrafficDay{},
+	}
+
+	if err := db.Get().Model(&DeviceTrafficDaily{}).
+		Select("date, udid, SUM(rx_bytes) AS rx_bytes, SUM(tx_bytes) AS tx_bytes").
+		Where("user_id = ? AND date BETWEEN ? AND ?", userID, out.Since, out.Until).
+		Group("date, udid").Order("date ASC, udid ASC").
+		Scan(&out.Daily).Error; err != nil {
+		return nil, err
+	}
+
+	byUDID := map[string]*DataUserTrafficDevice{}
+	for _, d := range out.Daily {
+		dev, ok := byUDID[d.UDID]
+		if !ok {
+			dev = &DataUserTrafficDevice{UDID: d.UDID}
+			byUDID[d.UDID] = dev
+		}
+		dev.RxBytes += d.RxBytes
+		dev.TxBytes += d.TxBytes
+		dev.TotalBytes += d.RxBytes + d.TxBytes
+		out.RxBytes += d.RxBytes
+		out.TxBytes += d.TxBytes
+	}
+	out.TotalBytes = out.RxBytes + out.TxBytes
+
+	if len(byUDID) > 0 {
+		udids := make([]string, 0, len(byUDID))
+		for udid := range byUDID {
+			udids = append(udids, udid)
+		}
+		var devices []Device
+		if err := db.Get().Where("user_id = ? AND udid IN ?", userID, udids).Find(&devices).Error; err != nil {
+			return nil, err
+		}
+		for _, d := range devices {
+			byUDID[d.UDID].Remark = d.Remark
+		}
+		for _, dev := range byUDID {
+			out.Devices = append(out.Devices, *dev)
+		}
+		sort.Slice(out.Devices, func(i, j int) bool {
+			if out.Devices[i].TotalBytes != out.Devices[j].TotalBytes {
+				return out.Devices[i].TotalBytes > out.Devices[j].TotalBytes
+			}
+			return out.Devices[i].UDID < out.Devices[j].UDID
+		})
+	}
+
+	// Same window as the abuse check: the CST calendar month so far.
+	start, end, err := trafficMonthRange(out.Month)
+	if err != nil {
+		return nil, err
+	}
+	var month struct{ Total int64 }
+	if err := db.Get().Model(&DeviceTrafficDaily{}).
+		Select("COALESCE(SUM(rx_bytes+tx_bytes),0) AS total").
+		Where("user_id = ? AND date BETWEEN ? AND ?", userID, start, end).
+		Scan(&month).Error; err != nil {
+		return nil, err
+	}
+	out.MonthBytes = month.Total
+	return out, nil
+}
+
+// api_get_user_traffic 我的流量（按设备、按日）
+//
+// GET /api/user/traffic?days=30   days: 1..60（明细保留期）
+func api_get_user_traffic(c *gin.Context) {
+	userID := ReqUserID(c)
+	days := defaultUserTrafficDays
+	if raw := c.Query("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > trafficRetentionDays {
+			Error(c, ErrorInvalidArgument, "days must be between 1 and "+strconv.Itoa(trafficRetentionDays))
+			return
+		}
+		days = n
+	}
+
+	out, err := queryUserTraffic(uint(userID), days, time.Now())
+	if err != nil {
+		log.Errorf(c, "user traffic for %d: %v", userID, err)
+		Error(c, ErrorSystemError, "query failed")
+		return
+	}
+	Success(c, out)
+}
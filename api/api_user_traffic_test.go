@@ -0,0 +1,65 @@
+package center
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+func TestQueryUserTraffic(t *testing.T) {
+	skipIfNoConfig(t)
+	now := time.Now()
+	today := trafficDate(now)
+	yesterday := trafficDate(now.AddDate(0, 0, -1))
+	old := trafficDate(now.AddDate(0, 0, -10))
+	t.Cleanup(func() {
+		db.Get().Where("node_ipv4 LIKE ?", "10.96.%").Delete(&DeviceTrafficDaily{})
+	})
+
+	user := CreateTestUser(t)
+	uid := uint(user.ID)
+	CreateTestDevice(t, user.ID, "usr-traffic-laptop")
+	seedTraffic(t, today, "usr-traffic-laptop", "10.96.0.1", uid, 100, 10)
+	seedTraffic(t, today, "usr-traffic-laptop", "10.96.0.2", uid, 50, 5) // same day, other node
+	seedTraffic(t, yesterday, "usr-traffic-laptop", "10.96.0.1", uid, 20, 2)
+	seedTraffic(t, today, "usr-traffic-gone", "10.96.0.1", uid, 300, 30) // device since removed
+	seedTraffic(t, old, "usr-traffic-laptop", "10.96.0.1", uid, 1000, 0) // outside a 7-day window
+	seedTraffic(t, today, "usr-traffic-other", "10.96.0.1", uid+1, 999, 999)
+
+	out, err := queryUserTraffic(uid, 7, now)
+	require.NoError(t, err)
+	assert.Equal(t, today, out.Until)
+	assert.Equal(t, int64(470), out.RxBytes)
+	assert.Equal(t, int64(47), out.TxBytes)
+	assert.Equal(t, int64(517), out.TotalBytes)
+
+	require.Len(t, out.Devices, 2)
+	assert.Equal(t, "usr-traffic-gone", out.Devices[0].UDID)
+	assert.Equal(t, int64(330), out.Devices[0].TotalBytes)
+	assert.Empty(t, out.Devices[0].Remark)
+	assert.Equal(t, "Test Device", out.Devices[1].Remark)
+	assert.Equal(t, int64(187), out.Devices[1].TotalBytes)
+
+	// One row per (day, device), nodes merged.
+	require.Len(t, out.Daily, 3)
+	assert.Equal(t, yesterday, out.Daily[0].Date)
+	assert.Equal(t, DataUserTrafficDay{Date: today, UDID: "usr-traffic-laptop", RxBytes: 150, TxBytes: 15}, out.Daily[2])
+
+	assert.Equal(t, trafficAbuseThresholdBytes(), out.MonthlyThresholdBytes)
+	assert.GreaterOrEqual(t, out.MonthBytes, int64(330+165))
+}
+
+func TestApiGetUserTraffic_DaysValidation(t *testing.T) {
+	r := SetupMinimalRouter()
+	r.GET("/api/user/traffic", api_get_user_traffic)
+
+	for _, days := range []string{"0", "61", "abc"} {
+		w := NewTestRequest("GET", "/api/user/traffic?days="+days).Execute(r)
+		resp, err := ParseResponse(w)
+		require.NoError(t, err)
+		assert.Equal(t, int(ErrorInvalidArgument), resp.Code, "days=%s", days)
+	}
+}
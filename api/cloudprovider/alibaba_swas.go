@@ -167,16 +167,18 @@ func (p *AlibabaSWASProvider) ListInstances(ctx context.Context) ([]*InstanceSta
 	}
 
 	statuses := make([]*InstanceStatus, 0, len(instances))
+	failed := listCollector{provider: ProviderAlibabaSWAS}
 	for _, inst := range instances {
 		status, err := p.GetInstanceStatus(ctx, inst.InstanceID)
 		if err != nil {
 			log.Warnf(ctx, "[ALIBABA] Failed to get status for %s: %v", inst.InstanceID, err)
+			failed.add(fmt.Errorf("instance %s: %w", inst.InstanceID, err))
 			continue
 		}
 		statuses = append(statuses, status)
 	}
 
-	return statuses, nil
+	return statuses, failed.err()
 }
 
 func (p *AlibabaSWASProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
@@ -476,7 +478,7 @@ func (p *AlibabaSWASProvider) ListImages(ctx context.Context, region string) ([]
 type MultiRegionAlibabaSWASProvider struct {
 	accessKeyID     string
 	accessKeySecret string
-	providers       map[string]*AlibabaSWASProvider
+	providers       map[string]Provider
 }
 
 // NewMultiRegionAlibabaSWASProvider creates a provider that manages instances across all Alibaba SWAS international regions
@@ -484,7 +486,7 @@ func NewMultiRegionAlibabaSWASProvider(accessKeyID, accessKeySecret string) (*Mu
 	mp := &MultiRegionAlibabaSWASProvider{
 		accessKeyID:     accessKeyID,
 		accessKeySecret: accessKeySecret,
-		providers:       make(map[string]*AlibabaSWASProvider),
+		providers:       make(map[string]Provider),
 	}
 
 	for _, region := range GetAlibabaSWASRegions() {
@@ -503,7 +505,14 @@ func (mp *MultiRegionAlibabaSWASProvider) Name() string {
 	return ProviderAlibabaSWAS
 }
 
-func (mp *MultiRegionAlibabaSWASProvider) getProviderForRegion(region string) *AlibabaSWASProvider {
+// guardParts implements fanOutProvider: each region is rate-limited on its own.
+func (mp *MultiRegionAlibabaSWASProvider) guardParts(wrap func(Provider) Provider) {
+	for region, p := range mp.providers {
+		mp.providers[region] = wrap(p)
+	}
+}
+
+func (mp *MultiRegionAlibabaSWASProvider) getProviderForRegion(region string) Provider {
 	if p, ok := mp.providers[region]; ok {
 		return p
 	}
@@ -531,16 +540,20 @@ func (mp *MultiRegionAlibabaSWASProvider) GetInstanceStatus(ctx context.Context,
 func (mp *MultiRegionAlibabaSWASProvider) ListInstances(ctx context.Context) ([]*InstanceStatus, error) {
 	var allStatuses []*InstanceStatus
 
+	failed := listCollector{provider: ProviderAlibabaSWAS}
 	for region, p := range mp.providers {
 		statuses, err := p.ListInstances(ctx)
 		if err != nil {
 			log.Warnf(ctx, "[ALIBABA] Failed to list instances in region %s: %v", region, err)
-			continue
+			failed.add(fmt.Errorf("region %s: %w", region, err))
+			if !IsPartialList(err) {
+				continue
+			}
 		}
 		allStatuses = append(allStatuses, statuses...)
 	}
 
-	return allStatuses, nil
+	return allStatuses, failed.err()
 }
 
 func (mp *MultiRegionAlibabaSWASProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
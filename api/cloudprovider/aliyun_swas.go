@@ -169,16 +169,18 @@ func (p *AliyunSWASProvider) ListInstances(ctx context.Context) ([]*InstanceStat
 	}
 
 	statuses := make([]*InstanceStatus, 0, len(instances))
+	failed := listCollector{provider: ProviderAliyunSWAS}
 	for _, inst := range instances {
 		status, err := p.GetInstanceStatus(ctx, inst.InstanceID)
 		if err != nil {
 			log.Warnf(ctx, "[ALIYUN] Failed to get status for %s: %v", inst.InstanceID, err)
+			failed.add(fmt.Errorf("instance %s: %w", inst.InstanceID, err))
 			continue
 		}
 		statuses = append(statuses, status)
 	}
 
-	return statuses, nil
+	return statuses, failed.err()
 }
 
 func (p *AliyunSWASProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
@@ -495,7 +497,7 @@ func (p *AliyunSWASProvider) ListImages(ctx context.Context, region string) ([]I
 type MultiRegionAliyunSWASProvider struct {
 	accessKeyID     string
 	accessKeySecret string
-	providers       map[string]*AliyunSWASProvider // region -> provider
+	providers       map[string]Provider // region -> provider
 }
 
 // NewMultiRegionAliyunSWASProvider creates a provider that manages instances across all Aliyun SWAS regions
@@ -503,7 +505,7 @@ func NewMultiRegionAliyunSWASProvider(accessKeyID, accessKeySecret string) (*Mul
 	mp := &MultiRegionAliyunSWASProvider{
 		accessKeyID:     accessKeyID,
 		accessKeySecret: accessKeySecret,
-		providers:       make(map[string]*AliyunSWASProvider),
+		providers:       make(map[string]Provider),
 	}
 
 	// Create a provider for each known Aliyun SWAS region
@@ -523,7 +525,14 @@ func (mp *MultiRegionAliyunSWASProvider) Name() string {
 	return ProviderAliyunSWAS
 }
 
-func (mp *MultiRegionAliyunSWASProvider) getProviderForRegion(region string) *AliyunSWASProvider {
+// guardParts implements fanOutProvider: each region is rate-limited on its own.
+func (mp *MultiRegionAliyunSWASProvider) guardParts(wrap func(Provider) Provider) {
+	for region, p := range mp.providers {
+		mp.providers[region] = wrap(p)
+	}
+}
+
+func (mp *MultiRegionAliyunSWASProvider) getProviderForRegion(region string) Provider {
 	// Try direct match
 	if p, ok := mp.providers[region]; ok {
 		return p
@@ -554,16 +563,20 @@ func (mp *MultiRegionAliyunSWASProvider) GetInstanceStatus(ctx context.Context,
 func (mp *MultiRegionAliyunSWASProvider) ListInstances(ctx context.Context) ([]*InstanceStatus, error) {
 	var allStatuses []*InstanceStatus
 
+	failed := listCollector{provider: ProviderAliyunSWAS}
 	for region, p := range mp.providers {
 		statuses, err := p.ListInstances(ctx)
 		if err != nil {
 			log.Warnf(ctx, "[ALIYUN] Failed to list instances in region %s: %v", region, err)
-			continue
+			failed.add(fmt.Errorf("region %s: %w", region, err))
+			if !IsPartialList(err) {
+				continue
+			}
 		}
 		allStatuses = append(allStatuses, statuses...)
 	}
 
-	return allStatuses, nil
+	return allStatuses, failed.err()
 }
 
 func (mp *MultiRegionAliyunSWASProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
@@ -181,6 +181,7 @@ func (p *AWSLightsailProvider) ListInstances(ctx context.Context) ([]*InstanceSt
 	}
 
 	statuses := make([]*InstanceStatus, 0, len(result.Instances))
+	failed := listCollector{provider: ProviderAWSLightsail}
 	for _, inst := range result.Instances {
 		if inst.Name == nil {
 			continue
@@ -188,12 +189,13 @@ func (p *AWSLightsailProvider) ListInstances(ctx context.Context) ([]*InstanceSt
 		status, err := p.GetInstanceStatus(ctx, *inst.Name)
 		if err != nil {
 			log.Warnf(ctx, "[AWS] Failed to get status for %s: %v", *inst.Name, err)
+			failed.add(fmt.Errorf("instance %s: %w", *inst.Name, err))
 			continue
 		}
 		statuses = append(statuses, status)
 	}
 
-	return statuses, nil
+	return statuses, failed.err()
 }
 
 func (p *AWSLightsailProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
@@ -489,7 +491,7 @@ func (p *AWSLightsailProvider) ListImages(ctx context.Context, region string) ([
 type MultiRegionAWSLightsailProvider struct {
 	accessKeyID     string
 	secretAccessKey string
-	providers       map[string]*AWSLightsailProvider // region -> provider
+	providers       map[string]Provider // region -> provider
 }
 
 // NewMultiRegionAWSLightsailProvider creates a provider that manages instances across all AWS regions
@@ -497,7 +499,7 @@ func NewMultiRegionAWSLightsailProvider(accessKeyID, secretAccessKey string) (*M
 	mp := &MultiRegionAWSLightsailProvider{
 		accessKeyID:     accessKeyID,
 		secretAccessKey: secretAccessKey,
-		providers:       make(map[string]*AWSLightsailProvider),
+		providers:       make(map[string]Provider),
 	}
 
 	// Create a provider for each known AWS Lightsail region
@@ -521,7 +523,14 @@ func (mp *MultiRegionAWSLightsailProvider) Name() string {
 	return ProviderAWSLightsail
 }
 
-func (mp *MultiRegionAWSLightsailProvider) getProviderForRegion(region string) *AWSLightsailProvider {
+// guardParts implements fanOutProvider: each region is rate-limited on its own.
+func (mp *MultiRegionAWSLightsailProvider) guardParts(wrap func(Provider) Provider) {
+	for region, p := range mp.providers {
+		mp.providers[region] = wrap(p)
+	}
+}
+
+func (mp *MultiRegionAWSLightsailProvider) getProviderForRegion(region string) Provider {
 	// Try direct match
 	if p, ok := mp.providers[region]; ok {
 		return p
@@ -552,16 +561,20 @@ func (mp *MultiRegionAWSLightsailProvider) GetInstanceStatus(ctx context.Context
 func (mp *MultiRegionAWSLightsailProvider) ListInstances(ctx context.Context) ([]*InstanceStatus, error) {
 	var allStatuses []*InstanceStatus
 
+	failed := listCollector{provider: ProviderAWSLightsail}
 	for region, p := range mp.providers {
 		statuses, err := p.ListInstances(ctx)
 		if err != nil {
 			log.Warnf(ctx, "[AWS] Failed to list instances in region %s: %v", region, err)
-			continue
+			failed.add(fmt.Errorf("region %s: %w", region, err))
+			if !IsPartialList(err) {
+				continue
+			}
 		}
 		allStatuses = append(allStatuses, statuses...)
 	}
 
-	return allStatuses, nil
+	return allStatuses, failed.err()
 }
 
 func (mp *MultiRegionAWSLightsailProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
@@ -600,7 +613,11 @@ func (mp *MultiRegionAWSLightsailProvider) StopInstance(ctx context.Context, ins
 	for _, p := range mp.providers {
 		_, err := p.GetInstanceStatus(ctx, instanceID)
 		if err == nil {
-			return p.StopInstance(ctx, instanceID)
+			stopper, ok := p.(InstanceStopper)
+			if !ok {
+				return nil, &NotSupportedError{Provider: ProviderAWSLightsail, Operation: "StopInstance"}
+			}
+			return stopper.StopInstance(ctx, instanceID)
 		}
 	}
 	return nil, fmt.Errorf("instance not found: %s", instanceID)
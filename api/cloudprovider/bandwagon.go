@@ -267,21 +267,22 @@ type BandwagonInstanceConfig struct {
 
 // MultiBandwagonProvider manages multiple Bandwagon instances under one account
 type MultiBandwagonProvider struct {
-	instances []*BandwagonProvider
-	veidMap   map[string]*BandwagonProvider
+	veids   []string // config order
+	veidMap map[string]Provider
 }
 
 // NewMultiBandwagonProvider creates a provider that manages multiple Bandwagon instances
 func NewMultiBandwagonProvider(configs []BandwagonInstanceConfig) *MultiBandwagonProvider {
 	mp := &MultiBandwagonProvider{
-		instances: make([]*BandwagonProvider, 0, len(configs)),
-		veidMap:   make(map[string]*BandwagonProvider),
+		veids:   make([]string, 0, len(configs)),
+		veidMap: make(map[string]Provider),
 	}
 	for _, cfg := range configs {
 		if cfg.VEID != "" && cfg.APIKey != "" {
-			p := NewBandwagonProvider(cfg.VEID, cfg.APIKey)
-			mp.instances = append(mp.instances, p)
-			mp.veidMap[cfg.VEID] = p
+			if _, dup := mp.veidMap[cfg.VEID]; !dup {
+				mp.veids = append(mp.veids, cfg.VEID)
+			}
+			mp.veidMap[cfg.VEID] = NewBandwagonProvider(cfg.VEID, cfg.APIKey)
 		}
 	}
 	return mp
@@ -291,6 +292,13 @@ func (mp *MultiBandwagonProvider) Name() string {
 	return ProviderBandwagon
 }
 
+// guardParts implements fanOutProvider: each VEID is rate-limited on its own.
+func (mp *MultiBandwagonProvider) guardParts(wrap func(Provider) Provider) {
+	for veid, p := range mp.veidMap {
+		mp.veidMap[veid] = wrap(p)
+	}
+}
+
 func (mp *MultiBandwagonProvider) GetInstanceStatus(ctx context.Context, instanceID string) (*InstanceStatus, error) {
 	p, ok := mp.veidMap[instanceID]
 	if !ok {
@@ -301,15 +309,17 @@ func (mp *MultiBandwagonProvider) GetInstanceStatus(ctx context.Context, instanc
 
 func (mp *MultiBandwagonProvider) ListInstances(ctx context.Context) ([]*InstanceStatus, error) {
 	var allStatuses []*InstanceStatus
-	for _, p := range mp.instances {
-		statuses, err := p.ListInstances(ctx)
+	failed := listCollector{provider: ProviderBandwagon}
+	for _, veid := range mp.veids {
+		statuses, err := mp.veidMap[veid].ListInstances(ctx)
 		if err != nil {
-			log.Warnf(ctx, "[BANDWAGON] Failed to list instances for veid=%s: %v", p.veid, err)
+			log.Warnf(ctx, "[BANDWAGON] Failed to list instances for veid=%s: %v", veid, err)
+			failed.add(fmt.Errorf("veid %s: %w", veid, err))
 			continue
 		}
 		allStatuses = append(allStatuses, statuses...)
 	}
-	return allStatuses, nil
+	return allStatuses, failed.err()
 }
 
 func (mp *MultiBandwagonProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
@@ -330,8 +340,8 @@ func (mp *MultiBandwagonProvider) DeleteInstance(ctx context.Context, instanceID
 
 func (mp *MultiBandwagonProvider) ListRegions(ctx context.Context) ([]RegionInfo, error) {
 	// Use the first provider to get regions
-	if len(mp.instances) > 0 {
-		return mp.instances[0].ListRegions(ctx)
+	if len(mp.veids) > 0 {
+		return mp.veidMap[mp.veids[0]].ListRegions(ctx)
 	}
 	return nil, &NotSupportedError{Provider: ProviderBandwagon, Operation: "ListRegions"}
 }
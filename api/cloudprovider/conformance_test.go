@@ -0,0 +1,448 @@
+package cloudprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/lightsail"
+)
+
+// Provider conformance suite.
+//
+// Every real provider is driven through the same contract checks against
+// recorded HTTP fixtures in testdata/conformance/<provider>.json, so a
+// provider that drifts from what the cloud workers rely on (not-found is an
+// error, unsupported ops return *NotSupportedError, IPs parse, plan IDs are
+// unique...) fails here instead of in production sync.
+//
+// Re-recording against a real account:
+//
+//	CLOUDPROVIDER_RECORD=aws_lightsail CLOUDPROVIDER_KEY=... CLOUDPROVIDER_SECRET=... \
+//	  go test ./cloudprovider -run TestConformance/aws_lightsail
+//
+// Recording replaces the fixture's interactions with live responses. Review
+// the diff before committing: responses contain real instance IDs and IPs.
+
+// conformanceFixture is one provider's recorded session plus the facts the
+// suite may assume about it.
+type conformanceFixture struct {
+	Provider         string                 `json:"provider"`
+	Region           string                 `json:"region"`
+	KnownInstance    string                 `json:"knownInstance"`
+	MissingInstance  string                 `json:"missingInstance"`
+	SupportsChangeIP bool                   `json:"supportsChangeIP"`
+	Create           *CreateInstanceOptions `json:"create,omitempty"`
+	Interactions     []fixtureInteraction   `json:"interactions"`
+}
+
+// fixtureInteraction is one recorded API call. Match is a subset of the
+// request parameters (query string for Aliyun, JSON body for Tencent/AWS);
+// the first interaction whose action and match fit is replayed.
+type fixtureInteraction struct {
+	Action string            `json:"action"`
+	Match  map[string]any    `json:"match,omitempty"`
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body"`
+}
+
+// conformanceFactories build each real provider on top of an injected HTTP
+// transport. Providers without an entry must be listed in conformanceExempt.
+var conformanceFactories = map[string]func(region string, rt http.RoundTripper) (Provider, error){
+	ProviderAliyunSWAS: func(region string, rt http.RoundTripper) (Provider, error) {
+		p := NewAliyunSWASProvider(recordKey("AKFIXTURE"), recordSecret("fixture-secret"), region)
+		p.client = &http.Client{Transport: rt}
+		return p, nil
+	},
+	ProviderAlibabaSWAS: func(region string, rt http.RoundTripper) (Provider, error) {
+		p := NewAlibabaSWASProvider(recordKey("AKFIXTURE"), recordSecret("fixture-secret"), region)
+		p.client = &http.Client{Transport: rt}
+		return p, nil
+	},
+	ProviderTencentLighthouse: func(region string, rt http.RoundTripper) (Provider, error) {
+		p, err := NewTencentLighthouseProvider(recordKey("AKIDFIXTURE"), recordSecret("fixture-secret"), region)
+		if err != nil {
+			return nil, err
+		}
+		p.client.WithHttpTransport(rt)
+		return p, nil
+	},
+	ProviderAWSLightsail: func(region string, rt http.RoundTripper) (Provider, error) {
+		return &AWSLightsailProvider{
+			region: region,
+			client: lightsail.New(lightsail.Options{
+				Region:           region,
+				Credentials:      credentials.NewStaticCredentialsProvider(recordKey("AKIAFIXTURE"), recordSecret("fixture-secret"), ""),
+				HTTPClient:       &http.Client{Transport: rt},
+				RetryMaxAttempts: 1,
+			}),
+		}, nil
+	},
+}
+
+// conformanceExempt lists providers that can't be driven through recorded
+// HTTP fixtures, and why.
+var conformanceExempt = map[string]string{
+	ProviderBandwagon:        "bwh SDK client is built without an injectable HTTP transport",
+	ProviderQCloudLighthouse: "same implementation as tencent_lighthouse, only the name differs",
+	ProviderSSHStandalone:    "needs a DB, not HTTP; covered by ssh_standalone_test.go",
+	ProviderFake:             "in-memory; runs the suite directly in TestConformance_Fake",
+}
+
+func recordKey(fallback string) string {
+	if os.Getenv("CLOUDPROVIDER_RECORD") != "" {
+		return os.Getenv("CLOUDPROVIDER_KEY")
+	}
+	return fallback
+}
+
+func recordSecret(fallback string) string {
+	if os.Getenv("CLOUDPROVIDER_RECORD") != "" {
+		return os.Getenv("CLOUDPROVIDER_SECRET")
+	}
+	return fallback
+}
+
+// requestAction extracts the API action and parameters from a provider
+// request: Tencent sends X-TC-Action, AWS sends X-Amz-Target, Aliyun uses the
+// Action query parameter.
+func requestAction(req *http.Request) (string, map[string]any, error) {
+	params := map[string]any{}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return "", nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &params); err != nil {
+				return "", nil, fmt.Errorf("decode request body: %w", err)
+			}
+		}
+	}
+	if a := req.Header.Get("X-TC-Action"); a != "" {
+		return a, params, nil
+	}
+	if t := req.Header.Get("X-Amz-Target"); t != "" {
+		return t[strings.LastIndex(t, ".")+1:], params, nil
+	}
+	for k, v := range req.URL.Query() {
+		params[k] = v[0]
+	}
+	return req.URL.Query().Get("Action"), params, nil
+}
+
+// matchParams reports whether every key in want equals the request value,
+// compared after a JSON round trip so numbers and lists line up.
+func matchParams(want, got map[string]any) bool {
+	for k, w := range want {
+		g, ok := got[k]
+		if !ok {
+			return false
+		}
+		wj, _ := json.Marshal(w)
+		gj, _ := json.Marshal(g)
+		var wv, gv any
+		json.Unmarshal(wj, &wv)
+		json.Unmarshal(gj, &gv)
+		if !reflect.DeepEqual(wv, gv) {
+			return false
+		}
+	}
+	return true
+}
+
+// replayTransport answers requests from a fixture. An unrecorded call fails
+// the test: the fixture must cover everything the provider does.
+type replayTransport struct {
+	t            *testing.T
+	interactions []fixtureInteraction
+}
+
+func (r *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	action, params, err := requestAction(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range r.interactions {
+		if it.Action != action || !matchParams(it.Match, params) {
+			continue
+		}
+		status := it.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		h := http.Header{"Content-Type": []string{"application/json"}}
+		for k, v := range it.Header {
+			h.Set(k, v)
+		}
+		return &http.Response{
+			StatusCode: status,
+			Status:     http.StatusText(status),
+			Header:     h,
+			Body:       io.NopCloser(bytes.NewReader(it.Body)),
+			Request:    req,
+		}, nil
+	}
+	r.t.Errorf("unrecorded %s call %s with params %v", req.URL.Host, action, params)
+	return nil, fmt.Errorf("no fixture for action %s", action)
+}
+
+// recordTransport forwards to the real API and captures each exchange.
+type recordTransport struct {
+	mu           sync.Mutex
+	interactions []fixtureInteraction
+}
+
+func (r *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	action, _, err := requestAction(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	r.mu.Lock()
+	r.interactions = append(r.interactions, fixtureInteraction{Action: action, Status: resp.StatusCode, Body: body})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func loadConformanceFixture(t *testing.T, path string) *conformanceFixture {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var f conformanceFixture
+	if err := json.Unmarshal(raw, &f); err != nil {
+		t.Fatalf("parse fixture %s: %v", path, err)
+	}
+	return &f
+}
+
+func TestConformance(t *testing.T) {
+	paths, _ := filepath.Glob(filepath.Join("testdata", "conformance", "*.json"))
+	covered := map[string]bool{}
+	for _, path := range paths {
+		f := loadConformanceFixture(t, path)
+		covered[f.Provider] = true
+		t.Run(f.Provider, func(t *testing.T) {
+			factory, ok := conformanceFactories[f.Provider]
+			if !ok {
+				t.Fatalf("no conformance factory for %s", f.Provider)
+			}
+			if os.Getenv("CLOUDPROVIDER_RECORD") == f.Provider {
+				rec := &recordTransport{}
+				p, err := factory(f.Region, rec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				runProviderConformance(t, p, f)
+				f.Interactions = rec.interactions
+				out, _ := json.MarshalIndent(f, "", "  ")
+				if err := os.WriteFile(path, append(out, '\n'), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			p, err := factory(f.Region, &replayTransport{t: t, interactions: f.Interactions})
+			if err != nil {
+				t.Fatal(err)
+			}
+			runProviderConformance(t, p, f)
+		})
+	}
+
+	for name := range conformanceFactories {
+		if !covered[name] {
+			t.Errorf("provider %s has a factory but no fixture in testdata/conformance", name)
+		}
+	}
+	for _, name := range []string{ProviderAliyunSWAS, ProviderAlibabaSWAS, ProviderAWSLightsail,
+		ProviderBandwagon, ProviderTencentLighthouse, ProviderQCloudLighthouse, ProviderSSHStandalone, ProviderFake} {
+		if _, ok := conformanceFactories[name]; !ok && conformanceExempt[name] == "" {
+			t.Errorf("provider %s is neither covered by the conformance suite nor exempt", name)
+		}
+	}
+}
+
+func TestConformance_Fake(t *testing.T) {
+	p := NewFakeProvider(FakeOptions{})
+	known := p.Seed(InstanceStatus{Region: "eu-frankfurt"})
+	runProviderConformance(t, p, &conformanceFixture{
+		Provider:         ProviderFake,
+		KnownInstance:    known,
+		MissingInstance:  "fake-missing",
+		SupportsChangeIP: true,
+		Create:           &CreateInstanceOptions{Region: "ap-tokyo", Plan: "fake-2tb", ImageID: "ubuntu-22.04", Name: "conformance"},
+	})
+}
+
+// runProviderConformance checks the contract the cloud workers rely on.
+func runProviderConformance(t *testing.T, p Provider, f *conformanceFixture) {
+	ctx := context.Background()
+
+	t.Run("Name", func(t *testing.T) {
+		if p.Name() != f.Provider {
+			t.Errorf("Name() = %q, want %q", p.Name(), f.Provider)
+		}
+	})
+
+	t.Run("ListInstances", func(t *testing.T) {
+		list, err := p.ListInstances(ctx)
+		if err != nil {
+			t.Fatalf("ListInstances: %v", err)
+		}
+		seen := map[string]bool{}
+		for _, st := range list {
+			checkInstanceStatus(t, st)
+			if seen[st.InstanceID] {
+				t.Errorf("duplicate instance %s", st.InstanceID)
+			}
+			seen[st.InstanceID] = true
+		}
+		if !seen[f.KnownInstance] {
+			t.Errorf("known instance %s missing from ListInstances", f.KnownInstance)
+		}
+	})
+
+	t.Run("GetInstanceStatus", func(t *testing.T) {
+		st, err := p.GetInstanceStatus(ctx, f.KnownInstance)
+		if err != nil {
+			t.Fatalf("GetInstanceStatus: %v", err)
+		}
+		if st.InstanceID != f.KnownInstance {
+			t.Errorf("InstanceID = %q, want %q", st.InstanceID, f.KnownInstance)
+		}
+		checkInstanceStatus(t, st)
+	})
+
+	t.Run("GetInstanceStatus_NotFound", func(t *testing.T) {
+		// Orphan detection and change-IP both depend on a missing instance
+		// being an error, never a zero-value status.
+		st, err := p.GetInstanceStatus(ctx, f.MissingInstance)
+		if err == nil {
+			t.Errorf("missing instance returned %+v, want error", st)
+		}
+	})
+
+	t.Run("ListRegions", func(t *testing.T) {
+		regions, err := p.ListRegions(ctx)
+		if err != nil {
+			t.Fatalf("ListRegions: %v", err)
+		}
+		for _, r := range regions {
+			if r.Slug == "" || r.ProviderID == "" {
+				t.Errorf("region %+v lacks slug or provider ID", r)
+			}
+		}
+	})
+
+	t.Run("ListPlans", func(t *testing.T) {
+		plans, err := p.ListPlans(ctx, f.Region)
+		if err != nil {
+			t.Fatalf("ListPlans: %v", err)
+		}
+		if len(plans) == 0 {
+			t.Fatal("no plans")
+		}
+		ids := map[string]bool{}
+		for _, pl := range plans {
+			if pl.ID == "" || ids[pl.ID] {
+				t.Errorf("plan ID %q empty or duplicated", pl.ID)
+			}
+			ids[pl.ID] = true
+			if pl.TransferTB < 0 || pl.PriceMonthly < 0 {
+				t.Errorf("plan %s has negative transfer/price: %+v", pl.ID, pl)
+			}
+		}
+	})
+
+	t.Run("ListImages", func(t *testing.T) {
+		images, err := p.ListImages(ctx, f.Region)
+		if err != nil {
+			t.Fatalf("ListImages: %v", err)
+		}
+		for _, img := range images {
+			if img.ID == "" {
+				t.Errorf("image without ID: %+v", img)
+			}
+		}
+	})
+
+	t.Run("ChangeIP", func(t *testing.T) {
+		res, err := p.ChangeIP(ctx, f.KnownInstance, ChangeIPOptions{})
+		if !f.SupportsChangeIP {
+			if !IsNotSupported(err) {
+				t.Errorf("unsupported ChangeIP must return *NotSupportedError, got %v", err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("ChangeIP: %v", err)
+		}
+		if res == nil || !res.Success {
+			t.Fatalf("ChangeIP result %+v, want success", res)
+		}
+		// new_ip may be absent for asynchronous providers, but if present it
+		// must be an address: the change-IP workflow persists it verbatim.
+		if ip, ok := res.Data["new_ip"].(string); ok && ip != "" && net.ParseIP(ip) == nil {
+			t.Errorf("new_ip %q is not an IP", ip)
+		}
+	})
+
+	if f.Create == nil {
+		return
+	}
+	t.Run("CreateDelete", func(t *testing.T) {
+		res, err := p.CreateInstance(ctx, *f.Create)
+		if err != nil {
+			t.Fatalf("CreateInstance: %v", err)
+		}
+		if res == nil || !res.Success || len(res.Data) == 0 {
+			t.Fatalf("CreateInstance result %+v, want success with identifying data", res)
+		}
+		if _, err := p.DeleteInstance(ctx, f.KnownInstance); err != nil {
+			t.Errorf("DeleteInstance: %v", err)
+		}
+		if _, err := p.DeleteInstance(ctx, f.MissingInstance); err == nil {
+			t.Error("deleting a missing instance must fail")
+		}
+	})
+}
+
+func checkInstanceStatus(t *testing.T, st *InstanceStatus) {
+	t.Helper()
+	if st.InstanceID == "" {
+		t.Errorf("status without InstanceID: %+v", st)
+	}
+	if st.IPAddress != "" && net.ParseIP(st.IPAddress).To4() == nil {
+		t.Errorf("%s: IPAddress %q is not IPv4", st.InstanceID, st.IPAddress)
+	}
+	if st.State == "running" && st.IPAddress == "" {
+		t.Errorf("%s: running instance without IPv4", st.InstanceID)
+	}
+	if st.TrafficUsedBytes < 0 || st.TrafficTotalBytes < 0 {
+		t.Errorf("%s: negative traffic %d/%d", st.InstanceID, st.TrafficUsedBytes, st.TrafficTotalBytes)
+	}
+}
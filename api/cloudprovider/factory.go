@@ -83,6 +83,10 @@ func NewProvider(cfg ProviderConfig) (Provider, error) {
 		// Use NewSSHStandaloneProvider() directly instead of this factory
 		return nil, fmt.Errorf("ssh_standalone provider requires NewSSHStandaloneProvider(accountName, db)")
 
+	case ProviderFake:
+		// In-memory fake, shared per access key so state survives across tasks
+		return FakeProviderFor(cfg.AccessKeyID), nil
+
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", cfg.Provider)
 	}
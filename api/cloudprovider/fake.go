@@ -0,0 +1,424 @@
+package cloudprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProviderFake is the in-memory provider used by tests and local development.
+// Never configure it for a production account.
+const ProviderFake = "fake"
+
+// ErrFakeThrottled looks like a provider rate-limit error (IsThrottled matches
+// it), so FailNext can exercise the Guard's retry path.
+var ErrFakeThrottled = errors.New("Throttling: Rate exceeded (fake)")
+
+// fakePlans are the bundles the fake sells. TransferTB drives the allowance
+// of instances created with that plan.
+var fakePlans = []PlanInfo{
+	{ID: "fake-1tb", Name: "1 Core / 1 GB RAM", CPU: 1, MemoryMB: 1024, StorageGB: 40, TransferTB: 1, PriceMonthly: 5},
+	{ID: "fake-2tb", Name: "2 Core / 2 GB RAM", CPU: 2, MemoryMB: 2048, StorageGB: 60, TransferTB: 2, PriceMonthly: 10},
+	{ID: "fake-4tb", Name: "2 Core / 4 GB RAM", CPU: 2, MemoryMB: 4096, StorageGB: 80, TransferTB: 4, PriceMonthly: 20},
+}
+
+var fakeImages = []ImageInfo{
+	{ID: "ubuntu-22.04", Name: "Ubuntu 22.04 LTS", OS: "linux", Platform: "ubuntu"},
+	{ID: "debian-12", Name: "Debian 12", OS: "linux", Platform: "debian"},
+}
+
+const fakeBytesPerTB = int64(1) << 40
+
+// FakeOptions tunes a FakeProvider.
+type FakeOptions struct {
+	Now            func() time.Time // 时钟,默认 time.Now;测试注入可控时钟
+	OpDelay        time.Duration    // create / ChangeIP / delete 的异步窗口,0 = 立即完成
+	TrafficPerHour int64            // 每台 running 实例每小时增长的流量(字节)
+}
+
+// FakeProvider is a stateful in-memory Provider. It simulates what the cloud
+// workers care about: monthly traffic that grows with time and resets on the
+// 1st (UTC), IP changes, asynchronous operations that settle after OpDelay,
+// and injected failures (FailNext). It also implements InstanceStopper.
+type FakeProvider struct {
+	mu        sync.Mutex
+	opts      FakeOptions
+	instances map[string]*fakeInstance
+	seq       int
+	failures  map[string][]error
+	calls     map[string]int
+}
+
+type fakeInstance struct {
+	status   InstanceStatus
+	lastTick time.Time // 流量已计到此刻
+	readyAt  time.Time // 进行中的异步操作完成时刻,零值 = 无
+	nextIP   string    // ChangeIP 目标,readyAt 时生效
+	deleting bool
+}
+
+// NewFakeProvider creates an empty fake.
+func NewFakeProvider(opts FakeOptions) *FakeProvider {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &FakeProvider{
+		opts:      opts,
+		instances: make(map[string]*fakeInstance),
+		failures:  make(map[string][]error),
+		calls:     make(map[string]int),
+	}
+}
+
+var (
+	fakeRegistry   = map[string]*FakeProvider{}
+	fakeRegistryMu sync.Mutex
+)
+
+// FakeProviderFor returns the shared fake for an account key, creating it on
+// first use. NewProvider routes ProviderFake here, so workers that rebuild the
+// provider for every task still see one consistent account state.
+func FakeProviderFor(key string) *FakeProvider {
+	fakeRegistryMu.Lock()
+	defer fakeRegistryMu.Unlock()
+	p, ok := fakeRegistry[key]
+	if !ok {
+		p = NewFakeProvider(FakeOptions{})
+		fakeRegistry[key] = p
+	}
+	return p
+}
+
+// SetFakeProvider installs a pre-configured fake under an account key.
+func SetFakeProvider(key string, p *FakeProvider) {
+	fakeRegistryMu.Lock()
+	defer fakeRegistryMu.Unlock()
+	fakeRegistry[key] = p
+}
+
+// ResetFakeProviders drops all shared fakes.
+func ResetFakeProviders() {
+	fakeRegistryMu.Lock()
+	defer fakeRegistryMu.Unlock()
+	fakeRegistry = map[string]*FakeProvider{}
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+// Seed adds a running instance directly, bypassing CreateInstance. Empty
+// fields get defaults (ID, IP, 1TB allowance, reset on the next 1st). Returns
+// the instance ID.
+func (p *FakeProvider) Seed(st InstanceStatus) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.opts.Now()
+	if st.InstanceID == "" {
+		st.InstanceID = p.nextID()
+	}
+	if st.Name == "" {
+		st.Name = st.InstanceID
+	}
+	if st.IPAddress == "" {
+		st.IPAddress = p.nextIP()
+	}
+	if st.TrafficTotalBytes == 0 {
+		st.TrafficTotalBytes = fakeBytesPerTB
+	}
+	if st.TrafficResetAt.IsZero() {
+		st.TrafficResetAt = fakeNextReset(now)
+	}
+	if st.State == "" {
+		st.State = "running"
+	}
+	p.instances[st.InstanceID] = &fakeInstance{status: st, lastTick: now}
+	return st.InstanceID
+}
+
+// FailNext queues errors returned by the next calls of op (a Provider method
+// name, e.g. "ListInstances"). A nil entry lets that call through.
+func (p *FakeProvider) FailNext(op string, errs ...error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures[op] = append(p.failures[op], errs...)
+}
+
+// Calls returns how many times op was invoked, failed calls included.
+func (p *FakeProvider) Calls(op string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[op]
+}
+
+// begin records a call and pops a queued failure. Caller holds mu.
+func (p *FakeProvider) begin(op string) error {
+	p.calls[op]++
+	q := p.failures[op]
+	if len(q) == 0 {
+		return nil
+	}
+	p.failures[op] = q[1:]
+	return q[0]
+}
+
+func (p *FakeProvider) nextID() string {
+	p.seq++
+	return fmt.Sprintf("fake-%d", p.seq)
+}
+
+// nextIP hands out addresses from 198.18.0.0/15 (RFC 2544 benchmarking range).
+func (p *FakeProvider) nextIP() string {
+	p.seq++
+	return fmt.Sprintf("198.18.%d.%d", (p.seq/250)%256, p.seq%250+1)
+}
+
+func fakeNextReset(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// settle advances one instance to now: completes a due async operation, rolls
+// the traffic cycle and accrues traffic. Returns false once the instance is
+// gone. Caller holds mu.
+func (p *FakeProvider) settle(id string, now time.Time) bool {
+	inst := p.instances[id]
+	if inst == nil {
+		return false
+	}
+	if !inst.readyAt.IsZero() && !now.Before(inst.readyAt) {
+		if inst.deleting {
+			delete(p.instances, id)
+			return false
+		}
+		if inst.nextIP != "" {
+			inst.status.IPAddress = inst.nextIP
+			inst.nextIP = ""
+		}
+		if inst.status.IPAddress == "" {
+			inst.status.IPAddress = p.nextIP()
+		}
+		inst.status.State = "running"
+		inst.readyAt = time.Time{}
+	}
+	st := &inst.status
+	if !st.TrafficResetAt.IsZero() && !now.Before(st.TrafficResetAt) {
+		st.TrafficUsedBytes = 0
+		if inst.lastTick.Before(st.TrafficResetAt) {
+			inst.lastTick = st.TrafficResetAt
+		}
+		st.TrafficResetAt = fakeNextReset(now)
+	}
+	if st.State == "running" && p.opts.TrafficPerHour > 0 && now.After(inst.lastTick) {
+		st.TrafficUsedBytes += int64(float64(p.opts.TrafficPerHour) * now.Sub(inst.lastTick).Hours())
+	}
+	inst.lastTick = now
+	return true
+}
+
+// startOp puts an instance into a transitional state until OpDelay passes.
+func (p *FakeProvider) startOp(id, state string, now time.Time) {
+	inst := p.instances[id]
+	inst.status.State = state
+	inst.readyAt = now.Add(p.opts.OpDelay)
+	p.settle(id, now)
+}
+
+func (p *FakeProvider) GetInstanceStatus(ctx context.Context, instanceID string) (*InstanceStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.begin("GetInstanceStatus"); err != nil {
+		return nil, err
+	}
+	if !p.settle(instanceID, p.opts.Now()) {
+		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	}
+	st := p.instances[instanceID].status
+	return &st, nil
+}
+
+func (p *FakeProvider) ListInstances(ctx context.Context) ([]*InstanceStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.begin("ListInstances"); err != nil {
+		return nil, err
+	}
+	now := p.opts.Now()
+	ids := make([]string, 0, len(p.instances))
+	for id := range p.instances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	out := make([]*InstanceStatus, 0, len(ids))
+	for _, id := range ids {
+		if !p.settle(id, now) {
+			continue
+		}
+		st := p.instances[id].status
+		out = append(out, &st)
+	}
+	return out, nil
+}
+
+// ChangeIP allocates the new address up front and reports it in Data, like
+// Lightsail; the instance keeps its old IP ("migrating") until OpDelay passes.
+func (p *FakeProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.begin("ChangeIP"); err != nil {
+		return nil, err
+	}
+	now := p.opts.Now()
+	if !p.settle(instanceID, now) {
+		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	}
+	inst := p.instances[instanceID]
+	if !inst.readyAt.IsZero() {
+		return nil, fmt.Errorf("instance %s is busy (%s)", instanceID, inst.status.State)
+	}
+	newIP := p.nextIP()
+	inst.nextIP = newIP
+	if opts.TargetRegion != "" {
+		inst.status.Region = opts.TargetRegion
+	}
+	p.startOp(instanceID, "migrating", now)
+	return &OperationResult{
+		Success: true,
+		Message: "IP change initiated",
+		Data:    map[string]any{"new_ip": newIP},
+	}, nil
+}
+
+func (p *FakeProvider) CreateInstance(ctx context.Context, opts CreateInstanceOptions) (*OperationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.begin("CreateInstance"); err != nil {
+		return nil, err
+	}
+	var plan *PlanInfo
+	for i := range fakePlans {
+		if fakePlans[i].ID == opts.Plan {
+			plan = &fakePlans[i]
+		}
+	}
+	if plan == nil {
+		return nil, fmt.Errorf("unknown plan: %s", opts.Plan)
+	}
+	if !fakeHasImage(opts.ImageID) {
+		return nil, fmt.Errorf("unknown image: %s", opts.ImageID)
+	}
+	for _, inst := range p.instances {
+		if opts.Name != "" && inst.status.Name == opts.Name {
+			return nil, fmt.Errorf("instance name already in use: %s", opts.Name)
+		}
+	}
+
+	now := p.opts.Now()
+	id := p.nextID()
+	name := opts.Name
+	if name == "" {
+		name = id
+	}
+	p.instances[id] = &fakeInstance{
+		status: InstanceStatus{
+			InstanceID:        id,
+			Name:              name,
+			Region:            opts.Region,
+			TrafficTotalBytes: int64(plan.TransferTB * float64(fakeBytesPerTB)),
+			TrafficResetAt:    fakeNextReset(now),
+		},
+		lastTick: now,
+	}
+	p.startOp(id, "pending", now)
+	return &OperationResult{
+		Success: true,
+		Message: "Instance creation initiated",
+		Data:    map[string]any{"instance_id": id, "instance_name": name},
+	}, nil
+}
+
+func (p *FakeProvider) DeleteInstance(ctx context.Context, instanceID string) (*OperationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.begin("DeleteInstance"); err != nil {
+		return nil, err
+	}
+	now := p.opts.Now()
+	if !p.settle(instanceID, now) {
+		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	}
+	p.instances[instanceID].deleting = true
+	p.startOp(instanceID, "deleting", now)
+	return &OperationResult{
+		Success: true,
+		Message: "Instance deletion initiated",
+	}, nil
+}
+
+// StopInstance implements InstanceStopper. Stopped instances accrue no traffic.
+func (p *FakeProvider) StopInstance(ctx context.Context, instanceID string) (*OperationResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.begin("StopInstance"); err != nil {
+		return nil, err
+	}
+	if !p.settle(instanceID, p.opts.Now()) {
+		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	}
+	p.instances[instanceID].status.State = "stopped"
+	return &OperationResult{
+		Success: true,
+		Message: "Instance stopped",
+	}, nil
+}
+
+// ListRegions offers every region in the unified registry, keyed by slug.
+func (p *FakeProvider) ListRegions(ctx context.Context) ([]RegionInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.begin("ListRegions"); err != nil {
+		return nil, err
+	}
+	regions := make([]RegionInfo, 0, len(AllRegions))
+	for _, r := range AllRegions {
+		regions = append(regions, RegionInfo{
+			Slug:       r.Slug,
+			NameEN:     r.NameEN,
+			NameZH:     r.NameZH,
+			Country:    r.Country,
+			ProviderID: r.Slug,
+			Available:  true,
+		})
+	}
+	return regions, nil
+}
+
+func (p *FakeProvider) ListPlans(ctx context.Context, region string) ([]PlanInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.begin("ListPlans"); err != nil {
+		return nil, err
+	}
+	return append([]PlanInfo(nil), fakePlans...), nil
+}
+
+func (p *FakeProvider) ListImages(ctx context.Context, region string) ([]ImageInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.begin("ListImages"); err != nil {
+		return nil, err
+	}
+	return append([]ImageInfo(nil), fakeImages...), nil
+}
+
+func fakeHasImage(id string) bool {
+	for _, img := range fakeImages {
+		if img.ID == id {
+			return true
+		}
+	}
+	return false
+}
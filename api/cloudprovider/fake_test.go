@@ -0,0 +1,156 @@
+package cloudprovider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for FakeOptions.Now.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestFake_TrafficGrowsAndResetsMonthly(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 3, 31, 20, 0, 0, 0, time.UTC)}
+	p := NewFakeProvider(FakeOptions{Now: clock.Now, TrafficPerHour: 1 << 30})
+	id := p.Seed(InstanceStatus{})
+	ctx := context.Background()
+
+	clock.Advance(2 * time.Hour)
+	st, err := p.GetInstanceStatus(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.TrafficUsedBytes != 2<<30 {
+		t.Fatalf("used = %d after 2h, want %d", st.TrafficUsedBytes, int64(2<<30))
+	}
+
+	// Crossing 04-01 00:00 UTC resets the cycle; only post-reset hours count.
+	clock.Advance(5 * time.Hour)
+	st, _ = p.GetInstanceStatus(ctx, id)
+	if st.TrafficUsedBytes != 3<<30 {
+		t.Fatalf("used = %d after reset, want %d", st.TrafficUsedBytes, int64(3<<30))
+	}
+	if want := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC); !st.TrafficResetAt.Equal(want) {
+		t.Fatalf("reset at %v, want %v", st.TrafficResetAt, want)
+	}
+
+	// Stopped instances stop accruing.
+	if _, err := p.StopInstance(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(10 * time.Hour)
+	st, _ = p.GetInstanceStatus(ctx, id)
+	if st.State != "stopped" || st.TrafficUsedBytes != 3<<30 {
+		t.Fatalf("stopped instance: state=%s used=%d", st.State, st.TrafficUsedBytes)
+	}
+}
+
+func TestFake_AsyncOperations(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 6, 10, 0, 0, 0, 0, time.UTC)}
+	p := NewFakeProvider(FakeOptions{Now: clock.Now, OpDelay: time.Minute})
+	ctx := context.Background()
+
+	res, err := p.CreateInstance(ctx, CreateInstanceOptions{Region: "ap-tokyo", Plan: "fake-2tb", ImageID: "debian-12", Name: "n1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := res.Data["instance_id"].(string)
+	st, _ := p.GetInstanceStatus(ctx, id)
+	if st.State != "pending" || st.IPAddress != "" {
+		t.Fatalf("fresh instance: state=%s ip=%q, want pending without IP", st.State, st.IPAddress)
+	}
+	if st.TrafficTotalBytes != 2*fakeBytesPerTB {
+		t.Fatalf("allowance = %d, want 2TB", st.TrafficTotalBytes)
+	}
+	if _, err := p.CreateInstance(ctx, CreateInstanceOptions{Plan: "fake-1tb", ImageID: "debian-12", Name: "n1"}); err == nil {
+		t.Fatal("duplicate name accepted")
+	}
+
+	clock.Advance(time.Minute)
+	st, _ = p.GetInstanceStatus(ctx, id)
+	if st.State != "running" || st.IPAddress == "" {
+		t.Fatalf("after OpDelay: state=%s ip=%q", st.State, st.IPAddress)
+	}
+	oldIP := st.IPAddress
+
+	res, err = p.ChangeIP(ctx, id, ChangeIPOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newIP := res.Data["new_ip"].(string)
+	st, _ = p.GetInstanceStatus(ctx, id)
+	if st.State != "migrating" || st.IPAddress != oldIP {
+		t.Fatalf("during ChangeIP: state=%s ip=%s, want migrating on %s", st.State, st.IPAddress, oldIP)
+	}
+	if _, err := p.ChangeIP(ctx, id, ChangeIPOptions{}); err == nil {
+		t.Fatal("ChangeIP on a busy instance accepted")
+	}
+	clock.Advance(time.Minute)
+	st, _ = p.GetInstanceStatus(ctx, id)
+	if st.State != "running" || st.IPAddress != newIP {
+		t.Fatalf("after ChangeIP: state=%s ip=%s, want running on %s", st.State, st.IPAddress, newIP)
+	}
+
+	if _, err := p.DeleteInstance(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := p.ListInstances(ctx)
+	if len(list) != 1 || list[0].State != "deleting" {
+		t.Fatalf("during delete: %+v", list)
+	}
+	clock.Advance(time.Minute)
+	if list, _ = p.ListInstances(ctx); len(list) != 0 {
+		t.Fatalf("instance still listed after delete: %+v", list)
+	}
+}
+
+func TestFake_FailNextThroughGuard(t *testing.T) {
+	p := NewFakeProvider(FakeOptions{})
+	p.Seed(InstanceStatus{})
+	p.FailNext("ListInstances", ErrFakeThrottled, ErrFakeThrottled)
+
+	got, err := testGuard(t, 5).Wrap(p).ListInstances(context.Background())
+	if err != nil {
+		t.Fatalf("expected guard to retry past throttling, got %v", err)
+	}
+	if len(got) != 1 || p.Calls("ListInstances") != 3 {
+		t.Fatalf("got %d instances after %d calls, want 1 after 3", len(got), p.Calls("ListInstances"))
+	}
+
+	boom := errors.New("boom")
+	p.FailNext("DeleteInstance", boom)
+	if _, err := p.DeleteInstance(context.Background(), "fake-1"); !errors.Is(err, boom) {
+		t.Fatalf("DeleteInstance err = %v, want injected error", err)
+	}
+}
+
+func TestFake_NewProviderSharesAccountState(t *testing.T) {
+	t.Cleanup(ResetFakeProviders)
+
+	a, err := NewProvider(ProviderConfig{Provider: ProviderFake, AccessKeyID: "acct-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := FakeProviderFor("acct-a").Seed(InstanceStatus{})
+
+	b, _ := NewProvider(ProviderConfig{Provider: ProviderFake, AccessKeyID: "acct-a"})
+	if a != b {
+		t.Fatal("same account key must return the same fake")
+	}
+	if _, err := b.GetInstanceStatus(context.Background(), id); err != nil {
+		t.Fatalf("seeded instance not visible through NewProvider: %v", err)
+	}
+	other, _ := NewProvider(ProviderConfig{Provider: ProviderFake, AccessKeyID: "acct-b"})
+	if list, _ := other.ListInstances(context.Background()); len(list) != 0 {
+		t.Fatalf("accounts leak state: %+v", list)
+	}
+
+	ResetFakeProviders()
+	if FakeProviderFor("acct-a") == a {
+		t.Fatal("ResetFakeProviders kept the old fake")
+	}
+}
@@ -0,0 +1,641 @@
+package cloudprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// ------------------------------------------------------------------
+// Provider middleware: per-account rate limit, throttle retries and
+// circuit breaking.
+//
+// Aliyun / Tencent / AWS throttle per account, not per call site, so the
+// state (token bucket, breaker, metrics) lives in a package-level registry
+// keyed by account name and survives across the short-lived Provider
+// values that the workers build for every task.
+//
+// Multi-region (and multi-VEID) providers fan one call out to a provider
+// per region. Those parts are guarded individually — each region call takes
+// its own token and retries on its own — while the breaker stays on the
+// aggregate, so a lookup that misses in other regions before finding the
+// instance is one success, not a string of failures.
+//
+//	p, _ := NewProvider(cfg)
+//	p = GuardFor(accountName, opts).Wrap(p)
+// ------------------------------------------------------------------
+
+// GuardOptions tunes the middleware for one account. Zero fields fall back
+// to DefaultGuardOptions.
+type GuardOptions struct {
+	RatePerSecond    float64       // Sustained provider API calls per second
+	Burst            int           // Token bucket capacity
+	MaxRetries       int           // Extra attempts on throttling errors (negative disables)
+	BaseBackoff      time.Duration // First retry delay (doubled per attempt, full jitter)
+	MaxBackoff       time.Duration // Retry delay cap
+	BreakerThreshold int           // Consecutive failures that open the breaker
+	BreakerCooldown  time.Duration // How long the breaker stays open before a probe
+}
+
+// DefaultGuardOptions are conservative enough for the smallest quota we
+// hold (Aliyun SWAS: 10 QPS per account, shared with the console).
+var DefaultGuardOptions = GuardOptions{
+	RatePerSecond:    5,
+	Burst:            10,
+	MaxRetries:       3,
+	BaseBackoff:      500 * time.Millisecond,
+	MaxBackoff:       10 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  5 * time.Minute,
+}
+
+func (o GuardOptions) withDefaults() GuardOptions {
+	d := DefaultGuardOptions
+	if o.RatePerSecond <= 0 {
+		o.RatePerSecond = d.RatePerSecond
+	}
+	if o.Burst <= 0 {
+		o.Burst = d.Burst
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = d.MaxRetries
+	} else if o.MaxRetries < 0 {
+		o.MaxRetries = 0 // explicit opt-out
+	}
+	if o.BaseBackoff <= 0 {
+		o.BaseBackoff = d.BaseBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = d.MaxBackoff
+	}
+	if o.BreakerThreshold <= 0 {
+		o.BreakerThreshold = d.BreakerThreshold
+	}
+	if o.BreakerCooldown <= 0 {
+		o.BreakerCooldown = d.BreakerCooldown
+	}
+	return o
+}
+
+// ErrCircuitOpen is returned without calling the provider while an
+// account's breaker is open.
+var ErrCircuitOpen = errors.New("cloud provider circuit open")
+
+// IsCircuitOpen checks if error is ErrCircuitOpen
+func IsCircuitOpen(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+// throttleCodes are provider error codes that mean "slow down, try again".
+var throttleCodes = []string{
+	"Throttling",           // Aliyun: Throttling, Throttling.User, Throttling.Api
+	"RequestLimitExceeded", // Tencent Cloud API 3.0
+	"ThrottlingException",  // AWS
+	"TooManyRequests",      // AWS TooManyRequestsException
+	"Rate exceeded",        // AWS message text
+	"status code: 429",
+	"StatusCode: 429",
+}
+
+// IsThrottled reports whether err (or any error it wraps) is a provider
+// throttling response worth retrying.
+func IsThrottled(err error) bool {
+	if err == nil {
+		return false
+	}
+	// AWS smithy errors expose ErrorCode(); Tencent SDK errors expose GetCode().
+	var awsCode interface{ ErrorCode() string }
+	if errors.As(err, &awsCode) && matchThrottleCode(awsCode.ErrorCode()) {
+		return true
+	}
+	var tcCode interface{ GetCode() string }
+	if errors.As(err, &tcCode) && matchThrottleCode(tcCode.GetCode()) {
+		return true
+	}
+	// PartialListError: throttled if any region/instance was throttled.
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range multi.Unwrap() {
+			if IsThrottled(e) {
+				return true
+			}
+		}
+	}
+	// Aliyun's hand-rolled client (and bwh) only give us the message.
+	return matchThrottleCode(err.Error())
+}
+
+func matchThrottleCode(s string) bool {
+	for _, code := range throttleCodes {
+		if strings.Contains(s, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// countsAsFailure decides whether an error should move the breaker.
+// Caller mistakes and unsupported operations say nothing about the
+// account's health, and neither does a partial list: one unreachable
+// region must not shut the account's healthy regions out.
+func countsAsFailure(err error) bool {
+	if err == nil || IsNotSupported(err) || IsPartialList(err) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	return true
+}
+
+// ------------------------------------------------------------------
+// Token bucket
+// ------------------------------------------------------------------
+
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64 // tokens per second
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// reserve takes one token and returns how long the caller must wait before
+// using it (zero when a token was available).
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait blocks until a token is available or ctx is done. Returns the time
+// spent waiting.
+func (b *tokenBucket) wait(ctx context.Context) (time.Duration, error) {
+	d := b.reserve()
+	if d <= 0 {
+		return 0, nil
+	}
+	if err := sleepCtx(ctx, d); err != nil {
+		// Give the token back — we never used it.
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return d, err
+	}
+	return d, nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// ------------------------------------------------------------------
+// Circuit breaker
+// ------------------------------------------------------------------
+
+// Breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     string
+	openedAt  time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+// allow reports whether a call may go through. After the cooldown a single
+// probe call is let through (half-open); its result closes or re-opens.
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return false
+		}
+		cb.state = BreakerHalfOpen
+		cb.probing = true
+		return true
+	case BreakerHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record feeds a call result back. Returns true when this result opened
+// the breaker.
+func (cb *circuitBreaker) record(failed bool) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+	if !failed {
+		cb.failures = 0
+		cb.state = BreakerClosed
+		return false
+	}
+
+	cb.failures++
+	if cb.state == BreakerHalfOpen || (cb.state == BreakerClosed && cb.failures >= cb.threshold) {
+		cb.state = BreakerOpen
+		cb.openedAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// release ends a half-open probe without a verdict: the call never reached
+// the provider (or was cancelled), so it says nothing about the account.
+func (cb *circuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+}
+
+func (cb *circuitBreaker) snapshot() (state string, failures int, openedAt time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state, cb.failures, cb.openedAt
+}
+
+// ------------------------------------------------------------------
+// Metrics
+// ------------------------------------------------------------------
+
+type guardMetrics struct {
+	calls           atomic.Int64 // Provider calls attempted (including retries)
+	failures        atomic.Int64 // Calls that returned a health-relevant error
+	throttled       atomic.Int64 // Calls rejected by the provider as throttled
+	retries         atomic.Int64 // Retry attempts after throttling
+	rateLimited     atomic.Int64 // Calls that had to wait for a token
+	rateLimitWaitMs atomic.Int64 // Total time spent waiting for tokens
+	breakerOpened   atomic.Int64 // Times the breaker tripped
+	breakerRejected atomic.Int64 // Calls short-circuited by an open breaker
+}
+
+// GuardMetrics is a point-in-time snapshot of one account's middleware
+// counters. Counters are cumulative since process start.
+type GuardMetrics struct {
+	Account         string `json:"account"`
+	Calls           int64  `json:"calls"`
+	Failures        int64  `json:"failures"`
+	Throttled       int64  `json:"throttled"`
+	Retries         int64  `json:"retries"`
+	RateLimited     int64  `json:"rate_limited"`
+	RateLimitWaitMs int64  `json:"rate_limit_wait_ms"`
+	BreakerOpened   int64  `json:"breaker_opened"`
+	BreakerRejected int64  `json:"breaker_rejected"`
+	BreakerState    string `json:"breaker_state"`
+	ConsecutiveFail int    `json:"consecutive_failures"`
+	BreakerOpenedAt int64  `json:"breaker_opened_at,omitempty"`
+}
+
+// ------------------------------------------------------------------
+// Guard registry
+// ------------------------------------------------------------------
+
+// Guard holds the shared middleware state for one provider account.
+type Guard struct {
+	account string
+	opts    GuardOptions
+	bucket  *tokenBucket
+	breaker *circuitBreaker
+	metrics guardMetrics
+}
+
+var (
+	guardsMu sync.Mutex
+	guards   = make(map[string]*Guard)
+)
+
+// GuardFor returns the account's Guard, creating it with opts on first use.
+// Later calls reuse the existing state; opts only apply at creation.
+func GuardFor(account string, opts GuardOptions) *Guard {
+	guardsMu.Lock()
+	defer guardsMu.Unlock()
+
+	if g, ok := guards[account]; ok {
+		return g
+	}
+	opts = opts.withDefaults()
+	g := &Guard{
+		account: account,
+		opts:    opts,
+		bucket:  newTokenBucket(opts.RatePerSecond, opts.Burst),
+		breaker: newCircuitBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
+	}
+	guards[account] = g
+	return g
+}
+
+// GuardMetricsSnapshot returns metrics for every account that has made at
+// least one guarded call, sorted by account name.
+func GuardMetricsSnapshot() []GuardMetrics {
+	guardsMu.Lock()
+	list := make([]*Guard, 0, len(guards))
+	for _, g := range guards {
+		list = append(list, g)
+	}
+	guardsMu.Unlock()
+
+	out := make([]GuardMetrics, 0, len(list))
+	for _, g := range list {
+		out = append(out, g.Metrics())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Account < out[j].Account })
+	return out
+}
+
+// Metrics returns a snapshot of this account's counters.
+func (g *Guard) Metrics() GuardMetrics {
+	state, failures, openedAt := g.breaker.snapshot()
+	m := GuardMetrics{
+		Account:         g.account,
+		Calls:           g.metrics.calls.Load(),
+		Failures:        g.metrics.failures.Load(),
+		Throttled:       g.metrics.throttled.Load(),
+		Retries:         g.metrics.retries.Load(),
+		RateLimited:     g.metrics.rateLimited.Load(),
+		RateLimitWaitMs: g.metrics.rateLimitWaitMs.Load(),
+		BreakerOpened:   g.metrics.breakerOpened.Load(),
+		BreakerRejected: g.metrics.breakerRejected.Load(),
+		BreakerState:    state,
+		ConsecutiveFail: failures,
+	}
+	if !openedAt.IsZero() {
+		m.BreakerOpenedAt = openedAt.Unix()
+	}
+	return m
+}
+
+// Degraded reports whether the account is currently unhealthy (breaker open
+// or probing).
+func (g *Guard) Degraded() bool {
+	state, _, _ := g.breaker.snapshot()
+	return state != BreakerClosed
+}
+
+// fanOutProvider is implemented by providers that spread calls over
+// per-region (or per-VEID) providers, so Wrap can rate-limit each part.
+type fanOutProvider interface {
+	guardParts(wrap func(Provider) Provider)
+}
+
+// Wrap returns p with this Guard's rate limit, retries and breaker applied.
+func (g *Guard) Wrap(p Provider) Provider {
+	p = unguarded(p)
+	if fo, ok := p.(fanOutProvider); ok {
+		fo.guardParts(g.limit)
+		return g.wrap(p, true, false)
+	}
+	return g.wrap(p, true, true)
+}
+
+// limit wraps one part of a fan-out provider with the rate limit and
+// retries only; the aggregate holds the breaker.
+func (g *Guard) limit(p Provider) Provider {
+	return g.wrap(unguarded(p), false, true)
+}
+
+// wrap keeps optional capabilities visible: the result satisfies
+// InstanceStopper only when p does, so callers' capability checks still work.
+func (g *Guard) wrap(p Provider, breaker, limit bool) Provider {
+	gp := &guardedProvider{inner: p, guard: g, breaker: breaker, limit: limit}
+	if _, ok := p.(InstanceStopper); ok {
+		return &guardedStopper{gp}
+	}
+	return gp
+}
+
+// unguarded strips a previous Wrap so guards never stack.
+func unguarded(p Provider) Provider {
+	switch gp := p.(type) {
+	case *guardedProvider:
+		return gp.inner
+	case *guardedStopper:
+		return gp.inner
+	}
+	return p
+}
+
+// backoff returns the full-jitter delay before retry attempt n (1-based).
+func (g *Guard) backoff(n int) time.Duration {
+	d := g.opts.BaseBackoff << (n - 1)
+	if d <= 0 || d > g.opts.MaxBackoff {
+		d = g.opts.MaxBackoff
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+// queuedError is returned when the context ends while waiting for a token:
+// the call never reached the provider.
+type queuedError struct{ err error }
+
+func (e *queuedError) Error() string { return e.err.Error() }
+func (e *queuedError) Unwrap() error { return e.err }
+
+// guardCall runs fn under the account's breaker. Cancelled calls and calls
+// that never left the token queue release a half-open probe without a
+// verdict.
+func guardCall[T any](ctx context.Context, g *Guard, op string, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+	if !g.breaker.allow() {
+		g.metrics.breakerRejected.Add(1)
+		return zero, fmt.Errorf("%s %s: %w", g.account, op, ErrCircuitOpen)
+	}
+
+	result, err := fn(ctx)
+	var queued *queuedError
+	if errors.As(err, &queued) || errors.Is(err, context.Canceled) {
+		// Not the account's fault, and no evidence it recovered either:
+		// only free the probe slot.
+		g.breaker.release()
+		return result, err
+	}
+	failed := countsAsFailure(err)
+	if failed {
+		g.metrics.failures.Add(1)
+	}
+	if g.breaker.record(failed) {
+		g.metrics.breakerOpened.Add(1)
+		log.Errorf(ctx, "[CLOUD] %s circuit opened after %s failure: %v", g.account, op, err)
+	}
+	return result, err
+}
+
+// limitCall runs fn under the account's token bucket and retry policy.
+// Only throttling errors are retried — anything else is returned to the
+// caller on the first attempt.
+func limitCall[T any](ctx context.Context, g *Guard, op string, fn func(context.Context) (T, error)) (T, error) {
+	var (
+		result T
+		err    error
+	)
+	for attempt := 0; ; attempt++ {
+		waited, werr := g.bucket.wait(ctx)
+		if waited > 0 {
+			g.metrics.rateLimited.Add(1)
+			g.metrics.rateLimitWaitMs.Add(waited.Milliseconds())
+		}
+		if werr != nil {
+			var zero T
+			return zero, &queuedError{err: werr}
+		}
+
+		g.metrics.calls.Add(1)
+		result, err = fn(ctx)
+		if err == nil || !IsThrottled(err) {
+			return result, err
+		}
+
+		g.metrics.throttled.Add(1)
+		if attempt >= g.opts.MaxRetries {
+			return result, err
+		}
+		delay := g.backoff(attempt + 1)
+		log.Warnf(ctx, "[CLOUD] %s %s throttled, retry %d/%d in %s: %v",
+			g.account, op, attempt+1, g.opts.MaxRetries, delay, err)
+		g.metrics.retries.Add(1)
+		if serr := sleepCtx(ctx, delay); serr != nil {
+			return result, err
+		}
+	}
+}
+
+// guarded applies the layers p is configured for to one provider call.
+func guarded[T any](ctx context.Context, p *guardedProvider, op string, fn func(context.Context) (T, error)) (T, error) {
+	call := fn
+	if p.limit {
+		call = func(ctx context.Context) (T, error) { return limitCall(ctx, p.guard, op, fn) }
+	}
+	if !p.breaker {
+		return call(ctx)
+	}
+	return guardCall(ctx, p.guard, op, call)
+}
+
+// guardedProvider is the Provider returned by Guard.Wrap (breaker and
+// limit) and set on fan-out parts (limit only).
+type guardedProvider struct {
+	inner   Provider
+	guard   *Guard
+	breaker bool // circuit breaker around each call
+	limit   bool // token bucket and throttle retries around each call
+}
+
+// Unwrap returns the underlying provider.
+func (p *guardedProvider) Unwrap() Provider {
+	return p.inner
+}
+
+func (p *guardedProvider) Name() string {
+	return p.inner.Name()
+}
+
+func (p *guardedProvider) GetInstanceStatus(ctx context.Context, instanceID string) (*InstanceStatus, error) {
+	return guarded(ctx, p, "GetInstanceStatus", func(ctx context.Context) (*InstanceStatus, error) {
+		return p.inner.GetInstanceStatus(ctx, instanceID)
+	})
+}
+
+func (p *guardedProvider) ListInstances(ctx context.Context) ([]*InstanceStatus, error) {
+	return guarded(ctx, p, "ListInstances", p.inner.ListInstances)
+}
+
+func (p *guardedProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
+	return guarded(ctx, p, "ChangeIP", func(ctx context.Context) (*OperationResult, error) {
+		return p.inner.ChangeIP(ctx, instanceID, opts)
+	})
+}
+
+func (p *guardedProvider) CreateInstance(ctx context.Context, opts CreateInstanceOptions) (*OperationResult, error) {
+	return guarded(ctx, p, "CreateInstance", func(ctx context.Context) (*OperationResult, error) {
+		return p.inner.CreateInstance(ctx, opts)
+	})
+}
+
+func (p *guardedProvider) DeleteInstance(ctx context.Context, instanceID string) (*OperationResult, error) {
+	return guarded(ctx, p, "DeleteInstance", func(ctx context.Context) (*OperationResult, error) {
+		return p.inner.DeleteInstance(ctx, instanceID)
+	})
+}
+
+func (p *guardedProvider) ListRegions(ctx context.Context) ([]RegionInfo, error) {
+	return guarded(ctx, p, "ListRegions", p.inner.ListRegions)
+}
+
+func (p *guardedProvider) ListPlans(ctx context.Context, region string) ([]PlanInfo, error) {
+	return guarded(ctx, p, "ListPlans", func(ctx context.Context) ([]PlanInfo, error) {
+		return p.inner.ListPlans(ctx, region)
+	})
+}
+
+func (p *guardedProvider) ListImages(ctx context.Context, region string) ([]ImageInfo, error) {
+	return guarded(ctx, p, "ListImages", func(ctx context.Context) ([]ImageInfo, error) {
+		return p.inner.ListImages(ctx, region)
+	})
+}
+
+// guardedStopper is the wrapper for providers that implement InstanceStopper.
+type guardedStopper struct {
+	*guardedProvider
+}
+
+func (p *guardedStopper) StopInstance(ctx context.Context, instanceID string) (*OperationResult, error) {
+	stopper := p.inner.(InstanceStopper)
+	return guarded(ctx, p.guardedProvider, "StopInstance", func(ctx context.Context) (*OperationResult, error) {
+		return stopper.StopInstance(ctx, instanceID)
+	})
+}
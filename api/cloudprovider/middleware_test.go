@@ -0,0 +1,325 @@
+package cloudprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// scriptedProvider returns queued ListInstances errors in order, then succeeds.
+type scriptedProvider struct {
+	errs  []error
+	calls int
+}
+
+func (p *scriptedProvider) Name() string { return "scripted" }
+func (p *scriptedProvider) GetInstanceStatus(ctx context.Context, instanceID string) (*InstanceStatus, error) {
+	return nil, &NotSupportedError{Provider: "scripted", Operation: "GetInstanceStatus"}
+}
+func (p *scriptedProvider) ListInstances(ctx context.Context) ([]*InstanceStatus, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []*InstanceStatus{{InstanceID: "i-1"}}, nil
+}
+func (p *scriptedProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
+	return nil, &NotSupportedError{Provider: "scripted", Operation: "ChangeIP"}
+}
+func (p *scriptedProvider) CreateInstance(ctx context.Context, opts CreateInstanceOptions) (*OperationResult, error) {
+	return nil, &NotSupportedError{Provider: "scripted", Operation: "CreateInstance"}
+}
+func (p *scriptedProvider) DeleteInstance(ctx context.Context, instanceID string) (*OperationResult, error) {
+	return nil, &NotSupportedError{Provider: "scripted", Operation: "DeleteInstance"}
+}
+func (p *scriptedProvider) ListRegions(ctx context.Context) ([]RegionInfo, error) { return nil, nil }
+func (p *scriptedProvider) ListPlans(ctx context.Context, region string) ([]PlanInfo, error) {
+	return nil, nil
+}
+func (p *scriptedProvider) ListImages(ctx context.Context, region string) ([]ImageInfo, error) {
+	return nil, nil
+}
+
+// scriptedFanOut lists every part like the multi-region providers do.
+type scriptedFanOut struct {
+	scriptedProvider
+	parts []Provider
+}
+
+func (p *scriptedFanOut) guardParts(wrap func(Provider) Provider) {
+	for i, part := range p.parts {
+		p.parts[i] = wrap(part)
+	}
+}
+
+func (p *scriptedFanOut) ListInstances(ctx context.Context) ([]*InstanceStatus, error) {
+	var all []*InstanceStatus
+	failed := listCollector{provider: "scripted"}
+	for i, part := range p.parts {
+		statuses, err := part.ListInstances(ctx)
+		if err != nil {
+			failed.add(fmt.Errorf("region %d: %w", i, err))
+			continue
+		}
+		all = append(all, statuses...)
+	}
+	return all, failed.err()
+}
+
+// testGuard builds a fast guard under a per-test account name so tests don't
+// share breaker state through the package registry.
+func testGuard(t *testing.T, threshold int) *Guard {
+	t.Helper()
+	return GuardFor(t.Name(), GuardOptions{
+		RatePerSecond:    1000,
+		Burst:            1000,
+		MaxRetries:       2,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       2 * time.Millisecond,
+		BreakerThreshold: threshold,
+		BreakerCooldown:  20 * time.Millisecond,
+	})
+}
+
+func TestIsThrottled(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("aliyun API error: Throttling.User - Request was denied due to user flow control."), true},
+		{fmt.Errorf("failed to list instances: %w", errors.New("[TencentCloudSDKError] Code=RequestLimitExceeded")), true},
+		{errors.New("operation error Lightsail: GetInstances, ThrottlingException: Rate exceeded"), true},
+		{errors.New("aliyun API error: InvalidAccessKeyId.NotFound - Specified access key is not found."), false},
+		{&PartialListError{Provider: "x", Errs: []error{errors.New("region a: Throttling")}}, true},
+		{&NotSupportedError{Provider: "x", Operation: "ChangeIP"}, false},
+	}
+	for _, tt := range tests {
+		if got := IsThrottled(tt.err); got != tt.want {
+			t.Errorf("IsThrottled(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestGuard_RetriesThrottling(t *testing.T) {
+	inner := &scriptedProvider{errs: []error{
+		errors.New("Throttling.User"),
+		errors.New("Throttling.User"),
+	}}
+	g := testGuard(t, 5)
+	p := g.Wrap(inner)
+
+	got, err := p.ListInstances(context.Background())
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if len(got) != 1 || inner.calls != 3 {
+		t.Fatalf("got %d instances after %d calls, want 1 after 3", len(got), inner.calls)
+	}
+	m := g.Metrics()
+	if m.Throttled != 2 || m.Retries != 2 || m.Failures != 0 {
+		t.Errorf("metrics = %+v, want throttled=2 retries=2 failures=0", m)
+	}
+}
+
+func TestGuard_DoesNotRetryOtherErrors(t *testing.T) {
+	inner := &scriptedProvider{errs: []error{errors.New("InvalidAccessKeyId")}}
+	g := testGuard(t, 5)
+
+	if _, err := g.Wrap(inner).ListInstances(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if inner.calls != 1 {
+		t.Errorf("non-throttle error retried: %d calls", inner.calls)
+	}
+}
+
+func TestGuard_CircuitBreaker(t *testing.T) {
+	boom := errors.New("connection refused")
+	inner := &scriptedProvider{errs: []error{boom, boom}}
+	g := testGuard(t, 2)
+	p := g.Wrap(inner)
+	ctx := context.Background()
+
+	p.ListInstances(ctx)
+	p.ListInstances(ctx)
+	if !g.Degraded() {
+		t.Fatal("breaker should be open after threshold failures")
+	}
+
+	if _, err := p.ListInstances(ctx); !IsCircuitOpen(err) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("open breaker must not call provider, calls=%d", inner.calls)
+	}
+
+	// After the cooldown a single probe goes through and closes the breaker.
+	time.Sleep(30 * time.Millisecond)
+	if _, err := p.ListInstances(ctx); err != nil {
+		t.Fatalf("probe call failed: %v", err)
+	}
+	if g.Degraded() {
+		t.Error("successful probe should close the breaker")
+	}
+	m := g.Metrics()
+	if m.BreakerOpened != 1 || m.BreakerRejected != 1 {
+		t.Errorf("metrics = %+v, want opened=1 rejected=1", m)
+	}
+}
+
+// TestGuard_CancelledProbeKeepsBreakerHalfOpen: a probe cancelled while
+// queued for a token or in flight says nothing about the account, so it must
+// neither close the breaker nor keep the probe slot.
+func TestGuard_CancelledProbeKeepsBreakerHalfOpen(t *testing.T) {
+	boom := errors.New("connection refused")
+	inner := &scriptedProvider{errs: []error{boom, boom, context.Canceled}}
+	g := testGuard(t, 2)
+	p := g.Wrap(inner)
+	ctx := context.Background()
+
+	p.ListInstances(ctx)
+	p.ListInstances(ctx)
+	time.Sleep(30 * time.Millisecond)
+
+	// Cancelled while waiting for a token.
+	g.bucket.mu.Lock()
+	g.bucket.tokens, g.bucket.last = -10, time.Now()
+	g.bucket.mu.Unlock()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := p.ListInstances(cancelled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if state, _, _ := g.breaker.snapshot(); state != BreakerHalfOpen || inner.calls != 2 {
+		t.Fatalf("cancelled wait must leave the breaker half-open without a call, got %s after %d calls", state, inner.calls)
+	}
+	g.bucket.mu.Lock()
+	g.bucket.tokens = g.bucket.capacity
+	g.bucket.mu.Unlock()
+
+	// Cancelled in flight.
+	if _, err := p.ListInstances(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if !g.Degraded() {
+		t.Fatal("cancelled probe must not close the breaker")
+	}
+
+	// The probe slot was freed, so the next call probes and closes it.
+	if _, err := p.ListInstances(ctx); err != nil {
+		t.Fatalf("probe call failed: %v", err)
+	}
+	if g.Degraded() {
+		t.Error("successful probe should close the breaker")
+	}
+}
+
+func TestGuard_NotSupportedDoesNotTrip(t *testing.T) {
+	g := testGuard(t, 1)
+	p := g.Wrap(&scriptedProvider{})
+
+	if _, err := p.ChangeIP(context.Background(), "i-1", ChangeIPOptions{}); !IsNotSupported(err) {
+		t.Fatalf("expected NotSupportedError to pass through unchanged, got %v", err)
+	}
+	if g.Degraded() {
+		t.Error("NotSupportedError must not open the breaker")
+	}
+}
+
+// TestGuard_KeepsStopperCapability: the wrapper is an InstanceStopper exactly
+// when the provider is, so the overage backstop's capability check still sees
+// providers that cannot stop instances.
+func TestGuard_KeepsStopperCapability(t *testing.T) {
+	g := testGuard(t, 1)
+	if _, ok := g.Wrap(&scriptedProvider{}).(InstanceStopper); ok {
+		t.Error("wrapped non-stopper must not satisfy InstanceStopper")
+	}
+
+	p := g.Wrap(NewFakeProvider(FakeOptions{}))
+	stopper, ok := p.(InstanceStopper)
+	if !ok {
+		t.Fatal("wrapped stopper must satisfy InstanceStopper")
+	}
+	if _, err := stopper.StopInstance(context.Background(), "missing"); err == nil {
+		t.Error("expected an error stopping an unknown instance")
+	}
+	if m := g.Metrics(); m.Calls != 1 {
+		t.Errorf("StopInstance not guarded: calls=%d", m.Calls)
+	}
+	if _, ok := g.Wrap(p).(InstanceStopper); !ok {
+		t.Error("re-wrapping must keep the capability")
+	}
+}
+
+// TestGuard_PartialListDoesNotTrip: a multi-region list with one region
+// down keeps returning PartialListError; the breaker must stay closed so the
+// healthy regions can still be synced and operated on.
+func TestGuard_PartialListDoesNotTrip(t *testing.T) {
+	regionDown := &PartialListError{Provider: "scripted", Errs: []error{errors.New("region ap-east-1: connection refused")}}
+	inner := &scriptedProvider{errs: []error{regionDown, regionDown, regionDown}}
+	g := testGuard(t, 2)
+	p := g.Wrap(inner)
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.ListInstances(context.Background()); !IsPartialList(err) {
+			t.Fatalf("call %d: expected PartialListError, got %v", i, err)
+		}
+	}
+	if g.Degraded() {
+		t.Fatal("partial lists must not open the breaker")
+	}
+	if m := g.Metrics(); m.Failures != 0 {
+		t.Errorf("partial lists counted as failures: %+v", m)
+	}
+}
+
+// TestGuard_FanOutGuardsEachRegion: every region call takes its own token
+// and a throttled region is retried alone, not the whole fan-out.
+func TestGuard_FanOutGuardsEachRegion(t *testing.T) {
+	a := &scriptedProvider{}
+	b := &scriptedProvider{errs: []error{errors.New("Throttling.User")}}
+	c := &scriptedProvider{}
+	g := testGuard(t, 5)
+	p := g.Wrap(&scriptedFanOut{parts: []Provider{a, b, c}})
+
+	got, err := p.ListInstances(context.Background())
+	if err != nil || len(got) != 3 {
+		t.Fatalf("got %d instances, err %v; want 3, nil", len(got), err)
+	}
+	if a.calls != 1 || b.calls != 2 || c.calls != 1 {
+		t.Errorf("region calls = %d/%d/%d, want 1/2/1", a.calls, b.calls, c.calls)
+	}
+	if m := g.Metrics(); m.Calls != 4 || m.Retries != 1 {
+		t.Errorf("metrics = %+v, want calls=4 retries=1", m)
+	}
+
+	// Re-wrapping must not stack the per-region limit.
+	g.Wrap(p).ListInstances(context.Background())
+	if m := g.Metrics(); m.Calls != 7 {
+		t.Errorf("calls after re-wrap = %d, want 7", m.Calls)
+	}
+}
+
+func TestListCollector(t *testing.T) {
+	var empty listCollector
+	if empty.err() != nil {
+		t.Error("no failures should yield nil error")
+	}
+
+	c := listCollector{provider: ProviderAWSLightsail}
+	c.add(errors.New("region us-east-1: timeout"))
+	err := c.err()
+	if !IsPartialList(err) {
+		t.Fatalf("expected PartialListError, got %T", err)
+	}
+	if !IsPartialList(fmt.Errorf("wrapped: %w", err)) {
+		t.Error("IsPartialList should see through wrapping")
+	}
+}
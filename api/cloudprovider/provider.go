@@ -3,6 +3,7 @@ package cloudprovider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -145,3 +146,45 @@ func IsNotSupported(err error) bool {
 	_, ok := err.(*NotSupportedError)
 	return ok
 }
+
+// PartialListError is returned by ListInstances together with the statuses
+// that could be fetched when some regions or instances failed. Callers may
+// use the partial result, but an instance missing from it is NOT proof that
+// the instance is gone — orphan detection must be skipped.
+type PartialListError struct {
+	Provider string
+	Errs     []error
+}
+
+func (e *PartialListError) Error() string {
+	return fmt.Sprintf("%s: partial instance list (%d failures): %v", e.Provider, len(e.Errs), errors.Join(e.Errs...))
+}
+
+func (e *PartialListError) Unwrap() []error {
+	return e.Errs
+}
+
+// IsPartialList checks if error is (or wraps) PartialListError
+func IsPartialList(err error) bool {
+	var pe *PartialListError
+	return errors.As(err, &pe)
+}
+
+// listCollector accumulates per-region/per-instance failures inside a
+// ListInstances fan-out so the aggregate can be reported as PartialListError
+// instead of being silently dropped.
+type listCollector struct {
+	provider string
+	errs     []error
+}
+
+func (l *listCollector) add(err error) {
+	l.errs = append(l.errs, err)
+}
+
+func (l *listCollector) err() error {
+	if len(l.errs) == 0 {
+		return nil
+	}
+	return &PartialListError{Provider: l.provider, Errs: l.errs}
+}
@@ -137,16 +137,18 @@ func (p *TencentLighthouseProvider) ListInstances(ctx context.Context) ([]*Insta
 	}
 
 	var statuses []*InstanceStatus
+	failed := listCollector{provider: p.providerName}
 	for _, inst := range response.Response.InstanceSet {
 		status, err := p.GetInstanceStatus(ctx, *inst.InstanceId)
 		if err != nil {
 			log.Warnf(ctx, "[TENCENT] Failed to get status for %s: %v", *inst.InstanceId, err)
+			failed.add(fmt.Errorf("instance %s: %w", *inst.InstanceId, err))
 			continue
 		}
 		statuses = append(statuses, status)
 	}
 
-	return statuses, nil
+	return statuses, failed.err()
 }
 
 func (p *TencentLighthouseProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
@@ -355,7 +357,7 @@ func getFirstIP(ips []*string) string {
 type MultiRegionTencentLighthouseProvider struct {
 	secretId     string
 	secretKey    string
-	providers    map[string]*TencentLighthouseProvider
+	providers    map[string]Provider
 	providerName string
 }
 
@@ -373,7 +375,7 @@ func newMultiRegionTencentProvider(secretId, secretKey, providerName string, reg
 	mp := &MultiRegionTencentLighthouseProvider{
 		secretId:     secretId,
 		secretKey:    secretKey,
-		providers:    make(map[string]*TencentLighthouseProvider),
+		providers:    make(map[string]Provider),
 		providerName: providerName,
 	}
 
@@ -396,7 +398,14 @@ func (mp *MultiRegionTencentLighthouseProvider) Name() string {
 	return mp.providerName
 }
 
-func (mp *MultiRegionTencentLighthouseProvider) getProviderForRegion(region string) *TencentLighthouseProvider {
+// guardParts implements fanOutProvider: each region is rate-limited on its own.
+func (mp *MultiRegionTencentLighthouseProvider) guardParts(wrap func(Provider) Provider) {
+	for region, p := range mp.providers {
+		mp.providers[region] = wrap(p)
+	}
+}
+
+func (mp *MultiRegionTencentLighthouseProvider) getProviderForRegion(region string) Provider {
 	if p, ok := mp.providers[region]; ok {
 		return p
 	}
@@ -424,16 +433,20 @@ func (mp *MultiRegionTencentLighthouseProvider) GetInstanceStatus(ctx context.Co
 func (mp *MultiRegionTencentLighthouseProvider) ListInstances(ctx context.Context) ([]*InstanceStatus, error) {
 	var allStatuses []*InstanceStatus
 
+	failed := listCollector{provider: mp.providerName}
 	for region, p := range mp.providers {
 		statuses, err := p.ListInstances(ctx)
 		if err != nil {
 			log.Warnf(ctx, "[TENCENT] Failed to list instances in region %s: %v", region, err)
-			continue
+			failed.add(fmt.Errorf("region %s: %w", region, err))
+			if !IsPartialList(err) {
+				continue
+			}
 		}
 		allStatuses = append(allStatuses, statuses...)
 	}
 
-	return allStatuses, nil
+	return allStatuses, failed.err()
 }
 
 func (mp *MultiRegionTencentLighthouseProvider) ChangeIP(ctx context.Context, instanceID string, opts ChangeIPOptions) (*OperationResult, error) {
@@ -128,7 +128,7 @@ func TestDedicatedLine_EntitlementDecouple_FullPath(t *testing.T) {
 // TokenIssueAt is aligned to the JWT so handleJWTAuth resolves it.
 func makeGatewaySubsRequest(t *testing.T, user *User, udid string) *httptest.ResponseRecorder {
 	t.Helper()
-	tokens, issuedAt, err := generateTokens(context.Background(), user.ID, udid, user.Roles)
+	tokens, issuedAt, err := generateTokens(context.Background(), user.ID, udid, user.Roles, "")
 	require.NoError(t, err)
 
 	dev := Device{
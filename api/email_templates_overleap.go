@@ -14,6 +14,7 @@ import (
 // ——其功能入口本身已被品牌 gate / 渠道锁挡住，overleap 用户不可达：
 //   - delegatePayInviteTemplate — 代付邀请，PaymentChannels 目前不含 overleap 支付渠道
 //   - adminResetPasswordTemplate — 管理员代重置密码，admin 专属操作
+//   - adminResetTwoFactorTemplate — 管理员重置两步验证，admin 专属操作
 //   - privateNode* 系列（专属线路相关模板）— 专属节点是 kaitu 专属产品
 //
 // kaitu 模板字节不变的保证：brandedEmailTemplate[T].Kaitu 直接复用
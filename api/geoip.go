@@ -2,10 +2,14 @@ package center
 
 import (
 	"context"
+	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/spf13/viper"
 	db "github.com/wordgate/qtoolkit/db"
 	"github.com/wordgate/qtoolkit/geoip"
 	"github.com/wordgate/qtoolkit/log"
@@ -56,6 +60,38 @@ func CountryFromGinContext(c *gin.Context) string {
 	return strings.ToLower(cc)
 }
 
+var (
+	asnOnce   sync.Once
+	asnReader *geoip2.Reader
+)
+
+// ASNFromIP looks up the autonomous system number of ip in the MaxMind
+// GeoLite2-ASN database configured at geoip.asn_db. Returns 0 when the
+// database is not configured or the address is unknown.
+func ASNFromIP(ip string) uint {
+	asnOnce.Do(func() {
+		path := viper.GetString("geoip.asn_db")
+		if path == "" {
+			return
+		}
+		r, err := geoip2.Open(path)
+		if err != nil {
+			log.Errorf(context.Background(), "failed to open ASN database %s: %v", path, err)
+			return
+		}
+		asnReader = r
+	})
+	parsed := net.ParseIP(ip)
+	if asnReader == nil || parsed == nil {
+		return 0
+	}
+	record, err := asnReader.ASN(parsed)
+	if err != nil {
+		return 0
+	}
+	return record.AutonomousSystemNumber
+}
+
 // maybeUpdateUserCountry updates user.current_country asynchronously when a
 // new country is detected. Called from auth middleware after successful auth.
 func maybeUpdateUserCountry(c *gin.Context, user *User) {
@@ -58,10 +58,13 @@ var actionDisplayNames = map[string]string{
 	"campaign_delete":     "删除优惠活动",
 	"campaign_issue_keys": "发放 License Key",
 	"user_hard_delete":    "硬删除用户",
+	"user_reset_2fa":      "重置用户两步验证",
 	"plan_update":         "修改订阅套餐",
 	"plan_delete":         "删除订阅套餐",
 	"withdraw_approve":    "审批提现",
 	"withdraw_complete":   "完成提现",
+	"cloud_rotate_ip":     "自动更换被封 IP",
+	"cloud_fleet_apply":   "云节点舰队对齐",
 }
 
 func actionDisplayName(action string) string {
@@ -177,6 +180,41 @@ func SubmitApproval(c *gin.Context, action string, params any, summary string) (
 	return approval.ID, false, nil
 }
 
+// SystemApprovalRequestor 是系统（worker）发起审批时的发起人标识。
+// RequestorID=0 不对应任何用户，所以任何 admin 都可以审批（不会触发自审限制）。
+const SystemApprovalRequestor = "system"
+
+// SubmitSystemApproval 由后台任务提交审批请求（无 gin 上下文、无发起人）。
+// 总是创建 pending 记录 —— 自动化触发的操作必须有人确认，没有超管直通。
+func SubmitSystemApproval(ctx context.Context, action string, params any, summary string) (uint64, error) {
+	if _, ok := getApprovalCallback(action); !ok {
+		return 0, fmt.Errorf("approval callback not registered for action: %s", action)
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return 0, fmt.Errorf("marshal params: %w", err)
+	}
+
+	approval := AdminApproval{
+		RequestorUUID: SystemApprovalRequestor,
+		RequestorName: SystemApprovalRequestor,
+		Action:        action,
+		Params:        string(paramsJSON),
+		Summary:       summary,
+		Status:        "pending",
+	}
+	if err := db.Get().Create(&approval).Error; err != nil {
+		return 0, fmt.Errorf("create approval: %w", err)
+	}
+
+	log.Infof(ctx, "approval submitted: id=%d action=%s by=%s", approval.ID, action, SystemApprovalRequestor)
+
+	go NotifyApprovalSubmitted(context.Background(), &approval)
+
+	return approval.ID, nil
+}
+
 // ===================== Approve =====================
 
 // ApproveApproval 审批通过
@@ -454,6 +492,10 @@ func NotifyApprovalResult(ctx context.Context, approval *AdminApproval) {
 	log.Infof(ctx, "[APPROVAL] notification: approval id=%d action=%s status=%s",
 		approval.ID, approval.Action, approval.Status)
 
+	if approval.RequestorID == 0 {
+		return // 系统发起（SubmitSystemApproval），没有可通知的发起人
+	}
+
 	email := getAdminEmail(ctx, approval.RequestorID)
 	if email == "" {
 		return
@@ -18,9 +18,14 @@ func executeApprovalEDMSend(ctx context.Context, params json.RawMessage) error {
 		return fmt.Errorf("unmarshal params: %w", err)
 	}
 
+	category := req.Category
+	if category == "" {
+		category = NotifyCategoryMarketing
+	}
 	sendReq := &SendEmailsRequest{
-		BatchID: req.BatchID,
-		Items:   make([]SendEmailItem, len(req.Items)),
+		BatchID:  req.BatchID,
+		Category: category,
+		Items:    make([]SendEmailItem, len(req.Items)),
 	}
 	for i, item := range req.Items {
 		sendReq.Items[i] = SendEmailItem{
@@ -476,3 +481,32 @@ func executeApprovalOrderRefund(ctx context.Context, params json.RawMessage) err
 
 	return ProcessOrderRefund(ctx, p.OrderID, p.Reason, p.OperatorID)
 }
+
+// ===================== User Reset 2FA =====================
+
+// executeApprovalUserReset2FA 审批通过后清空用户的两步验证并邮件通知
+func executeApprovalUserReset2FA(ctx context.Context, params json.RawMessage) error {
+	var p adminResetTwoFactorParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	var user User
+	if err := db.Get().Where(&User{UUID: p.UserUUID}).First(&user).Error; err != nil {
+		return fmt.Errorf("find user %s: %w", p.UserUUID, err)
+	}
+	clearTwoFactor(&user)
+	if err := saveTwoFactorState(&user); err != nil {
+		return fmt.Errorf("reset two-factor for user %s: %w", p.UserUUID, err)
+	}
+
+	meta := AdminResetTwoFactorMeta{
+		ChangeTime: time.Now().Format("2006-01-02 15:04:05"),
+		AdminEmail: p.AdminEmail,
+	}
+	if err := emailToUser(ctx, int64(user.ID), adminResetTwoFactorTemplate, meta); err != nil {
+		log.Errorf(ctx, "send 2fa reset notification to user %s failed: %v", p.UserUUID, err)
+	}
+	log.Infof(ctx, "two-factor authentication reset for user %s; reason=%q", p.UserUUID, p.Reason)
+	return nil
+}
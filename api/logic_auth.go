@@ -76,6 +76,7 @@ type TokenClaims struct {
 	Type         string `json:"type"` // access/refresh
 	TokenIssueAt int64  `json:"token_issue_at"`
 	Roles        uint64 `json:"roles"` // 角色位掩码（新增，旧 token 解析为 0）
+	SessionID    string `json:"sid,omitempty"` // 会话家族 ID（AuthSession.FamilyID），存量 token 为空
 }
 
 // 实现 jwt.Claims 接口
@@ -103,9 +104,14 @@ func (c TokenClaims) GetAudience() (jwt.ClaimStrings, error) {
 	return nil, nil
 }
 
-// generateTokens 生成访问令牌和刷新令牌
-func generateTokens(ctx context.Context, userID uint64, deviceID string, roles uint64) (*DataAuthResult, time.Time, error) {
+// generateTokens 生成访问令牌和刷新令牌，sessionID 写入 sid claim
+func generateTokens(ctx context.Context, userID uint64, deviceID string, roles uint64, sessionID string) (*DataAuthResult, time.Time, error) {
+	return generateTokensAt(ctx, userID, deviceID, roles, sessionID, time.Now())
+}
 
+// generateTokensAt 以指定签发时间生成令牌。refresh 轮换用它保证新一代的
+// TokenIssueAt 严格大于上一代，否则同一秒内轮换的旧 refresh token 仍会有效。
+func generateTokensAt(ctx context.Context, userID uint64, deviceID string, roles uint64, sessionID string, now time.Time) (*DataAuthResult, time.Time, error) {
 	log.Debugf(ctx, "generating tokens for user %d, device %s, roles %d", userID, deviceID, roles)
 	jwtConfig := configJwt(ctx)
 	jwtSecret := []byte(jwtConfig.Secret)
@@ -113,7 +119,6 @@ func generateTokens(ctx context.Context, userID uint64, deviceID string, roles u
 	accessTokenExpiry := time.Duration(jwtConfig.AccessTokenExpiry) * time.Second
 	refreshTokenExpiry := time.Duration(jwtConfig.RefreshTokenExpiry) * time.Second
 
-	now := time.Now()
 	var issue = func(tokenType string, expiry time.Duration) (string, error) {
 		claims := TokenClaims{
 			UserID:       userID,
@@ -122,6 +127,7 @@ func generateTokens(ctx context.Context, userID uint64, deviceID string, roles u
 			Type:         tokenType,
 			TokenIssueAt: now.Unix(),
 			Roles:        roles,
+			SessionID:    sessionID,
 		}
 		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
 	}
@@ -149,7 +155,8 @@ const WebCookieRenewalThreshold = 7 * 24 * time.Hour // 7 days
 
 // generateWebCookieToken 生成 Web Cookie 专用的 access token
 // 有效期为 2 个月，不需要 refresh token（通过 sliding expiration 自动续期）
-func generateWebCookieToken(ctx context.Context, userID uint64, roles uint64) (*DataAuthResult, time.Time, error) {
+// 登录入口应使用 issueWebSession；续期时沿用原 token 的 sessionID
+func generateWebCookieToken(ctx context.Context, userID uint64, roles uint64, sessionID string) (*DataAuthResult, time.Time, error) {
 	log.Debugf(ctx, "generating web cookie token for user %d (2-month expiry), roles %d", userID, roles)
 	jwtConfig := configJwt(ctx)
 	jwtSecret := []byte(jwtConfig.Secret)
@@ -162,6 +169,7 @@ func generateWebCookieToken(ctx context.Context, userID uint64, roles uint64) (*
 		Type:         TokenTypeAccess,
 		TokenIssueAt: now.Unix(),
 		Roles:        roles,
+		SessionID:    sessionID,
 	}
 
 	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
@@ -209,6 +217,10 @@ func validateToken(ctx context.Context, tokenString string, tokenType string) (*
 	}
 	if device.TokenIssueAt != claims.TokenIssueAt {
 		log.Warnf(ctx, "token issue at mismatch for user %d, udid %s", claims.UserID, claims.DeviceID)
+		// 早于当前一代的 refresh token：已被轮换掉却再次出现，交由调用方吊销家族
+		if tokenType == TokenTypeRefresh && claims.TokenIssueAt < device.TokenIssueAt {
+			return claims, nil, ErrRefreshTokenReused
+		}
 		return nil, nil, ErrInvalidToken
 	}
 	return claims, &device, nil
@@ -0,0 +1,544 @@
+package center
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kaitu-io/k2app/api/cloudprovider"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// logic_cloud_fleet.go — 共享池声明式规格 + plan/apply 对齐。
+//
+// cloud_instance.fleet.nodes 描述期望状态("eu-frankfurt 3 台, 品牌 kaitu+overleap,
+// 月流量 ≥2TB")。plan 把规格和现有 CloudInstance/SlaveNode 做 diff,给出建机/删机动作;
+// apply 只提交一条 cloud_fleet_apply 审批,审批通过后把动作投递成普通的
+// cloud:create / cloud:delete 任务。思路同 terraform plan/apply,但:
+//   - 只管规格里列出的 region,其它 region 的实例一律不碰;
+//   - 专属节点(class=private 或仍被专属订阅占用)不计入、也永远不会被删;
+//   - 审批执行时重新盘点库存,和 plan 时的指纹不一致就拒绝执行(计划已过期)。
+//
+// 新建的实例仍需部署 sidecar(provision-node.sh + K2_NODE_BRANDS),动作里带上
+// 规格要求的品牌供部署方参考;节点自注册后才会按品牌参与匹配。
+
+const approvalActionCloudFleetApply = "cloud_fleet_apply"
+
+// fleetSettleWindow: 上一次 apply 执行后的静默期。新建的实例要等下一轮同步才会出现在
+// CloudInstance 里,这段时间内重新 plan 会把它们算成缺口而重复建机。
+const fleetSettleWindow = 15 * time.Minute
+
+const bytesPerTB = 1 << 40
+
+const (
+	FleetOpCreate = "create"
+	FleetOpDelete = "delete"
+)
+
+// FleetInstance is one shared-pool cloud instance as seen by the planner.
+type FleetInstance struct {
+	CloudInstanceID uint64  `json:"cloudInstanceId"`
+	AccountName     string  `json:"accountName"`
+	Provider        string  `json:"provider"`
+	Name            string  `json:"name"`
+	IP              string  `json:"ip"`
+	RegionSlug      string  `json:"regionSlug"` // "" = region 不在统一注册表里
+	TransferTB      float64 `json:"transferTb"` // 0 = 未知
+	CreatedAt       int64   `json:"createdAt"`
+	NodeID          uint64  `json:"nodeId"` // 0 = 尚未自注册
+	Brands          []Brand `json:"brands,omitempty"`
+}
+
+// deletable: ssh_standalone 实例由 SlaveNode 自动发现,provider 不支持删除。
+func (fi *FleetInstance) deletable() bool {
+	return fi.Provider != cloudprovider.ProviderSSHStandalone
+}
+
+// matches reports whether the instance satisfies a spec entry. Unregistered
+// instances and unknown transfer allowances count as matching: they are most
+// likely instances we just created, and counting them is what keeps plan from
+// ordering the same node twice.
+func (fi *FleetInstance) matches(e *CloudFleetSpecEntry) bool {
+	if fi.RegionSlug != e.Region {
+		return false
+	}
+	if fi.TransferTB > 0 && fi.TransferTB < e.MinTransferTB {
+		return false
+	}
+	if fi.NodeID == 0 {
+		return true
+	}
+	for _, b := range e.Brands {
+		if !slices.Contains(fi.Brands, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// FleetAction is one step of a fleet plan.
+type FleetAction struct {
+	Op     string `json:"op"` // create | delete
+	Entry  int    `json:"entry"`
+	Region string `json:"region"`
+
+	// create
+	AccountName    string  `json:"accountName,omitempty"`
+	Provider       string  `json:"provider,omitempty"`
+	ProviderRegion string  `json:"providerRegion,omitempty"`
+	Plan           string  `json:"plan,omitempty"`
+	ImageID        string  `json:"imageId,omitempty"`
+	Name           string  `json:"name,omitempty"`
+	Brands         []Brand `json:"brands,omitempty"` // 部署 sidecar 时要声明的品牌
+	TransferTB     float64 `json:"transferTb,omitempty"`
+	PriceMonthly   float64 `json:"priceMonthly,omitempty"`
+
+	// delete
+	CloudInstanceID uint64 `json:"cloudInstanceId,omitempty"`
+	IP              string `json:"ip,omitempty"`
+}
+
+// FleetEntryStatus is the per-entry diff result.
+type FleetEntryStatus struct {
+	Entry       int      `json:"entry"`
+	Region      string   `json:"region"`
+	Brands      []Brand  `json:"brands,omitempty"`
+	Want        int      `json:"want"`
+	Have        int      `json:"have"`
+	Pending     int      `json:"pending"` // Have 中尚未自注册的实例数
+	InstanceIDs []uint64 `json:"instanceIds"`
+}
+
+// FleetPlan is the plan output, and also the approval params of an apply.
+// PlanID must stay the first field: fleetPlanParamsPrefix matches on it.
+type FleetPlan struct {
+	PlanID      string             `json:"planId"`
+	PlannedAt   int64              `json:"plannedAt"`
+	Fingerprint string             `json:"fingerprint"`
+	Settling    bool               `json:"settling"` // 上次 apply 仍在静默期内,不允许再次 apply
+	Entries     []FleetEntryStatus `json:"entries"`
+	Actions     []FleetAction      `json:"actions"`
+	Unmanaged   int                `json:"unmanaged"` // 不在规格 region 内、未被管理的共享实例数
+	Warnings    []string           `json:"warnings"`
+}
+
+// Counts returns the number of create and delete actions.
+func (p *FleetPlan) Counts() (creates, deletes int) {
+	for _, a := range p.Actions {
+		if a.Op == FleetOpCreate {
+			creates++
+		} else {
+			deletes++
+		}
+	}
+	return creates, deletes
+}
+
+func fleetPlanParamsPrefix(planID string) string {
+	return fmt.Sprintf(`{"planId":%q,`, planID)
+}
+
+// diffFleet assigns inventory to spec entries and returns per-entry status,
+// the shortfall per entry, and delete actions for the surplus.
+//
+// Entries are matched most-specific first (more brands, then higher transfer
+// floor) so a broad "any brand" entry can't swallow the nodes a narrower entry
+// in the same region needs. Within an entry registered nodes are kept before
+// unregistered ones, then oldest first, so the surplus removed is the newest.
+func diffFleet(spec []CloudFleetSpecEntry, inv []FleetInstance) ([]FleetEntryStatus, []int, []FleetAction, []string) {
+	order := make([]int, len(spec))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ea, eb := &spec[order[a]], &spec[order[b]]
+		if len(ea.Brands) != len(eb.Brands) {
+			return len(ea.Brands) > len(eb.Brands)
+		}
+		return ea.MinTransferTB > eb.MinTransferTB
+	})
+
+	sorted := slices.Clone(inv)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		ra, rb := sorted[a].NodeID != 0, sorted[b].NodeID != 0
+		if ra != rb {
+			return ra
+		}
+		if sorted[a].CreatedAt != sorted[b].CreatedAt {
+			return sorted[a].CreatedAt < sorted[b].CreatedAt
+		}
+		return sorted[a].CloudInstanceID < sorted[b].CloudInstanceID
+	})
+
+	assigned := make(map[uint64]bool, len(sorted))
+	statuses := make([]FleetEntryStatus, len(spec))
+	shortfall := make([]int, len(spec))
+	var deletes []FleetAction
+	var warnings []string
+
+	// 第一轮:每个条目按序保留至多 Count 台
+	for _, idx := range order {
+		e := &spec[idx]
+		st := FleetEntryStatus{Entry: idx, Region: e.Region, Brands: e.Brands, Want: e.Count, InstanceIDs: []uint64{}}
+		for i := range sorted {
+			fi := &sorted[i]
+			if st.Have >= e.Count {
+				break
+			}
+			if assigned[fi.CloudInstanceID] || !fi.matches(e) {
+				continue
+			}
+			assigned[fi.CloudInstanceID] = true
+			st.Have++
+			if fi.NodeID == 0 {
+				st.Pending++
+			}
+			st.InstanceIDs = append(st.InstanceIDs, fi.CloudInstanceID)
+		}
+		shortfall[idx] = e.Count - st.Have
+		statuses[idx] = st
+	}
+
+	// 第二轮:规格 region 内没被保留的实例 —— 符合某个条目的是多余,删;一个都不符合的只告警
+	managed := make(map[string]bool, len(spec))
+	for i := range spec {
+		managed[spec[i].Region] = true
+	}
+	for i := range sorted {
+		fi := &sorted[i]
+		if !managed[fi.RegionSlug] || assigned[fi.CloudInstanceID] {
+			continue
+		}
+		entry := -1
+		for _, idx := range order {
+			if fi.matches(&spec[idx]) {
+				entry = idx
+				break
+			}
+		}
+		switch {
+		case entry < 0:
+			warnings = append(warnings, fmt.Sprintf("instance %d (%s) in %s matches no spec entry (brands/transfer), left untouched", fi.CloudInstanceID, fi.IP, fi.RegionSlug))
+		case !fi.deletable():
+			warnings = append(warnings, fmt.Sprintf("entry %d: surplus instance %d (%s) is %s and cannot be deleted by the provider", entry, fi.CloudInstanceID, fi.IP, fi.Provider))
+		default:
+			deletes = append(deletes, FleetAction{
+				Op: FleetOpDelete, Entry: entry, Region: fi.RegionSlug,
+				AccountName: fi.AccountName, Provider: fi.Provider, Name: fi.Name,
+				CloudInstanceID: fi.CloudInstanceID, IP: fi.IP,
+			})
+		}
+	}
+	return statuses, shortfall, deletes, warnings
+}
+
+// cheapestFleetPlan picks the cheapest plan whose monthly transfer meets the
+// floor. Returns nil when none qualifies.
+func cheapestFleetPlan(plans []cloudprovider.PlanInfo, minTB float64) *cloudprovider.PlanInfo {
+	var best *cloudprovider.PlanInfo
+	for i := range plans {
+		p := &plans[i]
+		if p.TransferTB < minTB {
+			continue
+		}
+		if best == nil || p.PriceMonthly < best.PriceMonthly {
+			best = p
+		}
+	}
+	return best
+}
+
+// fleetCandidate is an account that can create instances for a spec entry.
+type fleetCandidate struct {
+	account        *CloudInstanceAccount
+	providerRegion string
+	imageID        string
+}
+
+// fleetCandidates lists accounts able to create instances in the entry's
+// region: the provider must map the slug, the account (if region-bound) must
+// sit in that region, and an image must be configured for the provider.
+func fleetCandidates(e *CloudFleetSpecEntry, accounts []CloudInstanceAccount, images map[string]string) []fleetCandidate {
+	var out []fleetCandidate
+	for i := range accounts {
+		acc := &accounts[i]
+		if len(e.Accounts) > 0 && !slices.Contains(e.Accounts, acc.Name) {
+			continue
+		}
+		if acc.Provider == cloudprovider.ProviderSSHStandalone {
+			continue
+		}
+		pr := cloudprovider.GetProviderRegion(e.Region, acc.Provider)
+		if pr == "" || (acc.Region != "" && acc.Region != pr) {
+			continue
+		}
+		img := images[acc.Provider]
+		if img == "" {
+			continue
+		}
+		out = append(out, fleetCandidate{account: acc, providerRegion: pr, imageID: img})
+	}
+	return out
+}
+
+// fleetCreateRegion converts a provider region into what CreateInstance
+// expects. Lightsail creates into an availability zone, not a region.
+func fleetCreateRegion(provider, providerRegion string) string {
+	if provider == cloudprovider.ProviderAWSLightsail {
+		return providerRegion + "a"
+	}
+	return providerRegion
+}
+
+// fleetFingerprint hashes the spec and the inventory it was diffed against.
+// apply refuses to execute when the fingerprint no longer matches.
+func fleetFingerprint(spec []CloudFleetSpecEntry, inv []FleetInstance) string {
+	lines := make([]string, 0, len(inv))
+	for i := range inv {
+		lines = append(lines, fmt.Sprintf("%d|%s|%d", inv[i].CloudInstanceID, inv[i].IP, inv[i].NodeID))
+	}
+	sort.Strings(lines)
+	specJSON, _ := json.Marshal(spec)
+	h := sha256.New()
+	h.Write(specJSON)
+	h.Write([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// loadFleetInventory returns all shared-pool cloud instances. Private
+// instances (class=private nodes, or still owned by a dedicated-line
+// subscription) are excluded entirely.
+func loadFleetInventory(ctx context.Context) ([]FleetInstance, error) {
+	var instances []CloudInstance
+	if err := db.Get().Order("id ASC").Find(&instances).Error; err != nil {
+		return nil, fmt.Errorf("query cloud instances: %w", err)
+	}
+
+	var privateIDs []uint64
+	if err := db.Get().Model(&PrivateNodeSubscription{}).
+		Where("cloud_instance_id IS NOT NULL AND status IN ?", privateOwningStatuses).
+		Pluck("cloud_instance_id", &privateIDs).Error; err != nil {
+		return nil, fmt.Errorf("query private subscriptions: %w", err)
+	}
+
+	ips := make([]string, 0, len(instances))
+	for i := range instances {
+		ips = append(ips, instances[i].IPAddress)
+	}
+	nodeByIP := map[string]*SlaveNode{}
+	if len(ips) > 0 {
+		var nodes []SlaveNode
+		if err := db.Get().Where("ipv4 IN ?", ips).Find(&nodes).Error; err != nil {
+			return nil, fmt.Errorf("query slave nodes: %w", err)
+		}
+		for i := range nodes {
+			nodeByIP[nodes[i].Ipv4] = &nodes[i]
+		}
+	}
+
+	inv := make([]FleetInstance, 0, len(instances))
+	for i := range instances {
+		ci := &instances[i]
+		if slices.Contains(privateIDs, ci.ID) {
+			continue
+		}
+		fi := FleetInstance{
+			CloudInstanceID: ci.ID,
+			AccountName:     ci.AccountName,
+			Provider:        ci.Provider,
+			Name:            ci.Name,
+			IP:              ci.IPAddress,
+			TransferTB:      float64(ci.TrafficTotalBytes) / bytesPerTB,
+			CreatedAt:       ci.CreatedAt.Unix(),
+		}
+		if r := cloudprovider.GetRegionByProviderID(ci.Provider, ci.Region); r != nil {
+			fi.RegionSlug = r.Slug
+		}
+		if n := nodeByIP[ci.IPAddress]; n != nil {
+			if n.Class == NodeClassPrivate {
+				continue
+			}
+			fi.NodeID = n.ID
+			fi.Brands = n.DeclaredBrands()
+		}
+		inv = append(inv, fi)
+	}
+	log.Debugf(ctx, "[FLEET] inventory: %d shared instances", len(inv))
+	return inv, nil
+}
+
+// lastFleetApplyAt returns when the most recent fleet apply executed, or zero.
+// Applies belonging to excludePlanID (the one currently executing) are skipped.
+func lastFleetApplyAt(excludePlanID string) time.Time {
+	q := db.Get().Model(&AdminApproval{}).
+		Where("action = ? AND status IN ? AND executed_at IS NOT NULL", approvalActionCloudFleetApply, []string{"executing", "executed"})
+	if excludePlanID != "" {
+		q = q.Where("params NOT LIKE ?", fleetPlanParamsPrefix(excludePlanID)+"%")
+	}
+	var a AdminApproval
+	if err := q.Order("executed_at DESC").Limit(1).Find(&a).Error; err != nil || a.ExecutedAt == nil {
+		return time.Time{}
+	}
+	return *a.ExecutedAt
+}
+
+// planCloudFleet diffs the configured spec against the inventory and resolves
+// each shortfall to the cheapest qualifying plan across candidate accounts.
+func planCloudFleet(ctx context.Context) (*FleetPlan, error) {
+	cfg := ConfigCloudInstance()
+	spec := cfg.Fleet.Nodes
+
+	inv, err := loadFleetInventory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	plan := &FleetPlan{
+		PlanID:      generateId("fleet"),
+		PlannedAt:   now.Unix(),
+		Fingerprint: fleetFingerprint(spec, inv),
+		Actions:     []FleetAction{},
+		Warnings:    []string{},
+	}
+	if last := lastFleetApplyAt(""); !last.IsZero() && now.Sub(last) < fleetSettleWindow {
+		plan.Settling = true
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("last apply executed at %s; new instances may not be synced yet, wait until %s before applying again",
+			last.Format(time.RFC3339), last.Add(fleetSettleWindow).Format(time.RFC3339)))
+	}
+
+	managed := map[string]bool{}
+	for i := range spec {
+		managed[spec[i].Region] = true
+	}
+	for i := range inv {
+		if !managed[inv[i].RegionSlug] {
+			plan.Unmanaged++
+		}
+	}
+
+	entries, shortfall, deletes, warnings := diffFleet(spec, inv)
+	plan.Entries = entries
+	plan.Warnings = append(plan.Warnings, warnings...)
+
+	// 同一次 plan 内按 account+region 缓存套餐列表,避免重复打 provider API
+	planCache := map[string][]cloudprovider.PlanInfo{}
+	for idx, need := range shortfall {
+		if need <= 0 {
+			continue
+		}
+		e := &spec[idx]
+		var best *cloudprovider.PlanInfo
+		var bestCand fleetCandidate
+		for _, cand := range fleetCandidates(e, cfg.Accounts, cfg.Fleet.Images) {
+			key := cand.account.Name + "/" + cand.providerRegion
+			plans, ok := planCache[key]
+			if !ok {
+				provider, err := newCloudProvider(cand.account)
+				if err == nil {
+					plans, err = provider.ListPlans(ctx, cand.providerRegion)
+				}
+				if err != nil {
+					plan.Warnings = append(plan.Warnings, fmt.Sprintf("entry %d: list plans on %s failed: %v", idx, cand.account.Name, err))
+					continue
+				}
+				planCache[key] = plans
+			}
+			if p := cheapestFleetPlan(plans, e.MinTransferTB); p != nil && (best == nil || p.PriceMonthly < best.PriceMonthly) {
+				best, bestCand = p, cand
+			}
+		}
+		if best == nil {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("entry %d: no account/plan can create in %s with ≥%.1fTB transfer (check accounts, fleet.images)", idx, e.Region, e.MinTransferTB))
+			continue
+		}
+		for n := 1; n <= need; n++ {
+			plan.Actions = append(plan.Actions, FleetAction{
+				Op: FleetOpCreate, Entry: idx, Region: e.Region,
+				AccountName:    bestCand.account.Name,
+				Provider:       bestCand.account.Provider,
+				ProviderRegion: bestCand.providerRegion,
+				Plan:           best.ID,
+				ImageID:        bestCand.imageID,
+				Name:           fmt.Sprintf("k2-%s-%s-%d", e.Region, strings.TrimPrefix(plan.PlanID, "fleet-"), len(plan.Actions)+1),
+				Brands:         e.Brands,
+				TransferTB:     best.TransferTB,
+				PriceMonthly:   best.PriceMonthly,
+			})
+		}
+	}
+	plan.Actions = append(plan.Actions, deletes...)
+	return plan, nil
+}
+
+// checkFleetPlanFresh rejects executing a plan whose inputs changed since it
+// was made: another apply ran, or the spec/inventory fingerprint moved.
+func checkFleetPlanFresh(ctx context.Context, plan *FleetPlan) error {
+	if last := lastFleetApplyAt(plan.PlanID); last.Unix() >= plan.PlannedAt {
+		return fmt.Errorf("fleet plan %s is stale: another apply executed at %s, re-run plan", plan.PlanID, last.Format(time.RFC3339))
+	}
+	inv, err := loadFleetInventory(ctx)
+	if err != nil {
+		return err
+	}
+	if fp := fleetFingerprint(ConfigCloudInstance().Fleet.Nodes, inv); fp != plan.Fingerprint {
+		return fmt.Errorf("fleet plan %s is stale: spec or inventory changed (%s → %s), re-run plan", plan.PlanID, plan.Fingerprint, fp)
+	}
+	return nil
+}
+
+// executeApprovalCloudFleetApply enqueues the approved plan's actions as
+// regular cloud create/delete tasks.
+func executeApprovalCloudFleetApply(ctx context.Context, params json.RawMessage) error {
+	var plan FleetPlan
+	if err := json.Unmarshal(params, &plan); err != nil {
+		return fmt.Errorf("unmarshal params: %w", err)
+	}
+	if err := checkFleetPlanFresh(ctx, &plan); err != nil {
+		return err
+	}
+
+	var errs []error
+	enqueued := 0
+	for _, a := range plan.Actions {
+		var err error
+		switch a.Op {
+		case FleetOpCreate:
+			_, err = enqueueCloudTask(TaskTypeCloudCreate, CloudCreatePayload{
+				AccountName: a.AccountName,
+				Region:      fleetCreateRegion(a.Provider, a.ProviderRegion),
+				Plan:        a.Plan,
+				ImageID:     a.ImageID,
+				Name:        a.Name,
+			})
+		case FleetOpDelete:
+			// 计划与执行之间实例可能被专属订阅占用,删除前再确认一次
+			if isPrivateCloudInstance(a.CloudInstanceID) {
+				err = fmt.Errorf("instance %d became private, not deleting", a.CloudInstanceID)
+				break
+			}
+			_, err = enqueueCloudTask(TaskTypeCloudDelete, CloudDeletePayload{CloudInstanceID: a.CloudInstanceID})
+		default:
+			err = fmt.Errorf("unknown fleet op %q", a.Op)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", a.Op, a.Region, err))
+			continue
+		}
+		enqueued++
+	}
+
+	creates, deletes := plan.Counts()
+	log.Infof(ctx, "[FLEET] plan %s applied: %d/%d actions enqueued (create=%d delete=%d)", plan.PlanID, enqueued, len(plan.Actions), creates, deletes)
+	sendCloudSlackNotification(ctx, "Fleet Apply",
+		fmt.Sprintf("Plan: %s\nCreate: %d, Delete: %d\nEnqueued: %d/%d", plan.PlanID, creates, deletes, enqueued, len(plan.Actions)))
+	return errors.Join(errs...)
+}
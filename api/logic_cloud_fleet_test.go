@@ -0,0 +1,136 @@
+package center
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kaitu-io/k2app/api/cloudprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fleetInst(id uint64, region string, nodeID uint64, createdAt int64, brands ...Brand) FleetInstance {
+	return FleetInstance{
+		CloudInstanceID: id,
+		Provider:        cloudprovider.ProviderAWSLightsail,
+		IP:              fmt.Sprintf("10.0.0.%d", id),
+		RegionSlug:      region,
+		TransferTB:      2,
+		CreatedAt:       createdAt,
+		NodeID:          nodeID,
+		Brands:          brands,
+	}
+}
+
+func TestDiffFleet_ShortfallAndSurplus(t *testing.T) {
+	spec := []CloudFleetSpecEntry{
+		{Region: "eu-frankfurt", Count: 3, MinTransferTB: 1},
+		{Region: "ap-tokyo", Count: 1},
+	}
+	inv := []FleetInstance{
+		fleetInst(1, "eu-frankfurt", 11, 100, BrandKaitu),
+		fleetInst(2, "ap-tokyo", 12, 100, BrandKaitu),
+		fleetInst(3, "ap-tokyo", 13, 200, BrandKaitu),
+		fleetInst(4, "ap-tokyo", 0, 50),     // unregistered: kept only after registered ones
+		fleetInst(5, "us-virginia", 15, 10), // unmanaged region
+	}
+
+	statuses, shortfall, deletes, warnings := diffFleet(spec, inv)
+
+	assert.Equal(t, []int{2, 0}, shortfall)
+	assert.Equal(t, []uint64{1}, statuses[0].InstanceIDs)
+	assert.Equal(t, []uint64{2}, statuses[1].InstanceIDs, "oldest registered node is kept")
+	require.Len(t, deletes, 2)
+	assert.Equal(t, uint64(3), deletes[0].CloudInstanceID)
+	assert.Equal(t, uint64(4), deletes[1].CloudInstanceID)
+	assert.Empty(t, warnings)
+}
+
+func TestDiffFleet_SpecificEntryMatchedFirst(t *testing.T) {
+	// The broad entry is listed first but must not swallow the overleap node.
+	spec := []CloudFleetSpecEntry{
+		{Region: "eu-frankfurt", Count: 1},
+		{Region: "eu-frankfurt", Count: 1, Brands: []Brand{BrandKaitu, BrandOverleap}},
+	}
+	inv := []FleetInstance{
+		fleetInst(1, "eu-frankfurt", 11, 100, BrandKaitu, BrandOverleap),
+		fleetInst(2, "eu-frankfurt", 12, 200, BrandKaitu),
+	}
+
+	statuses, shortfall, deletes, _ := diffFleet(spec, inv)
+
+	assert.Equal(t, []int{0, 0}, shortfall)
+	assert.Equal(t, []uint64{2}, statuses[0].InstanceIDs)
+	assert.Equal(t, []uint64{1}, statuses[1].InstanceIDs)
+	assert.Empty(t, deletes)
+
+	// A second overleap node is not surplus of the narrow entry: the broad
+	// entry can still use it.
+	inv = []FleetInstance{
+		fleetInst(1, "eu-frankfurt", 11, 100, BrandKaitu, BrandOverleap),
+		fleetInst(2, "eu-frankfurt", 12, 200, BrandKaitu, BrandOverleap),
+	}
+	statuses, shortfall, deletes, _ = diffFleet(spec, inv)
+	assert.Equal(t, []int{0, 0}, shortfall)
+	assert.Equal(t, []uint64{2}, statuses[0].InstanceIDs)
+	assert.Empty(t, deletes)
+}
+
+func TestDiffFleet_NonMatchingAndUndeletable(t *testing.T) {
+	spec := []CloudFleetSpecEntry{
+		{Region: "eu-frankfurt", Count: 0, MinTransferTB: 3},
+	}
+	small := fleetInst(1, "eu-frankfurt", 11, 100)
+	ssh := fleetInst(2, "eu-frankfurt", 12, 100)
+	ssh.Provider = cloudprovider.ProviderSSHStandalone
+	ssh.TransferTB = 0 // unknown allowance counts as matching
+
+	_, _, deletes, warnings := diffFleet(spec, []FleetInstance{small, ssh})
+
+	assert.Empty(t, deletes, "below-floor instance is untouched, ssh_standalone cannot be deleted")
+	assert.Len(t, warnings, 2)
+}
+
+func TestCheapestFleetPlan(t *testing.T) {
+	plans := []cloudprovider.PlanInfo{
+		{ID: "nano", TransferTB: 1, PriceMonthly: 5},
+		{ID: "large", TransferTB: 4, PriceMonthly: 20},
+		{ID: "small", TransferTB: 2, PriceMonthly: 10},
+	}
+	assert.Equal(t, "small", cheapestFleetPlan(plans, 2).ID)
+	assert.Equal(t, "nano", cheapestFleetPlan(plans, 0).ID)
+	assert.Nil(t, cheapestFleetPlan(plans, 5))
+}
+
+func TestFleetCandidates(t *testing.T) {
+	accounts := []CloudInstanceAccount{
+		{Name: "aws-fra", Provider: cloudprovider.ProviderAWSLightsail, Region: "eu-central-1"},
+		{Name: "aws-tokyo", Provider: cloudprovider.ProviderAWSLightsail, Region: "ap-northeast-1"},
+		{Name: "tencent", Provider: cloudprovider.ProviderTencentLighthouse},
+		{Name: "ssh", Provider: cloudprovider.ProviderSSHStandalone},
+	}
+	images := map[string]string{cloudprovider.ProviderAWSLightsail: "ubuntu_22_04"}
+	entry := CloudFleetSpecEntry{Region: "eu-frankfurt", Count: 1}
+
+	got := fleetCandidates(&entry, accounts, images)
+	require.Len(t, got, 1, "tencent has no image configured, aws-tokyo is bound to another region")
+	assert.Equal(t, "aws-fra", got[0].account.Name)
+	assert.Equal(t, "eu-central-1a", fleetCreateRegion(got[0].account.Provider, got[0].providerRegion))
+
+	entry.Accounts = []string{"tencent"}
+	assert.Empty(t, fleetCandidates(&entry, accounts, images))
+}
+
+func TestFleetFingerprint(t *testing.T) {
+	spec := []CloudFleetSpecEntry{{Region: "eu-frankfurt", Count: 2}}
+	a := fleetInst(1, "eu-frankfurt", 11, 100)
+	b := fleetInst(2, "eu-frankfurt", 0, 200)
+
+	fp := fleetFingerprint(spec, []FleetInstance{a, b})
+	assert.Equal(t, fp, fleetFingerprint(spec, []FleetInstance{b, a}), "order-independent")
+
+	b.NodeID = 12
+	assert.NotEqual(t, fp, fleetFingerprint(spec, []FleetInstance{a, b}), "node registration changes inventory")
+	spec[0].Count = 3
+	assert.NotEqual(t, fp, fleetFingerprint(spec, []FleetInstance{a}))
+}
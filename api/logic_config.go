@@ -3,8 +3,11 @@ package center
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/kaitu-io/k2app/api/cloudprovider"
 	"github.com/spf13/viper"
 	"github.com/wordgate/qtoolkit/log"
 	"github.com/wordgate/wordgate-sdk"
@@ -156,6 +159,132 @@ func configStripe(ctx context.Context) StripeConfig {
 	return cfg
 }
 
+// PushConfig 推送通道凭证；某通道缺配置时该通道的发送直接失败（记入指标），不影响其他通道
+type PushConfig struct {
+	APNsTeamID     string // Apple Developer Team ID
+	APNsKeyID      string // .p8 key 的 Key ID
+	APNsPrivateKey string // .p8 文件内容（PEM）
+	APNsTopic      string // 令牌未带 topic 时的默认 Bundle ID
+
+	FCMProjectID      string
+	FCMServiceAccount string // 服务账号 JSON 内容
+
+	JPushAppKey       string
+	JPushMasterSecret string
+}
+
+// configPush 获取推送配置
+func configPush() PushConfig {
+	return PushConfig{
+		APNsTeamID:        viper.GetString("push.apns.team_id"),
+		APNsKeyID:         viper.GetString("push.apns.key_id"),
+		APNsPrivateKey:    viper.GetString("push.apns.private_key"),
+		APNsTopic:         viper.GetString("push.apns.topic"),
+		FCMProjectID:      viper.GetString("push.fcm.project_id"),
+		FCMServiceAccount: viper.GetString("push.fcm.service_account"),
+		JPushAppKey:       viper.GetString("push.jpush.app_key"),
+		JPushMasterSecret: viper.GetString("push.jpush.master_secret"),
+	}
+}
+
+// OAuthProviderConfig 第三方登录客户端凭证
+type OAuthProviderConfig struct {
+	ClientID     string // Google / GitHub 的 OAuth client ID；Apple 为 Services ID
+	ClientSecret string // Google / GitHub
+
+	// Sign in with Apple 的 client secret 是用 .p8 私钥现签的 ES256 JWT
+	TeamID     string
+	KeyID      string
+	PrivateKey string // .p8 文件内容（PEM）
+}
+
+// Enabled 凭证齐全才对外提供该登录方式
+func (cfg OAuthProviderConfig) Enabled(provider string) bool {
+	if cfg.ClientID == "" {
+		return false
+	}
+	if provider == oauthProviderApple {
+		return cfg.TeamID != "" && cfg.KeyID != "" && cfg.PrivateKey != ""
+	}
+	return cfg.ClientSecret != ""
+}
+
+// configOAuthProvider 获取第三方登录配置
+// Priority: oauth.{provider}.{brand}.* > oauth.{provider}.*
+// 回调地址按品牌官网区分，GitHub OAuth App 只能登记一个回调域名，故允许按品牌覆盖
+func configOAuthProvider(provider string, brand Brand) OAuthProviderConfig {
+	get := func(key string) string {
+		if v := viper.GetString(fmt.Sprintf("oauth.%s.%s.%s", provider, brand, key)); v != "" {
+			return v
+		}
+		return viper.GetString(fmt.Sprintf("oauth.%s.%s", provider, key))
+	}
+	return OAuthProviderConfig{
+		ClientID:     get("client_id"),
+		ClientSecret: get("client_secret"),
+		TeamID:       get("team_id"),
+		KeyID:        get("key_id"),
+		PrivateKey:   get("private_key"),
+	}
+}
+
+// LoginRiskConfig 登录风控阈值（分数来自 logic_login_risk.go 的信号打分）
+type LoginRiskConfig struct {
+	CaptchaScore   int // 达到即要求人机验证
+	EmailCodeScore int // 达到即拒绝密码登录，只能走邮箱验证码
+	BlockScore     int // 达到即临时封禁来源 IP
+	BlockSeconds   int // 临时封禁时长
+}
+
+// configLoginRisk 获取登录风控配置
+func configLoginRisk() LoginRiskConfig {
+	cfg := LoginRiskConfig{
+		CaptchaScore:   viper.GetInt("login_risk.captcha_score"),
+		EmailCodeScore: viper.GetInt("login_risk.email_code_score"),
+		BlockScore:     viper.GetInt("login_risk.block_score"),
+		BlockSeconds:   viper.GetInt("login_risk.block_seconds"),
+	}
+	if cfg.CaptchaScore <= 0 {
+		cfg.CaptchaScore = 40
+	}
+	if cfg.EmailCodeScore <= 0 {
+		cfg.EmailCodeScore = 60
+	}
+	if cfg.BlockScore <= 0 {
+		cfg.BlockScore = 100
+	}
+	if cfg.BlockSeconds <= 0 {
+		cfg.BlockSeconds = 900
+	}
+	return cfg
+}
+
+// CaptchaConfig Cloudflare Turnstile 凭证；未配置时风控不会要求人机验证
+type CaptchaConfig struct {
+	SiteKey   string // 下发给前端渲染组件
+	SecretKey string // 服务端 siteverify
+}
+
+// Enabled 两把 key 齐全才启用
+func (cfg CaptchaConfig) Enabled() bool {
+	return cfg.SiteKey != "" && cfg.SecretKey != ""
+}
+
+// configCaptcha 获取人机验证配置
+// Priority: captcha.turnstile.{brand}.* > captcha.turnstile.*（site key 绑定域名，按品牌官网区分）
+func configCaptcha(brand Brand) CaptchaConfig {
+	get := func(key string) string {
+		if v := viper.GetString(fmt.Sprintf("captcha.turnstile.%s.%s", brand, key)); v != "" {
+			return v
+		}
+		return viper.GetString("captcha.turnstile." + key)
+	}
+	return CaptchaConfig{
+		SiteKey:   get("site_key"),
+		SecretKey: get("secret_key"),
+	}
+}
+
 func ConfigServer(ctx context.Context) ServerConfig {
 	cfg := ServerConfig{
 		Port:       viper.GetInt("server.port"),
@@ -270,6 +399,61 @@ type CloudInstanceSyncConfig struct {
 	Cron    string
 }
 
+// CloudInstanceGuardConfig tunes the per-account provider middleware
+// (rate limit, throttle retries, circuit breaker). Zero values fall back to
+// cloudprovider.DefaultGuardOptions.
+type CloudInstanceGuardConfig struct {
+	RatePerSecond    float64
+	Burst            int
+	MaxRetries       int
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// CloudInstanceChangeIPConfig tunes the change-IP cutover workflow
+// (worker_cloud_change_ip.go). Zero values fall back to the defaults below.
+type CloudInstanceChangeIPConfig struct {
+	DrainWait       time.Duration // 摘流后等待客户端迁走再换 IP
+	RegisterTimeout time.Duration // 换 IP 后等待 sidecar 用新 IP 重新注册的上限
+	PollInterval    time.Duration // 重新注册检查间隔
+	RestartCommand  string        // 换 IP 后通过 SSH 在新 IP 上执行，促使 sidecar 重新探测 IP 并注册
+}
+
+// CloudInstanceFleetConfig is the declarative shared-pool spec
+// (logic_cloud_fleet.go). Only regions listed in Nodes are managed; everything
+// else is left alone by plan/apply.
+type CloudInstanceFleetConfig struct {
+	Images map[string]string // provider → 建机用的镜像 ID;没配镜像的 provider 不参与建机
+	Nodes  []CloudFleetSpecEntry
+}
+
+// CloudFleetSpecEntry is one line of the fleet spec, e.g.
+// "3 nodes in eu-frankfurt, brands kaitu+overleap, min 2TB transfer".
+type CloudFleetSpecEntry struct {
+	Region        string   `json:"region"` // cloudprovider.AllRegions 的统一 slug
+	Count         int      `json:"count"`
+	Brands        []Brand  `json:"brands,omitempty"` // 节点须声明的品牌;空 = 不限
+	MinTransferTB float64  `json:"minTransferTb"`
+	Accounts      []string `json:"accounts,omitempty"` // 限定建机账号;空 = 所有覆盖该 region 的账号
+}
+
+// CloudInstanceRotationConfig is the blocked-IP auto-rotation policy
+// (worker_cloud_rotation.go). Rotations are never executed directly: a
+// detection above the threshold only files a "cloud_rotate_ip" approval.
+type CloudInstanceRotationConfig struct {
+	Enabled             bool
+	Cron                string
+	ConfidenceThreshold float64       // 0-1,综合置信度达到才提交审批
+	MinSignals          int           // 至少要有几路信号有数据,避免单一信号误判
+	Cooldown            time.Duration // 同一实例两次换 IP 的最小间隔
+	MaxPerMonth         int           // 同一实例每自然月(UTC)最多换几次
+	Providers           []string      // 允许自动换 IP 的 provider,空 = 全部不允许
+	Window              time.Duration // 评分/遥测信号的回看窗口
+	Countries           []string      // 评分信号只看这些国家的用户(墙内视角)
+	MinRatings          int
+	MinConnections      int
+}
+
 // CloudInstanceConfig holds all cloud instance related configuration
 // Config structure:
 //
@@ -277,6 +461,38 @@ type CloudInstanceSyncConfig struct {
 //	  sync:
 //	    enabled: false
 //	    cron: "*/30 * * * *"
+//	  guard:
+//	    rate_per_second: 5
+//	    burst: 10
+//	    max_retries: 3
+//	    breaker_threshold: 5
+//	    breaker_cooldown: "5m"
+//	  change_ip:
+//	    drain_wait: "3m"
+//	    register_timeout: "30m"
+//	    poll_interval: "1m"
+//	    restart_command: "sudo docker restart k2s k2-sidecar"
+//	  rotation:
+//	    enabled: false
+//	    cron: "15 * * * *"
+//	    confidence_threshold: 0.7
+//	    min_signals: 2
+//	    cooldown: "72h"
+//	    max_per_month: 3
+//	    providers: ["aws_lightsail", "bandwagon"]
+//	    window: "24h"
+//	    countries: ["CN"]
+//	    min_ratings: 5
+//	    min_connections: 10
+//	  fleet:
+//	    images:
+//	      aws_lightsail: "ubuntu_22_04"
+//	    nodes:
+//	      - region: "eu-frankfurt"
+//	        count: 3
+//	        brands: ["kaitu", "overleap"]
+//	        min_transfer_tb: 2
+//	        accounts: ["aws-main"]
 //	  accounts:
 //	    - name: "aliyun-hk"
 //	      provider: "aliyun_swas"
@@ -285,6 +501,10 @@ type CloudInstanceSyncConfig struct {
 //	      access_key_secret: "xxx"
 type CloudInstanceConfig struct {
 	Sync     CloudInstanceSyncConfig
+	Guard    CloudInstanceGuardConfig
+	ChangeIP CloudInstanceChangeIPConfig
+	Rotation CloudInstanceRotationConfig
+	Fleet    CloudInstanceFleetConfig
 	Accounts []CloudInstanceAccount
 }
 
@@ -305,6 +525,46 @@ func ConfigCloudInstance() CloudInstanceConfig {
 		if cloudInstanceConfig.Sync.Cron == "" {
 			cloudInstanceConfig.Sync.Cron = "*/30 * * * *" // Default: every 30 minutes
 		}
+		cloudInstanceConfig.Guard = CloudInstanceGuardConfig{
+			RatePerSecond:    viper.GetFloat64("cloud_instance.guard.rate_per_second"),
+			Burst:            viper.GetInt("cloud_instance.guard.burst"),
+			MaxRetries:       viper.GetInt("cloud_instance.guard.max_retries"),
+			BreakerThreshold: viper.GetInt("cloud_instance.guard.breaker_threshold"),
+			BreakerCooldown:  viper.GetDuration("cloud_instance.guard.breaker_cooldown"),
+		}
+		cloudInstanceConfig.ChangeIP = CloudInstanceChangeIPConfig{
+			DrainWait:       viper.GetDuration("cloud_instance.change_ip.drain_wait"),
+			RegisterTimeout: viper.GetDuration("cloud_instance.change_ip.register_timeout"),
+			PollInterval:    viper.GetDuration("cloud_instance.change_ip.poll_interval"),
+			RestartCommand:  viper.GetString("cloud_instance.change_ip.restart_command"),
+		}
+		if cloudInstanceConfig.ChangeIP.DrainWait <= 0 {
+			cloudInstanceConfig.ChangeIP.DrainWait = 3 * time.Minute
+		}
+		if cloudInstanceConfig.ChangeIP.RegisterTimeout <= 0 {
+			cloudInstanceConfig.ChangeIP.RegisterTimeout = 30 * time.Minute
+		}
+		if cloudInstanceConfig.ChangeIP.PollInterval <= 0 {
+			cloudInstanceConfig.ChangeIP.PollInterval = time.Minute
+		}
+		if cloudInstanceConfig.ChangeIP.RestartCommand == "" {
+			cloudInstanceConfig.ChangeIP.RestartCommand = "sudo docker restart k2s k2-sidecar"
+		}
+		cloudInstanceConfig.Rotation = CloudInstanceRotationConfig{
+			Enabled:             viper.GetBool("cloud_instance.rotation.enabled"),
+			Cron:                viper.GetString("cloud_instance.rotation.cron"),
+			ConfidenceThreshold: viper.GetFloat64("cloud_instance.rotation.confidence_threshold"),
+			MinSignals:          viper.GetInt("cloud_instance.rotation.min_signals"),
+			Cooldown:            viper.GetDuration("cloud_instance.rotation.cooldown"),
+			MaxPerMonth:         viper.GetInt("cloud_instance.rotation.max_per_month"),
+			Providers:           viper.GetStringSlice("cloud_instance.rotation.providers"),
+			Window:              viper.GetDuration("cloud_instance.rotation.window"),
+			Countries:           viper.GetStringSlice("cloud_instance.rotation.countries"),
+			MinRatings:          viper.GetInt("cloud_instance.rotation.min_ratings"),
+			MinConnections:      viper.GetInt("cloud_instance.rotation.min_connections"),
+		}
+		applyRotationDefaults(&cloudInstanceConfig.Rotation)
+		cloudInstanceConfig.Fleet = parseCloudFleetConfig()
 
 		// Parse accounts
 		var accounts []interface{}
@@ -352,6 +612,83 @@ func ConfigCloudInstance() CloudInstanceConfig {
 	return *cloudInstanceConfig
 }
 
+// applyRotationDefaults fills unset rotation policy fields. Providers has no
+// default on purpose: auto-rotation must be opted into per provider.
+func applyRotationDefaults(r *CloudInstanceRotationConfig) {
+	if r.Cron == "" {
+		r.Cron = "15 * * * *"
+	}
+	if r.ConfidenceThreshold <= 0 {
+		r.ConfidenceThreshold = 0.7
+	}
+	if r.MinSignals <= 0 {
+		r.MinSignals = 2
+	}
+	if r.Cooldown <= 0 {
+		r.Cooldown = 72 * time.Hour
+	}
+	if r.MaxPerMonth <= 0 {
+		r.MaxPerMonth = 3
+	}
+	if r.Window <= 0 {
+		r.Window = 24 * time.Hour
+	}
+	if len(r.Countries) == 0 {
+		r.Countries = []string{"CN"}
+	}
+	if r.MinRatings <= 0 {
+		r.MinRatings = 5
+	}
+	if r.MinConnections <= 0 {
+		r.MinConnections = 10
+	}
+}
+
+// parseCloudFleetConfig reads cloud_instance.fleet. Entries with an unknown
+// region slug or invalid brand are dropped with an error log rather than
+// half-applied.
+func parseCloudFleetConfig() CloudInstanceFleetConfig {
+	fleet := CloudInstanceFleetConfig{
+		Images: viper.GetStringMapString("cloud_instance.fleet.images"),
+	}
+	var nodes []interface{}
+	if err := viper.UnmarshalKey("cloud_instance.fleet.nodes", &nodes); err != nil {
+		log.Errorf(context.Background(), "[CONFIG] Failed to parse cloud_instance.fleet.nodes: %v", err)
+		return fleet
+	}
+	for i, n := range nodes {
+		m, ok := n.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		entry := CloudFleetSpecEntry{
+			Region:        getString(m, "region"),
+			Count:         int(getFloat(m, "count")),
+			MinTransferTB: getFloat(m, "min_transfer_tb"),
+			Accounts:      getStringSlice(m, "accounts"),
+		}
+		if cloudprovider.GetRegionBySlug(entry.Region) == nil || entry.Count < 0 {
+			log.Errorf(context.Background(), "[CONFIG] cloud_instance.fleet.nodes[%d]: invalid region %q or count %d, skipped", i, entry.Region, entry.Count)
+			continue
+		}
+		valid := true
+		for _, b := range getStringSlice(m, "brands") {
+			brand := Brand(strings.ToLower(b))
+			if !brand.Valid() {
+				valid = false
+				break
+			}
+			entry.Brands = append(entry.Brands, brand)
+		}
+		if !valid {
+			log.Errorf(context.Background(), "[CONFIG] cloud_instance.fleet.nodes[%d]: invalid brand in %v, skipped", i, m["brands"])
+			continue
+		}
+		fleet.Nodes = append(fleet.Nodes, entry)
+	}
+	return fleet
+}
+
 // ConfigCloudInstanceAccountByName returns a specific cloud account by name
 func ConfigCloudInstanceAccountByName(name string) *CloudInstanceAccount {
 	cfg := ConfigCloudInstance()
@@ -373,3 +710,28 @@ func getString(m map[string]interface{}, key string) string {
 	return ""
 }
 
+// getFloat reads a YAML number (int or float) from map
+func getFloat(m map[string]interface{}, key string) float64 {
+	switch v := m[key].(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// getStringSlice reads a YAML string list from map
+func getStringSlice(m map[string]interface{}, key string) []string {
+	items, _ := m[key].([]interface{})
+	out := make([]string, 0, len(items))
+	for _, it := range items {
+		if s, ok := it.(string); ok && s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
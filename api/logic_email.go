@@ -164,6 +164,22 @@ var (
 
 如果您不知情，或这并非您主动联系客服请求的操作，请立即联系我们的客服。
 
+此致
+系统通知`,
+	}
+
+	adminResetTwoFactorTemplate = EmailTemplate[AdminResetTwoFactorMeta]{
+		Subject: "Kaitu 账号两步验证已被管理员重置",
+		Body: `尊敬的用户：
+
+您的 Kaitu 账号两步验证刚刚被管理员重置，登录时将不再要求认证器验证码。
+
+详细信息：
+- 操作时间：{{.ChangeTime}}
+- 操作人：{{if .AdminEmail}}{{.AdminEmail}}{{else}}（系统管理员）{{end}}
+
+建议您尽快在「账号安全」中重新启用两步验证。如果您不知情，请立即修改密码并联系我们的客服。
+
 此致
 系统通知`,
 	}
@@ -220,6 +236,12 @@ type AdminResetPasswordMeta struct {
 	AdminEmail string // 可能为空字符串：解密失败 / admin 无邮箱身份
 }
 
+// AdminResetTwoFactorMeta 管理员重置两步验证邮件元数据
+type AdminResetTwoFactorMeta struct {
+	ChangeTime string
+	AdminEmail string // 可能为空字符串：同 AdminResetPasswordMeta
+}
+
 // DelegatePayInviteMeta 代付邀请邮件元数据
 type DelegatePayInviteMeta struct {
 	InviterEmail string
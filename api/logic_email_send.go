@@ -22,8 +22,9 @@ type SendEmailItem struct {
 
 // SendEmailsRequest 批量发送请求
 type SendEmailsRequest struct {
-	BatchID string          `json:"batchId"`
-	Items   []SendEmailItem `json:"items"`
+	BatchID  string          `json:"batchId"`
+	Category NotifyCategory  `json:"category,omitempty"` // 通知类别，收件人关闭该类邮件时跳过；空为 service
+	Items    []SendEmailItem `json:"items"`
 }
 
 // SendEmailResultItem 单封邮件发送结果
@@ -114,7 +115,7 @@ func SendTemplatedEmails(ctx context.Context, req *SendEmailsRequest) (*SendEmai
 		case <-ticker.C:
 		}
 
-		itemResult := sendSingleTemplatedEmail(ctx, req.BatchID, &item, templateCache, varsCache)
+		itemResult := sendSingleTemplatedEmail(ctx, req.BatchID, req.Category, &item, templateCache, varsCache)
 		result.Items = append(result.Items, itemResult)
 
 		switch itemResult.Status {
@@ -140,6 +141,7 @@ func SendTemplatedEmails(ctx context.Context, req *SendEmailsRequest) (*SendEmai
 func sendSingleTemplatedEmail(
 	ctx context.Context,
 	batchID string,
+	category NotifyCategory,
 	item *SendEmailItem,
 	templateCache map[string]*EmailMarketingTemplate,
 	varsCache map[string][]string,
@@ -244,10 +246,21 @@ func sendSingleTemplatedEmail(
 		return resultItem
 	}
 
-	// 7. Create log (pending)
+	// 7. Inbox first, then notification preference — same as PushToUser:
+	// muting a channel stops delivery, not the in-app copy. A skipped log row
+	// keeps a rerun of the batch from recording the inbox twice.
+	recordInbox(ctx, userID, category, NotifyChannelEmail, subject, inboxTextFromHTML(content), nil)
+	if !notificationAllowed(ctx, userID, category, NotifyChannelEmail) {
+		resultItem.Status = "skipped"
+		resultItem.Error = "muted by user preference"
+		createEmailSendLog(ctx, batchID, tmpl.ID, userID, item.Email, userLang, EmailSendLogStatusSkipped, resultItem.Error)
+		return resultItem
+	}
+
+	// 8. Create log (pending)
 	sendLog := createEmailSendLog(ctx, batchID, tmpl.ID, userID, item.Email, userLang, EmailSendLogStatusPending, "")
 
-	// 8. Send — from_name/from_email identity keyed off the recipient's own
+	// 9. Send — from_name/from_email identity keyed off the recipient's own
 	// brand (resolvedUser.Brand), same authority used for template selection
 	// above (step 2), not the request brand (batch sends have no request ctx).
 	if err := sendEmail(ctx, item.Email, subject, content, Brand(resolvedUser.Brand)); err != nil {
@@ -257,7 +270,7 @@ func sendSingleTemplatedEmail(
 		return resultItem
 	}
 
-	// 9. Success
+	// 10. Success
 	updateEmailSendLogStatus(sendLog, EmailSendLogStatusSent, "")
 	resultItem.Status = "sent"
 	return resultItem
@@ -0,0 +1,475 @@
+package center
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"github.com/wordgate/qtoolkit/redis"
+)
+
+// ===================== 登录风控 =====================
+//
+// 发码、验证码登录、密码登录在进入凭证校验前先过一次风控评估：
+// 按邮箱 / IP / 网段 / ASN 统计窗口内的失败次数，叠加邮箱与 IP 的扇出、
+// 国家变化、新设备等信号打分，再按 LoginRiskConfig 的阈值逐级升级挑战：
+// 人机验证 → 只允许邮箱验证码 → 临时封禁来源 IP。
+// 被拦下、带信号或最终失败的评估写一条 LoginRiskEvent，管理后台据此追溯和解封；
+// 干净放行且登录成功的只按 loginRiskAllowSampleRate 抽样落库，避免每次登录都写一行。
+
+const (
+	loginRiskActionSendCode      = "send_code"
+	loginRiskActionCodeLogin     = "code_login"
+	loginRiskActionPasswordLogin = "password_login"
+	loginRiskActionDeviceCode    = "device_code" // 申请设备授权码（无邮箱，只有 IP 侧信号）
+
+	loginRiskDecisionAllow     = "allow"
+	loginRiskDecisionCaptcha   = "captcha"
+	loginRiskDecisionEmailCode = "email_code"
+	loginRiskDecisionBlock     = "block"
+
+	loginRiskOutcomeSuccess  = "success"  // 凭证校验通过（发码场景为已发送）
+	loginRiskOutcomeFailed   = "failed"   // 凭证错误或邮箱未注册
+	loginRiskOutcomeRejected = "rejected" // 被风控决策拦下，未进入凭证校验
+
+	// 固定窗口：计数 key 首次写入时设置 TTL，窗口内累计
+	loginRiskWindow = 15 * time.Minute
+
+	// 干净放行（无信号）的评估每 N 次抽样落库一次，保留成功基线供对比
+	loginRiskAllowSampleRate = 20
+
+	// LoginRiskEvent 保留天数：后台只追溯近期的拦截与解封
+	loginRiskEventRetentionDays = 90
+)
+
+// riskStep 计数达到 Min 即得 Score 分；表按 Min 降序排列，取第一个命中项
+type riskStep struct {
+	Min   int64
+	Score int
+}
+
+var (
+	riskEmailFailSteps  = []riskStep{{12, 70}, {8, 55}, {5, 40}, {3, 20}}
+	riskIPFailSteps     = []riskStep{{40, 100}, {20, 70}, {10, 40}, {5, 20}}
+	riskSubnetFailSteps = []riskStep{{80, 70}, {30, 40}, {10, 15}}
+	riskASNFailSteps    = []riskStep{{300, 30}, {100, 10}}
+	riskIPEmailsSteps   = []riskStep{{50, 70}, {20, 45}, {10, 20}} // 同一 IP 失败过的不同邮箱数（撞库 / 密码喷洒）
+	riskEmailIPsSteps   = []riskStep{{12, 60}, {6, 35}, {3, 15}}   // 同一邮箱失败过的不同 IP 数（分布式爆破）
+
+	riskCountryChangeScore = 25
+	riskNewDeviceScore     = 15
+)
+
+// turnstileVerifyURL Cloudflare Turnstile 服务端校验地址（测试中替换为本地 mock）
+var turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+var captchaHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// loginRiskAttempt 一次待评估的登录相关请求
+type loginRiskAttempt struct {
+	Action       string
+	EmailHash    string
+	User         *User  // nil = 邮箱未注册
+	UDID         string // 仅 App 端；Web 端为空，不参与新设备判断
+	CaptchaToken string
+}
+
+// loginRisk 风控评估结果；放行后随请求传给 recordLoginFailure / recordLoginSuccess
+type loginRisk struct {
+	Action    string
+	EmailHash string
+	IP        string
+	Subnet    string
+	ASN       uint
+	Score     int
+	Signals   []string
+	Decision  string
+	EventID   uint64
+
+	pending *LoginRiskEvent // 未抽中的干净放行：登录失败时再补写
+}
+
+// loginRiskSampleAllow 决定干净放行是否落库（测试中替换）
+var loginRiskSampleAllow = func() bool {
+	return rand.IntN(loginRiskAllowSampleRate) == 0
+}
+
+func riskStepScore(steps []riskStep, n int64) int {
+	for _, s := range steps {
+		if n >= s.Min {
+			return s.Score
+		}
+	}
+	return 0
+}
+
+// ipSubnet 同一运营商分配的相邻地址归为一组：IPv4 取 /24，IPv6 取 /48
+func ipSubnet(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+func loginRiskFailKey(dimension, value string) string {
+	return "risk:fail:" + dimension + ":" + value
+}
+
+func loginRiskBlockKey(ip string) string {
+	return "risk:block:ip:" + ip
+}
+
+func loginRiskIPEmailsKey(ip string) string {
+	return "risk:ip_emails:" + ip
+}
+
+func loginRiskEmailIPsKey(emailHash string) string {
+	return "risk:email_ips:" + emailHash
+}
+
+// loginRiskFailKeys 一次失败要累加的全部计数 key
+func loginRiskFailKeys(risk *loginRisk) []string {
+	keys := []string{loginRiskFailKey("ip", risk.IP)}
+	if risk.EmailHash != "" {
+		keys = append(keys, loginRiskFailKey("email", risk.EmailHash))
+	}
+	if risk.Subnet != "" {
+		keys = append(keys, loginRiskFailKey("subnet", risk.Subnet))
+	}
+	if risk.ASN != 0 {
+		keys = append(keys, loginRiskFailKey("asn", fmt.Sprint(risk.ASN)))
+	}
+	return keys
+}
+
+// assessLoginRisk 给一次请求打分并得出原始决策（未按动作类型和人机验证结果调整）。
+// Redis 故障时按无信号放行：风控不能成为登录的单点故障。
+func assessLoginRisk(c *gin.Context, attempt loginRiskAttempt) *loginRisk {
+	ctx := context.Background()
+	rdb := redis.Client()
+	cfg := configLoginRisk()
+
+	ip := c.ClientIP()
+	risk := &loginRisk{
+		Action:    attempt.Action,
+		EmailHash: attempt.EmailHash,
+		IP:        ip,
+		Subnet:    ipSubnet(ip),
+		ASN:       ASNFromIP(ip),
+		Decision:  loginRiskDecisionAllow,
+	}
+
+	if n, err := rdb.Exists(ctx, loginRiskBlockKey(ip)).Result(); err != nil {
+		log.Warnf(c, "login risk: failed to check block of %s: %v", ip, err)
+	} else if n > 0 {
+		risk.Score = cfg.BlockScore
+		risk.Signals = []string{"ip_blocked"}
+		risk.Decision = loginRiskDecisionBlock
+		return risk
+	}
+
+	// 封禁只落在来源 IP 上，所以单凭邮箱侧信号最多升级到"只允许验证码登录"，
+	// 否则攻击者刷失败次数就能把真实用户连同其 IP 一起锁在门外
+	emailScore, ipScore := 0, 0
+	add := func(score *int, signal string, points int) {
+		if points > 0 {
+			*score += points
+			risk.Signals = append(risk.Signals, signal)
+		}
+	}
+	failKeys := loginRiskFailKeys(risk)
+	failCounts := make(map[string]int64, len(failKeys))
+	if values, err := rdb.MGet(ctx, failKeys...).Result(); err != nil {
+		log.Warnf(c, "login risk: failed to read failure counters: %v", err)
+	} else {
+		for i, v := range values {
+			if str, ok := v.(string); ok {
+				failCounts[failKeys[i]], _ = strconv.ParseInt(str, 10, 64)
+			}
+		}
+	}
+	count := func(dimension, value string) int64 {
+		return failCounts[loginRiskFailKey(dimension, value)]
+	}
+
+	if n := count("ip", ip); n > 0 {
+		add(&ipScore, fmt.Sprintf("ip_fail=%d", n), riskStepScore(riskIPFailSteps, n))
+	}
+	if n := count("subnet", risk.Subnet); n > 0 {
+		add(&ipScore, fmt.Sprintf("subnet_fail=%d", n), riskStepScore(riskSubnetFailSteps, n))
+	}
+	if n := count("asn", fmt.Sprint(risk.ASN)); n > 0 {
+		add(&ipScore, fmt.Sprintf("asn_fail=%d", n), riskStepScore(riskASNFailSteps, n))
+	}
+	if n := count("email", attempt.EmailHash); n > 0 {
+		add(&emailScore, fmt.Sprintf("email_fail=%d", n), riskStepScore(riskEmailFailSteps, n))
+	}
+
+	// 扇出：失败时写入的 HyperLogLog 近似去重计数
+	if n, err := rdb.PFCount(ctx, loginRiskIPEmailsKey(ip)).Result(); err != nil {
+		log.Warnf(c, "login risk: failed to read fan-out of %s: %v", ip, err)
+	} else if n > 0 {
+		add(&ipScore, fmt.Sprintf("ip_emails=%d", n), riskStepScore(riskIPEmailsSteps, n))
+	}
+	if attempt.EmailHash != "" {
+		if n, err := rdb.PFCount(ctx, loginRiskEmailIPsKey(attempt.EmailHash)).Result(); err != nil {
+			log.Warnf(c, "login risk: failed to read fan-out of email: %v", err)
+		} else if n > 0 {
+			add(&emailScore, fmt.Sprintf("email_ips=%d", n), riskStepScore(riskEmailIPsSteps, n))
+		}
+	}
+
+	if user := attempt.User; user != nil {
+		country := CountryFromGinContext(c)
+		if user.CurrentCountry != "" && country != "" && country != user.CurrentCountry {
+			add(&emailScore, fmt.Sprintf("country_change=%s>%s", user.CurrentCountry, country), riskCountryChangeScore)
+		}
+		if attempt.UDID != "" {
+			var known int64
+			if err := db.Get().Model(&Device{}).Where("udid = ? AND user_id = ?", attempt.UDID, user.ID).Count(&known).Error; err != nil {
+				log.Warnf(c, "login risk: failed to check device %s of user %d: %v", attempt.UDID, user.ID, err)
+			} else if known == 0 {
+				add(&emailScore, "new_device", riskNewDeviceScore)
+			}
+		}
+	}
+
+	risk.Score = emailScore + ipScore
+	switch {
+	case risk.Score >= cfg.BlockScore && ipScore > 0:
+		risk.Decision = loginRiskDecisionBlock
+	case risk.Score >= cfg.EmailCodeScore:
+		risk.Decision = loginRiskDecisionEmailCode
+	case risk.Score >= cfg.CaptchaScore:
+		risk.Decision = loginRiskDecisionCaptcha
+	}
+	return risk
+}
+
+// enforceLoginRisk 评估请求并执行决策，写入风控记录。
+// 返回 false 时已写出错误响应，调用方直接 return。
+//
+// 不同动作能施加的挑战不同：
+//   - 发码：无法"改用验证码"，email_code 降为人机验证
+//   - 验证码登录：持有邮箱验证码本身已是强凭证，只执行封禁
+//   - 密码登录：全部决策
+//   - 设备授权码：无头客户端做不了人机验证，同验证码登录只执行封禁，
+//     频率由 api_device_code 的按 IP 计数兜住
+//
+// 需要人机验证但品牌未配置 Turnstile 时，密码登录升级为只允许验证码，其余放行。
+func enforceLoginRisk(c *gin.Context, attempt loginRiskAttempt) (*loginRisk, bool) {
+	risk := assessLoginRisk(c, attempt)
+
+	switch attempt.Action {
+	case loginRiskActionSendCode:
+		if risk.Decision == loginRiskDecisionEmailCode {
+			risk.Decision = loginRiskDecisionCaptcha
+		}
+	case loginRiskActionCodeLogin, loginRiskActionDeviceCode:
+		if risk.Decision != loginRiskDecisionBlock {
+			risk.Decision = loginRiskDecisionAllow
+		}
+	}
+
+	if risk.Decision == loginRiskDecisionCaptcha {
+		cfg := configCaptcha(ReqBrand(c))
+		switch {
+		case !cfg.Enabled():
+			risk.Signals = append(risk.Signals, "captcha_unavailable")
+			if attempt.Action == loginRiskActionPasswordLogin {
+				risk.Decision = loginRiskDecisionEmailCode
+			} else {
+				risk.Decision = loginRiskDecisionAllow
+			}
+		case attempt.CaptchaToken == "":
+			// 保持 captcha，前端据此渲染组件
+		case verifyCaptchaToken(c, cfg, attempt.CaptchaToken):
+			risk.Signals = append(risk.Signals, "captcha_passed")
+			risk.Decision = loginRiskDecisionAllow
+		default:
+			risk.Signals = append(risk.Signals, "captcha_failed")
+		}
+	}
+
+	if risk.Decision == loginRiskDecisionBlock && !(len(risk.Signals) == 1 && risk.Signals[0] == "ip_blocked") {
+		seconds := configLoginRisk().BlockSeconds
+		if err := redis.Client().Set(context.Background(), loginRiskBlockKey(risk.IP), risk.Score, time.Duration(seconds)*time.Second).Err(); err != nil {
+			log.Errorf(c, "login risk: failed to block %s: %v", risk.IP, err)
+		} else {
+			log.Warnf(c, "login risk: blocked %s for %ds, score=%d signals=%v", risk.IP, seconds, risk.Score, risk.Signals)
+		}
+	}
+
+	event := LoginRiskEvent{
+		Action:    attempt.Action,
+		Brand:     string(ReqBrand(c)),
+		EmailHash: attempt.EmailHash,
+		IP:        risk.IP,
+		Subnet:    risk.Subnet,
+		ASN:       risk.ASN,
+		Country:   CountryFromGinContext(c),
+		UDID:      attempt.UDID,
+		Score:     risk.Score,
+		Signals:   truncateRiskSignals(risk.Signals),
+		Decision:  risk.Decision,
+	}
+	if attempt.User != nil {
+		event.UserID = attempt.User.ID
+	}
+	if risk.Decision != loginRiskDecisionAllow {
+		event.Outcome = loginRiskOutcomeRejected
+	}
+	if risk.Decision != loginRiskDecisionAllow || len(risk.Signals) > 0 || loginRiskSampleAllow() {
+		saveLoginRiskEvent(c, risk, &event)
+	} else {
+		risk.pending = &event
+	}
+
+	switch risk.Decision {
+	case loginRiskDecisionBlock:
+		log.Warnf(c, "login risk: %s from %s rejected (block), score=%d signals=%v", attempt.Action, risk.IP, risk.Score, risk.Signals)
+		Error(c, ErrorTooManyRequests, "too many login attempts, try again later")
+		return risk, false
+	case loginRiskDecisionEmailCode:
+		log.Warnf(c, "login risk: %s from %s rejected (email code), score=%d signals=%v", attempt.Action, risk.IP, risk.Score, risk.Signals)
+		Error(c, ErrorEmailCodeRequired, "sign in with an email verification code")
+		return risk, false
+	case loginRiskDecisionCaptcha:
+		log.Infof(c, "login risk: %s from %s requires captcha, score=%d signals=%v", attempt.Action, risk.IP, risk.Score, risk.Signals)
+		Error(c, ErrorCaptchaRequired, "captcha required")
+		return risk, false
+	}
+	return risk, true
+}
+
+// loginRiskUser 按邮箱找本品牌用户，仅供打分（国家变化 / 新设备）；查不到或出错都返回 nil。
+// 验证码登录在校验验证码之后才查用户，这里单独查一次，避免改变"用户不存在"的返回时机。
+func loginRiskUser(c *gin.Context, emailHash string) *User {
+	var identify LoginIdentify
+	if err := db.Get().Preload("User").Where(&LoginIdentify{Type: "email", IndexID: emailHash, Brand: string(ReqBrand(c))}).First(&identify).Error; err != nil {
+		return nil
+	}
+	return identify.User
+}
+
+// truncateRiskSignals 拼成逗号列表并截断到列宽
+func truncateRiskSignals(signals []string) string {
+	s := strings.Join(signals, ",")
+	if len(s) > 255 {
+		s = s[:255]
+	}
+	return s
+}
+
+// recordLoginFailure 凭证错误（或邮箱未注册）后累加各维度失败计数
+func recordLoginFailure(c *gin.Context, risk *loginRisk) {
+	if risk == nil {
+		return
+	}
+	ctx := context.Background()
+	rdb := redis.Client()
+	for _, key := range loginRiskFailKeys(risk) {
+		n, err := rdb.Incr(ctx, key).Result()
+		if err != nil {
+			log.Warnf(c, "login risk: failed to count failure %s: %v", key, err)
+			continue
+		}
+		if n == 1 {
+			rdb.Expire(ctx, key, loginRiskWindow)
+		}
+	}
+	if risk.EmailHash != "" {
+		pipe := rdb.TxPipeline()
+		pipe.PFAdd(ctx, loginRiskIPEmailsKey(risk.IP), risk.EmailHash)
+		pipe.Expire(ctx, loginRiskIPEmailsKey(risk.IP), loginRiskWindow)
+		pipe.PFAdd(ctx, loginRiskEmailIPsKey(risk.EmailHash), risk.IP)
+		pipe.Expire(ctx, loginRiskEmailIPsKey(risk.EmailHash), loginRiskWindow)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Warnf(c, "login risk: failed to count fan-out: %v", err)
+		}
+	}
+	setLoginRiskOutcome(c, risk, loginRiskOutcomeFailed)
+}
+
+// recordLoginSuccess 凭证校验通过：登录动作清零该邮箱的失败计数
+// （IP 侧计数保留，一个成功账号不能替整个来源洗白）
+func recordLoginSuccess(c *gin.Context, risk *loginRisk) {
+	if risk == nil {
+		return
+	}
+	if risk.Action != loginRiskActionSendCode && risk.EmailHash != "" {
+		if err := redis.Client().Del(context.Background(), loginRiskFailKey("email", risk.EmailHash)).Err(); err != nil {
+			log.Warnf(c, "login risk: failed to reset email failures: %v", err)
+		}
+	}
+	setLoginRiskOutcome(c, risk, loginRiskOutcomeSuccess)
+}
+
+func saveLoginRiskEvent(c *gin.Context, risk *loginRisk, event *LoginRiskEvent) {
+	if err := db.Get().Create(event).Error; err != nil {
+		log.Errorf(c, "login risk: failed to save event: %v", err)
+		return
+	}
+	risk.EventID = event.ID
+}
+
+func setLoginRiskOutcome(c *gin.Context, risk *loginRisk, outcome string) {
+	if risk.EventID == 0 {
+		if risk.pending != nil && outcome == loginRiskOutcomeFailed {
+			risk.pending.Outcome = outcome
+			saveLoginRiskEvent(c, risk, risk.pending)
+			risk.pending = nil
+		}
+		return
+	}
+	if err := db.Get().Model(&LoginRiskEvent{}).Where("id = ?", risk.EventID).Update("outcome", outcome).Error; err != nil {
+		log.Warnf(c, "login risk: failed to update outcome of event %d: %v", risk.EventID, err)
+	}
+}
+
+// unblockLoginRiskIP 解除 IP 的临时封禁并清零其失败计数
+func unblockLoginRiskIP(ctx context.Context, ip string) error {
+	return redis.Client().Del(ctx, loginRiskBlockKey(ip), loginRiskFailKey("ip", ip)).Err()
+}
+
+// verifyCaptchaToken 向 Turnstile 校验前端提交的 token（一次性，重复提交会失败）
+func verifyCaptchaToken(c *gin.Context, cfg CaptchaConfig, token string) bool {
+	form := url.Values{
+		"secret":   {cfg.SecretKey},
+		"response": {token},
+		"remoteip": {c.ClientIP()},
+	}
+	resp, err := captchaHTTPClient.PostForm(turnstileVerifyURL, form)
+	if err != nil {
+		log.Errorf(c, "captcha verify request failed: %v", err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Errorf(c, "captcha verify response invalid: %v", err)
+		return false
+	}
+	if !result.Success {
+		log.Warnf(c, "captcha verify rejected: %v", result.ErrorCodes)
+	}
+	return result.Success
+}
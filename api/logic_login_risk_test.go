@@ -0,0 +1,400 @@
+package center
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/redis"
+)
+
+// resetLoginRiskKeys 清掉风控计数，避免与同一进程内其它登录测试互相影响
+func resetLoginRiskKeys(t *testing.T) {
+	t.Helper()
+	testInitConfig()
+	purge := func() {
+		for _, key := range testMiniRedis.Keys() {
+			if strings.HasPrefix(key, "risk:") {
+				testMiniRedis.Del(key)
+			}
+		}
+	}
+	purge()
+	t.Cleanup(purge)
+}
+
+// riskTestContext 构造来源为 ip 的请求上下文
+func riskTestContext(ip string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/login/password", nil)
+	c.Request.RemoteAddr = ip + ":40000"
+	return c, w
+}
+
+// failLogins 模拟 n 次来自 ip 的失败登录（不落库）
+func failLogins(ip, emailHash string, n int) {
+	c, _ := riskTestContext(ip)
+	for i := 0; i < n; i++ {
+		recordLoginFailure(c, &loginRisk{
+			Action:    loginRiskActionPasswordLogin,
+			EmailHash: emailHash,
+			IP:        ip,
+			Subnet:    ipSubnet(ip),
+		})
+	}
+}
+
+func TestIPSubnet(t *testing.T) {
+	assert.Equal(t, "203.0.113.0/24", ipSubnet("203.0.113.77"))
+	assert.Equal(t, "2001:db8:1::/48", ipSubnet("2001:db8:1:2::5"))
+	assert.Equal(t, "", ipSubnet("not-an-ip"))
+}
+
+func TestRiskStepScore(t *testing.T) {
+	assert.Equal(t, 0, riskStepScore(riskEmailFailSteps, 2))
+	assert.Equal(t, 20, riskStepScore(riskEmailFailSteps, 3))
+	assert.Equal(t, 40, riskStepScore(riskEmailFailSteps, 7))
+	assert.Equal(t, 70, riskStepScore(riskEmailFailSteps, 500))
+}
+
+func TestAssessLoginRisk_CleanRequestAllowed(t *testing.T) {
+	resetLoginRiskKeys(t)
+
+	c, _ := riskTestContext("198.51.100.10")
+	risk := assessLoginRisk(c, loginRiskAttempt{Action: loginRiskActionPasswordLogin, EmailHash: "hash-clean"})
+	assert.Equal(t, loginRiskDecisionAllow, risk.Decision)
+	assert.Equal(t, 0, risk.Score)
+	assert.Empty(t, risk.Signals)
+	assert.Equal(t, "198.51.100.0/24", risk.Subnet)
+}
+
+func TestAssessLoginRisk_EscalatesWithFailures(t *testing.T) {
+	resetLoginRiskKeys(t)
+	const ip = "198.51.100.20"
+
+	// 3 次：邮箱 20 分，不到人机验证阈值
+	failLogins(ip, "hash-victim", 3)
+	c, _ := riskTestContext(ip)
+	risk := assessLoginRisk(c, loginRiskAttempt{Action: loginRiskActionPasswordLogin, EmailHash: "hash-victim"})
+	assert.Equal(t, loginRiskDecisionAllow, risk.Decision)
+	assert.Equal(t, 20, risk.Score)
+
+	// 5 次：邮箱 40 + IP 20 → 只允许验证码
+	failLogins(ip, "hash-victim", 2)
+	risk = assessLoginRisk(c, loginRiskAttempt{Action: loginRiskActionPasswordLogin, EmailHash: "hash-victim"})
+	assert.Equal(t, loginRiskDecisionEmailCode, risk.Decision)
+	assert.Equal(t, 60, risk.Score)
+	assert.Contains(t, risk.Signals, "email_fail=5")
+	assert.Contains(t, risk.Signals, "ip_fail=5")
+
+	// 同一 IP 换个邮箱：只剩 IP 侧 20 分
+	risk = assessLoginRisk(c, loginRiskAttempt{Action: loginRiskActionPasswordLogin, EmailHash: "hash-other"})
+	assert.Equal(t, loginRiskDecisionAllow, risk.Decision)
+	assert.Equal(t, 20, risk.Score)
+}
+
+func TestAssessLoginRisk_SprayingBlocksIP(t *testing.T) {
+	resetLoginRiskKeys(t)
+	const ip = "198.51.100.30"
+
+	for i := 0; i < 40; i++ {
+		failLogins(ip, fmt.Sprintf("hash-spray-%d", i), 1)
+	}
+	c, _ := riskTestContext(ip)
+	risk := assessLoginRisk(c, loginRiskAttempt{Action: loginRiskActionSendCode, EmailHash: "hash-next"})
+	assert.Equal(t, loginRiskDecisionBlock, risk.Decision)
+	assert.Contains(t, risk.Signals, "ip_fail=40")
+	assert.Contains(t, risk.Signals, "ip_emails=40")
+}
+
+// 分布式爆破同一邮箱：邮箱侧分数再高也不封禁（封禁只落在 IP 上），
+// 真实用户仍可用邮箱验证码登录
+func TestAssessLoginRisk_EmailSignalsNeverBlock(t *testing.T) {
+	resetLoginRiskKeys(t)
+
+	for i := 0; i < 12; i++ {
+		failLogins(fmt.Sprintf("203.0.%d.9", 100+i), "hash-target", 1)
+	}
+	c, _ := riskTestContext("198.51.100.40")
+	risk := assessLoginRisk(c, loginRiskAttempt{Action: loginRiskActionPasswordLogin, EmailHash: "hash-target"})
+	assert.GreaterOrEqual(t, risk.Score, configLoginRisk().BlockScore)
+	assert.Equal(t, loginRiskDecisionEmailCode, risk.Decision)
+	assert.Contains(t, risk.Signals, "email_ips=12")
+}
+
+func TestRecordLoginSuccess_ResetsEmailFailures(t *testing.T) {
+	resetLoginRiskKeys(t)
+	const ip = "198.51.100.50"
+
+	failLogins(ip, "hash-forgetful", 4)
+	c, _ := riskTestContext(ip)
+	recordLoginSuccess(c, &loginRisk{Action: loginRiskActionPasswordLogin, EmailHash: "hash-forgetful", IP: ip})
+
+	risk := assessLoginRisk(c, loginRiskAttempt{Action: loginRiskActionPasswordLogin, EmailHash: "hash-forgetful"})
+	assert.NotContains(t, strings.Join(risk.Signals, ","), "email_fail")
+	// IP 侧计数不因一次成功清零
+	n, err := redis.Client().Get(context.Background(), loginRiskFailKey("ip", ip)).Int64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+}
+
+// mockTurnstile 启动假的 siteverify，token 为 "pass" 时通过
+func mockTurnstile(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		ok := r.PostForm.Get("secret") == "turnstile-secret" && r.PostForm.Get("response") == "pass"
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": ok})
+	}))
+	t.Cleanup(srv.Close)
+
+	origURL := turnstileVerifyURL
+	turnstileVerifyURL = srv.URL
+	viper.Set("captcha.turnstile.site_key", "turnstile-site")
+	viper.Set("captcha.turnstile.secret_key", "turnstile-secret")
+	t.Cleanup(func() {
+		turnstileVerifyURL = origURL
+		viper.Set("captcha.turnstile.site_key", "")
+		viper.Set("captcha.turnstile.secret_key", "")
+	})
+}
+
+func lastLoginRiskEvent(t *testing.T) LoginRiskEvent {
+	t.Helper()
+	var ev LoginRiskEvent
+	require.NoError(t, db.Get().Order("id DESC").First(&ev).Error)
+	return ev
+}
+
+func responseCode(t *testing.T, w *httptest.ResponseRecorder) ErrorCode {
+	t.Helper()
+	resp, err := ParseResponse(w)
+	require.NoError(t, err, "body=%s", w.Body.String())
+	return ErrorCode(resp.Code)
+}
+
+func TestEnforceLoginRisk_CaptchaChallenge(t *testing.T) {
+	skipIfNoConfig(t)
+	resetLoginRiskKeys(t)
+	mockTurnstile(t)
+	const ip = "198.51.100.60"
+
+	// IP 10 次失败：IP 40 + 网段 15，达到人机验证阈值
+	failLogins(ip, "hash-captcha-seed", 10)
+
+	c, w := riskTestContext(ip)
+	_, ok := enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionPasswordLogin, EmailHash: "hash-captcha-x"})
+	assert.False(t, ok)
+	assert.Equal(t, ErrorCaptchaRequired, responseCode(t, w))
+	ev := lastLoginRiskEvent(t)
+	assert.Equal(t, loginRiskDecisionCaptcha, ev.Decision)
+	assert.Equal(t, loginRiskOutcomeRejected, ev.Outcome)
+
+	c, w = riskTestContext(ip)
+	_, ok = enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionPasswordLogin, EmailHash: "hash-captcha-x", CaptchaToken: "wrong"})
+	assert.False(t, ok)
+	assert.Equal(t, ErrorCaptchaRequired, responseCode(t, w))
+	assert.Contains(t, lastLoginRiskEvent(t).Signals, "captcha_failed")
+
+	c, _ = riskTestContext(ip)
+	risk, ok := enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionPasswordLogin, EmailHash: "hash-captcha-x", CaptchaToken: "pass"})
+	require.True(t, ok)
+	assert.Equal(t, loginRiskDecisionAllow, risk.Decision)
+
+	recordLoginSuccess(c, risk)
+	ev = lastLoginRiskEvent(t)
+	assert.Equal(t, loginRiskOutcomeSuccess, ev.Outcome)
+	assert.Contains(t, ev.Signals, "captcha_passed")
+}
+
+func TestEnforceLoginRisk_CaptchaUnavailable(t *testing.T) {
+	skipIfNoConfig(t)
+	resetLoginRiskKeys(t)
+	const ip = "198.51.100.70"
+
+	failLogins(ip, "hash-nocaptcha-seed", 10)
+
+	// 密码登录升级为只允许验证码
+	c, w := riskTestContext(ip)
+	_, ok := enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionPasswordLogin, EmailHash: "hash-nocaptcha-x"})
+	assert.False(t, ok)
+	assert.Equal(t, ErrorEmailCodeRequired, responseCode(t, w))
+
+	// 发码放行，记下信号
+	c, _ = riskTestContext(ip)
+	risk, ok := enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionSendCode, EmailHash: "hash-nocaptcha-x"})
+	require.True(t, ok)
+	assert.Contains(t, risk.Signals, "captcha_unavailable")
+}
+
+func TestEnforceLoginRisk_BlockPersistsAndUnblocks(t *testing.T) {
+	skipIfNoConfig(t)
+	resetLoginRiskKeys(t)
+	const ip = "198.51.100.80"
+
+	for i := 0; i < 40; i++ {
+		failLogins(ip, fmt.Sprintf("hash-block-%d", i), 1)
+	}
+
+	c, w := riskTestContext(ip)
+	_, ok := enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionCodeLogin, EmailHash: "hash-block-x"})
+	assert.False(t, ok)
+	assert.Equal(t, ErrorTooManyRequests, responseCode(t, w))
+
+	// 清掉失败计数后封禁仍然生效
+	testMiniRedis.Del(loginRiskFailKey("ip", ip))
+	testMiniRedis.Del(loginRiskIPEmailsKey(ip))
+	c, w = riskTestContext(ip)
+	_, ok = enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionSendCode, EmailHash: "hash-block-y"})
+	assert.False(t, ok)
+	assert.Equal(t, ErrorTooManyRequests, responseCode(t, w))
+	assert.Equal(t, "ip_blocked", lastLoginRiskEvent(t).Signals)
+
+	require.NoError(t, unblockLoginRiskIP(context.Background(), ip))
+	c, _ = riskTestContext(ip)
+	_, ok = enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionSendCode, EmailHash: "hash-block-y"})
+	assert.True(t, ok)
+}
+
+// 验证码登录只执行封禁：即使分数达到验证码阈值也放行
+func TestEnforceLoginRisk_CodeLoginOnlyBlocks(t *testing.T) {
+	skipIfNoConfig(t)
+	resetLoginRiskKeys(t)
+	const ip = "198.51.100.90"
+
+	failLogins(ip, "hash-code-login", 8)
+	c, _ := riskTestContext(ip)
+	risk, ok := enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionCodeLogin, EmailHash: "hash-code-login"})
+	require.True(t, ok)
+	assert.Equal(t, loginRiskDecisionAllow, risk.Decision)
+	assert.GreaterOrEqual(t, risk.Score, configLoginRisk().EmailCodeScore)
+}
+
+// 干净放行未抽中时不落库，登录失败时补写；抽中的照常落库
+func TestEnforceLoginRisk_SamplesCleanAllows(t *testing.T) {
+	skipIfNoConfig(t)
+	resetLoginRiskKeys(t)
+	const ip = "198.51.100.95"
+	sampled := false
+	orig := loginRiskSampleAllow
+	loginRiskSampleAllow = func() bool { return sampled }
+	t.Cleanup(func() { loginRiskSampleAllow = orig })
+
+	countEvents := func() int64 {
+		var n int64
+		require.NoError(t, db.Get().Model(&LoginRiskEvent{}).Where("ip = ?", ip).Count(&n).Error)
+		return n
+	}
+
+	c, _ := riskTestContext(ip)
+	risk, ok := enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionCodeLogin, EmailHash: "hash-sample-x"})
+	require.True(t, ok)
+	recordLoginSuccess(c, risk)
+	assert.Equal(t, int64(0), countEvents())
+
+	c, _ = riskTestContext(ip)
+	risk, ok = enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionCodeLogin, EmailHash: "hash-sample-x"})
+	require.True(t, ok)
+	recordLoginFailure(c, risk)
+	assert.Equal(t, int64(1), countEvents())
+	assert.Equal(t, loginRiskOutcomeFailed, lastLoginRiskEvent(t).Outcome)
+
+	sampled = true
+	c, _ = riskTestContext(ip)
+	risk, ok = enforceLoginRisk(c, loginRiskAttempt{Action: loginRiskActionCodeLogin, EmailHash: "hash-sample-x"})
+	require.True(t, ok)
+	recordLoginSuccess(c, risk)
+	assert.Equal(t, int64(2), countEvents())
+	assert.Equal(t, loginRiskOutcomeSuccess, lastLoginRiskEvent(t).Outcome)
+}
+
+// 端到端：连续输错密码后，正确密码也被要求改用邮箱验证码；未注册邮箱同样计数
+func TestWebPasswordLogin_RiskRequiresEmailCode(t *testing.T) {
+	skipIfNoConfig(t)
+	resetLoginRiskKeys(t)
+
+	const password = "k7N#mq2P!xT9"
+	_, email := seedWebPasswordLoginUser(t, password)
+
+	r := SetupMinimalRouter()
+	r.POST("/api/auth/web-login/password", api_web_password_login)
+	login := func(email, password string) ErrorCode {
+		body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/web-login/password", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "198.51.100.100:40000"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return responseCode(t, w)
+	}
+
+	assert.Equal(t, ErrorInvalidCredentials, login("nobody-risk@example.com", "whatever"))
+	assert.Equal(t, loginRiskOutcomeFailed, lastLoginRiskEvent(t).Outcome)
+	assert.Equal(t, uint64(0), lastLoginRiskEvent(t).UserID)
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, ErrorInvalidCredentials, login(email, "wrong-password"))
+	}
+	assert.Equal(t, ErrorEmailCodeRequired, login(email, password))
+	ev := lastLoginRiskEvent(t)
+	assert.Equal(t, loginRiskDecisionEmailCode, ev.Decision)
+	assert.NotZero(t, ev.UserID)
+}
+
+func TestAdminLoginRiskEvents_FilterAndUnblock(t *testing.T) {
+	skipIfNoConfig(t)
+	resetLoginRiskKeys(t)
+
+	user := CreateTestUser(t)
+	c, _ := riskTestContext("198.51.100.110")
+	emailHash := secretHashIt(c, []byte("risk-admin@example.com"))
+	events := []LoginRiskEvent{
+		{Action: loginRiskActionPasswordLogin, EmailHash: emailHash, UserID: user.ID, IP: "198.51.100.110", Score: 60, Signals: "email_fail=5,ip_fail=5", Decision: loginRiskDecisionEmailCode, Outcome: loginRiskOutcomeRejected},
+		{Action: loginRiskActionSendCode, EmailHash: "other", IP: "198.51.100.111", Decision: loginRiskDecisionBlock, Outcome: loginRiskOutcomeRejected},
+	}
+	require.NoError(t, db.Get().Create(&events).Error)
+	t.Cleanup(func() { db.Get().Delete(&events) })
+
+	r := SetupMinimalRouter()
+	r.GET("/app/login-risk/events", api_admin_list_login_risk_events)
+	r.POST("/app/login-risk/unblock", func(c *gin.Context) {
+		c.Set("authContext", &authContext{UserID: 1, User: &User{ID: 1, UUID: "admin-uuid"}})
+		api_admin_login_risk_unblock(c)
+	})
+
+	w := NewTestRequest(http.MethodGet, "/app/login-risk/events?email=Risk-Admin@example.com").Execute(r)
+	page, err := ParseResponseData[ListResult[AdminLoginRiskEventItem]](w)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	item := page.Items[0]
+	assert.Equal(t, loginRiskDecisionEmailCode, item.Decision)
+	assert.Equal(t, []string{"email_fail=5", "ip_fail=5"}, item.Signals)
+	require.NotNil(t, item.User)
+	assert.Equal(t, user.UUID, item.User.UUID)
+
+	w = NewTestRequest(http.MethodGet, "/app/login-risk/events?decision=block&ip=198.51.100.111").Execute(r)
+	page, err = ParseResponseData[ListResult[AdminLoginRiskEventItem]](w)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Nil(t, page.Items[0].User)
+	assert.Empty(t, page.Items[0].Signals)
+
+	testMiniRedis.Set(loginRiskBlockKey("198.51.100.111"), "100")
+	w = NewTestRequest(http.MethodPost, "/app/login-risk/unblock").WithBody(map[string]string{"ip": "198.51.100.111"}).Execute(r)
+	assert.Equal(t, ErrorCode(0), responseCode(t, w))
+	assert.False(t, testMiniRedis.Exists(loginRiskBlockKey("198.51.100.111")))
+
+	w = NewTestRequest(http.MethodPost, "/app/login-risk/unblock").WithBody(map[string]string{"ip": "bogus"}).Execute(r)
+	assert.Equal(t, ErrorInvalidArgument, responseCode(t, w))
+}
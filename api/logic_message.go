@@ -41,7 +41,16 @@ func CreateEmailMessage(ctx context.Context, userID uint64, msgType, title, cont
 		Metadata: string(metadataJSON),
 	}
 
-	return db.Get().Create(&message).Error
+	if err := db.Get().Create(&message).Error; err != nil {
+		return err
+	}
+	recordInbox(ctx, userID, messageCategories[msgType], NotifyChannelEmail, title, content, nil)
+	return nil
+}
+
+// messageCategories 消息类型对应的通知类别（未列出的为 service）
+var messageCategories = map[string]NotifyCategory{
+	MessageTypeDeviceKick: NotifyCategorySecurity,
 }
 
 // CreateDeviceKickEmail 创建设备踢除邮件消息
@@ -0,0 +1,141 @@
+package center
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"gorm.io/gorm/clause"
+)
+
+// =====================================================================
+// 通知偏好与收件箱
+// =====================================================================
+//
+// - 偏好按 (用户, 类别) 控制推送和邮件两个渠道；安全类不可关闭
+// - 收件箱记录每次推送与邮件（不受偏好影响，关闭推送的营销消息仍可在收件箱看到），
+//   每个用户只保留最近 inboxKeepPerUser 条
+//
+// =====================================================================
+
+// inboxKeepPerUser 每个用户收件箱保留的条数
+const inboxKeepPerUser = 100
+
+// inboxBodyMaxRunes 邮件正文摘要长度
+const inboxBodyMaxRunes = 500
+
+// notificationAllowed 用户是否接收该类别在该渠道的通知。
+// 查询失败时放行：漏发安全/账单通知比多发一条营销更糟。
+func notificationAllowed(ctx context.Context, userID uint64, category NotifyCategory, channel NotifyChannel) bool {
+	category = category.orDefault()
+	if category.Mandatory() || userID == 0 {
+		return true
+	}
+	var prefs []NotificationPreference
+	if err := db.Get().Where("user_id = ? AND category = ?", userID, category).Limit(1).Find(&prefs).Error; err != nil {
+		log.Warnf(ctx, "[NOTIFY] Failed to load preference user=%d category=%s: %v", userID, category, err)
+		return true
+	}
+	if len(prefs) == 0 {
+		return true
+	}
+	switch channel {
+	case NotifyChannelPush:
+		return prefs[0].Push
+	case NotifyChannelEmail:
+		return prefs[0].Email
+	}
+	return true
+}
+
+// getNotificationPreferences 返回用户全部类别的偏好（未设置的按全开）
+func getNotificationPreferences(userID uint64) ([]NotificationPreference, error) {
+	var stored []NotificationPreference
+	if err := db.Get().Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	byCategory := make(map[NotifyCategory]NotificationPreference, len(stored))
+	for _, p := range stored {
+		byCategory[p.Category] = p
+	}
+	out := make([]NotificationPreference, 0, len(NotifyCategories))
+	for _, c := range NotifyCategories {
+		p, ok := byCategory[c]
+		if !ok || c.Mandatory() {
+			p = NotificationPreference{Category: c, Push: true, Email: true, UpdatedAt: p.UpdatedAt}
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// setNotificationPreference 保存用户在某类别上的渠道开关
+func setNotificationPreference(userID uint64, category NotifyCategory, push, email bool) error {
+	p := NotificationPreference{UserID: userID, Category: category, Push: push, Email: email}
+	return db.Get().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"push", "email", "updated_at"}),
+	}).Create(&p).Error
+}
+
+// recordInbox 写入收件箱并裁剪到最近 inboxKeepPerUser 条。
+// 收件箱是辅助记录，失败只记日志，不影响通知本身。
+func recordInbox(ctx context.Context, userID uint64, category NotifyCategory, channel NotifyChannel, title, body string, data map[string]interface{}) {
+	if userID == 0 {
+		return
+	}
+	entry := InboxNotification{
+		UserID:   userID,
+		Category: category.orDefault(),
+		Channel:  channel,
+		Title:    truncateRunes(title, 255),
+		Body:     body,
+	}
+	if len(data) > 0 {
+		if raw, err := json.Marshal(data); err == nil {
+			entry.Data = string(raw)
+		}
+	}
+	if err := db.Get().Create(&entry).Error; err != nil {
+		log.Warnf(ctx, "[NOTIFY] Failed to record inbox entry for user %d: %v", userID, err)
+		return
+	}
+
+	// 第 N+1 新的条目及更早的全部删除
+	var cutoff []uint64
+	if err := db.Get().Model(&InboxNotification{}).Where("user_id = ?", userID).
+		Order("id DESC").Offset(inboxKeepPerUser).Limit(1).Pluck("id", &cutoff).Error; err != nil {
+		log.Warnf(ctx, "[NOTIFY] Failed to trim inbox for user %d: %v", userID, err)
+		return
+	}
+	if len(cutoff) > 0 {
+		db.Get().Where("user_id = ? AND id <= ?", userID, cutoff[0]).Delete(&InboxNotification{})
+	}
+}
+
+var (
+	htmlTagRegex    = regexp.MustCompile(`(?s)<(style|script)[^>]*>.*?</(style|script)>|<[^>]+>`)
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+)
+
+// inboxTextFromHTML 把邮件 HTML 转为收件箱用的纯文本摘要
+func inboxTextFromHTML(html string) string {
+	text := htmlTagRegex.ReplaceAllString(html, " ")
+	for _, entity := range [][2]string{{"&nbsp;", " "}, {"&lt;", "<"}, {"&gt;", ">"}, {"&quot;", `"`}, {"&#39;", "'"}, {"&amp;", "&"}} {
+		text = strings.ReplaceAll(text, entity[0], entity[1])
+	}
+	text = strings.TrimSpace(whitespaceRegex.ReplaceAllString(text, " "))
+	return truncateRunes(text, inboxBodyMaxRunes)
+}
+
+// truncateRunes 按字符截断，超长时以省略号结尾
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,721 @@
+package center
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"github.com/wordgate/qtoolkit/redis"
+	"gorm.io/gorm"
+)
+
+// =====================================================================
+// 第三方登录：Google、Sign in with Apple（OIDC），GitHub（OAuth 2.0）
+// =====================================================================
+//
+// 授权码流程在服务端完成，浏览器只经手 state 和一次性 ticket：
+//   1. start：生成 state / nonce / PKCE verifier 存 Redis，返回 provider 授权地址
+//   2. callback：provider 回跳（Apple 为 form_post），用授权码换 token；OIDC 校验
+//      id_token（JWKS 签名、iss、aud、nonce），GitHub 调 API 取用户与已验证邮箱。
+//      已验证的身份存为一次性 ticket，303 回官网 /login/oauth
+//   3. 官网页带 ticket + state 调 JSON 接口完成登录（含两步验证）或绑定。
+//      state 只保存在发起授权的浏览器里，挡住把攻击者的 ticket 塞给受害者的 login CSRF
+//
+// 身份记为 LoginIdentify{Type: provider, IndexID: hash(sub)}，与邮箱身份一样按品牌
+// 隔离。首次登录按 provider 已验证的邮箱关联同品牌既有账号；没有则新建账号，并同时
+// 建邮箱身份，通知邮件、验证码登录等依赖邮箱的流程照旧可用。
+
+const (
+	oauthProviderGoogle = "google"
+	oauthProviderApple  = "apple"
+	oauthProviderGitHub = "github"
+
+	oauthStatePrefix  = "oauth:state:"
+	oauthTicketPrefix = "oauth:ticket:"
+	oauthStateTTL     = 600 // 秒：在 provider 页面完成授权的时限
+	oauthTicketTTL    = 300 // 秒：回跳后完成登录（含输入两步验证码）的时限
+
+	oauthIntentLogin = "login"
+	oauthIntentLink  = "link"
+
+	oidcMetadataTTL    = time.Hour
+	oidcJWKSRefetchGap = time.Minute // kid 未命中时重新拉取 JWKS 的最小间隔
+)
+
+// oauthProviderNames 支持的 provider，按登录页展示顺序
+var oauthProviderNames = []string{oauthProviderGoogle, oauthProviderApple, oauthProviderGitHub}
+
+// provider 端点，测试替换为本地替身 issuer
+var (
+	oauthIssuers = map[string]string{
+		oauthProviderGoogle: "https://accounts.google.com",
+		oauthProviderApple:  "https://appleid.apple.com",
+	}
+	githubOAuthURL = "https://github.com"
+	githubAPIURL   = "https://api.github.com"
+)
+
+var oauthHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+var errOAuthInvalid = errors.New("invalid oauth response")
+
+// isOAuthProvider provider 名是否受支持
+func isOAuthProvider(provider string) bool {
+	for _, p := range oauthProviderNames {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// enabledOAuthProviders 品牌下凭证齐全的 provider
+func enabledOAuthProviders(brand Brand) []string {
+	providers := []string{}
+	for _, p := range oauthProviderNames {
+		if configOAuthProvider(p, brand).Enabled(p) {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// oauthIdentity provider 断言的身份
+type oauthIdentity struct {
+	Provider      string `json:"provider"`
+	Subject       string `json:"subject"`
+	Email         string `json:"email,omitempty"` // 已规范化为小写
+	EmailVerified bool   `json:"emailVerified"`
+}
+
+// oauthState 一次授权的服务端状态
+type oauthState struct {
+	Provider     string `json:"provider"`
+	Brand        Brand  `json:"brand"`
+	Intent       string `json:"intent"`
+	UserID       uint64 `json:"userId,omitempty"`       // 仅绑定
+	Nonce        string `json:"nonce,omitempty"`        // 仅 OIDC
+	CodeVerifier string `json:"codeVerifier,omitempty"` // provider 不支持 PKCE 时为空
+	RedirectURI  string `json:"redirectUri"`
+}
+
+// oauthTicket 回跳成功后待官网页兑换的身份
+type oauthTicket struct {
+	State    string        `json:"state"`
+	Brand    Brand         `json:"brand"`
+	Intent   string        `json:"intent"`
+	UserID   uint64        `json:"userId,omitempty"`
+	Identity oauthIdentity `json:"identity"`
+}
+
+// oauthRandom n 字节随机数的 base64url
+func oauthRandom(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand unavailable, refusing to issue oauth state: %v", err))
+	}
+	return webauthnB64.EncodeToString(b)
+}
+
+func oauthCachePut(key string, v any, ttl int) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return redis.CacheSet(key, string(raw), ttl)
+}
+
+func oauthCacheGet(key string, v any) bool {
+	var raw string
+	exist, err := redis.CacheGet(key, &raw)
+	if err != nil || !exist {
+		return false
+	}
+	return json.Unmarshal([]byte(raw), v) == nil
+}
+
+// takeOAuthState 读取并删除 state；未知或过期返回 nil
+func takeOAuthState(state string) *oauthState {
+	if state == "" {
+		return nil
+	}
+	var st oauthState
+	if !oauthCacheGet(oauthStatePrefix+state, &st) {
+		return nil
+	}
+	_ = redis.CacheDel(oauthStatePrefix + state)
+	return &st
+}
+
+// issueOAuthTicket 保存已验证身份，返回 ticket
+func issueOAuthTicket(t oauthTicket) (string, error) {
+	ticket := oauthRandom(32)
+	if err := oauthCachePut(oauthTicketPrefix+ticket, t, oauthTicketTTL); err != nil {
+		return "", fmt.Errorf("store oauth ticket: %w", err)
+	}
+	return ticket, nil
+}
+
+// peekOAuthTicket 读取 ticket 并校验发起授权的浏览器持有对应 state。
+// 不删除：两步验证码输错后可带同一 ticket 重试，成功后由 dropOAuthTicket 作废。
+func peekOAuthTicket(ticket, state string) *oauthTicket {
+	if ticket == "" || state == "" {
+		return nil
+	}
+	var t oauthTicket
+	if !oauthCacheGet(oauthTicketPrefix+ticket, &t) {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(t.State), []byte(state)) != 1 {
+		return nil
+	}
+	return &t
+}
+
+func dropOAuthTicket(ticket string) {
+	_ = redis.CacheDel(oauthTicketPrefix + ticket)
+}
+
+// oauthRedirectURI provider 回调地址，经官网 /api 反代到 Center。
+// 需在各 provider 控制台按品牌登记。
+func oauthRedirectURI(brand Brand, provider string) string {
+	return strings.TrimRight(brand.Config().BaseURL, "/") + "/api/auth/oauth/" + provider + "/callback"
+}
+
+// beginOAuth 生成 state 并返回 provider 授权地址
+func beginOAuth(ctx context.Context, provider string, brand Brand, intent string, userID uint64) (authorizeURL, state string, err error) {
+	cfg := configOAuthProvider(provider, brand)
+	st := oauthState{
+		Provider:     provider,
+		Brand:        brand,
+		Intent:       intent,
+		UserID:       userID,
+		CodeVerifier: oauthRandom(32),
+		RedirectURI:  oauthRedirectURI(brand, provider),
+	}
+	state = oauthRandom(24)
+
+	q := url.Values{}
+	q.Set("client_id", cfg.ClientID)
+	q.Set("redirect_uri", st.RedirectURI)
+	q.Set("response_type", "code")
+	q.Set("state", state)
+
+	var endpoint string
+	pkce := true
+	if provider == oauthProviderGitHub {
+		endpoint = githubOAuthURL + "/login/oauth/authorize"
+		q.Set("scope", "read:user user:email")
+	} else {
+		meta, err := oidcMetadata(ctx, oauthIssuers[provider])
+		if err != nil {
+			return "", "", err
+		}
+		endpoint = meta.AuthorizationEndpoint
+		st.Nonce = oauthRandom(16)
+		q.Set("scope", "openid email")
+		q.Set("nonce", st.Nonce)
+		switch provider {
+		case oauthProviderApple:
+			// 请求 email scope 时 Apple 只接受 form_post 回跳
+			q.Set("response_mode", "form_post")
+		case oauthProviderGoogle:
+			q.Set("prompt", "select_account")
+		}
+		pkce = meta.supportsS256()
+	}
+	if pkce {
+		sum := sha256.Sum256([]byte(st.CodeVerifier))
+		q.Set("code_challenge", webauthnB64.EncodeToString(sum[:]))
+		q.Set("code_challenge_method", "S256")
+	} else {
+		st.CodeVerifier = ""
+	}
+
+	if err := oauthCachePut(oauthStatePrefix+state, st, oauthStateTTL); err != nil {
+		return "", "", fmt.Errorf("store oauth state: %w", err)
+	}
+	return endpoint + "?" + q.Encode(), state, nil
+}
+
+// finishOAuth 消费 state，用授权码换取并校验身份。state 无效时返回 nil state。
+func finishOAuth(ctx context.Context, provider, state, code string) (*oauthState, *oauthIdentity, error) {
+	st := takeOAuthState(state)
+	if st == nil {
+		return nil, nil, fmt.Errorf("%w: unknown or expired state", errOAuthInvalid)
+	}
+	if st.Provider != provider {
+		return st, nil, fmt.Errorf("%w: state issued for %s", errOAuthInvalid, st.Provider)
+	}
+	if code == "" {
+		return st, nil, fmt.Errorf("%w: missing code", errOAuthInvalid)
+	}
+
+	cfg := configOAuthProvider(provider, st.Brand)
+	secret, err := oauthClientSecret(provider, cfg)
+	if err != nil {
+		return st, nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", st.RedirectURI)
+	form.Set("client_id", cfg.ClientID)
+	form.Set("client_secret", secret)
+	if st.CodeVerifier != "" {
+		form.Set("code_verifier", st.CodeVerifier)
+	}
+
+	if provider == oauthProviderGitHub {
+		tok, err := oauthExchangeCode(ctx, githubOAuthURL+"/login/oauth/access_token", form)
+		if err != nil {
+			return st, nil, err
+		}
+		id, err := githubIdentity(ctx, tok.AccessToken)
+		return st, id, err
+	}
+
+	meta, err := oidcMetadata(ctx, oauthIssuers[provider])
+	if err != nil {
+		return st, nil, err
+	}
+	tok, err := oauthExchangeCode(ctx, meta.TokenEndpoint, form)
+	if err != nil {
+		return st, nil, err
+	}
+	claims, err := verifyIDToken(ctx, provider, meta, cfg.ClientID, st.Nonce, tok.IDToken)
+	if err != nil {
+		return st, nil, err
+	}
+	return st, &oauthIdentity{
+		Provider:      provider,
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(strings.TrimSpace(claims.Email)),
+		EmailVerified: claims.emailVerified(),
+	}, nil
+}
+
+// oauthClientSecret Apple 现签 client secret JWT，其余直接取配置
+func oauthClientSecret(provider string, cfg OAuthProviderConfig) (string, error) {
+	if provider != oauthProviderApple {
+		return cfg.ClientSecret, nil
+	}
+	// 与 APNs 相同格式的 .p8（PKCS#8 EC）私钥
+	key, err := parseAPNsKey(cfg.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("sign in with apple key: %w", err)
+	}
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": cfg.TeamID,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+		"aud": oauthIssuers[oauthProviderApple],
+		"sub": cfg.ClientID,
+	})
+	t.Header["kid"] = cfg.KeyID
+	signed, err := t.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("sign apple client secret: %w", err)
+	}
+	return signed, nil
+}
+
+// oauthTokenResponse token 端点响应（GitHub 出错时也是 200 + error 字段）
+type oauthTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// oauthExchangeCode 用授权码换 token
+func oauthExchangeCode(ctx context.Context, tokenURL string, form url.Values) (*oauthTokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth token exchange: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var tok oauthTokenResponse
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return nil, fmt.Errorf("oauth token exchange: HTTP %d: %s", resp.StatusCode, truncateString(string(body), 200))
+	}
+	if resp.StatusCode != http.StatusOK || tok.Error != "" {
+		return nil, fmt.Errorf("%w: token exchange: HTTP %d: %s %s", errOAuthInvalid, resp.StatusCode, tok.Error, tok.ErrorDescription)
+	}
+	if tok.AccessToken == "" && tok.IDToken == "" {
+		return nil, fmt.Errorf("%w: token exchange returned no token", errOAuthInvalid)
+	}
+	return &tok, nil
+}
+
+// oauthGetJSON GET 并解析 JSON
+func oauthGetJSON(ctx context.Context, u string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		for _, hv := range vs {
+			req.Header.Add(k, hv)
+		}
+	}
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: HTTP %d: %s", u, resp.StatusCode, body)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// ===================== GitHub =====================
+
+// githubIdentity 取 GitHub 用户 ID 与主邮箱（/user 的 email 可能为空或未验证）
+func githubIdentity(ctx context.Context, accessToken string) (*oauthIdentity, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+accessToken)
+	header.Set("Accept", "application/vnd.github+json")
+	header.Set("User-Agent", "kaitu-center")
+
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := oauthGetJSON(ctx, githubAPIURL+"/user", header, &user); err != nil {
+		return nil, fmt.Errorf("github user: %w", err)
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("%w: github user without id", errOAuthInvalid)
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := oauthGetJSON(ctx, githubAPIURL+"/user/emails", header, &emails); err != nil {
+		return nil, fmt.Errorf("github emails: %w", err)
+	}
+	id := &oauthIdentity{Provider: oauthProviderGitHub, Subject: strconv.FormatInt(user.ID, 10)}
+	for _, e := range emails {
+		if e.Primary {
+			id.Email = strings.ToLower(strings.TrimSpace(e.Email))
+			id.EmailVerified = e.Verified
+			break
+		}
+	}
+	return id, nil
+}
+
+// ===================== OIDC discovery / JWKS =====================
+
+// oidcDiscovery /.well-known/openid-configuration 中用到的字段
+type oidcDiscovery struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+func (m *oidcDiscovery) supportsS256() bool {
+	for _, method := range m.CodeChallengeMethodsSupported {
+		if method == "S256" {
+			return true
+		}
+	}
+	return false
+}
+
+// oidcJWK JWKS 中的一把公钥
+type oidcJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// oidcCache discovery 与 JWKS 的进程内缓存，按 issuer / jwks_uri 索引
+var oidcCache = struct {
+	sync.Mutex
+	metadata map[string]oidcMetadataEntry
+	jwks     map[string]oidcJWKSEntry
+}{
+	metadata: map[string]oidcMetadataEntry{},
+	jwks:     map[string]oidcJWKSEntry{},
+}
+
+type oidcMetadataEntry struct {
+	meta      *oidcDiscovery
+	fetchedAt time.Time
+}
+
+type oidcJWKSEntry struct {
+	keys      []oidcJWK
+	fetchedAt time.Time
+}
+
+// oidcMetadata 获取 issuer 的 discovery 文档（缓存 oidcMetadataTTL）
+func oidcMetadata(ctx context.Context, issuer string) (*oidcDiscovery, error) {
+	oidcCache.Lock()
+	entry, ok := oidcCache.metadata[issuer]
+	oidcCache.Unlock()
+	if ok && time.Since(entry.fetchedAt) < oidcMetadataTTL {
+		return entry.meta, nil
+	}
+
+	var meta oidcDiscovery
+	if err := oauthGetJSON(ctx, strings.TrimRight(issuer, "/")+"/.well-known/openid-configuration", nil, &meta); err != nil {
+		return nil, fmt.Errorf("oidc discovery %s: %w", issuer, err)
+	}
+	if meta.Issuer != issuer || meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery %s: incomplete or mismatched document (issuer %q)", issuer, meta.Issuer)
+	}
+	oidcCache.Lock()
+	oidcCache.metadata[issuer] = oidcMetadataEntry{meta: &meta, fetchedAt: time.Now()}
+	oidcCache.Unlock()
+	return &meta, nil
+}
+
+// oidcKey 按 kid 取签名公钥。未命中时重新拉取 JWKS（provider 轮换密钥），
+// 但至多每 oidcJWKSRefetchGap 一次，避免伪造 kid 放大请求。
+func oidcKey(ctx context.Context, jwksURI, kid string) (crypto.PublicKey, error) {
+	oidcCache.Lock()
+	entry, cached := oidcCache.jwks[jwksURI]
+	oidcCache.Unlock()
+
+	if cached {
+		if k := findJWK(entry.keys, kid); k != nil {
+			return k.publicKey()
+		}
+		if time.Since(entry.fetchedAt) < oidcJWKSRefetchGap {
+			return nil, fmt.Errorf("%w: unknown signing key %q", errOAuthInvalid, kid)
+		}
+	}
+
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := oauthGetJSON(ctx, jwksURI, nil, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	oidcCache.Lock()
+	oidcCache.jwks[jwksURI] = oidcJWKSEntry{keys: set.Keys, fetchedAt: time.Now()}
+	oidcCache.Unlock()
+
+	if k := findJWK(set.Keys, kid); k != nil {
+		return k.publicKey()
+	}
+	return nil, fmt.Errorf("%w: unknown signing key %q", errOAuthInvalid, kid)
+}
+
+// findJWK 按 kid 查找；token 不带 kid 时仅在 JWKS 只有一把钥匙时采用
+func findJWK(keys []oidcJWK, kid string) *oidcJWK {
+	if kid == "" {
+		if len(keys) == 1 {
+			return &keys[0]
+		}
+		return nil
+	}
+	for i := range keys {
+		if keys[i].Kid == kid {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+// publicKey 解析 RSA 或 P-256 公钥
+func (k *oidcJWK) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := webauthnDecode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: n: %w", k.Kid, err)
+		}
+		e, err := webauthnDecode(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("jwk %s: invalid exponent", k.Kid)
+		}
+		exp := 0
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("jwk %s: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, errX := webauthnDecode(k.X)
+		y, errY := webauthnDecode(k.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("jwk %s: invalid coordinates", k.Kid)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("jwk %s: unsupported key type %q", k.Kid, k.Kty)
+}
+
+// oidcIDTokenClaims id_token 中用到的字段
+type oidcIDTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"` // Google 为 bool，Apple 为 "true" / "false"
+}
+
+func (c *oidcIDTokenClaims) emailVerified() bool {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// verifyIDToken 校验 id_token 签名、iss、aud、exp 与 nonce
+func verifyIDToken(ctx context.Context, provider string, meta *oidcDiscovery, clientID, nonce, raw string) (*oidcIDTokenClaims, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("%w: token response without id_token", errOAuthInvalid)
+	}
+	claims := &oidcIDTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return oidcKey(ctx, meta.JWKSURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		if errors.Is(err, errOAuthInvalid) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: id_token: %v", errOAuthInvalid, err)
+	}
+	// Google 历史上也会签发不带 scheme 的 iss
+	if claims.Issuer != meta.Issuer && !(provider == oauthProviderGoogle && "https://"+claims.Issuer == meta.Issuer) {
+		return nil, fmt.Errorf("%w: id_token issuer %q", errOAuthInvalid, claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: id_token without sub", errOAuthInvalid)
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: id_token nonce mismatch", errOAuthInvalid)
+	}
+	return claims, nil
+}
+
+// ===================== 身份 ↔ 账号 =====================
+
+// oauthIndexID 身份索引：provider 已由 LoginIdentify.Type 区分，这里只哈希 sub
+func oauthIndexID(ctx context.Context, id *oauthIdentity) string {
+	return secretHashIt(ctx, []byte(id.Subject))
+}
+
+// findOAuthIdentify 查同品牌下已绑定的第三方身份；未绑定返回 nil
+func findOAuthIdentify(ctx context.Context, brand Brand, id *oauthIdentity) (*LoginIdentify, error) {
+	var identify LoginIdentify
+	err := db.Get().Where(&LoginIdentify{Type: id.Provider, IndexID: oauthIndexID(ctx, id), Brand: string(brand)}).
+		First(&identify).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identify, nil
+}
+
+// resolveOAuthUser 找到身份对应的同品牌账号：已绑定的身份优先，其次按 provider
+// 已验证的邮箱关联。都没有返回 nil（由调用方决定是否新建）；linked 表示身份已绑定。
+func resolveOAuthUser(ctx context.Context, brand Brand, id *oauthIdentity) (user *User, linked bool, err error) {
+	identify, err := findOAuthIdentify(ctx, brand, id)
+	if err != nil {
+		return nil, false, err
+	}
+	var userID uint64
+	if identify != nil {
+		userID = identify.UserID
+		linked = true
+	} else {
+		if !id.EmailVerified || id.Email == "" {
+			return nil, false, nil
+		}
+		var emailIdentify LoginIdentify
+		err := db.Get().Where(&LoginIdentify{Type: "email", IndexID: secretHashIt(ctx, []byte(id.Email)), Brand: string(brand)}).
+			First(&emailIdentify).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		userID = emailIdentify.UserID
+	}
+
+	var u User
+	if err := db.Get().First(&u, userID).Error; err != nil {
+		return nil, false, fmt.Errorf("load user %d: %w", userID, err)
+	}
+	return &u, linked, nil
+}
+
+// createOAuthIdentify 为账号绑定第三方身份。EncryptedValue 存 provider 侧邮箱
+// （没有时存 sub），供账号页和后台展示。
+func createOAuthIdentify(ctx context.Context, tx *gorm.DB, userID uint64, brand Brand, id *oauthIdentity) error {
+	display := id.Email
+	if display == "" {
+		display = id.Subject
+	}
+	enc, err := secretEncryptString(ctx, display)
+	if err != nil {
+		return fmt.Errorf("encrypt oauth identity: %w", err)
+	}
+	if err := tx.Create(&LoginIdentify{
+		UserID:         userID,
+		Type:           id.Provider,
+		IndexID:        oauthIndexID(ctx, id),
+		EncryptedValue: enc,
+		Brand:          string(brand),
+	}).Error; err != nil {
+		return err
+	}
+	log.Infof(ctx, "linked %s identity to user %d", id.Provider, userID)
+	return nil
+}
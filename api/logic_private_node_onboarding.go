@@ -74,7 +74,8 @@ func onPrivateNodeOrderOnboarding(ctx context.Context, subID uint64) {
 	const welcomeSlug = "private-node-welcome"
 	if email != "" && templateSlugExists(welcomeSlug) {
 		if _, err := EnqueueTemplatedEmailTask(ctx, &SendEmailsRequest{
-			BatchID: fmt.Sprintf("pn-welcome-%d", sub.ID),
+			BatchID:  fmt.Sprintf("pn-welcome-%d", sub.ID),
+			Category: NotifyCategoryService,
 			Items: []SendEmailItem{{
 				Email: email, UserID: sub.UserID, Slug: welcomeSlug,
 				Vars: map[string]string{"region": sub.Region},
@@ -2,7 +2,13 @@ package center
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
 
 	db "github.com/wordgate/qtoolkit/db"
 	"github.com/wordgate/qtoolkit/log"
@@ -15,7 +21,8 @@ import (
 // 设计原则：推送的目的是到达用户，而不是记录日志
 // - PushToUser: 向用户的所有活跃设备发送推送
 // - 使用 asynq 异步队列确保推送可靠到达
-// - 不记录推送日志到数据库，仅保留运行时日志
+// - 不记录推送日志到数据库，仅保留运行时日志；用户可见的副本写入收件箱
+// - 遵循用户的通知偏好（按类别关闭推送）
 //
 // =====================================================================
 
@@ -27,11 +34,19 @@ type PushNotification struct {
 	Sound    string                 `json:"sound,omitempty"`    // 提示音，默认 "default"
 	Badge    *int                   `json:"badge,omitempty"`    // 角标数字（iOS）
 	ImageURL string                 `json:"imageUrl,omitempty"` // 图片 URL（富媒体推送）
+	Category NotifyCategory         `json:"category,omitempty"` // 通知类别，决定用户能否关闭；空为 service
 }
 
 // PushToUser 向用户的所有活跃设备发送推送
 // 这是推送的主入口，以用户为最小粒度
 func PushToUser(ctx context.Context, userID uint64, notification PushNotification) error {
+	// 先进收件箱：设备离线、令牌失效或用户关闭了推送，消息都不会丢
+	recordInbox(ctx, userID, notification.Category, NotifyChannelPush, notification.Title, notification.Body, notification.Data)
+	if !notificationAllowed(ctx, userID, notification.Category, NotifyChannelPush) {
+		log.Infof(ctx, "[PUSH] User %d muted %s push, skipping", userID, notification.Category.orDefault())
+		return nil
+	}
+
 	// 查找用户的所有活跃推送令牌
 	var tokens []PushToken
 	if err := db.Get().Where("user_id = ? AND status = ?", userID, PushTokenStatusActive).Find(&tokens).Error; err != nil {
@@ -75,46 +90,128 @@ func PushToUsers(ctx context.Context, userIDs []uint64, notification PushNotific
 	return nil
 }
 
+// errPushTokenInvalid 推送通道明确返回令牌失效（已卸载、令牌格式错误）。
+// 令牌不属于所配置的 topic / 项目属于我方配置问题，不算失效。
+// 命中时令牌被标记为 inactive，不再参与后续推送。
+var errPushTokenInvalid = errors.New("push token invalid")
+
+// errPushNotConfigured 推送通道未配置凭证
+var errPushNotConfigured = errors.New("push provider not configured")
+
+// pushHTTPClient 调用推送通道的 HTTP 客户端。APNs 要求 HTTP/2，
+// 标准库 Transport 在 TLS 上自动协商 h2。测试替换为本地替身服务器的客户端。
+var pushHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
 // sendPushToToken 发送推送到单个令牌（内部函数）
-// 这是实际调用推送提供商 SDK 的地方
+// 按 provider 分发，记录投递指标，并清理通道判定失效的令牌
 func sendPushToToken(ctx context.Context, token *PushToken, notification *PushNotification) error {
+	var send func(context.Context, *PushToken, *PushNotification) error
 	switch token.Provider {
 	case PushProviderAPNs:
-		return sendAPNsPush(ctx, token, notification)
+		send = sendAPNsPush
 	case PushProviderFCM:
-		return sendFCMPush(ctx, token, notification)
+		send = sendFCMPush
 	case PushProviderJPush:
-		return sendJPushPush(ctx, token, notification)
+		send = sendJPushPush
 	default:
 		return fmt.Errorf("unsupported push provider: %s", token.Provider)
 	}
+
+	start := time.Now()
+	err := send(ctx, token, notification)
+	recordPushResult(token.Provider, time.Since(start), err)
+
+	if errors.Is(err, errPushTokenInvalid) {
+		if dbErr := db.Get().Model(&PushToken{}).Where("id = ?", token.ID).
+			Update("status", PushTokenStatusInactive).Error; dbErr != nil {
+			log.Errorf(ctx, "[PUSH] Failed to deactivate token id=%d: %v", token.ID, dbErr)
+		} else {
+			log.Infof(ctx, "[PUSH] Deactivated invalid %s token id=%d device=%s", token.Provider, token.ID, token.DeviceUDID)
+		}
+	}
+	return err
 }
 
-// sendAPNsPush 发送 APNs 推送
-// TODO: 实现 APNs 推送
-func sendAPNsPush(ctx context.Context, token *PushToken, notification *PushNotification) error {
-	log.Infof(ctx, "[PUSH:APNs] Sending to device=%s, title=%s", token.DeviceUDID, notification.Title)
-	// TODO: 集成 APNs SDK
-	// 使用 token.Token 作为设备令牌
-	// 使用 token.Topic 作为 APNs topic (Bundle ID)
-	// 使用 token.Sandbox 判断是否为沙盒环境
-	return nil
+// pushDataStrings 将自定义数据转为字符串键值（FCM data 只接受字符串）
+func pushDataStrings(data map[string]interface{}) map[string]string {
+	if len(data) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(data))
+	for k, v := range data {
+		switch val := v.(type) {
+		case string:
+			out[k] = val
+		default:
+			raw, _ := json.Marshal(val)
+			out[k] = string(raw)
+		}
+	}
+	return out
 }
 
-// sendFCMPush 发送 FCM 推送
-// TODO: 实现 FCM 推送
-func sendFCMPush(ctx context.Context, token *PushToken, notification *PushNotification) error {
-	log.Infof(ctx, "[PUSH:FCM] Sending to device=%s, title=%s", token.DeviceUDID, notification.Title)
-	// TODO: 集成 FCM SDK
-	// 使用 token.Token 作为 FCM 注册令牌
-	return nil
+// =====================================================================
+// 投递指标（进程内累计，按 provider）
+// =====================================================================
+
+type pushCounters struct {
+	sent          atomic.Int64 // 通道已接受
+	failed        atomic.Int64 // 发送失败（含令牌失效、未配置）
+	invalidTokens atomic.Int64 // 令牌失效并被停用
+	notConfigured atomic.Int64 // 通道未配置
+	latencyMs     atomic.Int64 // 累计耗时
 }
 
-// sendJPushPush 发送极光推送
-// TODO: 实现极光推送
-func sendJPushPush(ctx context.Context, token *PushToken, notification *PushNotification) error {
-	log.Infof(ctx, "[PUSH:JPush] Sending to device=%s, title=%s", token.DeviceUDID, notification.Title)
-	// TODO: 集成极光推送 SDK
-	// 使用 token.Token 作为极光推送注册 ID
-	return nil
+// PushProviderMetrics 单个推送通道的指标快照，自进程启动累计
+type PushProviderMetrics struct {
+	Provider      PushProvider `json:"provider"`
+	Sent          int64        `json:"sent"`
+	Failed        int64        `json:"failed"`
+	InvalidTokens int64        `json:"invalid_tokens"`
+	NotConfigured int64        `json:"not_configured"`
+	AvgLatencyMs  int64        `json:"avg_latency_ms"`
+}
+
+var pushMetrics = map[PushProvider]*pushCounters{
+	PushProviderAPNs:  {},
+	PushProviderFCM:   {},
+	PushProviderJPush: {},
+}
+
+func recordPushResult(provider PushProvider, elapsed time.Duration, err error) {
+	c, ok := pushMetrics[provider]
+	if !ok {
+		return
+	}
+	c.latencyMs.Add(elapsed.Milliseconds())
+	switch {
+	case err == nil:
+		c.sent.Add(1)
+		return
+	case errors.Is(err, errPushTokenInvalid):
+		c.invalidTokens.Add(1)
+	case errors.Is(err, errPushNotConfigured):
+		c.notConfigured.Add(1)
+	}
+	c.failed.Add(1)
+}
+
+// PushMetricsSnapshot 返回各通道指标，按 provider 名排序
+func PushMetricsSnapshot() []PushProviderMetrics {
+	out := make([]PushProviderMetrics, 0, len(pushMetrics))
+	for provider, c := range pushMetrics {
+		m := PushProviderMetrics{
+			Provider:      provider,
+			Sent:          c.sent.Load(),
+			Failed:        c.failed.Load(),
+			InvalidTokens: c.invalidTokens.Load(),
+			NotConfigured: c.notConfigured.Load(),
+		}
+		if total := m.Sent + m.Failed; total > 0 {
+			m.AvgLatencyMs = c.latencyMs.Load() / total
+		}
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Provider < out[j].Provider })
+	return out
 }
@@ -0,0 +1,230 @@
+package center
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// =====================================================================
+// APNs - HTTP/2 provider API，token-based（.p8 + ES256 JWT）认证
+// https://developer.apple.com/documentation/usernotifications/sending-notification-requests-to-apns
+// =====================================================================
+
+// APNs 网关，测试替换为本地替身服务器
+var (
+	apnsProductionURL = "https://api.push.apple.com"
+	apnsSandboxURL    = "https://api.sandbox.push.apple.com"
+)
+
+// apnsTokenTTL provider token 的复用时长。Apple 要求 20~60 分钟内刷新，
+// 过于频繁地签发会被拒绝（TooManyProviderTokenUpdates）。
+const apnsTokenTTL = 50 * time.Minute
+
+// apnsInvalidReasons APNs 判定设备令牌本身失效的原因，命中即停用令牌
+var apnsInvalidReasons = map[string]bool{
+	"Unregistered": true, // HTTP 410：应用已卸载
+}
+
+// apnsBadDeviceToken 令牌格式错误，但令牌与网关环境（sandbox / production）不符时
+// APNs 返回的也是它。先换另一个环境重试，两边都拒绝才算令牌失效。
+const apnsBadDeviceToken = "BadDeviceToken"
+
+// apnsConfigReasons 是我方配置问题（topic / bundle ID 与令牌不符）而非令牌失效：
+// 令牌保留，修正配置后仍可投递
+var apnsConfigReasons = map[string]bool{
+	"DeviceTokenNotForTopic": true,
+}
+
+// apnsProviderToken 缓存的 provider JWT
+var apnsProviderToken struct {
+	sync.Mutex
+	keyID    string
+	token    string
+	issuedAt time.Time
+}
+
+// apnsBearer 返回可用的 provider JWT，过期或 Key ID 变化时重新签发
+func apnsBearer(cfg PushConfig) (string, error) {
+	apnsProviderToken.Lock()
+	defer apnsProviderToken.Unlock()
+	if apnsProviderToken.token != "" && apnsProviderToken.keyID == cfg.APNsKeyID &&
+		time.Since(apnsProviderToken.issuedAt) < apnsTokenTTL {
+		return apnsProviderToken.token, nil
+	}
+
+	key, err := parseAPNsKey(cfg.APNsPrivateKey)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": cfg.APNsTeamID,
+		"iat": now.Unix(),
+	})
+	t.Header["kid"] = cfg.APNsKeyID
+	signed, err := t.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("sign apns token: %w", err)
+	}
+	apnsProviderToken.keyID = cfg.APNsKeyID
+	apnsProviderToken.token = signed
+	apnsProviderToken.issuedAt = now
+	return signed, nil
+}
+
+// parseAPNsKey 解析 .p8（PKCS#8 PEM）私钥
+func parseAPNsKey(p8 string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(p8))
+	if block == nil {
+		return nil, errors.New("apns private key: no PEM block")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("apns private key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("apns private key: not an ECDSA key")
+	}
+	return ecKey, nil
+}
+
+// apnsPayload 构造 APNs 推送负载，自定义数据放在 aps 同级
+func apnsPayload(n *PushNotification) map[string]interface{} {
+	aps := map[string]interface{}{
+		"alert": map[string]string{"title": n.Title, "body": n.Body},
+	}
+	sound := n.Sound
+	if sound == "" {
+		sound = "default"
+	}
+	aps["sound"] = sound
+	if n.Badge != nil {
+		aps["badge"] = *n.Badge
+	}
+	payload := map[string]interface{}{}
+	for k, v := range n.Data {
+		payload[k] = v
+	}
+	if n.ImageURL != "" {
+		aps["mutable-content"] = 1 // 由 Notification Service Extension 下载图片
+		payload["imageUrl"] = n.ImageURL
+	}
+	payload["aps"] = aps
+	return payload
+}
+
+// sendAPNsPush 发送 APNs 推送
+// 环境由 token.Sandbox 决定，topic 取 token.Topic（缺省为配置的 Bundle ID）
+func sendAPNsPush(ctx context.Context, token *PushToken, notification *PushNotification) error {
+	cfg := configPush()
+	if cfg.APNsTeamID == "" || cfg.APNsKeyID == "" || cfg.APNsPrivateKey == "" {
+		return fmt.Errorf("apns: %w", errPushNotConfigured)
+	}
+	topic := token.Topic
+	if topic == "" {
+		topic = cfg.APNsTopic
+	}
+	if topic == "" {
+		return errors.New("apns: no topic for token")
+	}
+
+	bearer, err := apnsBearer(cfg)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(apnsPayload(notification))
+	if err != nil {
+		return fmt.Errorf("apns: marshal payload: %w", err)
+	}
+
+	sandbox := token.Sandbox != nil && *token.Sandbox
+	status, reason, err := apnsPost(ctx, apnsBaseURL(sandbox), bearer, topic, token, body)
+	if err != nil {
+		return err
+	}
+	if reason == apnsBadDeviceToken {
+		// 客户端上报的环境可能有误（如 TestFlight 包按 sandbox 注册）
+		retryStatus, retryReason, retryErr := apnsPost(ctx, apnsBaseURL(!sandbox), bearer, topic, token, body)
+		switch {
+		case retryErr != nil:
+			return retryErr
+		case retryStatus == http.StatusOK:
+			log.Warnf(ctx, "[PUSH:APNs] Token of device=%s registered as sandbox=%v but only accepted by the other environment",
+				token.DeviceUDID, sandbox)
+			return nil
+		case retryReason == apnsBadDeviceToken:
+			return fmt.Errorf("apns %d %s in both environments: %w", status, reason, errPushTokenInvalid)
+		}
+		status, reason = retryStatus, retryReason
+	}
+	if status == http.StatusOK {
+		return nil
+	}
+
+	if apnsInvalidReasons[reason] || status == http.StatusGone {
+		return fmt.Errorf("apns %d %s: %w", status, reason, errPushTokenInvalid)
+	}
+	if apnsConfigReasons[reason] {
+		log.Errorf(ctx, "[PUSH:APNs] Config error for topic %s (device=%s): %s", topic, token.DeviceUDID, reason)
+	}
+	if reason == "ExpiredProviderToken" || reason == "InvalidProviderToken" {
+		// 下次发送重新签发
+		apnsProviderToken.Lock()
+		apnsProviderToken.token = ""
+		apnsProviderToken.Unlock()
+	}
+	return fmt.Errorf("apns %d %s", status, reason)
+}
+
+// apnsBaseURL 按环境选择网关
+func apnsBaseURL(sandbox bool) string {
+	if sandbox {
+		return apnsSandboxURL
+	}
+	return apnsProductionURL
+}
+
+// apnsPost 向指定网关发送一次推送，返回 HTTP 状态码与 APNs 的 reason
+func apnsPost(ctx context.Context, base, bearer, topic string, token *PushToken, body []byte) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/3/device/"+url.PathEscape(token.Token), bytes.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("apns: %w", err)
+	}
+	req.Header.Set("authorization", "bearer "+bearer)
+	req.Header.Set("apns-topic", topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	req.Header.Set("content-type", "application/json")
+
+	resp, err := pushHTTPClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("apns: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		log.Debugf(ctx, "[PUSH:APNs] Sent to device=%s, apns-id=%s", token.DeviceUDID, resp.Header.Get("apns-id"))
+		return resp.StatusCode, "", nil
+	}
+
+	var apnsErr struct {
+		Reason string `json:"reason"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	_ = json.Unmarshal(raw, &apnsErr)
+	return resp.StatusCode, apnsErr.Reason, nil
+}
@@ -0,0 +1,215 @@
+package center
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// =====================================================================
+// FCM - HTTP v1 API，服务账号 OAuth2（JWT bearer grant）认证
+// https://firebase.google.com/docs/cloud-messaging/send-message
+// =====================================================================
+
+// fcmBaseURL FCM 网关，测试替换为本地替身服务器
+var fcmBaseURL = "https://fcm.googleapis.com"
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// fcmServiceAccount 服务账号 JSON 中用到的字段
+type fcmServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// fcmAccessToken 缓存的 OAuth2 access token
+var fcmAccessToken struct {
+	sync.Mutex
+	clientEmail string
+	token       string
+	expiresAt   time.Time
+}
+
+// fcmBearer 返回可用的 access token，提前 1 分钟刷新
+func fcmBearer(ctx context.Context, cfg PushConfig) (string, error) {
+	var sa fcmServiceAccount
+	if err := json.Unmarshal([]byte(cfg.FCMServiceAccount), &sa); err != nil {
+		return "", fmt.Errorf("fcm service account: %w", err)
+	}
+
+	fcmAccessToken.Lock()
+	defer fcmAccessToken.Unlock()
+	if fcmAccessToken.token != "" && fcmAccessToken.clientEmail == sa.ClientEmail &&
+		time.Until(fcmAccessToken.expiresAt) > time.Minute {
+		return fcmAccessToken.token, nil
+	}
+
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return "", errors.New("fcm service account: no PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("fcm service account: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("fcm service account: not an RSA key")
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   sa.ClientEmail,
+		"scope": fcmScope,
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("fcm: sign assertion: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sa.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("fcm: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := pushHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fcm: token exchange: %w", err)
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("fcm: token exchange %d: %s", resp.StatusCode, raw)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil || tok.AccessToken == "" {
+		return "", fmt.Errorf("fcm: token exchange: invalid response")
+	}
+
+	fcmAccessToken.clientEmail = sa.ClientEmail
+	fcmAccessToken.token = tok.AccessToken
+	fcmAccessToken.expiresAt = now.Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return tok.AccessToken, nil
+}
+
+// fcmMessage 构造 v1 messages:send 请求体
+func fcmMessage(registrationToken string, n *PushNotification) map[string]interface{} {
+	notification := map[string]string{"title": n.Title, "body": n.Body}
+	if n.ImageURL != "" {
+		notification["image"] = n.ImageURL
+	}
+	sound := n.Sound
+	if sound == "" {
+		sound = "default"
+	}
+	msg := map[string]interface{}{
+		"token":        registrationToken,
+		"notification": notification,
+		"android": map[string]interface{}{
+			"priority":     "high",
+			"notification": map[string]string{"sound": sound},
+		},
+	}
+	if data := pushDataStrings(n.Data); data != nil {
+		msg["data"] = data
+	}
+	return map[string]interface{}{"message": msg}
+}
+
+// fcmErrorCode 从 v1 错误体中取 FcmError.errorCode（如 UNREGISTERED）
+func fcmErrorCode(raw []byte) (status, code string) {
+	var body struct {
+		Error struct {
+			Status  string `json:"status"`
+			Details []struct {
+				Type      string `json:"@type"`
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) != nil {
+		return "", ""
+	}
+	for _, d := range body.Error.Details {
+		if strings.HasSuffix(d.Type, "google.firebase.fcm.v1.FcmError") {
+			return body.Error.Status, d.ErrorCode
+		}
+	}
+	return body.Error.Status, ""
+}
+
+// sendFCMPush 发送 FCM 推送
+func sendFCMPush(ctx context.Context, token *PushToken, notification *PushNotification) error {
+	cfg := configPush()
+	if cfg.FCMProjectID == "" || cfg.FCMServiceAccount == "" {
+		return fmt.Errorf("fcm: %w", errPushNotConfigured)
+	}
+	bearer, err := fcmBearer(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(fcmMessage(token.Token, notification))
+	if err != nil {
+		return fmt.Errorf("fcm: marshal message: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", fcmBaseURL, url.PathEscape(cfg.FCMProjectID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("fcm: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := pushHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fcm: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == http.StatusOK {
+		log.Debugf(ctx, "[PUSH:FCM] Sent to device=%s: %s", token.DeviceUDID, raw)
+		return nil
+	}
+
+	status, code := fcmErrorCode(raw)
+	// UNREGISTERED：应用已卸载或令牌过期，停用令牌
+	if code == "UNREGISTERED" {
+		return fmt.Errorf("fcm %d %s: %w", resp.StatusCode, code, errPushTokenInvalid)
+	}
+	// SENDER_ID_MISMATCH：令牌属于其他 Firebase 项目，多半是我方 project_id /
+	// 服务账号配错了，令牌本身可能完好，不停用
+	if code == "SENDER_ID_MISMATCH" {
+		log.Errorf(ctx, "[PUSH:FCM] Config error: token of device=%s belongs to another sender than the configured project", token.DeviceUDID)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		fcmAccessToken.Lock()
+		fcmAccessToken.token = ""
+		fcmAccessToken.Unlock()
+	}
+	return fmt.Errorf("fcm %d %s %s", resp.StatusCode, status, code)
+}
@@ -0,0 +1,87 @@
+package center
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// =====================================================================
+// 极光推送 - REST API v3，AppKey + Master Secret Basic 认证
+// https://docs.jiguang.cn/jpush/server/push/rest_api_v3_push
+// =====================================================================
+
+// jpushBaseURL 极光推送网关，测试替换为本地替身服务器
+var jpushBaseURL = "https://api.jpush.cn"
+
+// jpushErrNoTarget 极光返回「推送目标不存在」：registration ID 已失效
+const jpushErrNoTarget = 1011
+
+// jpushPayload 构造 v3/push 请求体，仅推送到单个 registration ID
+func jpushPayload(registrationID string, n *PushNotification) map[string]interface{} {
+	android := map[string]interface{}{
+		"alert": n.Body,
+		"title": n.Title,
+	}
+	if len(n.Data) > 0 {
+		android["extras"] = n.Data
+	}
+	if n.ImageURL != "" {
+		android["big_pic_path"] = n.ImageURL
+		android["style"] = 3 // 大图样式
+	}
+	return map[string]interface{}{
+		"platform": "android",
+		"audience": map[string][]string{"registration_id": {registrationID}},
+		"notification": map[string]interface{}{
+			"android": android,
+		},
+	}
+}
+
+// sendJPushPush 发送极光推送
+func sendJPushPush(ctx context.Context, token *PushToken, notification *PushNotification) error {
+	cfg := configPush()
+	if cfg.JPushAppKey == "" || cfg.JPushMasterSecret == "" {
+		return fmt.Errorf("jpush: %w", errPushNotConfigured)
+	}
+	body, err := json.Marshal(jpushPayload(token.Token, notification))
+	if err != nil {
+		return fmt.Errorf("jpush: marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, jpushBaseURL+"/v3/push", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("jpush: %w", err)
+	}
+	req.SetBasicAuth(cfg.JPushAppKey, cfg.JPushMasterSecret)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := pushHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("jpush: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	var result struct {
+		MsgID string `json:"msg_id"`
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(raw, &result)
+	if resp.StatusCode == http.StatusOK && result.Error.Code == 0 {
+		log.Debugf(ctx, "[PUSH:JPush] Sent to device=%s, msg_id=%s", token.DeviceUDID, result.MsgID)
+		return nil
+	}
+	if result.Error.Code == jpushErrNoTarget {
+		return fmt.Errorf("jpush %d %s: %w", result.Error.Code, result.Error.Message, errPushTokenInvalid)
+	}
+	return fmt.Errorf("jpush http %d code %d: %s", resp.StatusCode, result.Error.Code, result.Error.Message)
+}
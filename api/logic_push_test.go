@@ -0,0 +1,316 @@
+package center
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+// =====================================================================
+// Push provider clients against local stand-in servers
+// =====================================================================
+
+func pemPKCS8(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// setPushConfig sets push.* viper keys for one test.
+func setPushConfig(t *testing.T, kv map[string]string) {
+	t.Helper()
+	for k, v := range kv {
+		viper.Set(k, v)
+	}
+	t.Cleanup(func() {
+		for k := range kv {
+			viper.Set(k, "")
+		}
+	})
+}
+
+// usePushServer points pushHTTPClient at srv and restores it afterwards.
+func usePushServer(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	prev := pushHTTPClient
+	pushHTTPClient = srv.Client()
+	t.Cleanup(func() {
+		pushHTTPClient = prev
+		srv.Close()
+	})
+}
+
+func TestSendAPNsPush(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	setPushConfig(t, map[string]string{
+		"push.apns.team_id":     "TEAM123456",
+		"push.apns.key_id":      "KEY1234567",
+		"push.apns.private_key": pemPKCS8(t, key),
+	})
+	apnsProviderToken.token = ""
+
+	var gotPath, gotTopic, gotProto string
+	var gotPayload map[string]any
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotTopic, gotProto = r.URL.EscapedPath(), r.Header.Get("apns-topic"), r.Proto
+		bearer := strings.TrimPrefix(r.Header.Get("authorization"), "bearer ")
+		parsed, err := jwt.Parse(bearer, func(*jwt.Token) (any, error) { return &key.PublicKey, nil })
+		if err != nil || parsed.Header["kid"] != "KEY1234567" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"reason": "InvalidProviderToken"})
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&gotPayload)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/gone"):
+			w.WriteHeader(http.StatusGone)
+			json.NewEncoder(w).Encode(map[string]any{"reason": "Unregistered", "timestamp": 1})
+		case strings.HasSuffix(r.URL.Path, "/bad"),
+			strings.HasPrefix(r.URL.Path, "/sandbox/") && strings.HasSuffix(r.URL.Path, "/prodonly"):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"reason": "BadDeviceToken"})
+		case strings.HasSuffix(r.URL.Path, "/othertopic"):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"reason": "DeviceTokenNotForTopic"})
+		case strings.HasSuffix(r.URL.Path, "/busy"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"reason": "ServiceUnavailable"})
+		default:
+			w.Header().Set("apns-id", "abc")
+		}
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	usePushServer(t, srv)
+	prodURL, sandboxURL := apnsProductionURL, apnsSandboxURL
+	apnsProductionURL, apnsSandboxURL = srv.URL+"/prod", srv.URL+"/sandbox"
+	t.Cleanup(func() { apnsProductionURL, apnsSandboxURL = prodURL, sandboxURL })
+
+	ctx := context.Background()
+	sandbox := true
+	badge := 3
+	n := &PushNotification{Title: "Hi", Body: "There", Badge: &badge, Data: map[string]interface{}{"type": "ticket"}}
+	token := &PushToken{Token: "devtoken", Topic: "io.kaitu.app", Sandbox: &sandbox}
+
+	require.NoError(t, sendAPNsPush(ctx, token, n))
+	assert.Equal(t, "HTTP/2.0", gotProto)
+	assert.Equal(t, "/sandbox/3/device/devtoken", gotPath)
+	assert.Equal(t, "io.kaitu.app", gotTopic)
+	assert.Equal(t, "ticket", gotPayload["type"])
+	aps := gotPayload["aps"].(map[string]any)
+	assert.Equal(t, float64(3), aps["badge"])
+	assert.Equal(t, "default", aps["sound"])
+
+	sandbox = false
+	token.Token = "gone"
+	err = sendAPNsPush(ctx, token, n)
+	assert.ErrorIs(t, err, errPushTokenInvalid)
+	assert.Equal(t, "/prod/3/device/gone", gotPath)
+
+	token.Token = "busy"
+	err = sendAPNsPush(ctx, token, n)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, errPushTokenInvalid)
+
+	// BadDeviceToken also means the token belongs to the other environment:
+	// a sandbox-registered production token is delivered, not pruned.
+	sandbox = true
+	token.Token = "prodonly"
+	require.NoError(t, sendAPNsPush(ctx, token, n))
+	assert.Equal(t, "/prod/3/device/prodonly", gotPath)
+
+	// Rejected by both gateways: the token itself is bad.
+	token.Token = "bad"
+	err = sendAPNsPush(ctx, token, n)
+	assert.ErrorIs(t, err, errPushTokenInvalid)
+	sandbox = false
+
+	// A token for another topic means our topic is misconfigured; keep it.
+	token.Token = "othertopic"
+	err = sendAPNsPush(ctx, token, n)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, errPushTokenInvalid)
+
+	// The token is a path segment and must not be able to escape it.
+	token.Token = "../x?y"
+	sendAPNsPush(ctx, token, n)
+	assert.Equal(t, "/prod/3/device/..%2Fx%3Fy", gotPath)
+}
+
+func TestSendFCMPush(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	fcmAccessToken.token = ""
+
+	tokenExchanges := 0
+	var gotMessage map[string]any
+	var gotPath string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokenExchanges++
+			r.ParseForm()
+			parsed, err := jwt.Parse(r.PostForm.Get("assertion"), func(*jwt.Token) (any, error) { return &key.PublicKey, nil })
+			if err != nil || parsed.Claims.(jwt.MapClaims)["scope"] != fcmScope {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "ya29.test", "expires_in": 3600})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer ya29.test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotMessage)
+		msg := gotMessage["message"].(map[string]any)
+		switch msg["token"] {
+		case "stale":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"status":"NOT_FOUND","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"UNREGISTERED"}]}}`))
+			return
+		case "foreign":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"status":"PERMISSION_DENIED","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"SENDER_ID_MISMATCH"}]}}`))
+			return
+		}
+		w.Write([]byte(`{"name":"projects/kaitu-test/messages/1"}`))
+	}))
+	usePushServer(t, srv)
+	prev := fcmBaseURL
+	fcmBaseURL = srv.URL
+	t.Cleanup(func() { fcmBaseURL = prev })
+
+	sa, _ := json.Marshal(fcmServiceAccount{
+		ClientEmail: "push@kaitu-test.iam.gserviceaccount.com",
+		PrivateKey:  pemPKCS8(t, key),
+		TokenURI:    srv.URL + "/token",
+	})
+	setPushConfig(t, map[string]string{
+		"push.fcm.project_id":      "kaitu-test",
+		"push.fcm.service_account": string(sa),
+	})
+
+	ctx := context.Background()
+	n := &PushNotification{Title: "Hi", Body: "There", Data: map[string]interface{}{"id": 42}}
+	require.NoError(t, sendFCMPush(ctx, &PushToken{Token: "fresh"}, n))
+	assert.Equal(t, "/v1/projects/kaitu-test/messages:send", gotPath)
+	msg := gotMessage["message"].(map[string]any)
+	assert.Equal(t, map[string]any{"id": "42"}, msg["data"], "FCM data values must be strings")
+
+	err = sendFCMPush(ctx, &PushToken{Token: "stale"}, n)
+	assert.ErrorIs(t, err, errPushTokenInvalid)
+
+	// Sender mismatch points at our project config, not at the token.
+	err = sendFCMPush(ctx, &PushToken{Token: "foreign"}, n)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, errPushTokenInvalid)
+	assert.Equal(t, 1, tokenExchanges, "access token must be cached")
+}
+
+func TestSendJPushPush(t *testing.T) {
+	setPushConfig(t, map[string]string{
+		"push.jpush.app_key":       "appkey",
+		"push.jpush.master_secret": "secret",
+	})
+	var gotBody map[string]any
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "appkey" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"code":1004,"message":"auth failed"}}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		ids := gotBody["audience"].(map[string]any)["registration_id"].([]any)
+		if ids[0] == "stale" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":1011,"message":"cannot find user by this audience"}}`))
+			return
+		}
+		w.Write([]byte(`{"sendno":"0","msg_id":"123"}`))
+	}))
+	usePushServer(t, srv)
+	prev := jpushBaseURL
+	jpushBaseURL = srv.URL
+	t.Cleanup(func() { jpushBaseURL = prev })
+
+	ctx := context.Background()
+	n := &PushNotification{Title: "Hi", Body: "There"}
+	require.NoError(t, sendJPushPush(ctx, &PushToken{Token: "regid"}, n))
+	android := gotBody["notification"].(map[string]any)["android"].(map[string]any)
+	assert.Equal(t, "There", android["alert"])
+	assert.Equal(t, "Hi", android["title"])
+
+	assert.ErrorIs(t, sendJPushPush(ctx, &PushToken{Token: "stale"}, n), errPushTokenInvalid)
+}
+
+func TestSendPush_NotConfigured(t *testing.T) {
+	ctx := context.Background()
+	n := &PushNotification{Title: "Hi"}
+	assert.ErrorIs(t, sendAPNsPush(ctx, &PushToken{Token: "t"}, n), errPushNotConfigured)
+	assert.ErrorIs(t, sendFCMPush(ctx, &PushToken{Token: "t"}, n), errPushNotConfigured)
+	assert.ErrorIs(t, sendJPushPush(ctx, &PushToken{Token: "t"}, n), errPushNotConfigured)
+}
+
+func TestSendPushToToken_PrunesInvalidToken(t *testing.T) {
+	skipIfNoConfig(t)
+	setPushConfig(t, map[string]string{
+		"push.jpush.app_key":       "appkey",
+		"push.jpush.master_secret": "secret",
+	})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":1011,"message":"cannot find user by this audience"}}`))
+	}))
+	usePushServer(t, srv)
+	prev := jpushBaseURL
+	jpushBaseURL = srv.URL
+	t.Cleanup(func() { jpushBaseURL = prev })
+
+	user := CreateTestUser(t)
+	token := PushToken{
+		UserID:     user.ID,
+		DeviceUDID: "push-prune-device",
+		Platform:   PushPlatformAndroid,
+		Provider:   PushProviderJPush,
+		Token:      "stale",
+		AppFlavor:  AppFlavorChina,
+		Status:     PushTokenStatusActive,
+	}
+	require.NoError(t, db.Get().Create(&token).Error)
+	t.Cleanup(func() { db.Get().Unscoped().Delete(&token) })
+
+	before := pushMetrics[PushProviderJPush].invalidTokens.Load()
+	err := sendPushToToken(context.Background(), &token, &PushNotification{Title: "Hi"})
+	assert.ErrorIs(t, err, errPushTokenInvalid)
+
+	var stored PushToken
+	require.NoError(t, db.Get().First(&stored, token.ID).Error)
+	assert.Equal(t, PushTokenStatusInactive, stored.Status)
+	assert.Equal(t, before+1, pushMetrics[PushProviderJPush].invalidTokens.Load())
+}
+
+func TestPushMetricsSnapshot(t *testing.T) {
+	items := PushMetricsSnapshot()
+	require.Len(t, items, 3)
+	assert.Equal(t, PushProviderAPNs, items[0].Provider)
+	assert.Equal(t, PushProviderFCM, items[1].Provider)
+	assert.Equal(t, PushProviderJPush, items[2].Provider)
+}
@@ -0,0 +1,258 @@
+package center
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"sort"
+	"time"
+
+	db "github.com/wordgate/qtoolkit/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ruleMissRevealK is the k-anonymity threshold: a hash's plaintext is only
+// accepted once this many distinct reporters have sent it on the same salt
+// day. Below k a Revealed value could identify a single user's browsing, so
+// it is discarded without ever touching the database.
+const ruleMissRevealK = 10
+
+// ruleMissRevealQuorum is how many distinct reporters must submit the same
+// plaintext for a hash before it is stored. The server cannot verify a
+// plaintext against hash16 (the salt is client-side), so one reporter alone
+// could otherwise label any eligible hash with a domain of its choosing.
+const ruleMissRevealQuorum = 3
+
+// Retention windows, matching the Phase 1 plan: raw observations only need to
+// outlive one salt day (distinct-reporter counting reads them), aggregates
+// back the admin top-missed report which looks back at most 30 days.
+const (
+	ruleMissRawRetentionDays       = 3
+	ruleMissAggregateRetentionDays = 30
+)
+
+var (
+	ruleMissSaltDayRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	// ruleMissDomainRe accepts a lowercase hostname with at least two labels.
+	ruleMissDomainRe = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+// validRuleMissDomain reports whether s looks like a hostname a client would
+// have hashed. The server cannot recompute hash16 (the salt lives on the
+// client), so this is the only check a Revealed value gets.
+func validRuleMissDomain(s string) bool {
+	return len(s) <= 253 && ruleMissDomainRe.MatchString(s)
+}
+
+// ruleMissReporterHash derives the per-day anonymous reporter key from the
+// source IP's network prefix (see ruleMissReporterPrefix). It rotates with
+// salt_day so one device cannot be followed across days, and the raw IP is
+// never stored.
+func ruleMissReporterHash(ctx context.Context, saltDay, ip string) string {
+	return secretHashIt(ctx, []byte(saltDay+"|"+ruleMissReporterPrefix(ip)))[:16]
+}
+
+// ruleMissReporterPrefix collapses an address to the block one subscriber
+// controls: a /64 for IPv6, where every host picks its own interface id (and
+// privacy extensions rotate it), and a /24 for IPv4. Keying on the full
+// address would let a single IPv6 client pose as any number of reporters and
+// push a hash past k on its own. Unparseable input is returned unchanged.
+func ruleMissReporterPrefix(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// recordRuleMisses persists one validated batch: raw rows, additive per-hash
+// aggregates with a refreshed distinct-reporter count, and reveal votes for
+// hashes already past k; a plaintext is stored once ruleMissRevealQuorum
+// reporters agree on it. Returns the batch hashes that have passed k but are
+// still unrevealed and carried no plaintext — the client may send it next time.
+func recordRuleMisses(ctx context.Context, batch *RuleMissBatch, reporter string) ([]string, error) {
+	now := time.Now()
+
+	raws := make([]RuleMiss, 0, len(batch.Records))
+	hits := map[string]int64{}
+	revealed := map[string]string{}
+	for _, rec := range batch.Records {
+		raws = append(raws, RuleMiss{
+			SaltDay:       batch.SaltDay,
+			Hash16:        rec.Hash16,
+			ReporterHash:  reporter,
+			Country:       rec.Country,
+			WeekBucket:    rec.WeekBucket,
+			Protocol:      rec.Protocol,
+			ClientVersion: batch.ClientVersion,
+			RulesVersion:  batch.RulesVersion,
+		})
+		hits[rec.Hash16]++
+		if rec.Revealed != "" {
+			revealed[rec.Hash16] = rec.Revealed
+		}
+	}
+	hashes := make([]string, 0, len(hits))
+	for h := range hits {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+
+	pending := []string{}
+	err := db.Get().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&raws).Error; err != nil {
+			return err
+		}
+
+		// Distinct reporters per hash, counted over the raw rows that now
+		// include this batch.
+		var counts []struct {
+			Hash16  string
+			Devices int
+		}
+		if err := tx.Model(&RuleMiss{}).
+			Select("hash16, COUNT(DISTINCT reporter_hash) AS devices").
+			Where("salt_day = ? AND hash16 IN ?", batch.SaltDay, hashes).
+			Group("hash16").
+			Scan(&counts).Error; err != nil {
+			return err
+		}
+		devices := make(map[string]int, len(counts))
+		for _, row := range counts {
+			devices[row.Hash16] = row.Devices
+		}
+
+		// One row per hash, so the additive upsert never sees duplicate keys
+		// within the statement. Devices only ever grows: a late batch whose
+		// raw rows were already swept must not shrink the count.
+		aggs := make([]RuleMissAggregate, 0, len(hashes))
+		for _, h := range hashes {
+			aggs = append(aggs, RuleMissAggregate{
+				SaltDay: batch.SaltDay,
+				Hash16:  h,
+				Hits:    hits[h],
+				Devices: devices[h],
+			})
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "salt_day"}, {Name: "hash16"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"hits":       gorm.Expr("hits + VALUES(hits)"),
+				"devices":    gorm.Expr("GREATEST(devices, VALUES(devices))"),
+				"updated_at": now,
+			}),
+		}).Create(&aggs).Error; err != nil {
+			return err
+		}
+
+		var eligible []RuleMissAggregate
+		if err := tx.Where("salt_day = ? AND hash16 IN ? AND devices >= ? AND revealed = ''",
+			batch.SaltDay, hashes, ruleMissRevealK).
+			Find(&eligible).Error; err != nil {
+			return err
+		}
+		for _, agg := range eligible {
+			domain, ok := revealed[agg.Hash16]
+			if !ok {
+				pending = append(pending, agg.Hash16)
+				continue
+			}
+			// One vote per reporter; a reporter changing its answer replaces
+			// its earlier vote instead of adding another.
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "salt_day"}, {Name: "hash16"}, {Name: "reporter_hash"}},
+				DoUpdates: clause.AssignmentColumns([]string{"revealed"}),
+			}).Create(&RuleMissReveal{
+				SaltDay:      batch.SaltDay,
+				Hash16:       agg.Hash16,
+				ReporterHash: reporter,
+				Revealed:     domain,
+			}).Error; err != nil {
+				return err
+			}
+
+			var top struct {
+				Revealed string
+				Votes    int
+			}
+			if err := tx.Model(&RuleMissReveal{}).
+				Select("revealed, COUNT(DISTINCT reporter_hash) AS votes").
+				Where("salt_day = ? AND hash16 = ?", batch.SaltDay, agg.Hash16).
+				Group("revealed").
+				Order("votes DESC").
+				Limit(1).
+				Scan(&top).Error; err != nil {
+				return err
+			}
+			if top.Votes < ruleMissRevealQuorum {
+				continue
+			}
+			// First plaintext to reach quorum wins; the revealed = '' guard
+			// keeps a concurrent batch from overwriting it.
+			if err := tx.Model(&RuleMissAggregate{}).
+				Where("id = ? AND revealed = ''", agg.ID).
+				Updates(map[string]any{"revealed": top.Revealed, "revealed_at": now}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// RuleMissTopDomain is one row of the admin top-missed report.
+type RuleMissTopDomain struct {
+	Domain     string `json:"domain"`
+	Hits       int64  `json:"hits"`
+	DeviceDays int64  `json:"deviceDays"` // 每日不同上报方数之和（上报方按日轮换，无法跨日去重）
+	Days       int64  `json:"days"`       // 出现过的 salt_day 天数
+	LastSeen   string `json:"lastSeen"`   // 最近一次出现的 salt_day
+}
+
+// queryRuleMissTopDomains ranks revealed domains seen on salt days since
+// `since` (YYYY-MM-DD), and totals the hits still hidden behind unrevealed
+// hashes so the report shows how much of the miss volume it covers.
+func queryRuleMissTopDomains(since string, limit int) ([]RuleMissTopDomain, int64, error) {
+	var rows []struct {
+		Domain     string
+		Hits       int64
+		DeviceDays int64
+		Days       int64
+		LastSeen   string
+	}
+	if err := db.Get().Model(&RuleMissAggregate{}).
+		Select("revealed AS domain, SUM(hits) AS hits, SUM(devices) AS device_days, COUNT(DISTINCT salt_day) AS days, MAX(salt_day) AS last_seen").
+		Where("salt_day >= ? AND revealed <> ''", since).
+		Group("revealed").
+		Order("hits DESC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	out := make([]RuleMissTopDomain, len(rows))
+	for i, r := range rows {
+		out[i] = RuleMissTopDomain{
+			Domain:     r.Domain,
+			Hits:       r.Hits,
+			DeviceDays: r.DeviceDays,
+			Days:       r.Days,
+			LastSeen:   r.LastSeen,
+		}
+	}
+
+	var hidden struct{ Total int64 }
+	if err := db.Get().Model(&RuleMissAggregate{}).
+		Select("COALESCE(SUM(hits), 0) AS total").
+		Where("salt_day >= ? AND revealed = ''", since).
+		Scan(&hidden).Error; err != nil {
+		return nil, 0, err
+	}
+	return out, hidden.Total, nil
+}
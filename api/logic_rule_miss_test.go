@@ -0,0 +1,192 @@
+package center
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+func TestValidRuleMissDomain(t *testing.T) {
+	for _, ok := range []string{"example.com", "a.b.example.co.uk", "xn--fiqs8s.cn", "1-2.example.net"} {
+		assert.True(t, validRuleMissDomain(ok), ok)
+	}
+	for _, bad := range []string{"", "localhost", "Example.com", "-a.example.com", "a..com", "exa mple.com", "example.com."} {
+		assert.False(t, validRuleMissDomain(bad), bad)
+	}
+}
+
+// TestRuleMissReporterHash_RotatesDaily: the same IP maps to a stable key
+// within a salt day and to an unrelated key the next day.
+func TestRuleMissReporterHash_RotatesDaily(t *testing.T) {
+	ctx := context.Background()
+	a := ruleMissReporterHash(ctx, "2026-04-11", "192.0.2.1")
+	assert.Len(t, a, 16)
+	assert.Equal(t, a, ruleMissReporterHash(ctx, "2026-04-11", "192.0.2.1"))
+	assert.NotEqual(t, a, ruleMissReporterHash(ctx, "2026-04-12", "192.0.2.1"))
+	assert.NotEqual(t, a, ruleMissReporterHash(ctx, "2026-04-11", "198.51.100.1"))
+}
+
+// TestRuleMissReporterHash_OnePerPrefix: every address inside one IPv6 /64 or
+// IPv4 /24 is the same reporter, so rotating the interface id or host octet
+// does not buy extra votes towards k.
+func TestRuleMissReporterHash_OnePerPrefix(t *testing.T) {
+	ctx := context.Background()
+	const day = "2026-04-11"
+
+	seen := map[string]bool{}
+	for i := 1; i <= 50; i++ {
+		seen[ruleMissReporterHash(ctx, day, fmt.Sprintf("2001:db8:1:2:%x::%x", i, i*7))] = true
+	}
+	assert.Len(t, seen, 1, "50 addresses in 2001:db8:1:2::/64 count as one reporter")
+	assert.NotEqual(t,
+		ruleMissReporterHash(ctx, day, "2001:db8:1:2::1"),
+		ruleMissReporterHash(ctx, day, "2001:db8:1:3::1"), "neighbouring /64s are distinct reporters")
+
+	seen = map[string]bool{}
+	for i := 1; i <= 50; i++ {
+		seen[ruleMissReporterHash(ctx, day, fmt.Sprintf("203.0.113.%d", i))] = true
+	}
+	assert.Len(t, seen, 1, "50 addresses in 203.0.113.0/24 count as one reporter")
+	assert.Equal(t, ruleMissReporterHash(ctx, day, "203.0.113.9"), ruleMissReporterHash(ctx, day, "::ffff:203.0.113.200"))
+}
+
+func ruleMissBatchOf(saltDay string, recs ...RuleMissRecord) *RuleMissBatch {
+	return &RuleMissBatch{SchemaVersion: ruleMissSchemaVersion, SaltDay: saltDay, Records: recs}
+}
+
+func loadRuleMissAggregate(t *testing.T, saltDay, hash string) RuleMissAggregate {
+	t.Helper()
+	var agg RuleMissAggregate
+	require.NoError(t, db.Get().Where("salt_day = ? AND hash16 = ?", saltDay, hash).First(&agg).Error)
+	return agg
+}
+
+// TestRecordRuleMisses_RevealOnlyPastK walks one hash up to the threshold:
+// plaintext sent below k is dropped, the batch that reaches k is told to
+// reveal, and a plaintext sticks only once a quorum of reporters agrees.
+func TestRecordRuleMisses_RevealOnlyPastK(t *testing.T) {
+	skipIfNoConfig(t)
+	const saltDay = "1999-05-01"
+	cleanupRuleMissSaltDay(t, saltDay)
+	ctx := context.Background()
+
+	rec := validRecord(0xabc)
+	early := rec
+	early.Revealed = "early.example.com"
+
+	// k-1 distinct reporters, the first one already (wrongly) revealing.
+	for i := 0; i < ruleMissRevealK-1; i++ {
+		r := rec
+		if i == 0 {
+			r = early
+		}
+		pending, err := recordRuleMisses(ctx, ruleMissBatchOf(saltDay, r), fmt.Sprintf("reporter-%02d", i))
+		require.NoError(t, err)
+		assert.Empty(t, pending, "below k nothing is eligible")
+	}
+	// Same reporter again: hits grow, devices don't.
+	_, err := recordRuleMisses(ctx, ruleMissBatchOf(saltDay, rec, rec), "reporter-00")
+	require.NoError(t, err)
+	agg := loadRuleMissAggregate(t, saltDay, rec.Hash16)
+	assert.Equal(t, int64(ruleMissRevealK+1), agg.Hits)
+	assert.Equal(t, ruleMissRevealK-1, agg.Devices)
+	assert.Empty(t, agg.Revealed, "plaintext below k must be discarded")
+
+	// The k-th reporter crosses the threshold and is asked to reveal.
+	pending, err := recordRuleMisses(ctx, ruleMissBatchOf(saltDay, rec), "reporter-kk")
+	require.NoError(t, err)
+	assert.Equal(t, []string{rec.Hash16}, pending)
+
+	revealed := rec
+	revealed.Revealed = "missed.example.com"
+	forged := rec
+	forged.Revealed = "forged.example.com"
+
+	// A lone reporter cannot label the hash, however often it repeats itself.
+	for i := 0; i < ruleMissRevealQuorum; i++ {
+		pending, err = recordRuleMisses(ctx, ruleMissBatchOf(saltDay, forged), "reporter-kk")
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	}
+	assert.Empty(t, loadRuleMissAggregate(t, saltDay, rec.Hash16).Revealed, "one reporter is one vote")
+
+	// reporter-kk changes its answer; with quorum-1 others agreeing, the
+	// plaintext is stored.
+	for i := 0; i < ruleMissRevealQuorum; i++ {
+		reporter := fmt.Sprintf("reporter-%02d", i)
+		if i == 0 {
+			reporter = "reporter-kk"
+		}
+		assert.Empty(t, loadRuleMissAggregate(t, saltDay, rec.Hash16).Revealed, "below quorum")
+		_, err = recordRuleMisses(ctx, ruleMissBatchOf(saltDay, revealed), reporter)
+		require.NoError(t, err)
+	}
+	agg = loadRuleMissAggregate(t, saltDay, rec.Hash16)
+	assert.Equal(t, "missed.example.com", agg.Revealed)
+	assert.NotNil(t, agg.RevealedAt)
+
+	// First plaintext to reach quorum wins.
+	for i := 0; i < ruleMissRevealQuorum; i++ {
+		_, err = recordRuleMisses(ctx, ruleMissBatchOf(saltDay, forged), fmt.Sprintf("reporter-f%d", i))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "missed.example.com", loadRuleMissAggregate(t, saltDay, rec.Hash16).Revealed)
+}
+
+// TestAdminRuleMissTop aggregates a revealed domain across two salt days and
+// keeps unrevealed volume out of the ranking.
+func TestAdminRuleMissTop(t *testing.T) {
+	skipIfNoConfig(t)
+	today := time.Now().UTC().Format("2006-01-02")
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	const domain = "rule-miss-top-test.example.com"
+	purge := func() {
+		db.Get().Where("hash16 IN ?", []string{"fffffffffff00001", "fffffffffff00002", "fffffffffff00003"}).Delete(&RuleMissAggregate{})
+	}
+	purge()
+	t.Cleanup(purge)
+
+	require.NoError(t, db.Get().Create(&[]RuleMissAggregate{
+		{SaltDay: today, Hash16: "fffffffffff00001", Hits: 30, Devices: 12, Revealed: domain},
+		{SaltDay: yesterday, Hash16: "fffffffffff00002", Hits: 20, Devices: 11, Revealed: domain},
+		{SaltDay: today, Hash16: "fffffffffff00003", Hits: 7, Devices: 2},
+	}).Error)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/app/strategy/rule-misses/top", api_admin_rule_miss_top)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/strategy/rule-misses/top?range=7d&limit=500", nil))
+	var resp Response[RuleMissTopResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.EqualValues(t, ErrorNone, resp.Code)
+	assert.Equal(t, ruleMissRevealK, resp.Data.RevealK)
+	assert.GreaterOrEqual(t, resp.Data.UnrevealedHits, int64(7))
+
+	var found *RuleMissTopDomain
+	for i, d := range resp.Data.Domains {
+		if d.Domain == domain {
+			found = &resp.Data.Domains[i]
+		}
+	}
+	require.NotNil(t, found, "revealed domain listed")
+	assert.Equal(t, int64(50), found.Hits)
+	assert.Equal(t, int64(23), found.DeviceDays)
+	assert.Equal(t, int64(2), found.Days)
+	assert.Equal(t, today, found.LastSeen)
+
+	// 90d exceeds aggregate retention.
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/strategy/rule-misses/top?range=90d", nil))
+	assert.EqualValues(t, ErrorInvalidArgument, decodeResp(t, w).Code)
+}
@@ -0,0 +1,214 @@
+package center
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"gorm.io/gorm"
+)
+
+// ========================= 登录会话（token 家族） =========================
+//
+// 每次登录开启一个会话，签发的 token 在 sid claim 中携带 FamilyID：
+//   - 设备登录：access + refresh。refresh 轮换时沿用 FamilyID，Device.TokenIssueAt
+//     记录当前这一代；再次出示上一代 refresh token 视为泄漏，整个家族吊销。
+//     客户端必须串行刷新（webapp 已用 _refreshPromise 合并并发刷新）。
+//   - Web Cookie 登录：只有 access token，sliding 续期同样沿用 FamilyID。
+//
+// 没有 sid 的存量 token 仍然有效，只受 User.SessionsRevokedAt 全局登出约束；
+// 设备 refresh 一次后即迁入会话体系。
+
+const (
+	sessionClientWeb = "web" // Web Cookie 会话；设备会话沿用 classStr：service | router
+
+	// sessionTouchInterval 最后活跃时间的写库节流（秒），避免每个请求都写一次
+	sessionTouchInterval = 5 * 60
+
+	sessionRevokeUser       = "user"          // 用户在会话列表中吊销
+	sessionRevokeLogout     = "logout"        // 用户主动登出
+	sessionRevokeAdmin      = "admin"         // 管理员全局登出
+	sessionRevokeReuse      = "refresh_reuse" // 上一代 refresh token 被再次使用
+	sessionRevokeSuperseded = "superseded"    // 同一设备重新登录，旧家族作废
+)
+
+// ErrRefreshTokenReused 出示了已被轮换掉的 refresh token
+var ErrRefreshTokenReused = e(ErrorNotLogin, "refresh token reused")
+
+// newSessionFamilyID 生成 32 位十六进制的家族 ID
+func newSessionFamilyID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand unavailable, refusing to issue session id: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// startAuthSession 开启新会话。设备会话会先作废同一 UDID 上仍有效的旧家族。
+func startAuthSession(c *gin.Context, tx *gorm.DB, userID uint64, udid, clientClass string) (*AuthSession, error) {
+	now := time.Now().Unix()
+	if udid != "" {
+		if err := tx.Model(&AuthSession{}).
+			Where("udid = ? AND revoked_at = 0", udid).
+			Updates(map[string]any{"revoked_at": now, "revoke_reason": sessionRevokeSuperseded}).Error; err != nil {
+			return nil, err
+		}
+	}
+	session := &AuthSession{
+		UserID:      userID,
+		FamilyID:    newSessionFamilyID(),
+		UDID:        udid,
+		ClientClass: clientClass,
+		IPCountry:   CountryFromGinContext(c),
+		LastSeenAt:  now,
+	}
+	if err := tx.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// issueWebSession 开启 Web 会话并签发 Cookie token
+func issueWebSession(c *gin.Context, userID uint64, roles uint64) (*DataAuthResult, error) {
+	session, err := startAuthSession(c, db.Get(), userID, "", sessionClientWeb)
+	if err != nil {
+		return nil, err
+	}
+	authResult, _, err := generateWebCookieToken(c, userID, roles, session.FamilyID)
+	return authResult, err
+}
+
+// renewWebCookieToken sliding 续期：沿用原会话；存量 token 没有 sid 时开启新的 Web 会话，
+// 否则续期后的 token 永远游离在会话列表和单会话吊销之外
+func renewWebCookieToken(c *gin.Context, userID uint64, claims *TokenClaims) (*DataAuthResult, error) {
+	sessionID := claims.SessionID
+	if sessionID == "" {
+		session, err := startAuthSession(c, db.Get(), userID, "", sessionClientWeb)
+		if err != nil {
+			return nil, fmt.Errorf("start web session: %w", err)
+		}
+		sessionID = session.FamilyID
+	}
+	authResult, _, err := generateWebCookieToken(c, userID, claims.Roles, sessionID)
+	return authResult, err
+}
+
+// issueDeviceSession 在登录事务内开启设备会话并签发 access + refresh token
+func issueDeviceSession(c *gin.Context, tx *gorm.DB, userID uint64, udid string, roles uint64) (*DataAuthResult, time.Time, error) {
+	session, err := startAuthSession(c, tx, userID, udid, classStr(isGatewayRequest(c)))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return generateTokens(c, userID, udid, roles, session.FamilyID)
+}
+
+// checkAuthSession 校验 token 所属会话仍然有效，并节流更新最后活跃时间。
+// 存量 token（无 sid）返回 nil 会话，只按 User.SessionsRevokedAt 判定。
+func checkAuthSession(c *gin.Context, claims *TokenClaims, user *User) (*AuthSession, bool) {
+	if claims.SessionID == "" {
+		if user != nil && user.SessionsRevokedAt != 0 && claims.TokenIssueAt <= user.SessionsRevokedAt {
+			log.Warnf(c, "legacy token for user %d issued before global logout", claims.UserID)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	var session AuthSession
+	if err := db.Get().Where(&AuthSession{FamilyID: claims.SessionID}).First(&session).Error; err != nil {
+		log.Warnf(c, "session %s not found for user %d: %v", claims.SessionID, claims.UserID, err)
+		return nil, false
+	}
+	if session.UserID != claims.UserID || session.UDID != claims.DeviceID {
+		log.Warnf(c, "session %s does not belong to user %d device %q", claims.SessionID, claims.UserID, claims.DeviceID)
+		return nil, false
+	}
+	if session.IsRevoked() {
+		log.Infof(c, "rejected token of revoked session %d (user %d, reason %s)", session.ID, session.UserID, session.RevokeReason)
+		return nil, false
+	}
+	touchAuthSession(c, &session)
+	return &session, true
+}
+
+// touchAuthSession 异步更新最后活跃时间与 IP 国家（与 maybeUpdateUserCountry 同一模式）
+func touchAuthSession(c *gin.Context, session *AuthSession) {
+	now := time.Now().Unix()
+	cc := CountryFromGinContext(c)
+	if now-session.LastSeenAt < sessionTouchInterval && (cc == "" || cc == session.IPCountry) {
+		return
+	}
+	updates := map[string]any{"last_seen_at": now}
+	session.LastSeenAt = now
+	if cc != "" {
+		updates["ip_country"] = cc
+		session.IPCountry = cc
+	}
+	sessionID := session.ID
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := db.Get().WithContext(ctx).Model(&AuthSession{}).
+			Where("id = ?", sessionID).Updates(updates).Error; err != nil {
+			log.Warnf(ctx, "failed to touch session %d: %v", sessionID, err)
+		}
+	}()
+}
+
+// revokeAuthSessions 吊销满足条件的有效会话，返回吊销条数
+func revokeAuthSessions(tx *gorm.DB, reason string, query any, args ...any) (int64, error) {
+	res := tx.Model(&AuthSession{}).
+		Where("revoked_at = 0").
+		Where(query, args...).
+		Updates(map[string]any{"revoked_at": time.Now().Unix(), "revoke_reason": reason})
+	return res.RowsAffected, res.Error
+}
+
+// handleRefreshReuse 上一代 refresh token 被再次使用：吊销整个家族。
+// 家族若已因同设备重新登录而作废，说明这是更早一轮登录的残留，不影响当前登录。
+func handleRefreshReuse(c *gin.Context, claims *TokenClaims) {
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		if claims.SessionID != "" {
+			n, err := revokeAuthSessions(tx, sessionRevokeReuse, "family_id = ? AND user_id = ?", claims.SessionID, claims.UserID)
+			if err != nil || n == 0 {
+				return err
+			}
+		} else {
+			// 存量家族：设备上已有会话说明旧 token 来自更早的登录
+			var active int64
+			if err := tx.Model(&AuthSession{}).
+				Where("udid = ? AND revoked_at = 0", claims.DeviceID).Count(&active).Error; err != nil {
+				return err
+			}
+			if active > 0 {
+				return nil
+			}
+		}
+		log.Warnf(c, "refresh token reuse detected for user %d device %s, revoking session family", claims.UserID, claims.DeviceID)
+		// 锚点清零：当前这一代 access / refresh token 一并失效
+		return tx.Model(&Device{}).
+			Where("udid = ? AND user_id = ?", claims.DeviceID, claims.UserID).
+			Update("token_issue_at", 0).Error
+	})
+	if err != nil {
+		log.Errorf(c, "failed to revoke session family for user %d device %s: %v", claims.UserID, claims.DeviceID, err)
+	}
+}
+
+// revokeAllUserSessions 全局登出：吊销全部会话、作废存量 token
+func revokeAllUserSessions(tx *gorm.DB, userID uint64) (int64, error) {
+	n, err := revokeAuthSessions(tx, sessionRevokeAdmin, "user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&User{}).Where("id = ?", userID).
+		Update("sessions_revoked_at", time.Now().Unix()).Error; err != nil {
+		return 0, err
+	}
+	return n, nil
+}
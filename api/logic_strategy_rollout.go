@@ -0,0 +1,160 @@
+package center
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"gorm.io/gorm"
+)
+
+// ========================= 规则灰度与 A/B 分组 =========================
+//
+// 下发优先级：显式分组（StrategyCohort）> 候选版本灰度桶 > 激活版本。
+// 灰度按 hash(候选版本:UDID) 分 100 桶：[0, P) 下发候选版本（rollout），
+// [P, 2P) 是等大的对照桶，仍下发激活版本（control），用于同口径对比；
+// 其余设备为 default。分桶以候选版本为盐，每个新候选重新洗牌，
+// 调大比例时已在桶内的设备保持不变。
+
+const (
+	strategyCohortDefault = "default"
+	strategyCohortRollout = "rollout"
+	strategyCohortControl = "control"
+)
+
+// reservedStrategyCohorts 不能用作显式分组名的内置标签
+var reservedStrategyCohorts = map[string]bool{
+	strategyCohortDefault: true,
+	strategyCohortRollout: true,
+	strategyCohortControl: true,
+}
+
+// strategyAssignment 设备当前应拿到的规则版本
+type strategyAssignment struct {
+	Rules  *StrategyRules // nil = 没有可用版本，下发内置默认
+	Cohort string
+}
+
+// Version 返回打标用的版本号，无版本时为 "default"
+func (a *strategyAssignment) Version() string {
+	if a.Rules == nil {
+		return "default"
+	}
+	return a.Rules.Version
+}
+
+// ETag 由版本和分组共同决定：同一版本在不同分组下的响应（cohort 字段、客户端
+// 打的标签）不同，只按版本比对会让换了分组的设备拿到 304、继续上报旧分组。
+// default 分组沿用纯版本号，与分组上线前客户端缓存的 ETag 一致。
+func (a *strategyAssignment) ETag() string {
+	if a.Cohort == "" || a.Cohort == strategyCohortDefault {
+		return fmt.Sprintf("%q", a.Version())
+	}
+	return fmt.Sprintf("%q", a.Version()+";"+a.Cohort)
+}
+
+// strategyRolloutBucket 把设备映射到 [0, 100) 的灰度桶
+func strategyRolloutBucket(version, udid string) int {
+	sum := sha256.Sum256([]byte(version + ":" + udid))
+	return int(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
+
+// resolveStrategyAssignment 计算设备的规则版本与分组。查询失败按"无此项"降级，
+// 最坏情况是设备拿到激活版本（与灰度上线前一致），不会让规则拉取失败。
+func resolveStrategyAssignment(ctx context.Context, device *Device) *strategyAssignment {
+	if device != nil {
+		if rules, cohort := loadCohortRules(ctx, device.ID); rules != nil {
+			return &strategyAssignment{Rules: rules, Cohort: cohort}
+		}
+	}
+
+	var active *StrategyRules
+	var row StrategyRules
+	if err := db.Get().Where(&StrategyRules{IsActive: BoolPtr(true)}).First(&row).Error; err == nil {
+		active = &row
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Warnf(ctx, "strategy: failed to load active rules: %v", err)
+	}
+
+	if device != nil && device.UDID != "" {
+		var candidate StrategyRules
+		err := db.Get().Where("rollout_percent > 0 AND is_active = ?", false).First(&candidate).Error
+		if err == nil {
+			bucket := strategyRolloutBucket(candidate.Version, device.UDID)
+			if bucket < candidate.RolloutPercent {
+				return &strategyAssignment{Rules: &candidate, Cohort: strategyCohortRollout}
+			}
+			if bucket < min(2*candidate.RolloutPercent, 100) {
+				return &strategyAssignment{Rules: active, Cohort: strategyCohortControl}
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Warnf(ctx, "strategy: failed to load rollout candidate: %v", err)
+		}
+	}
+
+	return &strategyAssignment{Rules: active, Cohort: strategyCohortDefault}
+}
+
+// loadCohortRules 返回设备所在显式分组及其固定版本；不在分组或版本已删除时返回 nil
+func loadCohortRules(ctx context.Context, deviceID uint64) (*StrategyRules, string) {
+	var member StrategyCohortDevice
+	if err := db.Get().Where(&StrategyCohortDevice{DeviceID: deviceID}).First(&member).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Warnf(ctx, "strategy: failed to load cohort membership for device %d: %v", deviceID, err)
+		}
+		return nil, ""
+	}
+	var cohort StrategyCohort
+	if err := db.Get().First(&cohort, member.CohortID).Error; err != nil {
+		return nil, ""
+	}
+	var rules StrategyRules
+	if err := db.Get().Where(&StrategyRules{Version: cohort.Version}).First(&rules).Error; err != nil {
+		log.Warnf(ctx, "strategy: cohort %s pins missing version %s", cohort.Name, cohort.Version)
+		return nil, ""
+	}
+	return &rules, cohort.Name
+}
+
+// StrategyOutcomeStats 某版本某分组在时间窗内的遥测结果
+type StrategyOutcomeStats struct {
+	Version         string  `json:"version"`
+	Cohort          string  `json:"cohort"`
+	Devices         int64   `json:"devices"`
+	Connections     int64   `json:"connections"`     // connection 事件数
+	Reported        int64   `json:"reported"`        // 其中带 outcome.success 的
+	Successes       int64   `json:"successes"`       // 其中 success = true 的
+	SuccessRate     float64 `json:"successRate"`     // successes / reported，无数据为 0
+	Ratings         int64   `json:"ratings"`         // 带满意度评分的事件数
+	AvgSatisfaction float64 `json:"avgSatisfaction"` // 1-5，无评分为 0
+}
+
+// queryStrategyOutcomes 按 (版本, 分组) 汇总 sinceMs（Unix 毫秒）之后的遥测事件
+func queryStrategyOutcomes(versions []string, sinceMs int64) ([]StrategyOutcomeStats, error) {
+	var rows []StrategyOutcomeStats
+	err := db.Get().Model(&TelemetryEvent{}).
+		Select(`rules_version AS version, cohort,
+			COUNT(DISTINCT device_id) AS devices,
+			SUM(CASE WHEN event_type = 'connection' THEN 1 ELSE 0 END) AS connections,
+			SUM(CASE WHEN event_type = 'connection' AND success IS NOT NULL THEN 1 ELSE 0 END) AS reported,
+			SUM(CASE WHEN event_type = 'connection' AND success = ? THEN 1 ELSE 0 END) AS successes,
+			COUNT(satisfaction) AS ratings,
+			COALESCE(AVG(satisfaction), 0) AS avg_satisfaction`, true).
+		Where("rules_version IN ? AND timestamp >= ?", versions, sinceMs).
+		Group("rules_version, cohort").
+		Order("rules_version, cohort").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		if rows[i].Reported > 0 {
+			rows[i].SuccessRate = float64(rows[i].Successes) / float64(rows[i].Reported)
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,236 @@
+package center
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+func TestStrategyRolloutBucket(t *testing.T) {
+	assert.Equal(t, strategyRolloutBucket("2026.01.23.1", "udid-a"), strategyRolloutBucket("2026.01.23.1", "udid-a"))
+
+	// Roughly uniform: a 10% rollout should land near 10% of devices.
+	in := 0
+	for i := 0; i < 10000; i++ {
+		b := strategyRolloutBucket("2026.01.23.1", fmt.Sprintf("udid-%d", i))
+		require.True(t, b >= 0 && b < 100)
+		if b < 10 {
+			in++
+		}
+	}
+	assert.InDelta(t, 1000, in, 150)
+
+	// A new candidate reshuffles: the same devices do not always land first.
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		udid := fmt.Sprintf("udid-%d", i)
+		if strategyRolloutBucket("2026.01.23.1", udid) != strategyRolloutBucket("2026.01.24.1", udid) {
+			moved++
+		}
+	}
+	assert.Greater(t, moved, 900)
+}
+
+func TestStrategyAssignmentETag(t *testing.T) {
+	rules := &StrategyRules{Version: "2026.01.23.1"}
+	def := &strategyAssignment{Rules: rules, Cohort: strategyCohortDefault}
+	assert.Equal(t, `"2026.01.23.1"`, def.ETag(), "default cohort keeps the plain version tag")
+	assert.Equal(t, `"default"`, (&strategyAssignment{Cohort: strategyCohortDefault}).ETag())
+
+	tags := map[string]bool{def.ETag(): true}
+	for _, cohort := range []string{strategyCohortRollout, strategyCohortControl, "beta-testers"} {
+		tags[(&strategyAssignment{Rules: rules, Cohort: cohort}).ETag()] = true
+	}
+	assert.Len(t, tags, 4, "same version, different cohorts, different ETags")
+}
+
+func validTestRulesConfig() AdminStrategyRulesConfig {
+	rate := float32(0.3)
+	return AdminStrategyRulesConfig{
+		Rules: []AdminRuleCondition{
+			{
+				ID:       "telecom-peak",
+				Priority: 10,
+				Match:    RuleMatch{Carrier: []string{"china_telecom"}, NetworkType: []string{"wifi"}, HistoryFailureRateGt: &rate},
+				Action:   RuleAction{ProtocolChain: []string{"k2:tcp_ws", "k2:quic_bbr"}, Congestion: "bbr", TimeoutMs: 3000},
+			},
+			{
+				ID:       "cellular",
+				Priority: 20,
+				Match:    RuleMatch{NetworkType: []string{"cellular"}},
+				Action:   RuleAction{ProtocolChain: []string{"k2:quic_brutal"}},
+			},
+		},
+		Protocols: map[string]any{"k2:quic_brutal": map[string]any{"up_mbps": 50}},
+		Default:   AdminDefaultConfig{ProtocolChain: []string{"k2:quic_bbr", "k2:tcp_ws"}, TimeoutMs: 5000},
+	}
+}
+
+func TestValidateRulesConfig(t *testing.T) {
+	ok := validTestRulesConfig()
+	require.NoError(t, validateRulesConfig(&ok))
+
+	bad := map[string]func(*AdminStrategyRulesConfig){
+		"unknown protocol param": func(c *AdminStrategyRulesConfig) { c.Protocols["k2:wireguard"] = map[string]any{} },
+		"non-object params":      func(c *AdminStrategyRulesConfig) { c.Protocols["k2:quic_bbr"] = 1 },
+		"duplicate priority":     func(c *AdminStrategyRulesConfig) { c.Rules[1].Priority = 10 },
+		"network type":           func(c *AdminStrategyRulesConfig) { c.Rules[1].Match.NetworkType = []string{"5g"} },
+		"congestion":             func(c *AdminStrategyRulesConfig) { c.Rules[1].Action.Congestion = "cubic" },
+		"failure rate":           func(c *AdminStrategyRulesConfig) { r := float32(1.5); c.Rules[0].Match.HistoryFailureRateGt = &r },
+		"long id":                func(c *AdminStrategyRulesConfig) { c.Rules[0].ID = string(bytes.Repeat([]byte("x"), 65)) },
+		"default chain":          func(c *AdminStrategyRulesConfig) { c.Default.ProtocolChain = []string{"k2:quic_bbr", "k2:quic_bbr"} },
+		"duplicate in chain": func(c *AdminStrategyRulesConfig) {
+			c.Rules[1].Action.ProtocolChain = []string{"k2:tcp_ws", "k2:tcp_ws"}
+		},
+	}
+	for name, mutate := range bad {
+		config := validTestRulesConfig()
+		mutate(&config)
+		assert.Error(t, validateRulesConfig(&config), name)
+	}
+}
+
+func createTestStrategyRules(t *testing.T, version string, rolloutPercent int) *StrategyRules {
+	t.Helper()
+	rules := &StrategyRules{
+		Version:        version,
+		Content:        `{"rules":[],"protocols":{},"default":{"protocol_chain":["k2:quic_bbr"],"timeout_ms":5000}}`,
+		IsActive:       BoolPtr(false),
+		RolloutPercent: rolloutPercent,
+	}
+	db.Get().Unscoped().Where(&StrategyRules{Version: version}).Delete(&StrategyRules{})
+	require.NoError(t, db.Get().Create(rules).Error)
+	t.Cleanup(func() { db.Get().Unscoped().Delete(rules) })
+	return rules
+}
+
+// TestResolveStrategyAssignment: rollout and control buckets follow the hash,
+// and an explicit cohort overrides both.
+func TestResolveStrategyAssignment(t *testing.T) {
+	skipIfNoConfig(t)
+	ctx := context.Background()
+	candidate := createTestStrategyRules(t, "1999.01.02.1", 30)
+	pinned := createTestStrategyRules(t, "1999.01.02.2", 0)
+
+	seen := map[string]int{}
+	for i := 0; i < 200; i++ {
+		device := &Device{ID: uint64(900000000 + i), UDID: fmt.Sprintf("rollout-test-%d", i)}
+		a := resolveStrategyAssignment(ctx, device)
+		bucket := strategyRolloutBucket(candidate.Version, device.UDID)
+		switch {
+		case bucket < 30:
+			assert.Equal(t, strategyCohortRollout, a.Cohort)
+			assert.Equal(t, candidate.Version, a.Version())
+		case bucket < 60:
+			assert.Equal(t, strategyCohortControl, a.Cohort)
+			assert.NotEqual(t, candidate.Version, a.Version())
+		default:
+			assert.Equal(t, strategyCohortDefault, a.Cohort)
+		}
+		seen[a.Cohort]++
+	}
+	assert.Len(t, seen, 3)
+
+	cohort := &StrategyCohort{Name: "rollout-test-pinned", Version: pinned.Version}
+	db.Get().Where(&StrategyCohort{Name: cohort.Name}).Delete(&StrategyCohort{})
+	require.NoError(t, db.Get().Create(cohort).Error)
+	member := &StrategyCohortDevice{CohortID: cohort.ID, DeviceID: 900000000}
+	require.NoError(t, db.Get().Create(member).Error)
+	t.Cleanup(func() {
+		db.Get().Delete(member)
+		db.Get().Delete(cohort)
+	})
+
+	a := resolveStrategyAssignment(ctx, &Device{ID: 900000000, UDID: "rollout-test-0"})
+	assert.Equal(t, cohort.Name, a.Cohort)
+	assert.Equal(t, pinned.Version, a.Version())
+
+	// No device (unauthenticated callers) never enters the rollout.
+	assert.NotEqual(t, candidate.Version, resolveStrategyAssignment(ctx, nil).Version())
+}
+
+// TestAdminStrategyRollout: starting one candidate stops the other, and
+// out-of-range percentages are rejected.
+func TestAdminStrategyRollout(t *testing.T) {
+	skipIfNoConfig(t)
+	first := createTestStrategyRules(t, "1999.01.03.1", 20)
+	second := createTestStrategyRules(t, "1999.01.03.2", 0)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/app/strategy/rules/:version/rollout", api_admin_strategy_rollout)
+	put := func(version, body string) Response[DataAny] {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/app/strategy/rules/"+version+"/rollout", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return decodeResp(t, w)
+	}
+
+	assert.EqualValues(t, ErrorNone, put(second.Version, `{"percent":50}`).Code)
+	require.NoError(t, db.Get().First(first, first.ID).Error)
+	require.NoError(t, db.Get().First(second, second.ID).Error)
+	assert.Equal(t, 0, first.RolloutPercent)
+	assert.Equal(t, 50, second.RolloutPercent)
+
+	assert.EqualValues(t, ErrorInvalidArgument, put(second.Version, `{"percent":101}`).Code)
+	assert.EqualValues(t, ErrorInvalidArgument, put(second.Version, `{}`).Code)
+	assert.EqualValues(t, ErrorNotFound, put("1999.01.03.9", `{"percent":10}`).Code)
+
+	assert.EqualValues(t, ErrorNone, put(second.Version, `{"percent":0}`).Code)
+	require.NoError(t, db.Get().First(second, second.ID).Error)
+	assert.Equal(t, 0, second.RolloutPercent)
+}
+
+func TestQueryStrategyOutcomes(t *testing.T) {
+	skipIfNoConfig(t)
+	user := CreateTestUser(t)
+	device := CreateTestDevice(t, user.ID, "outcomes-test-"+user.UUID)
+	now := time.Now().UnixMilli()
+
+	four := 4
+	two := 2
+	events := []TelemetryEvent{
+		{EventType: "connection", RulesVersion: "1999.01.04.1", Cohort: strategyCohortRollout, Success: BoolPtr(true)},
+		{EventType: "connection", RulesVersion: "1999.01.04.1", Cohort: strategyCohortRollout, Success: BoolPtr(true)},
+		{EventType: "connection", RulesVersion: "1999.01.04.1", Cohort: strategyCohortRollout, Success: BoolPtr(false)},
+		{EventType: "connection", RulesVersion: "1999.01.04.1", Cohort: strategyCohortRollout},
+		{EventType: "feedback", RulesVersion: "1999.01.04.1", Cohort: strategyCohortRollout, Satisfaction: &four},
+		{EventType: "feedback", RulesVersion: "1999.01.04.1", Cohort: strategyCohortRollout, Satisfaction: &two},
+		{EventType: "connection", RulesVersion: "1999.01.04.0", Cohort: strategyCohortControl, Success: BoolPtr(false)},
+	}
+	for i := range events {
+		events[i].EventID = fmt.Sprintf("outcomes-test-%s-%d", user.UUID, i)
+		events[i].Timestamp = now
+		events[i].DeviceID = device.ID
+	}
+	require.NoError(t, db.Get().Create(&events).Error)
+	t.Cleanup(func() { db.Get().Where("device_id = ?", device.ID).Delete(&TelemetryEvent{}) })
+
+	groups, err := queryStrategyOutcomes([]string{"1999.01.04.1", "1999.01.04.0"}, now-1000)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+
+	control, rollout := groups[0], groups[1]
+	assert.Equal(t, strategyCohortControl, control.Cohort)
+	assert.Equal(t, int64(1), control.Connections)
+	assert.Equal(t, 0.0, control.SuccessRate)
+
+	assert.Equal(t, "1999.01.04.1", rollout.Version)
+	assert.Equal(t, int64(1), rollout.Devices)
+	assert.Equal(t, int64(4), rollout.Connections)
+	assert.Equal(t, int64(3), rollout.Reported)
+	assert.Equal(t, int64(2), rollout.Successes)
+	assert.InDelta(t, 2.0/3.0, rollout.SuccessRate, 1e-9)
+	assert.Equal(t, int64(2), rollout.Ratings)
+	assert.InDelta(t, 3.0, rollout.AvgSatisfaction, 1e-9)
+}
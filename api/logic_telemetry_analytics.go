@@ -0,0 +1,276 @@
+package center
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	db "github.com/wordgate/qtoolkit/db"
+	"gorm.io/gorm"
+)
+
+// ========================= 遥测分析 =========================
+//
+// 入库时把分析维度从事件 JSON 抽到 TelemetryEvent 的列上，客户端约定的键：
+//   decision.protocol_chain []string  实际尝试的协议链（回退 decision.protocol）
+//   decision.node           string    连接的节点（域名或 IP）
+//   context.country         string    ISO 3166-1 alpha-2，缺省按请求 IP 查
+//   context.carrier         string    运营商（与规则 match.carrier 同一取值）
+//   context.asn             number    缺省按请求 IP 查
+//   outcome.success         bool
+//   outcome.connect_ms      number    建连耗时
+//   outcome.failure_reason  string    失败原因码，仅 success=false 时记录
+// TaskTypeTelemetryRollup 再把 connection 事件按日汇总到 rollup 表。
+
+// telemetryEventRetentionDays 原始事件保留期：rollup 只重算今天与昨天，
+// 明细另供规则灰度对比（api_admin_strategy_compare）按最长 30 天回看
+const telemetryEventRetentionDays = 30
+
+// telemetryRateLimitRetentionDays 限流计数按小时分桶，只读当前小时
+const telemetryRateLimitRetentionDays = 2
+
+// telemetryMaxConnectMs 超过即视为客户端时钟/计时异常，不计入耗时统计
+const telemetryMaxConnectMs = 120000
+
+// telemetryDimensionAll 全量汇总行的维度名（Value 为空）
+const telemetryDimensionAll = "all"
+
+// telemetryDimensionColumns 可切片的维度及其在 telemetry_events 上的列（all 不分组）
+var telemetryDimensionColumns = map[string]string{
+	telemetryDimensionAll: "",
+	"protocol_chain":      "protocol_chain",
+	"country":             "country",
+	"carrier":             "carrier",
+	"asn":                 "asn",
+	"app_version":         "app_version",
+	"node":                "node",
+	"rules_version":       "rules_version",
+}
+
+// applyTelemetryDims 填充事件的分析维度列。fallbackCountry/fallbackASN 来自请求 IP，
+// 仅在客户端未上报时使用（客户端通常在隧道建立后才上报，请求 IP 可能是节点出口）。
+func applyTelemetryDims(row *TelemetryEvent, evt *TelemetryEventDTO, fallbackCountry string, fallbackASN uint) {
+	if chain, ok := evt.Decision["protocol_chain"].([]any); ok {
+		parts := make([]string, 0, len(chain))
+		for _, p := range chain {
+			if s, ok := p.(string); ok && s != "" {
+				parts = append(parts, s)
+			}
+		}
+		row.ProtocolChain = clipTelemetryString(strings.Join(parts, ","), 128)
+	}
+	if row.ProtocolChain == "" {
+		row.ProtocolChain = telemetryString(evt.Decision, "protocol", 128)
+	}
+	row.Node = telemetryString(evt.Decision, "node", 64)
+	row.Carrier = telemetryString(evt.Context, "carrier", 32)
+
+	row.Country = fallbackCountry
+	if cc, _ := evt.Context["country"].(string); len(cc) == 2 {
+		row.Country = strings.ToLower(cc)
+	}
+	row.ASN = fallbackASN
+	if n, ok := evt.Context["asn"].(float64); ok && n > 0 && n < math.MaxUint32 {
+		row.ASN = uint(n)
+	}
+
+	if n, ok := evt.Outcome["connect_ms"].(float64); ok && n >= 0 && n <= telemetryMaxConnectMs {
+		ms := int(n)
+		row.ConnectMs = &ms
+	}
+	if row.Success != nil && !*row.Success {
+		row.FailureReason = telemetryString(evt.Outcome, "failure_reason", 64)
+	}
+}
+
+// telemetryString 取 JSON 对象中的字符串字段并截断到列宽
+func telemetryString(m map[string]any, key string, max int) string {
+	s, _ := m[key].(string)
+	return clipTelemetryString(strings.TrimSpace(s), max)
+}
+
+func clipTelemetryString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	// 按字节截断后去掉被切开的 UTF-8 尾字节
+	return strings.ToValidUTF8(s[:max], "")
+}
+
+// rebuildTelemetryRollups 整日重算 day（UTC，YYYY-MM-DD）的 rollup：先删后插，
+// 同一事务内完成，重复执行结果相同，迟到的事件在下次重算时补进来。
+func rebuildTelemetryRollups(ctx context.Context, day string) error {
+	start, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return fmt.Errorf("bad rollup day %q: %w", day, err)
+	}
+	end := start.AddDate(0, 0, 1)
+	base := func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&TelemetryEvent{}).
+			Where("event_type = ? AND created_at >= ? AND created_at < ?", "connection", start, end)
+	}
+
+	return db.Get().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("day = ?", day).Delete(&TelemetryRollup{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("day = ?", day).Delete(&TelemetryFailureRollup{}).Error; err != nil {
+			return err
+		}
+
+		for dim, col := range telemetryDimensionColumns {
+			value, groups, failureGroups := col, col, col+", failure_reason"
+			if col == "" {
+				value, groups, failureGroups = "''", "", "failure_reason"
+			}
+
+			var rows []TelemetryRollup
+			q := base(tx).Select(fmt.Sprintf(`%s AS value,
+				COUNT(*) AS attempts,
+				COALESCE(SUM(CASE WHEN success = ? THEN 1 ELSE 0 END), 0) AS successes,
+				COALESCE(SUM(CASE WHEN success = ? THEN 1 ELSE 0 END), 0) AS failures,
+				COUNT(DISTINCT device_id) AS devices,
+				COALESCE(SUM(connect_ms), 0) AS connect_ms_sum,
+				COUNT(connect_ms) AS connect_ms_count,
+				COALESCE(SUM(CASE WHEN connect_ms < 1000 THEN 1 ELSE 0 END), 0) AS connect_under1s,
+				COALESCE(SUM(CASE WHEN connect_ms >= 1000 AND connect_ms < 3000 THEN 1 ELSE 0 END), 0) AS connect_under3s,
+				COALESCE(SUM(CASE WHEN connect_ms >= 3000 THEN 1 ELSE 0 END), 0) AS connect_over3s`, value), true, false)
+			if groups != "" {
+				q = q.Group(groups)
+			}
+			if err := q.Scan(&rows).Error; err != nil {
+				return fmt.Errorf("rollup %s: %w", dim, err)
+			}
+			kept := rows[:0]
+			for _, r := range rows {
+				// 不分组的聚合在无事件时也会返回一行全 0
+				if r.Attempts == 0 {
+					continue
+				}
+				r.Day = day
+				r.Dimension = dim
+				kept = append(kept, r)
+			}
+			if len(kept) > 0 {
+				if err := tx.CreateInBatches(&kept, 500).Error; err != nil {
+					return fmt.Errorf("insert rollup %s: %w", dim, err)
+				}
+			}
+
+			var failures []TelemetryFailureRollup
+			if err := base(tx).
+				Select(fmt.Sprintf("%s AS value, failure_reason AS reason, COUNT(*) AS count", value)).
+				Where("success = ?", false).
+				Group(failureGroups).
+				Scan(&failures).Error; err != nil {
+				return fmt.Errorf("failure rollup %s: %w", dim, err)
+			}
+			for i := range failures {
+				failures[i].Day = day
+				failures[i].Dimension = dim
+			}
+			if len(failures) > 0 {
+				if err := tx.CreateInBatches(&failures, 500).Error; err != nil {
+					return fmt.Errorf("insert failure rollup %s: %w", dim, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// TelemetryConnectStats 一组 rollup 行的合计
+type TelemetryConnectStats struct {
+	Attempts       int64   `json:"attempts"`
+	Successes      int64   `json:"successes"`
+	Failures       int64   `json:"failures"`
+	SuccessRate    float64 `json:"successRate"` // successes / (successes + failures)，无结果为 0
+	DeviceDays     int64   `json:"deviceDays"`  // 每日不同设备数之和
+	AvgConnectMs   float64 `json:"avgConnectMs"`
+	ConnectUnder1s int64   `json:"connectUnder1s"`
+	ConnectUnder3s int64   `json:"connectUnder3s"` // [1s, 3s)
+	ConnectOver3s  int64   `json:"connectOver3s"`
+}
+
+// telemetryStatsRow rollup 汇总查询的扫描目标
+type telemetryStatsRow struct {
+	Bucket         string // day 或维度值
+	Attempts       int64
+	Successes      int64
+	Failures       int64
+	Devices        int64
+	ConnectMsSum   int64
+	ConnectMsCount int64
+	ConnectUnder1s int64
+	ConnectUnder3s int64
+	ConnectOver3s  int64
+}
+
+func (r *telemetryStatsRow) stats() TelemetryConnectStats {
+	s := TelemetryConnectStats{
+		Attempts:       r.Attempts,
+		Successes:      r.Successes,
+		Failures:       r.Failures,
+		DeviceDays:     r.Devices,
+		ConnectUnder1s: r.ConnectUnder1s,
+		ConnectUnder3s: r.ConnectUnder3s,
+		ConnectOver3s:  r.ConnectOver3s,
+	}
+	if done := r.Successes + r.Failures; done > 0 {
+		s.SuccessRate = float64(r.Successes) / float64(done)
+	}
+	if r.ConnectMsCount > 0 {
+		s.AvgConnectMs = float64(r.ConnectMsSum) / float64(r.ConnectMsCount)
+	}
+	return s
+}
+
+// queryTelemetryStats 汇总 dimension 在 since（含）之后的 rollup，按 groupBy 分组：
+// "day" 出日序列，"value" 出维度值排行（按尝试次数降序）
+func queryTelemetryStats(dimension, since, groupBy string, limit int) ([]telemetryStatsRow, error) {
+	var rows []telemetryStatsRow
+	q := db.Get().Model(&TelemetryRollup{}).
+		Select(groupBy+` AS bucket,
+			SUM(attempts) AS attempts,
+			SUM(successes) AS successes,
+			SUM(failures) AS failures,
+			SUM(devices) AS devices,
+			SUM(connect_ms_sum) AS connect_ms_sum,
+			SUM(connect_ms_count) AS connect_ms_count,
+			SUM(connect_under1s) AS connect_under1s,
+			SUM(connect_under3s) AS connect_under3s,
+			SUM(connect_over3s) AS connect_over3s`).
+		Where("dimension = ? AND day >= ?", dimension, since).
+		Group(groupBy)
+	if groupBy == "day" {
+		q = q.Order("day")
+	} else {
+		q = q.Order("attempts DESC")
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err := q.Scan(&rows).Error
+	return rows, err
+}
+
+// TelemetryFailureRow 失败原因排行
+type TelemetryFailureRow struct {
+	Reason string `json:"reason"` // 空 = 未上报原因
+	Count  int64  `json:"count"`
+}
+
+// queryTelemetryFailures 失败原因排行；value 为 nil 时汇总该维度全部取值
+func queryTelemetryFailures(dimension string, value *string, since string, limit int) ([]TelemetryFailureRow, error) {
+	rows := []TelemetryFailureRow{}
+	q := db.Get().Model(&TelemetryFailureRollup{}).
+		Select("reason, SUM(count) AS count").
+		Where("dimension = ? AND day >= ?", dimension, since)
+	if value != nil {
+		q = q.Where("value = ?", *value)
+	}
+	err := q.Group("reason").Order("count DESC").Limit(limit).Scan(&rows).Error
+	return rows, err
+}
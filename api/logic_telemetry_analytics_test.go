@@ -0,0 +1,166 @@
+package center
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+func TestApplyTelemetryDims(t *testing.T) {
+	evt := &TelemetryEventDTO{
+		Context:  map[string]any{"country": "JP", "carrier": "china_telecom", "asn": float64(4134)},
+		Decision: map[string]any{"protocol_chain": []any{"k2:quic_bbr", "k2:tcp_ws"}, "node": "jp1.example.com"},
+		Outcome:  map[string]any{"success": false, "connect_ms": float64(1234), "failure_reason": "handshake_timeout"},
+	}
+	row := TelemetryEvent{Success: BoolPtr(false)}
+	applyTelemetryDims(&row, evt, "cn", 9808)
+	assert.Equal(t, "k2:quic_bbr,k2:tcp_ws", row.ProtocolChain)
+	assert.Equal(t, "jp1.example.com", row.Node)
+	assert.Equal(t, "china_telecom", row.Carrier)
+	assert.Equal(t, "jp", row.Country, "client-reported country wins")
+	assert.Equal(t, uint(4134), row.ASN)
+	require.NotNil(t, row.ConnectMs)
+	assert.Equal(t, 1234, *row.ConnectMs)
+	assert.Equal(t, "handshake_timeout", row.FailureReason)
+
+	// Missing / malformed fields fall back or stay empty.
+	evt = &TelemetryEventDTO{
+		Context:  map[string]any{"country": "Japan"},
+		Decision: map[string]any{"protocol": "k2:tcp_ws", "node": strings.Repeat("n", 100)},
+		Outcome:  map[string]any{"success": true, "connect_ms": float64(10 * 60 * 1000), "failure_reason": "ignored"},
+	}
+	row = TelemetryEvent{Success: BoolPtr(true)}
+	applyTelemetryDims(&row, evt, "cn", 9808)
+	assert.Equal(t, "k2:tcp_ws", row.ProtocolChain)
+	assert.Len(t, row.Node, 64)
+	assert.Equal(t, "cn", row.Country)
+	assert.Equal(t, uint(9808), row.ASN)
+	assert.Nil(t, row.ConnectMs, "implausible durations are dropped")
+	assert.Empty(t, row.FailureReason, "reasons only recorded on failures")
+}
+
+// TestRebuildTelemetryRollups seeds one day of connection events (in 1999, so
+// no real rollup day is touched) and checks totals, a slice, the failure
+// reasons, and that rebuilding twice does not double count.
+func TestRebuildTelemetryRollups(t *testing.T) {
+	skipIfNoConfig(t)
+	const day = "1999-07-01"
+	purge := func() {
+		db.Get().Where("day = ?", day).Delete(&TelemetryRollup{})
+		db.Get().Where("day = ?", day).Delete(&TelemetryFailureRollup{})
+	}
+	purge()
+	t.Cleanup(purge)
+
+	user := CreateTestUser(t)
+	device := CreateTestDevice(t, user.ID, "rollup-test-"+user.UUID)
+	at := time.Date(1999, 7, 1, 12, 0, 0, 0, time.UTC)
+	ms := func(n int) *int { return &n }
+	events := []TelemetryEvent{
+		{EventType: "connection", ProtocolChain: "k2:quic_bbr", Success: BoolPtr(true), ConnectMs: ms(400)},
+		{EventType: "connection", ProtocolChain: "k2:quic_bbr", Success: BoolPtr(true), ConnectMs: ms(2000)},
+		{EventType: "connection", ProtocolChain: "k2:quic_bbr", Success: BoolPtr(false), FailureReason: "handshake_timeout"},
+		{EventType: "connection", ProtocolChain: "k2:tcp_ws", Success: BoolPtr(false), FailureReason: "handshake_timeout", ConnectMs: ms(5000)},
+		{EventType: "session", ProtocolChain: "k2:tcp_ws"},
+	}
+	for i := range events {
+		events[i].EventID = fmt.Sprintf("rollup-test-%s-%d", user.UUID, i)
+		events[i].DeviceID = device.ID
+		events[i].CreatedAt = at
+	}
+	require.NoError(t, db.Get().Create(&events).Error)
+	t.Cleanup(func() { db.Get().Where("device_id = ?", device.ID).Delete(&TelemetryEvent{}) })
+
+	ctx := context.Background()
+	require.NoError(t, rebuildTelemetryRollups(ctx, day))
+	require.NoError(t, rebuildTelemetryRollups(ctx, day))
+
+	var all TelemetryRollup
+	require.NoError(t, db.Get().Where("day = ? AND dimension = ?", day, telemetryDimensionAll).First(&all).Error)
+	assert.Equal(t, int64(4), all.Attempts, "session events are not connections")
+	assert.Equal(t, int64(2), all.Successes)
+	assert.Equal(t, int64(2), all.Failures)
+	assert.Equal(t, int64(1), all.Devices)
+	assert.Equal(t, int64(7400), all.ConnectMsSum)
+	assert.Equal(t, int64(3), all.ConnectMsCount)
+	assert.Equal(t, []int64{1, 1, 1}, []int64{all.ConnectUnder1s, all.ConnectUnder3s, all.ConnectOver3s})
+
+	var bbr TelemetryRollup
+	require.NoError(t, db.Get().Where("day = ? AND dimension = ? AND value = ?", day, "protocol_chain", "k2:quic_bbr").First(&bbr).Error)
+	assert.Equal(t, int64(3), bbr.Attempts)
+	assert.Equal(t, int64(2), bbr.Successes)
+
+	var reasons []TelemetryFailureRollup
+	require.NoError(t, db.Get().Where("day = ? AND dimension = ?", day, telemetryDimensionAll).Find(&reasons).Error)
+	require.Len(t, reasons, 1)
+	assert.Equal(t, "handshake_timeout", reasons[0].Reason)
+	assert.Equal(t, int64(2), reasons[0].Count)
+}
+
+func TestAdminTelemetryBreakdown_BadParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/app/telemetry/breakdown", api_admin_telemetry_breakdown)
+	r.GET("/app/telemetry/failures", api_admin_telemetry_failures)
+
+	for _, path := range []string{
+		"/app/telemetry/breakdown",
+		"/app/telemetry/breakdown?dimension=all",
+		"/app/telemetry/breakdown?dimension=user_id",
+		"/app/telemetry/breakdown?dimension=node&range=1y",
+		"/app/telemetry/failures?dimension=device_id",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.EqualValues(t, ErrorInvalidArgument, decodeResp(t, w).Code, path)
+	}
+}
+
+// TestAdminTelemetryBreakdown reads seeded rollups for today and checks the
+// per-value sums across days.
+func TestAdminTelemetryBreakdown(t *testing.T) {
+	skipIfNoConfig(t)
+	today := time.Now().UTC().Format("2006-01-02")
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	const node = "telemetry-breakdown-test.example.com"
+	purge := func() {
+		db.Get().Where("dimension = ? AND value = ?", "node", node).Delete(&TelemetryRollup{})
+	}
+	purge()
+	t.Cleanup(purge)
+	require.NoError(t, db.Get().Create(&[]TelemetryRollup{
+		{Day: today, Dimension: "node", Value: node, Attempts: 10, Successes: 8, Failures: 2, Devices: 3, ConnectMsSum: 5000, ConnectMsCount: 10},
+		{Day: yesterday, Dimension: "node", Value: node, Attempts: 10, Successes: 4, Failures: 4, Devices: 2, ConnectMsSum: 15000, ConnectMsCount: 10},
+	}).Error)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/app/telemetry/breakdown", api_admin_telemetry_breakdown)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/telemetry/breakdown?dimension=node&range=7d&limit=500", nil))
+	var resp Response[TelemetryBreakdownResponse]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.EqualValues(t, ErrorNone, resp.Code)
+
+	var found *TelemetryBreakdownRow
+	for i, row := range resp.Data.Rows {
+		if row.Value == node {
+			found = &resp.Data.Rows[i]
+		}
+	}
+	require.NotNil(t, found)
+	assert.Equal(t, int64(20), found.Attempts)
+	assert.InDelta(t, 12.0/18.0, found.SuccessRate, 1e-9)
+	assert.Equal(t, int64(5), found.DeviceDays)
+	assert.InDelta(t, 1000.0, found.AvgConnectMs, 1e-9)
+}
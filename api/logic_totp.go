@@ -0,0 +1,256 @@
+package center
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// =====================================================================
+// TOTP 两步验证（RFC 6238）
+// =====================================================================
+//
+// - 任何用户可选启用；拥有管理角色（IsAdmin 或 RoleUser 以外的角色）的账号必须启用，
+//   否则 AdminRequired / RoleRequired 拒绝访问
+// - 在 Web 验证码登录、密码登录（App/Web）中，凭证通过后再校验 totpCode
+// - 恢复码一次性使用，只存哈希
+// - 失败计数与密码登录共用 PasswordFailedAttempts，达到上限一起锁定
+//
+// =====================================================================
+
+const (
+	totpPeriod      = 30 // 秒
+	totpDigits      = 6
+	totpSkew        = 1  // 允许前后各 1 个时间步的时钟偏差
+	totpSecretBytes = 20 // 160 bit，RFC 4226 推荐长度
+
+	recoveryCodeCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// recoveryCodeAlphabet 去掉易混淆的 0/1/i/l/o
+const recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// totpGenerateSecret 生成新的 base32 密钥
+func totpGenerateSecret() (string, error) {
+	buf := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// totpCodeAt 计算指定时间步的验证码
+func totpCodeAt(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("decode totp secret: %w", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod), nil
+}
+
+// totpStep 返回时间对应的时间步
+func totpStep(now time.Time) int64 {
+	return now.Unix() / totpPeriod
+}
+
+// totpMatch 校验验证码，返回匹配的时间步。
+// 只接受大于 lastStep 的时间步，同一验证码不能用两次。
+func totpMatch(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := totpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totpCodeAt(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpProvisioningURI 生成认证器 App 扫码用的 otpauth:// 地址
+func totpProvisioningURI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// normalizeRecoveryCode 统一大小写并去掉分隔符，用户输入 "ABCD EFGH" 也能匹配
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// generateRecoveryCodes 生成一组新的恢复码，返回明文（仅展示一次）和待存储的哈希 JSON
+func generateRecoveryCodes(ctx context.Context) ([]string, string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	buf := make([]byte, 8)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", err
+		}
+		var sb strings.Builder
+		for j, b := range buf {
+			if j == 4 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+		}
+		codes[i] = sb.String()
+		hashes[i] = secretHashIt(ctx, []byte(normalizeRecoveryCode(codes[i])))
+	}
+	raw, err := json.Marshal(hashes)
+	if err != nil {
+		return nil, "", err
+	}
+	return codes, string(raw), nil
+}
+
+// recoveryCodeHashes 解析用户剩余恢复码的哈希
+func recoveryCodeHashes(user *User) []string {
+	var hashes []string
+	if user.TOTPRecoveryCodes != "" {
+		_ = json.Unmarshal([]byte(user.TOTPRecoveryCodes), &hashes)
+	}
+	return hashes
+}
+
+// consumeRecoveryCode 匹配则从用户的恢复码中移除（调用方负责保存）
+func consumeRecoveryCode(ctx context.Context, user *User, code string) bool {
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return false
+	}
+	hash := secretHashIt(ctx, []byte(normalized))
+	hashes := recoveryCodeHashes(user)
+	for i, h := range hashes {
+		if hmac.Equal([]byte(h), []byte(hash)) {
+			remaining := append(hashes[:i:i], hashes[i+1:]...)
+			raw, _ := json.Marshal(remaining)
+			user.TOTPRecoveryCodes = string(raw)
+			return true
+		}
+	}
+	return false
+}
+
+// twoFactorEnabled 用户是否已启用两步验证
+func twoFactorEnabled(user *User) bool {
+	return user.TOTPEnabledAt > 0
+}
+
+// requiresTwoFactor 是否必须启用两步验证：超级管理员或拥有 RoleUser 以外的任一角色
+func requiresTwoFactor(user *User) bool {
+	if user.IsAdmin != nil && *user.IsAdmin {
+		return true
+	}
+	return user.Roles&^RoleUser != 0
+}
+
+// checkTOTPCode 只校验 TOTP（不接受恢复码），通过时推进 TOTPLastStep（调用方负责保存）
+func checkTOTPCode(ctx context.Context, user *User, code string) bool {
+	secret, err := secretDecryptString(ctx, user.TOTPSecret)
+	if err != nil {
+		log.Errorf(ctx, "failed to decrypt totp secret for user %d: %v", user.ID, err)
+		return false
+	}
+	step, ok := totpMatch(secret, code, time.Now(), user.TOTPLastStep)
+	if ok {
+		user.TOTPLastStep = step
+	}
+	return ok
+}
+
+// saveTwoFactorState 只写两步验证和失败计数相关列，避免覆盖并发修改的其他字段
+func saveTwoFactorState(user *User) error {
+	return db.Get().Model(user).Select(
+		"TOTPSecret", "TOTPEnabledAt", "TOTPLastStep", "TOTPRecoveryCodes",
+		"PasswordFailedAttempts", "PasswordLockedUntil",
+	).Updates(user).Error
+}
+
+// verifySecondFactor 登录时的第二因素校验，凭证（密码/邮箱验证码）已通过后调用。
+// 未启用两步验证直接通过；code 可以是 TOTP 验证码或恢复码（用过即作废）。
+// 返回 rerr，调用方直接 ErrorE。
+func verifySecondFactor(ctx context.Context, user *User, code string) error {
+	if !twoFactorEnabled(user) {
+		return nil
+	}
+	if IsAccountLocked(user) {
+		log.Warnf(ctx, "second factor rejected: user %d is locked until %d", user.ID, user.PasswordLockedUntil)
+		return e(ErrorTooManyRequests, "account temporarily locked")
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return e(ErrorTwoFactorRequired, "two-factor code required")
+	}
+
+	ok := checkTOTPCode(ctx, user, code)
+	usedRecovery := false
+	if !ok {
+		ok = consumeRecoveryCode(ctx, user, code)
+		usedRecovery = ok
+	}
+	if !ok {
+		log.Warnf(ctx, "invalid two-factor code for user %d", user.ID)
+		if err := RecordFailedPasswordAttempt(ctx, user); err != nil {
+			log.Errorf(ctx, "failed to record failed attempt for user %d: %v", user.ID, err)
+		}
+		return e(ErrorInvalidTwoFactorCode, "invalid two-factor code")
+	}
+
+	user.PasswordFailedAttempts = 0
+	user.PasswordLockedUntil = 0
+	if err := saveTwoFactorState(user); err != nil {
+		log.Errorf(ctx, "failed to save two-factor state for user %d: %v", user.ID, err)
+		return e(ErrorSystemError, "login failed")
+	}
+	if usedRecovery {
+		log.Infof(ctx, "user %d signed in with a recovery code, %d left", user.ID, len(recoveryCodeHashes(user)))
+	}
+	return nil
+}
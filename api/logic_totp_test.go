@@ -0,0 +1,120 @@
+package center
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA1 test key from RFC 6238 Appendix B ("12345678901234567890").
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeAt_RFC6238Vectors(t *testing.T) {
+	// Appendix B lists 8-digit codes; the 6-digit code is the low six digits.
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		got, err := totpCodeAt(rfc6238Secret, totpStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "t=%d", unix)
+	}
+}
+
+func TestTOTPMatch(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := totpStep(now)
+	prev, _ := totpCodeAt(rfc6238Secret, step-1)
+	next, _ := totpCodeAt(rfc6238Secret, step+1)
+	stale, _ := totpCodeAt(rfc6238Secret, step-2)
+
+	got, ok := totpMatch(rfc6238Secret, "005924", now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, step, got)
+
+	_, ok = totpMatch(rfc6238Secret, " 005924 ", now, 0)
+	assert.True(t, ok, "surrounding whitespace is tolerated")
+	_, ok = totpMatch(rfc6238Secret, prev, now, 0)
+	assert.True(t, ok, "one step of clock skew is accepted")
+	_, ok = totpMatch(rfc6238Secret, next, now, 0)
+	assert.True(t, ok)
+	_, ok = totpMatch(rfc6238Secret, stale, now, 0)
+	assert.False(t, ok, "two steps old is rejected")
+
+	_, ok = totpMatch(rfc6238Secret, "005924", now, step)
+	assert.False(t, ok, "a code cannot be replayed once its step is used")
+	_, ok = totpMatch(rfc6238Secret, "00592", now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPGenerateSecret(t *testing.T) {
+	a, err := totpGenerateSecret()
+	require.NoError(t, err)
+	b, _ := totpGenerateSecret()
+	assert.NotEqual(t, a, b)
+	raw, err := totpEncoding.DecodeString(a)
+	require.NoError(t, err)
+	assert.Len(t, raw, totpSecretBytes)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := totpProvisioningURI("开途", "a+b@example.com", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/开途:a+b@example.com", u.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	assert.Equal(t, "开途", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	codes, hashes, err := generateRecoveryCodes(ctx)
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-9]{4}-[a-z2-9]{4}$`, code)
+		assert.NotContains(t, hashes, code, "only hashes are stored")
+	}
+
+	user := &User{TOTPRecoveryCodes: hashes}
+	assert.True(t, consumeRecoveryCode(ctx, user, strings.ToUpper(strings.ReplaceAll(codes[3], "-", " "))))
+	assert.Len(t, recoveryCodeHashes(user), recoveryCodeCount-1)
+	assert.False(t, consumeRecoveryCode(ctx, user, codes[3]), "a recovery code works once")
+	assert.False(t, consumeRecoveryCode(ctx, user, ""))
+	assert.True(t, consumeRecoveryCode(ctx, user, codes[0]))
+	assert.Len(t, recoveryCodeHashes(user), recoveryCodeCount-2)
+}
+
+func TestRequiresTwoFactor(t *testing.T) {
+	assert.False(t, requiresTwoFactor(&User{Roles: RoleUser}))
+	assert.False(t, requiresTwoFactor(&User{}))
+	assert.True(t, requiresTwoFactor(&User{Roles: RoleUser | RoleSupport}))
+	assert.True(t, requiresTwoFactor(&User{Roles: RoleUser, IsAdmin: BoolPtr(true)}))
+}
+
+func TestVerifySecondFactor_NotEnabled(t *testing.T) {
+	assert.NoError(t, verifySecondFactor(context.Background(), &User{}, ""))
+}
+
+func TestVerifySecondFactor_CodeRequired(t *testing.T) {
+	user := &User{TOTPEnabledAt: 1, TOTPSecret: rfc6238Secret}
+	err := verifySecondFactor(context.Background(), user, " ")
+	assert.Equal(t, e(ErrorTwoFactorRequired, "two-factor code required"), err)
+
+	user.PasswordLockedUntil = time.Now().Add(time.Minute).Unix()
+	err = verifySecondFactor(context.Background(), user, "123456")
+	assert.Equal(t, ErrorTooManyRequests, err.(rerr).code)
+}
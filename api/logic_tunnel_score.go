@@ -88,6 +88,17 @@ func shouldHideTunnelForUser(u *NodeUsage, isAdmin bool, now int64) bool {
 	return isNodeOverQuota(u) || isNodeOffline(u, now)
 }
 
+// shouldHideNodeForUser adds node-row state on top of shouldHideTunnelForUser:
+// a node drained ahead of an IP change (SlaveNode.DrainUntil) is hidden from
+// non-admins too, so clients stop picking up tunnel URLs that are about to die.
+// Callers that hold the tunnel's node should use this one.
+func shouldHideNodeForUser(n *SlaveNode, u *NodeUsage, isAdmin bool, now int64) bool {
+	if !isAdmin && n != nil && n.IsDraining(now) {
+		return true
+	}
+	return shouldHideTunnelForUser(u, isAdmin, now)
+}
+
 // buildTunnelInstanceDataFromUsage builds the scoring DTO from NodeUsage.
 // nil usage → nil (ComputeRecommendScore(nil)=0.5 neutral). TimeRatio uses the
 // node-reported billing-cycle end (Epoch). Mirrors the old buildTunnelInstanceData
@@ -122,3 +122,19 @@ func TestShouldHideTunnelForUser_AdminBypassAndOffline(t *testing.T) {
 	assert.False(t, shouldHideTunnelForUser(over, true, now), "admin sees over-quota")
 	assert.False(t, shouldHideTunnelForUser(offline, true, now), "admin sees offline")
 }
+
+// TestShouldHideNodeForUser_Draining pins the change-IP pre-drain: a drained
+// node is hidden from non-admins until DrainUntil passes; admins still see it.
+func TestShouldHideNodeForUser_Draining(t *testing.T) {
+	now := int64(1_000_000)
+	healthy := &NodeUsage{QuotaTotalBytes: 1 << 40, UsedBytes: 1 << 30, LastReportAt: now}
+	draining := &SlaveNode{DrainUntil: now + 60}
+	expired := &SlaveNode{DrainUntil: now - 1}
+
+	assert.True(t, shouldHideNodeForUser(draining, healthy, false, now), "non-admin draining hidden")
+	assert.False(t, shouldHideNodeForUser(draining, healthy, true, now), "admin sees draining")
+	assert.False(t, shouldHideNodeForUser(expired, healthy, false, now), "expired drain visible again")
+	assert.False(t, shouldHideNodeForUser(nil, nil, false, now), "nil node/usage never hidden")
+	assert.True(t, shouldHideNodeForUser(&SlaveNode{}, &NodeUsage{QuotaTotalBytes: 1, UsedBytes: 1}, false, now),
+		"usage rules still apply")
+}
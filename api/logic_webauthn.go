@@ -0,0 +1,584 @@
+package center
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wordgate/qtoolkit/redis"
+)
+
+// =====================================================================
+// WebAuthn / passkey（W3C WebAuthn Level 2）
+// =====================================================================
+//
+// - RP ID 取品牌根域（BrandConfig.RedirectRootDomain），品牌由浏览器 Origin 经
+//   BrandFromHost 确定；clientData.origin 必须在该品牌的 WebOrigins 内
+// - attestation 固定 "none"：不校验 attStmt，只取公钥
+// - 要求 discoverable credential + user verification，登录时无需先输入邮箱，
+//   UV 通过即视为已完成多因素，不再要求 TOTP
+// - challenge 存 Redis，一次性，webauthnChallengeTTL 秒过期
+//
+// =====================================================================
+
+const (
+	webauthnChallengePrefix = "webauthn:challenge:"
+	webauthnChallengeTTL    = 300 // 秒，与前端 timeout 一致
+	webauthnChallengeBytes  = 32
+
+	webauthnMaxCredentials = 10 // 每个用户最多登记的 passkey 数
+
+	webauthnPurposeRegister = "register"
+	webauthnPurposeLogin    = "login"
+)
+
+// COSE 算法标识（RFC 9053）
+const (
+	coseAlgES256 int64 = -7
+	coseAlgEdDSA int64 = -8
+	coseAlgRS256 int64 = -257
+)
+
+// authenticator data flags
+const (
+	authFlagUserPresent      = 0x01
+	authFlagUserVerified     = 0x04
+	authFlagBackupState      = 0x10
+	authFlagAttestedCredData = 0x40
+)
+
+var errWebauthnInvalid = errors.New("invalid webauthn response")
+
+// webauthnB64 WebAuthn JSON 统一使用无填充 base64url
+var webauthnB64 = base64.RawURLEncoding
+
+// webauthnDecode 解码 base64url，兼容带填充的输入
+func webauthnDecode(s string) ([]byte, error) {
+	return webauthnB64.DecodeString(strings.TrimRight(s, "="))
+}
+
+// webauthnRP 当前请求对应的 relying party
+type webauthnRP struct {
+	ID      string // RP ID，品牌根域
+	Name    string
+	Brand   Brand
+	Origins []string
+}
+
+// resolveWebauthnRP 由浏览器 Origin（经 Next.js 代理后 Host 可能是 API 域名）
+// 或请求 Host 确定品牌；都不是品牌站点时不支持 passkey。
+func resolveWebauthnRP(c *gin.Context) (*webauthnRP, bool) {
+	hosts := []string{c.Request.Host}
+	if origin := c.GetHeader("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			hosts = append([]string{u.Host}, hosts...)
+		}
+	}
+	for _, h := range hosts {
+		if b, ok := BrandFromHost(h); ok {
+			cfg := b.Config()
+			return &webauthnRP{
+				ID:      cfg.RedirectRootDomain,
+				Name:    cfg.DisplayName,
+				Brand:   b,
+				Origins: cfg.WebOrigins,
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// webauthnChallenge 一次 ceremony 的服务端状态
+type webauthnChallenge struct {
+	Purpose string `json:"purpose"`
+	Brand   Brand  `json:"brand"`
+	UserID  uint64 `json:"userId,omitempty"` // 仅登记时
+}
+
+// issueWebauthnChallenge 生成 challenge 并存入 Redis，返回 base64url
+func issueWebauthnChallenge(state webauthnChallenge) (string, error) {
+	buf := make([]byte, webauthnChallengeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	challenge := webauthnB64.EncodeToString(buf)
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	if err := redis.CacheSet(webauthnChallengePrefix+challenge, string(raw), webauthnChallengeTTL); err != nil {
+		return "", fmt.Errorf("store webauthn challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// takeWebauthnChallenge 原子地读取并删除 challenge（GETDEL），并发的两次断言
+// 只有一次能拿到；未知或过期返回 nil
+func takeWebauthnChallenge(challenge string) *webauthnChallenge {
+	key := webauthnChallengePrefix + strings.TrimRight(challenge, "=")
+	stored, err := redis.Client().GetDel(context.Background(), key).Result()
+	if err != nil {
+		return nil
+	}
+	// issueWebauthnChallenge 经 CacheSet 写入，值本身是 JSON 编码过的字符串
+	var raw string
+	if err := json.Unmarshal([]byte(stored), &raw); err != nil {
+		return nil
+	}
+	var state webauthnChallenge
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		return nil
+	}
+	return &state
+}
+
+// ===================== clientDataJSON =====================
+
+type webauthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// verifyClientData 校验 type / origin，取出 challenge 对应的服务端状态。
+// challenge 无论成功与否都已被消费。
+func verifyClientData(raw []byte, ceremony string, rp *webauthnRP, purpose string) (*webauthnChallenge, error) {
+	var cd webauthnClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, fmt.Errorf("%w: clientDataJSON: %v", errWebauthnInvalid, err)
+	}
+	state := takeWebauthnChallenge(cd.Challenge)
+	if state == nil {
+		return nil, fmt.Errorf("%w: unknown or expired challenge", errWebauthnInvalid)
+	}
+	if cd.Type != ceremony {
+		return nil, fmt.Errorf("%w: type %q", errWebauthnInvalid, cd.Type)
+	}
+	if state.Purpose != purpose || state.Brand != rp.Brand {
+		return nil, fmt.Errorf("%w: challenge issued for %s/%s", errWebauthnInvalid, state.Purpose, state.Brand)
+	}
+	if cd.CrossOrigin {
+		return nil, fmt.Errorf("%w: cross-origin ceremony", errWebauthnInvalid)
+	}
+	allowed := false
+	for _, o := range rp.Origins {
+		if cd.Origin == o {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: origin %q", errWebauthnInvalid, cd.Origin)
+	}
+	return state, nil
+}
+
+// ===================== authenticator data =====================
+
+type webauthnAuthData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// 仅 AT 标志置位（登记）时
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte // COSE_Key 原始 CBOR
+}
+
+// parseAuthData 解析 authenticator data（WebAuthn §6.1）
+func parseAuthData(data []byte) (*webauthnAuthData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", errWebauthnInvalid)
+	}
+	ad := &webauthnAuthData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if ad.Flags&authFlagAttestedCredData == 0 {
+		return ad, nil
+	}
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: attested credential data too short", errWebauthnInvalid)
+	}
+	ad.AAGUID = rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, fmt.Errorf("%w: credential id length %d", errWebauthnInvalid, idLen)
+	}
+	ad.CredentialID = rest[:idLen]
+	rest = rest[idLen:]
+	// COSE_Key 之后可能还有 extensions，按 CBOR 实际长度截取
+	_, n, err := cborDecode(rest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: credential public key: %v", errWebauthnInvalid, err)
+	}
+	ad.PublicKey = rest[:n]
+	return ad, nil
+}
+
+// check 校验 rpIdHash 以及 UP / UV 标志
+func (ad *webauthnAuthData) check(rp *webauthnRP) error {
+	want := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(ad.RPIDHash, want[:]) != 1 {
+		return fmt.Errorf("%w: rpIdHash mismatch", errWebauthnInvalid)
+	}
+	if ad.Flags&authFlagUserPresent == 0 {
+		return fmt.Errorf("%w: user not present", errWebauthnInvalid)
+	}
+	if ad.Flags&authFlagUserVerified == 0 {
+		return fmt.Errorf("%w: user not verified", errWebauthnInvalid)
+	}
+	return nil
+}
+
+// formatAAGUID 16 字节 → 8-4-4-4-12
+func formatAAGUID(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// ===================== 登记 / 断言 =====================
+
+// webauthnRegistration 登记 ceremony 校验通过后的结果
+type webauthnRegistration struct {
+	UserID       uint64
+	CredentialID string // base64url
+	PublicKey    string // base64url COSE_Key
+	Algorithm    int64
+	SignCount    uint32
+	AAGUID       string
+	Synced       bool
+}
+
+// verifyWebauthnRegistration 校验 navigator.credentials.create() 的结果（WebAuthn §7.1）
+func verifyWebauthnRegistration(rp *webauthnRP, credentialID, clientDataB64, attestationB64 string) (*webauthnRegistration, error) {
+	clientData, err := webauthnDecode(clientDataB64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: clientDataJSON encoding", errWebauthnInvalid)
+	}
+	state, err := verifyClientData(clientData, "webauthn.create", rp, webauthnPurposeRegister)
+	if err != nil {
+		return nil, err
+	}
+
+	attRaw, err := webauthnDecode(attestationB64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestationObject encoding", errWebauthnInvalid)
+	}
+	att, _, err := cborDecode(attRaw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestationObject: %v", errWebauthnInvalid, err)
+	}
+	attMap, ok := att.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestationObject is not a map", errWebauthnInvalid)
+	}
+	authDataRaw, ok := attMap["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing authData", errWebauthnInvalid)
+	}
+	ad, err := parseAuthData(authDataRaw)
+	if err != nil {
+		return nil, err
+	}
+	if err := ad.check(rp); err != nil {
+		return nil, err
+	}
+	if ad.CredentialID == nil {
+		return nil, fmt.Errorf("%w: no attested credential data", errWebauthnInvalid)
+	}
+	if webauthnB64.EncodeToString(ad.CredentialID) != strings.TrimRight(credentialID, "=") {
+		return nil, fmt.Errorf("%w: credential id mismatch", errWebauthnInvalid)
+	}
+	alg, _, err := parseCOSEKey(ad.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthnRegistration{
+		UserID:       state.UserID,
+		CredentialID: webauthnB64.EncodeToString(ad.CredentialID),
+		PublicKey:    webauthnB64.EncodeToString(ad.PublicKey),
+		Algorithm:    alg,
+		SignCount:    ad.SignCount,
+		AAGUID:       formatAAGUID(ad.AAGUID),
+		Synced:       ad.Flags&authFlagBackupState != 0,
+	}, nil
+}
+
+// verifyWebauthnAssertion 校验 navigator.credentials.get() 的结果（WebAuthn §7.2），
+// 返回新的签名计数。cred 由调用方按 credential id 查出。
+func verifyWebauthnAssertion(rp *webauthnRP, cred *PasskeyCredential, clientDataB64, authDataB64, signatureB64 string) (uint32, error) {
+	clientData, err := webauthnDecode(clientDataB64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: clientDataJSON encoding", errWebauthnInvalid)
+	}
+	if _, err := verifyClientData(clientData, "webauthn.get", rp, webauthnPurposeLogin); err != nil {
+		return 0, err
+	}
+	authData, err := webauthnDecode(authDataB64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: authenticatorData encoding", errWebauthnInvalid)
+	}
+	ad, err := parseAuthData(authData)
+	if err != nil {
+		return 0, err
+	}
+	if err := ad.check(rp); err != nil {
+		return 0, err
+	}
+	sig, err := webauthnDecode(signatureB64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: signature encoding", errWebauthnInvalid)
+	}
+	coseKey, err := webauthnDecode(cred.PublicKey)
+	if err != nil {
+		return 0, fmt.Errorf("corrupt stored public key for passkey %d: %w", cred.ID, err)
+	}
+
+	clientDataHash := sha256.Sum256(clientData)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	if err := verifyCOSESignature(coseKey, signed, sig); err != nil {
+		return 0, err
+	}
+
+	// 签名计数：任一方非 0 时必须递增，否则可能是被克隆的认证器
+	if (ad.SignCount != 0 || cred.SignCount != 0) && ad.SignCount <= cred.SignCount {
+		return 0, fmt.Errorf("%w: sign count %d not greater than stored %d", errWebauthnInvalid, ad.SignCount, cred.SignCount)
+	}
+	return ad.SignCount, nil
+}
+
+// ===================== COSE_Key =====================
+
+// parseCOSEKey 解析公钥，返回算法和 Go 公钥
+func parseCOSEKey(raw []byte) (int64, crypto.PublicKey, error) {
+	v, _, err := cborDecode(raw)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: COSE key: %v", errWebauthnInvalid, err)
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: COSE key is not a map", errWebauthnInvalid)
+	}
+	intOf := func(k int64) (int64, bool) {
+		x, ok := m[k].(int64)
+		return x, ok
+	}
+	bytesOf := func(k int64) []byte {
+		b, _ := m[k].([]byte)
+		return b
+	}
+	kty, _ := intOf(1)
+	alg, ok := intOf(3)
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: COSE key without alg", errWebauthnInvalid)
+	}
+
+	switch alg {
+	case coseAlgES256:
+		crv, _ := intOf(-1)
+		x, y := bytesOf(-2), bytesOf(-3)
+		if kty != 2 || crv != 1 || len(x) != 32 || len(y) != 32 {
+			return 0, nil, fmt.Errorf("%w: malformed ES256 key", errWebauthnInvalid)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return 0, nil, fmt.Errorf("%w: ES256 point not on curve", errWebauthnInvalid)
+		}
+		return alg, pub, nil
+	case coseAlgEdDSA:
+		crv, _ := intOf(-1)
+		x := bytesOf(-2)
+		if kty != 1 || crv != 6 || len(x) != ed25519.PublicKeySize {
+			return 0, nil, fmt.Errorf("%w: malformed Ed25519 key", errWebauthnInvalid)
+		}
+		return alg, ed25519.PublicKey(x), nil
+	case coseAlgRS256:
+		n, eb := bytesOf(-1), bytesOf(-2)
+		if kty != 3 || len(n) < 256 || len(eb) == 0 || len(eb) > 4 {
+			return 0, nil, fmt.Errorf("%w: malformed RS256 key", errWebauthnInvalid)
+		}
+		e := 0
+		for _, b := range eb {
+			e = e<<8 | int(b)
+		}
+		return alg, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: e}, nil
+	default:
+		return 0, nil, fmt.Errorf("%w: unsupported COSE alg %d", errWebauthnInvalid, alg)
+	}
+}
+
+// verifyCOSESignature 用 COSE_Key 校验签名
+func verifyCOSESignature(coseKey, signed, sig []byte) error {
+	alg, pub, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+	ok := false
+	switch alg {
+	case coseAlgES256:
+		digest := sha256.Sum256(signed)
+		ok = ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], sig)
+	case coseAlgEdDSA:
+		ok = ed25519.Verify(pub.(ed25519.PublicKey), signed, sig)
+	case coseAlgRS256:
+		digest := sha256.Sum256(signed)
+		ok = rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	}
+	if !ok {
+		return fmt.Errorf("%w: bad signature", errWebauthnInvalid)
+	}
+	return nil
+}
+
+// ===================== CBOR（RFC 8949 子集） =====================
+//
+// 只覆盖 WebAuthn 用到的部分：整数、字节串、文本串、数组、map、true/false/null，
+// 仅定长编码。返回值：uint/negint → int64，bytes → []byte，text → string，
+// array → []any，map → map[any]any。
+
+const cborMaxDepth = 16
+
+// cborDecode 解码一个 CBOR 数据项，返回值和消耗的字节数
+func cborDecode(data []byte) (any, int, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.item(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.pos, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) head() (major byte, arg uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, errors.New("cbor: unexpected end of data")
+	}
+	b := d.data[d.pos]
+	d.pos++
+	major, info := b>>5, b&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		n := 1 << (info - 24)
+		if d.pos+n > len(d.data) {
+			return 0, 0, errors.New("cbor: unexpected end of data")
+		}
+		for _, c := range d.data[d.pos : d.pos+n] {
+			arg = arg<<8 | uint64(c)
+		}
+		d.pos += n
+		return major, arg, nil
+	default:
+		return 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+	}
+}
+
+func (d *cborDecoder) item(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, errors.New("cbor: nesting too deep")
+	}
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), nil
+	case 2, 3:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errors.New("cbor: string exceeds data")
+		}
+		b := d.data[d.pos : d.pos+int(arg)]
+		d.pos += int(arg)
+		if major == 3 {
+			return string(b), nil
+		}
+		return append([]byte{}, b...), nil
+	case 4:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errors.New("cbor: array exceeds data")
+		}
+		arr := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	case 5:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errors.New("cbor: map exceeds data")
+		}
+		m := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("cbor: unsupported map key type %T", k)
+			}
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	case 7:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", arg)
+	default:
+		return nil, fmt.Errorf("cbor: unsupported major type %d", major)
+	}
+}
@@ -0,0 +1,433 @@
+package center
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ===================== 测试用 CBOR 编码 =====================
+
+// cborPair 保持 map 键顺序，便于构造确定的字节
+type cborPair struct{ k, v any }
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 1<<8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n < 1<<16:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	default:
+		b := []byte{major<<5 | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+		return b
+	}
+}
+
+func cborEncode(v any) []byte {
+	switch x := v.(type) {
+	case int:
+		if x >= 0 {
+			return cborHead(0, uint64(x))
+		}
+		return cborHead(1, uint64(-1-x))
+	case []byte:
+		return append(cborHead(2, uint64(len(x))), x...)
+	case string:
+		return append(cborHead(3, uint64(len(x))), x...)
+	case bool:
+		if x {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case []any:
+		out := cborHead(4, uint64(len(x)))
+		for _, e := range x {
+			out = append(out, cborEncode(e)...)
+		}
+		return out
+	case []cborPair:
+		out := cborHead(5, uint64(len(x)))
+		for _, p := range x {
+			out = append(out, cborEncode(p.k)...)
+			out = append(out, cborEncode(p.v)...)
+		}
+		return out
+	}
+	panic("unsupported")
+}
+
+// ===================== 软件认证器 =====================
+
+type softAuthenticator struct {
+	key       *ecdsa.PrivateKey
+	credID    []byte
+	signCount uint32
+	rpID      string
+	flags     byte
+}
+
+func newSoftAuthenticator(t *testing.T, rpID string) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &softAuthenticator{key: key, credID: id, rpID: rpID, flags: authFlagUserPresent | authFlagUserVerified}
+}
+
+func (a *softAuthenticator) id() string { return webauthnB64.EncodeToString(a.credID) }
+
+func (a *softAuthenticator) coseKey() []byte {
+	pad := func(b []byte) []byte { return append(make([]byte, 32-len(b)), b...) }
+	return cborEncode([]cborPair{
+		{1, 2}, {3, -7}, {-1, 1},
+		{-2, pad(a.key.X.Bytes())},
+		{-3, pad(a.key.Y.Bytes())},
+	})
+}
+
+func (a *softAuthenticator) authData(attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(a.rpID))
+	out := append([]byte{}, rpHash[:]...)
+	flags := a.flags
+	if attested {
+		flags |= authFlagAttestedCredData
+	}
+	out = append(out, flags)
+	out = binary.BigEndian.AppendUint32(out, a.signCount)
+	if attested {
+		out = append(out, make([]byte, 16)...) // AAGUID
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.credID)))
+		out = append(out, a.credID...)
+		out = append(out, a.coseKey()...)
+	}
+	return out
+}
+
+func webauthnClientDataJSON(typ, challenge, origin string) string {
+	raw, _ := json.Marshal(map[string]any{"type": typ, "challenge": challenge, "origin": origin})
+	return webauthnB64.EncodeToString(raw)
+}
+
+// create 模拟 navigator.credentials.create()，返回 clientDataJSON 与 attestationObject
+func (a *softAuthenticator) create(challenge, origin string) (string, string) {
+	att := cborEncode([]cborPair{
+		{"fmt", "none"},
+		{"attStmt", []cborPair{}},
+		{"authData", a.authData(true)},
+	})
+	return webauthnClientDataJSON("webauthn.create", challenge, origin), webauthnB64.EncodeToString(att)
+}
+
+// get 模拟 navigator.credentials.get()，返回 clientDataJSON、authenticatorData、signature
+func (a *softAuthenticator) get(t *testing.T, challenge, origin string) (string, string, string) {
+	t.Helper()
+	a.signCount++
+	cd := webauthnClientDataJSON("webauthn.get", challenge, origin)
+	cdRaw, _ := webauthnDecode(cd)
+	ad := a.authData(false)
+	cdHash := sha256.Sum256(cdRaw)
+	digest := sha256.Sum256(append(append([]byte{}, ad...), cdHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+	return cd, webauthnB64.EncodeToString(ad), webauthnB64.EncodeToString(sig)
+}
+
+// ===================== tests =====================
+
+func testWebauthnRP() *webauthnRP {
+	cfg := BrandKaitu.Config()
+	return &webauthnRP{ID: cfg.RedirectRootDomain, Name: cfg.DisplayName, Brand: BrandKaitu, Origins: cfg.WebOrigins}
+}
+
+func TestCBORDecode(t *testing.T) {
+	raw := cborEncode([]cborPair{
+		{"a", 1}, {-2, []byte{1, 2}}, {"list", []any{"x", -300, true}}, {"n", 70000},
+	})
+	v, n, err := cborDecode(append(raw, 0xff)) // 尾部多余字节不计入
+	require.NoError(t, err)
+	assert.Equal(t, len(raw), n)
+	m := v.(map[any]any)
+	assert.Equal(t, int64(1), m["a"])
+	assert.Equal(t, []byte{1, 2}, m[int64(-2)])
+	assert.Equal(t, []any{"x", int64(-300), true}, m["list"])
+	assert.Equal(t, int64(70000), m["n"])
+
+	for _, bad := range [][]byte{
+		{},
+		{0x5a, 0xff, 0xff, 0xff, 0xff}, // 字节串长度越界
+		{0x9f},                         // 不定长数组
+		{0xa1, 0x80, 0x01},             // 数组作为 map 键
+		{0xfb, 0, 0, 0, 0, 0, 0, 0, 0}, // 浮点
+	} {
+		_, _, err := cborDecode(bad)
+		assert.Error(t, err, "% x", bad)
+	}
+}
+
+// cborSpecVectors RFC 8949 附录 A 中落在解码器支持范围内的例子
+var cborSpecVectors = []struct {
+	hex  string
+	want any
+}{
+	{"00", int64(0)},
+	{"01", int64(1)},
+	{"0a", int64(10)},
+	{"17", int64(23)},
+	{"1818", int64(24)},
+	{"1819", int64(25)},
+	{"1864", int64(100)},
+	{"1903e8", int64(1000)},
+	{"1a000f4240", int64(1000000)},
+	{"1b000000e8d4a51000", int64(1000000000000)},
+	{"20", int64(-1)},
+	{"29", int64(-10)},
+	{"3863", int64(-100)},
+	{"3903e7", int64(-1000)},
+	{"40", []byte{}},
+	{"4401020304", []byte{1, 2, 3, 4}},
+	{"60", ""},
+	{"6161", "a"},
+	{"6449455446", "IETF"},
+	{"62225c", "\"\\"},
+	{"62c3bc", "\u00fc"},
+	{"63e6b0b4", "\u6c34"},
+	{"80", []any{}},
+	{"83010203", []any{int64(1), int64(2), int64(3)}},
+	{"8301820203820405", []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+	{"a0", map[any]any{}},
+	{"a201020304", map[any]any{int64(1): int64(2), int64(3): int64(4)}},
+	{"a26161016162820203", map[any]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+	{"826161a161626163", []any{"a", map[any]any{"b": "c"}}},
+	{"f4", false},
+	{"f5", true},
+	{"f6", nil},
+}
+
+func TestCBORDecode_SpecVectors(t *testing.T) {
+	for _, tc := range cborSpecVectors {
+		raw, err := hex.DecodeString(tc.hex)
+		require.NoError(t, err)
+		v, n, err := cborDecode(raw)
+		require.NoError(t, err, tc.hex)
+		assert.Equal(t, len(raw), n, tc.hex)
+		assert.Equal(t, tc.want, v, tc.hex)
+	}
+
+	// 附录 A 中解码器有意不支持的编码：超出 int64 的整数、浮点、不定长
+	for _, h := range []string{
+		"1bffffffffffffffff", // 18446744073709551615
+		"3bffffffffffffffff", // -18446744073709551616
+		"f90000",             // 0.0（半精度）
+		"fb3ff199999999999a", // 1.1
+		"5f42010243030405ff", // (_ h'0102', h'030405')
+		"9fff",               // [_ ]
+		"bf61610161629f0203ffff",
+	} {
+		raw, err := hex.DecodeString(h)
+		require.NoError(t, err)
+		_, _, err = cborDecode(raw)
+		assert.Error(t, err, h)
+	}
+}
+
+func FuzzCBORDecode(f *testing.F) {
+	for _, tc := range cborSpecVectors {
+		raw, _ := hex.DecodeString(tc.hex)
+		f.Add(raw)
+	}
+	f.Add(cborEncode([]cborPair{{1, 2}, {3, -7}, {-1, 1}, {-2, make([]byte, 32)}, {-3, make([]byte, 32)}}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, n, err := cborDecode(data)
+		if err != nil {
+			return
+		}
+		if n <= 0 || n > len(data) {
+			t.Fatalf("consumed %d of %d bytes", n, len(data))
+		}
+		// 解码结果只能由支持的类型构成
+		var walk func(any, int)
+		walk = func(v any, depth int) {
+			if depth > cborMaxDepth+1 {
+				t.Fatalf("nesting deeper than cborMaxDepth")
+			}
+			switch x := v.(type) {
+			case nil, bool, int64, string, []byte:
+			case []any:
+				for _, e := range x {
+					walk(e, depth+1)
+				}
+			case map[any]any:
+				for k, e := range x {
+					walk(k, depth+1)
+					walk(e, depth+1)
+				}
+			default:
+				t.Fatalf("unexpected decoded type %T", v)
+			}
+		}
+		walk(v, 0)
+		// 同一输入的前缀解码必须一致
+		_, n2, err := cborDecode(data[:n])
+		if err != nil || n2 != n {
+			t.Fatalf("re-decoding the consumed prefix: n=%d err=%v", n2, err)
+		}
+		_, _, _ = parseCOSEKey(data)
+	})
+}
+
+func TestResolveWebauthnRP(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/web-login/passkey/options", nil)
+	c.Request.Host = "k2.52j.me"
+	c.Request.Header.Set("Origin", "https://www.overleap.io")
+	rp, ok := resolveWebauthnRP(c)
+	require.True(t, ok, "browser origin wins over the proxied API host")
+	assert.Equal(t, "overleap.io", rp.ID)
+	assert.Equal(t, BrandOverleap, rp.Brand)
+
+	c.Request.Header.Del("Origin")
+	_, ok = resolveWebauthnRP(c)
+	assert.False(t, ok)
+
+	c.Request.Host = "www.kaitu.io:443"
+	rp, ok = resolveWebauthnRP(c)
+	require.True(t, ok)
+	assert.Equal(t, "kaitu.io", rp.ID)
+}
+
+func TestWebauthnRegistrationAndAssertion(t *testing.T) {
+	testInitConfig()
+	rp := testWebauthnRP()
+	auth := newSoftAuthenticator(t, rp.ID)
+	origin := rp.Origins[0]
+
+	challenge, err := issueWebauthnChallenge(webauthnChallenge{Purpose: webauthnPurposeRegister, Brand: rp.Brand, UserID: 42})
+	require.NoError(t, err)
+	cd, att := auth.create(challenge, origin)
+	reg, err := verifyWebauthnRegistration(rp, auth.id(), cd, att)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), reg.UserID)
+	assert.Equal(t, auth.id(), reg.CredentialID)
+	assert.Equal(t, coseAlgES256, reg.Algorithm)
+	assert.Equal(t, "00000000-0000-0000-0000-000000000000", reg.AAGUID)
+
+	_, err = verifyWebauthnRegistration(rp, auth.id(), cd, att)
+	assert.ErrorIs(t, err, errWebauthnInvalid, "a challenge can only be used once")
+
+	cred := &PasskeyCredential{PublicKey: reg.PublicKey, SignCount: reg.SignCount}
+	login := func(origin string) (uint32, error) {
+		challenge, err := issueWebauthnChallenge(webauthnChallenge{Purpose: webauthnPurposeLogin, Brand: rp.Brand})
+		require.NoError(t, err)
+		cd, ad, sig := auth.get(t, challenge, origin)
+		return verifyWebauthnAssertion(rp, cred, cd, ad, sig)
+	}
+
+	count, err := login(origin)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), count)
+	cred.SignCount = count
+
+	_, err = login("https://evil.example")
+	assert.ErrorIs(t, err, errWebauthnInvalid)
+
+	auth.signCount = 0 // 克隆的认证器：计数回退
+	_, err = login(origin)
+	assert.ErrorIs(t, err, errWebauthnInvalid)
+}
+
+func TestWebauthnRejects(t *testing.T) {
+	testInitConfig()
+	rp := testWebauthnRP()
+	origin := rp.Origins[0]
+
+	t.Run("wrong rp id", func(t *testing.T) {
+		auth := newSoftAuthenticator(t, "overleap.io")
+		challenge, _ := issueWebauthnChallenge(webauthnChallenge{Purpose: webauthnPurposeRegister, Brand: rp.Brand})
+		cd, att := auth.create(challenge, origin)
+		_, err := verifyWebauthnRegistration(rp, auth.id(), cd, att)
+		assert.ErrorIs(t, err, errWebauthnInvalid)
+	})
+
+	t.Run("no user verification", func(t *testing.T) {
+		auth := newSoftAuthenticator(t, rp.ID)
+		auth.flags = authFlagUserPresent
+		challenge, _ := issueWebauthnChallenge(webauthnChallenge{Purpose: webauthnPurposeRegister, Brand: rp.Brand})
+		cd, att := auth.create(challenge, origin)
+		_, err := verifyWebauthnRegistration(rp, auth.id(), cd, att)
+		assert.ErrorIs(t, err, errWebauthnInvalid)
+	})
+
+	t.Run("login challenge used for registration", func(t *testing.T) {
+		auth := newSoftAuthenticator(t, rp.ID)
+		challenge, _ := issueWebauthnChallenge(webauthnChallenge{Purpose: webauthnPurposeLogin, Brand: rp.Brand})
+		cd, att := auth.create(challenge, origin)
+		_, err := verifyWebauthnRegistration(rp, auth.id(), cd, att)
+		assert.ErrorIs(t, err, errWebauthnInvalid)
+	})
+
+	t.Run("unknown challenge", func(t *testing.T) {
+		auth := newSoftAuthenticator(t, rp.ID)
+		cd, att := auth.create("bm90LWlzc3VlZA", origin)
+		_, err := verifyWebauthnRegistration(rp, auth.id(), cd, att)
+		assert.ErrorIs(t, err, errWebauthnInvalid)
+	})
+
+	t.Run("tampered signature", func(t *testing.T) {
+		auth := newSoftAuthenticator(t, rp.ID)
+		cred := &PasskeyCredential{PublicKey: webauthnB64.EncodeToString(auth.coseKey())}
+		challenge, _ := issueWebauthnChallenge(webauthnChallenge{Purpose: webauthnPurposeLogin, Brand: rp.Brand})
+		cd, ad, _ := auth.get(t, challenge, origin)
+		other := newSoftAuthenticator(t, rp.ID)
+		_, _, sig := other.get(t, "", origin)
+		_, err := verifyWebauthnAssertion(rp, cred, cd, ad, sig)
+		assert.ErrorIs(t, err, errWebauthnInvalid)
+	})
+}
+
+func TestTakeWebauthnChallenge_SingleUse(t *testing.T) {
+	testInitConfig()
+	challenge, err := issueWebauthnChallenge(webauthnChallenge{Purpose: webauthnPurposeLogin, Brand: BrandKaitu})
+	require.NoError(t, err)
+
+	state := takeWebauthnChallenge(challenge)
+	require.NotNil(t, state)
+	assert.Equal(t, webauthnPurposeLogin, state.Purpose)
+	assert.Nil(t, takeWebauthnChallenge(challenge), "GETDEL removes the challenge with the read")
+}
+
+func TestVerifyCOSESignature_Ed25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key := cborEncode([]cborPair{{1, 1}, {3, -8}, {-1, 6}, {-2, []byte(pub)}})
+	msg := []byte("signed data")
+	assert.NoError(t, verifyCOSESignature(key, msg, ed25519.Sign(priv, msg)))
+	assert.ErrorIs(t, verifyCOSESignature(key, []byte("other"), ed25519.Sign(priv, msg)), errWebauthnInvalid)
+
+	// RFC 8032 §7.1 TEST 1：空消息
+	vecPub, _ := hex.DecodeString("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	sig, _ := hex.DecodeString("e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b")
+	key = cborEncode([]cborPair{{1, 1}, {3, -8}, {-1, 6}, {-2, vecPub}})
+	assert.NoError(t, verifyCOSESignature(key, []byte{}, sig))
+	sig[0] ^= 1
+	assert.ErrorIs(t, verifyCOSESignature(key, []byte{}, sig), errWebauthnInvalid)
+
+	unsupported := cborEncode([]cborPair{{1, 2}, {3, -35}})
+	_, _, err = parseCOSEKey(unsupported)
+	assert.ErrorIs(t, err, errWebauthnInvalid)
+}
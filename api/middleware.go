@@ -150,6 +150,10 @@ type authContext struct {
 	UDID   string
 	Device *Device
 	User   *User
+
+	ViaAccessKey bool // X-Access-Key 认证：非交互凭证，不做两步验证要求
+
+	Session *AuthSession // token 所属会话；存量 token 与 X-Access-Key 为 nil
 }
 
 // getAuthContext 获取认证上下文，确保只执行一次
@@ -241,8 +245,9 @@ func handleCookieJWTAuth(c *gin.Context, token string) *authContext {
 				if remaining < WebCookieRenewalThreshold {
 					log.Infof(c, "sliding expiration: renewing web cookie for user %d (remaining: %v)", authCtx.UserID, remaining)
 
-					// 生成新的 token，保留原 token 中的 roles
-					newAuthResult, _, err := generateWebCookieToken(c, authCtx.UserID, claims.Roles)
+					// 生成新的 token，保留原 token 中的 roles 与会话；
+					// 存量 token（无 sid）在此开启 Web 会话，迁入会话体系
+					newAuthResult, err := renewWebCookieToken(c, authCtx.UserID, claims)
 					if err != nil {
 						log.Errorf(c, "failed to renew web cookie for user %d: %v", authCtx.UserID, err)
 						// 续期失败不影响当前请求，继续使用旧 token
@@ -282,6 +287,8 @@ func handleAccessKeyAuth(c *gin.Context, accessKey string) *authContext {
 		UDID:   "",
 		Device: nil,
 		User:   &user,
+
+		ViaAccessKey: true,
 	}
 	c.Set("authContext", authCtx)
 	return authCtx
@@ -333,13 +340,19 @@ func handleJWTAuth(c *gin.Context, token string) *authContext {
 			return nil
 		}
 
+		session, ok := checkAuthSession(c, claims, &user)
+		if !ok {
+			return nil
+		}
+
 		// 创建Web认证上下文
 		log.Debugf(c, "creating new web auth context for user %d", claims.UserID)
 		authCtx := &authContext{
-			UserID: claims.UserID,
-			UDID:   "",
-			Device: nil,
-			User:   &user,
+			UserID:  claims.UserID,
+			UDID:    "",
+			Device:  nil,
+			User:    &user,
+			Session: session,
 		}
 		c.Set("authContext", authCtx)
 		return authCtx
@@ -363,6 +376,10 @@ func handleJWTAuth(c *gin.Context, token string) *authContext {
 		log.Warnf(c, "token issue at mismatch for device: %s, user: %d", udid, claims.UserID)
 		return nil
 	}
+	session, ok := checkAuthSession(c, claims, device.User)
+	if !ok {
+		return nil
+	}
 
 	// 解析 X-K2-Client header 并更新设备的应用版本信息
 	if clientHeader := c.GetHeader("X-K2-Client"); clientHeader != "" {
@@ -374,10 +391,11 @@ func handleJWTAuth(c *gin.Context, token string) *authContext {
 	// 创建设备认证上下文
 	log.Debugf(c, "creating new device auth context for user %d, device %s", device.UserID, device.UDID)
 	authCtx := &authContext{
-		UserID: device.UserID,
-		UDID:   device.UDID,
-		Device: &device,
-		User:   device.User,
+		UserID:  device.UserID,
+		UDID:    device.UDID,
+		Device:  &device,
+		User:    device.User,
+		Session: session,
 	}
 	c.Set("authContext", authCtx)
 	return authCtx
@@ -410,6 +428,15 @@ func ReqDevice(c *gin.Context) *Device {
 	return ctx.Device
 }
 
+// ReqSession 从上下文中获取当前会话（存量 token 与 X-Access-Key 认证为 nil）
+func ReqSession(c *gin.Context) *AuthSession {
+	ctx := getAuthContext(c)
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Session
+}
+
 func ReqUDID(c *gin.Context) string {
 	ctx := getAuthContext(c)
 	if ctx == nil {
@@ -670,11 +697,30 @@ func AdminRequired() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if !twoFactorSetupSatisfied(c, user) {
+			return
+		}
 		c.Next()
 	}
 }
 
+// twoFactorSetupSatisfied 管理角色账号必须已启用两步验证才能访问后台；
+// 未启用时写入 ErrorTwoFactorSetupRequired 并 Abort。AccessKey 认证不受限。
+func twoFactorSetupSatisfied(c *gin.Context, user *User) bool {
+	if twoFactorEnabled(user) {
+		return true
+	}
+	if ctx := getAuthContext(c); ctx != nil && ctx.ViaAccessKey {
+		return true
+	}
+	log.Warnf(c, "admin access denied: user %d has not enabled two-factor authentication, path=%s", user.ID, c.Request.URL.Path)
+	Error(c, ErrorTwoFactorSetupRequired, "two-factor authentication required for admin accounts")
+	c.Abort()
+	return false
+}
+
 // RoleRequired 细粒度权限检查：IsAdmin=true 直接通过；否则检查 user.Roles 是否包含指定角色。
+// 两者都还要求账号已启用两步验证（见 twoFactorSetupSatisfied）。
 // role 参数支持位或组合：RoleRequired(RoleDevopsViewer | RoleDevopsEditor) 表示任一满足即通过。
 // 权限来源：从 DB 加载的 User 结构体（通过 ReqUser(c)），与 AdminRequired() 读取 IsAdmin 一致。
 // 角色变更立即生效（下次请求），无需重新签发 token。
@@ -686,17 +732,16 @@ func RoleRequired(role uint64) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		if user.IsAdmin != nil && *user.IsAdmin {
-			c.Next()
-			return
-		}
-		if !HasRole(user.Roles, role) {
+		if (user.IsAdmin == nil || !*user.IsAdmin) && !HasRole(user.Roles, role) {
 			log.Warnf(c, "role check failed: need=%d user=%d roles=%d path=%s",
 				role, user.ID, user.Roles, c.Request.URL.Path)
 			Error(c, ErrorForbidden, "permission denied")
 			c.Abort()
 			return
 		}
+		if !twoFactorSetupSatisfied(c, user) {
+			return
+		}
 		c.Next()
 	}
 }
@@ -690,7 +690,7 @@ func TestRoleRequired_NoUser(t *testing.T) {
 // TestRoleRequired_ExactRole 拥有精确角色的用户通过
 func TestRoleRequired_ExactRole(t *testing.T) {
 	testInitConfig()
-	user := &User{ID: 1, Roles: RoleDevopsViewer}
+	user := &User{ID: 1, Roles: RoleDevopsViewer, TOTPEnabledAt: 1}
 	r := createRoleTestRouter(user, RoleDevopsViewer)
 	req, _ := http.NewRequest("GET", "/test", nil)
 	w := httptest.NewRecorder()
@@ -724,7 +724,7 @@ func TestRoleRequired_RoleUser_Denied(t *testing.T) {
 func TestRoleRequired_IsAdmin_Bypass(t *testing.T) {
 	testInitConfig()
 	isAdmin := true
-	user := &User{ID: 4, Roles: RoleUser, IsAdmin: &isAdmin} // Roles 没有 DevopsViewer，但 IsAdmin=true
+	user := &User{ID: 4, Roles: RoleUser, IsAdmin: &isAdmin, TOTPEnabledAt: 1} // Roles 没有 DevopsViewer，但 IsAdmin=true
 	r := createRoleTestRouter(user, RoleDevopsViewer)
 	req, _ := http.NewRequest("GET", "/test", nil)
 	w := httptest.NewRecorder()
@@ -739,7 +739,7 @@ func TestRoleRequired_CombinedRole_EitherSuffices(t *testing.T) {
 
 	// 只有 DevopsViewer：应通过
 	t.Run("viewer passes viewOrEdit check", func(t *testing.T) {
-		user := &User{ID: 5, Roles: RoleDevopsViewer}
+		user := &User{ID: 5, Roles: RoleDevopsViewer, TOTPEnabledAt: 1}
 		r := createRoleTestRouter(user, viewOrEdit)
 		req, _ := http.NewRequest("GET", "/test", nil)
 		w := httptest.NewRecorder()
@@ -749,7 +749,7 @@ func TestRoleRequired_CombinedRole_EitherSuffices(t *testing.T) {
 
 	// 只有 DevopsEditor：应通过
 	t.Run("editor passes viewOrEdit check", func(t *testing.T) {
-		user := &User{ID: 6, Roles: RoleDevopsEditor}
+		user := &User{ID: 6, Roles: RoleDevopsEditor, TOTPEnabledAt: 1}
 		r := createRoleTestRouter(user, viewOrEdit)
 		req, _ := http.NewRequest("GET", "/test", nil)
 		w := httptest.NewRecorder()
@@ -768,6 +768,44 @@ func TestRoleRequired_CombinedRole_EitherSuffices(t *testing.T) {
 	})
 }
 
+// TestRoleRequired_TwoFactorSetupRequired 管理角色账号未启用两步验证时被拒绝，AccessKey 认证除外
+func TestRoleRequired_TwoFactorSetupRequired(t *testing.T) {
+	testInitConfig()
+	isAdmin := true
+	for _, user := range []*User{
+		{ID: 9, Roles: RoleDevopsEditor},
+		{ID: 10, Roles: RoleUser, IsAdmin: &isAdmin},
+	} {
+		r := createRoleTestRouter(user, RoleDevopsEditor)
+		req, _ := http.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct{ Code int `json:"code"` }
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, int(ErrorTwoFactorSetupRequired), resp.Code, "user %d", user.ID)
+	}
+
+	// 角色不足仍然是 403，不提示去启用两步验证
+	r := createRoleTestRouter(&User{ID: 11, Roles: RoleSupport}, RoleDevopsEditor)
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assertForbidden(t, w)
+
+	// AccessKey 是非交互凭证，不要求两步验证
+	gin.SetMode(gin.TestMode)
+	ak := gin.New()
+	ak.Use(func(c *gin.Context) {
+		c.Set("authContext", &authContext{UserID: 12, User: &User{ID: 12, IsAdmin: &isAdmin}, ViaAccessKey: true})
+		c.Next()
+	})
+	ak.GET("/test", AdminRequired(), func(c *gin.Context) { c.JSON(200, gin.H{"code": 0}) })
+	req, _ = http.NewRequest("GET", "/test", nil)
+	w = httptest.NewRecorder()
+	ak.ServeHTTP(w, req)
+	assertAuthSuccess(t, w)
+}
+
 func TestParseClientHeader_ClientClass(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -805,7 +843,7 @@ func TestParseClientHeader_ClientClass(t *testing.T) {
 func TestRoleRequired_MultipleRoles(t *testing.T) {
 	testInitConfig()
 	// 用户同时有 DevopsViewer + Support
-	user := &User{ID: 8, Roles: RoleDevopsViewer | RoleSupport}
+	user := &User{ID: 8, Roles: RoleDevopsViewer | RoleSupport, TOTPEnabledAt: 1}
 
 	// 检查需要 DevopsEditor：应失败（没有 DevopsEditor bit）
 	t.Run("missing editor role denied", func(t *testing.T) {
@@ -121,6 +121,14 @@ func Migrate() error {
 		&EmailSendLog{},
 		// 推送通知系统
 		&PushToken{},
+		&NotificationPreference{},
+		&InboxNotification{},
+		// Passkey 登录
+		&PasskeyCredential{},
+		// 登录会话（refresh token 家族）
+		&AuthSession{},
+		// 登录风控决策记录
+		&LoginRiskEvent{},
 		// ECH 密钥管理
 		&ECHKey{},
 		// 分销商沟通记录
@@ -129,10 +137,15 @@ func Migrate() error {
 		&StrategyRules{},
 		&TelemetryEvent{},
 		&TelemetryRateLimit{},
+		&StrategyCohort{},
+		&StrategyCohortDevice{},
+		&TelemetryRollup{},
+		&TelemetryFailureRollup{},
 		// Route diagnosis
 		&IPRouteInfo{},
 		// Cloud instance management
 		&CloudInstance{},
+		&CloudIPChange{},
 		// Device log & feedback ticket
 		&DeviceLog{},
 		&FeedbackTicket{},
@@ -143,6 +156,10 @@ func Migrate() error {
 		&StatAppOpen{},
 		&StatConnection{},
 		&StatK2sDownload{},
+		// Rule-miss telemetry (raw + per-hash aggregate)
+		&RuleMiss{},
+		&RuleMissAggregate{},
+		&RuleMissReveal{},
 		// Admin audit log
 		&AdminAuditLog{},
 		// Admin approval system
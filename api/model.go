@@ -94,6 +94,15 @@ type User struct {
 	PasswordFailedAttempts int    `gorm:"not null;default:0"` // Failed login attempts counter
 	PasswordLockedUntil    int64  `gorm:"not null;default:0"` // Unix timestamp when lock expires (0 = not locked)
 
+	// TOTP two-factor authentication
+	TOTPSecret        string `gorm:"column:totp_secret;type:varchar(255)"`          // 加密的 base32 密钥；未启用时为待确认的新密钥
+	TOTPEnabledAt     int64  `gorm:"column:totp_enabled_at;not null;default:0"`     // 启用时间戳（0 = 未启用）
+	TOTPLastStep      int64  `gorm:"column:totp_last_step;not null;default:0"`      // 最近一次通过的时间步，防止同一验证码重放
+	TOTPRecoveryCodes string `gorm:"column:totp_recovery_codes;type:text" json:"-"` // 未使用恢复码的哈希（JSON 数组）
+
+	// 全局登出：此时间之前签发、且不带会话 ID 的存量 token 一律失效（带会话 ID 的按 AuthSession 判定）
+	SessionsRevokedAt int64 `gorm:"not null;default:0" json:"-"`
+
 	// 地理位置（ISO 3166-1 alpha-2 小写，空字符串表示未知）
 	RegistrationCountry string `gorm:"column:registration_country;type:varchar(2);not null;default:''" json:"registrationCountry"` // 注册时（首次创建用户）检测到的国家
 	CurrentCountry      string `gorm:"column:current_country;type:varchar(2);not null;default:''" json:"currentCountry"`           // 最近一次认证请求检测到的国家
@@ -526,10 +535,20 @@ type SlaveNode struct {
 	VisibleKaitu    *bool `gorm:"default:true" json:"visibleKaitu"`
 	VisibleOverleap *bool `gorm:"default:true" json:"visibleOverleap"`
 
+	// 摘流截止时间（Unix 秒，0 = 未摘流）。换 IP 工作流在切换前置位，让非管理员的
+	// 隧道列表 / 订阅 / antiblock 种子提前不再下发该节点；带截止时间而不是布尔值，
+	// 是为了工作流中途崩溃时节点能自动恢复，而不是永久消失。
+	DrainUntil int64 `gorm:"not null;default:0" json:"drainUntil"`
+
 	// 关联
 	Tunnels []SlaveTunnel `gorm:"foreignKey:NodeID"` // 该物理节点上的隧道
 }
 
+// IsDraining 节点是否处于换 IP 前的摘流期。
+func (n *SlaveNode) IsDraining(now int64) bool {
+	return n.DrainUntil > now
+}
+
 // DeclaredBrands 解析节点自我声明的能力上限。空/全非法 → [kaitu]。
 // 回退到 kaitu 而不是空集是刻意的 fail-safe：一个拼错的 K2_NODE_BRANDS 应该
 // 让节点停在现状，而不是把它从所有品牌里静默摘掉。
@@ -1184,12 +1203,37 @@ type StrategyRules struct {
 	Version  string `gorm:"type:varchar(50);uniqueIndex;not null" json:"version"` // Version format: YYYY.MM.DD.N
 	Content  string `gorm:"type:text;not null" json:"content"`                    // JSON: rules, protocols, default
 	IsActive *bool  `gorm:"default:true" json:"isActive"`                         // Only one active version at a time
+
+	// RolloutPercent 未激活版本的灰度比例（0-100），按设备哈希分桶；同一时间只有一个候选版本灰度。
+	// 激活后清零（激活即 100%）。
+	RolloutPercent int `gorm:"not null;default:0" json:"rolloutPercent"`
+}
+
+// StrategyCohort 显式 A/B 分组：组内设备固定下发 Version，优先于灰度分桶。
+// Name 同时作为遥测事件的 cohort 标签。
+type StrategyCohort struct {
+	ID        uint64    `gorm:"primarykey"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Name    string `gorm:"type:varchar(64);uniqueIndex;not null" json:"name"`
+	Version string `gorm:"type:varchar(50);not null;index" json:"version"` // 固定下发的规则版本
+	Note    string `gorm:"type:varchar(255);not null;default:''" json:"note"`
+}
+
+// StrategyCohortDevice 显式分组成员，一台设备同一时间只属于一个分组
+type StrategyCohortDevice struct {
+	ID        uint64    `gorm:"primarykey"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	CohortID uint64 `gorm:"not null;index" json:"cohortId"`
+	DeviceID uint64 `gorm:"not null;uniqueIndex" json:"deviceId"`
 }
 
 // TelemetryEvent stores individual telemetry events
 type TelemetryEvent struct {
 	ID        uint64    `gorm:"primarykey"`
-	CreatedAt time.Time `json:"createdAt"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"` // rollup 与保留期清扫都按服务端时间
 
 	// Event identification
 	EventID   string `gorm:"type:varchar(64);uniqueIndex;not null" json:"eventId"` // UUID from client
@@ -1210,6 +1254,20 @@ type TelemetryEvent struct {
 
 	// Metadata
 	AppVersion string `gorm:"type:varchar(32)" json:"appVersion"` // Client app version
+
+	// 灰度 / A/B 对比：入库时按设备当前分配打标，Success 取自 outcome.success
+	RulesVersion string `gorm:"type:varchar(50);not null;default:'';index" json:"rulesVersion"`
+	Cohort       string `gorm:"type:varchar(64);not null;default:''" json:"cohort"`
+	Success      *bool  `json:"success,omitempty"`
+
+	// 分析维度：入库时从 JSON 抽出（见 applyTelemetryDims），rollup 直接 GROUP BY
+	ProtocolChain string `gorm:"type:varchar(128);not null;default:''" json:"protocolChain"` // decision.protocol_chain 逗号拼接
+	Country       string `gorm:"type:varchar(2);not null;default:''" json:"country"`
+	Carrier       string `gorm:"type:varchar(32);not null;default:''" json:"carrier"`
+	ASN           uint   `gorm:"column:asn;not null;default:0" json:"asn"`
+	Node          string `gorm:"type:varchar(64);not null;default:''" json:"node"`
+	ConnectMs     *int   `json:"connectMs,omitempty"`                                       // outcome.connect_ms
+	FailureReason string `gorm:"type:varchar(64);not null;default:''" json:"failureReason"` // outcome.failure_reason，仅失败事件
 }
 
 // TelemetryRateLimit tracks rate limiting for telemetry uploads
@@ -1412,6 +1470,50 @@ type CloudOperationLog struct {
 	Error       string `gorm:"type:text"` // Error message if failed
 }
 
+// CloudIPChange status values
+const (
+	CloudIPChangeStatusDraining         = "draining"          // 节点已摘流，等待客户端迁走
+	CloudIPChangeStatusAwaitingRegister = "awaiting_register" // IP 已更换，等待 sidecar 用新 IP 注册
+	CloudIPChangeStatusCompleted        = "completed"
+	CloudIPChangeStatusFailed           = "failed"
+)
+
+// CloudIPChange is one change-IP cutover (worker_cloud_change_ip.go). It is
+// both the workflow state and the durable old→new IP history, so an IP that
+// got blocked can be traced back to the instance and node that owned it.
+type CloudIPChange struct {
+	ID        uint64 `gorm:"primarykey" json:"id"`
+	CreatedAt int64  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt int64  `gorm:"autoUpdateTime" json:"updatedAt"`
+
+	CloudInstanceID uint64 `gorm:"not null;index" json:"cloudInstanceId"`
+	AccountName     string `gorm:"type:varchar(50);not null;index" json:"accountName"`
+	Provider        string `gorm:"type:varchar(20);not null" json:"provider"`
+	InstanceID      string `gorm:"type:varchar(100);not null" json:"instanceId"`
+	TargetRegion    string `gorm:"type:varchar(50);not null;default:''" json:"targetRegion"`
+	Reason          string `gorm:"type:varchar(255);not null;default:''" json:"reason"` // "manual" 或自动轮换的检测摘要
+
+	// SlaveNode being cut over. 0 = instance had no registered node; the IP is
+	// still swapped and recorded, there is just nothing to drain or migrate.
+	NodeID uint64 `gorm:"not null;default:0;index" json:"nodeId"`
+	OldIP  string `gorm:"type:varchar(45);not null;index" json:"oldIp"`
+	NewIP  string `gorm:"type:varchar(45);not null;default:'';index" json:"newIp"`
+
+	Status string `gorm:"type:varchar(20);not null;index" json:"status"`
+	Error  string `gorm:"type:text" json:"error,omitempty"`
+
+	DrainedAt    int64 `gorm:"not null;default:0" json:"drainedAt"`
+	ChangedAt    int64 `gorm:"not null;default:0" json:"changedAt"`    // provider swap done
+	RestartedAt  int64 `gorm:"not null;default:0" json:"restartedAt"`  // SSH restart on new IP succeeded
+	RegisteredAt int64 `gorm:"not null;default:0" json:"registeredAt"` // sidecar re-registered with new IP
+	CompletedAt  int64 `gorm:"not null;default:0" json:"completedAt"`
+}
+
+// IsTerminal reports whether the workflow has finished (either way).
+func (c *CloudIPChange) IsTerminal() bool {
+	return c.Status == CloudIPChangeStatusCompleted || c.Status == CloudIPChangeStatusFailed
+}
+
 // LicenseKeyBatch 授权码批次（独立于活动码的分发单位）
 type LicenseKeyBatch struct {
 	ID        uint64         `gorm:"primarykey" json:"id"`
@@ -0,0 +1,34 @@
+package center
+
+import "time"
+
+// ========================= 登录风控 =========================
+
+// LoginRiskEvent 一次登录相关请求（发码 / 验证码登录 / 密码登录）的风控决策记录。
+// 拦截、带信号、失败的评估落一行，干净的成功放行只抽样，供管理后台追溯"为什么被要求验证码 / 被封禁"。
+// 不挂 User 外键：未知邮箱的尝试同样要记录，用户硬删除后记录也应保留。
+type LoginRiskEvent struct {
+	ID        uint64    `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+
+	Action    string `gorm:"type:varchar(16);not null;index" json:"action"` // 见 loginRiskAction* 常量
+	Brand     string `gorm:"type:varchar(16);not null;default:''" json:"brand"`
+	EmailHash string `gorm:"type:varchar(64);not null;default:'';index" json:"-"` // 与 LoginIdentify.IndexID 同算法
+	UserID    uint64 `gorm:"not null;default:0;index" json:"-"`                   // 0 = 邮箱未注册
+
+	IP      string `gorm:"column:ip;type:varchar(45);not null;default:'';index" json:"ip"`
+	Subnet  string `gorm:"type:varchar(64);not null;default:''" json:"subnet"` // IPv4 /24、IPv6 /48
+	ASN     uint   `gorm:"column:asn;not null;default:0" json:"asn"`
+	Country string `gorm:"type:varchar(2);not null;default:''" json:"country"`
+	UDID    string `gorm:"column:udid;type:varchar(255);not null;default:''" json:"udid"`
+
+	Score    int    `gorm:"not null;default:0" json:"score"`
+	Signals  string `gorm:"type:varchar(255);not null;default:''" json:"signals"` // 逗号分隔的命中信号
+	Decision string `gorm:"type:varchar(16);not null;index" json:"decision"`      // 见 loginRiskDecision* 常量
+	Outcome  string `gorm:"type:varchar(16);not null;default:''" json:"outcome"`  // 见 loginRiskOutcome* 常量
+}
+
+// TableName 指定表名
+func (LoginRiskEvent) TableName() string {
+	return "login_risk_events"
+}
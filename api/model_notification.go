@@ -0,0 +1,94 @@
+package center
+
+import "time"
+
+// ========================= 通知偏好与收件箱 =========================
+
+// NotifyCategory 通知类别，用户按类别设置推送/邮件偏好
+type NotifyCategory string
+
+const (
+	NotifyCategorySecurity  NotifyCategory = "security"  // 安全告警：新设备登录、设备被移除、流量滥用警告等，不可关闭
+	NotifyCategoryBilling   NotifyCategory = "billing"   // 账单：续费提醒、支付结果
+	NotifyCategoryService   NotifyCategory = "service"   // 服务状态：线路、流量、维护通知
+	NotifyCategoryMarketing NotifyCategory = "marketing" // 营销：活动、召回、优惠
+	NotifyCategorySupport   NotifyCategory = "support"   // 客服：工单回复
+)
+
+// NotifyCategories 全部类别，按展示顺序
+var NotifyCategories = []NotifyCategory{
+	NotifyCategorySecurity,
+	NotifyCategoryBilling,
+	NotifyCategoryService,
+	NotifyCategoryMarketing,
+	NotifyCategorySupport,
+}
+
+// Valid 是否为已知类别
+func (c NotifyCategory) Valid() bool {
+	for _, known := range NotifyCategories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Mandatory 该类别是否不允许用户关闭
+func (c NotifyCategory) Mandatory() bool {
+	return c == NotifyCategorySecurity
+}
+
+// orDefault 未指定类别的通知按服务通知处理
+func (c NotifyCategory) orDefault() NotifyCategory {
+	if c == "" {
+		return NotifyCategoryService
+	}
+	return c
+}
+
+// NotifyChannel 通知渠道
+type NotifyChannel string
+
+const (
+	NotifyChannelPush  NotifyChannel = "push"
+	NotifyChannelEmail NotifyChannel = "email"
+)
+
+// NotificationPreference 用户在某类别上的渠道开关
+// 只存用户改过的类别；没有记录即全部开启
+type NotificationPreference struct {
+	ID        uint64    `gorm:"primarykey" json:"-"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	UserID   uint64         `gorm:"not null;uniqueIndex:idx_notify_pref_user_category" json:"-"`
+	Category NotifyCategory `gorm:"type:varchar(20);not null;uniqueIndex:idx_notify_pref_user_category" json:"category"`
+	Push     bool           `gorm:"not null" json:"push"`
+	Email    bool           `gorm:"not null" json:"email"`
+}
+
+// TableName 指定表名
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// InboxNotification 站内收件箱条目
+// 推送与邮件发出时各记一条，每个用户只保留最近 inboxKeepPerUser 条
+type InboxNotification struct {
+	ID        uint64    `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+
+	UserID   uint64         `gorm:"not null;index:idx_inbox_user_read" json:"-"`
+	Category NotifyCategory `gorm:"type:varchar(20);not null" json:"category"`
+	Channel  NotifyChannel  `gorm:"type:varchar(20);not null" json:"channel"`
+	Title    string         `gorm:"type:varchar(255);not null" json:"title"`
+	Body     string         `gorm:"type:text" json:"body"`
+	Data     string         `gorm:"type:text" json:"data,omitempty"` // 推送自定义数据（JSON），供客户端跳转
+	ReadAt   *time.Time     `gorm:"index:idx_inbox_user_read" json:"readAt"`
+}
+
+// TableName 指定表名
+func (InboxNotification) TableName() string {
+	return "inbox_notifications"
+}
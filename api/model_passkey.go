@@ -0,0 +1,41 @@
+package center
+
+import (
+	"strings"
+	"time"
+)
+
+// ========================= Passkey（WebAuthn） =========================
+
+// PasskeyCredential 用户登记的 passkey，用于 Web 控制台免密登录。
+// 凭证只在登记时的品牌（即 RP ID）下有效。
+type PasskeyCredential struct {
+	ID        uint64    `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"-"`
+
+	UserID       uint64 `gorm:"not null;index" json:"-"`
+	Brand        string `gorm:"type:varchar(20);not null" json:"-"`
+	CredentialID string `gorm:"type:varchar(255);not null;uniqueIndex" json:"credentialId"` // base64url
+	PublicKey    string `gorm:"type:text;not null" json:"-"`                                // COSE_Key 原始 CBOR，base64url
+	Algorithm    int64  `gorm:"not null" json:"algorithm"`                                  // COSE 算法：-7 ES256 / -8 EdDSA / -257 RS256
+	SignCount    uint32 `gorm:"not null;default:0" json:"-"`
+	AAGUID       string `gorm:"column:aaguid;type:varchar(36)" json:"aaguid"`
+	Transports   string `gorm:"type:varchar(100)" json:"-"`           // 逗号分隔，登录时回传给浏览器
+	Synced       bool   `gorm:"not null;default:false" json:"synced"` // BS 标志：已在云端同步（iCloud 钥匙串、Google 密码管理器等）
+	Remark       string `gorm:"type:varchar(100)" json:"remark"`
+	LastUsedAt   int64  `gorm:"not null;default:0" json:"lastUsedAt"`
+}
+
+// TableName 指定表名
+func (PasskeyCredential) TableName() string {
+	return "passkey_credentials"
+}
+
+// TransportList 拆分 Transports
+func (p *PasskeyCredential) TransportList() []string {
+	if p.Transports == "" {
+		return nil
+	}
+	return strings.Split(p.Transports, ",")
+}
@@ -0,0 +1,72 @@
+package center
+
+import "time"
+
+// ========================= Rule-miss 遥测 =========================
+
+// RuleMiss 一条原始 rule-miss 观测（客户端匿名上报，保留 ruleMissRawRetentionDays 天）。
+// 只存哈希，不存明文域名；明文只会落在 RuleMissReveal 与 RuleMissAggregate 上。
+// 客户端按 salt_day 轮换盐值，同一域名在不同日子的 hash16 不同，所以
+// (salt_day, hash16) 才是一个域名的唯一键。
+type RuleMiss struct {
+	ID        uint64    `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	SaltDay string `gorm:"type:varchar(10);not null;index:idx_rule_miss_hash,priority:1"`
+	Hash16  string `gorm:"type:varchar(16);not null;index:idx_rule_miss_hash,priority:2"`
+	// ReporterHash 上报方的匿名标识：hash(salt_day|来源 IP) 截断，按日轮换，
+	// 只用于统计"有多少不同设备报过这个哈希"，不能跨日关联同一设备
+	ReporterHash string `gorm:"type:varchar(16);not null"`
+
+	Country       string `gorm:"type:varchar(2);not null"`
+	WeekBucket    string `gorm:"type:varchar(8);not null"`
+	Protocol      string `gorm:"type:varchar(8);not null;default:''"`
+	ClientVersion string `gorm:"type:varchar(32);not null;default:''"`
+	RulesVersion  string `gorm:"type:varchar(32);not null;default:''"`
+}
+
+// TableName 指定表名
+func (RuleMiss) TableName() string {
+	return "rule_misses"
+}
+
+// RuleMissAggregate 按 (salt_day, hash16) 聚合的 rule-miss 计数（保留 ruleMissAggregateRetentionDays 天）。
+// Devices 达到 ruleMissRevealK 之前不接受明文；达到之后由 RuleMissReveal 投票，
+// 第一个获得 ruleMissRevealQuorum 个不同上报方支持的明文写入 Revealed。
+type RuleMissAggregate struct {
+	ID        uint64 `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time `gorm:"index"`
+
+	SaltDay string `gorm:"type:varchar(10);not null;uniqueIndex:uk_rule_miss_agg,priority:1"`
+	Hash16  string `gorm:"type:varchar(16);not null;uniqueIndex:uk_rule_miss_agg,priority:2"`
+
+	Hits    int64 `gorm:"not null;default:0"` // 上报总次数
+	Devices int   `gorm:"not null;default:0"` // 不同上报方数（k-匿名判定依据）
+
+	Revealed   string `gorm:"type:varchar(253);not null;default:'';index"` // 明文域名，空 = 未揭示
+	RevealedAt *time.Time
+}
+
+// TableName 指定表名
+func (RuleMissAggregate) TableName() string {
+	return "rule_miss_aggregates"
+}
+
+// RuleMissReveal 一个上报方为已过 k 的哈希提交的明文（保留 ruleMissRawRetentionDays 天）。
+// hash16 的盐值只在客户端，服务端无法校验明文与哈希是否对应，所以单个上报方
+// 提交的明文不可信：每个上报方对每个哈希只算一票（重复提交覆盖先前的明文）。
+type RuleMissReveal struct {
+	ID        uint64    `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	SaltDay      string `gorm:"type:varchar(10);not null;uniqueIndex:uk_rule_miss_reveal,priority:1"`
+	Hash16       string `gorm:"type:varchar(16);not null;uniqueIndex:uk_rule_miss_reveal,priority:2"`
+	ReporterHash string `gorm:"type:varchar(16);not null;uniqueIndex:uk_rule_miss_reveal,priority:3"`
+	Revealed     string `gorm:"type:varchar(253);not null"`
+}
+
+// TableName 指定表名
+func (RuleMissReveal) TableName() string {
+	return "rule_miss_reveals"
+}
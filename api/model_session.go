@@ -0,0 +1,35 @@
+package center
+
+import "time"
+
+// ========================= 登录会话 =========================
+
+// AuthSession 一次登录产生的 token 家族（refresh token family）。
+// JWT 通过 sid claim 指向 FamilyID；refresh 轮换 token 但沿用同一家族，
+// 旧 refresh token 被再次使用即视为泄漏，整个家族吊销。
+// Web Cookie 会话（UDID 为空）没有 refresh token，同样可单独吊销。
+type AuthSession struct {
+	ID        uint64    `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"-"`
+
+	UserID      uint64 `gorm:"not null;index" json:"-"`
+	FamilyID    string `gorm:"type:varchar(32);not null;uniqueIndex" json:"-"`
+	UDID        string `gorm:"column:udid;type:varchar(255);not null;default:'';index" json:"-"` // 空 = Web Cookie 会话
+	ClientClass string `gorm:"type:varchar(16);not null" json:"clientClass"`                     // web | service | router
+	IPCountry   string `gorm:"column:ip_country;type:varchar(2);not null;default:''" json:"ipCountry"`
+	LastSeenAt  int64  `gorm:"not null;default:0" json:"lastSeenAt"`
+
+	RevokedAt    int64  `gorm:"not null;default:0;index" json:"-"` // 0 = 有效
+	RevokeReason string `gorm:"type:varchar(32)" json:"-"`         // 见 sessionRevoke* 常量
+}
+
+// TableName 指定表名
+func (AuthSession) TableName() string {
+	return "auth_sessions"
+}
+
+// IsRevoked 会话是否已吊销
+func (s *AuthSession) IsRevoked() bool {
+	return s.RevokedAt != 0
+}
@@ -0,0 +1,54 @@
+package center
+
+import "time"
+
+// ========================= 遥测分析 Rollup =========================
+
+// TelemetryRollup 按 (UTC 日, 维度, 维度值) 汇总的 connection 事件，由
+// TaskTypeTelemetryRollup 从 telemetry_events 整日重算（幂等），管理后台只读此表。
+// Dimension = "all" 时 Value 为空，是当日全量。
+type TelemetryRollup struct {
+	ID        uint64 `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Day       string `gorm:"type:varchar(10);not null;uniqueIndex:uk_telemetry_rollup,priority:1"`
+	Dimension string `gorm:"type:varchar(16);not null;uniqueIndex:uk_telemetry_rollup,priority:2"`
+	Value     string `gorm:"type:varchar(128);not null;uniqueIndex:uk_telemetry_rollup,priority:3"`
+
+	Attempts  int64 `gorm:"not null;default:0"` // connection 事件数
+	Successes int64 `gorm:"not null;default:0"` // outcome.success = true
+	Failures  int64 `gorm:"not null;default:0"` // outcome.success = false
+	Devices   int64 `gorm:"not null;default:0"` // 当日不同设备数（跨日不可相加）
+
+	// 建连耗时：求和 + 分桶，足够给出均值与分布，无需回扫明细
+	ConnectMsSum   int64 `gorm:"not null;default:0"`
+	ConnectMsCount int64 `gorm:"not null;default:0"`
+	ConnectUnder1s int64 `gorm:"column:connect_under1s;not null;default:0"`
+	ConnectUnder3s int64 `gorm:"column:connect_under3s;not null;default:0"` // [1s, 3s)
+	ConnectOver3s  int64 `gorm:"column:connect_over3s;not null;default:0"`
+}
+
+// TableName 指定表名
+func (TelemetryRollup) TableName() string {
+	return "telemetry_rollups"
+}
+
+// TelemetryFailureRollup 按 (UTC 日, 维度, 维度值, 失败原因) 汇总的失败次数
+type TelemetryFailureRollup struct {
+	ID        uint64 `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Day       string `gorm:"type:varchar(10);not null;uniqueIndex:uk_telemetry_failure_rollup,priority:1"`
+	Dimension string `gorm:"type:varchar(16);not null;uniqueIndex:uk_telemetry_failure_rollup,priority:2"`
+	Value     string `gorm:"type:varchar(128);not null;uniqueIndex:uk_telemetry_failure_rollup,priority:3"`
+	Reason    string `gorm:"type:varchar(64);not null;uniqueIndex:uk_telemetry_failure_rollup,priority:4"` // 空 = 客户端未上报原因
+
+	Count int64 `gorm:"not null;default:0"`
+}
+
+// TableName 指定表名
+func (TelemetryFailureRollup) TableName() string {
+	return "telemetry_failure_rollups"
+}
@@ -43,12 +43,18 @@ const (
 	ErrorLicenseKeyAlreadyRedeemed ErrorCode = 400011 // 用户已使用过授权码
 	ErrorProxyMembersDeprecated    ErrorCode = 400012 // 代付成员管理已下线
 	ErrorVerificationCodeExpired   ErrorCode = 400013 // 验证码已过期或未发送
+	ErrorTwoFactorRequired         ErrorCode = 400014 // 需要两步验证码（凭证已通过，带 totpCode 重新提交）
+	ErrorInvalidTwoFactorCode      ErrorCode = 400015 // 两步验证码或恢复码错误
+	ErrorOAuthEmailUnverified      ErrorCode = 400016 // 第三方账号没有已验证的邮箱，无法关联或注册
+	ErrorCaptchaRequired           ErrorCode = 400017 // 登录风控要求人机验证（带 captchaToken 重新提交）
+	ErrorEmailCodeRequired         ErrorCode = 400018 // 登录风控拒绝密码登录，须改用邮箱验证码
 
 	// Router class system error codes (added 2026-05-22)
 	ErrorPlanNoRouter        ErrorCode = 402001 // 套餐不支持路由器
 	ErrorRouterDeviceLimit   ErrorCode = 403001 // 路由器登录数量已达上限
 	ErrorDeviceClassMismatch ErrorCode = 403002 // 设备身份与历史注册类型不符
 	ErrorBrandMismatch       ErrorCode = 403003 // 账号品牌与请求品牌不符
+	ErrorTwoFactorSetupRequired ErrorCode = 403004 // 管理员账号须先启用两步验证
 
 	// Tier system error codes (added 2026-04-20)
 	ErrorTierMismatch            ErrorCode = 422001 // Plan tier 与 user tier 不匹配（续费场景）
@@ -59,7 +65,8 @@ const (
 	ErrorPaymentChannelUnavailable ErrorCode = 405001 // 当前品牌不支持该支付渠道
 
 	// 409xxx: 资源冲突（同品牌内唯一性被占用）
-	ErrorEmailAlreadyInUse ErrorCode = 409001 // 邮箱已被同品牌下的其他账号绑定
+	ErrorEmailAlreadyInUse     ErrorCode = 409001 // 邮箱已被同品牌下的其他账号绑定
+	ErrorIdentityAlreadyLinked ErrorCode = 409002 // 第三方账号已绑定同品牌下的其他账号
 )
 
 type DataAny struct{}
@@ -70,12 +70,25 @@ func SetupRouter() *gin.Engine {
 			auth.POST("/web-login", api_web_auth)
 			// Web 密码登录（无设备绑定，cookie 认证）
 			auth.POST("/web-login/password", api_web_password_login)
+			// Web passkey 登录（discoverable credential，无需邮箱）
+			auth.POST("/web-login/passkey/options", api_passkey_login_options)
+			auth.POST("/web-login/passkey", api_passkey_login)
+			// 第三方登录（Google / Apple / GitHub）。callback 由 provider 回跳，Apple 用 form_post
+			auth.GET("/oauth/providers", api_oauth_providers)
+			auth.POST("/oauth/:provider/start", api_oauth_start)
+			auth.GET("/oauth/:provider/callback", api_oauth_callback)
+			auth.POST("/oauth/:provider/callback", api_oauth_callback)
+			auth.POST("/web-login/oauth", api_oauth_login)
 			// 刷新 token
 			auth.POST("/refresh", api_refresh_token)
 			// 设备登出
 			auth.POST("/logout", AuthRequired(), api_logout)
 			// OTT exchange — public endpoint, no auth required
 			auth.GET("/ott/exchange", api_exchange_ott)
+			// 无交互登录（CI / k2-mcp）：access key、设备授权码
+			auth.POST("/access-key", api_access_key_login)
+			auth.POST("/device-code", api_device_code)
+			auth.POST("/device-token", api_device_token)
 			// 设备udid认证（已废弃，保留用于向后兼容，总是返回 403）
 			auth.POST("/auth-with-device", api_auth_with_device)
 		}
@@ -146,6 +159,14 @@ func SetupRouter() *gin.Engine {
 			user.PUT("/devices/:uuid/remark", AuthRequired(), EnforceDeviceClass(), api_update_device_remark)
 			// 获取设备列表
 			user.GET("/devices", AuthRequired(), EnforceDeviceClass(), api_get_devices)
+			// 我的流量：最近 N 天按设备、按日
+			user.GET("/traffic", AuthRequired(), EnforceDeviceClass(), api_get_user_traffic)
+			// 通知偏好与收件箱
+			user.GET("/notification-preferences", AuthRequired(), EnforceDeviceClass(), api_get_notification_preferences)
+			user.PUT("/notification-preferences", AuthRequired(), EnforceDeviceClass(), api_update_notification_preferences)
+			user.GET("/inbox", AuthRequired(), EnforceDeviceClass(), api_user_inbox)
+			user.GET("/inbox/unread", AuthRequired(), EnforceDeviceClass(), api_user_inbox_unread)
+			user.POST("/inbox/read", AuthRequired(), EnforceDeviceClass(), api_user_inbox_read)
 			// 创建订单
 			user.POST("/orders", AuthRequired(), EnforceDeviceClass(), api_create_order)
 			// iOS StoreKit IAP：客户端购买完成后上报 transactionId，服务端复核入账
@@ -198,8 +219,31 @@ func SetupRouter() *gin.Engine {
 			user.POST("/tickets/:id/reply", AuthRequired(), EnforceDeviceClass(), api_user_ticket_reply)
 			// 设置/更新密码
 			user.POST("/password", AuthRequired(), EnforceDeviceClass(), api_set_password)
+			// 两步验证（TOTP）：启用流程为 setup → enable；管理角色账号须启用才能访问后台
+			user.GET("/2fa", AuthRequired(), EnforceDeviceClass(), api_get_two_factor)
+			user.POST("/2fa/setup", AuthRequired(), EnforceDeviceClass(), api_setup_two_factor)
+			user.POST("/2fa/enable", AuthRequired(), EnforceDeviceClass(), api_enable_two_factor)
+			user.POST("/2fa/disable", AuthRequired(), EnforceDeviceClass(), api_disable_two_factor)
+			user.POST("/2fa/recovery-codes", AuthRequired(), EnforceDeviceClass(), api_regenerate_recovery_codes)
+			// Passkey（WebAuthn）管理
+			user.GET("/passkeys", AuthRequired(), EnforceDeviceClass(), api_list_passkeys)
+			user.POST("/passkeys/register/options", AuthRequired(), EnforceDeviceClass(), api_passkey_register_options)
+			user.POST("/passkeys/register", AuthRequired(), EnforceDeviceClass(), api_passkey_register)
+			user.PUT("/passkeys/:id", AuthRequired(), EnforceDeviceClass(), api_update_passkey)
+			user.DELETE("/passkeys/:id", AuthRequired(), EnforceDeviceClass(), api_delete_passkey)
+			// 已绑定的第三方登录
+			user.GET("/oauth", AuthRequired(), EnforceDeviceClass(), api_list_oauth_identities)
+			user.POST("/oauth/link", AuthRequired(), EnforceDeviceClass(), api_oauth_link)
+			user.POST("/oauth/:provider/link/start", AuthRequired(), EnforceDeviceClass(), api_oauth_link_start)
+			user.DELETE("/oauth/:provider", AuthRequired(), EnforceDeviceClass(), api_oauth_unlink)
+			// 登录会话：列表与单个吊销（当前会话走 /auth/logout）
+			user.GET("/sessions", AuthRequired(), EnforceDeviceClass(), api_list_sessions)
+			user.DELETE("/sessions/:id", AuthRequired(), EnforceDeviceClass(), api_revoke_session)
 			// OTT 签发 — webapp → web auth handoff
 			user.POST("/ott", AuthRequired(), EnforceDeviceClass(), api_issue_ott)
+			// 设备授权码：查询与批准（批准后签发 OTT 供设备兑换）
+			user.GET("/device-auth/:code", AuthRequired(), EnforceDeviceClass(), api_get_device_auth)
+			user.POST("/device-auth/approve", AuthRequired(), EnforceDeviceClass(), api_approve_device_auth)
 			// 连接质量评分
 			user.POST("/connection-rating", AuthRequired(), EnforceDeviceClass(), api_create_connection_rating)
 		}
@@ -265,9 +309,9 @@ func SetupRouter() *gin.Engine {
 		telemetry := api.Group("/telemetry")
 		log.Debugf(ctx, "registering /api/telemetry group")
 		{
-			// Rule-miss telemetry (Phase 1): anonymous, unauthenticated,
-			// drop-on-receive. Rate limited by source IP inside the
-			// handler. See api/telemetry.go for schema + design notes.
+			// Rule-miss telemetry (Phase 2): anonymous, unauthenticated,
+			// persisted with k-anonymous reveal. Rate limited by source IP
+			// inside the handler. See api/telemetry.go for schema + design notes.
 			telemetry.POST("/rule_miss", api_telemetry_rule_miss)
 
 			// Submit batch telemetry events (requires device auth)
@@ -344,6 +388,10 @@ func SetupRouter() *gin.Engine {
 		admin.PUT("/users/:uuid/email", api_admin_update_user_email)
 		// 用户密码管理（管理员代为重置）
 		admin.POST("/users/:uuid/password", api_admin_set_user_password)
+		// 用户两步验证重置（走审批）
+		admin.POST("/users/:uuid/2fa/reset", api_admin_reset_user_two_factor)
+		// 全局登出：吊销用户全部会话（凭证泄漏应急，无需审批）
+		admin.POST("/users/:uuid/sessions/revoke", api_admin_revoke_user_sessions)
 		// 用户角色管理（仅超级管理员）
 		admin.PUT("/users/:uuid/roles", api_admin_set_user_roles)
 		admin.POST("/users/:uuid/devices/:udid/test-token", api_admin_issue_test_token)
@@ -352,6 +400,9 @@ func SetupRouter() *gin.Engine {
 		// 用户封禁管理（管理员直接执行，无需审批）
 		admin.POST("/users/:uuid/block", api_admin_block_user)
 		admin.POST("/users/:uuid/unblock", api_admin_unblock_user)
+		// 登录风控：决策记录查询、解除 IP 临时封禁
+		admin.GET("/login-risk/events", api_admin_list_login_risk_events)
+		admin.POST("/login-risk/unblock", api_admin_login_risk_unblock)
 
 		// Device statistics
 		admin.GET("/devices/statistics", api_admin_get_device_statistics)
@@ -394,6 +445,11 @@ func SetupRouter() *gin.Engine {
 		// 问卷调查统计
 		admin.GET("/surveys/stats", api_admin_survey_stats)
 
+		// 连接质量看板（读 telemetry rollup 表）
+		admin.GET("/telemetry/overview", api_admin_telemetry_overview)
+		admin.GET("/telemetry/breakdown", api_admin_telemetry_breakdown)
+		admin.GET("/telemetry/failures", api_admin_telemetry_failures)
+
 		// Strategy rules management
 		strategy := admin.Group("/strategy")
 		{
@@ -402,6 +458,16 @@ func SetupRouter() *gin.Engine {
 			strategy.GET("/rules/:version", api_admin_strategy_get)               // Get specific version
 			strategy.PUT("/rules/:version/activate", api_admin_strategy_activate) // Activate version
 			strategy.DELETE("/rules/:version", api_admin_strategy_delete)         // Delete version
+			strategy.PUT("/rules/:version/rollout", api_admin_strategy_rollout)   // Staged rollout percent
+			strategy.GET("/rules/:version/compare", api_admin_strategy_compare)   // Outcomes vs active version
+			strategy.GET("/rule-misses/top", api_admin_rule_miss_top)             // Top missed domains (k-anonymous)
+
+			// A/B cohorts pinned to a rules version
+			strategy.GET("/cohorts", api_admin_strategy_cohort_list)
+			strategy.POST("/cohorts", api_admin_strategy_cohort_create)
+			strategy.DELETE("/cohorts/:id", api_admin_strategy_cohort_delete)
+			strategy.POST("/cohorts/:id/devices", api_admin_strategy_cohort_add_devices)
+			strategy.DELETE("/cohorts/:id/devices/:udid", api_admin_strategy_cohort_remove_device)
 		}
 
 	}
@@ -452,6 +518,13 @@ func SetupRouter() *gin.Engine {
 		opsAdmin.GET("/cloud/regions", RoleRequired(viewOrEdit), api_admin_list_cloud_regions)
 		opsAdmin.GET("/cloud/plans", RoleRequired(viewOrEdit), api_admin_list_cloud_plans)
 		opsAdmin.GET("/cloud/images", RoleRequired(viewOrEdit), api_admin_list_cloud_images)
+		opsAdmin.GET("/cloud/provider-metrics", RoleRequired(viewOrEdit), api_admin_cloud_provider_metrics)
+		opsAdmin.GET("/cloud/ip-changes", RoleRequired(viewOrEdit), api_admin_list_cloud_ip_changes)
+		opsAdmin.GET("/cloud/rotation/signals", RoleRequired(viewOrEdit), api_admin_cloud_rotation_signals)
+		opsAdmin.GET("/cloud/fleet/plan", RoleRequired(viewOrEdit), api_admin_cloud_fleet_plan)
+
+		// 推送投递指标
+		opsAdmin.GET("/push/metrics", RoleRequired(viewOrEdit), api_admin_push_metrics)
 
 		// 云实例（读写）
 		opsAdmin.POST("/cloud/instances/sync", RoleRequired(RoleDevopsEditor), api_admin_sync_all_cloud_instances)
@@ -459,6 +532,7 @@ func SetupRouter() *gin.Engine {
 		opsAdmin.PUT("/cloud/instances/:id/traffic-config", RoleRequired(RoleDevopsEditor), api_admin_update_traffic_config)
 		opsAdmin.POST("/cloud/instances", RoleRequired(RoleDevopsEditor), api_admin_create_cloud_instance)
 		opsAdmin.DELETE("/cloud/instances/:id", RoleRequired(RoleDevopsEditor), api_admin_delete_cloud_instance)
+		opsAdmin.POST("/cloud/fleet/apply", RoleRequired(RoleDevopsEditor), api_admin_cloud_fleet_apply)
 
 		// 专属节点运维任务队列（外部 AI agent / 运维消费）
 		opsAdmin.GET("/node-operations", RoleRequired(viewOrEdit), adminListNodeOperations)
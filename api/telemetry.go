@@ -10,12 +10,26 @@ import (
 )
 
 // ------------------------------------------------------------------
-// Rule-miss telemetry endpoint (Phase 1 — "dark instrumentation")
+// Rule-miss telemetry endpoint (Phase 2 — persistence + k-anonymous reveal)
 //
-// Accepts anonymous rule-miss batches from k2 clients and drops them
-// on the floor. Phase 1 has no persistence — we only validate the wire
-// format, emit an INFO log per accepted batch, and update a metrics
-// counter. Phase 2 will add the RuleMiss GORM model + retention worker.
+// Accepts anonymous rule-miss batches from k2 clients. Raw hashed
+// records land in rule_misses, per-(salt_day, hash16) counters in
+// rule_miss_aggregates (see logic_rule_miss.go). A record's Revealed
+// plaintext is only considered once its hash has been reported by
+// ruleMissRevealK distinct reporters, and only stored once
+// ruleMissRevealQuorum distinct reporters sent the same plaintext; the
+// response lists the batch hashes that have crossed k and still await a
+// reveal. Retention is handled by the stats retention sweep
+// (worker_stats_retention.go).
+//
+// A "reporter" is the source network, not the device: batches carry no
+// device identity, so ruleMissReporterHash stands in for one, keyed on the
+// IPv6 /64 or IPv4 /24 of the source address. k therefore counts distinct
+// source prefixes per salt day. Devices behind one NAT, egress or /24
+// (carrier CGNAT, an office, a VPN exit) merge into a single reporter and
+// undercount, while one device whose network changes during the day (mobile
+// handover, Wi-Fi to cellular) is counted once per prefix and inflates both
+// k and the reveal quorum.
 //
 // Route: POST /api/telemetry/rule_miss  (UNAUTHENTICATED, rate limited)
 //
@@ -25,6 +39,7 @@ import (
 const (
 	ruleMissSchemaVersion = 1
 	ruleMissMaxRecords    = 200
+	ruleMissMaxVersionLen = 32
 )
 
 // RuleMissBatch is the JSON body accepted at /api/telemetry/rule_miss.
@@ -38,8 +53,8 @@ type RuleMissBatch struct {
 }
 
 // RuleMissRecord is a single hashed rule-miss observation.
-// Revealed is always empty in Phase 1 — Phase 2 k-anonymous reveal will
-// populate it.
+// Revealed carries the plaintext domain only for hashes the server
+// listed in a previous DataRuleMissAck; below k it is discarded.
 type RuleMissRecord struct {
 	Hash16     string `json:"hash16"`
 	Country    string `json:"country"`
@@ -48,6 +63,14 @@ type RuleMissRecord struct {
 	Revealed   string `json:"revealed,omitempty"`
 }
 
+// DataRuleMissAck is the response to an accepted batch.
+type DataRuleMissAck struct {
+	// RevealHashes are hashes from this batch that passed the k-anonymity
+	// threshold but have no plaintext yet. Clients may set Revealed for
+	// them in a later batch.
+	RevealHashes []string `json:"reveal_hashes"`
+}
+
 // Validation regexes — compiled once at package init.
 var (
 	ruleMissHash16Re     = regexp.MustCompile(`^[0-9a-f]{16}$`)
@@ -57,15 +80,13 @@ var (
 
 // api_telemetry_rule_miss handles POST /api/telemetry/rule_miss.
 //
-// Phase 1 behavior: validate, log, drop. No DB writes.
-//
-// Returns 200 on accept (empty body via Success) and an error code on
-// validation failure. The HTTP body contract follows the Center
+// Returns 200 on accept (DataRuleMissAck via Success) and an error code on
+// validation or persistence failure. The HTTP body contract follows the Center
 // convention: HTTP status is always 200, business state in JSON code.
 func api_telemetry_rule_miss(c *gin.Context) {
 	// Basic in-memory rate limit: 10 req/min per source IP. This is a
 	// defensive guard — the endpoint is public and we don't want a
-	// single IP flooding Center. Phase 2 will add proper metering.
+	// single IP flooding Center.
 	if !ruleMissRateLimiter.Allow(c.ClientIP()) {
 		Error(c, ErrorTooManyRequests, "rate limited")
 		return
@@ -93,6 +114,16 @@ func api_telemetry_rule_miss(c *gin.Context) {
 		return
 	}
 
+	// salt_day keys the aggregates, so a persisted batch must carry one.
+	if !ruleMissSaltDayRe.MatchString(req.SaltDay) {
+		Error(c, ErrorInvalidArgument, "invalid salt_day")
+		return
+	}
+	if len(req.ClientVersion) > ruleMissMaxVersionLen || len(req.RulesVersion) > ruleMissMaxVersionLen {
+		Error(c, ErrorInvalidArgument, "invalid version")
+		return
+	}
+
 	// Validate every record. Any single malformed record rejects the
 	// whole batch — clients shouldn't upload garbage.
 	for i, rec := range req.Records {
@@ -117,30 +148,32 @@ func api_telemetry_rule_miss(c *gin.Context) {
 			Error(c, ErrorInvalidArgument, "invalid protocol")
 			return
 		}
-		// Revealed MUST be empty in Phase 1.
-		if rec.Revealed != "" {
-			Error(c, ErrorInvalidArgument, "revealed not supported in phase 1")
+		// Revealed is optional; when present it must at least look like
+		// a hostname. Whether it is kept depends on k (recordRuleMisses).
+		if rec.Revealed != "" && !validRuleMissDomain(rec.Revealed) {
+			Error(c, ErrorInvalidArgument, "invalid revealed")
 			return
 		}
 	}
 
-	// Pick a representative country for the log line. If the batch
-	// contains mixed countries, pick the first — we're not persisting
-	// anyway.
-	sampleCountry := req.Records[0].Country
-	log.Infof(c, "rule_miss: got %d records from country=%s client=%s salt_day=%s",
-		len(req.Records), sampleCountry, req.ClientVersion, req.SaltDay)
+	reporter := ruleMissReporterHash(c, req.SaltDay, c.ClientIP())
+	pending, err := recordRuleMisses(c, &req, reporter)
+	if err != nil {
+		log.Errorf(c, "rule_miss: failed to persist %d records: %v", len(req.Records), err)
+		Error(c, ErrorSystemError, "failed to save records")
+		return
+	}
 
-	// TODO(phase-2): persist to DB via GORM RuleMiss model +
-	// retention worker (raw 3 days, aggregated 30 days).
+	log.Infof(c, "rule_miss: stored %d records from country=%s client=%s salt_day=%s pending_reveal=%d",
+		len(req.Records), req.Records[0].Country, req.ClientVersion, req.SaltDay, len(pending))
 
-	SuccessEmpty(c)
+	Success(c, &DataRuleMissAck{RevealHashes: pending})
 }
 
 // ------------------------------------------------------------------
 // In-memory per-IP token bucket rate limiter.
 // 10 requests/minute per source IP. Reset each minute.
-// Phase 1 only — deliberately simple; Phase 2 can move to Redis.
+// Deliberately simple — per-process, no Redis.
 // ------------------------------------------------------------------
 
 type ruleMissIPLimiter struct {
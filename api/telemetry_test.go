@@ -11,11 +11,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
 )
 
 // setupRuleMissTestRouter creates a minimal gin router with only the
 // rule-miss endpoint. Mirrors the pattern used in api_stats_test.go:
-// no global config, no mock DB — the handler drops records in Phase 1.
+// no global config, no mock DB. Validation failures and empty batches
+// return before any DB call; tests that store records need skipIfNoConfig.
 func setupRuleMissTestRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
@@ -55,6 +57,19 @@ func postRuleMiss(t *testing.T, router *gin.Engine, body any) *httptest.Response
 	return w
 }
 
+// cleanupRuleMissSaltDay removes rule-miss rows for a test salt day before
+// and after the test, so reruns against a shared DB start clean.
+func cleanupRuleMissSaltDay(t *testing.T, saltDay string) {
+	t.Helper()
+	purge := func() {
+		db.Get().Where("salt_day = ?", saltDay).Delete(&RuleMiss{})
+		db.Get().Where("salt_day = ?", saltDay).Delete(&RuleMissAggregate{})
+		db.Get().Where("salt_day = ?", saltDay).Delete(&RuleMissReveal{})
+	}
+	purge()
+	t.Cleanup(purge)
+}
+
 func decodeResp(t *testing.T, w *httptest.ResponseRecorder) Response[DataAny] {
 	t.Helper()
 	var resp Response[DataAny]
@@ -63,14 +78,17 @@ func decodeResp(t *testing.T, w *httptest.ResponseRecorder) Response[DataAny] {
 }
 
 func TestRuleMiss_ValidBatch(t *testing.T) {
+	skipIfNoConfig(t)
 	resetRuleMissLimiter(t)
 	router := setupRuleMissTestRouter()
+	// 1999 salt day: cleanup must never touch genuine telemetry.
+	cleanupRuleMissSaltDay(t, "1999-04-11")
 
 	batch := RuleMissBatch{
 		SchemaVersion: 1,
 		ClientVersion: "0.4.3",
 		RulesVersion:  "2026-04",
-		SaltDay:       "2026-04-11",
+		SaltDay:       "1999-04-11",
 		Records: []RuleMissRecord{
 			validRecord(1),
 			validRecord(2),
@@ -80,6 +98,10 @@ func TestRuleMiss_ValidBatch(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	resp := decodeResp(t, w)
 	assert.EqualValues(t, ErrorNone, resp.Code)
+
+	var stored int64
+	db.Get().Model(&RuleMiss{}).Where("salt_day = ?", "1999-04-11").Count(&stored)
+	assert.Equal(t, int64(2), stored)
 }
 
 func TestRuleMiss_EmptyRecords(t *testing.T) {
@@ -174,14 +196,29 @@ func TestRuleMiss_InvalidWeekBucket(t *testing.T) {
 	assert.EqualValues(t, ErrorInvalidArgument, resp.Code)
 }
 
-func TestRuleMiss_RevealedRejectedInPhase1(t *testing.T) {
+func TestRuleMiss_InvalidSaltDay(t *testing.T) {
+	resetRuleMissLimiter(t)
+	router := setupRuleMissTestRouter()
+
+	batch := RuleMissBatch{
+		SchemaVersion: 1,
+		SaltDay:       "yesterday",
+		Records:       []RuleMissRecord{validRecord(1)},
+	}
+	w := postRuleMiss(t, router, batch)
+	resp := decodeResp(t, w)
+	assert.EqualValues(t, ErrorInvalidArgument, resp.Code)
+}
+
+func TestRuleMiss_InvalidRevealed(t *testing.T) {
 	resetRuleMissLimiter(t)
 	router := setupRuleMissTestRouter()
 
 	bad := validRecord(1)
-	bad.Revealed = "example.com"
+	bad.Revealed = "not a domain"
 	batch := RuleMissBatch{
 		SchemaVersion: 1,
+		SaltDay:       "2026-04-11",
 		Records:       []RuleMissRecord{bad},
 	}
 	w := postRuleMiss(t, router, batch)
@@ -191,14 +228,15 @@ func TestRuleMiss_RevealedRejectedInPhase1(t *testing.T) {
 
 // TestRuleMiss_NoAuthRequired verifies that the endpoint accepts
 // requests with no Authorization header, no device headers, and no
-// cookies — it is explicitly anonymous.
+// cookies — it is explicitly anonymous. An empty batch walks the same
+// auth-free path without needing a DB.
 func TestRuleMiss_NoAuthRequired(t *testing.T) {
 	resetRuleMissLimiter(t)
 	router := setupRuleMissTestRouter()
 
 	batch := RuleMissBatch{
 		SchemaVersion: 1,
-		Records:       []RuleMissRecord{validRecord(1)},
+		SaltDay:       "2026-04-11",
 	}
 	bodyBytes, _ := json.Marshal(batch)
 	req := httptest.NewRequest(http.MethodPost, "/api/telemetry/rule_miss", bytes.NewBuffer(bodyBytes))
@@ -212,13 +250,15 @@ func TestRuleMiss_NoAuthRequired(t *testing.T) {
 
 // TestRuleMiss_RateLimit fires 11 requests from the same client IP
 // within the 1-minute window and verifies the 11th is rate limited.
+// The limiter runs before the body is parsed, so empty batches count
+// the same as full ones.
 func TestRuleMiss_RateLimit(t *testing.T) {
 	resetRuleMissLimiter(t)
 	router := setupRuleMissTestRouter()
 
 	batch := RuleMissBatch{
 		SchemaVersion: 1,
-		Records:       []RuleMissRecord{validRecord(1)},
+		SaltDay:       "2026-04-11",
 	}
 	bodyBytes, _ := json.Marshal(batch)
 
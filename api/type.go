@@ -258,6 +258,7 @@ type DataLoginRequest struct {
 	Remark           string `json:"remark"`                              // 设备备注
 	Language         string `json:"language"`                            // 用户语言偏好（可选）
 	InviteCode       string `json:"inviteCode"`                          // 邀请码（可选，仅未激活用户可设置）
+	TOTPCode         string `json:"totpCode"`                            // 两步验证码或恢复码（已启用两步验证时必填）
 }
 
 // DataWebLoginRequest Web登录请求数据结构（无设备信息）
@@ -266,6 +267,7 @@ type DataWebLoginRequest struct {
 	VerificationCode string `json:"verificationCode" binding:"required"` // 验证码
 	Language         string `json:"language"`                            // 用户语言偏好（可选）
 	InviteCode       string `json:"inviteCode"`                          // 邀请码（可选，仅未激活用户可设置）
+	TOTPCode         string `json:"totpCode"`                            // 两步验证码或恢复码（已启用两步验证时必填）
 }
 
 // DataRefreshTokenRequest 刷新 token 请求数据结构
@@ -669,8 +671,9 @@ type EmailTemplateResponse struct {
 
 // SendTemplatedEmailsHTTPRequest 通用邮件发送HTTP请求（需审批）
 type SendTemplatedEmailsHTTPRequest struct {
-	BatchID string `json:"batchId" binding:"required"`
-	Items   []struct {
+	BatchID  string         `json:"batchId" binding:"required"`
+	Category NotifyCategory `json:"category"` // 空为 marketing：后台群发默认视为营销邮件
+	Items    []struct {
 		Email  string            `json:"email" binding:"required"`
 		UserID uint64            `json:"userId"`
 		Slug   string            `json:"slug" binding:"required"`
@@ -1082,6 +1085,7 @@ type StrategyRulesResponse struct {
 	Version   string           `json:"version"`
 	UpdatedAt string           `json:"updatedAt"` // ISO 8601 format
 	ETag      string           `json:"etag"`
+	Cohort    string           `json:"cohort,omitempty"` // 灰度/A-B 分组：rollout | control | default | 显式分组名
 	Rules     []map[string]any `json:"rules"`
 	Protocols map[string]any   `json:"protocols"`
 	Default   map[string]any   `json:"default"`
@@ -1089,9 +1093,11 @@ type StrategyRulesResponse struct {
 
 // TelemetryBatchRequest telemetry upload request
 type TelemetryBatchRequest struct {
-	DeviceID   string              `json:"deviceId" binding:"required"`
-	AppVersion string              `json:"appVersion" binding:"required"`
-	Events     []TelemetryEventDTO `json:"events" binding:"required,dive"`
+	DeviceID     string              `json:"deviceId" binding:"required"`
+	AppVersion   string              `json:"appVersion" binding:"required"`
+	RulesVersion string              `json:"rulesVersion,omitempty"` // 客户端实际运行的规则版本；事件 context.rules_version 优先
+	Cohort       string              `json:"cohort,omitempty"`       // 随规则下发的分组；事件 context.cohort 优先
+	Events       []TelemetryEventDTO `json:"events" binding:"required,dive"`
 }
 
 // TelemetryEventDTO single telemetry event in batch
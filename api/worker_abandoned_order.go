@@ -227,8 +227,9 @@ func processAbandonedOrders(ctx context.Context, batchID, slug string, windowSta
 	}
 
 	result, err := SendTemplatedEmails(ctx, &SendEmailsRequest{
-		BatchID: batchID,
-		Items:   items,
+		BatchID:  batchID,
+		Category: NotifyCategoryMarketing,
+		Items:    items,
 	})
 	if err != nil {
 		log.Errorf(ctx, "[ABANDONED] SendTemplatedEmails failed: %v", err)
@@ -34,6 +34,7 @@ type CloudSyncPayload struct {
 type CloudChangeIPPayload struct {
 	CloudInstanceID uint64 `json:"cloud_instance_id"`
 	TargetRegion    string `json:"target_region,omitempty"` // For BandwagonHost
+	Reason          string `json:"reason,omitempty"`        // Empty = manual
 }
 
 type CloudCreatePayload struct {
@@ -71,6 +72,30 @@ func accountToProviderConfig(account *CloudInstanceAccount) cloudprovider.Provid
 	return cfg
 }
 
+// newCloudProvider builds the account's provider wrapped in its shared
+// guard (per-account rate limit, throttle retries, circuit breaker). All
+// Center code paths that talk to a provider API must go through here so
+// they draw from the same per-account budget.
+func newCloudProvider(account *CloudInstanceAccount) (cloudprovider.Provider, error) {
+	provider, err := cloudprovider.NewProvider(accountToProviderConfig(account))
+	if err != nil {
+		return nil, err
+	}
+	return cloudGuard(account.Name).Wrap(provider), nil
+}
+
+// cloudGuard returns the shared middleware state for an account.
+func cloudGuard(accountName string) *cloudprovider.Guard {
+	g := ConfigCloudInstance().Guard
+	return cloudprovider.GuardFor(accountName, cloudprovider.GuardOptions{
+		RatePerSecond:    g.RatePerSecond,
+		Burst:            g.Burst,
+		MaxRetries:       g.MaxRetries,
+		BreakerThreshold: g.BreakerThreshold,
+		BreakerCooldown:  g.BreakerCooldown,
+	})
+}
+
 // sshExecBySlaveNodeIP executes an SSH command by looking up the SlaveNode by its IPv4 address.
 // This allows cloudprovider to use the system's SSH keypair instead of per-instance credentials.
 func sshExecBySlaveNodeIP(ctx context.Context, ip string, command string) (string, error) {
@@ -105,12 +130,16 @@ func RegisterCloudWorker() {
 	// Register task handlers
 	asynq.Handle(TaskTypeCloudSyncAll, handleCloudSyncAll)
 	asynq.Handle(TaskTypeCloudChangeIP, withSlackNotify(handleCloudChangeIP))
+	asynq.Handle(TaskTypeCloudChangeIPAdvance, withSlackNotify(handleCloudChangeIPAdvance))
 	asynq.Handle(TaskTypeCloudCreate, withSlackNotify(handleCloudCreate))
 	asynq.Handle(TaskTypeCloudDelete, withSlackNotify(handleCloudDelete))
 
 	// Register cron for status sync
 	asynq.Cron(cfg.Sync.Cron, TaskTypeCloudSyncAll, nil, hibikenAsynq.Unique(25*time.Minute))
 
+	RegisterCloudRotationWorker()
+	RegisterApprovalCallback(approvalActionCloudFleetApply, executeApprovalCloudFleetApply)
+
 	log.Infof(context.Background(), "[CLOUD] Cloud worker registered (cron: %s)", cfg.Sync.Cron)
 }
 
@@ -224,16 +253,29 @@ func syncAccount(ctx context.Context, database *gorm.DB, account CloudInstanceAc
 	log.Debugf(ctx, "[CLOUD] Provider config: provider=%s, region=%s, has_access_key=%v, has_secret=%v",
 		cfg.Provider, cfg.Region, cfg.AccessKeyID != "", cfg.AccessKeySecret != "" || cfg.SecretAccessKey != "")
 
-	provider, err := cloudprovider.NewProvider(cfg)
+	provider, err := newCloudProvider(&account)
 	if err != nil {
 		return fmt.Errorf("failed to create provider: %w", err)
 	}
 
 	log.Infof(ctx, "[CLOUD] Listing instances for account: %s", account.Name)
 	instances, err := provider.ListInstances(ctx)
-	if err != nil {
+	// A partial list (some regions/instances failed) is still worth upserting,
+	// but anything missing from it may simply be unreachable right now.
+	partial := cloudprovider.IsPartialList(err)
+	if err != nil && !partial {
+		if cloudprovider.IsCircuitOpen(err) {
+			// Breaker open: the account is known-degraded, don't hammer it and
+			// don't overwrite every instance's sync_error on each tick.
+			log.Warnf(ctx, "[CLOUD] Skipping account %s: %v", account.Name, err)
+			return nil
+		}
+		markAccountSyncError(ctx, account, err)
 		return fmt.Errorf("failed to list instances: %w", err)
 	}
+	if partial {
+		log.Warnf(ctx, "[CLOUD] Partial instance list for account %s: %v", account.Name, err)
+	}
 
 	log.Infof(ctx, "[CLOUD] Found %d instances for account: %s", len(instances), account.Name)
 
@@ -252,7 +294,14 @@ func syncAccount(ctx context.Context, database *gorm.DB, account CloudInstanceAc
 		}
 	}
 
-	// Orphan detection: mark instances that no longer exist as deleted
+	// Orphan detection: mark instances that no longer exist as deleted.
+	// Skipped on a partial list — a throttled region returns nothing, which
+	// would otherwise look like every instance was deleted. (An open breaker
+	// already returned above; a successful ListInstances closes it.)
+	if partial {
+		log.Warnf(ctx, "[CLOUD] Account %s degraded, skipping orphan detection", account.Name)
+		return nil
+	}
 	if err := markOrphanedInstances(ctx, account, syncedIDs); err != nil {
 		log.Errorf(ctx, "[CLOUD] Failed to mark orphaned instances: %v", err)
 	}
@@ -260,6 +309,21 @@ func syncAccount(ctx context.Context, database *gorm.DB, account CloudInstanceAc
 	return nil
 }
 
+// markAccountSyncError records a failed account-level sync on every active
+// instance of the account so the admin list shows why data is stale.
+func markAccountSyncError(ctx context.Context, account CloudInstanceAccount, syncErr error) {
+	query := db.Get().Model(&CloudInstance{}).Where(&CloudInstance{
+		Provider:    account.Provider,
+		AccountName: account.Name,
+	})
+	if account.Region != "" {
+		query = query.Where("region = ?", account.Region)
+	}
+	if err := query.Update("sync_error", syncErr.Error()).Error; err != nil {
+		log.Errorf(ctx, "[CLOUD] Failed to record sync error for account %s: %v", account.Name, err)
+	}
+}
+
 // markOrphanedInstances marks instances as "deleted" if they no longer exist in the provider
 func markOrphanedInstances(ctx context.Context, account CloudInstanceAccount, syncedIDs map[string]bool) error {
 	// Find all active instances in DB for this account/region
@@ -370,6 +434,19 @@ func upsertCloudInstance(ctx context.Context, account CloudInstanceAccount, stat
 	return nil
 }
 
+// privateOwningStatuses are the subscription statuses that still imply
+// ownership of the instance. The deprovision transition sets status to
+// deprovisioned but never clears cloud_instance_id, so a terminal
+// (deprovisioned/failed) line's link lingers forever — if the instance is
+// later recycled to the shared pool we must NOT keep treating it as private.
+var privateOwningStatuses = []string{
+	PNStatusPending,
+	PNStatusProvisioning,
+	PNStatusActive,
+	PNStatusGrace,
+	PNStatusSuspended,
+}
+
 // isPrivateCloudInstance reports whether a CloudInstance is owned by a dedicated-line
 // subscription. Private nodes self-meter usage and carry a sold quota; provider sync
 // reports only the VPS bundle, so its traffic figures must never overwrite theirs.
@@ -377,22 +454,9 @@ func isPrivateCloudInstance(ciID uint64) bool {
 	if ciID == 0 {
 		return false
 	}
-	// Only count subscriptions whose status still implies ownership of the
-	// instance. The deprovision transition sets status to deprovisioned but
-	// never clears cloud_instance_id, so a terminal (deprovisioned/failed)
-	// line's link lingers forever — if the instance is later recycled to the
-	// shared pool we must NOT keep skipping its traffic fields. Exclude
-	// terminal statuses so a recycled instance gets provider data again.
-	owningStatuses := []string{
-		PNStatusPending,
-		PNStatusProvisioning,
-		PNStatusActive,
-		PNStatusGrace,
-		PNStatusSuspended,
-	}
 	var count int64
 	if err := db.Get().Model(&PrivateNodeSubscription{}).
-		Where("cloud_instance_id = ? AND status IN ?", ciID, owningStatuses).
+		Where("cloud_instance_id = ? AND status IN ?", ciID, privateOwningStatuses).
 		Count(&count).Error; err != nil {
 		// Fail closed: on a DB error we cannot prove the instance is NOT
 		// private, so assume it is and skip provider traffic sync. Overwriting
@@ -405,60 +469,6 @@ func isPrivateCloudInstance(ciID uint64) bool {
 	return count > 0
 }
 
-func handleCloudChangeIP(ctx context.Context, payload []byte) error {
-	var p CloudChangeIPPayload
-	if err := asynq.Unmarshal(payload, &p); err != nil {
-		return fmt.Errorf("unmarshal payload failed: %w", err)
-	}
-
-	log.Infof(ctx, "[CLOUD] Starting IP change: instance_id=%d", p.CloudInstanceID)
-
-	// Get instance from DB
-	var instance CloudInstance
-	if err := db.Get().First(&instance, p.CloudInstanceID).Error; err != nil {
-		return fmt.Errorf("instance not found: %w", err)
-	}
-
-	// Get account config
-	account := ConfigCloudInstanceAccountByName(instance.AccountName)
-	if account == nil {
-		return fmt.Errorf("account not found: %s", instance.AccountName)
-	}
-
-	// Create provider
-	provider, err := cloudprovider.NewProvider(accountToProviderConfig(account))
-	if err != nil {
-		return fmt.Errorf("failed to create provider: %w", err)
-	}
-
-	// Execute IP change
-	result, err := provider.ChangeIP(ctx, instance.InstanceID, cloudprovider.ChangeIPOptions{
-		TargetRegion: p.TargetRegion,
-	})
-	if err != nil {
-		// Update sync error
-		db.Get().Model(&instance).Update("sync_error", err.Error())
-		return err
-	}
-
-	// Update instance with new IP
-	if newIP, ok := result.Data["new_ip"].(string); ok && newIP != "" {
-		db.Get().Model(&instance).Updates(map[string]any{
-			"ip_address":     newIP,
-			"last_synced_at": time.Now().Unix(),
-			"sync_error":     "",
-		})
-	}
-
-	log.Infof(ctx, "[CLOUD] IP change completed: %s", result.Message)
-
-	// Send success notification
-	sendCloudSlackNotification(ctx, "IP Change Completed",
-		fmt.Sprintf("Instance: %s\nNew IP: %v", instance.InstanceID, result.Data["new_ip"]))
-
-	return nil
-}
-
 func handleCloudCreate(ctx context.Context, payload []byte) error {
 	var p CloudCreatePayload
 	if err := asynq.Unmarshal(payload, &p); err != nil {
@@ -472,7 +482,7 @@ func handleCloudCreate(ctx context.Context, payload []byte) error {
 		return fmt.Errorf("account not found: %s", p.AccountName)
 	}
 
-	provider, err := cloudprovider.NewProvider(accountToProviderConfig(account))
+	provider, err := newCloudProvider(account)
 	if err != nil {
 		return fmt.Errorf("failed to create provider: %w", err)
 	}
@@ -522,7 +532,7 @@ func handleCloudDelete(ctx context.Context, payload []byte) error {
 		return fmt.Errorf("account not found: %s", instance.AccountName)
 	}
 
-	provider, err := cloudprovider.NewProvider(accountToProviderConfig(account))
+	provider, err := newCloudProvider(account)
 	if err != nil {
 		return fmt.Errorf("failed to create provider: %w", err)
 	}
@@ -0,0 +1,452 @@
+package center
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	hibikenAsynq "github.com/hibiken/asynq"
+	"github.com/wordgate/qtoolkit/asynq"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"gorm.io/gorm"
+
+	"github.com/kaitu-io/k2app/api/cloudprovider"
+)
+
+// worker_cloud_change_ip.go — 换 IP 编排。过去 handleCloudChangeIP 只换厂商侧 IP,
+// SlaveNode / SlaveTunnel、antiblock 种子和客户端拿到的隧道 URL 全部停在旧 IP 上,
+// 直到 sidecar 某天重启才重新注册。现在拆成由 CloudIPChange 行驱动的状态机:
+//
+//	draining          → 节点 DrainUntil 置位,非管理员列表不再下发;等 DrainWait
+//	(swap)            → 厂商 ChangeIP;同一事务内把节点行/用量/专属订阅改挂到新 IP
+//	awaiting_register → SSH 重启 sidecar,轮询直到它用新 IP 重新注册隧道
+//	completed/failed  → 解除摘流、清缓存、Slack
+//
+// 节点行原地改 IP 而不是等 sidecar 新建一行:节点主键是 slave_node_loads /
+// session_accts.slave_id / enterprise_lines.node_id 的引用键,原地改 IP 历史天然跟随;
+// sidecar 用同一个 SecretToken 在新 IP 上注册时命中这行,走正常的 UPDATE 路径替换隧道。
+// 每一步都以 CloudIPChange.Status 为准,asynq 重试重入是安全的。
+
+const TaskTypeCloudChangeIPAdvance = "cloud:change_ip:advance"
+
+type CloudChangeIPAdvancePayload struct {
+	ChangeID uint64 `json:"change_id"`
+}
+
+// handleCloudChangeIP starts a change-IP workflow: records the change, drains
+// the node and schedules the swap after the drain window.
+func handleCloudChangeIP(ctx context.Context, payload []byte) error {
+	var p CloudChangeIPPayload
+	if err := asynq.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("unmarshal payload failed: %w", err)
+	}
+
+	log.Infof(ctx, "[CLOUD] Starting IP change: instance_id=%d", p.CloudInstanceID)
+
+	var instance CloudInstance
+	if err := db.Get().First(&instance, p.CloudInstanceID).Error; err != nil {
+		return fmt.Errorf("instance not found: %w", err)
+	}
+	if ConfigCloudInstanceAccountByName(instance.AccountName) == nil {
+		return fmt.Errorf("account not found: %s", instance.AccountName)
+	}
+
+	// 同一实例同时只跑一个工作流。已有未结束的 → 视为本任务的重试,重新推进它即可
+	// (上一次可能建完记录后 Enqueue 失败)。
+	var active CloudIPChange
+	err := db.Get().Where("cloud_instance_id = ? AND status IN ?", instance.ID,
+		[]string{CloudIPChangeStatusDraining, CloudIPChangeStatusAwaitingRegister}).
+		Order("id DESC").First(&active).Error
+	if err == nil {
+		log.Warnf(ctx, "[CLOUD] IP change %d already in progress for instance %d, resuming", active.ID, instance.ID)
+		return enqueueCloudIPChangeAdvance(active.ID, 0)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to query active ip change: %w", err)
+	}
+
+	reason := p.Reason
+	if reason == "" {
+		reason = "manual"
+	}
+	cfg := ConfigCloudInstance().ChangeIP
+	now := time.Now().Unix()
+	change := CloudIPChange{
+		CloudInstanceID: instance.ID,
+		AccountName:     instance.AccountName,
+		Provider:        instance.Provider,
+		InstanceID:      instance.InstanceID,
+		TargetRegion:    p.TargetRegion,
+		Reason:          reason,
+		OldIP:           instance.IPAddress,
+		Status:          CloudIPChangeStatusDraining,
+		DrainedAt:       now,
+	}
+
+	var node SlaveNode
+	drainWait := cfg.DrainWait
+	if err := db.Get().Where("ipv4 = ?", instance.IPAddress).First(&node).Error; err == nil {
+		change.NodeID = node.ID
+	} else {
+		drainWait = 0 // 没有注册节点 → 没有客户端可迁,直接换
+	}
+
+	if err := db.Get().Create(&change).Error; err != nil {
+		return fmt.Errorf("failed to record ip change: %w", err)
+	}
+
+	if change.NodeID != 0 {
+		// 截止时间覆盖整个工作流:中途崩溃时节点会自己回来,而不是永久从列表消失。
+		drainUntil := now + int64((cfg.DrainWait+cfg.RegisterTimeout+cfg.PollInterval)/time.Second)
+		if err := db.Get().Model(&SlaveNode{}).Where("id = ?", node.ID).
+			Update("drain_until", drainUntil).Error; err != nil {
+			failCloudIPChange(ctx, &change, fmt.Errorf("failed to drain node: %w", err))
+			return err
+		}
+		invalidateNodeLoadCache(ctx, node.ID)
+		log.Infof(ctx, "[CLOUD] IP change %d: node %d (%s) drained until %d", change.ID, node.ID, node.Ipv4, drainUntil)
+	}
+
+	return enqueueCloudIPChangeAdvance(change.ID, drainWait)
+}
+
+// handleCloudChangeIPAdvance runs the next step of a change-IP workflow.
+func handleCloudChangeIPAdvance(ctx context.Context, payload []byte) error {
+	var p CloudChangeIPAdvancePayload
+	if err := asynq.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("unmarshal payload failed: %w", err)
+	}
+
+	var change CloudIPChange
+	if err := db.Get().First(&change, p.ChangeID).Error; err != nil {
+		return fmt.Errorf("ip change not found: %w", err)
+	}
+
+	switch change.Status {
+	case CloudIPChangeStatusDraining:
+		return stepCloudIPChangeSwap(ctx, &change)
+	case CloudIPChangeStatusAwaitingRegister:
+		return stepCloudIPChangeAwaitRegister(ctx, &change)
+	default:
+		return nil // terminal: duplicate or late delivery
+	}
+}
+
+// stepCloudIPChangeSwap swaps the IP at the provider and moves the node's
+// durable IP keys over to the new address.
+func stepCloudIPChangeSwap(ctx context.Context, change *CloudIPChange) error {
+	// 厂商调用成功后立刻落 NewIP:后面的 DB 切换失败时 asynq 重试不能再换一次 IP。
+	if change.NewIP == "" {
+		account := ConfigCloudInstanceAccountByName(change.AccountName)
+		if account == nil {
+			err := fmt.Errorf("account not found: %s", change.AccountName)
+			failCloudIPChange(ctx, change, err)
+			return err
+		}
+		provider, err := newCloudProvider(account)
+		if err != nil {
+			err = fmt.Errorf("failed to create provider: %w", err)
+			failCloudIPChange(ctx, change, err)
+			return err
+		}
+
+		result, err := provider.ChangeIP(ctx, change.InstanceID, cloudprovider.ChangeIPOptions{
+			TargetRegion: change.TargetRegion,
+		})
+		if err != nil {
+			db.Get().Model(&CloudInstance{}).Where("id = ?", change.CloudInstanceID).Update("sync_error", err.Error())
+			failCloudIPChange(ctx, change, err)
+			return err
+		}
+		newIP, _ := result.Data["new_ip"].(string)
+		if newIP == "" {
+			err := fmt.Errorf("provider did not report the new IP: %s", result.Message)
+			failCloudIPChange(ctx, change, err)
+			return err
+		}
+
+		change.NewIP = newIP
+		change.ChangedAt = time.Now().Unix()
+		if err := db.Get().Model(change).Updates(map[string]any{
+			"new_ip":     change.NewIP,
+			"changed_at": change.ChangedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to record new ip: %w", err)
+		}
+		log.Infof(ctx, "[CLOUD] IP change %d: %s → %s (%s)", change.ID, change.OldIP, change.NewIP, result.Message)
+	}
+
+	var registered bool
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		var err error
+		registered, err = cutoverNodeIP(tx, change)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ip change %d cutover failed: %w", change.ID, err)
+	}
+	if change.NodeID != 0 {
+		invalidateNodeLoadCache(ctx, change.NodeID)
+	}
+
+	if change.NodeID == 0 || registered {
+		return completeCloudIPChange(ctx, change)
+	}
+
+	restartSidecarForIPChange(ctx, change)
+
+	change.Status = CloudIPChangeStatusAwaitingRegister
+	if err := db.Get().Model(change).Updates(map[string]any{
+		"status":  change.Status,
+		"node_id": change.NodeID,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update ip change status: %w", err)
+	}
+	return enqueueCloudIPChangeAdvance(change.ID, ConfigCloudInstance().ChangeIP.PollInterval)
+}
+
+// stepCloudIPChangeAwaitRegister polls for the sidecar re-registering its
+// tunnels under the new IP.
+func stepCloudIPChangeAwaitRegister(ctx context.Context, change *CloudIPChange) error {
+	cfg := ConfigCloudInstance().ChangeIP
+
+	var node SlaveNode
+	if err := db.Get().Where("ipv4 = ?", change.NewIP).First(&node).Error; err == nil {
+		// 注册会整体替换隧道,所以"换 IP 之后新建的隧道"就是 sidecar 已用新 IP 注册的证据。
+		var fresh int64
+		db.Get().Model(&SlaveTunnel{}).
+			Where("node_id = ? AND created_at >= ?", node.ID, time.Unix(change.ChangedAt, 0)).
+			Count(&fresh)
+		if fresh > 0 {
+			change.NodeID = node.ID
+			return completeCloudIPChange(ctx, change)
+		}
+	}
+
+	deadline := change.ChangedAt + int64(cfg.RegisterTimeout/time.Second)
+	if time.Now().Unix() > deadline {
+		// 摘流不在这里解除:节点的隧道 URL 还指向已经失效的旧 IP,宁可让 DrainUntil
+		// 自然过期(届时节点若仍不上报,离线判定会继续隐藏它)。
+		err := fmt.Errorf("sidecar did not re-register with new IP %s within %s", change.NewIP, cfg.RegisterTimeout)
+		failCloudIPChange(ctx, change, err)
+		return err
+	}
+
+	if change.RestartedAt == 0 {
+		restartSidecarForIPChange(ctx, change)
+	}
+	return enqueueCloudIPChangeAdvance(change.ID, cfg.PollInterval)
+}
+
+// cutoverNodeIP moves everything keyed by the old IP to the new one. Returns
+// registered=true when the sidecar already beat us to it (registered a fresh
+// row under the new IP while the provider call was running), in which case
+// the old row's history is folded into that row instead.
+func cutoverNodeIP(tx *gorm.DB, change *CloudIPChange) (registered bool, err error) {
+	if err := tx.Model(&CloudInstance{}).Where("id = ?", change.CloudInstanceID).
+		Updates(map[string]any{
+			"ip_address":     change.NewIP,
+			"last_synced_at": time.Now().Unix(),
+			"sync_error":     "",
+		}).Error; err != nil {
+		return false, err
+	}
+
+	// 专属订阅的 BoundIpv4 是重认领键(reconcilePrivateIdentity),必须跟着走,
+	// 否则 sidecar 在新 IP 注册时认不回自己的主人。
+	if err := tx.Model(&PrivateNodeSubscription{}).Where("bound_ipv4 = ?", change.OldIP).
+		Update("bound_ipv4", change.NewIP).Error; err != nil {
+		return false, err
+	}
+
+	if change.NodeID == 0 {
+		return false, nil
+	}
+
+	var node SlaveNode
+	if err := tx.Where("id = ?", change.NodeID).First(&node).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// sidecar 优雅退出时 unregister 会硬删节点行;新 IP 注册后按 BoundIpv4 重认领。
+			change.NodeID = 0
+			return false, nil
+		}
+		return false, err
+	}
+
+	targetID := node.ID
+	var occupant SlaveNode
+	err = tx.Unscoped().Where("ipv4 = ? AND id <> ?", change.NewIP, node.ID).First(&occupant).Error
+	switch {
+	case err == nil && !occupant.DeletedAt.Valid && occupant.CreatedAt.Unix() >= change.CreatedAt:
+		// sidecar 已在新 IP 注册出一行:把旧行的归属和历史并过去,旧行删掉。
+		if err := mergeSlaveNodeInto(tx, &node, &occupant); err != nil {
+			return false, err
+		}
+		targetID = occupant.ID
+		registered = true
+	case err == nil:
+		// 新 IP 上残留的是别的机器以前的行(厂商回收再分配的 IP),直接清掉。
+		if err := hardDeleteSlaveNode(tx, occupant.ID); err != nil {
+			return false, err
+		}
+		fallthrough
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := tx.Model(&SlaveNode{}).Where("id = ?", node.ID).
+			Update("ipv4", change.NewIP).Error; err != nil {
+			return false, err
+		}
+	default:
+		return false, err
+	}
+
+	if err := tx.Model(&PrivateNodeSubscription{}).Where("slave_node_id = ?", node.ID).
+		Update("slave_node_id", targetID).Error; err != nil {
+		return false, err
+	}
+	if err := moveNodeUsageIP(tx, change, targetID); err != nil {
+		return false, err
+	}
+
+	change.NodeID = targetID
+	return registered, nil
+}
+
+// mergeSlaveNodeInto re-points everything referencing from.ID at to.ID, copies
+// the operator/ownership columns the registration path never sets, and
+// deletes from.
+func mergeSlaveNodeInto(tx *gorm.DB, from, to *SlaveNode) error {
+	if err := tx.Model(&SlaveNodeLoad{}).Where("node_id = ?", from.ID).Update("node_id", to.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&SessionAcct{}).Where("slave_id = ?", from.ID).Update("slave_id", to.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&EnterpriseLine{}).Where("node_id = ?", from.ID).Update("node_id", to.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&SlaveNode{}).Where("id = ?", to.ID).Updates(map[string]any{
+		"class":                 from.Class,
+		"private_owner_user_id": from.PrivateOwnerUserID,
+		"private_sub_id":        from.PrivateSubID,
+		"visible_kaitu":         from.VisibleKaitu,
+		"visible_overleap":      from.VisibleOverleap,
+	}).Error; err != nil {
+		return err
+	}
+	return hardDeleteSlaveNode(tx, from.ID)
+}
+
+// hardDeleteSlaveNode removes a node row with its tunnels and load history,
+// mirroring api_slave_node_unregister.
+func hardDeleteSlaveNode(tx *gorm.DB, nodeID uint64) error {
+	if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&SlaveTunnel{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&SlaveNodeLoad{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id = ?", nodeID).Delete(&SlaveNode{}).Error
+}
+
+// moveNodeUsageIP re-keys the node's NodeUsage mirror to the new IP. A row
+// already under the new IP wins only if the node reported into it during this
+// workflow; an older one belongs to a previous holder of the IP.
+func moveNodeUsageIP(tx *gorm.DB, change *CloudIPChange, nodeID uint64) error {
+	var existing NodeUsage
+	err := tx.Where("ipv4 = ?", change.NewIP).First(&existing).Error
+	switch {
+	case err == nil && existing.UpdatedAt >= change.CreatedAt:
+		return tx.Where("ipv4 = ?", change.OldIP).Delete(&NodeUsage{}).Error
+	case err == nil:
+		if err := tx.Delete(&existing).Error; err != nil {
+			return err
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+	return tx.Model(&NodeUsage{}).Where("ipv4 = ?", change.OldIP).
+		Updates(map[string]any{"ipv4": change.NewIP, "node_id": nodeID}).Error
+}
+
+// restartSidecarForIPChange kicks the node over SSH on its new IP so the
+// sidecar re-detects the address and re-registers. Best-effort: providers that
+// reboot the instance during the swap get a re-registration anyway, and the
+// await step retries until the first success.
+func restartSidecarForIPChange(ctx context.Context, change *CloudIPChange) {
+	node := SlaveNode{Ipv4: change.NewIP}
+	result, err := node.SSHExec(ctx, ConfigCloudInstance().ChangeIP.RestartCommand)
+	if err == nil && result.ExitCode != 0 {
+		err = fmt.Errorf("exit code %d: %s", result.ExitCode, result.Stderr)
+	}
+	if err != nil {
+		log.Warnf(ctx, "[CLOUD] IP change %d: sidecar restart on %s failed: %v", change.ID, change.NewIP, err)
+		return
+	}
+	change.RestartedAt = time.Now().Unix()
+	db.Get().Model(change).Update("restarted_at", change.RestartedAt)
+}
+
+func completeCloudIPChange(ctx context.Context, change *CloudIPChange) error {
+	now := time.Now().Unix()
+	if change.NodeID != 0 {
+		if err := db.Get().Model(&SlaveNode{}).Where("id = ?", change.NodeID).
+			Update("drain_until", 0).Error; err != nil {
+			return fmt.Errorf("failed to undrain node: %w", err)
+		}
+		invalidateNodeLoadCache(ctx, change.NodeID)
+		change.RegisteredAt = now
+	}
+
+	change.Status = CloudIPChangeStatusCompleted
+	change.CompletedAt = now
+	if err := db.Get().Model(change).Updates(map[string]any{
+		"status":        change.Status,
+		"node_id":       change.NodeID,
+		"registered_at": change.RegisteredAt,
+		"completed_at":  change.CompletedAt,
+		"error":         "",
+	}).Error; err != nil {
+		return fmt.Errorf("failed to complete ip change: %w", err)
+	}
+
+	log.Infof(ctx, "[CLOUD] IP change %d completed: %s → %s (node %d)", change.ID, change.OldIP, change.NewIP, change.NodeID)
+	sendCloudSlackNotification(ctx, "IP Change Completed",
+		fmt.Sprintf("Instance: %s\nOld IP: %s\nNew IP: %s", change.InstanceID, change.OldIP, change.NewIP))
+	return nil
+}
+
+// failCloudIPChange marks the workflow failed. If the IP was never swapped the
+// node still works on its old address, so the drain is lifted right away.
+func failCloudIPChange(ctx context.Context, change *CloudIPChange, cause error) {
+	change.Status = CloudIPChangeStatusFailed
+	change.Error = cause.Error()
+	change.CompletedAt = time.Now().Unix()
+	if err := db.Get().Model(change).Updates(map[string]any{
+		"status":       change.Status,
+		"error":        change.Error,
+		"completed_at": change.CompletedAt,
+	}).Error; err != nil {
+		log.Errorf(ctx, "[CLOUD] Failed to mark ip change %d failed: %v", change.ID, err)
+	}
+
+	if change.NodeID != 0 && change.NewIP == "" {
+		if err := db.Get().Model(&SlaveNode{}).Where("id = ?", change.NodeID).
+			Update("drain_until", 0).Error; err != nil {
+			log.Errorf(ctx, "[CLOUD] Failed to undrain node %d: %v", change.NodeID, err)
+		}
+		invalidateNodeLoadCache(ctx, change.NodeID)
+	}
+	log.Errorf(ctx, "[CLOUD] IP change %d failed: %v", change.ID, cause)
+}
+
+func enqueueCloudIPChangeAdvance(changeID uint64, delay time.Duration) error {
+	_, err := asynq.Enqueue(TaskTypeCloudChangeIPAdvance, CloudChangeIPAdvancePayload{
+		ChangeID: changeID,
+	}, hibikenAsynq.ProcessIn(delay))
+	if err != nil {
+		return fmt.Errorf("failed to schedule ip change %d: %w", changeID, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package center
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+	"gorm.io/gorm"
+)
+
+// seedIPChangeNode seeds a node with one tunnel, a load row and a usage row
+// under ip, and registers cleanup for anything left under its id.
+func seedIPChangeNode(t *testing.T, ip, uniq string) SlaveNode {
+	t.Helper()
+	node := SlaveNode{Ipv4: ip, Name: "ipchange-" + uniq, Country: "JP", Class: NodeClassShared}
+	require.NoError(t, db.Get().Create(&node).Error)
+	require.NoError(t, db.Get().Create(&SlaveTunnel{
+		NodeID: node.ID, Protocol: TunnelProtocolK2V5, Name: "ipc-" + uniq,
+		Domain: "ipc" + uniq + ".example.com", Port: 10001,
+	}).Error)
+	require.NoError(t, db.Get().Create(&SlaveNodeLoad{NodeID: node.ID, Load: 10}).Error)
+	t.Cleanup(func() {
+		db.Get().Unscoped().Where("node_id = ?", node.ID).Delete(&SlaveTunnel{})
+		db.Get().Unscoped().Where("node_id = ?", node.ID).Delete(&SlaveNodeLoad{})
+		db.Get().Unscoped().Delete(&SlaveNode{}, node.ID)
+	})
+	return node
+}
+
+// TestCutoverNodeIP_RenamesInPlace: the node row keeps its id (so loads /
+// session_accts / enterprise_lines stay attached) and every IP-keyed row
+// follows it to the new address.
+func TestCutoverNodeIP_RenamesInPlace(t *testing.T) {
+	setupTestDB(t)
+	uniq := fmt.Sprintf("%d", time.Now().UnixNano())
+	seg := uniq[len(uniq)-3:]
+	oldIP, newIP := fmt.Sprintf("10.91.%s.1", seg), fmt.Sprintf("10.91.%s.2", seg)
+
+	node := seedIPChangeNode(t, oldIP, uniq)
+	require.NoError(t, db.Get().Create(&NodeUsage{NodeID: node.ID, Ipv4: oldIP, UsedBytes: 42}).Error)
+	t.Cleanup(func() { db.Get().Where("ipv4 IN ?", []string{oldIP, newIP}).Delete(&NodeUsage{}) })
+
+	change := &CloudIPChange{NodeID: node.ID, OldIP: oldIP, NewIP: newIP, CreatedAt: time.Now().Unix()}
+	var registered bool
+	require.NoError(t, db.Get().Transaction(func(tx *gorm.DB) error {
+		var err error
+		registered, err = cutoverNodeIP(tx, change)
+		return err
+	}))
+
+	assert.False(t, registered)
+	assert.Equal(t, node.ID, change.NodeID)
+	var got SlaveNode
+	require.NoError(t, db.Get().First(&got, node.ID).Error)
+	assert.Equal(t, newIP, got.Ipv4)
+	var usage NodeUsage
+	require.NoError(t, db.Get().Where("ipv4 = ?", newIP).First(&usage).Error)
+	assert.Equal(t, int64(42), usage.UsedBytes)
+}
+
+// TestCutoverNodeIP_MergesIntoRaceRegistration: when the sidecar registered a
+// fresh row under the new IP mid-workflow, history and operator flags are
+// folded into that row and the old one is removed.
+func TestCutoverNodeIP_MergesIntoRaceRegistration(t *testing.T) {
+	setupTestDB(t)
+	uniq := fmt.Sprintf("%d", time.Now().UnixNano())
+	seg := uniq[len(uniq)-3:]
+	oldIP, newIP := fmt.Sprintf("10.92.%s.1", seg), fmt.Sprintf("10.92.%s.2", seg)
+
+	old := seedIPChangeNode(t, oldIP, uniq+"a")
+	require.NoError(t, db.Get().Model(&SlaveNode{}).Where("id = ?", old.ID).
+		Update("visible_overleap", false).Error)
+	change := &CloudIPChange{NodeID: old.ID, OldIP: oldIP, NewIP: newIP, CreatedAt: time.Now().Unix()}
+	fresh := seedIPChangeNode(t, newIP, uniq+"b")
+
+	var registered bool
+	require.NoError(t, db.Get().Transaction(func(tx *gorm.DB) error {
+		var err error
+		registered, err = cutoverNodeIP(tx, change)
+		return err
+	}))
+
+	assert.True(t, registered)
+	assert.Equal(t, fresh.ID, change.NodeID)
+	var loads int64
+	db.Get().Model(&SlaveNodeLoad{}).Where("node_id = ?", fresh.ID).Count(&loads)
+	assert.Equal(t, int64(2), loads, "old node's load history moved to the new row")
+	var got SlaveNode
+	require.NoError(t, db.Get().First(&got, fresh.ID).Error)
+	require.NotNil(t, got.VisibleOverleap)
+	assert.False(t, *got.VisibleOverleap, "operator kill switch carried over")
+	assert.ErrorIs(t, db.Get().Unscoped().First(&SlaveNode{}, old.ID).Error, gorm.ErrRecordNotFound)
+}
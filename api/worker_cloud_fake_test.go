@@ -0,0 +1,83 @@
+package center
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kaitu-io/k2app/api/cloudprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+// fakeAccount registers a fresh in-memory provider under a unique account and
+// cleans up the rows sync writes for it.
+func fakeAccount(t *testing.T, opts cloudprovider.FakeOptions) (CloudInstanceAccount, *cloudprovider.FakeProvider) {
+	t.Helper()
+	key := "fake-" + time.Now().Format("20060102150405.000000")
+	fake := cloudprovider.NewFakeProvider(opts)
+	cloudprovider.SetFakeProvider(key, fake)
+	account := CloudInstanceAccount{Name: key, Provider: cloudprovider.ProviderFake, AccessKeyID: key}
+	t.Cleanup(func() {
+		db.Get().Unscoped().Where("provider = ? AND account_name = ?", cloudprovider.ProviderFake, key).Delete(&CloudInstance{})
+	})
+	return account, fake
+}
+
+// TestSyncAccount_FakeProviderEndToEnd 用 fake provider 驱动完整同步：新实例入库、
+// 流量随时间增长、ChangeIP 落地新 IP、云端删除后标记孤儿。
+func TestSyncAccount_FakeProviderEndToEnd(t *testing.T) {
+	testInitConfig()
+	skipIfNoConfig(t)
+
+	now := time.Now().UTC()
+	clock := func() time.Time { return now }
+	account, fake := fakeAccount(t, cloudprovider.FakeOptions{Now: clock, OpDelay: time.Minute, TrafficPerHour: 1 << 30})
+	ctx := context.Background()
+
+	id := fake.Seed(cloudprovider.InstanceStatus{Region: "eu-frankfurt"})
+	require.NoError(t, syncAccount(ctx, db.Get(), account))
+
+	var ci CloudInstance
+	require.NoError(t, db.Get().Where("provider = ? AND instance_id = ?", cloudprovider.ProviderFake, id).First(&ci).Error)
+	assert.Equal(t, account.Name, ci.AccountName)
+	assert.Equal(t, "eu-frankfurt", ci.Region)
+	assert.Zero(t, ci.TrafficUsedBytes)
+
+	now = now.Add(3 * time.Hour)
+	res, err := fake.ChangeIP(ctx, id, cloudprovider.ChangeIPOptions{})
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	require.NoError(t, syncAccount(ctx, db.Get(), account))
+	require.NoError(t, db.Get().First(&ci, ci.ID).Error)
+	assert.Equal(t, res.Data["new_ip"], ci.IPAddress)
+	assert.Positive(t, ci.TrafficUsedBytes)
+
+	_, err = fake.DeleteInstance(ctx, id)
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	require.NoError(t, syncAccount(ctx, db.Get(), account))
+	err = db.Get().First(&CloudInstance{}, ci.ID).Error
+	assert.Error(t, err, "instance gone from provider must be soft-deleted")
+}
+
+// TestSyncAccount_FakeProviderListFailure 验证账号级失败写入 sync_error 且不误删实例。
+func TestSyncAccount_FakeProviderListFailure(t *testing.T) {
+	testInitConfig()
+	skipIfNoConfig(t)
+
+	account, fake := fakeAccount(t, cloudprovider.FakeOptions{})
+	ctx := context.Background()
+
+	id := fake.Seed(cloudprovider.InstanceStatus{Region: "ap-tokyo"})
+	require.NoError(t, syncAccount(ctx, db.Get(), account))
+
+	boom := assert.AnError
+	fake.FailNext("ListInstances", boom)
+	require.Error(t, syncAccount(ctx, db.Get(), account))
+
+	var ci CloudInstance
+	require.NoError(t, db.Get().Where("provider = ? AND instance_id = ?", cloudprovider.ProviderFake, id).First(&ci).Error)
+	assert.Contains(t, ci.SyncError, boom.Error())
+}
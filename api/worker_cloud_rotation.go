@@ -0,0 +1,374 @@
+package center
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wordgate/qtoolkit/asynq"
+	db "github.com/wordgate/qtoolkit/db"
+	"github.com/wordgate/qtoolkit/log"
+	"gorm.io/gorm"
+)
+
+// worker_cloud_rotation.go — 被封 IP 检测 + 自动换 IP 策略。
+//
+// 每个挂在云实例上的共享节点综合三路信号算一个"被墙置信度":
+//  1. 探测:IPRouteInfo 出站诊断(RunNodeDiagnosis,阿里云国内探针 traceroute)的失败率
+//  2. 评分:ConnectionRating 里墙内用户对该节点隧道的差评率
+//  3. 遥测:StatConnection 里连上不到一分钟就因 error/network 断开的比例
+//
+// 置信度过阈值且策略(provider 白名单、冷却期、月度上限)允许时,只提交一条
+// cloud_rotate_ip 审批 —— 换 IP 会打断节点上所有在线用户,自动化只负责发现和
+// 提议,执行必须有人点头。审批通过后走正常的换 IP 工作流(worker_cloud_change_ip.go),
+// 检测摘要记进 CloudIPChange.Reason,被封 IP 的历史因此可追溯。
+
+const TaskTypeCloudRotationCheck = "cloud:rotation:check"
+
+const approvalActionCloudRotateIP = "cloud_rotate_ip"
+
+// 各路信号权重。探测是唯一不依赖用户主动上报的客观信号,权重最高。
+const (
+	blockWeightProbe     = 0.4
+	blockWeightRating    = 0.3
+	blockWeightTelemetry = 0.3
+)
+
+// blockFastFailSec: 连上后这么短时间内因 error/network 断开,视为"被干扰"。
+const blockFastFailSec = 60
+
+// BlockSignals is one node's blocked-IP evaluation. Nil signal = no data.
+type BlockSignals struct {
+	NodeID          uint64   `json:"nodeId"`
+	NodeName        string   `json:"nodeName"`
+	IP              string   `json:"ip"`
+	CloudInstanceID uint64   `json:"cloudInstanceId"`
+	Provider        string   `json:"provider"`
+	Probe           *float64 `json:"probe,omitempty"`     // 探针失败率
+	Rating          *float64 `json:"rating,omitempty"`    // 墙内差评率
+	Telemetry       *float64 `json:"telemetry,omitempty"` // 快速失败断开率
+	Ratings         int64    `json:"ratings"`
+	Connections     int64    `json:"connections"`
+	Confidence      float64  `json:"confidence"`
+	Available       int      `json:"available"`
+	ProbeStale      bool     `json:"probeStale"` // 窗口内没有探测结果(且诊断已开启)
+	Decision        string   `json:"decision"`   // 见 decideRotation
+}
+
+// blockConfidence combines the available signals as a weighted mean over the
+// signals that have data. Returns the number of signals that contributed.
+func blockConfidence(probe, rating, telemetry *float64) (float64, int) {
+	var sum, weight float64
+	n := 0
+	for _, s := range []struct {
+		v *float64
+		w float64
+	}{{probe, blockWeightProbe}, {rating, blockWeightRating}, {telemetry, blockWeightTelemetry}} {
+		if s.v == nil {
+			continue
+		}
+		sum += *s.v * s.w
+		weight += s.w
+		n++
+	}
+	if weight == 0 {
+		return 0, 0
+	}
+	return sum / weight, n
+}
+
+// rotationPolicyCheck enforces the per-instance policy. lastChangeAt is the
+// most recent non-failed CloudIPChange (0 = never), monthCount the number of
+// non-failed changes this UTC month.
+func rotationPolicyCheck(policy CloudInstanceRotationConfig, provider string, lastChangeAt int64, monthCount int64, now time.Time) error {
+	if !slices.Contains(policy.Providers, provider) {
+		return fmt.Errorf("provider %s not allowed for auto-rotation", provider)
+	}
+	if lastChangeAt > 0 && now.Sub(time.Unix(lastChangeAt, 0)) < policy.Cooldown {
+		return fmt.Errorf("in cooldown until %s", time.Unix(lastChangeAt, 0).Add(policy.Cooldown).UTC().Format(time.RFC3339))
+	}
+	if monthCount >= int64(policy.MaxPerMonth) {
+		return fmt.Errorf("monthly limit reached (%d/%d)", monthCount, policy.MaxPerMonth)
+	}
+	return nil
+}
+
+// RegisterCloudRotationWorker registers the blocked-IP detector cron. Called
+// from RegisterCloudWorker: rotation needs the change-IP handlers to exist.
+func RegisterCloudRotationWorker() {
+	cfg := ConfigCloudInstance().Rotation
+	RegisterApprovalCallback(approvalActionCloudRotateIP, executeApprovalCloudRotateIP)
+	if !cfg.Enabled {
+		log.Infof(context.Background(), "[CLOUD] Blocked-IP rotation is disabled")
+		return
+	}
+	asynq.Handle(TaskTypeCloudRotationCheck, handleCloudRotationCheck)
+	asynq.Cron(cfg.Cron, TaskTypeCloudRotationCheck, nil)
+	log.Infof(context.Background(), "[CLOUD] Blocked-IP rotation registered (cron: %s, providers: %v)", cfg.Cron, cfg.Providers)
+}
+
+func handleCloudRotationCheck(ctx context.Context, _ []byte) error {
+	results, err := detectBlockedIPs(ctx)
+	if err != nil {
+		return err
+	}
+
+	submitted := 0
+	for i := range results {
+		r := &results[i]
+		switch r.Decision {
+		case "probe_requested":
+			if _, err := EnqueueDiagnosisOutbound(ctx, r.IP); err != nil {
+				log.Warnf(ctx, "[CLOUD] rotation: failed to enqueue probe for %s: %v", r.IP, err)
+			}
+		case "rotate":
+			if err := submitRotationApproval(ctx, r); err != nil {
+				log.Errorf(ctx, "[CLOUD] rotation: failed to submit approval for %s: %v", r.IP, err)
+				continue
+			}
+			submitted++
+		}
+	}
+	log.Infof(ctx, "[CLOUD] rotation check: evaluated=%d submitted=%d", len(results), submitted)
+	return nil
+}
+
+// detectBlockedIPs evaluates every shared node backed by a cloud instance.
+// Read-only: callers decide what to do with each Decision.
+func detectBlockedIPs(ctx context.Context) ([]BlockSignals, error) {
+	policy := ConfigCloudInstance().Rotation
+	now := time.Now()
+	since := now.Add(-policy.Window)
+
+	var nodes []SlaveNode
+	if err := db.Get().Where("class = ?", NodeClassShared).Find(&nodes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	ips := make([]string, len(nodes))
+	for i := range nodes {
+		ips[i] = nodes[i].Ipv4
+	}
+	var instances []CloudInstance
+	if err := db.Get().Where("ip_address IN ?", ips).Find(&instances).Error; err != nil {
+		return nil, fmt.Errorf("failed to list cloud instances: %w", err)
+	}
+	instByIP := make(map[string]*CloudInstance, len(instances))
+	for i := range instances {
+		instByIP[instances[i].IPAddress] = &instances[i]
+	}
+
+	// 诊断关闭时补探测无从谈起,探测信号只能缺席,不阻塞决策。
+	probeEnabled := configDiagnosis().Enabled
+
+	countries := make([]string, len(policy.Countries))
+	for i, c := range policy.Countries {
+		countries[i] = strings.ToUpper(c)
+	}
+
+	results := make([]BlockSignals, 0, len(nodes))
+	for i := range nodes {
+		node := &nodes[i]
+		inst, ok := instByIP[node.Ipv4]
+		if !ok {
+			continue // 自建节点:没有 ChangeIP 可用
+		}
+		r := BlockSignals{
+			NodeID:          node.ID,
+			NodeName:        node.Name,
+			IP:              node.Ipv4,
+			CloudInstanceID: inst.ID,
+			Provider:        inst.Provider,
+		}
+
+		var probe IPRouteInfo
+		if err := db.Get().Where("ip = ? AND direction = ?", node.Ipv4, DiagnosisDirectionOutbound).
+			First(&probe).Error; err == nil && probe.ProbeCount > 0 && !probe.DiagnosedAt.Before(since) {
+			v := 1 - float64(probe.SuccessCount)/float64(probe.ProbeCount)
+			r.Probe = &v
+		} else {
+			r.ProbeStale = probeEnabled
+		}
+
+		var domains []string
+		db.Get().Model(&SlaveTunnel{}).Where("node_id = ?", node.ID).Pluck("domain", &domains)
+		domains = append(domains, node.Ipv4)
+		var rating struct {
+			Total int64
+			Bad   int64
+		}
+		db.Get().Model(&ConnectionRating{}).
+			Select("COUNT(*) as total, COALESCE(SUM(CASE WHEN rating = 'bad' THEN 1 ELSE 0 END), 0) as bad").
+			Where("created_at >= ? AND server_domain IN ? AND UPPER(user_country) IN ?", since, domains, countries).
+			Scan(&rating)
+		r.Ratings = rating.Total
+		if rating.Total >= int64(policy.MinRatings) {
+			v := float64(rating.Bad) / float64(rating.Total)
+			r.Rating = &v
+		}
+
+		var conn struct {
+			Connects  int64
+			FastFails int64
+		}
+		db.Get().Model(&StatConnection{}).
+			Select("COALESCE(SUM(CASE WHEN event = 'connect' THEN 1 ELSE 0 END), 0) as connects, "+
+				"COALESCE(SUM(CASE WHEN event = 'disconnect' AND disconnect_reason IN ('error','network') AND duration_sec < ? THEN 1 ELSE 0 END), 0) as fast_fails",
+				blockFastFailSec).
+			Where("reported_at >= ? AND node_ipv4 = ?", since, node.Ipv4).
+			Scan(&conn)
+		r.Connections = conn.Connects
+		if conn.Connects >= int64(policy.MinConnections) {
+			v := float64(conn.FastFails) / float64(conn.Connects)
+			if v > 1 {
+				v = 1
+			}
+			r.Telemetry = &v
+		}
+
+		r.Confidence, r.Available = blockConfidence(r.Probe, r.Rating, r.Telemetry)
+		r.Decision = decideRotation(ctx, policy, &r, now)
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// decideRotation maps an evaluation to one of:
+//
+//	healthy          置信度未达阈值
+//	insufficient     有数据的信号不足 MinSignals 路
+//	probe_requested  用户侧信号已过阈值但窗口内没有探测 → 先补一次探测,下一轮再判
+//	pending          已有同实例的待审批 / 进行中的换 IP
+//	blocked_policy:… 策略拒绝(provider / 冷却 / 月度上限)
+//	rotate           提交审批
+func decideRotation(ctx context.Context, policy CloudInstanceRotationConfig, r *BlockSignals, now time.Time) string {
+	if r.Confidence < policy.ConfidenceThreshold {
+		return "healthy"
+	}
+	if r.Probe == nil && r.ProbeStale {
+		return "probe_requested"
+	}
+	if r.Available < policy.MinSignals {
+		return "insufficient"
+	}
+	if rotationPending(r.CloudInstanceID) {
+		return "pending"
+	}
+	if err := checkRotationPolicy(policy, r.CloudInstanceID, r.Provider, now); err != nil {
+		return "blocked_policy: " + err.Error()
+	}
+	return "rotate"
+}
+
+// checkRotationPolicy loads the instance's change history and applies
+// rotationPolicyCheck. Failed changes do not count: the IP did not move.
+func checkRotationPolicy(policy CloudInstanceRotationConfig, cloudInstanceID uint64, provider string, now time.Time) error {
+	var last CloudIPChange
+	var lastAt int64
+	err := db.Get().Where("cloud_instance_id = ? AND status <> ?", cloudInstanceID, CloudIPChangeStatusFailed).
+		Order("id DESC").First(&last).Error
+	if err == nil {
+		lastAt = last.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to load ip change history: %w", err)
+	}
+
+	utc := now.UTC()
+	monthStart := time.Date(utc.Year(), utc.Month(), 1, 0, 0, 0, 0, time.UTC).Unix()
+	var monthCount int64
+	if err := db.Get().Model(&CloudIPChange{}).
+		Where("cloud_instance_id = ? AND status <> ? AND created_at >= ?", cloudInstanceID, CloudIPChangeStatusFailed, monthStart).
+		Count(&monthCount).Error; err != nil {
+		return fmt.Errorf("failed to count ip changes: %w", err)
+	}
+	return rotationPolicyCheck(policy, provider, lastAt, monthCount, now)
+}
+
+// CloudRotateIPParams is the approval payload for cloud_rotate_ip.
+type CloudRotateIPParams struct {
+	CloudInstanceID uint64  `json:"cloudInstanceId"`
+	IP              string  `json:"ip"`
+	Confidence      float64 `json:"confidence"`
+	Reason          string  `json:"reason"`
+}
+
+// rotationPending reports whether the instance already has an open rotation
+// approval or an unfinished change-IP workflow. The approval lookup is
+// narrowed by idx_approval_action_status first, so the JSON predicate only
+// runs over the few open cloud_rotate_ip rows.
+func rotationPending(cloudInstanceID uint64) bool {
+	var n int64
+	db.Get().Model(&AdminApproval{}).
+		Where("action = ? AND status IN ? AND JSON_EXTRACT(params, '$.cloudInstanceId') = ?", approvalActionCloudRotateIP,
+			[]string{"pending", "approved", "executing"}, cloudInstanceID).
+		Count(&n)
+	if n > 0 {
+		return true
+	}
+	db.Get().Model(&CloudIPChange{}).
+		Where("cloud_instance_id = ? AND status IN ?", cloudInstanceID,
+			[]string{CloudIPChangeStatusDraining, CloudIPChangeStatusAwaitingRegister}).
+		Count(&n)
+	return n > 0
+}
+
+func formatBlockSignal(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *v)
+}
+
+func submitRotationApproval(ctx context.Context, r *BlockSignals) error {
+	reason := fmt.Sprintf("blocked: confidence=%.2f probe=%s rating=%s(%d) telemetry=%s(%d)",
+		r.Confidence, formatBlockSignal(r.Probe), formatBlockSignal(r.Rating), r.Ratings,
+		formatBlockSignal(r.Telemetry), r.Connections)
+	params := CloudRotateIPParams{
+		CloudInstanceID: r.CloudInstanceID,
+		IP:              r.IP,
+		Confidence:      r.Confidence,
+		Reason:          reason,
+	}
+	summary := fmt.Sprintf("节点 %s (%s, %s) 疑似被封，申请更换 IP\n%s", r.NodeName, r.IP, r.Provider, reason)
+	id, err := SubmitSystemApproval(ctx, approvalActionCloudRotateIP, params, summary)
+	if err != nil {
+		return err
+	}
+	log.Infof(ctx, "[CLOUD] rotation: approval %d submitted for %s (confidence %.2f)", id, r.IP, r.Confidence)
+	return nil
+}
+
+// executeApprovalCloudRotateIP runs after a rotation approval is granted.
+// Policy is re-checked: approvals can sit in the queue past a manual change.
+func executeApprovalCloudRotateIP(ctx context.Context, params json.RawMessage) error {
+	var p CloudRotateIPParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return fmt.Errorf("unmarshal params: %w", err)
+	}
+
+	var instance CloudInstance
+	if err := db.Get().First(&instance, p.CloudInstanceID).Error; err != nil {
+		return fmt.Errorf("instance not found: %w", err)
+	}
+	if instance.IPAddress != p.IP {
+		return fmt.Errorf("instance IP already changed (%s → %s)", p.IP, instance.IPAddress)
+	}
+	if err := checkRotationPolicy(ConfigCloudInstance().Rotation, instance.ID, instance.Provider, time.Now()); err != nil {
+		return err
+	}
+
+	if _, err := enqueueCloudTask(TaskTypeCloudChangeIP, CloudChangeIPPayload{
+		CloudInstanceID: instance.ID,
+		Reason:          p.Reason,
+	}); err != nil {
+		return fmt.Errorf("failed to enqueue change ip: %w", err)
+	}
+	return nil
+}
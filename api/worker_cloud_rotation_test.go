@@ -0,0 +1,76 @@
+package center
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/wordgate/qtoolkit/db"
+)
+
+func sigPtr(v float64) *float64 { return &v }
+
+func TestBlockConfidence(t *testing.T) {
+	conf, n := blockConfidence(nil, nil, nil)
+	assert.Equal(t, 0.0, conf)
+	assert.Equal(t, 0, n)
+
+	// Missing signals are excluded from the weighted mean, not counted as 0.
+	conf, n = blockConfidence(sigPtr(1), nil, nil)
+	assert.InDelta(t, 1.0, conf, 1e-9)
+	assert.Equal(t, 1, n)
+
+	conf, n = blockConfidence(sigPtr(1), sigPtr(0.5), sigPtr(0))
+	assert.InDelta(t, 0.4*1+0.3*0.5+0.3*0, conf, 1e-9)
+	assert.Equal(t, 3, n)
+}
+
+func TestRotationPolicyCheck(t *testing.T) {
+	policy := CloudInstanceRotationConfig{Providers: []string{"aws_lightsail"}}
+	applyRotationDefaults(&policy)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, rotationPolicyCheck(policy, "aws_lightsail", 0, 0, now))
+	assert.Error(t, rotationPolicyCheck(policy, "aliyun_swas", 0, 0, now), "provider not allowlisted")
+	assert.Error(t, rotationPolicyCheck(policy, "aws_lightsail", now.Add(-time.Hour).Unix(), 0, now), "cooldown")
+	assert.NoError(t, rotationPolicyCheck(policy, "aws_lightsail", now.Add(-73*time.Hour).Unix(), 2, now))
+	assert.Error(t, rotationPolicyCheck(policy, "aws_lightsail", 0, 3, now), "monthly limit")
+}
+
+func TestApplyRotationDefaults_NoProviderByDefault(t *testing.T) {
+	var policy CloudInstanceRotationConfig
+	applyRotationDefaults(&policy)
+	assert.Empty(t, policy.Providers, "auto-rotation must be opted into per provider")
+	assert.Equal(t, []string{"CN"}, policy.Countries)
+	assert.Equal(t, 0.7, policy.ConfidenceThreshold)
+}
+
+// TestRotationPending: open approvals are matched on the cloudInstanceId
+// field itself, whatever the serialized field order, and closed ones or other
+// instances (including ids sharing a prefix) do not count.
+func TestRotationPending(t *testing.T) {
+	skipIfNoConfig(t)
+	const id = 990001
+	var approvals []*AdminApproval
+	approve := func(status, params string) {
+		a := &AdminApproval{RequestorUUID: "system", RequestorName: "system", Action: approvalActionCloudRotateIP,
+			Params: params, Summary: "test", Status: status}
+		require.NoError(t, db.Get().Create(a).Error)
+		approvals = append(approvals, a)
+	}
+	t.Cleanup(func() {
+		for _, a := range approvals {
+			db.Get().Delete(a)
+		}
+	})
+
+	approve("executed", mustJSON(CloudRotateIPParams{CloudInstanceID: id}))
+	approve("pending", mustJSON(CloudRotateIPParams{CloudInstanceID: id * 10}))
+	assert.False(t, rotationPending(id), "executed approvals and other instances do not block")
+
+	approve("approved", fmt.Sprintf(`{"ip":"192.0.2.1","cloudInstanceId":%d}`, id))
+	assert.True(t, rotationPending(id))
+	assert.False(t, rotationPending(id+1))
+}
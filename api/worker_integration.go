@@ -52,6 +52,7 @@ func InitWorker() {
 	asynq.Handle(TaskTypePrivateNodeTrafficWarn, handlePrivateNodeTrafficWarn)
 	asynq.Handle(TaskTypeTrafficAbuseCheck, handleTrafficAbuseCheck)
 	asynq.Handle(TaskTypeStatsRetentionCleanup, handleStatsRetentionCleanup)
+	asynq.Handle(TaskTypeTelemetryRollup, handleTelemetryRollup)
 
 	// 注册续费提醒 Cron 任务
 	// 每天北京时间 10:30 执行（UTC 02:30）
@@ -98,6 +99,12 @@ func InitWorker() {
 	// Unique(25h) 防止多实例重复入队
 	asynq.Cron("20 4 * * *", TaskTypeStatsRetentionCleanup, nil, hibikenAsynq.Unique(25*time.Hour))
 
+	// 注册遥测分析 rollup Cron 任务
+	// 每 15 分钟整日重算今天与昨天(UTC)的 telemetry_rollups / telemetry_failure_rollups,
+	// 管理后台连接质量看板只读 rollup 表,不扫 telemetry_events 明细
+	// Unique(16min) 防止多实例重复入队
+	asynq.Cron("*/15 * * * *", TaskTypeTelemetryRollup, nil, hibikenAsynq.Unique(16*time.Minute))
+
 	// 注册 ECH 相关的 worker
 	RegisterECHWorker()
 
@@ -118,6 +125,7 @@ func InitWorker() {
 	RegisterApprovalCallback("license_key_batch_create", executeApprovalLicenseKeyBatchCreate)
 	RegisterApprovalCallback("license_key_batch_invalidate", executeApprovalLicenseKeyBatchInvalidate)
 	RegisterApprovalCallback("user_hard_delete", executeApprovalUserHardDelete)
+	RegisterApprovalCallback("user_reset_2fa", executeApprovalUserReset2FA)
 	RegisterApprovalCallback("plan_update", executeApprovalPlanUpdate)
 	RegisterApprovalCallback("plan_delete", executeApprovalPlanDelete)
 	RegisterApprovalCallback("withdraw_approve", executeApprovalWithdrawApprove)
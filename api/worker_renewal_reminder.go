@@ -125,8 +125,9 @@ func processRenewalReminders(ctx context.Context, daysBefore int) (sent, skipped
 	}
 
 	result, err := SendTemplatedEmails(ctx, &SendEmailsRequest{
-		BatchID: batchID,
-		Items:   items,
+		BatchID:  batchID,
+		Category: NotifyCategoryBilling,
+		Items:    items,
 	})
 	if err != nil {
 		log.Errorf(ctx, "[RENEWAL] SendTemplatedEmails failed: %v", err)
@@ -210,8 +211,9 @@ func processPrivateNodeRenewalReminders(ctx context.Context, daysBefore int) (se
 	}
 
 	result, err := SendTemplatedEmails(ctx, &SendEmailsRequest{
-		BatchID: batchID,
-		Items:   items,
+		BatchID:  batchID,
+		Category: NotifyCategoryBilling,
+		Items:    items,
 	})
 	if err != nil {
 		log.Errorf(ctx, "[PN-RENEWAL] SendTemplatedEmails failed: %v", err)
@@ -303,8 +305,9 @@ func processWinback(ctx context.Context, daysAfter int) (sent, skipped, failed i
 	}
 
 	result, err := SendTemplatedEmails(ctx, &SendEmailsRequest{
-		BatchID: batchID,
-		Items:   items,
+		BatchID:  batchID,
+		Category: NotifyCategoryMarketing,
+		Items:    items,
 	})
 	if err != nil {
 		log.Errorf(ctx, "[WINBACK] SendTemplatedEmails failed: %v", err)
@@ -20,6 +20,16 @@ const TaskTypeStatsRetentionCleanup = "stats:retention_cleanup"
 //     no historical queries exist — 30 days is pure slack.
 //   - stat_* / connection_ratings: admin reports look back at most 90 days
 //     (parseRangeDays caps at "90d") — 120 days keeps a 30-day buffer.
+//   - rule_misses / rule_miss_aggregates / rule_miss_reveals: windows live in
+//     logic_rule_miss.go;
+//     aggregates sweep on updated_at so a hash still being reported survives.
+//   - login_risk_events: window lives in logic_login_risk.go; the admin
+//     login-risk page only traces recent blocks and challenges.
+//   - telemetry_events / telemetry_rate_limits: windows live in
+//     logic_telemetry_analytics.go; dashboards read the rollups instead.
+//   - telemetry_*rollups: back the admin connection dashboards (same 90-day
+//     range cap as stat_*); rebuilt rows get a fresh updated_at, so a day is
+//     swept statsRetentionDays after its last rebuild.
 const (
 	nodeLoadRetentionDays = 30
 	statsRetentionDays    = 120
@@ -45,6 +55,14 @@ var statsRetentionTargets = []statsRetentionTarget{
 	{"stat_app_opens", "reported_at", statsRetentionDays},
 	{"stat_k2s_downloads", "created_at", statsRetentionDays},
 	{"connection_ratings", "created_at", statsRetentionDays},
+	{"rule_misses", "created_at", ruleMissRawRetentionDays},
+	{"rule_miss_aggregates", "updated_at", ruleMissAggregateRetentionDays},
+	{"rule_miss_reveals", "created_at", ruleMissRawRetentionDays},
+	{"login_risk_events", "created_at", loginRiskEventRetentionDays},
+	{"telemetry_events", "created_at", telemetryEventRetentionDays},
+	{"telemetry_rate_limits", "created_at", telemetryRateLimitRetentionDays},
+	{"telemetry_rollups", "updated_at", statsRetentionDays},
+	{"telemetry_failure_rollups", "updated_at", statsRetentionDays},
 }
 
 // deleteInBatches removes rows where column < cutoff, batchSize rows per
@@ -0,0 +1,31 @@
+package center
+
+import (
+	"context"
+	"time"
+
+	"github.com/wordgate/qtoolkit/log"
+)
+
+// TaskTypeTelemetryRollup is the Asynq cron task that refreshes the daily
+// telemetry rollups the admin dashboard reads, so no admin request ever scans
+// telemetry_events. Each run rebuilds today and yesterday (UTC): yesterday
+// picks up batches that clients uploaded late, older days are final.
+const TaskTypeTelemetryRollup = "telemetry:rollup"
+
+// handleTelemetryRollup is the Asynq cron handler for TaskTypeTelemetryRollup.
+// Returning the error lets Asynq retry; the rebuild is idempotent.
+func handleTelemetryRollup(ctx context.Context, _ []byte) error {
+	now := time.Now().UTC()
+	for _, day := range []string{
+		now.AddDate(0, 0, -1).Format("2006-01-02"),
+		now.Format("2006-01-02"),
+	} {
+		if err := rebuildTelemetryRollups(ctx, day); err != nil {
+			log.Errorf(ctx, "[TELEMETRY-ROLLUP] %s: %v", day, err)
+			return err
+		}
+	}
+	log.Infof(ctx, "[TELEMETRY-ROLLUP] refreshed rollups through %s", now.Format("2006-01-02"))
+	return nil
+}
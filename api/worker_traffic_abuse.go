@@ -147,10 +147,12 @@ func handleTrafficAbuseCheck(ctx context.Context, _ []byte) error {
 
 	// 每月一个 batch id：即使 TrafficAbuseAlert 去重行被人工清掉重跑，
 	// SendTemplatedEmails 的 (batch, template, user) 幂等键仍保证每人每月至多一封。
+	// 警告关系到账号是否被处置，走不可关闭的 security 类别，关掉服务通知的用户也会收到。
 	if len(warnItems) > 0 {
 		if _, serr := SendTemplatedEmails(ctx, &SendEmailsRequest{
-			BatchID: "traffic-abuse:" + month,
-			Items:   warnItems,
+			BatchID:  "traffic-abuse:" + month,
+			Category: NotifyCategorySecurity,
+			Items:    warnItems,
 		}); serr != nil {
 			log.Errorf(ctx, "[TRAFFIC-ABUSE] send warning emails: %v", serr)
 		}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -43,6 +45,11 @@ type CenterClient struct {
 	udid          string
 	http          *http.Client
 	refreshSource RefreshSource
+
+	// refreshMu serializes token refresh. Center rotates refresh tokens and
+	// treats a replayed one as stolen, so concurrent 401s must share a single
+	// refresh instead of each spending the same refresh token.
+	refreshMu sync.Mutex
 }
 
 // NewCenterClient creates a new CenterClient targeting baseURL.
@@ -74,6 +81,13 @@ func (c *CenterClient) SetUDID(udid string) {
 	c.mu.Unlock()
 }
 
+// UDID returns the device UDID sent in the X-UDID header.
+func (c *CenterClient) UDID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.udid
+}
+
 // SetRefreshSource registers a RefreshSource used to obtain a refresh token
 // and persist new tokens when a 401 triggers automatic re-authentication.
 func (c *CenterClient) SetRefreshSource(rs RefreshSource) {
@@ -85,22 +99,7 @@ func (c *CenterClient) SetRefreshSource(rs RefreshSource) {
 // Get performs a GET request to path and unmarshals the response Data into result.
 // On a 401 CenterError it attempts one transparent token refresh and retries.
 func (c *CenterClient) Get(path string, result any) error {
-	req, err := http.NewRequest(http.MethodGet, c.BaseURL+path, nil)
-	if err != nil {
-		return fmt.Errorf("center client get: %w", err)
-	}
-	err = c.do(req, result)
-	if !c.isCode401(err) {
-		return err
-	}
-	if !c.tryRefresh() {
-		return err
-	}
-	req2, err2 := http.NewRequest(http.MethodGet, c.BaseURL+path, nil)
-	if err2 != nil {
-		return fmt.Errorf("center client get retry: %w", err2)
-	}
-	return c.do(req2, result)
+	return c.send(http.MethodGet, path, nil, nil, result)
 }
 
 // Post performs a POST request to path with body marshalled as JSON,
@@ -108,27 +107,71 @@ func (c *CenterClient) Get(path string, result any) error {
 // On a 401 CenterError it attempts one transparent token refresh and retries
 // (body bytes are marshalled once and replayed on retry).
 func (c *CenterClient) Post(path string, body any, result any) error {
-	b, err := json.Marshal(body)
-	if err != nil {
-		return fmt.Errorf("center client post marshal: %w", err)
+	return c.send(http.MethodPost, path, nil, body, result)
+}
+
+// PostWithHeader is Post with extra request headers, e.g. X-Access-Key.
+func (c *CenterClient) PostWithHeader(path string, header http.Header, body any, result any) error {
+	return c.send(http.MethodPost, path, header, body, result)
+}
+
+// Put is Post with the PUT method.
+func (c *CenterClient) Put(path string, body any, result any) error {
+	return c.send(http.MethodPut, path, nil, body, result)
+}
+
+// Delete performs a DELETE request to path and unmarshals the response Data
+// into result.
+func (c *CenterClient) Delete(path string, result any) error {
+	return c.send(http.MethodDelete, path, nil, nil, result)
+}
+
+// send builds and executes a request, retrying once after a transparent token
+// refresh on 401. A nil body sends no payload.
+func (c *CenterClient) send(method, path string, header http.Header, body any, result any) error {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("center client %s marshal: %w", strings.ToLower(method), err)
+		}
 	}
-	req, err := http.NewRequest(http.MethodPost, c.BaseURL+path, bytes.NewReader(b))
+	newReq := func() (*http.Request, error) {
+		var r io.Reader
+		if b != nil {
+			r = bytes.NewReader(b)
+		}
+		req, err := http.NewRequest(method, c.BaseURL+path, r)
+		if err != nil {
+			return nil, fmt.Errorf("center client %s: %w", strings.ToLower(method), err)
+		}
+		for k, vs := range header {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+		if b != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, nil
+	}
+
+	req, err := newReq()
 	if err != nil {
-		return fmt.Errorf("center client post: %w", err)
+		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	staleToken := c.Token()
 	err = c.do(req, result)
 	if !c.isCode401(err) {
 		return err
 	}
-	if !c.tryRefresh() {
+	if !c.tryRefresh(staleToken) {
 		return err
 	}
-	req2, err2 := http.NewRequest(http.MethodPost, c.BaseURL+path, bytes.NewReader(b))
+	req2, err2 := newReq()
 	if err2 != nil {
-		return fmt.Errorf("center client post retry: %w", err2)
+		return err2
 	}
-	req2.Header.Set("Content-Type", "application/json")
 	return c.do(req2, result)
 }
 
@@ -146,8 +189,17 @@ type refreshResponse struct {
 
 // tryRefresh attempts to obtain new tokens using the refresh token.
 // It updates both the in-memory token and the persistent store.
-// Returns true if the refresh succeeded.
-func (c *CenterClient) tryRefresh() bool {
+// staleToken is the access token the failed request was sent with; if another
+// caller has already replaced it, the refresh is skipped and the retry uses
+// the new token. Returns true if a usable token is now available.
+func (c *CenterClient) tryRefresh(staleToken string) bool {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if c.Token() != staleToken {
+		return true
+	}
+
 	c.mu.RLock()
 	rs := c.refreshSource
 	c.mu.RUnlock()
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"sync/atomic"
 	"testing"
 )
@@ -274,6 +275,68 @@ func TestCenterClient_AutoRefresh_Post(t *testing.T) {
 	}
 }
 
+func TestCenterClient_AutoRefresh_ConcurrentSharesRefresh(t *testing.T) {
+	// Center rotates refresh tokens and rejects a replayed one, so concurrent
+	// 401s must trigger exactly one refresh.
+	const n = 5
+	var refreshCalls atomic.Int32
+	var arrived sync.WaitGroup
+	arrived.Add(n)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/auth/refresh":
+			if refreshCalls.Add(1) > 1 {
+				json.NewEncoder(w).Encode(centerResponse{Code: 401, Message: "refresh token reused"})
+				return
+			}
+			data, _ := json.Marshal(refreshResponse{AccessToken: "new-access", RefreshToken: "new-refresh"})
+			json.NewEncoder(w).Encode(centerResponse{Code: 0, Data: json.RawMessage(data)})
+		case "/api/user":
+			if r.Header.Get("Authorization") != "Bearer new-access" {
+				// Hold every stale request until all have arrived so their 401s overlap.
+				arrived.Done()
+				arrived.Wait()
+				json.NewEncoder(w).Encode(centerResponse{Code: 401, Message: "unauthorized"})
+				return
+			}
+			json.NewEncoder(w).Encode(centerResponse{Code: 0, Data: json.RawMessage(`{}`)})
+		}
+	}))
+	defer srv.Close()
+
+	sess := NewSession(t.TempDir())
+	sess.mu.Lock()
+	sess.AccessToken = "old-access"
+	sess.RefreshToken = "old-refresh"
+	sess.mu.Unlock()
+
+	c := NewCenterClient(srv.URL)
+	c.SetToken("old-access")
+	c.SetRefreshSource(sess)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.Get("/api/user", nil)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if got := refreshCalls.Load(); got != 1 {
+		t.Errorf("expected 1 refresh call, got %d", got)
+	}
+}
+
 func TestCenterClient_AutoRefresh_NoRefreshSource(t *testing.T) {
 	// Without a refresh source, a 401 should propagate as-is.
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -293,4 +356,24 @@ func TestCenterClient_AutoRefresh_NoRefreshSource(t *testing.T) {
 	if !errors.As(err, &ce) || ce.Code != 401 {
 		t.Errorf("expected 401 CenterError, got %v", err)
 	}
-}
\ No newline at end of file
+}
+func TestCenterClient_PutAndDelete(t *testing.T) {
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Method+" "+r.URL.Path+" "+r.Header.Get("Content-Type"))
+		json.NewEncoder(w).Encode(centerResponse{Code: 0, Message: "ok"})
+	}))
+	defer srv.Close()
+
+	c := NewCenterClient(srv.URL)
+	if err := c.Put("/things/1", map[string]string{"remark": "x"}, nil); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := c.Delete("/things/1", nil); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	want := []string{"PUT /things/1 application/json", "DELETE /things/1 "}
+	if len(seen) != 2 || seen[0] != want[0] || seen[1] != want[1] {
+		t.Errorf("got %q, want %q", seen, want)
+	}
+}
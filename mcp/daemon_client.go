@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -40,10 +42,22 @@ type DaemonConfig struct {
 	Routes []DaemonRoute `json:"routes"`
 }
 
-// DaemonRoute is one entry of ClientConfig.Routes. Only Via is needed here;
-// match criteria are irrelevant to naming the active server.
+// DaemonRoute is one entry of ClientConfig.Routes: traffic matching Match
+// goes to Via (an outbound URL, "direct" or "reject"). An empty Match is the
+// engine's fallback route.
 type DaemonRoute struct {
-	Via string `json:"via"`
+	Via   string       `json:"via"`
+	Match *DaemonMatch `json:"match,omitempty"`
+}
+
+// DaemonMatch is the subset of the engine's MatchConfig that k2-mcp writes.
+// Mirrors webapp/src/types/client-config.ts MatchConfig.
+type DaemonMatch struct {
+	All          bool     `json:"all,omitempty"`
+	Preset       string   `json:"preset,omitempty"`
+	Region       string   `json:"region,omitempty"`
+	DomainSuffix []string `json:"domain_suffix,omitempty"`
+	IPCIDR       []string `json:"ip_cidr,omitempty"`
 }
 
 // Server returns the first wire outbound URL in the route table, or "" when
@@ -104,12 +118,18 @@ func (d *DaemonClient) Ping() error {
 // "connecting" (doUp is async), and the engine fails 570 "no k2v5 outbound
 // configured" afterwards, out of band: a silent failure with a success reply.
 func (d *DaemonClient) Up(serverURL string) error {
+	return d.UpRoutes([]DaemonRoute{{Via: serverURL}})
+}
+
+// UpRoutes sends an "up" action with a full route table. At least one route
+// must carry the outbound URL as Via (see Up).
+func (d *DaemonClient) UpRoutes(routes []DaemonRoute) error {
 	return d.postCore(map[string]any{
 		"action": "up",
 		"params": map[string]any{
 			"config": map[string]any{
 				"mode":   "tun",
-				"routes": []map[string]string{{"via": serverURL}},
+				"routes": routes,
 			},
 		},
 	})
@@ -177,3 +197,64 @@ func (d *DaemonClient) postCore(body any) error {
 	}
 	return nil
 }
+
+// DaemonEvent is one server-sent event from GET /api/events. Type is the
+// "event:" field ("status", "stats"); it is empty when the daemon omits it.
+type DaemonEvent struct {
+	Type string
+	Data []byte
+}
+
+// Events streams GET /api/events, calling fn for each complete event until
+// the stream ends or ctx is cancelled. It always returns a non-nil error:
+// ctx.Err() on cancellation, otherwise why the stream stopped.
+//
+// Same wire format the desktop's status_stream.rs consumes: "event:" and
+// "data:" lines, a blank line ends an event, ":" lines are heartbeats.
+func (d *DaemonClient) Events(ctx context.Context, fn func(DaemonEvent)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.Addr+"/api/events", nil)
+	if err != nil {
+		return fmt.Errorf("daemon events: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// The stream is long-lived: reuse the transport but drop the 5s timeout.
+	client := &http.Client{Transport: d.httpClient().Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("daemon events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &DaemonError{Code: resp.StatusCode, Message: resp.Status}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var ev DaemonEvent
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			if len(ev.Data) > 0 {
+				fn(ev)
+			}
+			ev = DaemonEvent{}
+		case strings.HasPrefix(line, "event:"):
+			ev.Type = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if len(ev.Data) > 0 {
+				ev.Data = append(ev.Data, '\n')
+			}
+			ev.Data = append(ev.Data, strings.TrimSpace(strings.TrimPrefix(line, "data:"))...)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("daemon events read: %w", err)
+	}
+	return fmt.Errorf("daemon events: stream closed")
+}
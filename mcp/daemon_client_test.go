@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -178,3 +180,40 @@ func TestDaemonClient_Status(t *testing.T) {
 		t.Errorf("expected server 'k2v5://server.example.com', got '%s'", got)
 	}
 }
+
+func TestDaemonClient_Events(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/events" {
+			t.Errorf("expected /api/events, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("expected Accept text/event-stream, got %q", r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, ": heartbeat\n\n"+
+			"event: status\ndata: {\"state\":\"connecting\"}\n\n"+
+			"event: stats\r\ndata: {\"tx\":1}\r\n\r\n"+
+			"data: line1\ndata: line2\n\n")
+	}))
+	defer srv.Close()
+
+	var got []DaemonEvent
+	c := &DaemonClient{Addr: srv.URL}
+	err := c.Events(context.Background(), func(ev DaemonEvent) { got = append(got, ev) })
+	if err == nil {
+		t.Fatal("expected an error when the stream closes")
+	}
+	want := []DaemonEvent{
+		{Type: "status", Data: []byte(`{"state":"connecting"}`)},
+		{Type: "stats", Data: []byte(`{"tx":1}`)},
+		{Type: "", Data: []byte("line1\nline2")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type || string(got[i].Data) != string(want[i].Data) {
+			t.Errorf("event %d: expected %s %q, got %s %q", i, want[i].Type, want[i].Data, got[i].Type, got[i].Data)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// maxDaemonErrors bounds the in-memory daemon error history.
+const maxDaemonErrors = 20
+
+// daemonErrorEntry is one distinct daemon error seen by k2-mcp. Repeats of
+// the latest error (e.g. polling status while stuck in "error") only bump
+// Count and LastSeen.
+type daemonErrorEntry struct {
+	Code      int       `json:"code"`
+	Message   string    `json:"message"`
+	Source    string    `json:"source"` // status, events, connect
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// daemonErrors is the recent daemon error history. The daemon keeps no
+// history of its own, so this is what this process has observed since it
+// started. Zero value is ready to use.
+type daemonErrors struct {
+	mu      sync.Mutex
+	entries []daemonErrorEntry
+}
+
+// record adds an error, folding it into the newest entry if identical.
+func (h *daemonErrors) record(code int, message, source string) {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n := len(h.entries); n > 0 {
+		last := &h.entries[n-1]
+		if last.Code == code && last.Message == message {
+			last.Count++
+			last.LastSeen = now
+			return
+		}
+	}
+	h.entries = append(h.entries, daemonErrorEntry{
+		Code: code, Message: message, Source: source,
+		Count: 1, FirstSeen: now, LastSeen: now,
+	})
+	if len(h.entries) > maxDaemonErrors {
+		h.entries = h.entries[len(h.entries)-maxDaemonErrors:]
+	}
+}
+
+// list returns the history, newest last.
+func (h *daemonErrors) list() []daemonErrorEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]daemonErrorEntry(nil), h.entries...)
+}
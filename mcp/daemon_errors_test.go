@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDaemonErrors_FoldsRepeatsAndBounds(t *testing.T) {
+	var h daemonErrors
+	h.record(502, "handshake timeout", "status")
+	h.record(502, "handshake timeout", "events")
+	h.record(401, "auth failed", "connect")
+
+	got := h.list()
+	if len(got) != 2 || got[0].Count != 2 || got[1].Code != 401 {
+		t.Fatalf("unexpected history: %+v", got)
+	}
+
+	for i := 0; i < maxDaemonErrors+5; i++ {
+		h.record(500, fmt.Sprintf("err %d", i), "status")
+	}
+	got = h.list()
+	if len(got) != maxDaemonErrors {
+		t.Fatalf("expected %d entries, got %d", maxDaemonErrors, len(got))
+	}
+	if want := fmt.Sprintf("err %d", maxDaemonErrors+4); got[len(got)-1].Message != want {
+		t.Errorf("newest entry should be last, got %q", got[len(got)-1].Message)
+	}
+}
@@ -21,18 +21,41 @@ type Server struct {
 	TrafficUsagePercent   float64 `json:"traffic_usage_percent"`
 	BandwidthUsagePercent float64 `json:"bandwidth_usage_percent"`
 	ServerURL             string  `json:"server_url"`
+	RecommendScore        float64 `json:"recommend_score"`
 }
 
+// serverVersion is reported to MCP clients and attached to issue reports.
+const serverVersion = "0.1.0"
+
 // App holds shared state for all MCP tool handlers.
 type App struct {
 	center  *CenterClient
 	daemon  *DaemonClient
 	session *Session
 
+	// k2://status subscriptions fed by the daemon event stream.
+	status statusWatch
+
 	// Server list cache.
 	serversMu       sync.RWMutex
 	servers         []Server
 	serversCachedAt time.Time
+
+	// Latest probe_servers results, reused briefly by connect_best.
+	probesMu sync.Mutex
+	probes   map[int]probeResult
+
+	// Serialises read-modify-write of mcp-routes.json.
+	routingMu sync.Mutex
+
+	// Daemon errors seen by status, the event stream and connect (diagnose).
+	daemonErrors daemonErrors
+
+	// Device code awaiting approval (device_login / device_login_wait).
+	deviceLogin deviceLogin
+
+	// Auto-reconnect and failover (watchdog tool).
+	watchdog watchdog
 }
 
 func main() {
@@ -49,6 +72,9 @@ func main() {
 		if err := sess.Restore(); err != nil {
 			log.Printf("session restore: %v", err)
 		}
+	} else if err := NewSession(sessionDir).Restore(); err != nil {
+		// Unused this run, but still encrypt a plaintext MCP session at rest.
+		log.Printf("session migrate: %v", err)
 	}
 
 	center := NewCenterClient(apiURL)
@@ -76,10 +102,48 @@ func main() {
 	// Wire refresh source so 401 responses trigger automatic token refresh.
 	app.center.SetRefreshSource(app.session)
 
+	// CI / team machines: log in unattended with an access key.
+	if key := os.Getenv(accessKeyEnv); key != "" && !sess.LoggedIn() {
+		if _, err := app.loginWithAccessKey(key); err != nil {
+			log.Printf("access key login: %v", err)
+		}
+	}
+
+	server := newServer(app)
+
+	switch transport := envOr("K2_MCP_TRANSPORT", "stdio"); transport {
+	case "stdio":
+		if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
+			log.Fatal(err)
+		}
+	case "http":
+		// Long-running shared instance: several agents (or a remote
+		// orchestrator over an SSH tunnel) connect to one k2-mcp.
+		token, err := loadOrCreateHTTPToken(sessionDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		addr := envOr("K2_MCP_HTTP_ADDR", defaultHTTPAddr)
+		if err := serveHTTP(context.Background(), addr, newHTTPHandler(server, token)); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown K2_MCP_TRANSPORT %q (want stdio or http)", transport)
+	}
+}
+
+// newServer builds the MCP server with every tool and resource bound to app.
+// One server serves every transport and session.
+func newServer(app *App) *mcp.Server {
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "k2-mcp",
-		Version: "0.1.0",
-	}, nil)
+		Version: serverVersion,
+	}, &mcp.ServerOptions{
+		SubscribeHandler:   app.subscribeStatus,
+		UnsubscribeHandler: app.unsubscribeStatus,
+	})
+
+	app.addStatusResource(server)
 
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "send_code",
@@ -91,6 +155,21 @@ func main() {
 		Description: "Log in to Kaitu with email and verification code",
 	}, app.toolLogin)
 
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "login_access_key",
+		Description: "Log in non-interactively with an access key (for CI and shared machines)",
+	}, app.toolLoginAccessKey)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "device_login",
+		Description: "Start a device login: returns a short code for a logged-in user to approve in the Kaitu app",
+	}, app.toolDeviceLogin)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "device_login_wait",
+		Description: "Wait for the pending device login to be approved and store the session",
+	}, app.toolDeviceLoginWait)
+
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "account_info",
 		Description: "Get current account information",
@@ -116,19 +195,112 @@ func main() {
 		Description: "Connect to a VPN server",
 	}, app.toolConnect)
 
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "probe_servers",
+		Description: "Measure reachability and handshake latency to VPN servers, optionally filtered by country or region",
+	}, app.toolProbeServers)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "connect_best",
+		Description: "Connect to the best VPN server by latency and server recommendation, optionally filtered by country or region",
+	}, app.toolConnectBest)
+
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "disconnect",
 		Description: "Disconnect from the VPN",
 	}, app.toolDisconnect)
 
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_routes",
+		Description: "Show the routing profile and split-tunnel rules applied when connecting",
+	}, app.toolListRoutes)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "add_route",
+		Description: "Add a split-tunnel rule: send matching domains, CIDRs or a country's traffic via the tunnel, direct, or reject it",
+	}, app.toolAddRoute)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "remove_route",
+		Description: "Remove a split-tunnel rule by ID",
+	}, app.toolRemoveRoute)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "set_routing_profile",
+		Description: "Select a named routing profile (e.g. global, cnroute) or a country code",
+	}, app.toolSetRoutingProfile)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "usage",
+		Description: "Show traffic used over the last N days: totals, top devices, daily totals and how close this month is to the fair-use threshold",
+	}, app.toolUsage)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_devices",
+		Description: "List devices signed in to the account",
+	}, app.toolListDevices)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "remove_device",
+		Description: "Sign out a device and free its slot",
+	}, app.toolRemoveDevice)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rename_device",
+		Description: "Set a device's name (remark)",
+	}, app.toolRenameDevice)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_tickets",
+		Description: "List support tickets and their unread replies",
+	}, app.toolListTickets)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_ticket",
+		Description: "Show a support ticket with its full conversation",
+	}, app.toolGetTicket)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "reply_ticket",
+		Description: "Reply to a support ticket",
+	}, app.toolReplyTicket)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "report_issue",
+		Description: "File a support ticket describing a problem, with the current VPN status attached",
+	}, app.toolReportIssue)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_invites",
+		Description: "List invite codes with registration and purchase counts",
+	}, app.toolListInvites)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "create_invite",
+		Description: "Create an invite code and share link for a new user",
+	}, app.toolCreateInvite)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "web_login_link",
+		Description: "Get a one-time link that opens the Kaitu website signed in",
+	}, app.toolWebLoginLink)
+
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "status",
 		Description: "Get current VPN connection status",
 	}, app.toolStatus)
 
-	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
-		log.Fatal(err)
-	}
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "watchdog",
+		Description: "Enable or disable auto-reconnect for this session: reconnects a dropped tunnel with backoff and fails over to the next best server, reporting each action as a log notification",
+	}, app.toolWatchdog)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "diagnose",
+		Description: "Run connection diagnostics (daemon, login, server, DNS, route) and optionally upload the report for support",
+	}, app.toolDiagnose)
+
+	return server
 }
 
 func envOr(key, fallback string) string {
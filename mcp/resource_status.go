@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// statusResourceURI is the MCP resource exposing live connection state.
+const statusResourceURI = "k2://status"
+
+// statusRetryDelay is how long the watcher waits before reconnecting to the
+// daemon event stream (matches the desktop's status_stream.rs).
+var statusRetryDelay = 3 * time.Second
+
+// statusWatch follows the daemon SSE stream while at least one MCP session is
+// subscribed to k2://status. Zero value is ready to use.
+type statusWatch struct {
+	mu     sync.Mutex
+	subs   map[*mcp.ServerSession]bool
+	cancel context.CancelFunc
+	last   string // statusKey of the last state seen, "" until the baseline
+
+	// notify sends notifications/resources/updated; set by addStatusResource.
+	notify func()
+	// waiting marks sessions with a goroutine blocked on ss.Wait(), so a
+	// session that closes without unsubscribing (an HTTP client that just
+	// went away) is dropped then, and re-subscribing doesn't start another.
+	waiting map[*mcp.ServerSession]bool
+}
+
+// statusKey reduces a daemon status to what subscribers care about. Uptime
+// is deliberately excluded: it ticks every second and is not a state change.
+func statusKey(st *DaemonStatus) string {
+	key := st.State + "|" + st.Config.Server()
+	if st.Error != nil {
+		key += fmt.Sprintf("|%d|%s", st.Error.Code, st.Error.Message)
+	}
+	return key
+}
+
+// addStatusResource registers k2://status on server. The server must have
+// been created with subscribeStatus / unsubscribeStatus as its handlers.
+func (app *App) addStatusResource(server *mcp.Server) {
+	app.status.notify = func() {
+		server.ResourceUpdated(context.Background(), &mcp.ResourceUpdatedNotificationParams{ //nolint:errcheck // always nil
+			URI: statusResourceURI,
+		})
+	}
+	server.AddResource(&mcp.Resource{
+		URI:         statusResourceURI,
+		Name:        "status",
+		Description: "Current VPN connection state. Subscribe to be notified on connect, disconnect and errors.",
+		MIMEType:    "application/json",
+	}, app.readStatusResource)
+}
+
+// readStatusResource serves k2://status with the same payload as the status tool.
+func (app *App) readStatusResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	b, err := json.Marshal(app.statusSnapshot())
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{
+		{URI: statusResourceURI, MIMEType: "application/json", Text: string(b)},
+	}}, nil
+}
+
+// subscribeStatus starts the daemon event watcher on the first subscriber.
+func (app *App) subscribeStatus(ctx context.Context, req *mcp.SubscribeRequest) error {
+	if req.Params.URI != statusResourceURI {
+		return mcp.ResourceNotFoundError(req.Params.URI)
+	}
+	w := &app.status
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.subs == nil {
+		w.subs = make(map[*mcp.ServerSession]bool)
+		w.waiting = make(map[*mcp.ServerSession]bool)
+	}
+	w.subs[req.Session] = true
+	if ss := req.Session; ss != nil && !w.waiting[ss] {
+		w.waiting[ss] = true
+		go func() {
+			ss.Wait() //nolint:errcheck // only the close matters
+			w.mu.Lock()
+			delete(w.waiting, ss)
+			w.dropLocked(ss)
+			w.mu.Unlock()
+		}()
+	}
+	if w.cancel == nil {
+		watchCtx, cancel := context.WithCancel(context.Background())
+		w.cancel = cancel
+		w.last = ""
+		go app.watchStatus(watchCtx)
+	}
+	return nil
+}
+
+// unsubscribeStatus stops the watcher once the last subscriber leaves.
+func (app *App) unsubscribeStatus(ctx context.Context, req *mcp.UnsubscribeRequest) error {
+	if req.Params.URI != statusResourceURI {
+		return mcp.ResourceNotFoundError(req.Params.URI)
+	}
+	w := &app.status
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dropLocked(req.Session)
+	return nil
+}
+
+// watchStatus consumes the daemon event stream until ctx is cancelled,
+// reconnecting after statusRetryDelay. Losing the stream counts as
+// "disconnected", the same thing the status tool reports for an
+// unreachable daemon.
+func (app *App) watchStatus(ctx context.Context) {
+	for {
+		err := app.daemon.Events(ctx, func(ev DaemonEvent) {
+			if ev.Type != "status" && ev.Type != "" {
+				return
+			}
+			var st DaemonStatus
+			if err := json.Unmarshal(ev.Data, &st); err != nil {
+				log.Printf("daemon status event: %v", err)
+				return
+			}
+			if st.Error != nil {
+				app.daemonErrors.record(st.Error.Code, st.Error.Message, "events")
+			}
+			app.publishStatus(statusKey(&st))
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("daemon events: %v", err)
+		app.publishStatus(statusKey(&DaemonStatus{State: "disconnected"}))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(statusRetryDelay):
+		}
+	}
+}
+
+// publishStatus notifies subscribers if key differs from the last state seen.
+// The first state after the watcher starts is the baseline the subscriber
+// already has (it was just read), so it is recorded without a notification.
+func (app *App) publishStatus(key string) {
+	w := &app.status
+	w.mu.Lock()
+	if key == w.last {
+		w.mu.Unlock()
+		return
+	}
+	baseline := w.last == ""
+	w.last = key
+	if baseline || len(w.subs) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	notify := w.notify
+	w.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+}
+
+// dropLocked removes the subscription of ss and stops the watcher when none
+// remain. Caller holds mu.
+func (w *statusWatch) dropLocked(ss *mcp.ServerSession) {
+	delete(w.subs, ss)
+	if len(w.subs) == 0 && w.cancel != nil {
+		w.cancel()
+		w.cancel = nil
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// sseDaemon is a fake daemon whose /api/events stream emits whatever status
+// payloads the test pushes, and whose /api/core status answers with the
+// latest one.
+type sseDaemon struct {
+	srv    *httptest.Server
+	events chan string
+	drop   chan struct{}
+}
+
+func newSSEDaemon(t *testing.T) *sseDaemon {
+	t.Helper()
+	d := &sseDaemon{events: make(chan string, 8), drop: make(chan struct{}, 1)}
+	d.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/core":
+			writeDaemonEnvelope(w, DaemonStatus{State: "connected"})
+		case "/api/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case payload := <-d.events:
+					fmt.Fprintf(w, "event: status\ndata: %s\n\n", payload)
+					w.(http.Flusher).Flush()
+				case <-d.drop:
+					return
+				case <-r.Context().Done():
+					return
+				}
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(d.srv.Close)
+	return d
+}
+
+// connectStatusClient wires app into an in-memory MCP server/client pair and
+// returns the client session plus a channel of resource-updated URIs.
+func connectStatusClient(t *testing.T, app *App) (*mcp.ClientSession, <-chan string) {
+	t.Helper()
+	server := mcp.NewServer(&mcp.Implementation{Name: "k2-mcp", Version: "test"}, &mcp.ServerOptions{
+		SubscribeHandler:   app.subscribeStatus,
+		UnsubscribeHandler: app.unsubscribeStatus,
+	})
+	app.addStatusResource(server)
+
+	updates := make(chan string, 8)
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "test"}, &mcp.ClientOptions{
+		ResourceUpdatedHandler: func(_ context.Context, req *mcp.ResourceUpdatedNotificationRequest) {
+			updates <- req.Params.URI
+		},
+	})
+
+	ctx := context.Background()
+	st, ct := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(ctx, st, nil); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	cs, err := client.Connect(ctx, ct, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return cs, updates
+}
+
+func expectUpdate(t *testing.T, updates <-chan string) {
+	t.Helper()
+	select {
+	case uri := <-updates:
+		if uri != statusResourceURI {
+			t.Fatalf("expected update for %s, got %s", statusResourceURI, uri)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for resource update")
+	}
+}
+
+func expectNoUpdate(t *testing.T, updates <-chan string) {
+	t.Helper()
+	select {
+	case uri := <-updates:
+		t.Fatalf("unexpected update for %s", uri)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestStatusResource_Read(t *testing.T) {
+	d := newSSEDaemon(t)
+	app := newTestApp(t, "http://127.0.0.1:0")
+	app.daemon = &DaemonClient{Addr: d.srv.URL}
+	cs, _ := connectStatusClient(t, app)
+
+	res, err := cs.ReadResource(context.Background(), &mcp.ReadResourceParams{URI: statusResourceURI})
+	if err != nil {
+		t.Fatalf("read resource: %v", err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal([]byte(res.Contents[0].Text), &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if out["state"] != "connected" {
+		t.Errorf("expected state='connected', got %v", out["state"])
+	}
+}
+
+func TestStatusResource_SubscribeNotifiesOnChange(t *testing.T) {
+	defer func(d time.Duration) { statusRetryDelay = d }(statusRetryDelay)
+	statusRetryDelay = 10 * time.Millisecond
+
+	d := newSSEDaemon(t)
+	app := newTestApp(t, "http://127.0.0.1:0")
+	app.daemon = &DaemonClient{Addr: d.srv.URL}
+	cs, updates := connectStatusClient(t, app)
+
+	ctx := context.Background()
+	if err := cs.Subscribe(ctx, &mcp.SubscribeParams{URI: statusResourceURI}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	// Initial event on connect is the baseline, not a change.
+	d.events <- `{"state":"connected","uptimeSeconds":1}`
+	expectNoUpdate(t, updates)
+
+	// Uptime ticking is not a change either.
+	d.events <- `{"state":"connected","uptimeSeconds":2}`
+	expectNoUpdate(t, updates)
+
+	d.events <- `{"state":"error","error":{"code":570,"message":"no k2v5 outbound configured"}}`
+	expectUpdate(t, updates)
+
+	// Losing the daemon stream reads as disconnected.
+	d.drop <- struct{}{}
+	expectUpdate(t, updates)
+
+	// Reconnected and back up.
+	d.events <- `{"state":"connected","uptimeSeconds":3}`
+	expectUpdate(t, updates)
+
+	if err := cs.Unsubscribe(ctx, &mcp.UnsubscribeParams{URI: statusResourceURI}); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	app.status.mu.Lock()
+	running := app.status.cancel != nil
+	app.status.mu.Unlock()
+	if running {
+		t.Error("watcher still running after last unsubscribe")
+	}
+}
+
+func TestStatusResource_ClosedSessionStopsWatcher(t *testing.T) {
+	d := newSSEDaemon(t)
+	app := newTestApp(t, "http://127.0.0.1:0")
+	app.daemon = &DaemonClient{Addr: d.srv.URL}
+	cs, _ := connectStatusClient(t, app)
+
+	if err := cs.Subscribe(context.Background(), &mcp.SubscribeParams{URI: statusResourceURI}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	// Closing without unsubscribing must still release the subscription.
+	cs.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		app.status.mu.Lock()
+		subs, running := len(app.status.subs), app.status.cancel != nil
+		app.status.mu.Unlock()
+		if subs == 0 && !running {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("after session close: %d subscriptions, watcher running=%v", subs, running)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStatusResource_SubscribeUnknownURI(t *testing.T) {
+	app := newTestApp(t, "http://127.0.0.1:0")
+	cs, _ := connectStatusClient(t, app)
+
+	if err := cs.Subscribe(context.Background(), &mcp.SubscribeParams{URI: "k2://nope"}); err == nil {
+		t.Fatal("expected error subscribing to an unknown resource")
+	}
+}
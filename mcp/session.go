@@ -14,13 +14,16 @@ import (
 	"time"
 )
 
-// sessionData is the on-disk representation of the session.
-type sessionData struct {
-	AccessToken  string    `json:"access_token"`
-	RefreshToken string    `json:"refresh_token"`
-	Email        string    `json:"email"`
-	IssuedAt     time.Time `json:"issued_at"`
-}
+// Keys of mcp-session.json. Like the desktop storage.json it is a flat
+// string map whose values are JSON-encoded and then encrypted (ENC1:);
+// keySourceField is stored in clear and names where the key lives.
+const (
+	keySourceField    = "key_source"
+	accessTokenField  = "access_token"
+	refreshTokenField = "refresh_token"
+	emailField        = "email"
+	issuedAtField     = "issued_at"
+)
 
 // Session manages authentication tokens and device identity.
 type Session struct {
@@ -30,6 +33,7 @@ type Session struct {
 	Email        string
 	IssuedAt     time.Time
 	dir          string
+	keySource    string // sessionKeySources entry holding the file key
 }
 
 // NewSession creates a new Session that persists data to dir.
@@ -54,18 +58,42 @@ func (s *Session) LoggedIn() bool {
 	return s.AccessToken != ""
 }
 
-// Save persists the session to dir/session.json with 0600 permissions.
+// Save persists the session, encrypted, to dir/mcp-session.json with 0600
+// permissions. The key source of the previous save is reused while it still
+// holds the key.
 func (s *Session) Save() error {
 	s.mu.RLock()
-	data := sessionData{
-		AccessToken:  s.AccessToken,
-		RefreshToken: s.RefreshToken,
-		Email:        s.Email,
-		IssuedAt:     s.IssuedAt,
+	fields := map[string]string{
+		accessTokenField:  s.AccessToken,
+		refreshTokenField: s.RefreshToken,
+		emailField:        s.Email,
+		issuedAtField:     s.IssuedAt.Format(time.RFC3339Nano),
 	}
+	src := s.keySource
 	s.mu.RUnlock()
 
-	b, err := json.MarshalIndent(data, "", "  ")
+	var key *[32]byte
+	var err error
+	if src != "" {
+		key, err = sessionKeyFrom(src)
+	}
+	if src == "" || err != nil {
+		if src, key, err = newSessionKey(); err != nil {
+			return fmt.Errorf("session save: %w", err)
+		}
+	}
+
+	store := map[string]string{keySourceField: src}
+	for k, v := range fields {
+		raw, _ := json.Marshal(v)
+		enc, err := encryptValue(string(raw), *key)
+		if err != nil {
+			return fmt.Errorf("session save encrypt: %w", err)
+		}
+		store[k] = enc
+	}
+
+	b, err := json.MarshalIndent(store, "", "  ")
 	if err != nil {
 		return fmt.Errorf("session save marshal: %w", err)
 	}
@@ -78,11 +106,16 @@ func (s *Session) Save() error {
 	if err := os.WriteFile(path, b, 0600); err != nil {
 		return fmt.Errorf("session save write: %w", err)
 	}
+
+	s.mu.Lock()
+	s.keySource = src
+	s.mu.Unlock()
 	return nil
 }
 
-// Restore loads session from dir/session.json.
-// No error is returned if the file does not exist.
+// Restore loads session from dir/mcp-session.json.
+// No error is returned if the file does not exist. A plaintext session
+// written by an earlier version is loaded and re-saved encrypted.
 func (s *Session) Restore() error {
 	path := filepath.Join(s.dir, "mcp-session.json")
 	b, err := os.ReadFile(path)
@@ -93,17 +126,52 @@ func (s *Session) Restore() error {
 		return fmt.Errorf("session restore read: %w", err)
 	}
 
-	var data sessionData
-	if err := json.Unmarshal(b, &data); err != nil {
+	var store map[string]string
+	if err := json.Unmarshal(b, &store); err != nil {
 		return fmt.Errorf("session restore unmarshal: %w", err)
 	}
 
+	src := store[keySourceField]
+	var key *[32]byte
+	if src != "" {
+		if key, err = sessionKeyFrom(src); err != nil {
+			return fmt.Errorf("session restore key (%s): %w", src, err)
+		}
+	}
+
+	values := make(map[string]string, len(store))
+	for _, k := range []string{accessTokenField, refreshTokenField, emailField, issuedAtField} {
+		v := store[k]
+		if isEncrypted(v) {
+			if key == nil {
+				return fmt.Errorf("session restore: %s is encrypted but no key source is recorded", k)
+			}
+			plain, err := decryptValue(v, *key)
+			if err != nil {
+				return fmt.Errorf("session restore decrypt %s: %w", k, err)
+			}
+			if err := json.Unmarshal([]byte(plain), &v); err != nil {
+				return fmt.Errorf("session restore decode %s: %w", k, err)
+			}
+		}
+		values[k] = v
+	}
+	issuedAt, _ := time.Parse(time.RFC3339Nano, values[issuedAtField])
+
 	s.mu.Lock()
-	s.AccessToken = data.AccessToken
-	s.RefreshToken = data.RefreshToken
-	s.Email = data.Email
-	s.IssuedAt = data.IssuedAt
+	s.AccessToken = values[accessTokenField]
+	s.RefreshToken = values[refreshTokenField]
+	s.Email = values[emailField]
+	s.IssuedAt = issuedAt
+	s.keySource = src
 	s.mu.Unlock()
+
+	if src == "" && values[accessTokenField] != "" {
+		if err := s.Save(); err != nil {
+			return fmt.Errorf("session migrate: %w", err)
+		}
+		log.Printf("[session] Encrypted plaintext session file")
+	}
 	return nil
 }
 
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// The MCP session file is encrypted like the desktop store (ENC1: values,
+// see storage_crypto.go). The AES key lives outside the file: in the OS
+// secret store where one exists, otherwise derived from the hardware ID as
+// the desktop app does. The file records which source holds its key.
+
+// errNoSessionKey means the source works but holds no key (yet).
+var errNoSessionKey = errors.New("no session key stored")
+
+// sessionKeySource is one place the session key can live.
+type sessionKeySource struct {
+	name string
+	// key returns the stored key; with create it stores a new random key
+	// when there is none.
+	key func(create bool) (*[32]byte, error)
+}
+
+// hardwareKeySource derives the key from the machine ID with the desktop's
+// HKDF parameters. Always last: anyone on the host can read the machine ID.
+var hardwareKeySource = sessionKeySource{
+	name: "hwid",
+	key: func(bool) (*[32]byte, error) {
+		id, err := getHardwareID()
+		if err != nil {
+			return nil, err
+		}
+		key := deriveKey(id)
+		return &key, nil
+	},
+}
+
+// sessionKeySources in order of preference. Tests replace it.
+var sessionKeySources = append(platformSessionKeySources(), hardwareKeySource)
+
+// newSessionKey returns the key of the first source that can hold one.
+func newSessionKey() (string, *[32]byte, error) {
+	var errs []error
+	for _, src := range sessionKeySources {
+		key, err := src.key(true)
+		if err == nil {
+			return src.name, key, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", src.name, err))
+	}
+	return "", nil, fmt.Errorf("no session key source available: %w", errors.Join(errs...))
+}
+
+// sessionKeyFrom returns the existing key held by the named source.
+func sessionKeyFrom(name string) (*[32]byte, error) {
+	for _, src := range sessionKeySources {
+		if src.name == name {
+			return src.key(false)
+		}
+	}
+	return nil, fmt.Errorf("unknown session key source %q", name)
+}
+
+func randomSessionKey() (*[32]byte, error) {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, fmt.Errorf("generate session key: %w", err)
+	}
+	return &key, nil
+}
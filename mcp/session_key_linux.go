@@ -0,0 +1,95 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Secret Service attributes, via libsecret's secret-tool.
+	secretServiceName    = "io.kaitu.mcp"
+	secretServiceAccount = "session-key"
+	// Kernel user keyring description of keys stored by earlier versions.
+	keyringDescription = "kaitu-mcp:session-key"
+)
+
+// platformSessionKeySources prefers the desktop Secret Service (persistent,
+// unlocked with the login); without one, new keys fall through to the
+// hardware-ID key. The kernel user keyring is only read, for session files
+// an earlier version keyed there: its keys are lost on reboot, after which
+// the session is logged in again and re-keyed to a persistent source.
+func platformSessionKeySources() []sessionKeySource {
+	return []sessionKeySource{
+		{name: "secret-service", key: secretServiceKey},
+		{name: "keyring", key: kernelKeyringKey},
+	}
+}
+
+func secretServiceKey(create bool) (*[32]byte, error) {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil, errors.New("no D-Bus session bus")
+	}
+	tool, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, err
+	}
+	// A locked collection may prompt; never hang start-up on it.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, tool, "lookup", "service", secretServiceName, "account", secretServiceAccount).Output()
+	if err == nil {
+		raw, derr := base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+		if derr == nil && len(raw) == 32 {
+			var key [32]byte
+			copy(key[:], raw)
+			return &key, nil
+		}
+	}
+	if !create {
+		return nil, errNoSessionKey
+	}
+
+	key, err := randomSessionKey()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, tool, "store", "--label=Kaitu k2-mcp session key",
+		"service", secretServiceName, "account", secretServiceAccount)
+	cmd.Stdin = strings.NewReader(base64.StdEncoding.EncodeToString(key[:]))
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("secret-tool store: %w", err)
+	}
+	return key, nil
+}
+
+// errKeyringReadOnly keeps new keys out of the kernel keyring.
+var errKeyringReadOnly = errors.New("kernel keyring does not survive a reboot; not used for new keys")
+
+func kernelKeyringKey(create bool) (*[32]byte, error) {
+	if create {
+		return nil, errKeyringReadOnly
+	}
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, "user", keyringDescription, 0)
+	if err != nil {
+		return nil, errNoSessionKey
+	}
+	buf := make([]byte, 64)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+	if err != nil || n != 32 {
+		return nil, errNoSessionKey
+	}
+	var key [32]byte
+	copy(key[:], buf[:n])
+	return &key, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// platformSessionKeySources: macOS and Windows use the hardware-ID key, as
+// the desktop app does.
+func platformSessionKeySources() []sessionKeySource {
+	return nil
+}
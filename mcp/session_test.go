@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -9,6 +11,47 @@ import (
 	"time"
 )
 
+// TestMain keeps every test away from the real Secret Service and keyring.
+func TestMain(m *testing.M) {
+	sessionKeySources = []sessionKeySource{memoryKeySource("memory", true)}
+	os.Exit(m.Run())
+}
+
+// memoryKeySource holds one key in memory; available=false fails like an
+// absent keyring.
+func memoryKeySource(name string, available bool) sessionKeySource {
+	var stored *[32]byte
+	return sessionKeySource{name: name, key: func(create bool) (*[32]byte, error) {
+		if !available {
+			return nil, errors.New("unavailable")
+		}
+		if stored == nil {
+			if !create {
+				return nil, errNoSessionKey
+			}
+			key, err := randomSessionKey()
+			if err != nil {
+				return nil, err
+			}
+			stored = key
+		}
+		return stored, nil
+	}}
+}
+
+func readSessionFile(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, "mcp-session.json"))
+	if err != nil {
+		t.Fatalf("read session file: %v", err)
+	}
+	var store map[string]string
+	if err := json.Unmarshal(b, &store); err != nil {
+		t.Fatalf("session file is not a string map: %v", err)
+	}
+	return store
+}
+
 func TestSession_SaveAndRestore(t *testing.T) {
 	dir := t.TempDir()
 	s := NewSession(dir)
@@ -137,3 +180,84 @@ func TestSession_FilePermissions(t *testing.T) {
 		t.Errorf("expected file permissions 0600, got %o", info.Mode().Perm())
 	}
 }
+
+func TestSession_EncryptedAtRest(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSession(dir)
+	s.SetTokens("access-secret", "refresh-secret", "user@example.com", time.Now())
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	store := readSessionFile(t, dir)
+	if store[keySourceField] != "memory" {
+		t.Errorf("expected key source 'memory', got %q", store[keySourceField])
+	}
+	for _, k := range []string{accessTokenField, refreshTokenField, emailField, issuedAtField} {
+		if !isEncrypted(store[k]) {
+			t.Errorf("%s stored in clear: %q", k, store[k])
+		}
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, "mcp-session.json"))
+	if strings.Contains(string(raw), "secret") || strings.Contains(string(raw), "example.com") {
+		t.Error("session file leaks plaintext")
+	}
+}
+
+func TestSession_MigratesPlaintext(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{
+  "access_token": "old-access",
+  "refresh_token": "old-refresh",
+  "email": "old@example.com",
+  "issued_at": "2026-01-02T03:04:05Z"
+}`
+	if err := os.WriteFile(filepath.Join(dir, "mcp-session.json"), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSession(dir)
+	if err := s.Restore(); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if s.AccessToken != "old-access" || s.RefreshToken != "old-refresh" || s.Email != "old@example.com" {
+		t.Errorf("legacy session not loaded: %+v", s)
+	}
+	if !s.IssuedAt.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected IssuedAt %v", s.IssuedAt)
+	}
+
+	store := readSessionFile(t, dir)
+	if store[keySourceField] == "" || !isEncrypted(store[refreshTokenField]) {
+		t.Fatalf("plaintext session not migrated: %v", store)
+	}
+	s2 := NewSession(dir)
+	if err := s2.Restore(); err != nil || s2.RefreshToken != "old-refresh" {
+		t.Errorf("migrated session unreadable: %v, %q", err, s2.RefreshToken)
+	}
+}
+
+func TestSession_KeySourcePreference(t *testing.T) {
+	saved := sessionKeySources
+	t.Cleanup(func() { sessionKeySources = saved })
+	sessionKeySources = []sessionKeySource{
+		memoryKeySource("keyring", false),
+		memoryKeySource("fallback", true),
+	}
+
+	dir := t.TempDir()
+	s := NewSession(dir)
+	s.SetTokens("tok", "ref", "e@e.com", time.Now())
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if got := readSessionFile(t, dir)[keySourceField]; got != "fallback" {
+		t.Errorf("expected first available source 'fallback', got %q", got)
+	}
+
+	// The recorded source no longer has the key (e.g. keyring after reboot).
+	sessionKeySources = []sessionKeySource{memoryKeySource("fallback", true)}
+	if err := NewSession(dir).Restore(); err == nil {
+		t.Error("expected restore to fail without the key")
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
@@ -66,6 +67,28 @@ func decryptValue(encrypted string, key [32]byte) (string, error) {
 	return string(plaintext), nil
 }
 
+// encryptValue is the inverse of decryptValue: AES-256-GCM with a random
+// nonce, stored as ENC1: + base64(nonce(12) || ciphertext+tag).
+func encryptValue(plaintext string, key [32]byte) (string, error) {
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return "", fmt.Errorf("aes.NewCipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("cipher.NewGCM: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
 // isEncrypted checks whether a value has the ENC1: prefix.
 func isEncrypted(value string) bool {
 	return strings.HasPrefix(value, encPrefix)
@@ -118,3 +118,25 @@ func TestHardwareIDFormat(t *testing.T) {
 		}
 	}
 }
+
+func TestEncryptValueRoundTrip(t *testing.T) {
+	key := deriveKey("test-hardware-id")
+	enc, err := encryptValue(`"hello"`, key)
+	if err != nil {
+		t.Fatalf("encryptValue: %v", err)
+	}
+	if !isEncrypted(enc) {
+		t.Fatalf("missing %s prefix: %q", encPrefix, enc)
+	}
+	again, _ := encryptValue(`"hello"`, key)
+	if again == enc {
+		t.Error("nonce must be random")
+	}
+	got, err := decryptValue(enc, key)
+	if err != nil || got != `"hello"` {
+		t.Errorf("decryptValue = %q, %v", got, err)
+	}
+	if _, err := decryptValue(enc, deriveKey("other")); err == nil {
+		t.Error("expected failure with the wrong key")
+	}
+}
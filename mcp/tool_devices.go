@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// deviceEntry is one item of GET /api/user/devices.
+type deviceEntry struct {
+	UDID            string `json:"udid"`
+	Remark          string `json:"remark"`
+	TokenLastUsedAt int64  `json:"tokenLastUsedAt"`
+}
+
+// deviceListResponse is the data field returned by GET /api/user/devices.
+type deviceListResponse struct {
+	Items []deviceEntry `json:"items"`
+}
+
+// deviceOutput is one device as returned to the MCP client.
+type deviceOutput struct {
+	UDID       string `json:"udid"`
+	Remark     string `json:"remark,omitempty"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+	Current    bool   `json:"current"`
+}
+
+// toolListDevices implements the list_devices MCP tool.
+func (app *App) toolListDevices(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+
+	var resp deviceListResponse
+	if err := app.center.Get("/api/user/devices", &resp); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+
+	current := app.center.UDID()
+	out := make([]deviceOutput, 0, len(resp.Items))
+	for _, d := range resp.Items {
+		row := deviceOutput{UDID: d.UDID, Remark: d.Remark, Current: d.UDID == current}
+		if d.TokenLastUsedAt > 0 {
+			row.LastUsedAt = time.Unix(d.TokenLastUsedAt, 0).UTC().Format(time.RFC3339)
+		}
+		out = append(out, row)
+	}
+	return successResult(map[string]any{"devices": out}), nil, nil
+}
+
+// RemoveDeviceInput is the input schema for the remove_device tool.
+type RemoveDeviceInput struct {
+	UDID string `json:"udid" description:"UDID of the device to remove, from list_devices"`
+}
+
+// toolRemoveDevice implements the remove_device MCP tool. Removing a device
+// signs it out and frees its slot; the current device cannot be removed.
+func (app *App) toolRemoveDevice(ctx context.Context, req *mcp.CallToolRequest, in RemoveDeviceInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+	if in.UDID == "" {
+		return errorResult("udid is required, call list_devices to find it"), nil, nil
+	}
+	if in.UDID == app.center.UDID() {
+		return errorResult("cannot remove the current device"), nil, nil
+	}
+
+	if err := app.center.Delete("/api/user/devices/"+url.PathEscape(in.UDID), nil); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+	return successResult(map[string]any{"removed": in.UDID}), nil, nil
+}
+
+// RenameDeviceInput is the input schema for the rename_device tool.
+type RenameDeviceInput struct {
+	UDID   string `json:"udid"   description:"UDID of the device, from list_devices"`
+	Remark string `json:"remark" description:"New device name; empty clears it"`
+}
+
+// toolRenameDevice implements the rename_device MCP tool.
+func (app *App) toolRenameDevice(ctx context.Context, req *mcp.CallToolRequest, in RenameDeviceInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+	if in.UDID == "" {
+		return errorResult("udid is required, call list_devices to find it"), nil, nil
+	}
+
+	body := map[string]string{"remark": in.Remark}
+	if err := app.center.Put("/api/user/devices/"+url.PathEscape(in.UDID)+"/remark", body, nil); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+	return successResult(map[string]any{"udid": in.UDID, "remark": in.Remark}), nil, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// centerCall is one request seen by fakeCenter.
+type centerCall struct {
+	Method string
+	Path   string
+	Query  string
+	Body   map[string]any
+}
+
+// fakeCenter answers "METHOD /path" with canned data (or a *CenterError)
+// and records every call. Unknown routes answer 404.
+type fakeCenter struct {
+	mu     sync.Mutex
+	routes map[string]any
+	calls  []centerCall
+}
+
+func (f *fakeCenter) serve(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := centerCall{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery}
+		json.NewDecoder(r.Body).Decode(&call.Body)
+
+		f.mu.Lock()
+		f.calls = append(f.calls, call)
+		v, ok := f.routes[r.Method+" "+r.URL.Path]
+		f.mu.Unlock()
+
+		resp := centerResponse{Code: 0, Message: "ok"}
+		switch v := v.(type) {
+		case *CenterError:
+			resp.Code, resp.Message = v.Code, v.Message
+		case nil:
+			if !ok {
+				resp.Code, resp.Message = 404, "not found"
+			}
+		default:
+			data, _ := json.Marshal(v)
+			resp.Data = json.RawMessage(data)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// newFakeCenterApp returns a logged-in app talking to f.
+func newFakeCenterApp(t *testing.T, f *fakeCenter) *App {
+	t.Helper()
+	app := newTestApp(t, f.serve(t))
+	app.session.SetTokens("tok", "ref", "user@example.com", time.Now())
+	app.center.SetToken("tok")
+	return app
+}
+
+func TestToolListDevices_MarksCurrent(t *testing.T) {
+	f := &fakeCenter{routes: map[string]any{
+		"GET /api/user/devices": deviceListResponse{Items: []deviceEntry{
+			{UDID: "this-one", Remark: "laptop", TokenLastUsedAt: 1767225600},
+			{UDID: "old-phone"},
+		}},
+	}}
+	app := newFakeCenterApp(t, f)
+	app.center.SetUDID("this-one")
+
+	result, _, _ := app.toolListDevices(context.Background(), nil, nil)
+	if result.IsError {
+		t.Fatalf("expected success, got error: %s", textContent(t, result))
+	}
+	var out struct {
+		Devices []deviceOutput `json:"devices"`
+	}
+	if err := json.Unmarshal([]byte(textContent(t, result)), &out); err != nil {
+		t.Fatalf("unmarshal result: %v", err)
+	}
+	if len(out.Devices) != 2 {
+		t.Fatalf("expected 2 devices, got %d", len(out.Devices))
+	}
+	if !out.Devices[0].Current || out.Devices[0].LastUsedAt != "2026-01-01T00:00:00Z" {
+		t.Errorf("unexpected first device: %+v", out.Devices[0])
+	}
+	if out.Devices[1].Current || out.Devices[1].LastUsedAt != "" {
+		t.Errorf("unexpected second device: %+v", out.Devices[1])
+	}
+}
+
+func TestToolRemoveDevice(t *testing.T) {
+	f := &fakeCenter{routes: map[string]any{
+		"DELETE /api/user/devices/old-phone": nil,
+	}}
+	app := newFakeCenterApp(t, f)
+	app.center.SetUDID("this-one")
+	ctx := context.Background()
+
+	result, _, _ := app.toolRemoveDevice(ctx, nil, RemoveDeviceInput{UDID: "this-one"})
+	if !result.IsError || !strings.Contains(textContent(t, result), "current device") {
+		t.Errorf("expected current-device refusal, got %s", textContent(t, result))
+	}
+	if len(f.calls) != 0 {
+		t.Fatal("must not call Center for the current device")
+	}
+
+	result, _, _ = app.toolRemoveDevice(ctx, nil, RemoveDeviceInput{UDID: "old-phone"})
+	if result.IsError {
+		t.Fatalf("expected success, got error: %s", textContent(t, result))
+	}
+
+	result, _, _ = app.toolRemoveDevice(ctx, nil, RemoveDeviceInput{UDID: "gone"})
+	if !result.IsError {
+		t.Error("expected Center error for an unknown device")
+	}
+}
+
+func TestToolRenameDevice(t *testing.T) {
+	f := &fakeCenter{routes: map[string]any{
+		"PUT /api/user/devices/old-phone/remark": nil,
+	}}
+	app := newFakeCenterApp(t, f)
+
+	result, _, _ := app.toolRenameDevice(context.Background(), nil, RenameDeviceInput{UDID: "old-phone", Remark: "Mum's phone"})
+	if result.IsError {
+		t.Fatalf("expected success, got error: %s", textContent(t, result))
+	}
+	if len(f.calls) != 1 || f.calls[0].Body["remark"] != "Mum's phone" {
+		t.Errorf("unexpected Center calls: %+v", f.calls)
+	}
+}
+
+func TestDeviceTools_NotLoggedIn(t *testing.T) {
+	app := newTestApp(t, "http://127.0.0.1:0")
+	result, _, _ := app.toolListDevices(context.Background(), nil, nil)
+	if !result.IsError || !strings.Contains(textContent(t, result), "not logged in") {
+		t.Errorf("expected not-logged-in error, got %s", textContent(t, result))
+	}
+}
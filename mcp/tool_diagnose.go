@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Diagnostic check outcomes.
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// dnsTimeout bounds the server domain lookup.
+const dnsTimeout = 3 * time.Second
+
+// logBucketURL is the public S3 bucket the desktop app uploads logs to
+// (desktop/src-tauri/src/log_upload.rs). Variable for tests.
+var logBucketURL = "https://kaitu-service-logs.s3.ap-northeast-1.amazonaws.com"
+
+// lookupIP resolves a host name. Variable for tests.
+var lookupIP = net.DefaultResolver.LookupIPAddr
+
+// diagCheck is one line of the diagnose checklist.
+type diagCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Data   any    `json:"data,omitempty"`
+}
+
+// diagReport is the full diagnose output, uploaded as-is when requested.
+type diagReport struct {
+	GeneratedAt  string             `json:"generated_at"`
+	OS           string             `json:"os"`
+	Version      string             `json:"version"`
+	Summary      string             `json:"summary"`
+	Checks       []diagCheck        `json:"checks"`
+	DaemonErrors []daemonErrorEntry `json:"daemon_errors"`
+	Upload       *diagUpload        `json:"upload,omitempty"`
+}
+
+// diagUpload records where an uploaded report went.
+type diagUpload struct {
+	FeedbackID string `json:"feedback_id,omitempty"`
+	S3Key      string `json:"s3_key,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DiagnoseInput is the input schema for the diagnose tool.
+type DiagnoseInput struct {
+	ServerID int    `json:"server_id,omitempty" description:"Server to check; defaults to the one the daemon is using"`
+	Carrier  string `json:"carrier,omitempty"   description:"Chinese carrier for the outbound route check: china_telecom, china_unicom, china_mobile or china_education"`
+	Province string `json:"province,omitempty"  description:"Province in pinyin for the outbound route check, e.g. guangdong"`
+	Upload   bool   `json:"upload,omitempty"    description:"Upload the report for Kaitu support and return its feedback ID"`
+}
+
+// diagnoseState carries facts between checks.
+type diagnoseState struct {
+	status   *DaemonStatus
+	loggedIn bool
+	server   *Server
+	host     string
+	ips      []string
+}
+
+// toolDiagnose implements the diagnose MCP tool: run the connection
+// checklist and return one report. Checks never abort the run; each one
+// records its own outcome.
+func (app *App) toolDiagnose(ctx context.Context, req *mcp.CallToolRequest, in DiagnoseInput) (*mcp.CallToolResult, any, error) {
+	st := &diagnoseState{}
+	checks := []diagCheck{
+		app.checkDaemon(st),
+		app.checkToken(st),
+		app.checkServer(st, in.ServerID),
+		checkDNS(ctx, st),
+		app.checkOutboundRoute(st, in.Carrier, in.Province),
+	}
+
+	report := diagReport{
+		GeneratedAt:  time.Now().UTC().Format(time.RFC3339),
+		OS:           runtime.GOOS + "/" + runtime.GOARCH,
+		Version:      serverVersion,
+		Summary:      diagSummary(checks),
+		Checks:       checks,
+		DaemonErrors: app.daemonErrors.list(),
+	}
+	if in.Upload {
+		report.Upload = app.uploadReport(ctx, &report)
+	}
+	return successResult(report), nil, nil
+}
+
+// diagSummary names the first failing check, or the first warning.
+func diagSummary(checks []diagCheck) string {
+	for _, want := range []string{checkFail, checkWarn} {
+		for _, c := range checks {
+			if c.Status == want {
+				return c.Name + ": " + c.Detail
+			}
+		}
+	}
+	return "all checks passed"
+}
+
+// checkDaemon pings the daemon and records its state.
+func (app *App) checkDaemon(st *diagnoseState) diagCheck {
+	c := diagCheck{Name: "daemon"}
+	if err := app.daemon.Ping(); err != nil {
+		c.Status = checkFail
+		c.Detail = "k2 daemon is not reachable at " + app.daemon.Addr + ": " + err.Error()
+		return c
+	}
+	status, err := app.daemon.Status()
+	if err != nil {
+		c.Status = checkWarn
+		c.Detail = "daemon answers ping but status failed: " + err.Error()
+		return c
+	}
+	st.status = status
+	data := map[string]any{"state": status.State}
+	c.Status = checkOK
+	c.Detail = "daemon running, state " + status.State
+	if status.Error != nil {
+		app.daemonErrors.record(status.Error.Code, status.Error.Message, "status")
+		data["error_code"] = status.Error.Code
+		data["error"] = status.Error.Message
+		c.Status = checkWarn
+		c.Detail = fmt.Sprintf("daemon reports error %d: %s", status.Error.Code, status.Error.Message)
+	}
+	c.Data = data
+	return c
+}
+
+// checkToken verifies the access token against Center.
+func (app *App) checkToken(st *diagnoseState) diagCheck {
+	c := diagCheck{Name: "token"}
+	if !app.session.LoggedIn() {
+		c.Status = checkFail
+		c.Detail = "not logged in, call login first"
+		return c
+	}
+	var user userResponse
+	err := app.center.Get("/api/user", &user)
+	var ce *CenterError
+	switch {
+	case err == nil:
+		st.loggedIn = true
+		c.Status = checkOK
+		c.Detail = "access token accepted by Center"
+		if user.ExpiredAt > 0 && user.ExpiredAt <= time.Now().Unix() {
+			c.Status = checkWarn
+			c.Detail = "subscription expired at " + formatUnix(user.ExpiredAt)
+		}
+	case errors.As(err, &ce) && ce.Code == 401:
+		c.Status = checkFail
+		c.Detail = "access token rejected and refresh failed, call login again"
+	case errors.As(err, &ce):
+		st.loggedIn = true
+		c.Status = checkWarn
+		c.Detail = fmt.Sprintf("Center error %d: %s", ce.Code, ce.Message)
+	default:
+		c.Status = checkFail
+		c.Detail = "Center unreachable: " + err.Error()
+	}
+	return c
+}
+
+// checkServer finds the chosen server (by ID, else the daemon's current
+// outbound) in the current tunnel list.
+func (app *App) checkServer(st *diagnoseState, serverID int) diagCheck {
+	c := diagCheck{Name: "server"}
+	var serverURL string
+	if st.status != nil && st.status.Config != nil {
+		serverURL = st.status.Config.Server()
+	}
+	if serverID == 0 && serverURL != "" {
+		if u, err := url.Parse(serverURL); err == nil {
+			st.host = u.Hostname()
+		}
+	}
+	if serverID == 0 && st.host == "" {
+		c.Status = checkSkip
+		c.Detail = "no server chosen: pass server_id or connect first"
+		return c
+	}
+	if !st.loggedIn {
+		c.Status = checkSkip
+		c.Detail = "needs a valid login to fetch the tunnel list"
+		return c
+	}
+
+	servers, err := app.fetchServers()
+	if err != nil {
+		c.Status = checkFail
+		c.Detail = "fetch tunnel list: " + err.Error()
+		return c
+	}
+	for i := range servers {
+		s := &servers[i]
+		if (serverID != 0 && s.ID == serverID) || (serverID == 0 && strings.EqualFold(s.Domain, st.host)) {
+			st.server = s
+			st.host = s.Domain
+			break
+		}
+	}
+	if st.server == nil {
+		c.Status = checkFail
+		if serverID != 0 {
+			c.Detail = fmt.Sprintf("server %d is not in the current tunnel list, call list_servers", serverID)
+		} else {
+			c.Detail = st.host + " is no longer in the tunnel list; reconnect to a listed server"
+		}
+		return c
+	}
+	c.Status = checkOK
+	c.Detail = fmt.Sprintf("%s (%d) is in the tunnel list", st.server.Name, st.server.ID)
+	c.Data = map[string]any{"id": st.server.ID, "name": st.server.Name, "domain": st.server.Domain, "country": st.server.Country}
+	return c
+}
+
+// checkDNS resolves the server domain.
+func checkDNS(ctx context.Context, st *diagnoseState) diagCheck {
+	c := diagCheck{Name: "dns"}
+	if st.host == "" {
+		c.Status = checkSkip
+		c.Detail = "no server domain to resolve"
+		return c
+	}
+	if ip := net.ParseIP(st.host); ip != nil {
+		st.ips = []string{ip.String()}
+		c.Status = checkOK
+		c.Detail = "server address is an IP literal"
+		return c
+	}
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+	addrs, err := lookupIP(ctx, st.host)
+	if err != nil {
+		c.Status = checkFail
+		c.Detail = "cannot resolve " + st.host + ": " + err.Error()
+		return c
+	}
+	for _, a := range addrs {
+		st.ips = append(st.ips, a.IP.String())
+	}
+	c.Status = checkOK
+	c.Detail = st.host + " resolves to " + strings.Join(st.ips, ", ")
+	c.Data = map[string]any{"ips": st.ips}
+	return c
+}
+
+// outboundRouteResponse is the data field of GET /api/diagnosis/outbound-route.
+type outboundRouteResponse struct {
+	Carrier     string  `json:"carrier"`
+	Province    string  `json:"province"`
+	RouteType   string  `json:"routeType"`
+	DiagnosedAt *string `json:"diagnosedAt"`
+}
+
+// checkOutboundRoute asks Center which backbone (CN2 GIA, CMI, 163, ...) the
+// server's return path to the user's carrier takes.
+func (app *App) checkOutboundRoute(st *diagnoseState, carrier, province string) diagCheck {
+	c := diagCheck{Name: "outbound_route"}
+	if carrier == "" || province == "" {
+		c.Status = checkSkip
+		c.Detail = "pass carrier and province to check the server's route to your network"
+		return c
+	}
+	var ipv4 string
+	for _, ip := range st.ips {
+		if p := net.ParseIP(ip); p != nil && p.To4() != nil {
+			ipv4 = ip
+			break
+		}
+	}
+	if ipv4 == "" || !st.loggedIn {
+		c.Status = checkSkip
+		c.Detail = "needs a resolved IPv4 server address and a valid login"
+		return c
+	}
+
+	q := url.Values{"ip": {ipv4}, "carrier": {carrier}, "province": {province}}
+	var resp outboundRouteResponse
+	err := app.center.Get("/api/diagnosis/outbound-route?"+q.Encode(), &resp)
+	var ce *CenterError
+	switch {
+	case err == nil:
+		c.Status = checkOK
+		c.Detail = fmt.Sprintf("%s → %s/%s via %s", ipv4, carrier, province, resp.RouteType)
+		if resp.RouteType == "unknown" {
+			c.Status = checkWarn
+		}
+		c.Data = resp
+	case errors.As(err, &ce) && ce.Code == 425:
+		c.Status = checkSkip
+		c.Detail = "route detection started for " + ipv4 + ", run diagnose again in a few minutes"
+	default:
+		c.Status = checkWarn
+		c.Detail = "outbound route lookup failed: " + err.Error()
+	}
+	return c
+}
+
+// deviceLogRequest is the body of POST /api/user/device-log.
+type deviceLogRequest struct {
+	UDID       string           `json:"udid"`
+	FeedbackID string           `json:"feedbackId"`
+	S3Keys     []deviceLogS3Key `json:"s3Keys"`
+	Reason     string           `json:"reason"`
+	Meta       map[string]any   `json:"meta"`
+}
+
+type deviceLogS3Key struct {
+	Name  string `json:"name"`
+	S3Key string `json:"s3Key"`
+}
+
+// uploadReport puts the report in the log bucket and registers it with
+// Center, mirroring the desktop log upload. Failures are reported, not fatal.
+func (app *App) uploadReport(ctx context.Context, report *diagReport) *diagUpload {
+	feedbackID, err := newFeedbackID()
+	if err != nil {
+		return &diagUpload{Error: err.Error()}
+	}
+	udid := app.center.UDID()
+	now := time.Now().UTC()
+	key := fmt.Sprintf("mcp/%s/%s/%s/diagnose-%s-%s.json",
+		serverVersion, udid, now.Format("2006/01/02"), now.Format("150405"), feedbackID[:8])
+
+	body, _ := json.MarshalIndent(report, "", "  ")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, logBucketURL+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return &diagUpload{Error: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return &diagUpload{Error: "upload: " + err.Error()}
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return &diagUpload{Error: fmt.Sprintf("upload: HTTP %d", resp.StatusCode)}
+	}
+
+	up := &diagUpload{FeedbackID: feedbackID, S3Key: key}
+	err = app.center.Post("/api/user/device-log", deviceLogRequest{
+		UDID:       udid,
+		FeedbackID: feedbackID,
+		S3Keys:     []deviceLogS3Key{{Name: "diagnose", S3Key: key}},
+		Reason:     "mcp_diagnose",
+		Meta:       map[string]any{"os": runtime.GOOS, "appVersion": serverVersion, "summary": report.Summary},
+	}, nil)
+	if err != nil {
+		up.Error = "uploaded but not registered with Center: " + err.Error()
+	}
+	return up
+}
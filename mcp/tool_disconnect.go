@@ -8,6 +8,8 @@ import (
 
 // toolDisconnect implements the disconnect MCP tool.
 func (app *App) toolDisconnect(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+	// A deliberate disconnect leaves the watchdog nothing to restore.
+	app.watchdog.setTarget(nil)
 	if err := app.daemon.Down(); err != nil {
 		// If daemon is unreachable, treat as already disconnected.
 		return successResult(map[string]string{"status": "disconnected"}), nil, nil
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// inviteCodeEntry is one invite code as returned by /api/invite/my-codes.
+type inviteCodeEntry struct {
+	Code           string `json:"code"`
+	CreatedAt      int64  `json:"createdAt"`
+	Remark         string `json:"remark"`
+	Link           string `json:"link"`
+	RegisterCount  int64  `json:"registerCount"`
+	PurchaseCount  int64  `json:"purchaseCount"`
+	PurchaseReward int64  `json:"purchaseReward"`
+}
+
+// inviteListResponse is the data field returned by GET /api/invite/my-codes.
+type inviteListResponse struct {
+	Items []inviteCodeEntry `json:"items"`
+}
+
+// shareLinkResponse is the data field returned by
+// GET /api/invite/my-codes/:code/share-link.
+type shareLinkResponse struct {
+	ShareLink string `json:"shareLink"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// inviteOutput is one invite code as returned to the MCP client.
+type inviteOutput struct {
+	Code              string `json:"code"`
+	Remark            string `json:"remark,omitempty"`
+	Link              string `json:"link"`
+	Registered        int64  `json:"registered"`
+	Purchased         int64  `json:"purchased"`
+	RewardDays        int64  `json:"reward_days"`
+	CreatedAt         string `json:"created_at"`
+	ShareLink         string `json:"share_link,omitempty"`
+	ShareLinkExpireAt string `json:"share_link_expires_at,omitempty"`
+}
+
+func newInviteOutput(c inviteCodeEntry) inviteOutput {
+	return inviteOutput{
+		Code:       c.Code,
+		Remark:     c.Remark,
+		Link:       c.Link,
+		Registered: c.RegisterCount,
+		Purchased:  c.PurchaseCount,
+		RewardDays: c.PurchaseReward,
+		CreatedAt:  formatUnix(c.CreatedAt),
+	}
+}
+
+// ListInvitesInput is the input schema for the list_invites tool.
+type ListInvitesInput struct {
+	Page     int `json:"page,omitempty"      description:"Page number, starting at 1"`
+	PageSize int `json:"page_size,omitempty" description:"Codes per page (default 10, max 100)"`
+}
+
+// toolListInvites implements the list_invites MCP tool.
+func (app *App) toolListInvites(ctx context.Context, req *mcp.CallToolRequest, in ListInvitesInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+
+	path := "/api/invite/my-codes"
+	if in.Page > 0 || in.PageSize > 0 {
+		path += fmt.Sprintf("?page=%d&pageSize=%d", in.Page, in.PageSize)
+	}
+	var resp inviteListResponse
+	if err := app.center.Get(path, &resp); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+
+	out := make([]inviteOutput, 0, len(resp.Items))
+	for _, c := range resp.Items {
+		out = append(out, newInviteOutput(c))
+	}
+	return successResult(map[string]any{"invites": out}), nil, nil
+}
+
+// CreateInviteInput is the input schema for the create_invite tool.
+type CreateInviteInput struct {
+	Remark        string `json:"remark,omitempty"          description:"Optional note to remember who the invite is for"`
+	ExpiresInDays int    `json:"expires_in_days,omitempty" description:"Validity of the short share link in days (1-365, default 7)"`
+}
+
+// toolCreateInvite implements the create_invite MCP tool: mint a new invite
+// code, label it, and fetch a short share link for it. The remark and short
+// link are best-effort; the invite link itself never expires.
+func (app *App) toolCreateInvite(ctx context.Context, req *mcp.CallToolRequest, in CreateInviteInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+	if in.ExpiresInDays < 0 || in.ExpiresInDays > 365 {
+		return errorResult("expires_in_days must be between 1 and 365"), nil, nil
+	}
+
+	var code inviteCodeEntry
+	if err := app.center.Post("/api/invite/my-codes", struct{}{}, &code); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+	escaped := url.PathEscape(code.Code)
+
+	if in.Remark != "" {
+		body := map[string]string{"remark": in.Remark}
+		if err := app.center.Put("/api/invite/my-codes/"+escaped+"/remark", body, nil); err == nil {
+			code.Remark = in.Remark
+		}
+	}
+
+	out := newInviteOutput(code)
+	path := "/api/invite/my-codes/" + escaped + "/share-link"
+	if in.ExpiresInDays > 0 {
+		path += fmt.Sprintf("?expiresInDays=%d", in.ExpiresInDays)
+	}
+	var share shareLinkResponse
+	if err := app.center.Get(path, &share); err == nil && share.ShareLink != "" {
+		out.ShareLink = share.ShareLink
+		out.ShareLinkExpireAt = formatUnix(share.ExpiresAt)
+	}
+	return successResult(out), nil, nil
+}
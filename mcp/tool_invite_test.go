@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestToolCreateInvite(t *testing.T) {
+	f := &fakeCenter{routes: map[string]any{
+		"POST /api/invite/my-codes":                  inviteCodeEntry{Code: "ABC123", CreatedAt: 1767225600, Remark: "邀请码", Link: "https://www.kaitu.io/s/ABC123"},
+		"PUT /api/invite/my-codes/ABC123/remark":     nil,
+		"GET /api/invite/my-codes/ABC123/share-link": shareLinkResponse{ShareLink: "https://s.kaitu.io/xyz", ExpiresAt: 1767830400},
+	}}
+	app := newFakeCenterApp(t, f)
+
+	result, _, _ := app.toolCreateInvite(context.Background(), nil, CreateInviteInput{Remark: "for Sam", ExpiresInDays: 7})
+	if result.IsError {
+		t.Fatalf("expected success, got error: %s", textContent(t, result))
+	}
+	var out inviteOutput
+	json.Unmarshal([]byte(textContent(t, result)), &out)
+	want := inviteOutput{
+		Code:              "ABC123",
+		Remark:            "for Sam",
+		Link:              "https://www.kaitu.io/s/ABC123",
+		CreatedAt:         "2026-01-01T00:00:00Z",
+		ShareLink:         "https://s.kaitu.io/xyz",
+		ShareLinkExpireAt: "2026-01-08T00:00:00Z",
+	}
+	if out != want {
+		t.Errorf("got %+v, want %+v", out, want)
+	}
+	if len(f.calls) != 3 || f.calls[2].Query != "expiresInDays=7" {
+		t.Errorf("unexpected Center calls: %+v", f.calls)
+	}
+}
+
+func TestToolCreateInvite_ShareLinkBestEffort(t *testing.T) {
+	f := &fakeCenter{routes: map[string]any{
+		"POST /api/invite/my-codes":                  inviteCodeEntry{Code: "ABC123", Link: "https://www.kaitu.io/s/ABC123"},
+		"GET /api/invite/my-codes/ABC123/share-link": &CenterError{Code: 500, Message: "failed to generate share link"},
+	}}
+	app := newFakeCenterApp(t, f)
+
+	result, _, _ := app.toolCreateInvite(context.Background(), nil, CreateInviteInput{})
+	if result.IsError {
+		t.Fatalf("short-link failure must not fail the invite: %s", textContent(t, result))
+	}
+	var out inviteOutput
+	json.Unmarshal([]byte(textContent(t, result)), &out)
+	if out.Link == "" || out.ShareLink != "" {
+		t.Errorf("expected plain link only, got %+v", out)
+	}
+
+	result, _, _ = app.toolCreateInvite(context.Background(), nil, CreateInviteInput{ExpiresInDays: 400})
+	if !result.IsError {
+		t.Error("expected error for expires_in_days out of range")
+	}
+}
+
+func TestToolListInvites(t *testing.T) {
+	f := &fakeCenter{routes: map[string]any{
+		"GET /api/invite/my-codes": inviteListResponse{Items: []inviteCodeEntry{
+			{Code: "ABC123", RegisterCount: 3, PurchaseCount: 1, PurchaseReward: 30},
+		}},
+	}}
+	app := newFakeCenterApp(t, f)
+
+	result, _, _ := app.toolListInvites(context.Background(), nil, ListInvitesInput{})
+	if result.IsError {
+		t.Fatalf("expected success, got error: %s", textContent(t, result))
+	}
+	var out struct {
+		Invites []inviteOutput `json:"invites"`
+	}
+	json.Unmarshal([]byte(textContent(t, result)), &out)
+	if len(out.Invites) != 1 || out.Invites[0].Registered != 3 || out.Invites[0].RewardDays != 30 {
+		t.Errorf("unexpected invites: %+v", out.Invites)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Headless logins for CI runners and team machines, where nobody reads the
+// verification email: an admin-issued access key, or a device code approved
+// from the Kaitu app by an already logged-in user.
+
+// accessKeyEnv supplies an access key for login_access_key and start-up login.
+const accessKeyEnv = "KAITU_ACCESS_KEY"
+
+// Center response codes of the device-code poll.
+const (
+	codePendingApproval = 202
+	codeCodeExpired     = 400013
+)
+
+// devicePollUnit scales the server's poll interval (seconds); tests shrink it.
+var devicePollUnit = time.Second
+
+const (
+	defaultDeviceWait = 60 * time.Second
+	maxDeviceWait     = 5 * time.Minute
+)
+
+// deviceCodeResponse is the data field returned by POST /api/auth/device-code.
+type deviceCodeResponse struct {
+	DeviceCode string `json:"deviceCode"`
+	UserCode   string `json:"userCode"`
+	ExpiresIn  int    `json:"expiresIn"`
+	Interval   int    `json:"interval"`
+}
+
+// pendingDeviceLogin is the device code awaiting approval. The device code
+// itself is a secret and never leaves the process.
+type pendingDeviceLogin struct {
+	deviceCode string
+	userCode   string
+	interval   time.Duration
+	expiresAt  time.Time
+}
+
+// deviceLogin holds at most one pending device login.
+type deviceLogin struct {
+	mu      sync.Mutex
+	pending *pendingDeviceLogin
+}
+
+func (d *deviceLogin) get() *pendingDeviceLogin {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pending != nil && time.Now().After(d.pending.expiresAt) {
+		d.pending = nil
+	}
+	return d.pending
+}
+
+func (d *deviceLogin) set(p *pendingDeviceLogin) {
+	d.mu.Lock()
+	d.pending = p
+	d.mu.Unlock()
+}
+
+// clear drops p unless a newer device_login has replaced it.
+func (d *deviceLogin) clear(p *pendingDeviceLogin) {
+	d.mu.Lock()
+	if d.pending == p {
+		d.pending = nil
+	}
+	d.mu.Unlock()
+}
+
+// completeLogin stores tokens from any login endpoint. Headless logins do
+// not know the account email, so it is looked up best-effort.
+func (app *App) completeLogin(resp loginResponse) string {
+	app.center.SetToken(resp.AccessToken)
+
+	email := ""
+	var user userResponse
+	if err := app.center.Get("/api/user", &user); err == nil {
+		for _, ident := range user.LoginIdentifies {
+			if ident.Type == "email" {
+				email = ident.Value
+				break
+			}
+		}
+	}
+
+	app.session.SetTokens(resp.AccessToken, resp.RefreshToken, email, time.Unix(resp.IssuedAt, 0))
+	app.session.Save() //nolint:errcheck // non-fatal: tokens are in-memory
+	return email
+}
+
+// loginWithAccessKey exchanges an access key for device tokens.
+func (app *App) loginWithAccessKey(key string) (string, error) {
+	body := map[string]any{
+		"udid":   app.session.UDID(),
+		"remark": "k2-mcp",
+	}
+	var resp loginResponse
+	if err := app.center.PostWithHeader("/api/auth/access-key", http.Header{"X-Access-Key": {key}}, body, &resp); err != nil {
+		return "", err
+	}
+	return app.completeLogin(resp), nil
+}
+
+// LoginAccessKeyInput is the input schema for the login_access_key tool.
+type LoginAccessKeyInput struct {
+	AccessKey string `json:"access_key,omitempty" description:"Access key (ktu_...). Defaults to the KAITU_ACCESS_KEY environment variable"`
+}
+
+// toolLoginAccessKey implements the login_access_key MCP tool.
+func (app *App) toolLoginAccessKey(ctx context.Context, req *mcp.CallToolRequest, in LoginAccessKeyInput) (*mcp.CallToolResult, any, error) {
+	key := in.AccessKey
+	if key == "" {
+		key = os.Getenv(accessKeyEnv)
+	}
+	if key == "" {
+		return errorResult("access_key is required (or set " + accessKeyEnv + ")"), nil, nil
+	}
+
+	email, err := app.loginWithAccessKey(key)
+	if err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+	return successResult(map[string]string{"email": email}), nil, nil
+}
+
+// toolDeviceLogin implements the device_login MCP tool.
+func (app *App) toolDeviceLogin(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+	body := map[string]any{
+		"udid":   app.session.UDID(),
+		"remark": "k2-mcp",
+	}
+	var resp deviceCodeResponse
+	if err := app.center.Post("/api/auth/device-code", body, &resp); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+
+	p := &pendingDeviceLogin{
+		deviceCode: resp.DeviceCode,
+		userCode:   resp.UserCode,
+		interval:   time.Duration(resp.Interval) * devicePollUnit,
+		expiresAt:  time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+	}
+	app.deviceLogin.set(p)
+
+	return successResult(map[string]any{
+		"user_code":  p.userCode,
+		"expires_at": p.expiresAt.UTC().Format(time.RFC3339),
+		"message": fmt.Sprintf("Ask the account owner to open the Kaitu app, go to Devices → Approve new device and enter %s, "+
+			"then call device_login_wait", p.userCode),
+	}), nil, nil
+}
+
+// DeviceLoginWaitInput is the input schema for the device_login_wait tool.
+type DeviceLoginWaitInput struct {
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" description:"How long to wait for approval (default 60, max 300)"`
+}
+
+// toolDeviceLoginWait implements the device_login_wait MCP tool.
+func (app *App) toolDeviceLoginWait(ctx context.Context, req *mcp.CallToolRequest, in DeviceLoginWaitInput) (*mcp.CallToolResult, any, error) {
+	p := app.deviceLogin.get()
+	if p == nil {
+		return errorResult("no pending device login, call device_login first"), nil, nil
+	}
+
+	wait := defaultDeviceWait
+	if in.TimeoutSeconds > 0 {
+		wait = min(time.Duration(in.TimeoutSeconds)*time.Second, maxDeviceWait)
+	}
+	interval := p.interval
+	if interval <= 0 {
+		interval = 5 * devicePollUnit
+	}
+	deadline := time.Now().Add(wait)
+
+	for {
+		var resp loginResponse
+		err := app.center.Post("/api/auth/device-token", map[string]string{"deviceCode": p.deviceCode}, &resp)
+		var ce *CenterError
+		switch {
+		case err == nil:
+			app.deviceLogin.clear(p)
+			email := app.completeLogin(resp)
+			return successResult(map[string]string{"email": email}), nil, nil
+		case errors.As(err, &ce) && ce.Code == codeCodeExpired:
+			app.deviceLogin.clear(p)
+			return errorResult("device code expired, call device_login again"), nil, nil
+		case !(errors.As(err, &ce) && ce.Code == codePendingApproval):
+			return app.handleCenterError(err), nil, nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return successResult(map[string]string{
+				"status":    "pending",
+				"user_code": p.userCode,
+				"message":   "not approved yet, call device_login_wait again",
+			}), nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return errorResult("cancelled"), nil, nil
+		case <-time.After(interval):
+		}
+	}
+}
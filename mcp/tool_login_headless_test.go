@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// headlessCenter fakes the headless login endpoints. Polls answer pending
+// until approved is set.
+type headlessCenter struct {
+	mu        sync.Mutex
+	approved  bool
+	polls     int
+	accessKey string
+	bodies    map[string]map[string]any
+}
+
+func (h *headlessCenter) serve(t *testing.T) string {
+	t.Helper()
+	write := func(w http.ResponseWriter, code int, data any) {
+		raw, _ := json.Marshal(data)
+		json.NewEncoder(w).Encode(centerResponse{Code: code, Message: "msg", Data: raw})
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.bodies[r.URL.Path] = body
+		tokens := loginResponse{AccessToken: "acc", RefreshToken: "ref", IssuedAt: time.Now().Unix()}
+		switch r.URL.Path {
+		case "/api/auth/access-key":
+			if r.Header.Get("X-Access-Key") != h.accessKey {
+				write(w, 400006, nil)
+				return
+			}
+			write(w, 0, tokens)
+		case "/api/auth/device-code":
+			write(w, 0, deviceCodeResponse{DeviceCode: "secret-dc", UserCode: "BCDF-GHJK", ExpiresIn: 600, Interval: 1})
+		case "/api/auth/device-token":
+			h.polls++
+			switch {
+			case body["deviceCode"] != "secret-dc":
+				write(w, codeCodeExpired, nil)
+			case !h.approved:
+				write(w, codePendingApproval, nil)
+			default:
+				h.approved = false // single use
+				write(w, 0, tokens)
+			}
+		case "/api/user":
+			if r.Header.Get("Authorization") != "Bearer acc" {
+				write(w, 401, nil)
+				return
+			}
+			write(w, 0, map[string]any{"loginIdentifies": []map[string]string{{"type": "email", "value": "ci@example.com"}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestToolLoginAccessKey(t *testing.T) {
+	h := &headlessCenter{accessKey: "ktu_good", bodies: map[string]map[string]any{}}
+	app := newTestApp(t, h.serve(t))
+	ctx := context.Background()
+
+	result, _, _ := app.toolLoginAccessKey(ctx, nil, LoginAccessKeyInput{AccessKey: "ktu_bad"})
+	if !result.IsError || app.session.LoggedIn() {
+		t.Fatalf("bad key must fail, got %s", textContent(t, result))
+	}
+
+	t.Setenv(accessKeyEnv, "ktu_good")
+	result, _, _ = app.toolLoginAccessKey(ctx, nil, LoginAccessKeyInput{})
+	if result.IsError {
+		t.Fatalf("login_access_key: %s", textContent(t, result))
+	}
+	if !strings.Contains(textContent(t, result), "ci@example.com") {
+		t.Errorf("expected email in result, got %s", textContent(t, result))
+	}
+	if h.bodies["/api/auth/access-key"]["udid"] != app.session.UDID() {
+		t.Errorf("expected session udid, got %v", h.bodies["/api/auth/access-key"])
+	}
+
+	// Persisted through Session.Save.
+	restored := NewSession(app.session.dir)
+	if err := restored.Restore(); err != nil || !restored.LoggedIn() || restored.Email != "ci@example.com" {
+		t.Errorf("session not persisted: %+v, %v", restored, err)
+	}
+}
+
+func TestToolDeviceLogin_WaitForApproval(t *testing.T) {
+	devicePollUnit = 10 * time.Millisecond
+	t.Cleanup(func() { devicePollUnit = time.Second })
+
+	h := &headlessCenter{bodies: map[string]map[string]any{}}
+	app := newTestApp(t, h.serve(t))
+	ctx := context.Background()
+
+	result, _, _ := app.toolDeviceLoginWait(ctx, nil, DeviceLoginWaitInput{})
+	if !result.IsError {
+		t.Fatal("wait without device_login must fail")
+	}
+
+	result, _, _ = app.toolDeviceLogin(ctx, nil, nil)
+	if result.IsError {
+		t.Fatalf("device_login: %s", textContent(t, result))
+	}
+	text := textContent(t, result)
+	if !strings.Contains(text, "BCDF-GHJK") || strings.Contains(text, "secret-dc") {
+		t.Fatalf("expected user code only, got %s", text)
+	}
+
+	// Not approved within the timeout: still pending, nothing stored.
+	result, _, _ = app.toolDeviceLoginWait(ctx, nil, DeviceLoginWaitInput{TimeoutSeconds: 1})
+	if result.IsError || !strings.Contains(textContent(t, result), "pending") {
+		t.Fatalf("expected pending, got %s", textContent(t, result))
+	}
+	if app.session.LoggedIn() {
+		t.Fatal("must not be logged in before approval")
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		h.mu.Lock()
+		h.approved = true
+		h.mu.Unlock()
+	}()
+	result, _, _ = app.toolDeviceLoginWait(ctx, nil, DeviceLoginWaitInput{TimeoutSeconds: 5})
+	if result.IsError {
+		t.Fatalf("device_login_wait: %s", textContent(t, result))
+	}
+	if !app.session.LoggedIn() || app.center.Token() != "acc" {
+		t.Error("expected tokens stored after approval")
+	}
+	if app.deviceLogin.get() != nil {
+		t.Error("pending device login must be cleared")
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// WebLoginLinkInput is the input schema for the web_login_link tool.
+type WebLoginLinkInput struct {
+	Redirect string `json:"redirect" description:"https URL on the Kaitu website to open signed in, e.g. the account page"`
+}
+
+// ottResponse is the data field returned by POST /api/user/ott.
+type ottResponse struct {
+	URL string `json:"url"`
+}
+
+// toolWebLoginLink implements the web_login_link MCP tool: exchange the
+// session for a one-time link that signs a browser in and lands on redirect.
+// Center only accepts redirects on the account's brand domain; the link is
+// single-use and expires after 5 minutes.
+func (app *App) toolWebLoginLink(ctx context.Context, req *mcp.CallToolRequest, in WebLoginLinkInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+	if in.Redirect == "" {
+		return errorResult("redirect is required"), nil, nil
+	}
+
+	var resp ottResponse
+	body := map[string]string{"redirect": in.Redirect}
+	if err := app.center.Post("/api/user/ott", body, &resp); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+	return successResult(map[string]any{
+		"url":                resp.URL,
+		"expires_in_seconds": 300,
+		"message":            "Open this link once in a browser; it signs in and expires in 5 minutes. Do not share it.",
+	}), nil, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestToolWebLoginLink(t *testing.T) {
+	f := &fakeCenter{routes: map[string]any{
+		"POST /api/user/ott": ottResponse{URL: "https://www.kaitu.io/api/auth/ott/exchange?ott=abc"},
+	}}
+	app := newFakeCenterApp(t, f)
+
+	result, _, _ := app.toolWebLoginLink(context.Background(), nil, WebLoginLinkInput{Redirect: "https://www.kaitu.io/account"})
+	if result.IsError {
+		t.Fatalf("expected success, got error: %s", textContent(t, result))
+	}
+	var out map[string]any
+	json.Unmarshal([]byte(textContent(t, result)), &out)
+	if out["url"] != "https://www.kaitu.io/api/auth/ott/exchange?ott=abc" {
+		t.Errorf("unexpected url: %v", out["url"])
+	}
+	if f.calls[0].Body["redirect"] != "https://www.kaitu.io/account" {
+		t.Errorf("unexpected body: %+v", f.calls[0].Body)
+	}
+
+	result, _, _ = app.toolWebLoginLink(context.Background(), nil, WebLoginLinkInput{})
+	if !result.IsError {
+		t.Error("expected error without redirect")
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultProbeTimeout = 2 * time.Second
+	maxProbeTimeout     = 10 * time.Second
+	probeConcurrency    = 16
+	// probeCacheTTL bounds how long connect_best reuses probe_servers results.
+	probeCacheTTL = time.Minute
+	// probeRTTCeiling is the RTT at which the latency score bottoms out.
+	probeRTTCeiling = 500 * time.Millisecond
+)
+
+// probeResult is one server's reachability measurement.
+type probeResult struct {
+	Reachable bool
+	RTT       time.Duration
+	Err       string
+	At        time.Time
+}
+
+// probeAddr returns host:port to dial for a server URL (k2v5 defaults to 443).
+// The ?ip= parameter wins over the hostname, as in the center's
+// parseServerURLDescriptor: the transport dials that address directly and the
+// hostname is only the TLS/ECH name, which may not resolve to the node at all.
+func probeAddr(serverURL string) (string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", err
+	}
+	host := strings.Trim(u.Query().Get("ip"), "[]")
+	if host == "" {
+		host = u.Hostname()
+	}
+	if host == "" {
+		return "", fmt.Errorf("no host in server URL")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// probeServer measures the TCP handshake RTT to a server — the first leg the
+// k2v5 transport dials, so an unreachable or slow path shows up here.
+func probeServer(ctx context.Context, s Server, timeout time.Duration) probeResult {
+	res := probeResult{At: time.Now()}
+	addr, err := probeAddr(s.ServerURL)
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			res.Err = "timeout"
+		} else {
+			res.Err = err.Error()
+		}
+		return res
+	}
+	res.RTT = time.Since(start)
+	res.Reachable = true
+	conn.Close()
+	return res
+}
+
+// probeServers probes servers in parallel and records the results in the
+// probe cache. Results are keyed by server ID.
+func (app *App) probeServers(ctx context.Context, servers []Server, timeout time.Duration) map[int]probeResult {
+	results := make(map[int]probeResult, len(servers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, probeConcurrency)
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s Server) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			r := probeServer(ctx, s, timeout)
+			mu.Lock()
+			results[s.ID] = r
+			mu.Unlock()
+		}(s)
+	}
+	wg.Wait()
+
+	app.probesMu.Lock()
+	if app.probes == nil {
+		app.probes = make(map[int]probeResult)
+	}
+	for id, r := range results {
+		app.probes[id] = r
+	}
+	app.probesMu.Unlock()
+	return results
+}
+
+// cachedProbes returns fresh cached probe results for all servers, or nil if
+// any of them is missing or stale.
+func (app *App) cachedProbes(servers []Server) map[int]probeResult {
+	app.probesMu.Lock()
+	defer app.probesMu.Unlock()
+	out := make(map[int]probeResult, len(servers))
+	for _, s := range servers {
+		r, ok := app.probes[s.ID]
+		if !ok || time.Since(r.At) > probeCacheTTL {
+			return nil
+		}
+		out[s.ID] = r
+	}
+	return out
+}
+
+// filterServers keeps servers matching country and region (case-insensitive,
+// empty = any).
+func filterServers(servers []Server, country, region string) []Server {
+	out := make([]Server, 0, len(servers))
+	for _, s := range servers {
+		if country != "" && !strings.EqualFold(s.Country, country) {
+			continue
+		}
+		if region != "" && !strings.EqualFold(s.Region, region) {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// probeTimeout converts a timeout_ms input into a bounded duration.
+func probeTimeout(ms int) time.Duration {
+	if ms <= 0 {
+		return defaultProbeTimeout
+	}
+	d := time.Duration(ms) * time.Millisecond
+	if d > maxProbeTimeout {
+		return maxProbeTimeout
+	}
+	return d
+}
+
+// bestScore combines Center's recommendScore (load, health, capacity) with
+// the locally measured latency, which Center cannot see. Both are [0,1];
+// unreachable servers are never picked.
+func bestScore(s Server, r probeResult) float64 {
+	latency := 1 - float64(r.RTT)/float64(probeRTTCeiling)
+	if latency < 0 {
+		latency = 0
+	}
+	return 0.6*s.RecommendScore + 0.4*latency
+}
+
+// ProbeServersInput is the input schema for the probe_servers tool.
+type ProbeServersInput struct {
+	Country   string `json:"country,omitempty"    description:"Optional country code filter, e.g. JP"`
+	Region    string `json:"region,omitempty"     description:"Optional region filter"`
+	TimeoutMs int    `json:"timeout_ms,omitempty" description:"Per-server timeout in milliseconds (default 2000, max 10000)"`
+}
+
+// probeOutput is one row of probe_servers output.
+type probeOutput struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Country   string `json:"country"`
+	Reachable bool   `json:"reachable"`
+	RTTMs     int64  `json:"rtt_ms,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// toolProbeServers implements the probe_servers MCP tool.
+func (app *App) toolProbeServers(ctx context.Context, req *mcp.CallToolRequest, in ProbeServersInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+
+	servers, err := app.fetchServers()
+	if err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+	servers = filterServers(servers, in.Country, in.Region)
+	if len(servers) == 0 {
+		return errorResult("no servers match the filter, call list_servers to see available servers"), nil, nil
+	}
+
+	results := app.probeServers(ctx, servers, probeTimeout(in.TimeoutMs))
+
+	out := make([]probeOutput, 0, len(servers))
+	for _, s := range servers {
+		r := results[s.ID]
+		row := probeOutput{ID: s.ID, Name: s.Name, Country: s.Country, Reachable: r.Reachable, Error: r.Err}
+		if r.Reachable {
+			row.RTTMs = r.RTT.Milliseconds()
+		}
+		out = append(out, row)
+	}
+	// Reachable first, fastest first.
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Reachable != out[j].Reachable {
+			return out[i].Reachable
+		}
+		return out[i].RTTMs < out[j].RTTMs
+	})
+
+	return successResult(map[string]any{"servers": out}), nil, nil
+}
+
+// ConnectBestInput is the input schema for the connect_best tool.
+type ConnectBestInput struct {
+	Country   string `json:"country,omitempty"    description:"Optional country code filter, e.g. JP"`
+	Region    string `json:"region,omitempty"     description:"Optional region filter"`
+	TimeoutMs int    `json:"timeout_ms,omitempty" description:"Per-server probe timeout in milliseconds (default 2000, max 10000)"`
+}
+
+// toolConnectBest implements the connect_best MCP tool: probe (or reuse a
+// recent probe_servers run), score, and connect to the winner.
+func (app *App) toolConnectBest(ctx context.Context, req *mcp.CallToolRequest, in ConnectBestInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+
+	servers, err := app.fetchServers()
+	if err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+	servers = filterServers(servers, in.Country, in.Region)
+	if len(servers) == 0 {
+		return errorResult("no servers match the filter, call list_servers to see available servers"), nil, nil
+	}
+
+	results := app.cachedProbes(servers)
+	if results == nil {
+		results = app.probeServers(ctx, servers, probeTimeout(in.TimeoutMs))
+	}
+
+	var best *Server
+	var bestRes probeResult
+	bestVal := -1.0
+	for i := range servers {
+		r := results[servers[i].ID]
+		if !r.Reachable {
+			continue
+		}
+		if v := bestScore(servers[i], r); v > bestVal {
+			best, bestRes, bestVal = &servers[i], r, v
+		}
+	}
+	if best == nil {
+		return errorResult("no matching server is reachable, check your network or try another country"), nil, nil
+	}
+
+	return app.connectServer(best, map[string]any{
+		"server_id": best.ID,
+		"rtt_ms":    bestRes.RTT.Milliseconds(),
+		"score":     bestVal,
+	}), nil, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenTCP returns the address of a local listener that accepts and drops
+// connections, standing in for a reachable server.
+func listenTCP(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// closedAddr returns a local address nothing listens on.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// newProbeTestApp serves tunnels from a fake Center and records the URL the
+// daemon is asked to connect to.
+func newProbeTestApp(t *testing.T, tunnels []tunnelEntry) (*App, *string) {
+	t.Helper()
+	centerSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := json.Marshal(tunnelListResponse{Items: tunnels})
+		resp := centerResponse{Code: 0, Message: "ok", Data: json.RawMessage(data)}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(centerSrv.Close)
+
+	var upURL string
+	daemonSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/core" {
+			var body struct {
+				Action string `json:"action"`
+				Params struct {
+					Config struct {
+						Routes []DaemonRoute `json:"routes"`
+					} `json:"config"`
+				} `json:"params"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Action == "up" && len(body.Params.Config.Routes) > 0 {
+				upURL = body.Params.Config.Routes[0].Via
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(daemonSrv.Close)
+
+	app := newTestApp(t, centerSrv.URL)
+	app.daemon = &DaemonClient{Addr: daemonSrv.URL}
+	app.session.SetTokens("tok", "ref", "user@example.com", time.Now())
+	app.center.SetToken("tok")
+	return app, &upURL
+}
+
+func TestProbeAddr(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"k2v5://jp1.example.com", "jp1.example.com:443"},
+		{"k2v5://jp1.example.com:8443?ech=abc", "jp1.example.com:8443"},
+		{"k2v5://[2001:db8::1]:443", "[2001:db8::1]:443"},
+		{"k2v5://jp1.example.com?ip=203.0.113.7&ech=abc", "203.0.113.7:443"},
+		{"k2v5://jp1.example.com:8443?ip=2001:db8::7", "[2001:db8::7]:8443"},
+		{"k2v5://?ip=203.0.113.7", "203.0.113.7:443"},
+	}
+	for _, tt := range tests {
+		got, err := probeAddr(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("probeAddr(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := probeAddr("k2v5://"); err == nil {
+		t.Error("expected error for URL without host")
+	}
+}
+
+func TestToolProbeServers(t *testing.T) {
+	up := listenTCP(t)
+	down := closedAddr(t)
+	app, _ := newProbeTestApp(t, []tunnelEntry{
+		{ID: 1, Name: "Down", ServerURL: "k2v5://" + down, Node: tunnelNode{Country: "JP"}},
+		{ID: 2, Name: "Up", ServerURL: "k2v5://" + up, Node: tunnelNode{Country: "JP"}},
+		{ID: 3, Name: "Elsewhere", ServerURL: "k2v5://" + up, Node: tunnelNode{Country: "US"}},
+	})
+
+	result, _, err := app.toolProbeServers(context.Background(), nil, ProbeServersInput{Country: "jp", TimeoutMs: 1000})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %s", textContent(t, result))
+	}
+
+	var out struct {
+		Servers []probeOutput `json:"servers"`
+	}
+	if err := json.Unmarshal([]byte(textContent(t, result)), &out); err != nil {
+		t.Fatalf("unmarshal result: %v", err)
+	}
+	if len(out.Servers) != 2 {
+		t.Fatalf("expected 2 JP servers, got %d", len(out.Servers))
+	}
+	if out.Servers[0].ID != 2 || !out.Servers[0].Reachable {
+		t.Errorf("expected reachable server 2 first, got %+v", out.Servers[0])
+	}
+	if out.Servers[1].ID != 1 || out.Servers[1].Reachable || out.Servers[1].Error == "" {
+		t.Errorf("expected unreachable server 1 with an error, got %+v", out.Servers[1])
+	}
+}
+
+func TestToolConnectBest_PicksReachableByScore(t *testing.T) {
+	fast := listenTCP(t)
+	down := closedAddr(t)
+	app, upURL := newProbeTestApp(t, []tunnelEntry{
+		// Highest recommendScore but unreachable from here: never picked.
+		{ID: 1, Name: "Down", ServerURL: "k2v5://" + down, RecommendScore: 1, Node: tunnelNode{Country: "JP"}},
+		{ID: 2, Name: "Busy", ServerURL: "k2v5://" + fast, RecommendScore: 0.1, Node: tunnelNode{Country: "JP"}},
+		{ID: 3, Name: "Good", ServerURL: "k2v5://" + fast + "?ech=x", RecommendScore: 0.9, Node: tunnelNode{Country: "JP"}},
+	})
+
+	result, _, err := app.toolConnectBest(context.Background(), nil, ConnectBestInput{Country: "JP"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("expected success, got error: %s", textContent(t, result))
+	}
+
+	var out map[string]any
+	if err := json.Unmarshal([]byte(textContent(t, result)), &out); err != nil {
+		t.Fatalf("unmarshal result: %v", err)
+	}
+	if out["server"] != "Good" || out["server_id"] != float64(3) {
+		t.Errorf("expected Good (3), got %v (%v)", out["server"], out["server_id"])
+	}
+	if !strings.Contains(*upURL, fast) {
+		t.Errorf("daemon up URL %q does not target %s", *upURL, fast)
+	}
+}
+
+func TestToolConnectBest_NoneReachable(t *testing.T) {
+	app, upURL := newProbeTestApp(t, []tunnelEntry{
+		{ID: 1, Name: "Down", ServerURL: "k2v5://" + closedAddr(t), RecommendScore: 1, Node: tunnelNode{Country: "JP"}},
+	})
+
+	result, _, _ := app.toolConnectBest(context.Background(), nil, ConnectBestInput{})
+	if !result.IsError {
+		t.Fatalf("expected error, got %s", textContent(t, result))
+	}
+	if *upURL != "" {
+		t.Error("daemon must not be asked to connect")
+	}
+
+	result, _, _ = app.toolConnectBest(context.Background(), nil, ConnectBestInput{Country: "ZZ"})
+	if !result.IsError || !strings.Contains(textContent(t, result), "no servers match") {
+		t.Errorf("expected filter error, got %s", textContent(t, result))
+	}
+}
+
+func TestBestScore(t *testing.T) {
+	near := probeResult{Reachable: true, RTT: 20 * time.Millisecond}
+	far := probeResult{Reachable: true, RTT: 450 * time.Millisecond}
+	s := Server{RecommendScore: 0.5}
+	if bestScore(s, near) <= bestScore(s, far) {
+		t.Error("lower RTT must score higher at equal recommendScore")
+	}
+	if got := bestScore(Server{}, probeResult{Reachable: true, RTT: 2 * time.Second}); got != 0 {
+		t.Errorf("RTT beyond ceiling should add nothing, got %v", got)
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Route targets accepted by add_route. "tunnel" is replaced by the connected
+// server's outbound URL when the route table is built.
+const (
+	viaTunnel = "tunnel"
+	viaDirect = "direct"
+	viaReject = "reject"
+)
+
+// routingProfiles maps routing profile → the preset its traffic bypasses.
+// Same names Center's SuggestedProfileForCountry hands out; keep in sync with
+// webapp/src/utils/routes.ts PROFILE_TO_PRESET. "global" has no preset.
+var routingProfiles = map[string]string{
+	"global":  "",
+	"cnroute": "cn-access",
+	"iroute":  "ir-access",
+	"ruroute": "ru-access",
+	"troute":  "tr-access",
+	"pkroute": "pk-access",
+	"vnroute": "vn-access",
+	"mmroute": "mm-access",
+	"egroute": "eg-access",
+	"idroute": "id-access",
+	"saroute": "sa-access",
+	"aeroute": "ae-access",
+	"throute": "th-access",
+	"bdroute": "bd-access",
+	"byroute": "by-access",
+}
+
+// RouteRule is one user routing rule. Criteria within a rule are combined by
+// the engine; rules are evaluated in order, ahead of the profile's routes.
+type RouteRule struct {
+	ID           string   `json:"id"`
+	DomainSuffix []string `json:"domain_suffix,omitempty"`
+	IPCIDR       []string `json:"ip_cidr,omitempty"`
+	Geo          string   `json:"geo,omitempty"`
+	Via          string   `json:"via"`
+}
+
+// routingState is persisted to mcp-routes.json next to the session.
+type routingState struct {
+	Profile string      `json:"profile,omitempty"`
+	Rules   []RouteRule `json:"rules"`
+	NextID  int         `json:"next_id"`
+}
+
+const routesFile = "mcp-routes.json"
+
+// loadRouting reads the routing state; a missing file is an empty state.
+func loadRouting(dir string) (*routingState, error) {
+	b, err := os.ReadFile(filepath.Join(dir, routesFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &routingState{}, nil
+		}
+		return nil, fmt.Errorf("routes read: %w", err)
+	}
+	var st routingState
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("routes unmarshal: %w", err)
+	}
+	return &st, nil
+}
+
+func (st *routingState) save(dir string) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("routes marshal: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("routes mkdir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, routesFile), b, 0600); err != nil {
+		return fmt.Errorf("routes write: %w", err)
+	}
+	return nil
+}
+
+// build returns the daemon route table for outbound serverURL: user rules
+// first, then the profile. With no rules and no profile this is exactly the
+// single catch-all route Up sends.
+func (st *routingState) build(serverURL string) []DaemonRoute {
+	routes := make([]DaemonRoute, 0, len(st.Rules)+2)
+	for _, r := range st.Rules {
+		via := r.Via
+		if via == viaTunnel {
+			via = serverURL
+		}
+		routes = append(routes, DaemonRoute{Via: via, Match: &DaemonMatch{
+			DomainSuffix: r.DomainSuffix,
+			IPCIDR:       r.IPCIDR,
+			Region:       r.Geo,
+		}})
+	}
+	switch preset := routingProfiles[st.Profile]; {
+	case st.Profile == "":
+		routes = append(routes, DaemonRoute{Via: serverURL})
+	case preset == "":
+		routes = append(routes, DaemonRoute{Via: serverURL, Match: &DaemonMatch{All: true}})
+	default:
+		routes = append(routes,
+			DaemonRoute{Via: viaDirect, Match: &DaemonMatch{Preset: preset}},
+			DaemonRoute{Via: serverURL},
+		)
+	}
+	return routes
+}
+
+// withRouting runs fn on the routing state under lock and persists it if fn
+// succeeds and reports a change.
+func (app *App) withRouting(fn func(st *routingState) (bool, error)) error {
+	app.routingMu.Lock()
+	defer app.routingMu.Unlock()
+	st, err := loadRouting(app.session.dir)
+	if err != nil {
+		return err
+	}
+	changed, err := fn(st)
+	if err != nil || !changed {
+		return err
+	}
+	return st.save(app.session.dir)
+}
+
+// routesFor returns the route table for connecting to serverURL.
+func (app *App) routesFor(serverURL string) ([]DaemonRoute, error) {
+	var routes []DaemonRoute
+	err := app.withRouting(func(st *routingState) (bool, error) {
+		routes = st.build(serverURL)
+		return false, nil
+	})
+	return routes, err
+}
+
+// applyRoutes re-sends the route table if the tunnel is up, so rule changes
+// take effect without an explicit reconnect. Reports whether it did.
+//
+// The daemon's outbound URL carries the credentials it was connected with,
+// and the access token in it may have been refreshed since; like
+// connectServer, rebuild it from the bare server URL and the current token.
+func (app *App) applyRoutes() (bool, error) {
+	status, err := app.daemon.Status()
+	if err != nil || status.State != "connected" {
+		return false, nil
+	}
+	server := stripAuthURL(status.Config.Server())
+	if server == "" {
+		return false, nil
+	}
+	app.session.mu.RLock()
+	token := app.session.AccessToken
+	app.session.mu.RUnlock()
+	authURL := buildAuthURL(server, app.session.UDID(), token)
+
+	routes, err := app.routesFor(authURL)
+	if err != nil {
+		return false, err
+	}
+	if err := app.daemon.UpRoutes(routes); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+var geoCode = regexp.MustCompile(`^[a-z]{2}$`)
+
+// normalizeRule validates a rule and canonicalises its criteria.
+func normalizeRule(r *RouteRule) error {
+	r.Via = strings.ToLower(strings.TrimSpace(r.Via))
+	switch r.Via {
+	case viaTunnel, viaDirect, viaReject:
+	default:
+		return fmt.Errorf("via must be %q, %q or %q", viaTunnel, viaDirect, viaReject)
+	}
+
+	domains := make([]string, 0, len(r.DomainSuffix))
+	for _, d := range r.DomainSuffix {
+		d = strings.ToLower(strings.TrimSpace(d))
+		d = strings.TrimPrefix(strings.TrimPrefix(d, "*"), ".")
+		if d == "" || strings.ContainsAny(d, " /:*") {
+			return fmt.Errorf("invalid domain suffix %q", d)
+		}
+		domains = append(domains, d)
+	}
+	r.DomainSuffix = domains
+
+	cidrs := make([]string, 0, len(r.IPCIDR))
+	for _, c := range r.IPCIDR {
+		c = strings.TrimSpace(c)
+		if ip := net.ParseIP(c); ip != nil {
+			if ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(c)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q", c)
+		}
+		cidrs = append(cidrs, ipnet.String())
+	}
+	r.IPCIDR = cidrs
+
+	r.Geo = strings.ToLower(strings.TrimSpace(r.Geo))
+	if r.Geo != "" && !geoCode.MatchString(r.Geo) {
+		return fmt.Errorf("geo must be a two-letter country code, got %q", r.Geo)
+	}
+
+	if len(r.DomainSuffix) == 0 && len(r.IPCIDR) == 0 && r.Geo == "" {
+		return fmt.Errorf("a rule needs at least one of domain_suffix, ip_cidr or geo")
+	}
+	return nil
+}
+
+// routesOutput is what list_routes and the mutating tools return.
+func routesOutput(st *routingState) map[string]any {
+	profile := st.Profile
+	if profile == "" {
+		profile = "global"
+	}
+	profiles := make([]string, 0, len(routingProfiles))
+	for p := range routingProfiles {
+		profiles = append(profiles, p)
+	}
+	sort.Strings(profiles)
+	rules := st.Rules
+	if rules == nil {
+		rules = []RouteRule{}
+	}
+	return map[string]any{
+		"profile":            profile,
+		"rules":              rules,
+		"available_profiles": profiles,
+	}
+}
+
+// withApplied adds whether the change reached the live tunnel.
+func (app *App) withApplied(out map[string]any) map[string]any {
+	applied, err := app.applyRoutes()
+	out["applied"] = applied
+	if err != nil {
+		out["apply_error"] = err.Error()
+	} else if !applied {
+		out["note"] = "not connected; rules take effect on the next connect"
+	}
+	return out
+}
+
+// toolListRoutes implements the list_routes MCP tool.
+func (app *App) toolListRoutes(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+	var out map[string]any
+	if err := app.withRouting(func(st *routingState) (bool, error) {
+		out = routesOutput(st)
+		return false, nil
+	}); err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	return successResult(out), nil, nil
+}
+
+// AddRouteInput is the input schema for the add_route tool.
+type AddRouteInput struct {
+	DomainSuffix []string `json:"domain_suffix,omitempty" description:"Domain suffixes to match, e.g. github.com (also matches *.github.com)"`
+	IPCIDR       []string `json:"ip_cidr,omitempty"       description:"IP addresses or CIDR ranges to match, e.g. 10.0.0.0/8"`
+	Geo          string   `json:"geo,omitempty"           description:"Two-letter country code: match traffic to that country's domains and IPs"`
+	Via          string   `json:"via"                     description:"Where matching traffic goes: tunnel, direct or reject"`
+}
+
+// toolAddRoute implements the add_route MCP tool.
+func (app *App) toolAddRoute(ctx context.Context, req *mcp.CallToolRequest, in AddRouteInput) (*mcp.CallToolResult, any, error) {
+	rule := RouteRule{DomainSuffix: in.DomainSuffix, IPCIDR: in.IPCIDR, Geo: in.Geo, Via: in.Via}
+	if err := normalizeRule(&rule); err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+
+	var out map[string]any
+	if err := app.withRouting(func(st *routingState) (bool, error) {
+		st.NextID++
+		rule.ID = fmt.Sprintf("r%d", st.NextID)
+		st.Rules = append(st.Rules, rule)
+		out = routesOutput(st)
+		return true, nil
+	}); err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	out["added"] = rule.ID
+	return successResult(app.withApplied(out)), nil, nil
+}
+
+// RemoveRouteInput is the input schema for the remove_route tool.
+type RemoveRouteInput struct {
+	ID string `json:"id" description:"Rule ID from list_routes"`
+}
+
+// toolRemoveRoute implements the remove_route MCP tool.
+func (app *App) toolRemoveRoute(ctx context.Context, req *mcp.CallToolRequest, in RemoveRouteInput) (*mcp.CallToolResult, any, error) {
+	var out map[string]any
+	found := false
+	if err := app.withRouting(func(st *routingState) (bool, error) {
+		for i, r := range st.Rules {
+			if r.ID == in.ID {
+				st.Rules = append(st.Rules[:i], st.Rules[i+1:]...)
+				found = true
+				break
+			}
+		}
+		out = routesOutput(st)
+		return found, nil
+	}); err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	if !found {
+		return errorResult(fmt.Sprintf("rule %q not found, call list_routes to see rule IDs", in.ID)), nil, nil
+	}
+	return successResult(app.withApplied(out)), nil, nil
+}
+
+// SetRoutingProfileInput is the input schema for the set_routing_profile tool.
+type SetRoutingProfileInput struct {
+	Profile string `json:"profile" description:"Profile name (global, cnroute, iroute, ...) or a two-letter country code"`
+}
+
+// toolSetRoutingProfile implements the set_routing_profile MCP tool.
+func (app *App) toolSetRoutingProfile(ctx context.Context, req *mcp.CallToolRequest, in SetRoutingProfileInput) (*mcp.CallToolResult, any, error) {
+	profile := strings.ToLower(strings.TrimSpace(in.Profile))
+	if geoCode.MatchString(profile) {
+		// Country code → profile, as Center's SuggestedProfileForCountry does.
+		cc := profile
+		profile = "global"
+		for p, preset := range routingProfiles {
+			if preset == cc+"-access" {
+				profile = p
+			}
+		}
+	}
+	if _, ok := routingProfiles[profile]; !ok {
+		return errorResult(fmt.Sprintf("unknown routing profile %q, call list_routes for available profiles", in.Profile)), nil, nil
+	}
+
+	var out map[string]any
+	if err := app.withRouting(func(st *routingState) (bool, error) {
+		st.Profile = profile
+		out = routesOutput(st)
+		return true, nil
+	}); err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	return successResult(app.withApplied(out)), nil, nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestNormalizeRule(t *testing.T) {
+	r := RouteRule{
+		DomainSuffix: []string{"*.GitHub.com", ".example.org"},
+		IPCIDR:       []string{"10.1.2.3", "192.168.1.77/24", "2001:db8::1"},
+		Geo:          "CN",
+		Via:          "Direct",
+	}
+	if err := normalizeRule(&r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := RouteRule{
+		DomainSuffix: []string{"github.com", "example.org"},
+		IPCIDR:       []string{"10.1.2.3/32", "192.168.1.0/24", "2001:db8::1/128"},
+		Geo:          "cn",
+		Via:          "direct",
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("got %+v, want %+v", r, want)
+	}
+
+	bad := []RouteRule{
+		{DomainSuffix: []string{"github.com"}, Via: "proxy"},
+		{Via: "tunnel"},
+		{IPCIDR: []string{"10.0.0.0/33"}, Via: "tunnel"},
+		{DomainSuffix: []string{"https://github.com"}, Via: "tunnel"},
+		{Geo: "china", Via: "tunnel"},
+	}
+	for _, r := range bad {
+		if err := normalizeRule(&r); err == nil {
+			t.Errorf("expected error for %+v", r)
+		}
+	}
+}
+
+func TestRoutingStateBuild(t *testing.T) {
+	const url = "k2v5://u:t@jp1.example.com"
+
+	if got := (&routingState{}).build(url); !reflect.DeepEqual(got, []DaemonRoute{{Via: url}}) {
+		t.Errorf("empty state must keep the single catch-all route, got %+v", got)
+	}
+
+	st := &routingState{
+		Profile: "cnroute",
+		Rules: []RouteRule{
+			{ID: "r1", DomainSuffix: []string{"github.com"}, Via: viaTunnel},
+			{ID: "r2", IPCIDR: []string{"10.0.0.0/8"}, Via: viaDirect},
+		},
+	}
+	want := []DaemonRoute{
+		{Via: url, Match: &DaemonMatch{DomainSuffix: []string{"github.com"}}},
+		{Via: "direct", Match: &DaemonMatch{IPCIDR: []string{"10.0.0.0/8"}}},
+		{Via: "direct", Match: &DaemonMatch{Preset: "cn-access"}},
+		{Via: url},
+	}
+	if got := st.build(url); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	st = &routingState{Profile: "global"}
+	want = []DaemonRoute{{Via: url, Match: &DaemonMatch{All: true}}}
+	if got := st.build(url); !reflect.DeepEqual(got, want) {
+		t.Errorf("global: got %+v, want %+v", got, want)
+	}
+}
+
+// routesDaemon fakes a daemon in the given state and records every up.
+type routesDaemon struct {
+	mu    sync.Mutex
+	state string
+	ups   [][]DaemonRoute
+}
+
+func (d *routesDaemon) serve(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			return
+		}
+		var body struct {
+			Action string `json:"action"`
+			Params struct {
+				Config struct {
+					Routes []DaemonRoute `json:"routes"`
+				} `json:"config"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		switch body.Action {
+		case "up":
+			d.ups = append(d.ups, body.Params.Config.Routes)
+		case "status":
+			writeDaemonEnvelope(w, DaemonStatus{
+				State:  d.state,
+				Config: &DaemonConfig{Routes: []DaemonRoute{{Via: "k2v5://u:t@jp1.example.com"}}},
+			})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestRouteTools_PersistAndApply(t *testing.T) {
+	d := &routesDaemon{state: "disconnected"}
+	app := newTestApp(t, "http://127.0.0.1:0")
+	app.daemon = &DaemonClient{Addr: d.serve(t)}
+	ctx := context.Background()
+
+	result, _, _ := app.toolAddRoute(ctx, nil, AddRouteInput{DomainSuffix: []string{"github.com"}, Via: "tunnel"})
+	if result.IsError {
+		t.Fatalf("add_route: %s", textContent(t, result))
+	}
+	var out map[string]any
+	json.Unmarshal([]byte(textContent(t, result)), &out)
+	if out["added"] != "r1" || out["applied"] != false {
+		t.Errorf("expected r1 added but not applied while disconnected, got %v", out)
+	}
+	if len(d.ups) != 0 {
+		t.Fatal("must not touch the daemon while disconnected")
+	}
+
+	// Persisted next to the session.
+	st, err := loadRouting(app.session.dir)
+	if err != nil || len(st.Rules) != 1 || st.Rules[0].ID != "r1" {
+		t.Fatalf("rules not persisted: %+v, %v", st, err)
+	}
+
+	// Connected: a profile change is pushed to the live tunnel, with the
+	// outbound rebuilt from the current token rather than the daemon's stale one.
+	d.state = "connected"
+	app.session.mu.Lock()
+	app.session.AccessToken = "fresh-token"
+	app.session.mu.Unlock()
+	result, _, _ = app.toolSetRoutingProfile(ctx, nil, SetRoutingProfileInput{Profile: "IR"})
+	if result.IsError {
+		t.Fatalf("set_routing_profile: %s", textContent(t, result))
+	}
+	json.Unmarshal([]byte(textContent(t, result)), &out)
+	if out["profile"] != "iroute" || out["applied"] != true {
+		t.Errorf("expected iroute applied, got %v", out)
+	}
+	if len(d.ups) != 1 || len(d.ups[0]) != 3 || d.ups[0][1].Match.Preset != "ir-access" {
+		t.Fatalf("unexpected route table pushed: %+v", d.ups)
+	}
+	if want := buildAuthURL("k2v5://jp1.example.com", app.session.UDID(), "fresh-token"); d.ups[0][2].Via != want {
+		t.Errorf("expected outbound %q, got %q", want, d.ups[0][2].Via)
+	}
+
+	result, _, _ = app.toolRemoveRoute(ctx, nil, RemoveRouteInput{ID: "r1"})
+	if result.IsError {
+		t.Fatalf("remove_route: %s", textContent(t, result))
+	}
+	result, _, _ = app.toolRemoveRoute(ctx, nil, RemoveRouteInput{ID: "r1"})
+	if !result.IsError {
+		t.Error("expected error removing an unknown rule")
+	}
+
+	result, _, _ = app.toolSetRoutingProfile(ctx, nil, SetRoutingProfileInput{Profile: "nosuchroute"})
+	if !result.IsError {
+		t.Error("expected error for an unknown profile")
+	}
+}
+
+func TestToolConnect_SendsRoutingRules(t *testing.T) {
+	d := &routesDaemon{state: "disconnected"}
+	app, _ := newProbeTestApp(t, []tunnelEntry{
+		{ID: 7, Name: "Tokyo", ServerURL: "k2v5://jp1.example.com"},
+	})
+	app.daemon = &DaemonClient{Addr: d.serve(t)}
+	ctx := context.Background()
+
+	app.toolAddRoute(ctx, nil, AddRouteInput{IPCIDR: []string{"10.0.0.0/8"}, Via: "direct"})
+	result, _, _ := app.toolConnect(ctx, nil, ConnectInput{ServerID: 7})
+	if result.IsError {
+		t.Fatalf("connect: %s", textContent(t, result))
+	}
+	if len(d.ups) != 1 || len(d.ups[0]) != 2 {
+		t.Fatalf("expected rule + outbound routes, got %+v", d.ups)
+	}
+	if d.ups[0][0].Via != "direct" || d.ups[0][1].Match != nil {
+		t.Errorf("unexpected route table: %+v", d.ups[0])
+	}
+}
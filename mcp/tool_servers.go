@@ -26,6 +26,8 @@ type tunnelEntry struct {
 	Domain    string     `json:"domain"`
 	ServerURL string     `json:"serverUrl"`
 	Node      tunnelNode `json:"node"`
+	// RecommendScore is Center's [0,1] recommendation (higher = better).
+	RecommendScore float64 `json:"recommendScore"`
 }
 
 // tunnelListResponse wraps the Center API items array.
@@ -75,6 +77,7 @@ func (app *App) fetchServers() ([]Server, error) {
 			TrafficUsagePercent:   t.Node.TrafficUsagePercent,
 			BandwidthUsagePercent: t.Node.BandwidthUsagePercent,
 			ServerURL:             t.ServerURL,
+			RecommendScore:        t.RecommendScore,
 		})
 	}
 
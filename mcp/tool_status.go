@@ -24,12 +24,13 @@ func (app *App) resolveServerName(serverURL string) string {
 	return domain
 }
 
-// toolStatus implements the status MCP tool.
-func (app *App) toolStatus(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+// statusSnapshot returns the connection state as reported by the status tool
+// and the k2://status resource.
+func (app *App) statusSnapshot() map[string]any {
 	status, err := app.daemon.Status()
 	if err != nil {
 		// Daemon unreachable — treat as disconnected.
-		return successResult(map[string]string{"state": "disconnected"}), nil, nil
+		return map[string]any{"state": "disconnected"}
 	}
 
 	out := map[string]any{
@@ -48,10 +49,16 @@ func (app *App) toolStatus(ctx context.Context, req *mcp.CallToolRequest, _ any)
 		}
 	case "error":
 		if status.Error != nil {
+			app.daemonErrors.record(status.Error.Code, status.Error.Message, "status")
 			out["error"] = status.Error.Message
 			out["error_code"] = status.Error.Code
 		}
 	}
 
-	return successResult(out), nil, nil
+	return out
+}
+
+// toolStatus implements the status MCP tool.
+func (app *App) toolStatus(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+	return successResult(app.statusSnapshot()), nil, nil
 }
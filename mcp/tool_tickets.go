@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// maxTicketContent and maxReplyContent mirror Center's binding limits (runes).
+	maxTicketContent = 5000
+	maxReplyContent  = 2000
+)
+
+// ticketEntry is one item of GET /api/user/tickets.
+type ticketEntry struct {
+	ID          uint64 `json:"id"`
+	FeedbackID  string `json:"feedbackId"`
+	Content     string `json:"content"`
+	Status      string `json:"status"`
+	UserUnread  int    `json:"userUnread"`
+	LastReplyAt *int64 `json:"lastReplyAt"`
+	LastReplyBy string `json:"lastReplyBy"`
+	CreatedAt   int64  `json:"createdAt"`
+}
+
+// ticketListResponse is the data field returned by GET /api/user/tickets.
+type ticketListResponse struct {
+	Items      []ticketEntry `json:"items"`
+	Pagination struct {
+		Page     int   `json:"page"`
+		PageSize int   `json:"pageSize"`
+		Total    int64 `json:"total"`
+	} `json:"pagination"`
+}
+
+// ticketDetailResponse is the data field returned by GET /api/user/tickets/:id.
+type ticketDetailResponse struct {
+	ID         uint64 `json:"id"`
+	FeedbackID string `json:"feedbackId"`
+	Content    string `json:"content"`
+	Status     string `json:"status"`
+	CreatedAt  int64  `json:"createdAt"`
+	ResolvedAt *int64 `json:"resolvedAt"`
+	Replies    []struct {
+		SenderType string `json:"senderType"`
+		SenderName string `json:"senderName"`
+		Content    string `json:"content"`
+		CreatedAt  int64  `json:"createdAt"`
+	} `json:"replies"`
+}
+
+// ticketOutput is one ticket as returned to the MCP client.
+type ticketOutput struct {
+	ID          uint64 `json:"id"`
+	Status      string `json:"status"`
+	Summary     string `json:"summary"`
+	Unread      int    `json:"unread_replies"`
+	LastReplyBy string `json:"last_reply_by,omitempty"`
+	LastReplyAt string `json:"last_reply_at,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ticketReplyOutput is one message in a ticket conversation.
+type ticketReplyOutput struct {
+	From      string `json:"from"`
+	Name      string `json:"name,omitempty"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+// formatUnix renders a Center unix timestamp for MCP output.
+func formatUnix(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
+
+// ListTicketsInput is the input schema for the list_tickets tool.
+type ListTicketsInput struct {
+	Page     int `json:"page,omitempty"      description:"Page number, starting at 1"`
+	PageSize int `json:"page_size,omitempty" description:"Tickets per page (default 10, max 100)"`
+}
+
+// toolListTickets implements the list_tickets MCP tool.
+func (app *App) toolListTickets(ctx context.Context, req *mcp.CallToolRequest, in ListTicketsInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+
+	path := "/api/user/tickets"
+	if in.Page > 0 || in.PageSize > 0 {
+		path += fmt.Sprintf("?page=%d&pageSize=%d", in.Page, in.PageSize)
+	}
+	var resp ticketListResponse
+	if err := app.center.Get(path, &resp); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+
+	out := make([]ticketOutput, 0, len(resp.Items))
+	for _, t := range resp.Items {
+		row := ticketOutput{
+			ID:          t.ID,
+			Status:      t.Status,
+			Summary:     t.Content,
+			Unread:      t.UserUnread,
+			LastReplyBy: t.LastReplyBy,
+			CreatedAt:   formatUnix(t.CreatedAt),
+		}
+		if t.LastReplyAt != nil {
+			row.LastReplyAt = formatUnix(*t.LastReplyAt)
+		}
+		out = append(out, row)
+	}
+	return successResult(map[string]any{
+		"tickets": out,
+		"page":    resp.Pagination.Page,
+		"total":   resp.Pagination.Total,
+	}), nil, nil
+}
+
+// GetTicketInput is the input schema for the get_ticket tool.
+type GetTicketInput struct {
+	ID uint64 `json:"id" description:"Ticket ID, from list_tickets"`
+}
+
+// toolGetTicket implements the get_ticket MCP tool. Reading a ticket marks
+// its replies as read.
+func (app *App) toolGetTicket(ctx context.Context, req *mcp.CallToolRequest, in GetTicketInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+	if in.ID == 0 {
+		return errorResult("id is required, call list_tickets to find it"), nil, nil
+	}
+
+	var t ticketDetailResponse
+	if err := app.center.Get(fmt.Sprintf("/api/user/tickets/%d", in.ID), &t); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+
+	replies := make([]ticketReplyOutput, 0, len(t.Replies))
+	for _, r := range t.Replies {
+		replies = append(replies, ticketReplyOutput{
+			From:      r.SenderType,
+			Name:      r.SenderName,
+			Content:   r.Content,
+			CreatedAt: formatUnix(r.CreatedAt),
+		})
+	}
+	out := map[string]any{
+		"id":         t.ID,
+		"status":     t.Status,
+		"content":    t.Content,
+		"created_at": formatUnix(t.CreatedAt),
+		"replies":    replies,
+	}
+	if t.ResolvedAt != nil {
+		out["resolved_at"] = formatUnix(*t.ResolvedAt)
+	}
+	return successResult(out), nil, nil
+}
+
+// ReplyTicketInput is the input schema for the reply_ticket tool.
+type ReplyTicketInput struct {
+	ID      uint64 `json:"id"      description:"Ticket ID, from list_tickets"`
+	Content string `json:"content" description:"Reply text (max 2000 characters)"`
+}
+
+// toolReplyTicket implements the reply_ticket MCP tool. Replying to a
+// resolved ticket reopens it; closed tickets reject replies.
+func (app *App) toolReplyTicket(ctx context.Context, req *mcp.CallToolRequest, in ReplyTicketInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+	if in.ID == 0 || in.Content == "" {
+		return errorResult("id and content are required"), nil, nil
+	}
+	if len([]rune(in.Content)) > maxReplyContent {
+		return errorResult(fmt.Sprintf("content too long (max %d characters)", maxReplyContent)), nil, nil
+	}
+
+	body := map[string]string{"content": in.Content}
+	if err := app.center.Post(fmt.Sprintf("/api/user/tickets/%d/reply", in.ID), body, nil); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+	return successResult(map[string]any{"replied": in.ID}), nil, nil
+}
+
+// ReportIssueInput is the input schema for the report_issue tool.
+type ReportIssueInput struct {
+	Content string `json:"content" description:"Description of the problem: what happened, what was expected, steps to reproduce"`
+}
+
+// createTicketRequest is the body of POST /api/user/ticket.
+type createTicketRequest struct {
+	Content    string `json:"content"`
+	FeedbackID string `json:"feedbackId"`
+	OS         string `json:"os"`
+	AppVersion string `json:"app_version"`
+	Source     string `json:"source"`
+	SubmitTime string `json:"submit_time"`
+	VPNState   string `json:"vpn_state"`
+}
+
+// newFeedbackID returns a random UUIDv4, the ID format the apps use to
+// correlate tickets with uploaded logs.
+func newFeedbackID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// issueContent appends the daemon status snapshot to the user's description,
+// trimming the description so the whole report fits Center's limit.
+func issueContent(desc string, status map[string]any) string {
+	statusJSON, _ := json.MarshalIndent(status, "", "  ")
+	suffix := "\n\n--- daemon status (k2-mcp) ---\n" + string(statusJSON)
+	room := maxTicketContent - len([]rune(suffix))
+	if r := []rune(desc); len(r) > room {
+		desc = string(r[:room-1]) + "…"
+	}
+	return desc + suffix
+}
+
+// toolReportIssue implements the report_issue MCP tool: file a support
+// ticket with the current daemon status attached.
+func (app *App) toolReportIssue(ctx context.Context, req *mcp.CallToolRequest, in ReportIssueInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+	if in.Content == "" {
+		return errorResult("content is required"), nil, nil
+	}
+
+	feedbackID, err := newFeedbackID()
+	if err != nil {
+		return errorResult(fmt.Sprintf("generate feedback id: %s", err.Error())), nil, nil
+	}
+	status := app.statusSnapshot()
+	state, _ := status["state"].(string)
+
+	body := createTicketRequest{
+		Content:    issueContent(in.Content, status),
+		FeedbackID: feedbackID,
+		OS:         runtime.GOOS,
+		AppVersion: serverVersion,
+		Source:     "k2-mcp",
+		SubmitTime: time.Now().UTC().Format(time.RFC3339),
+		VPNState:   state,
+	}
+	if err := app.center.Post("/api/user/ticket", body, nil); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+	return successResult(map[string]any{
+		"submitted":   true,
+		"feedback_id": feedbackID,
+		"status":      status,
+		"message":     "Issue reported. Call list_tickets later to follow replies from support.",
+	}), nil, nil
+}
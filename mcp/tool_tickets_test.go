@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestToolListTickets(t *testing.T) {
+	replied := int64(1767225600)
+	list := ticketListResponse{Items: []ticketEntry{
+		{ID: 9, Content: "cannot connect", Status: "open", UserUnread: 2, LastReplyAt: &replied, LastReplyBy: "support", CreatedAt: 1767139200},
+	}}
+	list.Pagination.Page, list.Pagination.Total = 2, 11
+	f := &fakeCenter{routes: map[string]any{"GET /api/user/tickets": list}}
+	app := newFakeCenterApp(t, f)
+
+	result, _, _ := app.toolListTickets(context.Background(), nil, ListTicketsInput{Page: 2})
+	if result.IsError {
+		t.Fatalf("expected success, got error: %s", textContent(t, result))
+	}
+	if f.calls[0].Query != "page=2&pageSize=0" {
+		t.Errorf("unexpected query %q", f.calls[0].Query)
+	}
+	var out struct {
+		Tickets []ticketOutput `json:"tickets"`
+		Total   int64          `json:"total"`
+	}
+	json.Unmarshal([]byte(textContent(t, result)), &out)
+	if out.Total != 11 || len(out.Tickets) != 1 {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+	if got := out.Tickets[0]; got.Unread != 2 || got.LastReplyAt != "2026-01-01T00:00:00Z" || got.CreatedAt != "2025-12-31T00:00:00Z" {
+		t.Errorf("unexpected ticket: %+v", got)
+	}
+}
+
+func TestToolGetTicketAndReply(t *testing.T) {
+	detail := ticketDetailResponse{ID: 9, Content: "cannot connect", Status: "resolved", CreatedAt: 1767139200}
+	detail.Replies = append(detail.Replies, struct {
+		SenderType string `json:"senderType"`
+		SenderName string `json:"senderName"`
+		Content    string `json:"content"`
+		CreatedAt  int64  `json:"createdAt"`
+	}{SenderType: "admin", SenderName: "Kaitu Support", Content: "try again", CreatedAt: 1767225600})
+	f := &fakeCenter{routes: map[string]any{
+		"GET /api/user/tickets/9":        detail,
+		"POST /api/user/tickets/9/reply": nil,
+	}}
+	app := newFakeCenterApp(t, f)
+	ctx := context.Background()
+
+	result, _, _ := app.toolGetTicket(ctx, nil, GetTicketInput{ID: 9})
+	if result.IsError {
+		t.Fatalf("get_ticket: %s", textContent(t, result))
+	}
+	var out struct {
+		Replies []ticketReplyOutput `json:"replies"`
+	}
+	json.Unmarshal([]byte(textContent(t, result)), &out)
+	if len(out.Replies) != 1 || out.Replies[0].From != "admin" || out.Replies[0].Content != "try again" {
+		t.Errorf("unexpected replies: %+v", out.Replies)
+	}
+
+	result, _, _ = app.toolReplyTicket(ctx, nil, ReplyTicketInput{ID: 9, Content: "still broken"})
+	if result.IsError {
+		t.Fatalf("reply_ticket: %s", textContent(t, result))
+	}
+	if last := f.calls[len(f.calls)-1]; last.Body["content"] != "still broken" {
+		t.Errorf("unexpected reply body: %+v", last.Body)
+	}
+
+	result, _, _ = app.toolReplyTicket(ctx, nil, ReplyTicketInput{ID: 9, Content: strings.Repeat("x", maxReplyContent+1)})
+	if !result.IsError {
+		t.Error("expected error for an over-long reply")
+	}
+}
+
+func TestToolReportIssue_AttachesStatus(t *testing.T) {
+	daemonSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeDaemonEnvelope(w, DaemonStatus{State: "error", Error: &DaemonStatusError{Code: 502, Message: "handshake timeout"}})
+	}))
+	defer daemonSrv.Close()
+
+	f := &fakeCenter{routes: map[string]any{"POST /api/user/ticket": nil}}
+	app := newFakeCenterApp(t, f)
+	app.daemon = &DaemonClient{Addr: daemonSrv.URL}
+
+	result, _, _ := app.toolReportIssue(context.Background(), nil, ReportIssueInput{Content: "drops every few minutes"})
+	if result.IsError {
+		t.Fatalf("expected success, got error: %s", textContent(t, result))
+	}
+
+	body := f.calls[0].Body
+	content, _ := body["content"].(string)
+	if !strings.HasPrefix(content, "drops every few minutes") || !strings.Contains(content, "handshake timeout") {
+		t.Errorf("status not attached to content: %q", content)
+	}
+	if body["vpn_state"] != "error" || body["source"] != "k2-mcp" {
+		t.Errorf("unexpected ticket meta: %+v", body)
+	}
+	uuidRe := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if id, _ := body["feedbackId"].(string); !uuidRe.MatchString(id) {
+		t.Errorf("feedbackId %q is not a UUIDv4", id)
+	}
+}
+
+func TestIssueContent_FitsLimit(t *testing.T) {
+	got := issueContent(strings.Repeat("日", maxTicketContent), map[string]any{"state": "connected"})
+	if n := len([]rune(got)); n > maxTicketContent {
+		t.Errorf("content is %d runes, limit %d", n, maxTicketContent)
+	}
+	if !strings.HasSuffix(got, "}") {
+		t.Error("status block must survive truncation")
+	}
+}
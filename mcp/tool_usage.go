@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultUsageDays = 30
+	maxUsageDays     = 60 // Center keeps per-device traffic for two months
+	usageTopDevices  = 5
+	// usageWarnRatio of the monthly threshold triggers a warning.
+	usageWarnRatio = 0.8
+)
+
+// userTrafficDay is one (day, device) row of GET /api/user/traffic.
+type userTrafficDay struct {
+	Date    string `json:"date"`
+	UDID    string `json:"udid"`
+	RxBytes int64  `json:"rxBytes"`
+	TxBytes int64  `json:"txBytes"`
+}
+
+// userTrafficDevice is one device total of GET /api/user/traffic.
+type userTrafficDevice struct {
+	UDID       string `json:"udid"`
+	Remark     string `json:"remark"`
+	TotalBytes int64  `json:"totalBytes"`
+}
+
+// userTrafficResponse is the data field returned by GET /api/user/traffic.
+type userTrafficResponse struct {
+	Since                 string              `json:"since"`
+	Until                 string              `json:"until"`
+	RxBytes               int64               `json:"rxBytes"`
+	TxBytes               int64               `json:"txBytes"`
+	TotalBytes            int64               `json:"totalBytes"`
+	Month                 string              `json:"month"`
+	MonthBytes            int64               `json:"monthBytes"`
+	MonthlyThresholdBytes int64               `json:"monthlyThresholdBytes"`
+	Devices               []userTrafficDevice `json:"devices"`
+	Daily                 []userTrafficDay    `json:"daily"`
+}
+
+// UsageInput is the input schema for the usage tool.
+type UsageInput struct {
+	Days int `json:"days,omitempty" description:"Number of days to report, including today (default 30, max 60)"`
+}
+
+// usageDevice is one top device as returned to the MCP client.
+type usageDevice struct {
+	UDID    string `json:"udid"`
+	Remark  string `json:"remark,omitempty"`
+	Total   string `json:"total"`
+	Bytes   int64  `json:"bytes"`
+	Current bool   `json:"current"`
+}
+
+// usageDay is one day's total across devices.
+type usageDay struct {
+	Date  string `json:"date"`
+	Bytes int64  `json:"bytes"`
+}
+
+// usageOutput is the shape returned to the MCP client.
+type usageOutput struct {
+	Since           string        `json:"since"`
+	Until           string        `json:"until"`
+	Total           string        `json:"total"`
+	Download        string        `json:"download"`
+	Upload          string        `json:"upload"`
+	TotalBytes      int64         `json:"total_bytes"`
+	TopDevices      []usageDevice `json:"top_devices"`
+	Daily           []usageDay    `json:"daily"`
+	Month           string        `json:"month"`
+	MonthTotal      string        `json:"month_total"`
+	MonthThreshold  string        `json:"month_threshold,omitempty"`
+	MonthPercent    float64       `json:"month_percent,omitempty"`
+	Warning         string        `json:"warning,omitempty"`
+	DevicesReported int           `json:"devices_reported"`
+}
+
+// formatBytes renders n in binary units, e.g. "1.5 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// toolUsage implements the usage MCP tool.
+func (app *App) toolUsage(ctx context.Context, req *mcp.CallToolRequest, in UsageInput) (*mcp.CallToolResult, any, error) {
+	if !app.session.LoggedIn() {
+		return errorResult("not logged in, please call login first"), nil, nil
+	}
+	days := in.Days
+	if days <= 0 {
+		days = defaultUsageDays
+	}
+	if days > maxUsageDays {
+		return errorResult(fmt.Sprintf("days must be at most %d", maxUsageDays)), nil, nil
+	}
+
+	var resp userTrafficResponse
+	if err := app.center.Get("/api/user/traffic?days="+strconv.Itoa(days), &resp); err != nil {
+		return app.handleCenterError(err), nil, nil
+	}
+
+	out := usageOutput{
+		Since:           resp.Since,
+		Until:           resp.Until,
+		Total:           formatBytes(resp.TotalBytes),
+		Download:        formatBytes(resp.RxBytes),
+		Upload:          formatBytes(resp.TxBytes),
+		TotalBytes:      resp.TotalBytes,
+		TopDevices:      []usageDevice{},
+		Daily:           []usageDay{},
+		Month:           resp.Month,
+		MonthTotal:      formatBytes(resp.MonthBytes),
+		DevicesReported: len(resp.Devices),
+	}
+
+	current := app.center.UDID()
+	for i, d := range resp.Devices {
+		if i == usageTopDevices {
+			break
+		}
+		out.TopDevices = append(out.TopDevices, usageDevice{
+			UDID:    d.UDID,
+			Remark:  d.Remark,
+			Total:   formatBytes(d.TotalBytes),
+			Bytes:   d.TotalBytes,
+			Current: d.UDID == current,
+		})
+	}
+
+	// Daily rows are per device and sorted by date; fold them per day.
+	for _, d := range resp.Daily {
+		n := len(out.Daily)
+		if n == 0 || out.Daily[n-1].Date != d.Date {
+			out.Daily = append(out.Daily, usageDay{Date: d.Date})
+			n++
+		}
+		out.Daily[n-1].Bytes += d.RxBytes + d.TxBytes
+	}
+
+	if resp.MonthlyThresholdBytes > 0 {
+		ratio := float64(resp.MonthBytes) / float64(resp.MonthlyThresholdBytes)
+		out.MonthThreshold = formatBytes(resp.MonthlyThresholdBytes)
+		out.MonthPercent = math.Round(ratio*1000) / 10
+		switch {
+		case ratio >= 1:
+			out.Warning = fmt.Sprintf("this month's traffic (%s) exceeds the fair-use threshold of %s; the account may be reviewed for abuse",
+				out.MonthTotal, out.MonthThreshold)
+		case ratio >= usageWarnRatio:
+			out.Warning = fmt.Sprintf("this month's traffic (%s) is %.0f%% of the fair-use threshold of %s",
+				out.MonthTotal, ratio*100, out.MonthThreshold)
+		}
+	}
+
+	return successResult(out), nil, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.5 KiB"},
+		{5 << 30, "5.0 GiB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.in); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToolUsage(t *testing.T) {
+	const gib = int64(1) << 30
+	devices := make([]userTrafficDevice, 0, 7)
+	for i := 7; i > 0; i-- {
+		devices = append(devices, userTrafficDevice{UDID: "dev-" + string(rune('0'+i)), TotalBytes: int64(i) * gib})
+	}
+	f := &fakeCenter{routes: map[string]any{
+		"GET /api/user/traffic": userTrafficResponse{
+			Since: "2026-10-12", Until: "2026-10-18",
+			RxBytes: 20 * gib, TxBytes: 8 * gib, TotalBytes: 28 * gib,
+			Month: "2026-10", MonthBytes: 85 * gib, MonthlyThresholdBytes: 100 * gib,
+			Devices: devices,
+			Daily: []userTrafficDay{
+				{Date: "2026-10-17", UDID: "dev-1", RxBytes: 10, TxBytes: 1},
+				{Date: "2026-10-17", UDID: "dev-2", RxBytes: 20, TxBytes: 2},
+				{Date: "2026-10-18", UDID: "dev-1", RxBytes: 5},
+			},
+		},
+	}}
+	app := newFakeCenterApp(t, f)
+	app.center.SetUDID("dev-6")
+
+	result, _, _ := app.toolUsage(context.Background(), nil, UsageInput{Days: 7})
+	if result.IsError {
+		t.Fatalf("usage: %s", textContent(t, result))
+	}
+	if f.calls[0].Query != "days=7" {
+		t.Errorf("expected days=7, got %q", f.calls[0].Query)
+	}
+
+	var out usageOutput
+	if err := json.Unmarshal([]byte(textContent(t, result)), &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if out.Total != "28.0 GiB" || out.DevicesReported != 7 {
+		t.Errorf("unexpected totals: %+v", out)
+	}
+	if len(out.TopDevices) != usageTopDevices || out.TopDevices[0].UDID != "dev-7" || !out.TopDevices[1].Current {
+		t.Errorf("unexpected top devices: %+v", out.TopDevices)
+	}
+	if len(out.Daily) != 2 || out.Daily[0].Bytes != 33 || out.Daily[1].Bytes != 5 {
+		t.Errorf("daily not folded per day: %+v", out.Daily)
+	}
+	if out.MonthPercent != 85 || !strings.Contains(out.Warning, "85%") {
+		t.Errorf("expected an 85%% warning, got %v / %q", out.MonthPercent, out.Warning)
+	}
+
+	result, _, _ = app.toolUsage(context.Background(), nil, UsageInput{Days: 90})
+	if !result.IsError {
+		t.Error("expected error beyond the retention window")
+	}
+}
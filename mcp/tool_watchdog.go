@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// The watchdog follows daemon state after a connect. When the tunnel goes
+// into error it reconnects to the same server with exponential backoff, and
+// after maxRetries failed attempts fails over to the best other server.
+// Every action is sent to the session that enabled it as an MCP log
+// notification (logger "k2-watchdog") and kept for the watchdog tool.
+
+var (
+	watchdogInterval    = 5 * time.Second
+	watchdogBackoffBase = 2 * time.Second
+	watchdogBackoffMax  = time.Minute
+)
+
+const (
+	defaultWatchdogRetries = 3
+	maxWatchdogRetries     = 10
+	watchdogEventsKept     = 20
+	watchdogLogger         = "k2-watchdog"
+)
+
+// watchdogEvent is one logged watchdog action.
+type watchdogEvent struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// watchdog holds the watchdog goroutine and the server it keeps connected.
+// Zero value is ready to use (disabled).
+type watchdog struct {
+	mu         sync.Mutex
+	cancel     context.CancelFunc
+	owner      *mcp.ServerSession // receives log notifications; nil in tests
+	maxRetries int
+	// target is the last server connected through k2-mcp; nil after
+	// disconnect, which leaves nothing to watch.
+	target *Server
+	events []watchdogEvent
+}
+
+func (w *watchdog) setTarget(s *Server) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if s == nil {
+		w.target = nil
+		return
+	}
+	copied := *s
+	w.target = &copied
+}
+
+func (w *watchdog) currentTarget() *Server {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.target
+}
+
+// stopLocked cancels the goroutine. Caller holds mu.
+func (w *watchdog) stopLocked() {
+	if w.cancel != nil {
+		w.cancel()
+		w.cancel = nil
+	}
+	w.owner = nil
+}
+
+// watchdogBackoff is the wait after the n-th consecutive failure.
+func watchdogBackoff(n int) time.Duration {
+	d := watchdogBackoffBase
+	for i := 1; i < n && d < watchdogBackoffMax; i++ {
+		d *= 2
+	}
+	return min(d, watchdogBackoffMax)
+}
+
+// startWatchdog (re)starts the watchdog owned by ss. It stops by itself
+// when ss closes.
+func (app *App) startWatchdog(ss *mcp.ServerSession, maxRetries int) {
+	w := &app.watchdog
+	ctx, cancel := context.WithCancel(context.Background())
+	w.mu.Lock()
+	w.stopLocked()
+	w.cancel = cancel
+	w.owner = ss
+	w.maxRetries = maxRetries
+	w.mu.Unlock()
+
+	go app.runWatchdog(ctx, maxRetries)
+	if ss != nil {
+		go func() {
+			ss.Wait() //nolint:errcheck // only the close matters
+			w.mu.Lock()
+			if w.owner == ss {
+				w.stopLocked()
+			}
+			w.mu.Unlock()
+		}()
+	}
+}
+
+func (app *App) stopWatchdog() {
+	w := &app.watchdog
+	w.mu.Lock()
+	w.stopLocked()
+	w.mu.Unlock()
+}
+
+// watchdogLog records an action and notifies the owning session.
+func (app *App) watchdogLog(level mcp.LoggingLevel, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("[watchdog] %s", msg)
+
+	w := &app.watchdog
+	w.mu.Lock()
+	w.events = append(w.events, watchdogEvent{
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		Level:   string(level),
+		Message: msg,
+	})
+	if len(w.events) > watchdogEventsKept {
+		w.events = w.events[len(w.events)-watchdogEventsKept:]
+	}
+	owner := w.owner
+	w.mu.Unlock()
+
+	if owner != nil {
+		owner.Log(context.Background(), &mcp.LoggingMessageParams{ //nolint:errcheck // best-effort
+			Level:  level,
+			Logger: watchdogLogger,
+			Data:   msg,
+		})
+	}
+}
+
+// runWatchdog polls the daemon until ctx is cancelled.
+func (app *App) runWatchdog(ctx context.Context, maxRetries int) {
+	failures := 0
+	daemonDown := false
+	wait := watchdogInterval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = watchdogInterval
+
+		target := app.watchdog.currentTarget()
+		if target == nil {
+			failures = 0
+			continue
+		}
+
+		st, err := app.daemon.Status()
+		if err != nil {
+			// Nothing to reconnect through until the daemon is back.
+			if !daemonDown {
+				app.watchdogLog("warning", "k2 daemon unreachable: %v", err)
+				daemonDown = true
+			}
+			continue
+		}
+		if daemonDown {
+			app.watchdogLog("info", "k2 daemon is reachable again")
+			daemonDown = false
+		}
+
+		switch st.State {
+		case "connected":
+			if failures > 0 {
+				app.watchdogLog("info", "tunnel to %s is up again", target.Name)
+			}
+			failures = 0
+			continue
+		case "disconnected":
+			// Stopped outside k2-mcp (desktop app, k2 down): respect it.
+			app.watchdogLog("info", "tunnel to %s was disconnected elsewhere; standing by until the next connect", target.Name)
+			app.watchdog.setTarget(nil)
+			failures = 0
+			continue
+		case "error":
+		default:
+			continue // connecting / reconnecting: the daemon is working on it
+		}
+
+		reason := "error"
+		if st.Error != nil {
+			reason = fmt.Sprintf("error %d: %s", st.Error.Code, st.Error.Message)
+		}
+		failures++
+
+		if failures <= maxRetries {
+			app.watchdogLog("warning", "tunnel to %s is down (%s); reconnecting, attempt %d/%d",
+				target.Name, reason, failures, maxRetries)
+			if res := app.connectServer(target, nil); res.IsError {
+				app.watchdogLog("error", "reconnect to %s failed: %s", target.Name, resultText(res))
+			}
+			wait = watchdogBackoff(failures)
+			continue
+		}
+
+		next, err := app.failoverServer(ctx, target)
+		if err != nil {
+			app.watchdogLog("error", "failover from %s failed: %v; retrying %s later", target.Name, err, target.Name)
+			failures = 0
+			wait = watchdogBackoffMax
+			continue
+		}
+		app.watchdogLog("warning", "failing over from %s to %s after %d failed reconnects",
+			target.Name, next.Name, maxRetries)
+		if res := app.connectServer(next, nil); res.IsError {
+			app.watchdogLog("error", "connect to %s failed: %s", next.Name, resultText(res))
+		}
+		failures = 0
+	}
+}
+
+// failoverServer picks the best reachable server other than failed.
+func (app *App) failoverServer(ctx context.Context, failed *Server) (*Server, error) {
+	servers, err := app.fetchServers()
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]Server, 0, len(servers))
+	for _, s := range servers {
+		if s.ID != failed.ID {
+			candidates = append(candidates, s)
+		}
+	}
+	results := app.probeServers(ctx, candidates, defaultProbeTimeout)
+
+	var best *Server
+	bestVal := -1.0
+	for i := range candidates {
+		r := results[candidates[i].ID]
+		if !r.Reachable {
+			continue
+		}
+		if v := bestScore(candidates[i], r); v > bestVal {
+			best, bestVal = &candidates[i], v
+		}
+	}
+	if best == nil {
+		return nil, errors.New("no other server is reachable")
+	}
+	return best, nil
+}
+
+// resultText returns the first text content of a tool result.
+func resultText(res *mcp.CallToolResult) string {
+	if len(res.Content) > 0 {
+		if tc, ok := res.Content[0].(*mcp.TextContent); ok {
+			return tc.Text
+		}
+	}
+	return ""
+}
+
+// WatchdogInput is the input schema for the watchdog tool.
+type WatchdogInput struct {
+	Enabled    *bool `json:"enabled,omitempty"     description:"Turn the watchdog on (true) or off (false); omit to only report its state"`
+	MaxRetries int   `json:"max_retries,omitempty" description:"Reconnect attempts to the same server before failing over (default 3, max 10)"`
+}
+
+// toolWatchdog implements the watchdog MCP tool.
+func (app *App) toolWatchdog(ctx context.Context, req *mcp.CallToolRequest, in WatchdogInput) (*mcp.CallToolResult, any, error) {
+	if in.Enabled != nil {
+		if !*in.Enabled {
+			app.stopWatchdog()
+		} else {
+			if !app.session.LoggedIn() {
+				return errorResult("not logged in, please call login first"), nil, nil
+			}
+			retries := in.MaxRetries
+			if retries <= 0 {
+				retries = defaultWatchdogRetries
+			}
+			if retries > maxWatchdogRetries {
+				return errorResult(fmt.Sprintf("max_retries must be at most %d", maxWatchdogRetries)), nil, nil
+			}
+			var ss *mcp.ServerSession
+			if req != nil {
+				ss = req.Session
+			}
+			app.startWatchdog(ss, retries)
+		}
+	}
+
+	w := &app.watchdog
+	w.mu.Lock()
+	out := map[string]any{
+		"enabled": w.cancel != nil,
+		"events":  append([]watchdogEvent{}, w.events...),
+	}
+	if w.cancel != nil {
+		out["max_retries"] = w.maxRetries
+	}
+	if w.target != nil {
+		out["server"] = w.target.Name
+	} else if w.cancel != nil {
+		out["message"] = "watching; acts once a server is connected with connect or connect_best"
+	}
+	w.mu.Unlock()
+	return successResult(out), nil, nil
+}
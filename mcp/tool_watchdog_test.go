@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// watchdogDaemon reports state and moves to afterUp on every up.
+type watchdogDaemon struct {
+	mu      sync.Mutex
+	state   string
+	afterUp string
+	ups     []string
+}
+
+func (d *watchdogDaemon) serve(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			return
+		}
+		var body struct {
+			Action string `json:"action"`
+			Params struct {
+				Config struct {
+					Routes []DaemonRoute `json:"routes"`
+				} `json:"config"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		switch body.Action {
+		case "up":
+			routes := body.Params.Config.Routes
+			d.ups = append(d.ups, routes[len(routes)-1].Via)
+			d.state = d.afterUp
+		case "status":
+			st := DaemonStatus{State: d.state}
+			if d.state == "error" {
+				st.Error = &DaemonStatusError{Code: 503, Message: "server unreachable"}
+			}
+			writeDaemonEnvelope(w, st)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func (d *watchdogDaemon) upCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.ups)
+}
+
+func fastWatchdog(t *testing.T) {
+	t.Helper()
+	interval, base, max := watchdogInterval, watchdogBackoffBase, watchdogBackoffMax
+	watchdogInterval, watchdogBackoffBase, watchdogBackoffMax = 5*time.Millisecond, 5*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { watchdogInterval, watchdogBackoffBase, watchdogBackoffMax = interval, base, max })
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func watchdogEvents(app *App) string {
+	app.watchdog.mu.Lock()
+	defer app.watchdog.mu.Unlock()
+	msgs := make([]string, 0, len(app.watchdog.events))
+	for _, e := range app.watchdog.events {
+		msgs = append(msgs, e.Message)
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func TestWatchdogBackoff(t *testing.T) {
+	fastWatchdog(t)
+	if watchdogBackoff(1) != 5*time.Millisecond || watchdogBackoff(2) != 10*time.Millisecond {
+		t.Errorf("unexpected backoff: %v, %v", watchdogBackoff(1), watchdogBackoff(2))
+	}
+	if watchdogBackoff(10) != watchdogBackoffMax {
+		t.Errorf("backoff must be capped, got %v", watchdogBackoff(10))
+	}
+}
+
+func TestWatchdog_ReconnectsSameServer(t *testing.T) {
+	fastWatchdog(t)
+	d := &watchdogDaemon{state: "disconnected", afterUp: "connected"}
+	app, _ := newProbeTestApp(t, []tunnelEntry{
+		{ID: 1, Name: "Tokyo", ServerURL: "k2v5://jp1.example.com"},
+	})
+	app.daemon = &DaemonClient{Addr: d.serve(t)}
+	ctx := context.Background()
+
+	result, _, _ := app.toolConnect(ctx, nil, ConnectInput{ServerID: 1})
+	if result.IsError {
+		t.Fatalf("connect: %s", textContent(t, result))
+	}
+	enabled := true
+	result, _, _ = app.toolWatchdog(ctx, nil, WatchdogInput{Enabled: &enabled})
+	if result.IsError || !strings.Contains(textContent(t, result), `"server":"Tokyo"`) {
+		t.Fatalf("watchdog: %s", textContent(t, result))
+	}
+	t.Cleanup(app.stopWatchdog)
+
+	// The tunnel drops.
+	d.mu.Lock()
+	d.state = "error"
+	d.mu.Unlock()
+
+	waitFor(t, "recovery", func() bool { return strings.Contains(watchdogEvents(app), "up again") })
+	if n := d.upCount(); n != 2 {
+		t.Errorf("expected one reconnect after the initial connect, got %d ups", n)
+	}
+	if !strings.Contains(watchdogEvents(app), "attempt 1/3") {
+		t.Errorf("reconnect not logged: %s", watchdogEvents(app))
+	}
+}
+
+func TestWatchdog_FailsOverAfterRetries(t *testing.T) {
+	fastWatchdog(t)
+	up := listenTCP(t)
+	d := &watchdogDaemon{state: "connecting", afterUp: "error"}
+	app, _ := newProbeTestApp(t, []tunnelEntry{
+		{ID: 1, Name: "Broken", ServerURL: "k2v5://" + up, RecommendScore: 1},
+		{ID: 2, Name: "Backup", ServerURL: "k2v5://" + up, RecommendScore: 0.5},
+		{ID: 3, Name: "Dead", ServerURL: "k2v5://" + closedAddr(t), RecommendScore: 0.9},
+	})
+	app.daemon = &DaemonClient{Addr: d.serve(t)}
+	ctx := context.Background()
+
+	app.toolConnect(ctx, nil, ConnectInput{ServerID: 1})
+	enabled := true
+	app.toolWatchdog(ctx, nil, WatchdogInput{Enabled: &enabled, MaxRetries: 2})
+	t.Cleanup(app.stopWatchdog)
+
+	// Initial connect, two reconnects to Broken, then Backup (Dead is unreachable).
+	waitFor(t, "failover", func() bool { return d.upCount() >= 4 })
+	app.stopWatchdog()
+
+	if !strings.Contains(watchdogEvents(app), "from Broken to Backup") {
+		t.Errorf("unexpected failover: %s", watchdogEvents(app))
+	}
+	if target := app.watchdog.currentTarget(); target == nil || target.ID != 2 {
+		t.Errorf("expected the watchdog to follow Backup, got %+v", target)
+	}
+}
+
+func TestWatchdog_DisconnectStandsDown(t *testing.T) {
+	fastWatchdog(t)
+	d := &watchdogDaemon{state: "error", afterUp: "error"}
+	app, _ := newProbeTestApp(t, []tunnelEntry{{ID: 1, Name: "Tokyo", ServerURL: "k2v5://jp1.example.com"}})
+	app.daemon = &DaemonClient{Addr: d.serve(t)}
+	ctx := context.Background()
+
+	app.toolConnect(ctx, nil, ConnectInput{ServerID: 1})
+	app.toolDisconnect(ctx, nil, nil)
+	enabled := true
+	app.toolWatchdog(ctx, nil, WatchdogInput{Enabled: &enabled})
+	t.Cleanup(app.stopWatchdog)
+
+	time.Sleep(50 * time.Millisecond)
+	if n := d.upCount(); n != 1 {
+		t.Errorf("watchdog must not reconnect after disconnect, got %d ups", n)
+	}
+
+	disabled := false
+	result, _, _ := app.toolWatchdog(ctx, nil, WatchdogInput{Enabled: &disabled})
+	if !strings.Contains(textContent(t, result), `"enabled":false`) {
+		t.Errorf("expected disabled, got %s", textContent(t, result))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultHTTPAddr is where the opt-in HTTP transport listens. Loopback only:
+// remote orchestrators reach it through an SSH tunnel, never directly.
+const defaultHTTPAddr = "127.0.0.1:1778"
+
+// httpTokenFile holds the bearer token for the HTTP transport, next to
+// mcp-session.json. Clients on the same machine read it from there.
+const httpTokenFile = "mcp-http-token"
+
+// loadOrCreateHTTPToken returns the token in dir/mcp-http-token, generating a
+// random 256-bit one (0600) on first use. Delete the file to rotate.
+func loadOrCreateHTTPToken(dir string) (string, error) {
+	path := filepath.Join(dir, httpTokenFile)
+	b, err := os.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(b)); token != "" {
+			return token, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("http token read: %w", err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("http token generate: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("http token mkdir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("http token write: %w", err)
+	}
+	return token, nil
+}
+
+// requireBearer rejects requests without "Authorization: Bearer <token>".
+func requireBearer(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="k2-mcp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newHTTPHandler serves the streamable HTTP transport at /mcp. Every client
+// session is attached to the same server, and so to the same App: login,
+// server cache and k2://status subscriptions are shared across agents.
+func newHTTPHandler(server *mcp.Server, token string) http.Handler {
+	mcpHandler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+		return server
+	}, nil)
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", requireBearer(token, mcpHandler))
+	return mux
+}
+
+// serveHTTP runs the HTTP transport on addr until ctx is cancelled.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("http listen: %w", err)
+	}
+	if host, _, _ := net.SplitHostPort(ln.Addr().String()); !net.ParseIP(host).IsLoopback() {
+		log.Printf("WARNING: k2-mcp HTTP transport is listening on non-loopback %s", ln.Addr())
+	}
+	log.Printf("k2-mcp HTTP transport listening on http://%s/mcp", ln.Addr())
+
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx) //nolint:errcheck // best-effort on exit
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestLoadOrCreateHTTPToken(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested")
+
+	token, err := loadOrCreateHTTPToken(dir)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if len(token) != 64 {
+		t.Errorf("expected 64 hex chars, got %d", len(token))
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, httpTokenFile))
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("expected 0600, got %o", perm)
+		}
+	}
+
+	again, err := loadOrCreateHTTPToken(dir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if again != token {
+		t.Error("token must be stable across restarts")
+	}
+}
+
+// bearerTransport adds the Authorization header to every request.
+type bearerTransport struct{ token string }
+
+func (b bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+b.token)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func connectHTTPClient(t *testing.T, endpoint, token string) (*mcp.ClientSession, error) {
+	t.Helper()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "test"}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cs, err := client.Connect(ctx, &mcp.StreamableClientTransport{
+		Endpoint:   endpoint,
+		HTTPClient: &http.Client{Transport: bearerTransport{token: token}},
+		MaxRetries: -1,
+	}, nil)
+	if err == nil {
+		t.Cleanup(func() { cs.Close() })
+	}
+	return cs, err
+}
+
+// newHTTPTestServer serves app over the HTTP transport with token "secret".
+// Standalone SSE streams stay open until the server drops them, so cut
+// client connections before Close or it waits forever.
+func newHTTPTestServer(t *testing.T, app *App) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(newHTTPHandler(newServer(app), "secret"))
+	t.Cleanup(func() {
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+	return srv
+}
+
+func TestHTTPTransport_RejectsMissingToken(t *testing.T) {
+	app := newTestApp(t, "http://127.0.0.1:0")
+	srv := newHTTPTestServer(t, app)
+
+	resp, err := http.Post(srv.URL+"/mcp", "application/json", nil)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", resp.StatusCode)
+	}
+
+	if _, err := connectHTTPClient(t, srv.URL+"/mcp", "wrong"); err == nil {
+		t.Error("expected connect with a wrong token to fail")
+	}
+}
+
+func TestHTTPTransport_SessionsShareApp(t *testing.T) {
+	daemonSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeDaemonEnvelope(w, DaemonStatus{State: "disconnected"})
+	}))
+	defer daemonSrv.Close()
+
+	app := newTestApp(t, "http://127.0.0.1:0")
+	app.daemon = &DaemonClient{Addr: daemonSrv.URL}
+	srv := newHTTPTestServer(t, app)
+
+	a, err := connectHTTPClient(t, srv.URL+"/mcp", "secret")
+	if err != nil {
+		t.Fatalf("connect a: %v", err)
+	}
+	b, err := connectHTTPClient(t, srv.URL+"/mcp", "secret")
+	if err != nil {
+		t.Fatalf("connect b: %v", err)
+	}
+	if a.ID() == b.ID() {
+		t.Fatal("expected two distinct MCP sessions")
+	}
+
+	for name, cs := range map[string]*mcp.ClientSession{"a": a, "b": b} {
+		res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: "status"})
+		if err != nil {
+			t.Fatalf("%s: call status: %v", name, err)
+		}
+		var out map[string]any
+		if err := json.Unmarshal([]byte(textContent(t, res)), &out); err != nil {
+			t.Fatalf("%s: unmarshal: %v", name, err)
+		}
+		if out["state"] != "disconnected" {
+			t.Errorf("%s: expected state='disconnected', got %v", name, out["state"])
+		}
+	}
+}
@@ -51,6 +51,48 @@ export const statsTools: ToolRegistration[] = [
     path: '/app/stats/overview',
   }),
 
+  defineApiTool({
+    name: 'telemetry_overview',
+    description:
+      'Get client connection telemetry overview: daily success rate, time to connect and top failure reasons (from daily rollups).',
+    group: 'stats',
+    params: {
+      range: z.enum(['7d', '30d', '90d']).optional().describe('Time window (default 7d)'),
+    },
+    path: '/app/telemetry/overview',
+  }),
+
+  defineApiTool({
+    name: 'telemetry_breakdown',
+    description:
+      'Get connection success rate and time to connect sliced by one dimension, ordered by attempts.',
+    group: 'stats',
+    params: {
+      dimension: z
+        .enum(['protocol_chain', 'country', 'carrier', 'asn', 'app_version', 'node', 'rules_version'])
+        .describe('Dimension to slice by'),
+      range: z.enum(['7d', '30d', '90d']).optional().describe('Time window (default 7d)'),
+      limit: z.number().optional().describe('Max rows (1-500, default 50)'),
+    },
+    path: '/app/telemetry/breakdown',
+  }),
+
+  defineApiTool({
+    name: 'telemetry_failures',
+    description: 'Get connection failure reasons, optionally for one value of a dimension (e.g. a node).',
+    group: 'stats',
+    params: {
+      dimension: z
+        .enum(['all', 'protocol_chain', 'country', 'carrier', 'asn', 'app_version', 'node', 'rules_version'])
+        .optional()
+        .describe('Dimension (default all)'),
+      value: z.string().optional().describe('Dimension value to filter on'),
+      range: z.enum(['7d', '30d', '90d']).optional().describe('Time window (default 7d)'),
+      limit: z.number().optional().describe('Max reasons (1-200, default 20)'),
+    },
+    path: '/app/telemetry/failures',
+  }),
+
   defineApiTool({
     name: 'survey_stats',
     description: 'Get survey response statistics and aggregates.',
@@ -55,4 +55,84 @@ export const strategyTools: ToolRegistration[] = [
     },
     path: (p) => `/app/strategy/rules/${p.version}`,
   }),
+
+  defineApiTool({
+    name: 'set_strategy_rollout',
+    description:
+      'Set the staged rollout percentage (0-100) of a non-active strategy rule version. Devices are bucketed by hash; an equal-sized control bucket keeps the active version. Starting a rollout stops any other candidate; 0 stops it.',
+    group: 'strategy.write',
+    method: 'PUT',
+    params: {
+      version: z.string().describe('Rule version identifier'),
+      percent: z.number().int().min(0).max(100).describe('Percentage of devices that receive this version'),
+    },
+    path: (p) => `/app/strategy/rules/${p.version}/rollout`,
+  }),
+
+  defineApiTool({
+    name: 'compare_strategy_rule',
+    description:
+      'Compare telemetry outcomes (connection success rate, satisfaction) of a rule version against the active version, per cohort.',
+    group: 'strategy',
+    params: {
+      version: z.string().describe('Candidate rule version identifier'),
+      range: z.enum(['7d', '30d']).optional().describe('Time window (default 7d)'),
+    },
+    path: (p) => `/app/strategy/rules/${p.version}/compare`,
+  }),
+
+  defineApiTool({
+    name: 'list_strategy_cohorts',
+    description: 'List A/B cohorts pinned to strategy rule versions, with device counts.',
+    group: 'strategy',
+    path: '/app/strategy/cohorts',
+  }),
+
+  defineApiTool({
+    name: 'create_strategy_cohort',
+    description: 'Create an A/B cohort whose devices always receive the given rule version.',
+    group: 'strategy.write',
+    method: 'POST',
+    params: {
+      name: z.string().describe('Cohort name (lowercase, digits, - and _; not rollout/control/default)'),
+      version: z.string().describe('Rule version the cohort is pinned to'),
+      note: z.string().optional().describe('Free-form note'),
+    },
+    path: '/app/strategy/cohorts',
+  }),
+
+  defineApiTool({
+    name: 'delete_strategy_cohort',
+    description: 'Delete an A/B cohort; its devices fall back to rollout/active rules.',
+    group: 'strategy.write',
+    method: 'DELETE',
+    params: {
+      id: z.number().describe('Cohort ID'),
+    },
+    path: (p) => `/app/strategy/cohorts/${p.id}`,
+  }),
+
+  defineApiTool({
+    name: 'add_strategy_cohort_devices',
+    description: 'Add devices (by UDID) to an A/B cohort, moving them out of any other cohort.',
+    group: 'strategy.write',
+    method: 'POST',
+    params: {
+      id: z.number().describe('Cohort ID'),
+      udids: z.array(z.string()).min(1).max(500).describe('Device UDIDs'),
+    },
+    path: (p) => `/app/strategy/cohorts/${p.id}/devices`,
+  }),
+
+  defineApiTool({
+    name: 'remove_strategy_cohort_device',
+    description: 'Remove a device from an A/B cohort.',
+    group: 'strategy.write',
+    method: 'DELETE',
+    params: {
+      id: z.number().describe('Cohort ID'),
+      udid: z.string().describe('Device UDID'),
+    },
+    path: (p) => `/app/strategy/cohorts/${p.id}/devices/${p.udid}`,
+  }),
 ]
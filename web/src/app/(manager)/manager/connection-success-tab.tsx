@@ -0,0 +1,323 @@
+"use client";
+
+import { useEffect, useState } from "react";
+import {
+  api,
+  TelemetryBreakdownResponse,
+  TelemetryDimension,
+  TelemetryFailureRow,
+  TelemetryOverviewResponse,
+} from "@/lib/api";
+import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
+import {
+  Table,
+  TableBody,
+  TableCell,
+  TableHead,
+  TableHeader,
+  TableRow,
+} from "@/components/ui/table";
+import {
+  Select,
+  SelectContent,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from "@/components/ui/select";
+
+type Period = "7d" | "30d" | "90d";
+
+const dimensionLabels: Record<TelemetryDimension, string> = {
+  protocol_chain: "协议链",
+  country: "国家",
+  carrier: "运营商",
+  asn: "ASN",
+  app_version: "客户端版本",
+  node: "节点",
+  rules_version: "规则版本",
+};
+
+function formatRate(rate: number): string {
+  return `${(rate * 100).toFixed(1)}%`;
+}
+
+function formatMs(ms: number): string {
+  if (!ms) return "-";
+  return ms >= 1000 ? `${(ms / 1000).toFixed(2)}s` : `${Math.round(ms)}ms`;
+}
+
+function FailureTable({ rows }: { rows: TelemetryFailureRow[] }) {
+  if (rows.length === 0) {
+    return <div className="text-muted-foreground text-center py-4">暂无失败记录</div>;
+  }
+  return (
+    <Table>
+      <TableHeader>
+        <TableRow>
+          <TableHead>失败原因</TableHead>
+          <TableHead className="text-right">次数</TableHead>
+        </TableRow>
+      </TableHeader>
+      <TableBody>
+        {rows.map((item) => (
+          <TableRow key={item.reason}>
+            <TableCell className="font-mono text-sm">{item.reason || "(未上报)"}</TableCell>
+            <TableCell className="text-right">{item.count}</TableCell>
+          </TableRow>
+        ))}
+      </TableBody>
+    </Table>
+  );
+}
+
+export function ConnectionSuccessTab() {
+  const [period, setPeriod] = useState<Period>("7d");
+  const [dimension, setDimension] = useState<TelemetryDimension>("protocol_chain");
+  const [overview, setOverview] = useState<TelemetryOverviewResponse | null>(null);
+  const [breakdown, setBreakdown] = useState<TelemetryBreakdownResponse | null>(null);
+  const [selected, setSelected] = useState<string | null>(null);
+  const [selectedFailures, setSelectedFailures] = useState<TelemetryFailureRow[]>([]);
+  const [loading, setLoading] = useState(true);
+
+  useEffect(() => {
+    setLoading(true);
+    api
+      .getTelemetryOverview(period)
+      .then(setOverview)
+      .catch((err) => console.error("Failed to load telemetry overview:", err))
+      .finally(() => setLoading(false));
+  }, [period]);
+
+  useEffect(() => {
+    setSelected(null);
+    api
+      .getTelemetryBreakdown(dimension, period)
+      .then(setBreakdown)
+      .catch((err) => console.error("Failed to load telemetry breakdown:", err));
+  }, [dimension, period]);
+
+  useEffect(() => {
+    if (selected === null) {
+      setSelectedFailures([]);
+      return;
+    }
+    api
+      .getTelemetryFailures({ dimension, value: selected, range: period })
+      .then((res) => setSelectedFailures(res.reasons))
+      .catch((err) => console.error("Failed to load telemetry failures:", err));
+  }, [dimension, period, selected]);
+
+  if (loading && !overview) {
+    return <div className="text-muted-foreground text-center py-12">加载中...</div>;
+  }
+
+  if (!overview) {
+    return <div className="text-muted-foreground text-center py-12">暂无数据</div>;
+  }
+
+  const { totals } = overview;
+  const timed = totals.connectUnder1s + totals.connectUnder3s + totals.connectOver3s;
+  const distribution = [
+    { label: "< 1s", count: totals.connectUnder1s, className: "bg-green-500" },
+    { label: "1-3s", count: totals.connectUnder3s, className: "bg-yellow-500" },
+    { label: "≥ 3s", count: totals.connectOver3s, className: "bg-red-500" },
+  ];
+
+  return (
+    <div className="space-y-6">
+      {/* Period selector */}
+      <div className="flex justify-end">
+        <Select value={period} onValueChange={(v) => setPeriod(v as Period)}>
+          <SelectTrigger className="w-32">
+            <SelectValue />
+          </SelectTrigger>
+          <SelectContent>
+            <SelectItem value="7d">最近 7 天</SelectItem>
+            <SelectItem value="30d">最近 30 天</SelectItem>
+            <SelectItem value="90d">最近 90 天</SelectItem>
+          </SelectContent>
+        </Select>
+      </div>
+
+      {/* Summary cards */}
+      <div className="grid grid-cols-1 md:grid-cols-4 gap-4">
+        <Card>
+          <CardHeader className="pb-2">
+            <CardTitle className="text-sm font-medium text-muted-foreground">连接次数</CardTitle>
+          </CardHeader>
+          <CardContent>
+            <div className="text-2xl font-bold">{totals.attempts}</div>
+          </CardContent>
+        </Card>
+        <Card>
+          <CardHeader className="pb-2">
+            <CardTitle className="text-sm font-medium text-muted-foreground">成功率</CardTitle>
+          </CardHeader>
+          <CardContent>
+            <div className="text-2xl font-bold text-green-500">{formatRate(totals.successRate)}</div>
+          </CardContent>
+        </Card>
+        <Card>
+          <CardHeader className="pb-2">
+            <CardTitle className="text-sm font-medium text-muted-foreground">失败</CardTitle>
+          </CardHeader>
+          <CardContent>
+            <div className="text-2xl font-bold text-red-500">{totals.failures}</div>
+          </CardContent>
+        </Card>
+        <Card>
+          <CardHeader className="pb-2">
+            <CardTitle className="text-sm font-medium text-muted-foreground">平均建连耗时</CardTitle>
+          </CardHeader>
+          <CardContent>
+            <div className="text-2xl font-bold">{formatMs(totals.avgConnectMs)}</div>
+          </CardContent>
+        </Card>
+      </div>
+
+      {/* Trend — hand-rolled bar chart, same style as the rating tab */}
+      <Card>
+        <CardHeader>
+          <CardTitle>成功率趋势（UTC 日）</CardTitle>
+        </CardHeader>
+        <CardContent>
+          {overview.days.length === 0 ? (
+            <div className="text-muted-foreground text-center py-8">暂无数据</div>
+          ) : (
+            <div className="flex items-end gap-1 h-48 overflow-x-auto">
+              {overview.days.map((item) => {
+                const done = item.successes + item.failures;
+                const okPct = done > 0 ? (item.successes / done) * 100 : 0;
+                const failPct = done > 0 ? (item.failures / done) * 100 : 0;
+                return (
+                  <div
+                    key={item.day}
+                    className="flex-shrink-0 flex flex-col items-center gap-1"
+                    style={{ width: overview.days.length > 30 ? '12px' : '24px' }}
+                    title={`${item.day}: ${formatRate(item.successRate)} (${item.successes}/${done})，平均 ${formatMs(item.avgConnectMs)}`}
+                  >
+                    <div className="text-xs text-muted-foreground">
+                      {done > 0 ? formatRate(item.successRate) : ''}
+                    </div>
+                    <div className="w-full flex flex-col justify-end" style={{ height: '120px' }}>
+                      <div
+                        className="w-full bg-red-500 rounded-t"
+                        style={{ height: `${failPct}%`, minHeight: item.failures > 0 ? '2px' : '0' }}
+                      />
+                      <div
+                        className="w-full bg-green-500"
+                        style={{ height: `${okPct}%`, minHeight: item.successes > 0 ? '2px' : '0' }}
+                      />
+                    </div>
+                    <div className="text-xs text-muted-foreground rotate-45 origin-left whitespace-nowrap">
+                      {item.day.slice(5, 10)}
+                    </div>
+                  </div>
+                );
+              })}
+            </div>
+          )}
+        </CardContent>
+      </Card>
+
+      <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+        {/* Time to connect distribution */}
+        <Card>
+          <CardHeader>
+            <CardTitle>建连耗时分布</CardTitle>
+          </CardHeader>
+          <CardContent className="space-y-3">
+            {distribution.map((bucket) => {
+              const pct = timed > 0 ? bucket.count / timed : 0;
+              return (
+                <div key={bucket.label} className="space-y-1">
+                  <div className="flex justify-between text-sm">
+                    <span>{bucket.label}</span>
+                    <span className="text-muted-foreground">
+                      {bucket.count} ({formatRate(pct)})
+                    </span>
+                  </div>
+                  <div className="h-2 w-full rounded bg-muted">
+                    <div className={`h-2 rounded ${bucket.className}`} style={{ width: `${pct * 100}%` }} />
+                  </div>
+                </div>
+              );
+            })}
+          </CardContent>
+        </Card>
+
+        {/* Top failure reasons */}
+        <Card>
+          <CardHeader>
+            <CardTitle>失败原因 Top 10</CardTitle>
+          </CardHeader>
+          <CardContent>
+            <FailureTable rows={overview.topFailures} />
+          </CardContent>
+        </Card>
+      </div>
+
+      {/* Breakdown by dimension */}
+      <Card>
+        <CardHeader className="flex flex-row items-center justify-between">
+          <CardTitle>按维度</CardTitle>
+          <Select value={dimension} onValueChange={(v) => setDimension(v as TelemetryDimension)}>
+            <SelectTrigger className="w-36">
+              <SelectValue />
+            </SelectTrigger>
+            <SelectContent>
+              {(Object.keys(dimensionLabels) as TelemetryDimension[]).map((key) => (
+                <SelectItem key={key} value={key}>
+                  {dimensionLabels[key]}
+                </SelectItem>
+              ))}
+            </SelectContent>
+          </Select>
+        </CardHeader>
+        <CardContent>
+          <Table>
+            <TableHeader>
+              <TableRow>
+                <TableHead>{dimensionLabels[dimension]}</TableHead>
+                <TableHead className="text-right">连接次数</TableHead>
+                <TableHead className="text-right">失败</TableHead>
+                <TableHead className="text-right">成功率</TableHead>
+                <TableHead className="text-right">平均耗时</TableHead>
+                <TableHead className="text-right">设备·天</TableHead>
+              </TableRow>
+            </TableHeader>
+            <TableBody>
+              {(breakdown?.rows ?? []).map((item) => (
+                <TableRow
+                  key={item.value}
+                  className={`cursor-pointer ${selected === item.value ? 'bg-muted' : ''}`}
+                  onClick={() => setSelected(selected === item.value ? null : item.value)}
+                >
+                  <TableCell className="font-mono text-sm">{item.value || "(未上报)"}</TableCell>
+                  <TableCell className="text-right">{item.attempts}</TableCell>
+                  <TableCell className="text-right">{item.failures}</TableCell>
+                  <TableCell className="text-right">{formatRate(item.successRate)}</TableCell>
+                  <TableCell className="text-right">{formatMs(item.avgConnectMs)}</TableCell>
+                  <TableCell className="text-right">{item.deviceDays}</TableCell>
+                </TableRow>
+              ))}
+            </TableBody>
+          </Table>
+        </CardContent>
+      </Card>
+
+      {selected !== null && (
+        <Card>
+          <CardHeader>
+            <CardTitle>
+              失败原因：{dimensionLabels[dimension]} = {selected || "(未上报)"}
+            </CardTitle>
+          </CardHeader>
+          <CardContent>
+            <FailureTable rows={selectedFailures} />
+          </CardContent>
+        </Card>
+      )}
+    </div>
+  );
+}
@@ -0,0 +1,406 @@
+"use client";
+
+import { useState, useEffect, useCallback } from "react";
+import { useRouter } from "next/navigation";
+import { useSearchParams } from "next/navigation";
+import {
+  Table,
+  TableBody,
+  TableCell,
+  TableHead,
+  TableHeader,
+  TableRow,
+} from "@/components/ui/table";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Badge } from "@/components/ui/badge";
+import {
+  ColumnDef,
+  flexRender,
+  getCoreRowModel,
+  useReactTable,
+} from "@tanstack/react-table";
+import { api, AdminLoginRiskEventItem } from "@/lib/api";
+import { toast } from "sonner";
+
+const actionLabels: Record<string, string> = {
+  send_code: "发送验证码",
+  code_login: "验证码登录",
+  password_login: "密码登录",
+  device_code: "设备授权码",
+};
+
+const outcomeLabels: Record<string, string> = {
+  success: "成功",
+  failed: "失败",
+  rejected: "已拦截",
+};
+
+export default function LoginRiskPage() {
+  const router = useRouter();
+  const searchParams = useSearchParams();
+
+  const [data, setData] = useState<AdminLoginRiskEventItem[]>([]);
+  const [pageCount, setPageCount] = useState(0);
+  const [total, setTotal] = useState(0);
+  const [isLoading, setIsLoading] = useState(true);
+
+  // 从 URL query 获取状态
+  const page = searchParams.get("page")
+    ? parseInt(searchParams.get("page") as string, 10)
+    : 0;
+  const pageSize = searchParams.get("pageSize")
+    ? parseInt(searchParams.get("pageSize") as string, 10)
+    : 50;
+  const email = searchParams.get("email") || "";
+  const ip = searchParams.get("ip") || "";
+  const decision = searchParams.get("decision") || "";
+  const action = searchParams.get("action") || "";
+
+  // 本地筛选状态
+  const [localEmail, setLocalEmail] = useState(email);
+  const [localIP, setLocalIP] = useState(ip);
+  const [localDecision, setLocalDecision] = useState(decision);
+  const [localAction, setLocalAction] = useState(action);
+
+  const formatDate = (timestamp: number) => {
+    if (!timestamp) return "-";
+    return new Date(timestamp * 1000).toLocaleString("zh-CN");
+  };
+
+  const getDecisionBadge = (decision: string) => {
+    switch (decision) {
+      case "allow":
+        return <Badge variant="outline">{"放行"}</Badge>;
+      case "captcha":
+        return <Badge variant="secondary">{"人机验证"}</Badge>;
+      case "email_code":
+        return <Badge variant="secondary">{"强制验证码"}</Badge>;
+      case "block":
+        return <Badge variant="destructive">{"临时封禁"}</Badge>;
+      default:
+        return <Badge variant="outline">{decision}</Badge>;
+    }
+  };
+
+  const goToUserDetail = (userUuid: string) => {
+    router.push(`/manager/users/detail?uuid=${userUuid}`);
+  };
+
+  const handleUnblock = async (targetIP: string) => {
+    if (!confirm(`确定要解除 ${targetIP} 的登录封禁？该 IP 的失败计数也会一并清零。`)) return;
+
+    try {
+      await api.unblockLoginRiskIP(targetIP);
+      toast.success("已解除封禁");
+    } catch (error) {
+      console.error("Failed to unblock ip:", error);
+      toast.error("解除封禁失败");
+    }
+  };
+
+  const columns: ColumnDef<AdminLoginRiskEventItem>[] = [
+    {
+      accessorKey: "createdAt",
+      header: "时间",
+      cell: ({ row }) => (
+        <span className="text-sm whitespace-nowrap">{formatDate(row.original.createdAt)}</span>
+      ),
+    },
+    {
+      accessorKey: "action",
+      header: "请求",
+      cell: ({ row }) => actionLabels[row.original.action] || row.original.action,
+    },
+    {
+      accessorKey: "user",
+      header: "用户",
+      cell: ({ row }) => {
+        const user = row.original.user;
+        if (!user) {
+          return <span className="text-muted-foreground">{"未注册"}</span>;
+        }
+        return (
+          <Button
+            variant="link"
+            className="p-0 h-auto font-normal"
+            onClick={() => goToUserDetail(user.uuid)}
+          >
+            {user.email || user.uuid}
+          </Button>
+        );
+      },
+    },
+    {
+      accessorKey: "ip",
+      header: "来源",
+      cell: ({ row }) => {
+        const item = row.original;
+        return (
+          <div className="space-y-0.5">
+            <code className="text-xs bg-muted px-1 py-0.5 rounded">{item.ip}</code>
+            <div className="text-xs text-muted-foreground">
+              {item.country || "-"}
+              {item.asn ? ` · AS${item.asn}` : ""}
+            </div>
+          </div>
+        );
+      },
+    },
+    {
+      accessorKey: "score",
+      header: "分数",
+      cell: ({ row }) => <span className="font-medium">{row.original.score}</span>,
+    },
+    {
+      accessorKey: "signals",
+      header: "命中信号",
+      cell: ({ row }) => {
+        const signals = row.original.signals;
+        if (!signals.length) {
+          return <span className="text-muted-foreground">{"-"}</span>;
+        }
+        return (
+          <div className="flex flex-wrap gap-1 max-w-xs">
+            {signals.map((signal) => (
+              <code key={signal} className="text-xs bg-muted px-1 py-0.5 rounded">
+                {signal}
+              </code>
+            ))}
+          </div>
+        );
+      },
+    },
+    {
+      accessorKey: "decision",
+      header: "决策",
+      cell: ({ row }) => getDecisionBadge(row.original.decision),
+    },
+    {
+      accessorKey: "outcome",
+      header: "结果",
+      cell: ({ row }) => outcomeLabels[row.original.outcome] || row.original.outcome || "-",
+    },
+    {
+      id: "actions",
+      header: "操作",
+      cell: ({ row }) => {
+        if (row.original.decision !== "block") {
+          return <span className="text-muted-foreground">{"-"}</span>;
+        }
+        return (
+          <Button
+            size="sm"
+            variant="outline"
+            onClick={() => handleUnblock(row.original.ip)}
+          >
+            {"解除封禁"}
+          </Button>
+        );
+      },
+    },
+  ];
+
+  const fetchEvents = useCallback(async () => {
+    setIsLoading(true);
+    try {
+      const response = await api.listLoginRiskEvents({
+        page,
+        pageSize,
+        email: email || undefined,
+        ip: ip || undefined,
+        decision: decision || undefined,
+        action: action || undefined,
+      });
+      setData(response.items || []);
+      if (response.pagination) {
+        setPageCount(Math.ceil(response.pagination.total / response.pagination.pageSize));
+        setTotal(response.pagination.total);
+      }
+    } catch (error) {
+      console.error("Failed to fetch login risk events:", error);
+    } finally {
+      setIsLoading(false);
+    }
+  }, [page, pageSize, email, ip, decision, action]);
+
+  useEffect(() => {
+    fetchEvents();
+  }, [fetchEvents]);
+
+  const table = useReactTable({
+    data,
+    columns,
+    pageCount,
+    getCoreRowModel: getCoreRowModel(),
+    manualPagination: true,
+  });
+
+  const handleFilter = () => {
+    const params = new URLSearchParams();
+    params.set("page", "0");
+    params.set("pageSize", pageSize.toString());
+    if (localEmail.trim()) params.set("email", localEmail.trim());
+    if (localIP.trim()) params.set("ip", localIP.trim());
+    if (localDecision) params.set("decision", localDecision);
+    if (localAction) params.set("action", localAction);
+    router.push(`/manager/login-risk?${params.toString()}`);
+  };
+
+  const handleReset = () => {
+    setLocalEmail("");
+    setLocalIP("");
+    setLocalDecision("");
+    setLocalAction("");
+    router.push('/manager/login-risk');
+  };
+
+  return (
+    <div className="space-y-6">
+      <div>
+        <h1 className="text-3xl font-bold">{"登录风控"}</h1>
+        <p className="text-muted-foreground">{"查询登录请求的风控评分与决策，解除 IP 临时封禁"}</p>
+      </div>
+
+      {/* 筛选区域 */}
+      <div className="flex items-end gap-4 p-4 bg-muted/50 rounded-lg">
+        <div className="flex-1">
+          <label className="text-sm font-medium">{"邮箱"}</label>
+          <Input
+            value={localEmail}
+            onChange={(e) => setLocalEmail(e.target.value)}
+            onKeyDown={(e) => { if (e.key === 'Enter') handleFilter(); }}
+            placeholder="user@example.com"
+          />
+        </div>
+        <div className="flex-1">
+          <label className="text-sm font-medium">{"IP"}</label>
+          <Input
+            value={localIP}
+            onChange={(e) => setLocalIP(e.target.value)}
+            onKeyDown={(e) => { if (e.key === 'Enter') handleFilter(); }}
+            placeholder="203.0.113.7"
+          />
+        </div>
+        <div className="flex-1">
+          <label className="text-sm font-medium">{"决策"}</label>
+          <select
+            className="w-full p-2 border border-border bg-muted text-foreground rounded-md"
+            value={localDecision}
+            onChange={(e) => setLocalDecision(e.target.value)}
+          >
+            <option value="">{"全部"}</option>
+            <option value="allow">{"放行"}</option>
+            <option value="captcha">{"人机验证"}</option>
+            <option value="email_code">{"强制验证码"}</option>
+            <option value="block">{"临时封禁"}</option>
+          </select>
+        </div>
+        <div className="flex-1">
+          <label className="text-sm font-medium">{"请求"}</label>
+          <select
+            className="w-full p-2 border border-border bg-muted text-foreground rounded-md"
+            value={localAction}
+            onChange={(e) => setLocalAction(e.target.value)}
+          >
+            <option value="">{"全部"}</option>
+            {Object.entries(actionLabels).map(([value, label]) => (
+              <option key={value} value={value}>{label}</option>
+            ))}
+          </select>
+        </div>
+        <div className="flex gap-2">
+          <Button onClick={handleFilter}>{"筛选"}</Button>
+          <Button variant="outline" onClick={handleReset}>
+            {"重置"}
+          </Button>
+        </div>
+      </div>
+
+      <div className="rounded-md border">
+        <Table>
+          <TableHeader>
+            {table.getHeaderGroups().map((headerGroup) => (
+              <TableRow key={headerGroup.id}>
+                {headerGroup.headers.map((header) => (
+                  <TableHead key={header.id}>
+                    {header.isPlaceholder
+                      ? null
+                      : flexRender(
+                          header.column.columnDef.header,
+                          header.getContext()
+                        )}
+                  </TableHead>
+                ))}
+              </TableRow>
+            ))}
+          </TableHeader>
+          <TableBody>
+            {isLoading ? (
+              <TableRow>
+                <TableCell
+                  colSpan={columns.length}
+                  className="h-24 text-center"
+                >
+                  <div className="flex items-center justify-center">
+                    <div className="animate-spin rounded-full h-8 w-8 border-b-2 border-primary"></div>
+                  </div>
+                </TableCell>
+              </TableRow>
+            ) : table.getRowModel().rows?.length ? (
+              table.getRowModel().rows.map((row) => (
+                <TableRow key={row.id}>
+                  {row.getVisibleCells().map((cell) => (
+                    <TableCell key={cell.id}>
+                      {flexRender(
+                        cell.column.columnDef.cell,
+                        cell.getContext()
+                      )}
+                    </TableCell>
+                  ))}
+                </TableRow>
+              ))
+            ) : (
+              <TableRow>
+                <TableCell
+                  colSpan={columns.length}
+                  className="h-24 text-center"
+                >
+                  {"无结果."}
+                </TableCell>
+              </TableRow>
+            )}
+          </TableBody>
+        </Table>
+      </div>
+
+      <div className="flex items-center justify-end space-x-2 py-4">
+        <span className="text-sm text-muted-foreground">{"总计: "}{total}</span>
+        <Button
+          variant="outline"
+          size="sm"
+          onClick={() => {
+            const params = new URLSearchParams(searchParams.toString());
+            params.set('page', (page - 1).toString());
+            router.push(`/manager/login-risk?${params.toString()}`);
+          }}
+          disabled={page === 0}
+        >
+          {"上一页"}
+        </Button>
+        <Button
+          variant="outline"
+          size="sm"
+          onClick={() => {
+            const params = new URLSearchParams(searchParams.toString());
+            params.set('page', (page + 1).toString());
+            router.push(`/manager/login-risk?${params.toString()}`);
+          }}
+          disabled={page >= pageCount - 1}
+        >
+          {"下一页"}
+        </Button>
+      </div>
+    </div>
+  );
+}
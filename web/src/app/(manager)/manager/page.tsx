@@ -12,6 +12,7 @@ import {
   ConnectionRatingStatisticsResponse,
 } from "@/lib/api";
 import { ConnectionQualityTab } from "./connection-quality-tab";
+import { ConnectionSuccessTab } from "./connection-success-tab";
 import { Card, CardContent, CardHeader, CardTitle, CardDescription } from "@/components/ui/card";
 import { Table, TableBody, TableCell, TableHead, TableHeader, TableRow } from "@/components/ui/table";
 import { Badge } from "@/components/ui/badge";
@@ -146,6 +147,7 @@ export default function ManagerDashboardPage() {
           <TabsTrigger value="orders">订单统计</TabsTrigger>
           <TabsTrigger value="devices">设备统计</TabsTrigger>
           <TabsTrigger value="quality">连接质量</TabsTrigger>
+          <TabsTrigger value="success">连接成功率</TabsTrigger>
         </TabsList>
 
         {/* Overview Tab */}
@@ -980,6 +982,10 @@ export default function ManagerDashboardPage() {
         <TabsContent value="quality" className="space-y-6">
           <ConnectionQualityTab />
         </TabsContent>
+
+        <TabsContent value="success" className="space-y-6">
+          <ConnectionSuccessTab />
+        </TabsContent>
       </Tabs>
     </div>
   );
@@ -17,11 +17,12 @@ import {
   DialogHeader,
   DialogTitle,
 } from "@/components/ui/dialog";
-import { MoreHorizontal, Trash2, KeyRound, Ban } from "lucide-react";
+import { MoreHorizontal, Trash2, KeyRound, Ban, ShieldOff, LogOut } from "lucide-react";
 import { toast } from "sonner";
 import { api, isPendingApproval } from "@/lib/api";
 import { useRouter } from "next/navigation";
 import { ResetPasswordDialog } from "./ResetPasswordDialog";
+import { ResetTwoFactorDialog } from "./ResetTwoFactorDialog";
 
 interface MoreActionsMenuProps {
   userUUID: string;
@@ -38,11 +39,14 @@ export function MoreActionsMenu({
 }: MoreActionsMenuProps) {
   const router = useRouter();
   const [showResetPasswordDialog, setShowResetPasswordDialog] = useState(false);
+  const [showResetTwoFactorDialog, setShowResetTwoFactorDialog] = useState(false);
   const [showFirstConfirm, setShowFirstConfirm] = useState(false);
   const [showSecondConfirm, setShowSecondConfirm] = useState(false);
   const [isDeleting, setIsDeleting] = useState(false);
   const [showBlockConfirm, setShowBlockConfirm] = useState(false);
   const [isTogglingBlock, setIsTogglingBlock] = useState(false);
+  const [showRevokeSessionsConfirm, setShowRevokeSessionsConfirm] = useState(false);
+  const [isRevokingSessions, setIsRevokingSessions] = useState(false);
 
   const handleDeleteClick = () => {
     setShowFirstConfirm(true);
@@ -105,6 +109,24 @@ export function MoreActionsMenu({
     }
   };
 
+  const confirmRevokeSessions = async () => {
+    if (isRevokingSessions) return;
+
+    setIsRevokingSessions(true);
+    try {
+      await api.request(`/app/users/${userUUID}/sessions/revoke`, {
+        method: "POST",
+      });
+      toast.success("已强制下线该用户的全部会话");
+      setShowRevokeSessionsConfirm(false);
+    } catch (error) {
+      console.error("Failed to revoke user sessions:", error);
+      toast.error("操作失败，请重试或联系管理员");
+    } finally {
+      setIsRevokingSessions(false);
+    }
+  };
+
   return (
     <>
       <DropdownMenu>
@@ -122,6 +144,20 @@ export function MoreActionsMenu({
             <KeyRound className="mr-2 h-4 w-4" />
             {"重置密码"}
           </DropdownMenuItem>
+          <DropdownMenuItem
+            className="cursor-pointer"
+            onClick={() => setShowResetTwoFactorDialog(true)}
+          >
+            <ShieldOff className="mr-2 h-4 w-4" />
+            {"重置两步验证"}
+          </DropdownMenuItem>
+          <DropdownMenuItem
+            className="cursor-pointer"
+            onClick={() => setShowRevokeSessionsConfirm(true)}
+          >
+            <LogOut className="mr-2 h-4 w-4" />
+            {"强制下线全部会话"}
+          </DropdownMenuItem>
           <DropdownMenuItem
             className="cursor-pointer"
             onClick={() => setShowBlockConfirm(true)}
@@ -170,6 +206,35 @@ export function MoreActionsMenu({
         </DialogContent>
       </Dialog>
 
+      {/* 强制下线确认对话框 */}
+      <Dialog open={showRevokeSessionsConfirm} onOpenChange={setShowRevokeSessionsConfirm}>
+        <DialogContent>
+          <DialogHeader>
+            <DialogTitle>{"强制下线全部会话"}</DialogTitle>
+            <DialogDescription>
+              <span className="font-semibold">{userEmail}</span>
+              {" 在所有浏览器和设备上的登录将立即失效，需要重新登录。用于凭证泄漏等紧急情况，会写入审计日志。"}
+            </DialogDescription>
+          </DialogHeader>
+          <DialogFooter>
+            <Button
+              variant="outline"
+              onClick={() => setShowRevokeSessionsConfirm(false)}
+              disabled={isRevokingSessions}
+            >
+              {"取消"}
+            </Button>
+            <Button
+              variant="destructive"
+              onClick={confirmRevokeSessions}
+              disabled={isRevokingSessions}
+            >
+              {isRevokingSessions ? "处理中..." : "确认下线"}
+            </Button>
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+
       {/* 第一次确认对话框 */}
       <Dialog open={showFirstConfirm} onOpenChange={setShowFirstConfirm}>
         <DialogContent>
@@ -240,6 +305,13 @@ export function MoreActionsMenu({
         userUUID={userUUID}
         userEmail={userEmail}
       />
+
+      <ResetTwoFactorDialog
+        open={showResetTwoFactorDialog}
+        onOpenChange={setShowResetTwoFactorDialog}
+        userUUID={userUUID}
+        userEmail={userEmail}
+      />
     </>
   );
 }
@@ -0,0 +1,111 @@
+"use client";
+
+import { useState } from "react";
+import { api, ApiError, isPendingApproval } from "@/lib/api";
+import { getApiErrorMessageZh } from "@/lib/api-errors";
+import { toast } from "sonner";
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogFooter,
+  DialogHeader,
+  DialogTitle,
+} from "@/components/ui/dialog";
+import { Textarea } from "@/components/ui/textarea";
+import { Button } from "@/components/ui/button";
+
+interface ResetTwoFactorDialogProps {
+  open: boolean;
+  onOpenChange: (open: boolean) => void;
+  userUUID: string;
+  userEmail: string;
+}
+
+export function ResetTwoFactorDialog({
+  open,
+  onOpenChange,
+  userUUID,
+  userEmail,
+}: ResetTwoFactorDialogProps) {
+  const [reason, setReason] = useState("");
+  const [isSubmitting, setIsSubmitting] = useState(false);
+
+  const handleSubmit = async () => {
+    if (reason.trim().length < 3) {
+      toast.error("请填写重置原因（≥3 字符）");
+      return;
+    }
+
+    setIsSubmitting(true);
+    try {
+      await api.request(`/app/users/${userUUID}/2fa/reset`, {
+        method: "POST",
+        body: JSON.stringify({ reason: reason.trim() }),
+      });
+      toast.success(`已为 ${userEmail} 重置两步验证`);
+      setReason("");
+      onOpenChange(false);
+    } catch (e) {
+      if (isPendingApproval(e)) {
+        // api.request 已提示"已提交审批"
+        setReason("");
+        onOpenChange(false);
+      } else if (e instanceof ApiError) {
+        toast.error(getApiErrorMessageZh(e.code, "重置两步验证失败", e.message));
+      } else {
+        toast.error("重置两步验证失败");
+      }
+    } finally {
+      setIsSubmitting(false);
+    }
+  };
+
+  return (
+    <Dialog
+      open={open}
+      onOpenChange={(o) => {
+        if (!o) setReason("");
+        onOpenChange(o);
+      }}
+    >
+      <DialogContent>
+        <DialogHeader>
+          <DialogTitle>{"重置两步验证"}</DialogTitle>
+          <DialogDescription>
+            {`清除 ${userEmail} 的认证器绑定和全部恢复码。需审批，执行后向用户发送通知邮件。`}
+          </DialogDescription>
+        </DialogHeader>
+        <div className="space-y-4 py-4">
+          <div className="space-y-2">
+            <label className="text-sm font-medium">
+              {"重置原因"}
+              <span className="text-red-500 ml-1">{"*"}</span>
+            </label>
+            <Textarea
+              placeholder="例如：用户更换手机且恢复码丢失，已核实身份（工单 #1234）。将写入审计日志。"
+              value={reason}
+              onChange={(e) => setReason(e.target.value)}
+              rows={3}
+            />
+          </div>
+          <p className="text-xs text-muted-foreground">
+            {"提示：仅在确认是账号本人后操作。管理角色账号重置后，下次进入后台会被要求重新启用。"}
+          </p>
+        </div>
+        <DialogFooter>
+          <Button
+            variant="outline"
+            onClick={() => onOpenChange(false)}
+            disabled={isSubmitting}
+          >
+            {"取消"}
+          </Button>
+          <Button onClick={handleSubmit} disabled={isSubmitting}>
+            {isSubmitting ? "提交中..." : "确认重置"}
+          </Button>
+        </DialogFooter>
+      </DialogContent>
+    </Dialog>
+  );
+}
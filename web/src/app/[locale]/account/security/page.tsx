@@ -9,6 +9,10 @@ import { Card } from '@/components/ui/card';
 import { Button } from '@/components/ui/button';
 import { Lock } from 'lucide-react';
 import ChangePasswordDialog from '@/components/ChangePasswordDialog';
+import TwoFactorCard from '@/components/TwoFactorCard';
+import PasskeyCard from '@/components/PasskeyCard';
+import LinkedAccountsCard from '@/components/LinkedAccountsCard';
+import SessionsCard from '@/components/SessionsCard';
 
 export default function SecurityPage() {
   const t = useTranslations('admin.account');
@@ -40,6 +44,22 @@ export default function SecurityPage() {
         </div>
       </Card>
 
+      <div className="mt-4">
+        <TwoFactorCard />
+      </div>
+
+      <div className="mt-4">
+        <PasskeyCard />
+      </div>
+
+      <div className="mt-4">
+        <LinkedAccountsCard />
+      </div>
+
+      <div className="mt-4">
+        <SessionsCard />
+      </div>
+
       <ChangePasswordDialog
         open={dialogOpen}
         onOpenChange={setDialogOpen}
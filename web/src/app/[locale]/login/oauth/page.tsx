@@ -0,0 +1,184 @@
+"use client";
+
+import { useCallback, useEffect, useRef, useState, Suspense } from "react";
+import { useRouter, Link } from "@/i18n/routing";
+import { useSearchParams } from "next/navigation";
+import { useLocale, useTranslations } from "next-intl";
+import { useAuth } from "@/contexts/AuthContext";
+import { api, ApiError, ErrorCode } from "@/lib/api";
+import { getApiErrorMessage } from "@/lib/api-errors";
+import { clearPendingOAuth, readPendingOAuth, OAUTH_PROVIDER_NAMES, PendingOAuth } from "@/lib/oauth";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { toast } from "sonner";
+import Header from "@/components/Header";
+import Footer from "@/components/Footer";
+import { Loader2, ShieldCheck } from "lucide-react";
+
+function getCookie(name: string): string | null {
+  const match = document.cookie.match(new RegExp(`(?:^|; )${name}=([^;]*)`));
+  return match ? decodeURIComponent(match[1]) : null;
+}
+
+// Center 回调失败时带回的原因
+const CALLBACK_ERRORS: Record<string, string> = {
+  cancelled: 'auth.oauth.cancelled',
+  expired: 'auth.oauth.expired',
+  failed: 'auth.oauth.failed',
+};
+
+/**
+ * Landing page of the social login / account linking redirect.
+ *
+ * Center redirects here with `?ticket=` (or `?error=`). The ticket only
+ * works together with the state this tab saved before leaving, so a ticket
+ * planted by someone else cannot sign the visitor into a foreign account.
+ */
+function OAuthCompleteContent() {
+  const t = useTranslations();
+  const locale = useLocale();
+  const router = useRouter();
+  const searchParams = useSearchParams();
+  const { login } = useAuth();
+  const [pending, setPending] = useState<PendingOAuth | null>(null);
+  const [error, setError] = useState<string | null>(null);
+  const [totpRequired, setTotpRequired] = useState(false);
+  const [totpCode, setTotpCode] = useState("");
+  const [isLoading, setIsLoading] = useState(false);
+  const started = useRef(false);
+
+  const ticket = searchParams.get("ticket") || "";
+
+  const complete = useCallback(async (p: PendingOAuth, code?: string) => {
+    setIsLoading(true);
+    try {
+      if (p.intent === 'link') {
+        await api.oauthLink(ticket, p.state);
+        clearPendingOAuth();
+        toast.success(t('auth.oauth.linkSuccess', { provider: OAUTH_PROVIDER_NAMES[p.provider] }));
+        router.replace(p.returnTo);
+        return;
+      }
+      const { user, accessToken } = await api.oauthLogin(
+        {
+          ticket,
+          state: p.state,
+          totpCode: code?.trim() || undefined,
+          language: locale,
+          inviteCode: getCookie('kaitu_invite_code') || undefined,
+        },
+        { autoRedirectToAuth: false },
+      );
+      clearPendingOAuth();
+      toast.success(t('auth.login.loginSuccess'));
+      await login(user, accessToken);
+      router.replace(p.returnTo);
+    } catch (err) {
+      if (err instanceof ApiError && err.code === ErrorCode.TwoFactorRequired) {
+        setTotpRequired(true);
+        return;
+      }
+      if (err instanceof ApiError && err.code === ErrorCode.InvalidTwoFactorCode) {
+        setTotpCode("");
+        toast.error(getApiErrorMessage(err.code, t));
+        return;
+      }
+      clearPendingOAuth();
+      setError(err instanceof ApiError
+        ? getApiErrorMessage(err.code, t, t('auth.oauth.failed'), err.message)
+        : t('auth.oauth.failed'));
+    } finally {
+      setIsLoading(false);
+    }
+  }, [ticket, locale, login, router, t]);
+
+  useEffect(() => {
+    if (started.current) return;
+    started.current = true;
+
+    const p = readPendingOAuth();
+    setPending(p);
+    const reason = searchParams.get("error");
+    if (reason) {
+      clearPendingOAuth();
+      setError(t(CALLBACK_ERRORS[reason] ?? 'auth.oauth.failed'));
+      return;
+    }
+    if (!p || !ticket) {
+      setError(t('auth.oauth.expired'));
+      return;
+    }
+    complete(p);
+  }, [searchParams, ticket, complete, t]);
+
+  const backHref = pending?.intent === 'link' ? pending.returnTo : "/login";
+
+  return (
+    <div className="min-h-screen flex flex-col bg-background">
+      <Header />
+
+      <div className="flex-1 flex items-center justify-center py-12 px-4 sm:px-6 lg:px-8">
+        <div className="w-full max-w-md p-8 space-y-6 bg-card rounded-lg shadow-md">
+          {error ? (
+            <div className="space-y-6 text-center">
+              <p className="text-foreground">{error}</p>
+              <Link href={backHref}>
+                <Button variant="outline" className="w-full">
+                  {t('auth.oauth.back')}
+                </Button>
+              </Link>
+            </div>
+          ) : totpRequired && pending ? (
+            <form
+              className="space-y-4"
+              onSubmit={(e) => {
+                e.preventDefault();
+                complete(pending, totpCode);
+              }}
+            >
+              <p className="text-sm text-muted-foreground">{t('auth.login.twoFactorPrompt')}</p>
+              <div>
+                <Label htmlFor="oauth-totp" className="flex items-center gap-2">
+                  <ShieldCheck className="w-4 h-4" />
+                  {t('auth.login.twoFactorCode')}
+                </Label>
+                <Input
+                  id="oauth-totp"
+                  value={totpCode}
+                  onChange={(e) => setTotpCode(e.target.value)}
+                  placeholder={t('auth.login.twoFactorCodePlaceholder')}
+                  className="mt-1"
+                  autoComplete="one-time-code"
+                  autoFocus
+                />
+              </div>
+              <Button type="submit" disabled={isLoading || !totpCode.trim()} className="w-full">
+                {isLoading ? t('auth.login.loggingIn') : t('auth.login.loginButton')}
+              </Button>
+            </form>
+          ) : (
+            <div className="flex flex-col items-center gap-4 py-6 text-muted-foreground">
+              <Loader2 className="w-8 h-8 animate-spin" />
+              <p>{pending?.intent === 'link' ? t('auth.oauth.linking') : t('auth.oauth.signingIn')}</p>
+            </div>
+          )}
+        </div>
+      </div>
+
+      <Footer />
+    </div>
+  );
+}
+
+export default function OAuthCompletePage() {
+  return (
+    <Suspense fallback={
+      <div className="min-h-screen bg-background flex items-center justify-center">
+        <Loader2 className="w-8 h-8 animate-spin text-muted-foreground" />
+      </div>
+    }>
+      <OAuthCompleteContent />
+    </Suspense>
+  );
+}
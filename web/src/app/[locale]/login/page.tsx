@@ -15,6 +15,7 @@ import Header from "@/components/Header";
 import Footer from "@/components/Footer";
 import Image from "next/image";
 import { useBrand } from '@/hooks/useBrand';
+import OAuthLoginButtons, { useOAuthProviders } from "@/components/OAuthLoginButtons";
 
 function LoginPageContent() {
   const { login, isAuthenticated } = useAuth();
@@ -28,6 +29,7 @@ function LoginPageContent() {
   const [step, setStep] = useState(1); // 1 for email, 2 for code (with optional invite)
   const [isLoading, setIsLoading] = useState(false);
   const [isActivated, setIsActivated] = useState(true); // 用户是否已激活
+  const oauthProviders = useOAuthProviders();
 
   // Get the next URL from query params, default to account page
   const next = searchParams.get("next") || "/account";
@@ -159,6 +161,16 @@ function LoginPageContent() {
             >
               {isLoading ? t('auth.login.sendingCode') : t('auth.login.sendCode')}
             </Button>
+            {oauthProviders.length > 0 && (
+              <>
+                <div className="flex items-center gap-3 text-xs text-muted-foreground">
+                  <div className="h-px flex-1 bg-border" />
+                  {t('auth.login.or')}
+                  <div className="h-px flex-1 bg-border" />
+                </div>
+                <OAuthLoginButtons providers={oauthProviders} disabled={isLoading} returnTo={next} />
+              </>
+            )}
           </div>
         ) : (
           <form onSubmit={handleLogin} className="space-y-6">
@@ -7,12 +7,15 @@ import { useAuth } from "@/contexts/AuthContext";
 import { useAppConfig } from "@/contexts/AppConfigContext";
 import { api, ApiError, ErrorCode } from "@/lib/api";
 import { getApiErrorMessage } from "@/lib/api-errors";
+import OAuthLoginButtons, { useOAuthProviders } from "@/components/OAuthLoginButtons";
+import TurnstileWidget from "@/components/TurnstileWidget";
+import { getPasskeyAssertion, isPasskeyCancelled, isPasskeySupported } from "@/lib/passkey";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
 import { Tabs, TabsList, TabsTrigger, TabsContent } from "@/components/ui/tabs";
 import { toast } from "sonner";
-import { Mail, ArrowRight, Check, AlertCircleIcon, Lock } from "lucide-react";
+import { Mail, ArrowRight, Check, AlertCircleIcon, Lock, ShieldCheck, KeyRound } from "lucide-react";
 
 // Cookie helper functions
 function getCookie(name: string): string | null {
@@ -47,6 +50,16 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
   // Step-1 sub-tab: "code" (verification-code flow, default) | "password" (T18).
   const [loginMethod, setLoginMethod] = useState<"code" | "password">("code");
   const [password, setPassword] = useState("");
+  // 两步验证：服务端返回 TwoFactorRequired 后才显示，凭证保持不变带 totpCode 重新提交
+  const [totpRequired, setTotpRequired] = useState(false);
+  const [totpCode, setTotpCode] = useState("");
+  // Passkey 只在支持 WebAuthn 的浏览器中显示（挂载后检测，避免 SSR 不一致）
+  const [passkeySupported, setPasskeySupported] = useState(false);
+  // 登录风控：服务端返回 CaptchaRequired 后才显示人机验证，token 一次性，每次提交后重新挂载挂件
+  const [captchaRequired, setCaptchaRequired] = useState(false);
+  const [captchaToken, setCaptchaToken] = useState("");
+  const [captchaKey, setCaptchaKey] = useState(0);
+  const oauthProviders = useOAuthProviders(mode === 'login');
 
   // Load invite code from cookie on component mount
   useEffect(() => {
@@ -58,6 +71,10 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
     }
   }, []);
 
+  useEffect(() => {
+    setPasskeySupported(mode === 'login' && isPasskeySupported());
+  }, [mode]);
+
   const isValidEmail = (email: string) => {
     const emailRegex = /^[^\s@]+@[^\s@]+\.[^\s@]+$/;
     return emailRegex.test(email);
@@ -77,7 +94,8 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
 
       const response = await api.sendCode({
         email,
-        language: userLanguage
+        language: userLanguage,
+        captchaToken: takeCaptchaToken(),
       }, {
         autoRedirectToAuth: false,
       });
@@ -89,9 +107,11 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
       }
 
       toast.success(t('auth.login.codeSuccess'));
+      setCaptchaRequired(false);
       setStep(2);
     } catch (error) {
       if (error instanceof ApiError) {
+        if (handleLoginRiskError(error)) return;
         toast.error(getApiErrorMessage(error.code, t, undefined, error.message));
       } else {
         toast.error(t('auth.login.loginFailed'));
@@ -114,7 +134,13 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
       const userLanguage = typeof window !== 'undefined' ?
         window.location.pathname.split('/')[1] || 'en-US' : 'en-US';
       const response = await api.passwordLogin(
-        { email, password, language: userLanguage },
+        {
+          email,
+          password,
+          language: userLanguage,
+          totpCode: totpCode.trim() || undefined,
+          captchaToken: takeCaptchaToken(),
+        },
         { autoRedirectToAuth: false },
       );
       toast.success(t('auth.login.loginSuccess'));
@@ -126,6 +152,7 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
       onLoginSuccess?.();
     } catch (error) {
       if (error instanceof ApiError) {
+        if (handleTwoFactorError(error) || handleLoginRiskError(error)) return;
         toast.error(getApiErrorMessage(error.code, t, undefined, error.message));
       } else {
         toast.error(t('auth.login.loginFailed'));
@@ -135,6 +162,109 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
     }
   };
 
+  // Passkey login needs no email: the credential is discoverable and the
+  // server resolves the user from it. Cancelling the browser prompt is silent.
+  const handlePasskeyLogin = async () => {
+    setIsLoading(true);
+    try {
+      const options = await api.passkeyLoginOptions();
+      const assertion = await getPasskeyAssertion(options);
+      const { user, accessToken } = await api.passkeyLogin(assertion, { autoRedirectToAuth: false });
+      toast.success(t('auth.login.loginSuccess'));
+      await login(user, accessToken);
+      onLoginSuccess?.();
+    } catch (error) {
+      if (isPasskeyCancelled(error)) return;
+      if (error instanceof ApiError) {
+        toast.error(getApiErrorMessage(error.code, t, t('auth.login.passkeyFailed'), error.message));
+      } else {
+        toast.error(t('auth.login.passkeyFailed'));
+      }
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  // First TwoFactorRequired just reveals the field; the prompt explains why.
+  // Returns true when the error has been handled.
+  const handleTwoFactorError = (error: ApiError): boolean => {
+    if (error.code === ErrorCode.TwoFactorRequired && !totpRequired) {
+      setTotpRequired(true);
+      return true;
+    }
+    if (error.code === ErrorCode.InvalidTwoFactorCode) {
+      setTotpCode("");
+    }
+    return false;
+  };
+
+  // Turnstile tokens are single-use: hand the current one to the request and
+  // remount the widget so a retry gets a fresh challenge.
+  const takeCaptchaToken = (): string | undefined => {
+    if (!captchaToken) return undefined;
+    setCaptchaToken("");
+    setCaptchaKey((k) => k + 1);
+    return captchaToken;
+  };
+
+  // Login-risk step-ups. CaptchaRequired reveals the widget (only when the
+  // brand has a site key; otherwise fall through to the generic toast) and
+  // EmailCodeRequired switches the password tab over to the code flow.
+  // Returns true when the error has been handled.
+  const handleLoginRiskError = (error: ApiError): boolean => {
+    if (error.code === ErrorCode.CaptchaRequired && appConfig?.captchaSiteKey) {
+      toast.error(getApiErrorMessage(error.code, t, undefined, error.message));
+      setCaptchaRequired(true);
+      return true;
+    }
+    if (error.code === ErrorCode.EmailCodeRequired) {
+      toast.error(getApiErrorMessage(error.code, t, undefined, error.message));
+      setPassword("");
+      setCaptchaRequired(false);
+      setLoginMethod("code");
+      return true;
+    }
+    return false;
+  };
+
+  const needsCaptcha = captchaRequired && !!appConfig?.captchaSiteKey;
+
+  const renderCaptcha = () => needsCaptcha && (
+    <div className="space-y-2">
+      <p className="text-sm text-muted-foreground">
+        {t('errors.captchaRequired')}
+      </p>
+      <TurnstileWidget
+        key={captchaKey}
+        siteKey={appConfig!.captchaSiteKey!}
+        onVerify={setCaptchaToken}
+        onExpire={() => setCaptchaToken("")}
+      />
+    </div>
+  );
+
+  const renderTotpField = (id: string, onEnter?: () => void) => (
+    <div>
+      <p className="text-sm text-muted-foreground mb-2">
+        {t('auth.login.twoFactorPrompt')}
+      </p>
+      <Label htmlFor={id} className="flex items-center gap-2 text-base sm:text-sm font-bold sm:font-medium">
+        <ShieldCheck className="w-5 h-5 sm:w-4 sm:h-4" />
+        {t('auth.login.twoFactorCode')}
+      </Label>
+      <Input
+        id={id}
+        value={totpCode}
+        onChange={(e) => setTotpCode(e.target.value)}
+        onKeyDown={(e) => { if (onEnter && e.key === 'Enter' && totpCode.trim()) onEnter(); }}
+        placeholder={t('auth.login.twoFactorCodePlaceholder')}
+        className="mt-2 sm:mt-1 text-lg sm:text-base py-3 sm:py-2 px-4 sm:px-3"
+        autoComplete="one-time-code"
+        autoFocus
+      />
+    </div>
+  );
+
   const handleLogin = async (event: FormEvent<HTMLFormElement>) => {
     event.preventDefault();
     setIsLoading(true);
@@ -148,6 +278,7 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
         email,
         verificationCode: code,
         inviteCode: inviteCode.trim() || undefined, // 如果未激活，发送邀请码（可选）
+        totpCode: totpCode.trim() || undefined,
         language: userLanguage,
       }, {
         autoRedirectToAuth: false,
@@ -161,6 +292,7 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
       onLoginSuccess?.();
     } catch (error) {
       if (error instanceof ApiError) {
+        if (handleTwoFactorError(error)) return;
         toast.error(getApiErrorMessage(error.code, t, undefined, error.message));
         // On expired/missing code, clear the input AND send the user back to
         // step 1 so the "send code" button is the obvious next move.
@@ -179,6 +311,7 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
   return (
     <div className="space-y-1">
       {step === 1 ? (
+        <>
         <Tabs
           value={loginMethod}
           onValueChange={(v) => setLoginMethod(v as 'code' | 'password')}
@@ -220,10 +353,11 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
                   </p>
                 )}
               </div>
+              {isValidEmail(email) && renderCaptcha()}
               {isValidEmail(email) && (
                 <Button
                   onClick={handleSendCode}
-                  disabled={isLoading}
+                  disabled={isLoading || (needsCaptcha && !captchaToken)}
                   className="w-full font-bold text-lg sm:text-base py-6 sm:py-3"
                   size="lg"
                 >
@@ -291,9 +425,11 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
                   {t('auth.login.forgotPasswordHint')}
                 </p>
               </div>
+              {totpRequired && renderTotpField('login-totp-pw', handlePasswordLogin)}
+              {renderCaptcha()}
               <Button
                 onClick={handlePasswordLogin}
-                disabled={isLoading || !isValidEmail(email) || !password}
+                disabled={isLoading || !isValidEmail(email) || !password || (totpRequired && !totpCode.trim()) || (needsCaptcha && !captchaToken)}
                 className="w-full font-bold text-lg sm:text-base py-6 sm:py-3"
                 size="lg"
               >
@@ -311,6 +447,32 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
             </div>
           </TabsContent>
         </Tabs>
+        {(passkeySupported || oauthProviders.length > 0) && (
+          <div className="pt-3 space-y-3">
+            <div className="flex items-center gap-3 text-xs text-muted-foreground">
+              <div className="h-px flex-1 bg-border" />
+              {t('auth.login.or')}
+              <div className="h-px flex-1 bg-border" />
+            </div>
+            {passkeySupported && (
+              <Button
+                type="button"
+                variant="outline"
+                onClick={handlePasskeyLogin}
+                disabled={isLoading}
+                className="w-full text-lg sm:text-base py-6 sm:py-3"
+                size="lg"
+              >
+                <KeyRound className="w-5 h-5 sm:w-4 sm:h-4 mr-3 sm:mr-2" />
+                {t('auth.login.passkeyLogin')}
+              </Button>
+            )}
+            {oauthProviders.length > 0 && (
+              <OAuthLoginButtons providers={oauthProviders} disabled={isLoading} />
+            )}
+          </div>
+        )}
+        </>
       ) : (
         <form onSubmit={handleLogin} className="space-y-4 sm:space-y-4">
           {/* 如果用户未激活，显示提示信息 */}
@@ -367,6 +529,8 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
             </p>
           </div>
 
+          {totpRequired && renderTotpField('login-totp')}
+
           {/* 如果用户未激活，显示邀请码输入框 */}
           {!isActivated && (
             <div>
@@ -389,7 +553,7 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
           <div className="space-y-2">
             <Button
               type="submit"
-              disabled={isLoading || !code}
+              disabled={isLoading || !code || (totpRequired && !totpCode.trim())}
               className="w-full"
               size="lg"
             >
@@ -405,7 +569,7 @@ export default function EmailLogin({ onLoginSuccess, mode = 'login' }: EmailLogi
             <Button
               type="button"
               variant="ghost"
-              onClick={() => setStep(1)}
+              onClick={() => { setStep(1); setTotpRequired(false); setTotpCode(""); }}
               className="w-full text-muted-foreground"
               size="sm"
             >
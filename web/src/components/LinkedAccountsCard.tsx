@@ -0,0 +1,158 @@
+'use client';
+
+import { useCallback, useEffect, useState } from 'react';
+import { useTranslations } from 'next-intl';
+import { Card } from '@/components/ui/card';
+import { Button } from '@/components/ui/button';
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogHeader,
+  DialogTitle,
+  DialogFooter,
+} from '@/components/ui/dialog';
+import { toast } from 'sonner';
+import { api, ApiError, OAuthIdentityItem, OAuthProvider } from '@/lib/api';
+import { getApiErrorMessage } from '@/lib/api-errors';
+import { OAUTH_PROVIDER_NAMES, startOAuth } from '@/lib/oauth';
+import { Link2, Loader2 } from 'lucide-react';
+
+/**
+ * Account-security card for Google / Apple / GitHub sign-in.
+ *
+ * Lists the providers the brand offers with their link status. Linking
+ * leaves for the provider and comes back through /login/oauth; the last
+ * remaining sign-in method cannot be unlinked.
+ */
+export default function LinkedAccountsCard() {
+  const t = useTranslations();
+  const [providers, setProviders] = useState<OAuthProvider[]>([]);
+  const [identities, setIdentities] = useState<OAuthIdentityItem[] | null>(null);
+  const [unlinking, setUnlinking] = useState<OAuthProvider | null>(null);
+  const [submitting, setSubmitting] = useState(false);
+
+  const showError = useCallback(
+    (error: unknown) => {
+      if (error instanceof ApiError)
+        toast.error(getApiErrorMessage(error.code, t, error.message));
+      else toast.error(t('admin.account.linkedAccounts.operationFailed'));
+    },
+    [t],
+  );
+
+  const refresh = useCallback(async () => {
+    try {
+      const [available, linked] = await Promise.all([api.oauthProviders(), api.listOAuthIdentities()]);
+      setProviders(available.providers ?? []);
+      setIdentities(linked.items ?? []);
+    } catch (error) {
+      showError(error);
+    }
+  }, [showError]);
+
+  useEffect(() => {
+    refresh();
+  }, [refresh]);
+
+  const handleLink = async (provider: OAuthProvider) => {
+    setSubmitting(true);
+    try {
+      await startOAuth(provider, 'link', '/account/security');
+    } catch (error) {
+      showError(error);
+      setSubmitting(false);
+    }
+  };
+
+  const handleUnlink = async () => {
+    if (!unlinking) return;
+    setSubmitting(true);
+    try {
+      await api.oauthUnlink(unlinking);
+      toast.success(t('admin.account.linkedAccounts.unlinkSuccess'));
+      setUnlinking(null);
+      await refresh();
+    } catch (error) {
+      showError(error);
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  if (!identities) return null;
+  // 已绑定但品牌已下线的 provider 仍然展示，便于解绑
+  const shown = [
+    ...providers,
+    ...identities.map((i) => i.provider).filter((p) => !providers.includes(p)),
+  ];
+  if (shown.length === 0) return null;
+
+  return (
+    <Card className="p-6">
+      <div className="flex items-start gap-4">
+        <Link2 className="w-5 h-5 mt-0.5 text-muted-foreground" />
+        <div className="flex-1">
+          <h2 className="font-medium mb-1">{t('admin.account.linkedAccounts.title')}</h2>
+          <p className="text-sm text-muted-foreground mb-4">
+            {t('admin.account.linkedAccounts.description')}
+          </p>
+
+          <ul className="divide-y border rounded-md">
+            {shown.map((provider) => {
+              const identity = identities.find((i) => i.provider === provider);
+              return (
+                <li key={provider} className="flex items-center gap-3 px-3 py-2">
+                  <div className="flex-1 min-w-0">
+                    <div className="text-sm font-medium">{OAUTH_PROVIDER_NAMES[provider]}</div>
+                    <div className="text-xs text-muted-foreground truncate">
+                      {identity
+                        ? t('admin.account.linkedAccounts.linkedAs', {
+                            account: identity.account,
+                            date: new Date(identity.createdAt * 1000).toLocaleDateString(),
+                          })
+                        : t('admin.account.linkedAccounts.notLinked')}
+                    </div>
+                  </div>
+                  {identity ? (
+                    <Button variant="ghost" size="sm" onClick={() => setUnlinking(provider)}>
+                      {t('admin.account.linkedAccounts.unlink')}
+                    </Button>
+                  ) : (
+                    <Button
+                      variant="outline"
+                      size="sm"
+                      onClick={() => handleLink(provider)}
+                      disabled={submitting}
+                    >
+                      {t('admin.account.linkedAccounts.link')}
+                    </Button>
+                  )}
+                </li>
+              );
+            })}
+          </ul>
+        </div>
+      </div>
+
+      <Dialog open={!!unlinking} onOpenChange={(open) => !open && setUnlinking(null)}>
+        <DialogContent>
+          <DialogHeader>
+            <DialogTitle>{t('admin.account.linkedAccounts.unlink')}</DialogTitle>
+            <DialogDescription>
+              {t('admin.account.linkedAccounts.unlinkConfirm', {
+                provider: unlinking ? OAUTH_PROVIDER_NAMES[unlinking] : '',
+              })}
+            </DialogDescription>
+          </DialogHeader>
+          <DialogFooter>
+            <Button variant="destructive" onClick={handleUnlink} disabled={submitting}>
+              {submitting && <Loader2 className="w-4 h-4 mr-2 animate-spin" />}
+              {t('admin.account.linkedAccounts.unlink')}
+            </Button>
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+    </Card>
+  );
+}
@@ -0,0 +1,84 @@
+'use client';
+
+import { useEffect, useState } from 'react';
+import { useTranslations } from 'next-intl';
+import { usePathname } from '@/i18n/routing';
+import { Button } from '@/components/ui/button';
+import { toast } from 'sonner';
+import { api, ApiError, OAuthProvider } from '@/lib/api';
+import { getApiErrorMessage } from '@/lib/api-errors';
+import { OAUTH_PROVIDER_NAMES, startOAuth } from '@/lib/oauth';
+
+/** Providers the brand has configured; empty until loaded or when disabled. */
+export function useOAuthProviders(enabled = true): OAuthProvider[] {
+  const [providers, setProviders] = useState<OAuthProvider[]>([]);
+
+  useEffect(() => {
+    if (!enabled) return;
+    let cancelled = false;
+    (async () => {
+      try {
+        const res = await api.oauthProviders();
+        if (!cancelled) setProviders(res.providers ?? []);
+      } catch {
+        // 不可用时只是不展示第三方登录
+      }
+    })();
+    return () => {
+      cancelled = true;
+    };
+  }, [enabled]);
+
+  return providers;
+}
+
+/**
+ * "Continue with Google / Apple / GitHub" buttons. After the provider round
+ * trip, /login/oauth finishes the login and goes to `returnTo` (default:
+ * back to the current page).
+ */
+export default function OAuthLoginButtons({
+  providers,
+  disabled,
+  returnTo,
+}: {
+  providers: OAuthProvider[];
+  disabled?: boolean;
+  returnTo?: string;
+}) {
+  const t = useTranslations();
+  const pathname = usePathname();
+  const [starting, setStarting] = useState<OAuthProvider | null>(null);
+
+  const handleStart = async (provider: OAuthProvider) => {
+    setStarting(provider);
+    try {
+      await startOAuth(provider, 'login', returnTo ?? pathname + window.location.search);
+    } catch (error) {
+      if (error instanceof ApiError) {
+        toast.error(getApiErrorMessage(error.code, t, t('auth.login.oauthFailed'), error.message));
+      } else {
+        toast.error(t('auth.login.oauthFailed'));
+      }
+      setStarting(null);
+    }
+  };
+
+  return (
+    <div className="space-y-2">
+      {providers.map((provider) => (
+        <Button
+          key={provider}
+          type="button"
+          variant="outline"
+          onClick={() => handleStart(provider)}
+          disabled={disabled || starting !== null}
+          className="w-full text-lg sm:text-base py-6 sm:py-3"
+          size="lg"
+        >
+          {t('auth.login.oauthLogin', { provider: OAUTH_PROVIDER_NAMES[provider] })}
+        </Button>
+      ))}
+    </div>
+  );
+}
@@ -0,0 +1,241 @@
+'use client';
+
+import { useCallback, useEffect, useState } from 'react';
+import { useTranslations } from 'next-intl';
+import { Card } from '@/components/ui/card';
+import { Button } from '@/components/ui/button';
+import { Input } from '@/components/ui/input';
+import { Label } from '@/components/ui/label';
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogHeader,
+  DialogTitle,
+  DialogFooter,
+} from '@/components/ui/dialog';
+import { toast } from 'sonner';
+import { api, ApiError, Passkey } from '@/lib/api';
+import { getApiErrorMessage } from '@/lib/api-errors';
+import { createPasskey, isPasskeyCancelled, isPasskeySupported } from '@/lib/passkey';
+import { KeyRound, Loader2, Pencil, Trash2 } from 'lucide-react';
+
+/**
+ * Account-security card for passkeys (WebAuthn).
+ *
+ * Passkeys are bound to the brand domain they were created on, so the card
+ * only lists and adds credentials for the current site. Hidden entirely when
+ * the browser has no WebAuthn support.
+ */
+export default function PasskeyCard() {
+  const t = useTranslations();
+  const [supported, setSupported] = useState(false);
+  const [passkeys, setPasskeys] = useState<Passkey[] | null>(null);
+  const [adding, setAdding] = useState(false);
+  const [remark, setRemark] = useState('');
+  const [editing, setEditing] = useState<Passkey | null>(null);
+  const [deleting, setDeleting] = useState<Passkey | null>(null);
+  const [submitting, setSubmitting] = useState(false);
+
+  const showError = useCallback(
+    (error: unknown) => {
+      if (error instanceof ApiError)
+        toast.error(getApiErrorMessage(error.code, t, error.message));
+      else toast.error(t('admin.account.passkey.operationFailed'));
+    },
+    [t],
+  );
+
+  const refresh = useCallback(async () => {
+    try {
+      const res = await api.listPasskeys();
+      setPasskeys(res.items ?? []);
+    } catch (error) {
+      showError(error);
+    }
+  }, [showError]);
+
+  useEffect(() => {
+    if (!isPasskeySupported()) return;
+    setSupported(true);
+    refresh();
+  }, [refresh]);
+
+  const handleAdd = async () => {
+    setSubmitting(true);
+    try {
+      const options = await api.passkeyRegisterOptions();
+      const credential = await createPasskey(options, remark.trim() || undefined);
+      await api.registerPasskey(credential);
+      toast.success(t('admin.account.passkey.addSuccess'));
+      setAdding(false);
+      setRemark('');
+      await refresh();
+    } catch (error) {
+      // InvalidStateError: the authenticator already holds a passkey for this account
+      if (error instanceof DOMException && error.name === 'InvalidStateError') {
+        toast.error(t('admin.account.passkey.alreadyRegistered'));
+      } else if (!isPasskeyCancelled(error)) {
+        showError(error);
+      }
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleRename = async () => {
+    if (!editing || !remark.trim()) return;
+    setSubmitting(true);
+    try {
+      await api.updatePasskey(editing.id, remark.trim());
+      setEditing(null);
+      setRemark('');
+      await refresh();
+    } catch (error) {
+      showError(error);
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleDelete = async () => {
+    if (!deleting) return;
+    setSubmitting(true);
+    try {
+      await api.deletePasskey(deleting.id);
+      toast.success(t('admin.account.passkey.deleteSuccess'));
+      setDeleting(null);
+      await refresh();
+    } catch (error) {
+      showError(error);
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  if (!supported || !passkeys) return null;
+
+  return (
+    <Card className="p-6">
+      <div className="flex items-start gap-4">
+        <KeyRound className="w-5 h-5 mt-0.5 text-muted-foreground" />
+        <div className="flex-1">
+          <h2 className="font-medium mb-1">{t('admin.account.passkey.title')}</h2>
+          <p className="text-sm text-muted-foreground mb-4">
+            {t('admin.account.passkey.description')}
+          </p>
+
+          {passkeys.length > 0 && (
+            <ul className="divide-y border rounded-md mb-4">
+              {passkeys.map((p) => (
+                <li key={p.id} className="flex items-center gap-3 px-3 py-2">
+                  <div className="flex-1 min-w-0">
+                    <div className="text-sm font-medium truncate">
+                      {p.remark || t('admin.account.passkey.unnamed')}
+                      {p.synced && (
+                        <span className="ml-2 text-xs font-normal text-muted-foreground">
+                          {t('admin.account.passkey.synced')}
+                        </span>
+                      )}
+                    </div>
+                    <div className="text-xs text-muted-foreground">
+                      {t('admin.account.passkey.createdAt', {
+                        date: new Date(p.createdAt).toLocaleDateString(),
+                      })}
+                      {' · '}
+                      {p.lastUsedAt
+                        ? t('admin.account.passkey.lastUsedAt', {
+                            date: new Date(p.lastUsedAt * 1000).toLocaleDateString(),
+                          })
+                        : t('admin.account.passkey.neverUsed')}
+                    </div>
+                  </div>
+                  <Button
+                    variant="ghost"
+                    size="icon"
+                    aria-label={t('admin.account.passkey.rename')}
+                    onClick={() => {
+                      setEditing(p);
+                      setRemark(p.remark);
+                    }}
+                  >
+                    <Pencil className="w-4 h-4" />
+                  </Button>
+                  <Button
+                    variant="ghost"
+                    size="icon"
+                    aria-label={t('admin.account.passkey.delete')}
+                    onClick={() => setDeleting(p)}
+                  >
+                    <Trash2 className="w-4 h-4" />
+                  </Button>
+                </li>
+              ))}
+            </ul>
+          )}
+
+          <Button
+            onClick={() => {
+              setRemark('');
+              setAdding(true);
+            }}
+          >
+            {t('admin.account.passkey.add')}
+          </Button>
+        </div>
+      </div>
+
+      <Dialog open={adding || !!editing} onOpenChange={(open) => {
+        if (!open) {
+          setAdding(false);
+          setEditing(null);
+        }
+      }}>
+        <DialogContent>
+          <DialogHeader>
+            <DialogTitle>
+              {editing ? t('admin.account.passkey.rename') : t('admin.account.passkey.add')}
+            </DialogTitle>
+            {!editing && (
+              <DialogDescription>{t('admin.account.passkey.addHint')}</DialogDescription>
+            )}
+          </DialogHeader>
+          <div className="space-y-2">
+            <Label htmlFor="passkey-remark">{t('admin.account.passkey.remark')}</Label>
+            <Input
+              id="passkey-remark"
+              value={remark}
+              maxLength={100}
+              onChange={(e) => setRemark(e.target.value)}
+              placeholder={t('admin.account.passkey.remarkPlaceholder')}
+            />
+          </div>
+          <DialogFooter>
+            <Button
+              onClick={editing ? handleRename : handleAdd}
+              disabled={submitting || (!!editing && !remark.trim())}
+            >
+              {submitting && <Loader2 className="w-4 h-4 mr-2 animate-spin" />}
+              {editing ? t('admin.account.passkey.save') : t('admin.account.passkey.continue')}
+            </Button>
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+
+      <Dialog open={!!deleting} onOpenChange={(open) => !open && setDeleting(null)}>
+        <DialogContent>
+          <DialogHeader>
+            <DialogTitle>{t('admin.account.passkey.delete')}</DialogTitle>
+            <DialogDescription>{t('admin.account.passkey.deleteConfirm')}</DialogDescription>
+          </DialogHeader>
+          <DialogFooter>
+            <Button variant="destructive" onClick={handleDelete} disabled={submitting}>
+              {submitting && <Loader2 className="w-4 h-4 mr-2 animate-spin" />}
+              {t('admin.account.passkey.delete')}
+            </Button>
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+    </Card>
+  );
+}
@@ -0,0 +1,151 @@
+'use client';
+
+import { useCallback, useEffect, useState } from 'react';
+import { useTranslations } from 'next-intl';
+import { Card } from '@/components/ui/card';
+import { Button } from '@/components/ui/button';
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogHeader,
+  DialogTitle,
+  DialogFooter,
+} from '@/components/ui/dialog';
+import { toast } from 'sonner';
+import { api, ApiError, AuthSessionItem } from '@/lib/api';
+import { getApiErrorMessage } from '@/lib/api-errors';
+import { Globe, Loader2, LogOut, MonitorSmartphone, Router } from 'lucide-react';
+
+function SessionIcon({ session }: { session: AuthSessionItem }) {
+  if (session.clientClass === 'web') return <Globe className="w-4 h-4 text-muted-foreground" />;
+  if (session.clientClass === 'router') return <Router className="w-4 h-4 text-muted-foreground" />;
+  return <MonitorSmartphone className="w-4 h-4 text-muted-foreground" />;
+}
+
+/**
+ * Account-security card listing active login sessions.
+ *
+ * Each login (web console or app device) is one session; revoking it ends
+ * that login everywhere. The current session is marked and can only be
+ * ended by logging out.
+ */
+export default function SessionsCard() {
+  const t = useTranslations();
+  const [sessions, setSessions] = useState<AuthSessionItem[] | null>(null);
+  const [revoking, setRevoking] = useState<AuthSessionItem | null>(null);
+  const [submitting, setSubmitting] = useState(false);
+
+  const showError = useCallback(
+    (error: unknown) => {
+      if (error instanceof ApiError)
+        toast.error(getApiErrorMessage(error.code, t, error.message));
+      else toast.error(t('admin.account.sessions.operationFailed'));
+    },
+    [t],
+  );
+
+  const refresh = useCallback(async () => {
+    try {
+      const res = await api.listSessions();
+      setSessions(res.items ?? []);
+    } catch (error) {
+      showError(error);
+    }
+  }, [showError]);
+
+  useEffect(() => {
+    refresh();
+  }, [refresh]);
+
+  const handleRevoke = async () => {
+    if (!revoking) return;
+    setSubmitting(true);
+    try {
+      await api.revokeSession(revoking.id);
+      toast.success(t('admin.account.sessions.revokeSuccess'));
+      setRevoking(null);
+      await refresh();
+    } catch (error) {
+      showError(error);
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const sessionName = (s: AuthSessionItem) => {
+    if (s.clientClass === 'web') return t('admin.account.sessions.web');
+    if (s.deviceRemark) return s.deviceRemark;
+    if (s.clientClass === 'router') return t('admin.account.sessions.router');
+    return s.platform || t('admin.account.sessions.app');
+  };
+
+  if (!sessions) return null;
+
+  return (
+    <Card className="p-6">
+      <div className="flex items-start gap-4">
+        <MonitorSmartphone className="w-5 h-5 mt-0.5 text-muted-foreground" />
+        <div className="flex-1">
+          <h2 className="font-medium mb-1">{t('admin.account.sessions.title')}</h2>
+          <p className="text-sm text-muted-foreground mb-4">
+            {t('admin.account.sessions.description')}
+          </p>
+
+          <ul className="divide-y border rounded-md">
+            {sessions.map((s) => (
+              <li key={s.id} className="flex items-center gap-3 px-3 py-2">
+                <SessionIcon session={s} />
+                <div className="flex-1 min-w-0">
+                  <div className="text-sm font-medium truncate">
+                    {sessionName(s)}
+                    {s.current && (
+                      <span className="ml-2 text-xs font-normal text-primary">
+                        {t('admin.account.sessions.current')}
+                      </span>
+                    )}
+                  </div>
+                  <div className="text-xs text-muted-foreground">
+                    {s.ipCountry && `${s.ipCountry.toUpperCase()} · `}
+                    {t('admin.account.sessions.lastSeenAt', {
+                      date: new Date(s.lastSeenAt * 1000).toLocaleString(),
+                    })}
+                  </div>
+                </div>
+                {!s.current && (
+                  <Button
+                    variant="ghost"
+                    size="icon"
+                    aria-label={t('admin.account.sessions.revoke')}
+                    onClick={() => setRevoking(s)}
+                  >
+                    <LogOut className="w-4 h-4" />
+                  </Button>
+                )}
+              </li>
+            ))}
+          </ul>
+        </div>
+      </div>
+
+      <Dialog open={!!revoking} onOpenChange={(open) => !open && setRevoking(null)}>
+        <DialogContent>
+          <DialogHeader>
+            <DialogTitle>{t('admin.account.sessions.revoke')}</DialogTitle>
+            <DialogDescription>
+              {t('admin.account.sessions.revokeConfirm', {
+                name: revoking ? sessionName(revoking) : '',
+              })}
+            </DialogDescription>
+          </DialogHeader>
+          <DialogFooter>
+            <Button variant="destructive" onClick={handleRevoke} disabled={submitting}>
+              {submitting && <Loader2 className="w-4 h-4 mr-2 animate-spin" />}
+              {t('admin.account.sessions.revoke')}
+            </Button>
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+    </Card>
+  );
+}
@@ -0,0 +1,69 @@
+"use client";
+
+import { useEffect, useRef, useState } from "react";
+import Script from "next/script";
+
+// Cloudflare Turnstile 的最小类型声明（仅用到 render / remove）
+interface TurnstileApi {
+  render: (container: HTMLElement, options: {
+    sitekey: string;
+    callback: (token: string) => void;
+    "expired-callback"?: () => void;
+    "error-callback"?: () => void;
+  }) => string;
+  remove: (widgetId: string) => void;
+}
+
+declare global {
+  interface Window {
+    turnstile?: TurnstileApi;
+  }
+}
+
+export interface TurnstileWidgetProps {
+  siteKey: string;
+  onVerify: (token: string) => void;
+  // token 过期或校验出错时清空上层保存的 token
+  onExpire?: () => void;
+}
+
+/**
+ * 登录风控的人机验证挂件。token 一次性有效：提交后上层应通过改变 key 重新挂载。
+ */
+export default function TurnstileWidget({ siteKey, onVerify, onExpire }: TurnstileWidgetProps) {
+  const containerRef = useRef<HTMLDivElement>(null);
+  const [scriptReady, setScriptReady] = useState(
+    typeof window !== "undefined" && !!window.turnstile,
+  );
+
+  // 回调放进 ref，避免父组件每次渲染都重建挂件
+  const onVerifyRef = useRef(onVerify);
+  const onExpireRef = useRef(onExpire);
+  onVerifyRef.current = onVerify;
+  onExpireRef.current = onExpire;
+
+  useEffect(() => {
+    if (!scriptReady || !containerRef.current || !window.turnstile) return;
+    const widgetId = window.turnstile.render(containerRef.current, {
+      sitekey: siteKey,
+      callback: (token) => onVerifyRef.current(token),
+      "expired-callback": () => onExpireRef.current?.(),
+      "error-callback": () => onExpireRef.current?.(),
+    });
+    return () => {
+      window.turnstile?.remove(widgetId);
+    };
+  }, [scriptReady, siteKey]);
+
+  return (
+    <>
+      <Script
+        id="cf-turnstile"
+        src="https://challenges.cloudflare.com/turnstile/v0/api.js?render=explicit"
+        strategy="afterInteractive"
+        onReady={() => setScriptReady(true)}
+      />
+      <div ref={containerRef} className="flex justify-center" />
+    </>
+  );
+}
@@ -0,0 +1,286 @@
+'use client';
+
+import { useCallback, useEffect, useState } from 'react';
+import { useTranslations } from 'next-intl';
+import QRCode from 'qrcode';
+import { Card } from '@/components/ui/card';
+import { Button } from '@/components/ui/button';
+import { Input } from '@/components/ui/input';
+import { Label } from '@/components/ui/label';
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogHeader,
+  DialogTitle,
+  DialogFooter,
+} from '@/components/ui/dialog';
+import { toast } from 'sonner';
+import { api, ApiError, TwoFactorSetup, TwoFactorStatus } from '@/lib/api';
+import { getApiErrorMessage } from '@/lib/api-errors';
+import { Loader2, ShieldCheck } from 'lucide-react';
+
+type Action = 'disable' | 'regenerate';
+
+/**
+ * Account-security card for TOTP two-factor authentication.
+ *
+ * Flow: setup (QR + manual secret) → enable with a code from the app →
+ * recovery codes shown once. Once enabled, the same code input drives
+ * "regenerate recovery codes" and "disable"; the latter is hidden for
+ * admin-role accounts where 2FA is mandatory.
+ */
+export default function TwoFactorCard() {
+  const t = useTranslations();
+  const [status, setStatus] = useState<TwoFactorStatus | null>(null);
+  const [setup, setSetup] = useState<TwoFactorSetup | null>(null);
+  const [qrDataUrl, setQrDataUrl] = useState('');
+  const [code, setCode] = useState('');
+  const [action, setAction] = useState<Action | null>(null);
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+  const [submitting, setSubmitting] = useState(false);
+
+  const showError = useCallback(
+    (error: unknown) => {
+      if (error instanceof ApiError)
+        toast.error(getApiErrorMessage(error.code, t, error.message));
+      else toast.error(t('admin.account.twoFactor.operationFailed'));
+    },
+    [t],
+  );
+
+  const refresh = useCallback(async () => {
+    try {
+      setStatus(await api.getTwoFactor());
+    } catch (error) {
+      showError(error);
+    }
+  }, [showError]);
+
+  useEffect(() => {
+    refresh();
+  }, [refresh]);
+
+  useEffect(() => {
+    if (!setup) {
+      setQrDataUrl('');
+      return;
+    }
+    let cancelled = false;
+    QRCode.toDataURL(setup.otpauthUrl, { margin: 1, width: 192 }).then((url) => {
+      if (!cancelled) setQrDataUrl(url);
+    });
+    return () => {
+      cancelled = true;
+    };
+  }, [setup]);
+
+  const handleSetup = async () => {
+    setSubmitting(true);
+    try {
+      setSetup(await api.setupTwoFactor());
+      setCode('');
+    } catch (error) {
+      showError(error);
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleEnable = async () => {
+    if (!code.trim()) return;
+    setSubmitting(true);
+    try {
+      const res = await api.enableTwoFactor(code.trim());
+      toast.success(t('admin.account.twoFactor.enableSuccess'));
+      setSetup(null);
+      setCode('');
+      setRecoveryCodes(res.recoveryCodes);
+      await refresh();
+    } catch (error) {
+      showError(error);
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleAction = async () => {
+    if (!action || !code.trim()) return;
+    setSubmitting(true);
+    try {
+      if (action === 'disable') {
+        setStatus(await api.disableTwoFactor(code.trim()));
+        toast.success(t('admin.account.twoFactor.disableSuccess'));
+      } else {
+        const res = await api.regenerateRecoveryCodes(code.trim());
+        setRecoveryCodes(res.recoveryCodes);
+        await refresh();
+      }
+      setAction(null);
+      setCode('');
+    } catch (error) {
+      showError(error);
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const copyRecoveryCodes = async () => {
+    try {
+      await navigator.clipboard.writeText(recoveryCodes.join('\n'));
+      toast.success(t('admin.account.twoFactor.copied'));
+    } catch {
+      toast.error(t('admin.account.twoFactor.operationFailed'));
+    }
+  };
+
+  if (!status) return null;
+
+  return (
+    <Card className="p-6">
+      <div className="flex items-start gap-4">
+        <ShieldCheck className="w-5 h-5 mt-0.5 text-muted-foreground" />
+        <div className="flex-1">
+          <h2 className="font-medium mb-1">
+            {t('admin.account.twoFactor.title')}
+            <span className="ml-2 text-xs font-normal text-muted-foreground">
+              {status.enabled
+                ? t('admin.account.twoFactor.enabled')
+                : t('admin.account.twoFactor.disabled')}
+            </span>
+          </h2>
+          <p className="text-sm text-muted-foreground mb-4">
+            {t('admin.account.twoFactor.description')}
+          </p>
+          {status.required && (
+            <p className="text-sm text-amber-600 mb-4">{t('admin.account.twoFactor.required')}</p>
+          )}
+
+          {!status.enabled && !setup && (
+            <Button onClick={handleSetup} disabled={submitting}>
+              {submitting && <Loader2 className="mr-2 h-4 w-4 animate-spin" />}
+              {t('admin.account.twoFactor.enable')}
+            </Button>
+          )}
+
+          {!status.enabled && setup && (
+            <div className="space-y-4">
+              <p className="text-sm">{t('admin.account.twoFactor.scanQr')}</p>
+              {qrDataUrl && (
+                // eslint-disable-next-line @next/next/no-img-element
+                <img src={qrDataUrl} alt="otpauth QR" width={192} height={192} className="rounded border" />
+              )}
+              <div>
+                <p className="text-xs text-muted-foreground">{t('admin.account.twoFactor.manualEntry')}</p>
+                <code className="text-sm break-all select-all">{setup.secret}</code>
+              </div>
+              <div>
+                <Label htmlFor="two-factor-enable-code">{t('admin.account.twoFactor.code')}</Label>
+                <Input
+                  id="two-factor-enable-code"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  onKeyDown={(e) => { if (e.key === 'Enter') handleEnable(); }}
+                  placeholder={t('admin.account.twoFactor.codePlaceholder')}
+                  autoComplete="one-time-code"
+                  inputMode="numeric"
+                  className="mt-1 max-w-xs"
+                />
+              </div>
+              <div className="flex gap-2">
+                <Button onClick={handleEnable} disabled={submitting || !code.trim()}>
+                  {submitting && <Loader2 className="mr-2 h-4 w-4 animate-spin" />}
+                  {t('admin.account.twoFactor.confirmEnable')}
+                </Button>
+                <Button variant="outline" onClick={() => setSetup(null)} disabled={submitting}>
+                  {t('common.common.cancel')}
+                </Button>
+              </div>
+            </div>
+          )}
+
+          {status.enabled && (
+            <div className="space-y-4">
+              <p className="text-sm text-muted-foreground">
+                {t('admin.account.twoFactor.recoveryRemaining', { count: status.recoveryCodesRemaining })}
+              </p>
+              <div className="flex gap-2">
+                <Button variant="outline" onClick={() => setAction('regenerate')}>
+                  {t('admin.account.twoFactor.regenerate')}
+                </Button>
+                {!status.required && (
+                  <Button variant="destructive" onClick={() => setAction('disable')}>
+                    {t('admin.account.twoFactor.disable')}
+                  </Button>
+                )}
+              </div>
+            </div>
+          )}
+        </div>
+      </div>
+
+      {/* 敏感操作：需要当前验证码或恢复码 */}
+      <Dialog
+        open={action !== null}
+        onOpenChange={(o) => {
+          if (!o && !submitting) {
+            setAction(null);
+            setCode('');
+          }
+        }}
+      >
+        <DialogContent className="sm:max-w-md">
+          <DialogHeader>
+            <DialogTitle>
+              {action === 'disable'
+                ? t('admin.account.twoFactor.disable')
+                : t('admin.account.twoFactor.regenerate')}
+            </DialogTitle>
+            <DialogDescription>{t('admin.account.twoFactor.confirmWithCode')}</DialogDescription>
+          </DialogHeader>
+          <Input
+            value={code}
+            onChange={(e) => setCode(e.target.value)}
+            onKeyDown={(e) => { if (e.key === 'Enter') handleAction(); }}
+            placeholder={t('admin.account.twoFactor.actionCodePlaceholder')}
+            autoComplete="one-time-code"
+          />
+          <DialogFooter>
+            <Button variant="outline" onClick={() => setAction(null)} disabled={submitting}>
+              {t('common.common.cancel')}
+            </Button>
+            <Button
+              variant={action === 'disable' ? 'destructive' : 'default'}
+              onClick={handleAction}
+              disabled={submitting || !code.trim()}
+            >
+              {submitting && <Loader2 className="mr-2 h-4 w-4 animate-spin" />}
+              {t('common.common.confirm')}
+            </Button>
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+
+      {/* 恢复码只展示一次 */}
+      <Dialog open={recoveryCodes.length > 0} onOpenChange={(o) => !o && setRecoveryCodes([])}>
+        <DialogContent className="sm:max-w-md">
+          <DialogHeader>
+            <DialogTitle>{t('admin.account.twoFactor.recoveryTitle')}</DialogTitle>
+            <DialogDescription>{t('admin.account.twoFactor.recoveryHint')}</DialogDescription>
+          </DialogHeader>
+          <div className="grid grid-cols-2 gap-2 rounded border bg-muted p-4 font-mono text-sm">
+            {recoveryCodes.map((rc) => (
+              <span key={rc}>{rc}</span>
+            ))}
+          </div>
+          <DialogFooter>
+            <Button variant="outline" onClick={copyRecoveryCodes}>
+              {t('admin.account.twoFactor.copy')}
+            </Button>
+            <Button onClick={() => setRecoveryCodes([])}>{t('admin.account.twoFactor.done')}</Button>
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+    </Card>
+  );
+}
@@ -21,10 +21,15 @@ vi.mock('@/lib/api', () => {
       passwordLogin: vi
         .fn()
         .mockResolvedValue({ user: { id: 1, email: 'a@b.com' }, accessToken: 't' }),
+      oauthProviders: vi.fn().mockResolvedValue({ providers: [] }),
     },
     ApiError,
     ErrorCode: {
       VerificationCodeExpired: 400013,
+      TwoFactorRequired: 400014,
+      InvalidTwoFactorCode: 400015,
+      CaptchaRequired: 400017,
+      EmailCodeRequired: 400018,
       InvalidArgument: 422,
       InvalidOperation: 400,
     },
@@ -37,17 +42,30 @@ vi.mock('@/contexts/AuthContext', () => ({
   AuthProvider: ({ children }: { children: React.ReactNode }) => <>{children}</>,
 }));
 
+let mockAppConfig: { captchaSiteKey?: string } | null = null;
 vi.mock('@/contexts/AppConfigContext', () => ({
-  useAppConfig: () => ({ appConfig: null }),
+  useAppConfig: () => ({ appConfig: mockAppConfig }),
   AppConfigProvider: ({ children }: { children: React.ReactNode }) => <>{children}</>,
 }));
 
+vi.mock('@/i18n/routing', () => ({
+  usePathname: () => '/',
+}));
+
+// Turnstile loads a third-party script; stand in a button that "solves" it.
+vi.mock('@/components/TurnstileWidget', () => ({
+  default: ({ onVerify }: { onVerify: (token: string) => void }) => (
+    <button type="button" onClick={() => onVerify('captcha-token')}>solve-captcha</button>
+  ),
+}));
+
 vi.mock('sonner', () => ({
   toast: { error: vi.fn(), success: vi.fn() },
 }));
 
 beforeEach(() => {
   vi.clearAllMocks();
+  mockAppConfig = null;
 });
 
 describe('EmailLogin password tab', () => {
@@ -131,4 +149,80 @@ describe('EmailLogin password tab', () => {
     );
     await waitFor(() => expect(onLoginSuccess).toHaveBeenCalledTimes(1));
   });
+
+  it('reveals the two-factor field on TwoFactorRequired and resubmits with totpCode', async () => {
+    const { api, ApiError } = await import('@/lib/api');
+    const { toast } = await import('sonner');
+    vi.mocked(api.passwordLogin).mockRejectedValueOnce(
+      new ApiError(400014 as never, 'two-factor code required'),
+    );
+    render(<EmailLogin />);
+    fireEvent.click(screen.getByText('auth.login.passwordLogin'));
+    fireEvent.change(document.getElementById('login-email-pw')!, { target: { value: 'a@b.com' } });
+    fireEvent.change(document.getElementById('login-password')!, { target: { value: 'k7N#mq2P!xT9' } });
+    const submit = () =>
+      fireEvent.click(
+        screen.getAllByRole('button').find((b) => (b.textContent || '').includes('auth.login.loginButton'))!,
+      );
+
+    submit();
+    await waitFor(() => expect(document.getElementById('login-totp-pw')).toBeTruthy());
+    // The first TwoFactorRequired is a prompt, not an error.
+    expect(toast.error).not.toHaveBeenCalled();
+    expect(mockLogin).not.toHaveBeenCalled();
+
+    fireEvent.change(document.getElementById('login-totp-pw')!, { target: { value: '123456' } });
+    submit();
+    await waitFor(() => expect(mockLogin).toHaveBeenCalled());
+    expect(api.passwordLogin).toHaveBeenLastCalledWith(
+      expect.objectContaining({ email: 'a@b.com', password: 'k7N#mq2P!xT9', totpCode: '123456' }),
+      expect.anything(),
+    );
+  });
+
+  it('shows the captcha on CaptchaRequired and resubmits with the token', async () => {
+    const { api, ApiError } = await import('@/lib/api');
+    mockAppConfig = { captchaSiteKey: 'site-key' };
+    vi.mocked(api.passwordLogin).mockRejectedValueOnce(
+      new ApiError(400017 as never, 'captcha required'),
+    );
+    render(<EmailLogin />);
+    fireEvent.click(screen.getByText('auth.login.passwordLogin'));
+    fireEvent.change(document.getElementById('login-email-pw')!, { target: { value: 'a@b.com' } });
+    fireEvent.change(document.getElementById('login-password')!, { target: { value: 'k7N#mq2P!xT9' } });
+    const submitBtn = () =>
+      screen.getAllByRole('button').find((b) => (b.textContent || '').includes('auth.login.loginButton'))!;
+
+    fireEvent.click(submitBtn());
+    await waitFor(() => expect(screen.getByText('solve-captcha')).toBeInTheDocument());
+    // Submitting again is blocked until the challenge is solved.
+    expect(submitBtn()).toBeDisabled();
+
+    fireEvent.click(screen.getByText('solve-captcha'));
+    fireEvent.click(submitBtn());
+    await waitFor(() => expect(mockLogin).toHaveBeenCalled());
+    expect(api.passwordLogin).toHaveBeenLastCalledWith(
+      expect.objectContaining({ email: 'a@b.com', captchaToken: 'captcha-token' }),
+      expect.anything(),
+    );
+  });
+
+  it('switches to the code tab on EmailCodeRequired', async () => {
+    const { api, ApiError } = await import('@/lib/api');
+    vi.mocked(api.passwordLogin).mockRejectedValueOnce(
+      new ApiError(400018 as never, 'email code required'),
+    );
+    render(<EmailLogin />);
+    fireEvent.click(screen.getByText('auth.login.passwordLogin'));
+    fireEvent.change(document.getElementById('login-email-pw')!, { target: { value: 'a@b.com' } });
+    fireEvent.change(document.getElementById('login-password')!, { target: { value: 'k7N#mq2P!xT9' } });
+    fireEvent.click(
+      screen.getAllByRole('button').find((b) => (b.textContent || '').includes('auth.login.loginButton'))!,
+    );
+
+    // The code tab keeps the email, so sending a code is one click away.
+    await waitFor(() => expect(document.getElementById('login-email')).toBeTruthy());
+    expect((document.getElementById('login-email') as HTMLInputElement).value).toBe('a@b.com');
+    expect(mockLogin).not.toHaveBeenCalled();
+  });
 });
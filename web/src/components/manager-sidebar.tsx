@@ -5,7 +5,7 @@ import { usePathname } from "next/navigation";
 import Link from "next/link";
 import { cn } from "@/lib/utils";
 import { api } from "@/lib/api";
-import { Package, Users, Server, Receipt, Tag, Wallet, FileText, Activity, LogOut, Gauge, UserCircle, ClipboardList, Cloud, BarChart3, Key, MessageSquare, ShieldCheck, Megaphone, BookOpen, Router } from "lucide-react";
+import { Package, Users, Server, Receipt, Tag, Wallet, FileText, Activity, LogOut, Gauge, UserCircle, ClipboardList, Cloud, BarChart3, Key, MessageSquare, ShieldCheck, Megaphone, BookOpen, Router, ShieldAlert } from "lucide-react";
 import Image from "next/image";
 import { useAuth } from "@/contexts/AuthContext";
 import { Button } from "@/components/ui/button";
@@ -82,6 +82,7 @@ const menuGroups: MenuGroup[] = [
     items: [
       { href: "/manager/usages", icon: BarChart3, label: "使用统计" },
       { href: "/manager/surveys", icon: ClipboardList, label: "问卷统计" },
+      { href: "/manager/login-risk", icon: ShieldAlert, label: "登录风控" },
       { href: "/manager/asynqmon", icon: Gauge, label: "任务队列" },
     ]
   },
@@ -0,0 +1,27 @@
+import { describe, it, expect } from 'vitest';
+import { base64urlToBuffer, bufferToBase64url, isPasskeyCancelled } from '../passkey';
+
+describe('base64url', () => {
+  it('round-trips bytes that need url-safe characters', () => {
+    const bytes = new Uint8Array([0xfb, 0xff, 0xbf, 0x00, 0x3e]);
+    const encoded = bufferToBase64url(bytes.buffer);
+    expect(encoded).toBe('-_-_AD4');
+    expect(new Uint8Array(base64urlToBuffer(encoded))).toEqual(bytes);
+  });
+
+  it('decodes unpadded input of every length', () => {
+    for (const len of [0, 1, 2, 3, 4]) {
+      const bytes = new Uint8Array(len).map((_, i) => i + 1);
+      expect(new Uint8Array(base64urlToBuffer(bufferToBase64url(bytes.buffer)))).toEqual(bytes);
+    }
+  });
+});
+
+describe('isPasskeyCancelled', () => {
+  it('treats NotAllowedError and AbortError as cancellation', () => {
+    expect(isPasskeyCancelled(new DOMException('x', 'NotAllowedError'))).toBe(true);
+    expect(isPasskeyCancelled(new DOMException('x', 'AbortError'))).toBe(true);
+    expect(isPasskeyCancelled(new DOMException('x', 'InvalidStateError'))).toBe(false);
+    expect(isPasskeyCancelled(new Error('boom'))).toBe(false);
+  });
+});
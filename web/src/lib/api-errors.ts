@@ -54,6 +54,20 @@ export function getApiErrorMessage(
       return t('errors.invalidVerificationCode');
     case ErrorCode.VerificationCodeExpired:
       return t('errors.verificationCodeExpired');
+    case ErrorCode.TwoFactorRequired:
+      return t('errors.twoFactorRequired');
+    case ErrorCode.InvalidTwoFactorCode:
+      return t('errors.invalidTwoFactorCode');
+    case ErrorCode.TwoFactorSetupRequired:
+      return t('errors.twoFactorSetupRequired');
+    case ErrorCode.OAuthEmailUnverified:
+      return t('errors.oauthEmailUnverified');
+    case ErrorCode.CaptchaRequired:
+      return t('errors.captchaRequired');
+    case ErrorCode.EmailCodeRequired:
+      return t('errors.emailCodeRequired');
+    case ErrorCode.IdentityAlreadyLinked:
+      return t('errors.identityAlreadyLinked');
     case ErrorCode.InvalidInviteCode:
       return t('errors.invalidInviteCode');
     case ErrorCode.SelfInvitation:
@@ -91,6 +105,13 @@ const zhMessages: Record<number, string> = {
   [ErrorCode.InvalidClientClock]: '设备时间不正确，请校准系统时间',
   [ErrorCode.InvalidVerificationCode]: '验证码错误，请检查后重试',
   [ErrorCode.VerificationCodeExpired]: '验证码已过期或未发送，请点击重新发送',
+  [ErrorCode.TwoFactorRequired]: '请输入两步验证码',
+  [ErrorCode.InvalidTwoFactorCode]: '两步验证码或恢复码错误',
+  [ErrorCode.TwoFactorSetupRequired]: '管理员账号须先在“账号安全”中启用两步验证',
+  [ErrorCode.OAuthEmailUnverified]: '该第三方账号没有已验证的邮箱，请改用邮箱登录',
+  [ErrorCode.CaptchaRequired]: '请完成人机验证后继续',
+  [ErrorCode.EmailCodeRequired]: '为了账号安全，请改用邮箱验证码登录',
+  [ErrorCode.IdentityAlreadyLinked]: '该第三方账号已绑定其他账号',
   [ErrorCode.InvalidInviteCode]: '邀请码不正确',
   [ErrorCode.SelfInvitation]: '不能使用自己的邀请码',
   [ErrorCode.InvalidCredentials]: '登录凭证无效',
@@ -295,6 +295,56 @@ export interface ConnectionRatingStatisticsResponse {
   }>;
 }
 
+// 连接成功率看板（telemetry rollup）
+export type TelemetryDimension =
+  | 'protocol_chain'
+  | 'country'
+  | 'carrier'
+  | 'asn'
+  | 'app_version'
+  | 'node'
+  | 'rules_version';
+
+export interface TelemetryConnectStats {
+  attempts: number;
+  successes: number;
+  failures: number;
+  successRate: number;
+  deviceDays: number;
+  avgConnectMs: number;
+  connectUnder1s: number;
+  connectUnder3s: number;
+  connectOver3s: number;
+}
+
+export interface TelemetryFailureRow {
+  reason: string;
+  count: number;
+}
+
+export interface TelemetryOverviewResponse {
+  range: string;
+  since: string;
+  totals: TelemetryConnectStats;
+  days: Array<TelemetryConnectStats & { day: string }>;
+  topFailures: TelemetryFailureRow[];
+}
+
+export interface TelemetryBreakdownResponse {
+  range: string;
+  since: string;
+  dimension: TelemetryDimension;
+  rows: Array<TelemetryConnectStats & { value: string }>;
+}
+
+export interface TelemetryFailuresResponse {
+  range: string;
+  since: string;
+  dimension: string;
+  value?: string;
+  reasons: TelemetryFailureRow[];
+}
+
 export interface AdminTestDeviceData {
   udid: string;
   password: string;
@@ -470,6 +520,7 @@ export interface AppConfig {
   appDownload: AppDownload;
   appLinks: AppLinks;
   inviteReward: InviteConfig;
+  captchaSiteKey?: string; // Turnstile 站点 key；未配置人机验证时缺省
 }
 
 // 认证相关类型
@@ -494,6 +545,7 @@ export interface AuthUser {
 export interface SendAuthCodeRequest {
   email: string;
   language?: string;
+  captchaToken?: string; // 登录风控要求人机验证时携带
 }
 
 export interface WebLoginRequest {
@@ -501,6 +553,7 @@ export interface WebLoginRequest {
   verificationCode: string;
   language?: string;
   inviteCode?: string; // 邀请码（可选，仅未激活用户可设置）
+  totpCode?: string;   // 两步验证码或恢复码（收到 TwoFactorRequired 后携带）
 }
 
 // Web登录响应 - tokens通过HttpOnly Cookie设置，response只返回user信息
@@ -528,6 +581,8 @@ export interface PasswordLoginRequest {
   password: string;
   language?: string;
   inviteCode?: string;
+  totpCode?: string;
+  captchaToken?: string; // 登录风控要求人机验证时携带
 }
 
 // 设置 / 修改密码请求 (POST /api/user/password)
@@ -536,6 +591,119 @@ export interface SetPasswordRequest {
   confirmPassword: string;
 }
 
+// 两步验证状态 (GET /api/user/2fa)
+export interface TwoFactorStatus {
+  enabled: boolean;
+  enabledAt: number;
+  recoveryCodesRemaining: number;
+  required: boolean; // 管理角色账号必须启用，且不可关闭
+}
+
+// 两步验证待确认密钥 (POST /api/user/2fa/setup)
+export interface TwoFactorSetup {
+  secret: string;
+  otpauthUrl: string;
+}
+
+// 恢复码（明文只返回一次）
+export interface RecoveryCodesResponse {
+  recoveryCodes: string[];
+}
+
+// 已登记的 passkey (GET /api/user/passkeys)
+export interface Passkey {
+  id: number;
+  createdAt: string;
+  credentialId: string;
+  algorithm: number;
+  aaguid: string;
+  synced: boolean;     // 已在云端同步（iCloud 钥匙串、Google 密码管理器等）
+  remark: string;
+  lastUsedAt: number;  // 秒级时间戳，0 表示从未使用
+}
+
+// navigator.credentials.create() 参数（二进制字段均为 base64url）
+export interface PasskeyCreationOptions {
+  challenge: string;
+  rp: { id: string; name: string };
+  user: { id: string; name: string; displayName: string };
+  pubKeyCredParams: { type: 'public-key'; alg: number }[];
+  timeout: number;
+  attestation: AttestationConveyancePreference;
+  authenticatorSelection: AuthenticatorSelectionCriteria;
+  excludeCredentials: PasskeyCredentialDescriptor[];
+}
+
+// navigator.credentials.get() 参数（二进制字段均为 base64url）
+export interface PasskeyRequestOptions {
+  challenge: string;
+  rpId: string;
+  timeout: number;
+  userVerification: UserVerificationRequirement;
+  allowCredentials: PasskeyCredentialDescriptor[];
+}
+
+export interface PasskeyCredentialDescriptor {
+  type: 'public-key';
+  id: string;
+  transports?: AuthenticatorTransport[];
+}
+
+// passkey 登记结果 (POST /api/user/passkeys/register)
+export interface PasskeyRegisterRequest {
+  id: string;
+  clientDataJSON: string;
+  attestationObject: string;
+  transports?: string[];
+  remark?: string;
+}
+
+// passkey 登录断言 (POST /api/auth/web-login/passkey)
+export interface PasskeyLoginRequest {
+  id: string;
+  clientDataJSON: string;
+  authenticatorData: string;
+  signature: string;
+  userHandle?: string;
+}
+
+// 登录会话 (GET /api/user/sessions)
+export interface AuthSessionItem {
+  id: number;
+  clientClass: 'web' | 'service' | 'router';
+  platform?: string;      // 设备会话：macos / ios / router 等
+  deviceRemark?: string;  // 设备会话：设备备注
+  ipCountry: string;      // ISO 3166-1 alpha-2 小写，可能为空
+  createdAt: number;      // 秒级时间戳
+  lastSeenAt: number;     // 秒级时间戳，约 5 分钟精度
+  current: boolean;       // 是否为当前会话
+}
+
+// 第三方登录 (Google / Apple / GitHub)
+export type OAuthProvider = 'google' | 'apple' | 'github';
+
+// 发起授权 (POST /api/auth/oauth/:provider/start, /api/user/oauth/:provider/link/start)
+export interface OAuthStartResponse {
+  authorizeUrl: string;
+  state: string;          // 保存在本页会话中，完成登录/绑定时提交
+}
+
+// 用回调 ticket 完成登录 (POST /api/auth/web-login/oauth)
+export interface OAuthLoginRequest {
+  ticket: string;
+  state: string;
+  totpCode?: string;      // 两步验证码或恢复码（收到 TwoFactorRequired 后携带）
+  language?: string;
+  inviteCode?: string;
+}
+
+// 已绑定的第三方账号 (GET /api/user/oauth)
+export interface OAuthIdentityItem {
+  provider: OAuthProvider;
+  account: string;        // provider 侧邮箱，没有时为 provider 用户 ID
+  createdAt: number;      // 秒级时间戳
+}
+
 // ============================================================================
 // Error Handling Types
 // ============================================================================
@@ -567,6 +735,13 @@ export const ErrorCode = {
   InvalidCredentials: 400006,      // Invalid credentials
   ProxyMembersDeprecated: 400012,  // 代付成员管理已下线
   VerificationCodeExpired: 400013, // Verification code expired or not sent
+  TwoFactorRequired: 400014,       // 需要两步验证码（凭证已通过，带 totpCode 重新提交）
+  InvalidTwoFactorCode: 400015,    // 两步验证码或恢复码错误
+  OAuthEmailUnverified: 400016,    // 第三方账号没有已验证的邮箱，无法关联或注册
+  CaptchaRequired: 400017,         // 登录风控要求人机验证（带 captchaToken 重新提交）
+  EmailCodeRequired: 400018,       // 登录风控拒绝密码登录，须改用邮箱验证码
+  TwoFactorSetupRequired: 403004,  // 管理员账号须先启用两步验证
+  IdentityAlreadyLinked: 409002,   // 第三方账号已绑定同品牌下的其他账号
   TierMismatch: 422001,            // 跨档购买被拒绝（仅同档续费）
   ProxyPurchaseDeprecated: 422002, // 代付下单已下线
   ChannelUnavailable: 405001,      // 支付渠道不可用（如非 overleap 品牌调用 Stripe 端点）
@@ -874,6 +1049,23 @@ export interface AdminWithdrawListItem {
   processedAt?: number;                  // 处理完成时间
 }
 
+export interface AdminLoginRiskEventItem {
+  id: number;
+  createdAt: number;
+  action: string;                        // send_code, code_login, password_login, device_code
+  brand: string;
+  user?: ResourceUser;                   // 邮箱未注册时为空
+  ip: string;
+  subnet: string;
+  asn: number;
+  country: string;
+  udid: string;
+  score: number;
+  signals: string[];                     // 命中的风控信号
+  decision: string;                      // allow, captcha, email_code, block
+  outcome: string;                       // success, failed, rejected，空 = 请求未走完
+}
+
 export interface AdminOrderListResponse {
     items: AdminOrderListItem[];
     pagination: {
@@ -1817,6 +2009,37 @@ export const api = {
     });
   },
 
+  // 登录风控记录（管理员）
+  async listLoginRiskEvents(params: {
+    page?: number;
+    pageSize?: number;
+    email?: string;
+    ip?: string;
+    userUuid?: string;
+    decision?: string;
+    action?: string;
+  } = {}): Promise<ListResult<AdminLoginRiskEventItem>> {
+    const queryParams = new URLSearchParams();
+    if (params.page !== undefined) queryParams.set('page', params.page.toString());
+    if (params.pageSize !== undefined) queryParams.set('pageSize', params.pageSize.toString());
+    if (params.email) queryParams.set('email', params.email);
+    if (params.ip) queryParams.set('ip', params.ip);
+    if (params.userUuid) queryParams.set('userUuid', params.userUuid);
+    if (params.decision) queryParams.set('decision', params.decision);
+    if (params.action) queryParams.set('action', params.action);
+
+    const query = queryParams.toString();
+    return this.request<ListResult<AdminLoginRiskEventItem>>(`/app/login-risk/events${query ? '?' + query : ''}`);
+  },
+
+  // 解除 IP 的临时登录封禁（管理员）
+  async unblockLoginRiskIP(ip: string): Promise<void> {
+    return this.request<void>('/app/login-risk/unblock', {
+      method: 'POST',
+      body: JSON.stringify({ ip }),
+    });
+  },
+
   // Withdraw management APIs
   async listWithdrawRequests(params: {
     page?: number;
@@ -1896,6 +2119,156 @@ export const api = {
     });
   },
 
+  async getTwoFactor(): Promise<TwoFactorStatus> {
+    return this.request<TwoFactorStatus>('/api/user/2fa');
+  },
+
+  /**
+   * Generate a pending TOTP secret. It only takes effect after
+   * `enableTwoFactor` confirms a code from the authenticator app.
+   */
+  async setupTwoFactor(): Promise<TwoFactorSetup> {
+    return this.request<TwoFactorSetup>('/api/user/2fa/setup', {
+      method: 'POST',
+    });
+  },
+
+  async enableTwoFactor(code: string): Promise<RecoveryCodesResponse> {
+    return this.request<RecoveryCodesResponse>('/api/user/2fa/enable', {
+      method: 'POST',
+      body: JSON.stringify({ code }),
+    });
+  },
+
+  /** `code` may be a TOTP code or a recovery code. */
+  async disableTwoFactor(code: string): Promise<TwoFactorStatus> {
+    return this.request<TwoFactorStatus>('/api/user/2fa/disable', {
+      method: 'POST',
+      body: JSON.stringify({ code }),
+    });
+  },
+
+  async regenerateRecoveryCodes(code: string): Promise<RecoveryCodesResponse> {
+    return this.request<RecoveryCodesResponse>('/api/user/2fa/recovery-codes', {
+      method: 'POST',
+      body: JSON.stringify({ code }),
+    });
+  },
+
+  /**
+   * Passkey login options. The RP ID follows the brand of the page
+   * origin, so this fails with NotSupported on non-brand hosts.
+   */
+  async passkeyLoginOptions(): Promise<PasskeyRequestOptions> {
+    return this.request<PasskeyRequestOptions>('/api/auth/web-login/passkey/options', {
+      method: 'POST',
+    });
+  },
+
+  /** Passkey login skips two-factor: user verification is already required. */
+  async passkeyLogin(
+    data: PasskeyLoginRequest,
+    options?: Pick<ApiRequestOptions, 'autoRedirectToAuth'>,
+  ): Promise<WebLoginResponse> {
+    return this.request<WebLoginResponse>('/api/auth/web-login/passkey', {
+      method: 'POST',
+      body: JSON.stringify(data),
+      ...options,
+    });
+  },
+
+  async listPasskeys(): Promise<ListResult<Passkey>> {
+    return this.request<ListResult<Passkey>>('/api/user/passkeys');
+  },
+
+  async passkeyRegisterOptions(): Promise<PasskeyCreationOptions> {
+    return this.request<PasskeyCreationOptions>('/api/user/passkeys/register/options', {
+      method: 'POST',
+    });
+  },
+
+  async registerPasskey(data: PasskeyRegisterRequest): Promise<Passkey> {
+    return this.request<Passkey>('/api/user/passkeys/register', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    });
+  },
+
+  async updatePasskey(id: number, remark: string): Promise<void> {
+    return this.request<void>(`/api/user/passkeys/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify({ remark }),
+    });
+  },
+
+  async deletePasskey(id: number): Promise<void> {
+    return this.request<void>(`/api/user/passkeys/${id}`, {
+      method: 'DELETE',
+    });
+  },
+
+  /** Social login providers configured for the brand of the page origin. */
+  async oauthProviders(): Promise<{ providers: OAuthProvider[] }> {
+    return this.request<{ providers: OAuthProvider[] }>('/api/auth/oauth/providers');
+  },
+
+  async oauthStart(provider: OAuthProvider): Promise<OAuthStartResponse> {
+    return this.request<OAuthStartResponse>(`/api/auth/oauth/${provider}/start`, {
+      method: 'POST',
+    });
+  },
+
+  /**
+   * Completes a social login with the ticket from the callback redirect.
+   * Accounts with two-factor enabled get TwoFactorRequired first; retry
+   * with totpCode while the ticket is still valid.
+   */
+  async oauthLogin(
+    data: OAuthLoginRequest,
+    options?: Pick<ApiRequestOptions, 'autoRedirectToAuth'>,
+  ): Promise<WebLoginResponse> {
+    return this.request<WebLoginResponse>('/api/auth/web-login/oauth', {
+      method: 'POST',
+      body: JSON.stringify(data),
+      ...options,
+    });
+  },
+
+  async listOAuthIdentities(): Promise<ListResult<OAuthIdentityItem>> {
+    return this.request<ListResult<OAuthIdentityItem>>('/api/user/oauth');
+  },
+
+  async oauthLinkStart(provider: OAuthProvider): Promise<OAuthStartResponse> {
+    return this.request<OAuthStartResponse>(`/api/user/oauth/${provider}/link/start`, {
+      method: 'POST',
+    });
+  },
+
+  async oauthLink(ticket: string, state: string): Promise<void> {
+    return this.request<void>('/api/user/oauth/link', {
+      method: 'POST',
+      body: JSON.stringify({ ticket, state }),
+    });
+  },
+
+  /** Fails with InvalidOperation when it is the only remaining sign-in method. */
+  async oauthUnlink(provider: OAuthProvider): Promise<void> {
+    return this.request<void>(`/api/user/oauth/${provider}`, {
+      method: 'DELETE',
+    });
+  },
+
+  async listSessions(): Promise<ListResult<AuthSessionItem>> {
+    return this.request<ListResult<AuthSessionItem>>('/api/user/sessions');
+  },
+
+  /** Revokes another session of the current user. The current session must log out instead. */
+  async revokeSession(id: number): Promise<void> {
+    return this.request<void>(`/api/user/sessions/${id}`, {
+      method: 'DELETE',
+    });
+  },
+
   /**
    * Logout - clears server-side HttpOnly cookies
    */
@@ -2132,6 +2505,29 @@ export const api = {
     );
   },
 
+  async getTelemetryOverview(
+    range: '7d' | '30d' | '90d' = '7d'
+  ): Promise<TelemetryOverviewResponse> {
+    return this.request<TelemetryOverviewResponse>(`/app/telemetry/overview?range=${range}`);
+  },
+
+  async getTelemetryBreakdown(
+    dimension: TelemetryDimension,
+    range: '7d' | '30d' | '90d' = '7d'
+  ): Promise<TelemetryBreakdownResponse> {
+    const searchParams = new URLSearchParams({ dimension, range });
+    return this.request<TelemetryBreakdownResponse>(`/app/telemetry/breakdown?${searchParams}`);
+  },
+
+  async getTelemetryFailures(params: {
+    dimension: TelemetryDimension;
+    value: string;
+    range: '7d' | '30d' | '90d';
+  }): Promise<TelemetryFailuresResponse> {
+    const searchParams = new URLSearchParams(params);
+    return this.request<TelemetryFailuresResponse>(`/app/telemetry/failures?${searchParams}`);
+  },
+
   // ==================== Usage Analytics ====================
 
   async getUsageOverview(params: { range: string; os?: string }): Promise<UsageOverviewResponse> {
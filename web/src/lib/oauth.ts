@@ -0,0 +1,46 @@
+import { api, type OAuthProvider } from './api';
+
+// 第三方登录 / 绑定在跳转 provider 前把 state 存在本标签页的 sessionStorage，
+// 回到 /login/oauth 后连同 ticket 提交；别的浏览器拿到 ticket 也无法使用。
+
+const PENDING_KEY = 'oauth_pending';
+
+export const OAUTH_PROVIDER_NAMES: Record<OAuthProvider, string> = {
+  google: 'Google',
+  apple: 'Apple',
+  github: 'GitHub',
+};
+
+export interface PendingOAuth {
+  provider: OAuthProvider;
+  intent: 'login' | 'link';
+  state: string;
+  returnTo: string; // 不带 locale 前缀的站内路径
+}
+
+/** Starts the authorization redirect. Does not return on success. */
+export async function startOAuth(
+  provider: OAuthProvider,
+  intent: PendingOAuth['intent'],
+  returnTo: string,
+): Promise<void> {
+  const { authorizeUrl, state } =
+    intent === 'login' ? await api.oauthStart(provider) : await api.oauthLinkStart(provider);
+  const pending: PendingOAuth = { provider, intent, state, returnTo };
+  sessionStorage.setItem(PENDING_KEY, JSON.stringify(pending));
+  window.location.assign(authorizeUrl);
+}
+
+export function readPendingOAuth(): PendingOAuth | null {
+  if (typeof window === 'undefined') return null;
+  try {
+    const raw = sessionStorage.getItem(PENDING_KEY);
+    return raw ? (JSON.parse(raw) as PendingOAuth) : null;
+  } catch {
+    return null;
+  }
+}
+
+export function clearPendingOAuth(): void {
+  sessionStorage.removeItem(PENDING_KEY);
+}
@@ -0,0 +1,92 @@
+import type {
+  PasskeyCreationOptions,
+  PasskeyLoginRequest,
+  PasskeyRegisterRequest,
+  PasskeyRequestOptions,
+} from './api';
+
+// WebAuthn 的二进制字段在 API 上统一使用 base64url（无填充），
+// 这里负责与 ArrayBuffer 互转，并把浏览器返回的凭证整理成后端请求体。
+
+export function base64urlToBuffer(value: string): ArrayBuffer {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+  const padded = base64 + '='.repeat((4 - (base64.length % 4)) % 4);
+  const binary = atob(padded);
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes.buffer;
+}
+
+export function bufferToBase64url(buffer: ArrayBuffer): string {
+  const bytes = new Uint8Array(buffer);
+  let binary = '';
+  for (let i = 0; i < bytes.length; i++) {
+    binary += String.fromCharCode(bytes[i]);
+  }
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+export function isPasskeySupported(): boolean {
+  return (
+    typeof window !== 'undefined' &&
+    typeof window.PublicKeyCredential !== 'undefined' &&
+    typeof navigator !== 'undefined' &&
+    !!navigator.credentials
+  );
+}
+
+/**
+ * The user dismissed the browser prompt or it timed out. Browsers report
+ * both as NotAllowedError, so callers should stay silent rather than toast.
+ */
+export function isPasskeyCancelled(err: unknown): boolean {
+  return err instanceof DOMException && (err.name === 'NotAllowedError' || err.name === 'AbortError');
+}
+
+export async function createPasskey(
+  options: PasskeyCreationOptions,
+  remark?: string,
+): Promise<PasskeyRegisterRequest> {
+  const credential = (await navigator.credentials.create({
+    publicKey: {
+      ...options,
+      challenge: base64urlToBuffer(options.challenge),
+      user: { ...options.user, id: base64urlToBuffer(options.user.id) },
+      excludeCredentials: options.excludeCredentials.map((c) => ({ ...c, id: base64urlToBuffer(c.id) })),
+    },
+  })) as PublicKeyCredential | null;
+  if (!credential) {
+    throw new DOMException('No credential returned', 'NotAllowedError');
+  }
+  const response = credential.response as AuthenticatorAttestationResponse;
+  return {
+    id: credential.id,
+    clientDataJSON: bufferToBase64url(response.clientDataJSON),
+    attestationObject: bufferToBase64url(response.attestationObject),
+    transports: typeof response.getTransports === 'function' ? response.getTransports() : undefined,
+    remark,
+  };
+}
+
+export async function getPasskeyAssertion(options: PasskeyRequestOptions): Promise<PasskeyLoginRequest> {
+  const credential = (await navigator.credentials.get({
+    publicKey: {
+      ...options,
+      challenge: base64urlToBuffer(options.challenge),
+      allowCredentials: options.allowCredentials.map((c) => ({ ...c, id: base64urlToBuffer(c.id) })),
+    },
+  })) as PublicKeyCredential | null;
+  if (!credential) {
+    throw new DOMException('No credential returned', 'NotAllowedError');
+  }
+  const response = credential.response as AuthenticatorAssertionResponse;
+  return {
+    id: credential.id,
+    clientDataJSON: bufferToBase64url(response.clientDataJSON),
+    authenticatorData: bufferToBase64url(response.authenticatorData),
+    signature: bufferToBase64url(response.signature),
+    userHandle: response.userHandle ? bufferToBase64url(response.userHandle) : undefined,
+  };
+}
@@ -68,6 +68,8 @@ export default function EmailLoginForm({ onLoginSuccess }: EmailLoginFormProps)
   const [inviteCode, setInviteCode] = useState("");
   const [loginMethod, setLoginMethod] = useState<"code" | "password">("code");
   const [password, setPassword] = useState("");
+  // undefined until the backend asks for a second factor (TWO_FACTOR_REQUIRED)
+  const [totpCode, setTotpCode] = useState<string | undefined>(undefined);
 
   // UI state
   const [step, setStep] = useState<"email" | "code">("email");
@@ -242,8 +244,20 @@ export default function EmailLoginForm({ onLoginSuccess }: EmailLoginFormProps)
         deviceName: deviceRemark,
         platform: window._platform?.os || '',
         language: i18n.language,
+        totpCode: totpCode?.trim() || undefined,
       });
 
+      // Credentials were accepted but the account has 2FA on: reveal the code
+      // field and resubmit with the same email + password.
+      if (response.code === ERROR_CODES.TWO_FACTOR_REQUIRED && totpCode === undefined) {
+        setTotpCode("");
+        setError(t("auth:auth.twoFactorRequired"));
+        return;
+      }
+      if (response.code === ERROR_CODES.INVALID_TWO_FACTOR_CODE) {
+        setTotpCode("");
+      }
+
       handleResponseError(response.code, response.message, t, t("auth:auth.loginFailed"));
 
       cacheStore.clear();
@@ -401,6 +415,8 @@ export default function EmailLoginForm({ onLoginSuccess }: EmailLoginFormProps)
             password={password}
             onEmailChange={(v) => { setEmail(v); if (emailSuggestion) setEmailSuggestion(null); }}
             onPasswordChange={setPassword}
+            totpCode={totpCode}
+            onTotpCodeChange={setTotpCode}
             onSubmit={handlePasswordLogin}
             onEmailBlur={() => {
               const cleaned = email.trim().toLowerCase().replace(/\s+/g, '');
@@ -91,6 +91,8 @@ export default function LoginDialog() {
   const [error, setError] = useState("");
   const [loginMethod, setLoginMethod] = useState<"code" | "password">("code");
   const [password, setPassword] = useState("");
+  // undefined until the backend asks for a second factor (TWO_FACTOR_REQUIRED)
+  const [totpCode, setTotpCode] = useState<string | undefined>(undefined);
 
   // Refs for delayed focus (avoid autoFocus timing issues on old WebViews)
   const emailInputRef = useRef<HTMLInputElement>(null);
@@ -116,6 +118,7 @@ export default function LoginDialog() {
       setEmailSuggestion(null);
       setLoginMethod("code");
       setPassword("");
+      setTotpCode(undefined);
     }
   }, [isOpen]);
 
@@ -252,7 +255,19 @@ export default function LoginDialog() {
         deviceName: deviceRemark,
         platform: window._platform?.os || '',
         language: i18n.language,
+        totpCode: totpCode?.trim() || undefined,
       });
+
+      // Credentials were accepted but the account has 2FA on: reveal the code
+      // field and resubmit with the same email + password.
+      if (response.code === ERROR_CODES.TWO_FACTOR_REQUIRED && totpCode === undefined) {
+        setTotpCode("");
+        setError(t("auth:auth.twoFactorRequired"));
+        return;
+      }
+      if (response.code === ERROR_CODES.INVALID_TWO_FACTOR_CODE) {
+        setTotpCode("");
+      }
       handleResponseError(response.code, response.message, t, t("auth:auth.loginFailed"));
       cacheStore.clear();
       setIsAuthenticated(true);
@@ -506,6 +521,8 @@ export default function LoginDialog() {
               password={password}
               onEmailChange={(v) => { setEmail(v); if (emailSuggestion) setEmailSuggestion(null); }}
               onPasswordChange={setPassword}
+              totpCode={totpCode}
+              onTotpCodeChange={setTotpCode}
               onSubmit={handlePasswordLogin}
               onEmailBlur={() => {
                 const cleaned = email.trim().toLowerCase().replace(/\s+/g, '');
@@ -5,7 +5,11 @@ import {
   InputAdornment,
   CircularProgress,
 } from '@mui/material';
-import { AlternateEmail as AlternateEmailIcon, Lock as LockIcon } from '@mui/icons-material';
+import {
+  AlternateEmail as AlternateEmailIcon,
+  Lock as LockIcon,
+  VerifiedUser as VerifiedUserIcon,
+} from '@mui/icons-material';
 import { useTranslation } from 'react-i18next';
 import EmailSuggestion from './EmailSuggestion';
 import { delayedFocus } from '../utils/ui';
@@ -23,6 +27,12 @@ export interface PasswordAuthFieldsProps {
   isSubmitting: boolean;
   /** Auto-focus the email field on mount. Default true. */
   autoFocusEmail?: boolean;
+  /**
+   * Two-factor code. The field is rendered only when this is defined — callers
+   * switch it on after the backend answers TWO_FACTOR_REQUIRED.
+   */
+  totpCode?: string;
+  onTotpCodeChange?: (v: string) => void;
 }
 
 /**
@@ -40,7 +50,9 @@ export default function PasswordAuthFields(props: PasswordAuthFieldsProps) {
   const emailRef = useRef<HTMLInputElement>(null);
 
   const emailValid = isValidEmail(props.email);
-  const canSubmit = emailValid && props.password.length > 0 && !props.isSubmitting;
+  const showTotp = props.totpCode !== undefined;
+  const canSubmit = emailValid && props.password.length > 0 && !props.isSubmitting
+    && (!showTotp || props.totpCode!.trim().length > 0);
 
   useEffect(() => {
     if (props.autoFocusEmail === false) return;
@@ -113,6 +125,33 @@ export default function PasswordAuthFields(props: PasswordAuthFieldsProps) {
         sx={FIELD_SX}
       />
 
+      {showTotp && (
+        <TextField
+          fullWidth
+          label={t('auth:auth.twoFactorCode')}
+          placeholder={t('auth:auth.twoFactorCodePlaceholder')}
+          value={props.totpCode}
+          onChange={(e) => props.onTotpCodeChange?.(e.target.value)}
+          onKeyDown={handleEnter}
+          disabled={props.isSubmitting}
+          autoFocus
+          inputProps={{
+            autoCapitalize: 'none',
+            autoCorrect: 'off',
+            autoComplete: 'one-time-code',
+            spellCheck: false,
+          }}
+          InputProps={{
+            startAdornment: (
+              <InputAdornment position="start">
+                <VerifiedUserIcon color="primary" fontSize="small" />
+              </InputAdornment>
+            ),
+          }}
+          sx={FIELD_SX}
+        />
+      )}
+
       <Button
         fullWidth
         size="large"
@@ -21,11 +21,12 @@ import {
   Delete as DeleteIcon,
   Computer as ComputerIcon,
   EditOutlined as EditIcon,
+  AddLink as AddLinkIcon,
 } from "@mui/icons-material";
 import BackButton from "../components/BackButton";
 import { useTranslation } from "react-i18next";
 import { formatTime } from "../utils/time";
-import { Device } from "../services/api-types";
+import { Device, DeviceAuthInfo } from "../services/api-types";
 
 import { useUser } from "../hooks/useUser";
 import { useAlert } from "../stores";
@@ -46,6 +47,12 @@ export default function Devices() {
   const { showAlert } = useAlert();
   const currentUdid = user?.device?.udid;
 
+  // Approve a headless login (k2-mcp / CI) by its user code
+  const [approveDialogOpen, setApproveDialogOpen] = useState(false);
+  const [approveCode, setApproveCode] = useState("");
+  const [pendingAuth, setPendingAuth] = useState<DeviceAuthInfo | null>(null);
+  const [approving, setApproving] = useState(false);
+
   // Ref for delayed focus when editing device remark
   const remarkInputRef = useRef<HTMLInputElement>(null);
 
@@ -165,6 +172,52 @@ export default function Devices() {
     }
   };
 
+  const handleApproveOpen = () => {
+    setApproveCode("");
+    setPendingAuth(null);
+    setApproveDialogOpen(true);
+  };
+
+  const handleLookupCode = async () => {
+    if (approveCode.trim() === "") return;
+    setApproving(true);
+    try {
+      const response = await cloudApi.get<DeviceAuthInfo>(`/api/user/device-auth/${encodeURIComponent(approveCode.trim())}`);
+      if (response.code !== 0 || !response.data) {
+        console.error('[Devices] Lookup device code failed:', response.code, response.message);
+        showAlert(t('account:devices.codeNotFound'), 'error');
+        return;
+      }
+      setPendingAuth(response.data);
+    } catch (err) {
+      console.error('[Devices] Lookup device code failed:', err);
+      showAlert(t('account:devices.codeNotFound'), 'error');
+    } finally {
+      setApproving(false);
+    }
+  };
+
+  const handleApproveConfirm = async () => {
+    if (!pendingAuth) return;
+    setApproving(true);
+    try {
+      const response = await cloudApi.post('/api/user/device-auth/approve', { userCode: pendingAuth.userCode });
+      if (response.code !== 0) {
+        console.error('[Devices] Approve device failed:', response.code, response.message);
+        showAlert(t('account:devices.approveFailed'), 'error');
+        return;
+      }
+      console.info('[Devices] Approve device success');
+      showAlert(t('account:devices.approveSuccess'), 'success');
+      setApproveDialogOpen(false);
+    } catch (err) {
+      console.error('[Devices] Approve device failed:', err);
+      showAlert(t('account:devices.approveFailed'), 'error');
+    } finally {
+      setApproving(false);
+    }
+  };
+
   return (
     <Box sx={{
       width: "100%",
@@ -177,6 +230,9 @@ export default function Devices() {
         <Typography variant="h6" sx={{ flex: 1, fontWeight: 600 }} component="span">
           {t('account:devices.title')}
         </Typography>
+        <Button size="small" startIcon={<AddLinkIcon />} onClick={handleApproveOpen}>
+          {t('account:devices.approveNewDevice')}
+        </Button>
       </Box>
 
       {loading ? (
@@ -293,6 +349,59 @@ export default function Devices() {
           </Button>
         </DialogActions>
       </Dialog>
+
+      <Dialog
+        open={approveDialogOpen}
+        onClose={() => setApproveDialogOpen(false)}
+        fullWidth
+        maxWidth="xs"
+      >
+        <DialogTitle>{t('account:devices.approveDialogTitle')}</DialogTitle>
+        <DialogContent>
+          {pendingAuth ? (
+            <Typography>
+              {t('account:devices.approveConfirmMessage', {
+                remark: pendingAuth.remark || t('account:devices.unnamedDevice'),
+                ip: pendingAuth.clientIp,
+              })}
+            </Typography>
+          ) : (
+            <TextField
+              value={approveCode}
+              onChange={e => setApproveCode(e.target.value)}
+              onKeyDown={e => {
+                if (e.key === 'Enter') {
+                  handleLookupCode();
+                }
+              }}
+              label={t('account:devices.approveCodeLabel')}
+              helperText={t('account:devices.approveCodeHint')}
+              placeholder="BCDF-GHJK"
+              disabled={approving}
+              autoFocus
+              fullWidth
+              margin="dense"
+              inputProps={{
+                autoCapitalize: "characters",
+                autoCorrect: "off",
+                spellCheck: false,
+              }}
+            />
+          )}
+        </DialogContent>
+        <DialogActions>
+          <Button onClick={() => setApproveDialogOpen(false)}>{t('common:common.cancel')}</Button>
+          {pendingAuth ? (
+            <Button onClick={handleApproveConfirm} color="primary" disabled={approving}>
+              {t('account:devices.approve')}
+            </Button>
+          ) : (
+            <Button onClick={handleLookupCode} disabled={approving || approveCode.trim() === ""}>
+              {t('account:devices.lookupCode')}
+            </Button>
+          )}
+        </DialogActions>
+      </Dialog>
     </Box>
   );
 }
@@ -31,6 +31,14 @@ export interface DeviceListResponse {
   items: Device[]; // 设备列表
 }
 
+// 待批准的无交互登录（k2-mcp / CI 设备授权码）
+export interface DeviceAuthInfo {
+  userCode: string; // 授权码，形如 BCDF-GHJK
+  remark: string; // 申请设备的备注
+  clientIp: string; // 申请方 IP
+  createdAt: number; // 申请时间
+}
+
 export interface UpdateDeviceRemarkRequest {
   udid: string; // 设备唯一标识
   remark: string; // 新的备注
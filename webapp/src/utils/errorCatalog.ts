@@ -84,6 +84,11 @@ export const ERROR_CODES = {
   PROXY_MEMBERS_DEPRECATED: 400012,
   VERIFICATION_CODE_EXPIRED: 400013,
 
+  // Two-factor authentication (added 2026-10-18)
+  TWO_FACTOR_REQUIRED: 400014,
+  INVALID_TWO_FACTOR_CODE: 400015,
+  TWO_FACTOR_SETUP_REQUIRED: 403004,
+
   // Tier system error codes (added 2026-04-20)
   TIER_MISMATCH: 422001,
   PROXY_PURCHASE_DEPRECATED: 422002,
@@ -258,6 +263,13 @@ export const API_ERROR_CATALOG: Readonly<Record<number, ErrorEntry>> = {
   // actionable message that still does NOT reveal which field was wrong —
   // never the vague loginFailed, which users read as an unspecified error.
   [ERROR_CODES.INVALID_CREDENTIALS]: { key: 'auth:auth.invalidCredentials', defaultValue: 'Incorrect email or password' },
+  // 400014: credentials were fine but the account has 2FA on — the login forms
+  // catch this first to reveal the code field; the catalog entry is the fallback.
+  [ERROR_CODES.TWO_FACTOR_REQUIRED]: { key: 'auth:auth.twoFactorRequired', defaultValue: 'Enter the code from your authenticator app' },
+  [ERROR_CODES.INVALID_TWO_FACTOR_CODE]: { key: 'auth:auth.invalidTwoFactorCode', defaultValue: 'Invalid two-factor or recovery code' },
+  // 403004: admin-role account without 2FA hitting an admin route; enrolment
+  // happens on the website, so just point the user there.
+  [ERROR_CODES.TWO_FACTOR_SETUP_REQUIRED]: { key: 'auth:auth.twoFactorSetupRequired', defaultValue: 'Turn on two-factor authentication in Account security on the website first' },
   [ERROR_CODES.LICENSE_KEY_NOT_FOUND]: { key: 'common:errors.client.licenseKeyNotFound', defaultValue: 'License key not found' },
   [ERROR_CODES.LICENSE_KEY_USED]: { key: 'common:errors.client.licenseKeyUsed', defaultValue: 'License key already used' },
   [ERROR_CODES.LICENSE_KEY_EXPIRED]: { key: 'common:errors.client.licenseKeyExpired', defaultValue: 'License key expired' },